@@ -4267,6 +4267,12 @@ enum LSIFUploadState {
 
     # This upload is queued to be processed later.
     QUEUED
+
+    # This upload failed to be processed after exhausting its retries and will not be retried again.
+    FAILED
+
+    # This upload was deleted and will be removed once it can no longer be restored.
+    DELETING
 }
 
 # Metadata and status about an LSIF upload.
@@ -4298,7 +4304,7 @@ type LSIFUpload implements Node {
     # The time the upload compelted or errored.
     finishedAt: DateTime
 
-    # Metadata about an upload's failure (not set if state is not ERRORED).
+    # Metadata about an upload's failure (not set if state is not ERRORED or FAILED).
     failure: LSIFUploadFailureReason
 
     # Whether or not this upload provides intelligence for the tip of the default branch. Find reference
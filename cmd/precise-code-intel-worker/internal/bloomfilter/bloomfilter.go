@@ -0,0 +1,130 @@
+// Package bloomfilter creates bloom filters that are compatible with the filters
+// created and tested by the TypeScript code intel services, which use version 0.0.18
+// of the bloomfilter npm package.
+package bloomfilter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"unicode/utf16"
+)
+
+const (
+	// BloomFilterBits is the number of bits allocated for new bloom filters.
+	BloomFilterBits = 64 * 1024
+
+	// BloomFilterNumHashFunctions is the number of hash functions used to determine if
+	// a value is a member of the filter.
+	BloomFilterNumHashFunctions = 16
+)
+
+// CreateFilter creates a bloom filter containing the given values and returns the
+// gzipped JSON encoding expected by the api-server.
+func CreateFilter(identifiers []string) ([]byte, error) {
+	buckets := make([]int32, BloomFilterBits/32)
+	for _, identifier := range identifiers {
+		for _, location := range locations(identifier, BloomFilterBits, BloomFilterNumHashFunctions) {
+			buckets[location/32] |= 1 << (location % 32)
+		}
+	}
+
+	// Store the number of hash functions used to create this as it may change after
+	// this value is serialized. We don't want to test with more hash functions than
+	// it was created with, otherwise we'll get false negatives.
+	payload, err := json.Marshal(struct {
+		NumHashFunctions int     `json:"numHashFunctions"`
+		Buckets          []int32 `json:"buckets"`
+	}{BloomFilterNumHashFunctions, buckets})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err := gzipWriter.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// TestFilter decodes a filter created by CreateFilter and determines if the value is a
+// possible element. This may return a false positive, but will not return false negatives.
+func TestFilter(filter []byte, identifier string) (bool, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(filter))
+	if err != nil {
+		return false, err
+	}
+	defer gzipReader.Close()
+
+	var payload struct {
+		NumHashFunctions int     `json:"numHashFunctions"`
+		Buckets          []int32 `json:"buckets"`
+	}
+	if err := json.NewDecoder(gzipReader).Decode(&payload); err != nil {
+		return false, err
+	}
+
+	for _, location := range locations(identifier, int32(len(payload.Buckets)*32), payload.NumHashFunctions) {
+		if payload.Buckets[location/32]&(1<<(location%32)) == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// locations returns the k bit offsets of the value in a filter with m bits. This must
+// match BloomFilter.prototype.locations, including the semantics of JavaScript's
+// truncated remainder operator on signed 32-bit values.
+func locations(value string, m int32, k int) []int32 {
+	a := int64(fnv1a(value, 0))
+	b := int64(fnv1a(value, 1576284489))
+	x := a % int64(m)
+
+	r := make([]int32, k)
+	for i := 0; i < k; i++ {
+		if x < 0 {
+			r[i] = int32(x + int64(m))
+		} else {
+			r[i] = int32(x)
+		}
+
+		x = (x + b) % int64(m)
+	}
+
+	return r
+}
+
+// fnv1a is a Fowler/Noll/Vo hash over the UTF-16 code units of the value. The seed is
+// incorporated into the offset basis.
+func fnv1a(value string, seed uint32) int32 {
+	a := uint32(2166136261) ^ seed
+	for _, c := range utf16.Encode([]rune(value)) {
+		if d := uint32(c) & 0xff00; d != 0 {
+			a = fnvMultiply(a ^ d>>8)
+		}
+		a = fnvMultiply(a ^ uint32(c)&0xff)
+	}
+
+	return int32(fnvMix(a))
+}
+
+// fnvMultiply returns a * 16777619 mod 2**32.
+func fnvMultiply(a uint32) uint32 {
+	return a + (a << 1) + (a << 4) + (a << 7) + (a << 8) + (a << 24)
+}
+
+// fnvMix applies a final avalanche step to the hash value.
+func fnvMix(a uint32) uint32 {
+	a += a << 13
+	a ^= a >> 7
+	a += a << 3
+	a ^= a >> 17
+	a += a << 5
+	return a
+}
@@ -0,0 +1,57 @@
+package bloomfilter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLocations(t *testing.T) {
+	// Expected values generated by the bloomfilter npm package (v0.0.18)
+	testCases := []struct {
+		value    string
+		expected []int32
+	}{
+		{"foo", []int32{52015, 44361, 36707, 29053, 21399, 13745, 6091, 63973, 56319, 48665, 41011, 33357, 25703, 18049, 10395, 2741}},
+		{"bar", []int32{57123, 1303, 11019, 20735, 30451, 40167, 49883, 59599, 3779, 13495, 23211, 32927, 42643, 52359, 62075, 6255}},
+		{"github.com/sourcegraph/sourcegraph/internal/lsif:Foo", []int32{16616, 35925, 55234, 9007, 28316, 47625, 1398, 20707, 40016, 59325, 13098, 32407, 51716, 5489, 24798, 44107}},
+		{"ünïcödé", []int32{1257, 62857, 58921, 54985, 51049, 47113, 43177, 39241, 35305, 31369, 27433, 23497, 19561, 15625, 11689, 7753}},
+		{"日本語", []int32{26546, 54922, 17762, 46138, 8978, 37354, 194, 28570, 56946, 19786, 48162, 11002, 39378, 2218, 30594, 58970}},
+		{"😀x", []int32{54373, 51215, 48057, 44899, 41741, 38583, 35425, 32267, 29109, 25951, 22793, 19635, 16477, 13319, 10161, 7003}},
+		{"", []int32{34718, 17845, 972, 49635, 32762, 15889, 64552, 47679, 30806, 13933, 62596, 45723, 28850, 11977, 60640, 43767}},
+	}
+
+	for _, testCase := range testCases {
+		if actual := locations(testCase.value, BloomFilterBits, BloomFilterNumHashFunctions); !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("unexpected locations for %q. want=%v have=%v", testCase.value, testCase.expected, actual)
+		}
+	}
+}
+
+func TestCreateFilter(t *testing.T) {
+	var identifiers []string
+	for i := 0; i < 1000; i++ {
+		identifiers = append(identifiers, fmt.Sprintf("identifier-%d", i))
+	}
+
+	filter, err := CreateFilter(identifiers)
+	if err != nil {
+		t.Fatalf("unexpected error creating filter: %s", err)
+	}
+
+	for _, identifier := range identifiers {
+		if ok, err := TestFilter(filter, identifier); err != nil {
+			t.Fatalf("unexpected error testing filter: %s", err)
+		} else if !ok {
+			t.Errorf("expected %q to be in filter", identifier)
+		}
+	}
+
+	for _, identifier := range []string{"foo", "bar", "baz"} {
+		if ok, err := TestFilter(filter, identifier); err != nil {
+			t.Fatalf("unexpected error testing filter: %s", err)
+		} else if ok {
+			t.Errorf("expected %q not to be in filter", identifier)
+		}
+	}
+}
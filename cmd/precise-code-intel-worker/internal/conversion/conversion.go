@@ -0,0 +1,435 @@
+// Package conversion flattens the correlated data of an LSIF dump into the tables of
+// a SQLite bundle and gathers the package data required to populate Postgres.
+package conversion
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/correlation"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/sqlite"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+const (
+	// ResultsPerResultChunk is the target number of results per result chunk. This is
+	// used to determine the number of chunks created during conversion, but does not
+	// guarantee that the distribution of hash keys will be even.
+	ResultsPerResultChunk = 500
+
+	// MaxNumResultChunks is the maximum number of result chunks created during conversion.
+	MaxNumResultChunks = 1000
+)
+
+// DocumentStatistics are statistics about the documents of a converted dump.
+type DocumentStatistics struct {
+	// NumDocuments is the number of documents in the dump.
+	NumDocuments int
+	// NumRanges is the total number of ranges over all documents in the dump.
+	NumRanges int
+	// DocumentsByLanguage maps a language (identified by file extension) to the number of documents in the dump.
+	DocumentsByLanguage map[string]int
+}
+
+// Result is the data returned from a conversion that is required to populate Postgres.
+type Result struct {
+	// Packages are the packages provided by the dump.
+	Packages []types.Package
+	// References are the packages and symbols referenced by the dump.
+	References []types.PackageReference
+	// Statistics are statistics about the documents of the dump.
+	Statistics DocumentStatistics
+}
+
+// ProgressFunc reports the fraction (between 0 and 1) of the bundle that has been written.
+type ProgressFunc func(fraction float64)
+
+// Convert reads the (uncompressed) LSIF dump from the given reader and writes a SQLite
+// bundle to the given filename whose payloads are written with the given encoding. Returns
+// the package, reference, and statistics data needed to populate Postgres. The progress of
+// writing the bundle is reported to the given function, if any.
+func Convert(ctx context.Context, r io.Reader, root, filename string, payloadEncoding sqlite.PayloadEncoding, directoryChildren DirectoryChildrenFunc, onProgress ProgressFunc) (*Result, error) {
+	if onProgress == nil {
+		onProgress = func(fraction float64) {}
+	}
+
+	state, err := correlation.Correlate(r, root)
+	if err != nil {
+		return nil, err
+	}
+
+	documentPaths := make([]string, 0, len(state.DocumentPaths))
+	for _, documentPath := range state.DocumentPaths {
+		documentPaths = append(documentPaths, documentPath)
+	}
+
+	// Make all necessary visibility queries to gitserver here. This allows us to batch
+	// the requests to reduce the number of network roundtrips.
+	checker, err := NewPathExistenceChecker(ctx, root, documentPaths, directoryChildren)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := sqlite.NewWriter(filename, payloadEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	statistics, err := write(writer, state, checker, onProgress)
+	if err := writer.Close(err); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Packages:   packages(state),
+		References: references(state),
+		Statistics: statistics,
+	}, nil
+}
+
+// write populates each table of the bundle. The fraction reported after each table is its
+// approximate share of the time it takes to write the bundle.
+func write(writer *sqlite.Writer, state *correlation.State, checker *PathExistenceChecker, onProgress ProgressFunc) (DocumentStatistics, error) {
+	onProgress(0)
+
+	// Calculate the number of result chunks that we'll attempt to populate
+	numResults := len(state.DefinitionData) + len(state.ReferenceData) + len(state.ImplementationData)
+	numResultChunks := numResults / ResultsPerResultChunk
+	if numResultChunks == 0 {
+		numResultChunks = 1
+	}
+	if numResultChunks > MaxNumResultChunks {
+		numResultChunks = MaxNumResultChunks
+	}
+
+	if err := writer.WriteMeta(state.LSIFVersion, numResultChunks); err != nil {
+		return DocumentStatistics{}, err
+	}
+
+	statistics, err := writeDocuments(writer, state, checker)
+	if err != nil {
+		return DocumentStatistics{}, err
+	}
+	onProgress(0.5)
+
+	if err := writeResultChunks(writer, state, checker, numResultChunks); err != nil {
+		return DocumentStatistics{}, err
+	}
+	onProgress(0.7)
+
+	if err := writeDefinitionsAndReferences(writer, state, checker); err != nil {
+		return DocumentStatistics{}, err
+	}
+	onProgress(0.95)
+
+	if err := writeDiagnostics(writer, state, checker); err != nil {
+		return DocumentStatistics{}, err
+	}
+
+	return statistics, nil
+}
+
+// writeDocuments encodes and inserts all document entries for this dump. Returns
+// statistics about the inserted documents.
+func writeDocuments(writer *sqlite.Writer, state *correlation.State, checker *PathExistenceChecker) (DocumentStatistics, error) {
+	statistics := DocumentStatistics{DocumentsByLanguage: map[string]int{}}
+
+	for documentID, documentPath := range state.DocumentPaths {
+		// Do not gather any document that is not within the dump root or does not exist
+		// in git. If the path is outside of the dump root, then it will never be queried
+		// as the current text document path and the dump root are compared to determine
+		// which dump to open. If the path does not exist in git, it will also never be
+		// queried.
+		if !checker.ShouldIncludePath(documentPath, true) {
+			continue
+		}
+
+		document := gatherDocument(state, documentID)
+		if err := writer.WriteDocument(documentPath, document); err != nil {
+			return DocumentStatistics{}, err
+		}
+
+		statistics.NumDocuments++
+		statistics.NumRanges += len(document.Ranges)
+		statistics.DocumentsByLanguage[LanguageFromPath(documentPath)]++
+	}
+
+	return statistics, nil
+}
+
+// gatherDocument creates a self-contained document object from the correlation state.
+// This includes hover and moniker results, as well as identifiers to definition and
+// reference results (but not the actual ranges).
+func gatherDocument(state *correlation.State, documentID types.ID) types.DocumentData {
+	document := types.DocumentData{
+		Ranges:             map[types.ID]types.RangeData{},
+		HoverResults:       map[types.ID]string{},
+		Monikers:           map[types.ID]types.MonikerData{},
+		PackageInformation: map[types.ID]types.PackageInformationData{},
+	}
+
+	for rangeID := range state.ContainsData[documentID] {
+		r := state.RangeData[rangeID]
+		document.Ranges[rangeID] = r
+
+		if r.HoverResultID != "" {
+			document.HoverResults[r.HoverResultID] = state.HoverData[r.HoverResultID]
+		}
+
+		for monikerID := range r.MonikerIDs {
+			moniker := state.MonikerData[monikerID]
+			document.Monikers[monikerID] = moniker
+
+			if moniker.PackageInformationID != "" {
+				document.PackageInformation[moniker.PackageInformationID] = state.PackageInformationData[moniker.PackageInformationID]
+			}
+		}
+	}
+
+	return document
+}
+
+// LanguageFromPath returns the language of a document as identified by its (lowercased)
+// file extension. Documents without an extension are attributed to the language `unknown`.
+func LanguageFromPath(documentPath string) string {
+	if ext := strings.ToLower(strings.TrimPrefix(path.Ext(documentPath), ".")); ext != "" {
+		return ext
+	}
+	return "unknown"
+}
+
+// writeResultChunks correlates and inserts all result chunk entries for this dump.
+func writeResultChunks(writer *sqlite.Writer, state *correlation.State, checker *PathExistenceChecker, numResultChunks int) error {
+	// Create all the result chunks we'll be populating and inserting up-front. Data will
+	// be inserted into result chunks based on hash values (modulo the number of result
+	// chunks), and we don't want to create them lazily.
+	resultChunks := make([]types.ResultChunkData, numResultChunks)
+	for i := range resultChunks {
+		resultChunks[i] = types.ResultChunkData{
+			DocumentPaths:      map[types.ID]string{},
+			DocumentIDRangeIDs: map[types.ID][]types.DocumentIDRangeID{},
+		}
+	}
+
+	chunkResults := func(data map[types.ID]map[types.ID][]types.ID) {
+		for id, documentRanges := range data {
+			resultChunk := resultChunks[sqlite.HashKey(id, len(resultChunks))]
+
+			documentIDRangeIDs := []types.DocumentIDRangeID{}
+			for documentID, rangeIDs := range documentRanges {
+				documentPath := state.DocumentPaths[documentID]
+
+				// Skip pointing to locations that are not available in git. This can occur
+				// with indexers that point to generated files or dependencies that are not
+				// committed (e.g. node_modules). Keeping these in the dump can cause the
+				// UI to redirect to a path that doesn't exist.
+				if !checker.ShouldIncludePath(documentPath, false) {
+					continue
+				}
+
+				for _, rangeID := range rangeIDs {
+					// Add paths into the result chunk where they are used
+					resultChunk.DocumentPaths[documentID] = documentPath
+
+					documentIDRangeIDs = append(documentIDRangeIDs, types.DocumentIDRangeID{
+						DocumentID: documentID,
+						RangeID:    rangeID,
+					})
+				}
+			}
+
+			resultChunk.DocumentIDRangeIDs[id] = documentIDRangeIDs
+		}
+	}
+
+	// Add definitions, references, and implementations to result chunks
+	chunkResults(state.DefinitionData)
+	chunkResults(state.ReferenceData)
+	chunkResults(state.ImplementationData)
+
+	for id, resultChunk := range resultChunks {
+		// Empty chunk, no need to serialize as it will never be queried
+		if len(resultChunk.DocumentPaths) == 0 && len(resultChunk.DocumentIDRangeIDs) == 0 {
+			continue
+		}
+
+		if err := writer.WriteResultChunk(id, resultChunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDefinitionsAndReferences correlates and inserts all definition and reference
+// entries for this dump.
+func writeDefinitionsAndReferences(writer *sqlite.Writer, state *correlation.State, checker *PathExistenceChecker) error {
+	// Determine the set of monikers that are attached to a definition or a reference
+	// result. Correlating information in this way has two benefits:
+	//   (1) it reduces duplicates in the definitions and references tables
+	//   (2) it stop us from re-iterating over the range data of the entire
+	//       LSIF dump, which is by far the largest proportion of data.
+
+	definitionMonikers := map[types.ID]types.IDSet{}
+	referenceMonikers := map[types.ID]types.IDSet{}
+
+	for _, r := range state.RangeData {
+		if len(r.MonikerIDs) == 0 {
+			continue
+		}
+
+		if r.DefinitionResultID != "" {
+			addMonikers(definitionMonikers, r.DefinitionResultID, r.MonikerIDs)
+		}
+		if r.ReferenceResultID != "" {
+			addMonikers(referenceMonikers, r.ReferenceResultID, r.MonikerIDs)
+		}
+	}
+
+	writeMonikerRanges := func(data map[types.ID]map[types.ID][]types.ID, monikers map[types.ID]types.IDSet, write func(types.Location) error) error {
+		for id, documentRanges := range data {
+			// Correlate each moniker with the document/range pairs stored in the result set
+			for monikerID := range monikers[id] {
+				moniker := state.MonikerData[monikerID]
+
+				for documentID, rangeIDs := range documentRanges {
+					documentPath := state.DocumentPaths[documentID]
+
+					// Skip definitions or references that point to a document that are not
+					// present in the dump. Including this would cause a query that always
+					// fails when it cannot resolve the missing document data.
+					if !checker.ShouldIncludePath(documentPath, true) {
+						continue
+					}
+
+					for _, rangeID := range rangeIDs {
+						r := state.RangeData[rangeID]
+
+						if err := write(types.Location{
+							Scheme:         moniker.Scheme,
+							Identifier:     moniker.Identifier,
+							DocumentPath:   documentPath,
+							StartLine:      r.StartLine,
+							StartCharacter: r.StartCharacter,
+							EndLine:        r.EndLine,
+							EndCharacter:   r.EndCharacter,
+						}); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+
+		return nil
+	}
+
+	// Insert definitions and references records
+	if err := writeMonikerRanges(state.DefinitionData, definitionMonikers, writer.WriteDefinition); err != nil {
+		return err
+	}
+	return writeMonikerRanges(state.ReferenceData, referenceMonikers, writer.WriteReference)
+}
+
+// addMonikers adds the given moniker identifiers to the set of the given result.
+func addMonikers(monikers map[types.ID]types.IDSet, resultID types.ID, monikerIDs types.IDSet) {
+	set, ok := monikers[resultID]
+	if !ok {
+		set = types.IDSet{}
+		monikers[resultID] = set
+	}
+
+	for monikerID := range monikerIDs {
+		set.Add(monikerID)
+	}
+}
+
+// writeDiagnostics inserts a row for each diagnostic attached to a document in this dump.
+func writeDiagnostics(writer *sqlite.Writer, state *correlation.State, checker *PathExistenceChecker) error {
+	for documentID, diagnosticResultIDs := range state.DocumentDiagnostics {
+		documentPath, ok := state.DocumentPaths[documentID]
+
+		// Skip diagnostics of documents that were merged into another document or that
+		// are not present in the dump, as they would never be queried.
+		if !ok || !checker.ShouldIncludePath(documentPath, true) {
+			continue
+		}
+
+		for _, diagnosticResultID := range diagnosticResultIDs {
+			for _, diagnostic := range state.DiagnosticData[diagnosticResultID] {
+				if err := writer.WriteDiagnostic(documentPath, diagnostic); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// packages gathers all package information that is referenced by an exported moniker.
+// These will be the packages that are provided by the repository represented by this
+// LSIF dump.
+func packages(state *correlation.State) []types.Package {
+	seen := map[string]struct{}{}
+
+	var packages []types.Package
+	for _, id := range state.ExportedMonikers.Keys() {
+		pkg := monikerPackage(state, id)
+
+		key := packageKey(pkg)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		packages = append(packages, pkg)
+	}
+
+	return packages
+}
+
+// references gathers all imported moniker identifiers along with their package
+// information. These will be the packages that are a dependency of the repository
+// represented by this LSIF dump.
+func references(state *correlation.State) []types.PackageReference {
+	indexes := map[string]int{}
+
+	var references []types.PackageReference
+	for _, id := range state.ImportedMonikers.Keys() {
+		pkg := monikerPackage(state, id)
+		identifier := state.MonikerData[id].Identifier
+
+		key := packageKey(pkg)
+		if index, ok := indexes[key]; ok {
+			references[index].Identifiers = append(references[index].Identifiers, identifier)
+			continue
+		}
+
+		indexes[key] = len(references)
+		references = append(references, types.PackageReference{Package: pkg, Identifiers: []string{identifier}})
+	}
+
+	return references
+}
+
+// monikerPackage returns the package of the given moniker.
+func monikerPackage(state *correlation.State, monikerID types.ID) types.Package {
+	moniker := state.MonikerData[monikerID]
+	packageInformation := state.PackageInformationData[moniker.PackageInformationID]
+
+	return types.Package{
+		Scheme:  moniker.Scheme,
+		Name:    packageInformation.Name,
+		Version: packageInformation.Version,
+	}
+}
+
+// packageKey returns a string that uniquely identifies the given package.
+func packageKey(pkg types.Package) string {
+	key, _ := json.Marshal([]interface{}{pkg.Scheme, pkg.Name, pkg.Version})
+	return string(key)
+}
@@ -0,0 +1,357 @@
+package conversion
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/sqlite"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// testDump is an LSIF dump of github.com/sourcegraph/lsif-go@ad3507cb shared with the
+// tests of the TypeScript code intel services.
+const testDump = "../../../precise-code-intel/test-data/lsif-go@ad3507cb.lsif.gz"
+
+func TestConvert(t *testing.T) {
+	db := convertTestDump(t, sqlite.PayloadEncodingGzipJSON)
+
+	// `\ts, err := indexer.Index()` -> `\t Index() (*Stats, error)`
+	//                      ^^^^^           ^^^^^
+	r := findRange(t, db, "cmd/lsif-go/main.go", 110, 22)
+	expectedDefinitions := []testLocation{{"internal/index/indexer.go", 20, 1, 20, 6}}
+	if definitions := resolveResult(t, db, r["definitionResultId"]); !reflect.DeepEqual(definitions, expectedDefinitions) {
+		t.Errorf("unexpected definitions. want=%v have=%v", expectedDefinitions, definitions)
+	}
+
+	// `func (w *Writer) EmitRange(start, end Pos) (string, error) {`
+	//                   ^^^^^^^^^
+	r = findRange(t, db, "protocol/writer.go", 85, 20)
+	expectedReferences := []testLocation{
+		{"internal/index/indexer.go", 380, 22, 380, 31},
+		{"internal/index/indexer.go", 529, 22, 529, 31},
+		{"protocol/writer.go", 85, 17, 85, 26},
+	}
+	if references := resolveResult(t, db, r["referenceResultId"]); !reflect.DeepEqual(references, expectedReferences) {
+		t.Errorf("unexpected references. want=%v have=%v", expectedReferences, references)
+	}
+
+	// `\tcontents, err := findContents(pkgs, p, f, obj)`
+	//                     ^^^^^^^^^^^^
+	document := readDocument(t, db, "internal/index/indexer.go")
+	r = findRange(t, db, "internal/index/indexer.go", 628, 20)
+	var hoverText string
+	for _, pair := range document.HoverResults.Value {
+		if string(pair[0]) == string(r["hoverResultId"]) {
+			_ = json.Unmarshal(pair[1], &hoverText)
+		}
+	}
+	expectedHoverText := "```go\nfunc findContents(pkgs []*Package, p *Package, f *File, obj Object) ([]MarkedString, error)\n```\n\n---\n\nfindContents returns contents used as hover info for given object."
+	if hoverText != expectedHoverText {
+		t.Errorf("unexpected hover text. want=%q have=%q", expectedHoverText, hoverText)
+	}
+}
+
+func TestConvertMeta(t *testing.T) {
+	db := convertTestDump(t, sqlite.PayloadEncodingGzipJSON)
+
+	var lsifVersion, sourcegraphVersion, payloadEncoding string
+	var numResultChunks, schemaVersion int
+	if err := db.QueryRow(`SELECT "lsifVersion", "sourcegraphVersion", "numResultChunks", "schemaVersion", "payloadEncoding" FROM "meta" WHERE "id" = 1`).Scan(
+		&lsifVersion,
+		&sourcegraphVersion,
+		&numResultChunks,
+		&schemaVersion,
+		&payloadEncoding,
+	); err != nil {
+		t.Fatalf("unexpected error reading meta table: %s", err)
+	}
+
+	if lsifVersion != "0.4.3" || sourcegraphVersion != sqlite.InternalVersion || numResultChunks < 1 || schemaVersion != sqlite.SchemaVersion || payloadEncoding != "gzip-json" {
+		t.Errorf("unexpected meta row: %q %q %d %d %q", lsifVersion, sourcegraphVersion, numResultChunks, schemaVersion, payloadEncoding)
+	}
+}
+
+func TestConvertBinaryPayloads(t *testing.T) {
+	db := convertTestDump(t, sqlite.PayloadEncodingGzipBinary)
+
+	var payloadEncoding string
+	if err := db.QueryRow(`SELECT "payloadEncoding" FROM "meta" WHERE "id" = 1`).Scan(&payloadEncoding); err != nil {
+		t.Fatalf("unexpected error reading meta table: %s", err)
+	}
+	if payloadEncoding != "gzip-binary" {
+		t.Errorf("unexpected payload encoding. want=%q have=%q", "gzip-binary", payloadEncoding)
+	}
+
+	for _, query := range []string{`SELECT "data" FROM "documents"`, `SELECT "data" FROM "resultChunks"`} {
+		var data []byte
+		if err := db.QueryRow(query).Scan(&data); err != nil {
+			t.Fatalf("unexpected error reading payload: %s", err)
+		}
+
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("unexpected error decompressing payload: %s", err)
+		}
+		payload, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("unexpected error decompressing payload: %s", err)
+		}
+
+		// Binary payloads start with the version of the binary encoding
+		if len(payload) == 0 || payload[0] != 1 {
+			t.Errorf("unexpected payload: %q", payload)
+		}
+	}
+}
+
+func TestConvertPathExistence(t *testing.T) {
+	directoryChildren := func(ctx context.Context, dirnames []string) (map[string][]string, error) {
+		return map[string][]string{"cmd/lsif-go": {"cmd/lsif-go/main.go"}}, nil
+	}
+
+	f, err := os.Open(testDump)
+	if err != nil {
+		t.Fatalf("unexpected error opening test dump: %s", err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("unexpected error reading test dump: %s", err)
+	}
+
+	filename := filepath.Join(tempDir(t), "bundle.sqlite")
+	var fractions []float64
+	onProgress := func(fraction float64) { fractions = append(fractions, fraction) }
+
+	result, err := Convert(context.Background(), r, "", filename, sqlite.PayloadEncodingGzipJSON, directoryChildren, onProgress)
+	if err != nil {
+		t.Fatalf("unexpected error converting test dump: %s", err)
+	}
+
+	if expected := []float64{0, 0.5, 0.7, 0.95}; !reflect.DeepEqual(fractions, expected) {
+		t.Errorf("unexpected progress. want=%v have=%v", expected, fractions)
+	}
+
+	if result.Statistics.NumDocuments != 1 || result.Statistics.DocumentsByLanguage["go"] != 1 {
+		t.Errorf("unexpected statistics: %+v", result.Statistics)
+	}
+}
+
+func TestPathExistenceChecker(t *testing.T) {
+	var requested []string
+	directoryChildren := func(ctx context.Context, dirnames []string) (map[string][]string, error) {
+		requested = append(requested, dirnames...)
+
+		return map[string][]string{
+			"":        {"foo.go", "sub"},
+			"sub":     {"sub/bar.go"},
+			"sub/baz": nil,
+		}, nil
+	}
+
+	checker, err := NewPathExistenceChecker(context.Background(), "sub/", []string{"bar.go", "baz/qux.go", "../foo.go", "../../escape.go"}, directoryChildren)
+	if err != nil {
+		t.Fatalf("unexpected error creating checker: %s", err)
+	}
+
+	if expected := []string{"", "sub", "sub/baz"}; !reflect.DeepEqual(requested, expected) {
+		t.Errorf("unexpected requested directories. want=%v have=%v", expected, requested)
+	}
+
+	testCases := []struct {
+		path                string
+		requireDocumentDump bool
+		expected            bool
+	}{
+		{"bar.go", true, true},
+		{"baz/qux.go", true, false},
+		{"../foo.go", false, true},
+		{"../foo.go", true, false},
+		{"../../escape.go", false, false},
+	}
+
+	for _, testCase := range testCases {
+		if actual := checker.ShouldIncludePath(testCase.path, testCase.requireDocumentDump); actual != testCase.expected {
+			t.Errorf("unexpected result for %q (requireDocumentDump=%v). want=%v have=%v", testCase.path, testCase.requireDocumentDump, testCase.expected, actual)
+		}
+	}
+}
+
+type testLocation struct {
+	Path                                             string
+	StartLine, StartCharacter, EndLine, EndCharacter int
+}
+
+// encodedValue is the JSON encoding of a map or set.
+type encodedValue struct {
+	Type  string               `json:"type"`
+	Value [][2]json.RawMessage `json:"value"`
+}
+
+type testRange struct {
+	StartLine      int `json:"startLine"`
+	StartCharacter int `json:"startCharacter"`
+	EndLine        int `json:"endLine"`
+	EndCharacter   int `json:"endCharacter"`
+}
+
+type testDocument struct {
+	Ranges       encodedValue `json:"ranges"`
+	HoverResults encodedValue `json:"hoverResults"`
+}
+
+type testResultChunk struct {
+	DocumentPaths      encodedValue `json:"documentPaths"`
+	DocumentIDRangeIDs encodedValue `json:"documentIdRangeIds"`
+}
+
+func convertTestDump(t *testing.T, payloadEncoding sqlite.PayloadEncoding) *sql.DB {
+	f, err := os.Open(testDump)
+	if err != nil {
+		t.Fatalf("unexpected error opening test dump: %s", err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("unexpected error reading test dump: %s", err)
+	}
+
+	filename := filepath.Join(tempDir(t), "bundle.sqlite")
+	if _, err := Convert(context.Background(), r, "", filename, payloadEncoding, nil, nil); err != nil {
+		t.Fatalf("unexpected error converting test dump: %s", err)
+	}
+
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatalf("unexpected error opening bundle: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "precise-code-intel-worker-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %s", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+func gunzipJSON(t *testing.T, data []byte, v interface{}) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error decompressing data: %s", err)
+	}
+
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		t.Fatalf("unexpected error decoding data: %s", err)
+	}
+}
+
+func readDocument(t *testing.T, db *sql.DB, path string) testDocument {
+	var data []byte
+	if err := db.QueryRow(`SELECT "data" FROM "documents" WHERE "path" = ?`, path).Scan(&data); err != nil {
+		t.Fatalf("unexpected error reading document %s: %s", path, err)
+	}
+
+	var document testDocument
+	gunzipJSON(t, data, &document)
+	if document.Ranges.Type != "map" || document.HoverResults.Type != "map" {
+		t.Fatalf("unexpected document encoding")
+	}
+
+	return document
+}
+
+// findRange returns the range of the document that contains the given position.
+func findRange(t *testing.T, db *sql.DB, path string, line, character int) map[string]json.RawMessage {
+	for _, pair := range readDocument(t, db, path).Ranges.Value {
+		var r testRange
+		if err := json.Unmarshal(pair[1], &r); err != nil {
+			t.Fatalf("unexpected error decoding range: %s", err)
+		}
+
+		if r.StartLine == line && r.StartCharacter <= character && character < r.EndCharacter {
+			var fields map[string]json.RawMessage
+			_ = json.Unmarshal(pair[1], &fields)
+			return fields
+		}
+	}
+
+	t.Fatalf("no range found at %s:%d:%d", path, line, character)
+	return nil
+}
+
+// resolveResult returns the locations of the given definition or reference result in
+// the order of their paths and positions.
+func resolveResult(t *testing.T, db *sql.DB, resultID json.RawMessage) []testLocation {
+	var numResultChunks int
+	if err := db.QueryRow(`SELECT "numResultChunks" FROM "meta" WHERE "id" = 1`).Scan(&numResultChunks); err != nil {
+		t.Fatalf("unexpected error reading meta table: %s", err)
+	}
+
+	var data []byte
+	if err := db.QueryRow(`SELECT "data" FROM "resultChunks" WHERE "id" = ?`, sqlite.HashKey(types.ID(resultID), numResultChunks)).Scan(&data); err != nil {
+		t.Fatalf("unexpected error reading result chunk: %s", err)
+	}
+
+	var resultChunk testResultChunk
+	gunzipJSON(t, data, &resultChunk)
+
+	paths := map[string]string{}
+	for _, pair := range resultChunk.DocumentPaths.Value {
+		var path string
+		_ = json.Unmarshal(pair[1], &path)
+		paths[string(pair[0])] = path
+	}
+
+	var locations []testLocation
+	for _, pair := range resultChunk.DocumentIDRangeIDs.Value {
+		if string(pair[0]) != string(resultID) {
+			continue
+		}
+
+		var documentIDRangeIDs []struct {
+			DocumentID json.RawMessage `json:"documentId"`
+			RangeID    json.RawMessage `json:"rangeId"`
+		}
+		_ = json.Unmarshal(pair[1], &documentIDRangeIDs)
+
+		for _, pair := range documentIDRangeIDs {
+			path := paths[string(pair.DocumentID)]
+
+			for _, rangePair := range readDocument(t, db, path).Ranges.Value {
+				if string(rangePair[0]) != string(pair.RangeID) {
+					continue
+				}
+
+				var r testRange
+				_ = json.Unmarshal(rangePair[1], &r)
+				locations = append(locations, testLocation{path, r.StartLine, r.StartCharacter, r.EndLine, r.EndCharacter})
+			}
+		}
+	}
+
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Path != locations[j].Path {
+			return locations[i].Path < locations[j].Path
+		}
+		return locations[i].StartLine < locations[j].StartLine
+	})
+
+	return locations
+}
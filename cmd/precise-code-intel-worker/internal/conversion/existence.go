@@ -0,0 +1,94 @@
+package conversion
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxDirectoriesPerRequest is the maximum number of directories listed by a single
+// request to gitserver.
+const maxDirectoriesPerRequest = 100
+
+// DirectoryChildrenFunc returns a map from each of the given repo-root-relative
+// directories to its children.
+type DirectoryChildrenFunc func(ctx context.Context, dirnames []string) (map[string][]string, error)
+
+// PathExistenceChecker determines whether or not a document path within an LSIF upload
+// should be visible within the generated dump. This allows us to prune documents which
+// are not inside of the root (which will never be queried from within this dump), and
+// references to paths that do not occur in the git tree at this commit.
+type PathExistenceChecker struct {
+	root  string
+	paths map[string]struct{}
+}
+
+// NewPathExistenceChecker creates a PathExistenceChecker for the given dump root-relative
+// document paths. The contents of the parent directory of each path are requested from
+// gitserver up-front so that the cost of these requests is paid in one place. If the
+// directoryChildren function is nil, every path is assumed to be known by git.
+func NewPathExistenceChecker(ctx context.Context, root string, documentPaths []string, directoryChildren DirectoryChildrenFunc) (*PathExistenceChecker, error) {
+	checker := &PathExistenceChecker{root: root}
+	if directoryChildren == nil {
+		return checker, nil
+	}
+
+	dirnameSet := map[string]struct{}{}
+	for _, documentPath := range documentPaths {
+		dirnameSet[dirnameWithoutDot(path.Join(root, documentPath))] = struct{}{}
+	}
+
+	dirnames := make([]string, 0, len(dirnameSet))
+	for dirname := range dirnameSet {
+		// Paths that escape the repository can never exist in git
+		if !strings.HasPrefix(dirname, "..") {
+			dirnames = append(dirnames, dirname)
+		}
+	}
+	sort.Strings(dirnames)
+
+	checker.paths = map[string]struct{}{}
+	for len(dirnames) > 0 {
+		batch := dirnames
+		if len(batch) > maxDirectoriesPerRequest {
+			batch = batch[:maxDirectoriesPerRequest]
+		}
+		dirnames = dirnames[len(batch):]
+
+		childMap, err := directoryChildren(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, children := range childMap {
+			for _, child := range children {
+				checker.paths[child] = struct{}{}
+			}
+		}
+	}
+
+	return checker, nil
+}
+
+// ShouldIncludePath determines if the given dump root-relative path should be included
+// in the generated dump. If requireDocumentDump is true, the path must also be within
+// the dump root.
+func (c *PathExistenceChecker) ShouldIncludePath(documentPath string, requireDocumentDump bool) bool {
+	if c.paths != nil {
+		if _, ok := c.paths[path.Join(c.root, documentPath)]; !ok {
+			return false
+		}
+	}
+
+	return !requireDocumentDump || !strings.HasPrefix(documentPath, "..")
+}
+
+// dirnameWithoutDot returns the dirname of the given path, or the empty string if the
+// path denotes a file in the current directory.
+func dirnameWithoutDot(pathname string) string {
+	if dirname := path.Dir(pathname); dirname != "." {
+		return dirname
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package correlation
+
+import (
+	"io"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// Correlate reads the (uncompressed) LSIF dump from the given reader and returns its
+// canonicalized correlation state.
+func Correlate(r io.Reader, dumpRoot string) (*State, error) {
+	correlator := NewCorrelator(dumpRoot)
+	if err := ReadElements(r, correlator.Insert); err != nil {
+		return nil, err
+	}
+
+	state := correlator.State()
+	if state.ProjectRoot == nil {
+		return nil, errNoMetadata
+	}
+
+	Canonicalize(state)
+	return state, nil
+}
+
+// Canonicalize merges documents that share a path, collapses linked reference
+// results into a single canonical reference result, and flattens the results of
+// result sets into the ranges that can reach them. After canonicalization, the
+// next edges of the state can be ignored.
+func Canonicalize(state *State) {
+	// Determine if multiple documents are defined with the same URI. This happens in
+	// some indexers (such as lsif-tsc) that index dependent projects into the same
+	// dump as the target project. For each set of documents that share a path, we
+	// choose one document to be the canonical representative and merge the contains,
+	// definition, reference, and implementation data into the unique canonical document.
+	mergeDocuments(state)
+
+	// Determine which reference results are linked together. Determine a canonical
+	// reference result for each set so that we can remap all identifiers to the
+	// chosen one.
+	canonicalReferenceResultIDs := canonicalizeReferenceResults(state)
+
+	// Collapse result sets data into the ranges that can reach them.
+	for rangeID, r := range state.RangeData {
+		canonicalizeItem(state, canonicalReferenceResultIDs, rangeID, &r.ResultSetData)
+		state.RangeData[rangeID] = r
+	}
+}
+
+// mergeDocuments moves the contains, definition, reference, implementation, and diagnostic data keyed
+// by a document with a duplicate path into the canonical document with that path. The
+// canonical document of a path is the one with the smallest identifier.
+func mergeDocuments(state *State) {
+	documentIDs := make([]types.ID, 0, len(state.DocumentPaths))
+	for id := range state.DocumentPaths {
+		documentIDs = append(documentIDs, id)
+	}
+	types.SortIDs(documentIDs)
+
+	canonicalIDs := map[string]types.ID{}
+	for _, id := range documentIDs {
+		path := state.DocumentPaths[id]
+
+		canonicalID, ok := canonicalIDs[path]
+		if !ok {
+			canonicalIDs[path] = id
+			continue
+		}
+
+		for rangeID := range state.ContainsData[id] {
+			state.ContainsData[canonicalID].Add(rangeID)
+		}
+		delete(state.ContainsData, id)
+
+		mergeDefinitionReferences(id, canonicalID, state.DefinitionData)
+		mergeDefinitionReferences(id, canonicalID, state.ReferenceData)
+		mergeDefinitionReferences(id, canonicalID, state.ImplementationData)
+
+		if diagnosticResultIDs, ok := state.DocumentDiagnostics[id]; ok {
+			state.DocumentDiagnostics[canonicalID] = append(state.DocumentDiagnostics[canonicalID], diagnosticResultIDs...)
+			delete(state.DocumentDiagnostics, id)
+		}
+
+		// Discard the document data as a flag to prevent inserting one
+		// of the documents subsumed by the canonical representative.
+		delete(state.DocumentPaths, id)
+	}
+}
+
+// mergeDefinitionReferences moves the definition, reference, or implementation data for document `id`
+// into the data of document `canonicalID`.
+func mergeDefinitionReferences(id, canonicalID types.ID, data map[types.ID]map[types.ID][]types.ID) {
+	for _, documentMap := range data {
+		if rangeIDs, ok := documentMap[id]; ok {
+			documentMap[canonicalID] = append(documentMap[canonicalID], rangeIDs...)
+			delete(documentMap, id)
+		}
+	}
+}
+
+// canonicalizeReferenceResults determines which reference results are linked via item
+// edges and chooses a canonical reference result from each batch. All data is merged into
+// the canonical result and all non-canonical results are removed from the state (unlinked
+// results are left alone). Returns a map from reference result identifier to the identifier
+// of the canonical result.
+func canonicalizeReferenceResults(state *State) map[types.ID]types.ID {
+	canonicalIDs := map[types.ID]types.ID{}
+
+	for referenceResultID := range state.LinkedReferenceResults {
+		// Don't re-process the same set of linked reference results
+		if _, ok := canonicalIDs[referenceResultID]; ok {
+			continue
+		}
+
+		// Find all reachable items and order them deterministically
+		linkedIDs := state.LinkedReferenceResults.extractSet(referenceResultID).Keys()
+
+		// Choose arbitrary canonical id
+		canonicalID := linkedIDs[0]
+		canonicalReferenceResult := state.ReferenceData[canonicalID]
+
+		for _, linkedID := range linkedIDs {
+			// Link each id to its canonical representation. We do this for
+			// the `linkedID == canonicalID` case so we can reliably detect
+			// duplication at the start of this loop.
+			canonicalIDs[linkedID] = canonicalID
+
+			if linkedID != canonicalID {
+				// If it's a different identifier, then normalize all data from the linked result
+				// set into the canonical one.
+				for documentID, rangeIDs := range state.ReferenceData[linkedID] {
+					canonicalReferenceResult[documentID] = append(canonicalReferenceResult[documentID], rangeIDs...)
+				}
+			}
+		}
+	}
+
+	// Remove all non-canonical but linked result sets
+	for id, canonicalID := range canonicalIDs {
+		if id != canonicalID {
+			delete(state.ReferenceData, id)
+		}
+	}
+
+	return canonicalIDs
+}
+
+// canonicalizeItem flattens the definition result, reference result, implementation result,
+// hover result, and monikers of a range or result set by following next edges in the graph.
+func canonicalizeItem(state *State, canonicalReferenceResultIDs map[types.ID]types.ID, id types.ID, item *types.ResultSetData) {
+	monikers := types.IDSet{}
+	if len(item.MonikerIDs) > 0 {
+		// Find arbitrary moniker attached to item and get all monikers reachable from it
+		for monikerID := range state.LinkedMonikers.extractSet(item.MonikerIDs.Keys()[0]) {
+			if state.MonikerData[monikerID].Kind != "local" {
+				monikers.Add(monikerID)
+			}
+		}
+	}
+
+	if nextID, ok := state.NextData[id]; ok {
+		// If we have a next edge to a result set, get it and canonicalize it first. This
+		// will recursively look at any result that that it can reach that hasn't yet been
+		// canonicalized.
+		nextItem := state.ResultSetData[nextID]
+		canonicalizeItem(state, canonicalReferenceResultIDs, nextID, &nextItem)
+		state.ResultSetData[nextID] = nextItem
+
+		// Add each moniker of the next set to this item
+		for monikerID := range nextItem.MonikerIDs {
+			monikers.Add(monikerID)
+		}
+
+		// If we do not have a definition, reference, implementation, or hover result,
+		// take the result value from the next item.
+
+		if item.DefinitionResultID == "" {
+			item.DefinitionResultID = nextItem.DefinitionResultID
+		}
+		if item.ReferenceResultID == "" {
+			item.ReferenceResultID = nextItem.ReferenceResultID
+		}
+		if item.ImplementationResultID == "" {
+			item.ImplementationResultID = nextItem.ImplementationResultID
+		}
+		if item.HoverResultID == "" {
+			item.HoverResultID = nextItem.HoverResultID
+		}
+	}
+
+	if canonicalID, ok := canonicalReferenceResultIDs[item.ReferenceResultID]; ok {
+		// If there is a canonical version of this reference result, use that instead
+		item.ReferenceResultID = canonicalID
+	}
+
+	// Update our moniker sets (our normalized sets and any monikers of our next item)
+	item.MonikerIDs = monikers
+
+	// Remove the next edge so we don't traverse it a second time
+	delete(state.NextData, id)
+}
@@ -0,0 +1,559 @@
+// Package correlation reads the vertices and edges of an LSIF dump into an in-memory
+// adjacency-list structure that is later flattened into the tables of a SQLite bundle.
+package correlation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/inconshreveable/log15"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// errNoMetadata occurs when a dump does not begin with a metadata vertex.
+var errNoMetadata = errors.New("no metadata defined")
+
+// State is the result of correlating the elements of a single LSIF dump.
+type State struct {
+	// LSIFVersion is the LSIF version of the input, extracted from the metadata vertex.
+	LSIFVersion string
+
+	// ProjectRoot is the root of all document URIs, extracted from the metadata vertex.
+	ProjectRoot *url.URL
+
+	// Vertex data
+	DocumentPaths          map[types.ID]string
+	RangeData              map[types.ID]types.RangeData
+	ResultSetData          map[types.ID]types.ResultSetData
+	HoverData              map[types.ID]string
+	MonikerData            map[types.ID]types.MonikerData
+	PackageInformationData map[types.ID]types.PackageInformationData
+	DiagnosticData         map[types.ID][]types.DiagnosticData
+	UnsupportedVertexes    types.IDSet
+
+	// Edge data
+	NextData            map[types.ID]types.ID
+	ContainsData        map[types.ID]types.IDSet
+	DefinitionData      map[types.ID]map[types.ID][]types.ID
+	ReferenceData       map[types.ID]map[types.ID][]types.ID
+	ImplementationData  map[types.ID]map[types.ID][]types.ID
+	DocumentDiagnostics map[types.ID][]types.ID
+
+	// LinkedMonikers is a disjoint set of monikers linked by nextMoniker edges.
+	LinkedMonikers disjointIDSet
+
+	// LinkedReferenceResults is a disjoint set of reference results linked by item edges.
+	LinkedReferenceResults disjointIDSet
+
+	// ImportedMonikers is the set of imported moniker identifiers with package information attached.
+	ImportedMonikers types.IDSet
+
+	// ExportedMonikers is the set of exported moniker identifiers with package information attached.
+	ExportedMonikers types.IDSet
+}
+
+// newState creates an empty correlation state.
+func newState() *State {
+	return &State{
+		DocumentPaths:          map[types.ID]string{},
+		RangeData:              map[types.ID]types.RangeData{},
+		ResultSetData:          map[types.ID]types.ResultSetData{},
+		HoverData:              map[types.ID]string{},
+		MonikerData:            map[types.ID]types.MonikerData{},
+		PackageInformationData: map[types.ID]types.PackageInformationData{},
+		DiagnosticData:         map[types.ID][]types.DiagnosticData{},
+		UnsupportedVertexes:    types.IDSet{},
+		NextData:               map[types.ID]types.ID{},
+		ContainsData:           map[types.ID]types.IDSet{},
+		DefinitionData:         map[types.ID]map[types.ID][]types.ID{},
+		ReferenceData:          map[types.ID]map[types.ID][]types.ID{},
+		ImplementationData:     map[types.ID]map[types.ID][]types.ID{},
+		DocumentDiagnostics:    map[types.ID][]types.ID{},
+		LinkedMonikers:         disjointIDSet{},
+		LinkedReferenceResults: disjointIDSet{},
+		ImportedMonikers:       types.IDSet{},
+		ExportedMonikers:       types.IDSet{},
+	}
+}
+
+// Correlator receives the vertices and edges of an LSIF dump one at a time and adds
+// them to its correlation state.
+type Correlator struct {
+	dumpRoot string
+	state    *State
+}
+
+// NewCorrelator creates a new correlator for a dump with the given repository-relative root.
+func NewCorrelator(dumpRoot string) *Correlator {
+	return &Correlator{dumpRoot: dumpRoot, state: newState()}
+}
+
+// State returns the correlation state after all elements have been inserted.
+func (c *Correlator) State() *State {
+	return c.state
+}
+
+// Insert processes a single vertex or edge.
+func (c *Correlator) Insert(element Element) error {
+	switch element.Type {
+	case "vertex":
+		return c.insertVertex(element)
+	case "edge":
+		return c.insertEdge(element)
+	}
+
+	return nil
+}
+
+func (c *Correlator) insertVertex(element Element) error {
+	switch element.Label {
+	case "metaData":
+		return c.handleMetaData(element)
+
+	case "document":
+		if c.state.ProjectRoot == nil {
+			return errNoMetadata
+		}
+
+		documentURI, err := parseURL(element.URI)
+		if err != nil {
+			return err
+		}
+
+		c.state.DocumentPaths[element.ID] = relativePath(c.state.ProjectRoot, documentURI)
+		c.state.ContainsData[element.ID] = types.IDSet{}
+
+	// The remaining vertex handlers stash data into an appropriate map. This data
+	// may be retrieved when an edge that references it is seen, or when a document
+	// is finalized.
+
+	case "range":
+		c.state.RangeData[element.ID] = types.RangeData{
+			StartLine:      element.Start.Line,
+			StartCharacter: element.Start.Character,
+			EndLine:        element.End.Line,
+			EndCharacter:   element.End.Character,
+			ResultSetData:  types.ResultSetData{MonikerIDs: types.IDSet{}},
+			Tag:            normalizeTag(element.Tag),
+		}
+
+	case "resultSet":
+		c.state.ResultSetData[element.ID] = types.ResultSetData{MonikerIDs: types.IDSet{}}
+
+	case "definitionResult":
+		c.state.DefinitionData[element.ID] = map[types.ID][]types.ID{}
+
+	case "referenceResult":
+		c.state.ReferenceData[element.ID] = map[types.ID][]types.ID{}
+
+	case "implementationResult":
+		c.state.ImplementationData[element.ID] = map[types.ID][]types.ID{}
+
+	case "hoverResult":
+		hover, err := normalizeHover(element.Result)
+		if err != nil {
+			return fmt.Errorf("malformed hover result %s: %s", element.ID, err)
+		}
+		c.state.HoverData[element.ID] = hover
+
+	case "moniker":
+		kind := element.Kind
+		if kind == "" {
+			kind = "local"
+		}
+
+		c.state.MonikerData[element.ID] = types.MonikerData{
+			Kind:       kind,
+			Scheme:     element.Scheme,
+			Identifier: element.Identifier,
+		}
+
+	case "packageInformation":
+		var version *string
+		if element.Version != nil && *element.Version != "" {
+			version = element.Version
+		}
+
+		c.state.PackageInformationData[element.ID] = types.PackageInformationData{
+			Name:    element.Name,
+			Version: version,
+		}
+
+	case "diagnosticResult":
+		diagnostics, err := normalizeDiagnostics(element.Result)
+		if err != nil {
+			return fmt.Errorf("malformed diagnostic result %s: %s", element.ID, err)
+		}
+		c.state.DiagnosticData[element.ID] = diagnostics
+
+	default:
+		// Some vertex labels are not yet supported (e.g. typeDefinitionResult). We
+		// keep track of these unsupported vertexes so that we don't mistake it for a
+		// missing vertex later when visiting edges.
+		c.state.UnsupportedVertexes.Add(element.ID)
+	}
+
+	return nil
+}
+
+func (c *Correlator) insertEdge(element Element) error {
+	switch element.Label {
+	case "contains":
+		return c.handleContains(element)
+	case "next":
+		return c.handleNextEdge(element)
+	case "item":
+		return c.handleItemEdge(element)
+	case "textDocument/definition":
+		return c.handleDefinitionEdge(element)
+	case "textDocument/references":
+		return c.handleReferenceEdge(element)
+	case "textDocument/implementation":
+		return c.handleImplementationEdge(element)
+	case "textDocument/hover":
+		return c.handleHoverEdge(element)
+	case "moniker":
+		return c.handleMonikerEdge(element)
+	case "nextMoniker":
+		return c.handleNextMonikerEdge(element)
+	case "packageInformation":
+		return c.handlePackageInformationEdge(element)
+	case "textDocument/diagnostic":
+		return c.handleDiagnosticEdge(element)
+	}
+
+	return nil
+}
+
+//
+// Vertex Handlers
+
+// handleMetaData extracts the project root so we can create relative paths for
+// documents and caches the LSIF protocol version that we will later insert into the
+// metadata table. This should be the first vertex seen.
+func (c *Correlator) handleMetaData(element Element) error {
+	projectRoot := element.ProjectRoot
+	if !strings.HasSuffix(projectRoot, "/") {
+		projectRoot += "/"
+	}
+
+	root, err := parseURL(projectRoot)
+	if err != nil {
+		return err
+	}
+
+	// We assume that the project root in the LSIF dump is either:
+	//
+	//   (1) the root of the LSIF dump, or
+	//   (2) the root of the repository
+	//
+	// These are the common cases and we don't explicitly support
+	// anything else. Here we normalize to (1) by appending the dump
+	// root if it's not already suffixed by it.
+
+	if c.dumpRoot != "" && !strings.HasSuffix(root.String(), c.dumpRoot) {
+		root = root.ResolveReference(&url.URL{Path: c.dumpRoot})
+	}
+
+	if element.Version != nil {
+		c.state.LSIFVersion = *element.Version
+	}
+	c.state.ProjectRoot = root
+	return nil
+}
+
+//
+// Edge Handlers
+
+// handleContains adds range data ids into the document in which they are contained.
+func (c *Correlator) handleContains(edge Element) error {
+	// Do not track project contains
+	set, ok := c.state.ContainsData[edge.OutV]
+	if !ok {
+		return nil
+	}
+
+	for _, inV := range edge.InVs {
+		if _, ok := c.state.RangeData[inV]; !ok {
+			return malformedDump(edge, inV, "range")
+		}
+		set.Add(inV)
+	}
+
+	return nil
+}
+
+// handleItemEdge updates definition, reference, and implementation fields from an item edge.
+func (c *Correlator) handleItemEdge(edge Element) error {
+	if documentMap, ok := c.state.DefinitionData[edge.OutV]; ok {
+		for _, inV := range edge.InVs {
+			if _, ok := c.state.RangeData[inV]; !ok {
+				return malformedDump(edge, inV, "range")
+			}
+			documentMap[edge.Document] = append(documentMap[edge.Document], inV)
+		}
+
+		return nil
+	}
+
+	if documentMap, ok := c.state.ReferenceData[edge.OutV]; ok {
+		for _, inV := range edge.InVs {
+			if _, ok := c.state.ReferenceData[inV]; ok {
+				c.state.LinkedReferenceResults.union(edge.OutV, inV)
+				continue
+			}
+
+			if _, ok := c.state.RangeData[inV]; !ok {
+				return malformedDump(edge, inV, "range")
+			}
+			documentMap[edge.Document] = append(documentMap[edge.Document], inV)
+		}
+
+		return nil
+	}
+
+	if documentMap, ok := c.state.ImplementationData[edge.OutV]; ok {
+		for _, inV := range edge.InVs {
+			if _, ok := c.state.RangeData[inV]; !ok {
+				return malformedDump(edge, inV, "range")
+			}
+			documentMap[edge.Document] = append(documentMap[edge.Document], inV)
+		}
+
+		return nil
+	}
+
+	if c.state.UnsupportedVertexes.Contains(edge.OutV) {
+		log15.Debug("Skipping edge from an unsupported vertex", "outV", edge.OutV.String())
+		return nil
+	}
+
+	return malformedDump(edge, edge.OutV, "definitionResult/referenceResult/implementationResult")
+}
+
+// handleMonikerEdge attaches the specified moniker to the specified range or result set.
+func (c *Correlator) handleMonikerEdge(edge Element) error {
+	if _, ok := c.state.MonikerData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "moniker")
+	}
+
+	return c.updateItem(edge, func(item *types.ResultSetData) {
+		item.MonikerIDs = types.NewIDSet(edge.InV)
+	})
+}
+
+// handleNextEdge sets the next field of the specified range or result set.
+func (c *Correlator) handleNextEdge(edge Element) error {
+	if _, ok := c.state.ResultSetData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "resultSet")
+	}
+
+	if err := c.updateItem(edge, func(item *types.ResultSetData) {}); err != nil {
+		return err
+	}
+
+	c.state.NextData[edge.OutV] = edge.InV
+	return nil
+}
+
+// handleNextMonikerEdge correlates monikers together so that when one moniker is
+// queried, each correlated moniker is also returned as a strongly connected set.
+func (c *Correlator) handleNextMonikerEdge(edge Element) error {
+	if _, ok := c.state.MonikerData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "moniker")
+	}
+	if _, ok := c.state.MonikerData[edge.OutV]; !ok {
+		return malformedDump(edge, edge.OutV, "moniker")
+	}
+
+	c.state.LinkedMonikers.union(edge.InV, edge.OutV)
+	return nil
+}
+
+// handlePackageInformationEdge sets the package information of the specified moniker.
+// If the moniker is an export moniker, then the package information will also be
+// returned as an exported package.
+func (c *Correlator) handlePackageInformationEdge(edge Element) error {
+	source, ok := c.state.MonikerData[edge.OutV]
+	if !ok {
+		return malformedDump(edge, edge.OutV, "moniker")
+	}
+	if _, ok := c.state.PackageInformationData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "packageInformation")
+	}
+
+	source.PackageInformationID = edge.InV
+	c.state.MonikerData[edge.OutV] = source
+
+	switch source.Kind {
+	case "export":
+		c.state.ExportedMonikers.Add(edge.OutV)
+	case "import":
+		c.state.ImportedMonikers.Add(edge.OutV)
+	}
+
+	return nil
+}
+
+// handleDefinitionEdge sets the definition result of the specified range or result set.
+func (c *Correlator) handleDefinitionEdge(edge Element) error {
+	if _, ok := c.state.DefinitionData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "definitionResult")
+	}
+
+	return c.updateItem(edge, func(item *types.ResultSetData) {
+		item.DefinitionResultID = edge.InV
+	})
+}
+
+// handleReferenceEdge sets the reference result of the specified range or result set.
+func (c *Correlator) handleReferenceEdge(edge Element) error {
+	if _, ok := c.state.ReferenceData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "referenceResult")
+	}
+
+	return c.updateItem(edge, func(item *types.ResultSetData) {
+		item.ReferenceResultID = edge.InV
+	})
+}
+
+// handleImplementationEdge sets the implementation result of the specified range or result set.
+func (c *Correlator) handleImplementationEdge(edge Element) error {
+	if _, ok := c.state.ImplementationData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "implementationResult")
+	}
+
+	return c.updateItem(edge, func(item *types.ResultSetData) {
+		item.ImplementationResultID = edge.InV
+	})
+}
+
+// handleHoverEdge sets the hover result of the specified range or result set.
+func (c *Correlator) handleHoverEdge(edge Element) error {
+	if _, ok := c.state.HoverData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "hoverResult")
+	}
+
+	return c.updateItem(edge, func(item *types.ResultSetData) {
+		item.HoverResultID = edge.InV
+	})
+}
+
+// handleDiagnosticEdge attaches the diagnostic result to the specified document.
+// Diagnostics attached to a project are not associated with a file and are skipped.
+func (c *Correlator) handleDiagnosticEdge(edge Element) error {
+	if _, ok := c.state.DocumentPaths[edge.OutV]; !ok {
+		log15.Debug("Skipping diagnostics not attached to a document", "outV", edge.OutV.String())
+		return nil
+	}
+
+	if _, ok := c.state.DiagnosticData[edge.InV]; !ok {
+		return malformedDump(edge, edge.InV, "diagnosticResult")
+	}
+
+	c.state.DocumentDiagnostics[edge.OutV] = append(c.state.DocumentDiagnostics[edge.OutV], edge.InV)
+	return nil
+}
+
+// updateItem applies the given function to the range or result set that is the
+// source of the given edge.
+func (c *Correlator) updateItem(edge Element, fn func(item *types.ResultSetData)) error {
+	if r, ok := c.state.RangeData[edge.OutV]; ok {
+		fn(&r.ResultSetData)
+		c.state.RangeData[edge.OutV] = r
+		return nil
+	}
+
+	if rs, ok := c.state.ResultSetData[edge.OutV]; ok {
+		fn(&rs)
+		c.state.ResultSetData[edge.OutV] = rs
+		return nil
+	}
+
+	return malformedDump(edge, edge.OutV, "range/resultSet")
+}
+
+// malformedDump returns an error describing an edge that references a missing vertex.
+func malformedDump(edge Element, id types.ID, kinds string) error {
+	return fmt.Errorf("edge %s (%s) references a nonexistent %s vertex %s", edge.ID.String(), edge.Label, kinds, id.String())
+}
+
+// normalizeTag extracts the symbol data from a range tag. Only declaration and
+// definition tags describe a symbol; other tags are discarded.
+func normalizeTag(tag *rangeTag) *types.SymbolTagData {
+	if tag == nil || (tag.Type != "declaration" && tag.Type != "definition") {
+		return nil
+	}
+
+	return &types.SymbolTagData{Text: tag.Text, Kind: tag.Kind, FullRange: tag.FullRange}
+}
+
+// normalizeDiagnostics converts the raw result of a diagnosticResult vertex.
+func normalizeDiagnostics(result json.RawMessage) ([]types.DiagnosticData, error) {
+	var payload []diagnostic
+	if err := json.Unmarshal(result, &payload); err != nil {
+		return nil, err
+	}
+
+	diagnostics := make([]types.DiagnosticData, 0, len(payload))
+	for _, d := range payload {
+		var code *string
+		if len(d.Code) > 0 && string(d.Code) != "null" {
+			s := types.ID(d.Code).String()
+			code = &s
+		}
+
+		diagnostics = append(diagnostics, types.DiagnosticData{
+			Severity:       d.Severity,
+			Code:           code,
+			Message:        d.Message,
+			Source:         d.Source,
+			StartLine:      d.Range.Start.Line,
+			StartCharacter: d.Range.Start.Character,
+			EndLine:        d.Range.End.Line,
+			EndCharacter:   d.Range.End.Character,
+		})
+	}
+
+	return diagnostics, nil
+}
+
+// parseURL parses the given URL after removing the characters that are ignored by
+// the WHATWG URL parser, which is used by the TypeScript worker. Some indexers emit
+// URIs with a trailing newline.
+func parseURL(rawurl string) (*url.URL, error) {
+	rawurl = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, rawurl)
+
+	return url.Parse(strings.TrimSpace(rawurl))
+}
+
+// relativePath constructs a root-relative path within a dump.
+func relativePath(projectRoot, documentURI *url.URL) string {
+	rootPath := projectRoot.Path
+	if !strings.HasSuffix(rootPath, "/") {
+		rootPath += "/"
+	}
+
+	if strings.HasPrefix(documentURI.Path, rootPath) {
+		return strings.TrimPrefix(documentURI.Path, rootPath)
+	}
+
+	// Walk up from the project root until we find a common ancestor
+	prefix := ""
+	dir := strings.TrimSuffix(rootPath, "/")
+	for dir != "" && dir != "/" && !strings.HasPrefix(documentURI.Path, dir+"/") {
+		dir = path.Dir(dir)
+		prefix += "../"
+	}
+
+	return prefix + strings.TrimPrefix(documentURI.Path, strings.TrimSuffix(dir, "/")+"/")
+}
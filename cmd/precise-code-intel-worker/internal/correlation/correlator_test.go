@@ -0,0 +1,50 @@
+package correlation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+func TestCorrelateImplementationResults(t *testing.T) {
+	dump := strings.Join([]string{
+		`{"id": 1, "type": "vertex", "label": "metaData", "version": "0.4.3", "projectRoot": "file:///test/root"}`,
+		`{"id": 2, "type": "vertex", "label": "document", "uri": "file:///test/root/foo.go"}`,
+		`{"id": 3, "type": "vertex", "label": "range", "start": {"line": 1, "character": 5}, "end": {"line": 1, "character": 8}}`,
+		`{"id": 4, "type": "vertex", "label": "range", "start": {"line": 4, "character": 5}, "end": {"line": 4, "character": 8}}`,
+		`{"id": 5, "type": "vertex", "label": "resultSet"}`,
+		`{"id": 6, "type": "vertex", "label": "implementationResult"}`,
+		`{"id": 7, "type": "edge", "label": "contains", "outV": 2, "inVs": [3, 4]}`,
+		`{"id": 8, "type": "edge", "label": "next", "outV": 3, "inV": 5}`,
+		`{"id": 9, "type": "edge", "label": "textDocument/implementation", "outV": 5, "inV": 6}`,
+		`{"id": 10, "type": "edge", "label": "item", "outV": 6, "inVs": [4], "document": 2}`,
+	}, "\n")
+
+	state, err := Correlate(strings.NewReader(dump), "")
+	if err != nil {
+		t.Fatalf("unexpected error correlating dump: %s", err)
+	}
+
+	if implementationResultID := state.RangeData["3"].ImplementationResultID; implementationResultID != "6" {
+		t.Errorf("unexpected implementation result of range. want=%q have=%q", "6", implementationResultID)
+	}
+
+	expectedImplementations := map[types.ID][]types.ID{"2": {"4"}}
+	if implementations := state.ImplementationData["6"]; !reflect.DeepEqual(implementations, expectedImplementations) {
+		t.Errorf("unexpected implementations. want=%v have=%v", expectedImplementations, implementations)
+	}
+}
+
+func TestCorrelateImplementationResultMissing(t *testing.T) {
+	dump := strings.Join([]string{
+		`{"id": 1, "type": "vertex", "label": "metaData", "version": "0.4.3", "projectRoot": "file:///test/root"}`,
+		`{"id": 2, "type": "vertex", "label": "range", "start": {"line": 1, "character": 5}, "end": {"line": 1, "character": 8}}`,
+		`{"id": 3, "type": "edge", "label": "textDocument/implementation", "outV": 2, "inV": 4}`,
+	}, "\n")
+
+	if _, err := Correlate(strings.NewReader(dump), ""); err == nil {
+		t.Fatalf("expected error correlating dump with a missing implementation result")
+	}
+}
@@ -0,0 +1,41 @@
+package correlation
+
+import "github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+
+// disjointIDSet is a set of identifiers partitioned into linked groups. Unlike a
+// union-find structure, the full group of an identifier can be enumerated.
+type disjointIDSet map[types.ID]types.IDSet
+
+// union links the two identifiers.
+func (s disjointIDSet) union(id1, id2 types.ID) {
+	s.link(id1, id2)
+	s.link(id2, id1)
+}
+
+func (s disjointIDSet) link(from, to types.ID) {
+	if _, ok := s[from]; !ok {
+		s[from] = types.IDSet{}
+	}
+	s[from].Add(to)
+}
+
+// extractSet returns the set of identifiers reachable from the given identifier,
+// including the identifier itself.
+func (s disjointIDSet) extractSet(id types.ID) types.IDSet {
+	set := types.NewIDSet(id)
+	frontier := []types.ID{id}
+
+	for len(frontier) > 0 {
+		v := frontier[0]
+		frontier = frontier[1:]
+
+		for w := range s[v] {
+			if !set.Contains(w) {
+				set.Add(w)
+				frontier = append(frontier, w)
+			}
+		}
+	}
+
+	return set
+}
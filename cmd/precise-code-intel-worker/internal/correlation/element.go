@@ -0,0 +1,92 @@
+package correlation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// Element is a vertex or an edge of an LSIF dump. Only the fields read during
+// correlation are decoded; the fields that are set depend on the element's label.
+type Element struct {
+	ID    types.ID `json:"id"`
+	Type  string   `json:"type"`
+	Label string   `json:"label"`
+
+	// metaData and packageInformation vertices
+	Version     *string `json:"version"`
+	ProjectRoot string  `json:"projectRoot"`
+
+	// document vertices
+	URI string `json:"uri"`
+
+	// range vertices
+	Start types.Position `json:"start"`
+	End   types.Position `json:"end"`
+	Tag   *rangeTag      `json:"tag"`
+
+	// hoverResult and diagnosticResult vertices
+	Result json.RawMessage `json:"result"`
+
+	// moniker vertices
+	Kind       string `json:"kind"`
+	Scheme     string `json:"scheme"`
+	Identifier string `json:"identifier"`
+
+	// packageInformation vertices
+	Name string `json:"name"`
+
+	// edges
+	OutV     types.ID   `json:"outV"`
+	InV      types.ID   `json:"inV"`
+	InVs     []types.ID `json:"inVs"`
+	Document types.ID   `json:"document"`
+}
+
+// rangeTag is the optional tag of a range vertex.
+type rangeTag struct {
+	Type      string      `json:"type"`
+	Text      string      `json:"text"`
+	Kind      int         `json:"kind"`
+	FullRange types.Range `json:"fullRange"`
+}
+
+// diagnostic is a single LSP diagnostic of a diagnosticResult vertex.
+type diagnostic struct {
+	Range    types.Range     `json:"range"`
+	Severity *int            `json:"severity"`
+	Code     json.RawMessage `json:"code"`
+	Source   *string         `json:"source"`
+	Message  string          `json:"message"`
+}
+
+// ReadElements decodes each non-empty line of the given (uncompressed) input as a
+// vertex or edge and invokes fn with the result.
+func ReadElements(r io.Reader, fn func(element Element) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	index := 0
+	for scanner.Scan() {
+		index++
+
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var element Element
+		if err := json.Unmarshal(line, &element); err != nil {
+			return fmt.Errorf("failed to process line #%d (%q): %s", index, line, err)
+		}
+
+		if err := fn(element); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,63 @@
+package correlation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// hoverSeparator separates the individual parts of a normalized hover result.
+const hoverSeparator = "\n\n---\n\n"
+
+// normalizeHover converts the raw result of an LSP hover object into a string.
+func normalizeHover(result json.RawMessage) (string, error) {
+	var hover struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := json.Unmarshal(result, &hover); err != nil {
+		return "", err
+	}
+
+	var contents []json.RawMessage
+	if err := json.Unmarshal(hover.Contents, &contents); err != nil {
+		contents = []json.RawMessage{hover.Contents}
+	}
+
+	var parts []string
+	for _, content := range contents {
+		part, err := normalizeHoverContent(content)
+		if err != nil {
+			return "", err
+		}
+
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, hoverSeparator), nil
+}
+
+// normalizeHoverContent converts a single string, MarkupContent, or MarkedString
+// value into a string.
+func normalizeHoverContent(content json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(content, &s); err == nil {
+		return s, nil
+	}
+
+	var payload struct {
+		Kind     *string `json:"kind"`
+		Language string  `json:"language"`
+		Value    string  `json:"value"`
+	}
+	if err := json.Unmarshal(content, &payload); err != nil {
+		return "", err
+	}
+
+	if payload.Kind != nil {
+		return payload.Value, nil
+	}
+
+	return fmt.Sprintf("```%s\n%s\n```", payload.Language, payload.Value), nil
+}
@@ -0,0 +1,38 @@
+// Package db moves uploads through the conversion lifecycle in Postgres: it dequeues queued
+// uploads, records the heartbeats and progress of their conversion, and marks them as
+// completed or errored.
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/keegancsmith/sqlf"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Exec runs the given query and discards its result.
+func Exec(ctx context.Context, db Execer, q *sqlf.Query) error {
+	_, err := db.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+// Done commits the given transaction if err is nil and rolls it back otherwise. Returns err
+// combined with any error that occurs while the transaction is closed.
+func Done(tx *sql.Tx, err error) error {
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			err = multierror.Append(err, rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/pkg/errors"
+)
+
+// Upload is the subset of an lsif_uploads row required for conversion.
+type Upload struct {
+	ID           int
+	RepositoryID int
+	Commit       string
+	Root         string
+	Indexer      string
+
+	// Checksum is the hex-encoded SHA-256 digest of the raw upload. Empty if the
+	// digest was not recorded when the upload was received.
+	Checksum string
+
+	// TracingContext is the JSON-encoded span context of the request that enqueued
+	// the upload.
+	TracingContext string
+}
+
+// ErrNotProcessing occurs when an upload leaves the processing state (e.g. it is soft
+// deleted) while it is being converted.
+var ErrNotProcessing = errors.New("upload is no longer processing")
+
+// Dequeue selects the oldest queued upload, moves it into the processing state on behalf of
+// the given worker, and locks it in a new transaction. Locked rows are skipped as they are
+// being handled by another worker process. Only JSON lines uploads are selected, as SCIP
+// uploads are converted by the TypeScript worker.
+//
+// The state transition is committed before the transaction is opened so that it is visible
+// to the API while the upload is converted. The returned transaction holds a key share lock
+// on the upload, which conflicts with the locks taken by the janitor and by requests that
+// delete or requeue the upload, but not with updates of non-key columns, so that heartbeats
+// and progress can be recorded outside of it. The caller must close the transaction with
+// Done. An upload that is deleted or leaves the processing state before it is locked is
+// skipped. Returns false if there are no queued uploads.
+func Dequeue(ctx context.Context, db *sql.DB, workerID string) (Upload, *sql.Tx, bool, error) {
+	for {
+		id, ok, err := markProcessing(ctx, db, workerID)
+		if err != nil || !ok {
+			return Upload{}, nil, false, err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return Upload{}, nil, false, err
+		}
+
+		upload, ok, err := lockUpload(ctx, tx, id)
+		if err != nil || !ok {
+			if err := Done(tx, err); err != nil {
+				return Upload{}, nil, false, err
+			}
+
+			// Record was deleted or soft deleted in race
+			continue
+		}
+
+		return upload, tx, true, nil
+	}
+}
+
+// markProcessing selects the next oldest upload with a state of `queued`, sets its state to
+// `processing`, and records the given worker as the one converting it. Returns false if
+// there are no queued uploads.
+func markProcessing(ctx context.Context, db Execer, workerID string) (int, bool, error) {
+	q := sqlf.Sprintf(`
+		WITH locked AS (
+			UPDATE lsif_uploads u
+			SET state = 'processing', started_at = now(), progress = 0, stage = NULL, worker_id = %s, last_heartbeat_at = now()
+			WHERE id = (
+				SELECT id FROM lsif_uploads
+				WHERE state = 'queued' AND format = 'lsif'
+				ORDER BY uploaded_at
+				FOR UPDATE SKIP LOCKED LIMIT 1
+			)
+			RETURNING u.id
+		), recorded AS (
+			INSERT INTO lsif_upload_events (upload_id, event, state, source)
+			SELECT id, 'processing', 'processing', 'worker' FROM locked
+		)
+		SELECT id FROM locked
+	`, workerID)
+
+	var id int
+	if err := db.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// lockUpload locks and returns the upload with the given identifier with a key share lock.
+// Returns false if the upload was deleted or has left the processing state.
+func lockUpload(ctx context.Context, tx Execer, id int) (Upload, bool, error) {
+	q := sqlf.Sprintf(`
+		SELECT id, repository_id, "commit", root, indexer, checksum, tracing_context FROM lsif_uploads
+		WHERE id = %s AND state = 'processing'
+		FOR KEY SHARE LIMIT 1
+	`, id)
+
+	var upload Upload
+	var checksum sql.NullString
+	if err := tx.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(
+		&upload.ID,
+		&upload.RepositoryID,
+		&upload.Commit,
+		&upload.Root,
+		&upload.Indexer,
+		&checksum,
+		&upload.TracingContext,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return Upload{}, false, nil
+		}
+		return Upload{}, false, err
+	}
+
+	upload.Checksum = checksum.String
+	return upload, true, nil
+}
+
+// Heartbeat records that the given worker is still converting the given upload. This must
+// not be called within the transaction that converts the upload. Returns false if the upload
+// is no longer being processed by the given worker (e.g. it was reset by the janitor).
+func Heartbeat(ctx context.Context, db *sql.DB, id int, workerID string) (bool, error) {
+	return updateProcessing(ctx, db, sqlf.Sprintf(`
+		UPDATE lsif_uploads SET last_heartbeat_at = now()
+		WHERE id = %s AND state = 'processing' AND worker_id = %s
+	`, id, workerID))
+}
+
+// UpdateProgress records the progress (as a percentage) of the conversion of a processing
+// upload. This must not be called within the transaction that converts the upload, so that
+// the progress is visible before the conversion commits.
+func UpdateProgress(ctx context.Context, db *sql.DB, id int, stage string, progress int) error {
+	return Exec(ctx, db, sqlf.Sprintf(`
+		UPDATE lsif_uploads SET stage = %s, progress = %s
+		WHERE id = %s AND state = 'processing'
+	`, stage, progress, id))
+}
+
+// MarkComplete marks a processing upload as complete and sets its finished timestamp. The
+// progress of the upload is set to 100. Returns ErrNotProcessing if the upload has left the
+// processing state, so that the conversion is not committed.
+func MarkComplete(ctx context.Context, tx *sql.Tx, id int) error {
+	ok, err := updateProcessing(ctx, tx, sqlf.Sprintf(`
+		UPDATE lsif_uploads SET state = 'completed', finished_at = now(), progress = 100, stage = NULL
+		WHERE id = %s AND state = 'processing'
+	`, id))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotProcessing
+	}
+
+	return recordEvent(ctx, tx, id, "completed", "completed", nil)
+}
+
+// MarkErrored marks a processing upload as errored and records the reason for the failure.
+// An upload that has left the processing state is left untouched.
+func MarkErrored(ctx context.Context, tx *sql.Tx, id int, failureSummary, failureStacktrace string) error {
+	ok, err := updateProcessing(ctx, tx, sqlf.Sprintf(`
+		UPDATE lsif_uploads
+		SET state = 'errored', finished_at = now(), failure_summary = %s, failure_stacktrace = %s
+		WHERE id = %s AND state = 'processing'
+	`, failureSummary, failureStacktrace, id))
+	if err != nil || !ok {
+		return err
+	}
+
+	return recordEvent(ctx, tx, id, "errored", "errored", &failureSummary)
+}
+
+// updateProcessing runs the given update of a processing upload. Returns false if no row
+// was updated.
+func updateProcessing(ctx context.Context, db Execer, q *sqlf.Query) (bool, error) {
+	result, err := db.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// recordEvent adds an entry caused by the worker to the audit log of the given upload.
+func recordEvent(ctx context.Context, tx Execer, id int, event, state string, message *string) error {
+	return Exec(ctx, tx, sqlf.Sprintf(`
+		INSERT INTO lsif_upload_events (upload_id, event, state, source, message)
+		VALUES (%s, %s, %s, 'worker', %s)
+	`, id, event, state, message))
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+)
+
+type testUpload struct {
+	id         int
+	state      string
+	format     string
+	workerID   string
+	uploadedAt time.Time
+}
+
+func insertUploads(t *testing.T, db *sql.DB, uploads ...testUpload) {
+	for _, upload := range uploads {
+		if upload.format == "" {
+			upload.format = "lsif"
+		}
+		if upload.uploadedAt.IsZero() {
+			upload.uploadedAt = time.Now()
+		}
+
+		if _, err := db.Exec(`
+			INSERT INTO lsif_uploads (id, repository_id, "commit", indexer, tracing_context, state, format, worker_id, uploaded_at)
+			VALUES ($1, 50, $2, 'lsif-go', '{}', $3, $4, NULLIF($5, ''), $6)
+		`, upload.id, strings.Repeat("a", 40), upload.state, upload.format, upload.workerID, upload.uploadedAt); err != nil {
+			t.Fatalf("unexpected error inserting upload: %s", err)
+		}
+	}
+}
+
+type uploadRow struct {
+	state    string
+	workerID string
+	progress int
+	summary  string
+	events   []string
+}
+
+func readUpload(t *testing.T, db *sql.DB, id int) uploadRow {
+	var row uploadRow
+	if err := db.QueryRow(`
+		SELECT state, COALESCE(worker_id, ''), progress, COALESCE(failure_summary, '') FROM lsif_uploads WHERE id = $1
+	`, id).Scan(&row.state, &row.workerID, &row.progress, &row.summary); err != nil {
+		t.Fatalf("unexpected error reading upload: %s", err)
+	}
+
+	rows, err := db.Query(`SELECT event FROM lsif_upload_events WHERE upload_id = $1 AND source = 'worker' ORDER BY id`, id)
+	if err != nil {
+		t.Fatalf("unexpected error reading events: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event string
+		if err := rows.Scan(&event); err != nil {
+			t.Fatalf("unexpected error reading events: %s", err)
+		}
+		row.events = append(row.events, event)
+	}
+
+	return row
+}
+
+func TestDequeue(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+	now := time.Now()
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "queued", uploadedAt: now.Add(-time.Hour)},
+		testUpload{id: 2, state: "queued", uploadedAt: now.Add(-2 * time.Hour)},
+		testUpload{id: 3, state: "queued", format: "scip", uploadedAt: now.Add(-3 * time.Hour)},
+		testUpload{id: 4, state: "processing", workerID: "other", uploadedAt: now.Add(-4 * time.Hour)},
+	)
+
+	upload, tx, ok, err := Dequeue(context.Background(), db, "worker-1")
+	if err != nil {
+		t.Fatalf("unexpected error dequeueing upload: %s", err)
+	}
+	if !ok {
+		t.Fatalf("expected an upload to be dequeued")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if upload.ID != 2 || upload.RepositoryID != 50 || upload.Indexer != "lsif-go" {
+		t.Errorf("unexpected upload. have=%+v", upload)
+	}
+
+	// The state transition is visible outside of the returned transaction
+	row := readUpload(t, db, 2)
+	if row.state != "processing" || row.workerID != "worker-1" || len(row.events) != 1 || row.events[0] != "processing" {
+		t.Errorf("unexpected dequeued upload. have=%+v", row)
+	}
+
+	// The returned transaction locks the upload against deletion but not against heartbeats
+	if _, err := db.Exec(`SELECT id FROM lsif_uploads WHERE id = 2 FOR UPDATE NOWAIT`); err == nil {
+		t.Errorf("expected the dequeued upload to be locked")
+	}
+	if ok, err := Heartbeat(context.Background(), db, 2, "worker-1"); err != nil || !ok {
+		t.Errorf("expected heartbeat of locked upload to succeed. ok=%v err=%v", ok, err)
+	}
+
+	if err := Done(tx, nil); err != nil {
+		t.Fatalf("unexpected error committing transaction: %s", err)
+	}
+}
+
+func TestDequeueEmpty(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "queued", format: "scip"},
+		testUpload{id: 2, state: "processing", workerID: "other"},
+		testUpload{id: 3, state: "completed"},
+	)
+
+	if _, _, ok, err := Dequeue(context.Background(), db, "worker-1"); err != nil {
+		t.Fatalf("unexpected error dequeueing upload: %s", err)
+	} else if ok {
+		t.Fatalf("expected no upload to be dequeued")
+	}
+}
+
+func TestDequeueSkipsLocked(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+	now := time.Now()
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "queued", uploadedAt: now.Add(-2 * time.Hour)},
+		testUpload{id: 2, state: "queued", uploadedAt: now.Add(-time.Hour)},
+	)
+
+	lockTx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error beginning transaction: %s", err)
+	}
+	defer func() { _ = lockTx.Rollback() }()
+
+	if _, err := lockTx.Exec(`SELECT id FROM lsif_uploads WHERE id = 1 FOR UPDATE`); err != nil {
+		t.Fatalf("unexpected error locking upload: %s", err)
+	}
+
+	upload, tx, ok, err := Dequeue(context.Background(), db, "worker-1")
+	if err != nil {
+		t.Fatalf("unexpected error dequeueing upload: %s", err)
+	}
+	if !ok {
+		t.Fatalf("expected an upload to be dequeued")
+	}
+	defer func() { _ = Done(tx, nil) }()
+
+	if upload.ID != 2 {
+		t.Errorf("unexpected upload. want=%d have=%d", 2, upload.ID)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "processing", workerID: "worker-1"},
+		testUpload{id: 2, state: "queued"},
+	)
+
+	for _, testCase := range []struct {
+		id       int
+		workerID string
+		expected bool
+	}{
+		{1, "worker-1", true},
+		{1, "worker-2", false},
+		{2, "worker-1", false},
+		{3, "worker-1", false},
+	} {
+		if ok, err := Heartbeat(context.Background(), db, testCase.id, testCase.workerID); err != nil {
+			t.Fatalf("unexpected error recording heartbeat: %s", err)
+		} else if ok != testCase.expected {
+			t.Errorf("unexpected heartbeat result for upload %d of %s. want=%v have=%v", testCase.id, testCase.workerID, testCase.expected, ok)
+		}
+	}
+}
+
+func TestUpdateProgress(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "processing", workerID: "worker-1"},
+		testUpload{id: 2, state: "queued"},
+	)
+
+	for _, id := range []int{1, 2} {
+		if err := UpdateProgress(context.Background(), db, id, "correlating", 40); err != nil {
+			t.Fatalf("unexpected error updating progress: %s", err)
+		}
+	}
+
+	if row := readUpload(t, db, 1); row.progress != 40 {
+		t.Errorf("unexpected progress of processing upload. want=%d have=%d", 40, row.progress)
+	}
+	if row := readUpload(t, db, 2); row.progress != 0 {
+		t.Errorf("unexpected progress of queued upload. want=%d have=%d", 0, row.progress)
+	}
+}
+
+func TestMarkComplete(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "processing", workerID: "worker-1"},
+		testUpload{id: 2, state: "deleting"},
+	)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error beginning transaction: %s", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := MarkComplete(context.Background(), tx, 1); err != nil {
+		t.Fatalf("unexpected error marking upload as complete: %s", err)
+	}
+	if err := MarkComplete(context.Background(), tx, 2); err != ErrNotProcessing {
+		t.Fatalf("unexpected error marking deleting upload as complete. want=%v have=%v", ErrNotProcessing, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error committing transaction: %s", err)
+	}
+
+	if row := readUpload(t, db, 1); row.state != "completed" || row.progress != 100 || len(row.events) != 1 || row.events[0] != "completed" {
+		t.Errorf("unexpected completed upload. have=%+v", row)
+	}
+	if row := readUpload(t, db, 2); row.state != "deleting" || len(row.events) != 0 {
+		t.Errorf("unexpected deleting upload. have=%+v", row)
+	}
+}
+
+func TestMarkErrored(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+	db := dbconn.Global
+
+	insertUploads(t, db,
+		testUpload{id: 1, state: "processing", workerID: "worker-1"},
+		testUpload{id: 2, state: "deleting"},
+	)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error beginning transaction: %s", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, id := range []int{1, 2} {
+		if err := MarkErrored(context.Background(), tx, id, "oops", "stack"); err != nil {
+			t.Fatalf("unexpected error marking upload as errored: %s", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error committing transaction: %s", err)
+	}
+
+	if row := readUpload(t, db, 1); row.state != "errored" || row.summary != "oops" || len(row.events) != 1 || row.events[0] != "errored" {
+		t.Errorf("unexpected errored upload. have=%+v", row)
+	}
+	if row := readUpload(t, db, 2); row.state != "deleting" || row.summary != "" || len(row.events) != 0 {
+		t.Errorf("unexpected deleting upload. have=%+v", row)
+	}
+}
@@ -0,0 +1,174 @@
+// Package gitserver runs git commands for the worker. Commands are sent through
+// the internal API of the frontend, which resolves repository identifiers into
+// names and proxies the command to the gitserver shard that holds the repository.
+package gitserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// MaxCommitsPerUpdate is the maximum number of commits requested from gitserver
+// when updating the commit graph of a repository.
+const MaxCommitsPerUpdate = 150
+
+// ErrUnknownRepository occurs when the frontend does not know the repository.
+var ErrUnknownRepository = errors.New("unknown repository")
+
+// Exec runs the given git command for the repository and returns its raw output.
+func Exec(ctx context.Context, repositoryID int, args ...string) ([]byte, error) {
+	if len(args) > 0 && args[0] == "git" {
+		return nil, errors.New("gitserver commands should not be prefixed with `git`")
+	}
+
+	payload, err := json.Marshal(map[string][]string{"args": args})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/.internal/git/%d/exec", api.InternalClient.URL, repositoryID)
+	resp, err := ctxhttp.Post(ctx, nil, url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrUnknownRepository
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d running git command: %s", resp.StatusCode, body)
+	}
+
+	// Determine if the underlying git command failed. The trailers are only
+	// available once the body has been read completely.
+	if status := resp.Trailer.Get("X-Exec-Exit-Status"); status != "" && status != "0" {
+		return nil, fmt.Errorf("failed to run git command %q: %s", append([]string{"git"}, args...), resp.Trailer.Get("X-Exec-Stderr"))
+	}
+
+	return body, nil
+}
+
+// ExecLines runs the given git command for the repository and returns its output
+// split into non-empty lines.
+func ExecLines(ctx context.Context, repositoryID int, args ...string) ([]string, error) {
+	out, err := Exec(ctx, repositoryID, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+// DirectoryChildren returns a map from each of the given repo-root-relative directories
+// to the set of its children at the given commit. The root directory is denoted by the
+// empty string. Except for the root directory, all supplied directories should be disjoint.
+func DirectoryChildren(ctx context.Context, repositoryID int, commit string, dirnames []string) (map[string][]string, error) {
+	args := []string{"ls-tree", "--name-only", commit, "--"}
+	for _, dirname := range dirnames {
+		if dirname == "" {
+			args = append(args, ".")
+		} else {
+			args = append(args, strings.TrimSuffix(dirname, "/")+"/")
+		}
+	}
+
+	children, err := ExecLines(ctx, repositoryID, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	childMap := map[string][]string{}
+	for _, dirname := range dirnames {
+		childMap[dirname] = nil
+	}
+	for _, child := range children {
+		dirname := path.Dir(child)
+		if dirname == "." {
+			dirname = ""
+		}
+
+		if _, ok := childMap[dirname]; ok {
+			childMap[dirname] = append(childMap[dirname], child)
+		}
+	}
+
+	return childMap, nil
+}
+
+// TrackedFiles returns the paths of all files tracked by git recursively within the
+// given directory at the given commit. The root directory is denoted by the empty string.
+func TrackedFiles(ctx context.Context, repositoryID int, commit, dirname string) ([]string, error) {
+	if dirname == "" {
+		dirname = "."
+	} else {
+		dirname = strings.TrimSuffix(dirname, "/") + "/"
+	}
+
+	return ExecLines(ctx, repositoryID, "ls-tree", "-r", "--name-only", commit, "--", dirname)
+}
+
+// CommitsNear returns a map from commits to their set of parents starting at the given
+// commit and returning at most MaxCommitsPerUpdate commits. The set of parents may be
+// empty. If the repository is unknown, an empty map is returned.
+func CommitsNear(ctx context.Context, repositoryID int, commit string) (map[string][]string, error) {
+	lines, err := ExecLines(ctx, repositoryID, "log", "--pretty=%H %P", commit, fmt.Sprintf("-%d", MaxCommitsPerUpdate))
+	if err != nil {
+		if err == ErrUnknownRepository {
+			return map[string][]string{}, nil
+		}
+		return nil, err
+	}
+
+	return flattenCommitParents(lines), nil
+}
+
+// flattenCommitParents converts git log output into a parentage map. Each line of the
+// input should have the form `commit p1 p2 p3...`.
+func flattenCommitParents(lines []string) map[string][]string {
+	commits := map[string][]string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		commits[fields[0]] = fields[1:]
+	}
+
+	return commits
+}
+
+// Head returns the current tip of the default branch of the given repository. An empty
+// string is returned if the repository has no commits.
+func Head(ctx context.Context, repositoryID int) (string, error) {
+	lines, err := ExecLines(ctx, repositoryID, "rev-parse", "HEAD")
+	if err != nil || len(lines) == 0 {
+		return "", err
+	}
+
+	return lines[0], nil
+}
@@ -0,0 +1,97 @@
+// Package shards assigns dumps to bundle manager shards with the same consistent hash
+// ring as the TypeScript code intel services, so that the worker sends each bundle to
+// the shard from which the api-server will later query it.
+package shards
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PointsPerShard is the number of points each shard occupies on the hash ring. This must
+// match POINTS_PER_SHARD in shared/shards.ts.
+const PointsPerShard = 128
+
+// ParseURLs parses a comma-separated list of bundle manager URLs. Whitespace around each
+// URL and empty entries are ignored, and trailing slashes are removed so that each shard
+// has a single canonical URL.
+func ParseURLs(value string) []string {
+	var urls []string
+	seen := map[string]struct{}{}
+
+	for _, url := range strings.Split(value, ",") {
+		url = strings.TrimRight(strings.TrimSpace(url), "/")
+		if url == "" {
+			continue
+		}
+		if _, ok := seen[url]; ok {
+			continue
+		}
+
+		seen[url] = struct{}{}
+		urls = append(urls, url)
+	}
+
+	return urls
+}
+
+type point struct {
+	hash uint32
+	url  string
+}
+
+// Ring is a consistent hash ring that assigns each dump to one of a set of bundle
+// manager shards.
+type Ring struct {
+	urls   []string
+	points []point
+}
+
+// NewRing creates a ring of the given bundle manager URLs.
+func NewRing(urls []string) (*Ring, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("at least one bundle manager URL is required")
+	}
+
+	points := make([]point, 0, len(urls)*PointsPerShard)
+	for _, url := range urls {
+		for i := 0; i < PointsPerShard; i++ {
+			points = append(points, point{hash: hash(url + "#" + strconv.Itoa(i)), url: url})
+		}
+	}
+
+	// Break ties by URL so that the ring does not depend on the order of the URLs
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].url < points[j].url
+	})
+
+	return &Ring{urls: urls, points: points}, nil
+}
+
+// URLs returns the URLs of the shards of the ring.
+func (r *Ring) URLs() []string {
+	return r.urls
+}
+
+// ShardFor returns the URL of the shard that stores the bundle of the given dump. This
+// is the first point on the ring at or after the hash of the dump identifier.
+func (r *Ring) ShardFor(id int) string {
+	target := hash(strconv.Itoa(id))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= target })
+
+	// Wrap around to the first point
+	return r.points[i%len(r.points)].url
+}
+
+// hash hashes the given value to an unsigned 32-bit integer.
+func hash(value string) uint32 {
+	sum := md5.Sum([]byte(value))
+	return binary.BigEndian.Uint32(sum[:4])
+}
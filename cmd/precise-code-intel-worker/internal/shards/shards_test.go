@@ -0,0 +1,57 @@
+package shards
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseURLs(t *testing.T) {
+	expected := []string{"http://a:3187", "http://b:3187"}
+	if urls := ParseURLs(" http://a:3187/, http://b:3187,,http://a:3187 "); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("unexpected urls. want=%v have=%v", expected, urls)
+	}
+	if urls := ParseURLs(""); len(urls) != 0 {
+		t.Errorf("unexpected urls. want=%v have=%v", nil, urls)
+	}
+}
+
+func TestRingMatchesTypeScript(t *testing.T) {
+	ring, err := NewRing([]string{"http://a:3187", "http://b:3187", "http://c:3187"})
+	if err != nil {
+		t.Fatalf("unexpected error creating ring: %s", err)
+	}
+
+	// Generated with ShardRing in shared/shards.ts
+	expected := map[int]string{
+		1:    "http://a:3187",
+		2:    "http://a:3187",
+		3:    "http://c:3187",
+		6:    "http://c:3187",
+		9:    "http://b:3187",
+		10:   "http://c:3187",
+		1000: "http://b:3187",
+	}
+
+	for id, url := range expected {
+		if shard := ring.ShardFor(id); shard != url {
+			t.Errorf("unexpected shard for dump %d. want=%s have=%s", id, url, shard)
+		}
+	}
+}
+
+func TestRingOrderIndependent(t *testing.T) {
+	ring1, _ := NewRing([]string{"http://a", "http://b", "http://c"})
+	ring2, _ := NewRing([]string{"http://c", "http://a", "http://b"})
+
+	for id := 1; id <= 5000; id++ {
+		if shard1, shard2 := ring1.ShardFor(id), ring2.ShardFor(id); shard1 != shard2 {
+			t.Fatalf("unexpected shard for dump %d. want=%s have=%s", id, shard1, shard2)
+		}
+	}
+}
+
+func TestRingRequiresShard(t *testing.T) {
+	if _, err := NewRing(nil); err == nil {
+		t.Fatalf("expected error creating empty ring")
+	}
+}
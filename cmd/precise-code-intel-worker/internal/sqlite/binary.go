@@ -0,0 +1,332 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// binaryEncodingVersion is the version of the binary encoding, written as the first byte
+// of every encoded value. This must match BINARY_ENCODING_VERSION in shared/encoding/binary.ts.
+const binaryEncodingVersion = 1
+
+// The tags that precede each encoded value and identify its type. These must match the
+// Tag enum in shared/encoding/binary.ts.
+const (
+	tagNull            = 0
+	tagFalse           = 1
+	tagTrue            = 2
+	tagUnsignedInteger = 3
+	tagNegativeInteger = 4
+	tagDouble          = 5
+	tagString          = 6
+	tagStringReference = 7
+	tagArray           = 8
+	tagObject          = 9
+	tagMap             = 10
+	tagSet             = 11
+)
+
+// maxSafeInteger is the largest integer that JavaScript numbers represent exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// binaryWriter encodes values in the binary encoding read by the bundle manager. Integers
+// are written as varints, and each distinct string (including object keys) is written once
+// and then referred to by its index.
+type binaryWriter struct {
+	buf     []byte
+	strings map[string]int
+}
+
+func newBinaryWriter() *binaryWriter {
+	w := &binaryWriter{buf: make([]byte, 0, 1024), strings: map[string]int{}}
+	w.buf = append(w.buf, binaryEncodingVersion)
+	return w
+}
+
+func (w *binaryWriter) writeNull() {
+	w.buf = append(w.buf, tagNull)
+}
+
+func (w *binaryWriter) writeInt(value int) {
+	if value >= 0 {
+		w.buf = append(w.buf, tagUnsignedInteger)
+		w.writeVarint(uint64(value))
+	} else {
+		w.buf = append(w.buf, tagNegativeInteger)
+		w.writeVarint(uint64(-int64(value)))
+	}
+}
+
+// writeNumber writes a number as a varint if it is a safe integer and as a double otherwise.
+// Unlike the TypeScript writer, negative zero is written as zero: identifiers are used as
+// map keys and compared with ===, neither of which distinguishes the two.
+func (w *binaryWriter) writeNumber(value float64) {
+	if value != math.Trunc(value) || math.Abs(value) > maxSafeInteger {
+		w.buf = append(w.buf, tagDouble)
+		w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(w.buf[len(w.buf)-8:], math.Float64bits(value))
+		return
+	}
+
+	w.writeInt(int(value))
+}
+
+// writeString writes a string, or a reference to the same string if it has been written before.
+func (w *binaryWriter) writeString(value string) {
+	if index, ok := w.strings[value]; ok {
+		w.buf = append(w.buf, tagStringReference)
+		w.writeVarint(uint64(index))
+		return
+	}
+
+	w.strings[value] = len(w.strings)
+	w.buf = append(w.buf, tagString)
+	w.writeVarint(uint64(len(value)))
+	w.buf = append(w.buf, value...)
+}
+
+// writeOptionalString writes the given string or null if it is nil.
+func (w *binaryWriter) writeOptionalString(value *string) {
+	if value == nil {
+		w.writeNull()
+	} else {
+		w.writeString(*value)
+	}
+}
+
+// writeID writes an identifier with the type of its raw JSON encoding, or null if it is empty.
+func (w *binaryWriter) writeID(id types.ID) {
+	if id == "" {
+		w.writeNull()
+		return
+	}
+
+	if value, err := strconv.Unquote(string(id)); err == nil {
+		w.writeString(value)
+		return
+	}
+
+	value, err := strconv.ParseFloat(string(id), 64)
+	if err != nil {
+		// Identifiers are either JSON numbers or JSON strings
+		w.writeString(string(id))
+		return
+	}
+
+	w.writeNumber(value)
+}
+
+func (w *binaryWriter) writeHeader(tag byte, size int) {
+	w.buf = append(w.buf, tag)
+	w.writeVarint(uint64(size))
+}
+
+// writeVarint writes an integer in base 128, least significant group first.
+func (w *binaryWriter) writeVarint(value uint64) {
+	for value >= 0x80 {
+		w.buf = append(w.buf, byte(value)|0x80)
+		value >>= 7
+	}
+	w.buf = append(w.buf, byte(value))
+}
+
+// encodeDocumentBinary returns the binary encoding of the given document. The encoded
+// value has the same shape as the document data decoded from JSON by the bundle manager.
+func encodeDocumentBinary(document types.DocumentData) []byte {
+	w := newBinaryWriter()
+	w.writeHeader(tagObject, 4)
+
+	w.writeString("ranges")
+	w.writeHeader(tagMap, len(document.Ranges))
+	rangeIDs := make([]types.ID, 0, len(document.Ranges))
+	for id := range document.Ranges {
+		rangeIDs = append(rangeIDs, id)
+	}
+	types.SortIDs(rangeIDs)
+	for _, id := range rangeIDs {
+		w.writeID(id)
+		w.writeRange(document.Ranges[id])
+	}
+
+	w.writeString("hoverResults")
+	w.writeHeader(tagMap, len(document.HoverResults))
+	hoverResultIDs := make([]types.ID, 0, len(document.HoverResults))
+	for id := range document.HoverResults {
+		hoverResultIDs = append(hoverResultIDs, id)
+	}
+	types.SortIDs(hoverResultIDs)
+	for _, id := range hoverResultIDs {
+		w.writeID(id)
+		w.writeString(document.HoverResults[id])
+	}
+
+	w.writeString("monikers")
+	w.writeHeader(tagMap, len(document.Monikers))
+	monikerIDs := make([]types.ID, 0, len(document.Monikers))
+	for id := range document.Monikers {
+		monikerIDs = append(monikerIDs, id)
+	}
+	types.SortIDs(monikerIDs)
+	for _, id := range monikerIDs {
+		moniker := document.Monikers[id]
+		w.writeID(id)
+
+		size := 3
+		if moniker.PackageInformationID != "" {
+			size++
+		}
+		w.writeHeader(tagObject, size)
+		w.writeString("kind")
+		w.writeString(moniker.Kind)
+		w.writeString("scheme")
+		w.writeString(moniker.Scheme)
+		w.writeString("identifier")
+		w.writeString(moniker.Identifier)
+		if moniker.PackageInformationID != "" {
+			w.writeString("packageInformationId")
+			w.writeID(moniker.PackageInformationID)
+		}
+	}
+
+	w.writeString("packageInformation")
+	w.writeHeader(tagMap, len(document.PackageInformation))
+	packageInformationIDs := make([]types.ID, 0, len(document.PackageInformation))
+	for id := range document.PackageInformation {
+		packageInformationIDs = append(packageInformationIDs, id)
+	}
+	types.SortIDs(packageInformationIDs)
+	for _, id := range packageInformationIDs {
+		packageInformation := document.PackageInformation[id]
+		w.writeID(id)
+		w.writeHeader(tagObject, 2)
+		w.writeString("name")
+		w.writeString(packageInformation.Name)
+		w.writeString("version")
+		w.writeOptionalString(packageInformation.Version)
+	}
+
+	return w.buf
+}
+
+// writeRange writes a range along with its results, omitting missing results as the JSON
+// encoding does.
+func (w *binaryWriter) writeRange(r types.RangeData) {
+	results := []struct {
+		key string
+		id  types.ID
+	}{
+		{"definitionResultId", r.DefinitionResultID},
+		{"referenceResultId", r.ReferenceResultID},
+		{"implementationResultId", r.ImplementationResultID},
+		{"hoverResultId", r.HoverResultID},
+	}
+
+	size := 5
+	for _, result := range results {
+		if result.id != "" {
+			size++
+		}
+	}
+	if r.Tag != nil {
+		size++
+	}
+
+	w.writeHeader(tagObject, size)
+	w.writeString("startLine")
+	w.writeInt(r.StartLine)
+	w.writeString("startCharacter")
+	w.writeInt(r.StartCharacter)
+	w.writeString("endLine")
+	w.writeInt(r.EndLine)
+	w.writeString("endCharacter")
+	w.writeInt(r.EndCharacter)
+
+	for _, result := range results {
+		if result.id != "" {
+			w.writeString(result.key)
+			w.writeID(result.id)
+		}
+	}
+
+	w.writeString("monikerIds")
+	monikerIDs := r.MonikerIDs.Keys()
+	w.writeHeader(tagSet, len(monikerIDs))
+	for _, id := range monikerIDs {
+		w.writeID(id)
+	}
+
+	if r.Tag != nil {
+		w.writeString("tag")
+		w.writeHeader(tagObject, 3)
+		w.writeString("text")
+		w.writeString(r.Tag.Text)
+		w.writeString("kind")
+		w.writeInt(r.Tag.Kind)
+		w.writeString("fullRange")
+		w.writeLSPRange(r.Tag.FullRange)
+	}
+}
+
+// writeLSPRange writes a range as a pair of start and end positions.
+func (w *binaryWriter) writeLSPRange(r types.Range) {
+	w.writeHeader(tagObject, 2)
+	for _, position := range []struct {
+		key  string
+		line int
+		char int
+	}{
+		{"start", r.Start.Line, r.Start.Character},
+		{"end", r.End.Line, r.End.Character},
+	} {
+		w.writeString(position.key)
+		w.writeHeader(tagObject, 2)
+		w.writeString("line")
+		w.writeInt(position.line)
+		w.writeString("character")
+		w.writeInt(position.char)
+	}
+}
+
+// encodeResultChunkBinary returns the binary encoding of the given result chunk. The encoded
+// value has the same shape as the result chunk data decoded from JSON by the bundle manager.
+func encodeResultChunkBinary(resultChunk types.ResultChunkData) []byte {
+	w := newBinaryWriter()
+	w.writeHeader(tagObject, 2)
+
+	w.writeString("documentPaths")
+	w.writeHeader(tagMap, len(resultChunk.DocumentPaths))
+	documentIDs := make([]types.ID, 0, len(resultChunk.DocumentPaths))
+	for id := range resultChunk.DocumentPaths {
+		documentIDs = append(documentIDs, id)
+	}
+	types.SortIDs(documentIDs)
+	for _, id := range documentIDs {
+		w.writeID(id)
+		w.writeString(resultChunk.DocumentPaths[id])
+	}
+
+	w.writeString("documentIdRangeIds")
+	w.writeHeader(tagMap, len(resultChunk.DocumentIDRangeIDs))
+	resultIDs := make([]types.ID, 0, len(resultChunk.DocumentIDRangeIDs))
+	for id := range resultChunk.DocumentIDRangeIDs {
+		resultIDs = append(resultIDs, id)
+	}
+	types.SortIDs(resultIDs)
+	for _, id := range resultIDs {
+		pairs := resultChunk.DocumentIDRangeIDs[id]
+		w.writeID(id)
+		w.writeHeader(tagArray, len(pairs))
+		for _, pair := range pairs {
+			w.writeHeader(tagObject, 2)
+			w.writeString("documentId")
+			w.writeID(pair.DocumentID)
+			w.writeString("rangeId")
+			w.writeID(pair.RangeID)
+		}
+	}
+
+	return w.buf
+}
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// The expected encodings below were generated by decoding the JSON encoding of the same
+// value with parseJSON and re-encoding it with encodeBinary in shared/encoding/binary.ts.
+
+func TestEncodeDocumentBinary(t *testing.T) {
+	expected := "010904060672616e6765730a0203010908060973746172744c696e650301060e737461727443686172616374657203020607656e644c696e650303060c656e6443686172616374657203040612646566696e6974696f6e526573756c744964030506117265666572656e6365526573756c744964060136060d686f766572526573756c7449640307060a6d6f6e696b65724964730b0203080601390601320907070103ac0207020300070303ac020704030c0616696d706c656d656e746174696f6e526573756c744964030a07090b0006037461670903060474657874060a66756e6320466f6f282906046b696e64030c060966756c6c52616e6765090206057374617274090206046c696e6503ac02060963686172616374657203000603656e640902071303b60207140301060c686f766572526573756c74730a0103070614606060676f0a66756e6320466f6f28290a60606006086d6f6e696b6572730a0203080904071006066578706f72740606736368656d650605676f6d6f64060a6964656e7469666965720607666f6f3a466f6f06147061636b616765496e666f726d6174696f6e4964030b070a090307100606696d706f7274071a071b071c06076261723a42617206127061636b616765496e666f726d6174696f6e0a02030b090206046e616d650603666f6f060776657273696f6e060676312e322e33030c090207220603626172072400"
+	if encoded := hex.EncodeToString(encodeDocumentBinary(testDocument())); encoded != expected {
+		t.Errorf("unexpected encoding. want=%s have=%s", expected, encoded)
+	}
+}
+
+func TestEncodeResultChunkBinary(t *testing.T) {
+	expected := "010902060d646f63756d656e7450617468730a0203010606666f6f2e676f060132060a6261722f62617a2e676f0612646f63756d656e74496452616e67654964730a0304c8010800030508020902060a646f63756d656e7449640301060772616e67654964030109020705070207060702060136080109020705030107060301"
+	if encoded := hex.EncodeToString(encodeResultChunkBinary(testResultChunk())); encoded != expected {
+		t.Errorf("unexpected encoding. want=%s have=%s", expected, encoded)
+	}
+}
+
+func TestEncodeBinaryNumericIDs(t *testing.T) {
+	resultChunk := types.ResultChunkData{
+		DocumentPaths: map[types.ID]string{"-0": "a", "1.5": "b", "9007199254740993": "c", "1e3": "d"},
+	}
+
+	// Negative zero, fractions, unsafe integers, and exponents are read as JavaScript would
+	expected := "010902060d646f63756d656e7450617468730a04030006016105000000000000f83f06016203e8070601640500000000000040430601630612646f63756d656e74496452616e67654964730a00"
+	if encoded := hex.EncodeToString(encodeResultChunkBinary(resultChunk)); encoded != expected {
+		t.Errorf("unexpected encoding. want=%s have=%s", expected, encoded)
+	}
+}
+
+func BenchmarkEncodeDocument(b *testing.B) {
+	document := benchmarkDocument(100000)
+
+	for _, encoding := range []PayloadEncoding{PayloadEncodingGzipJSON, PayloadEncodingGzipBinary} {
+		w := &Writer{payloadEncoding: encoding}
+
+		b.Run(string(encoding), func(b *testing.B) {
+			b.ReportAllocs()
+
+			var size int
+			for i := 0; i < b.N; i++ {
+				data, err := w.encode(document, func() []byte { return encodeDocumentBinary(document) })
+				if err != nil {
+					b.Fatalf("unexpected error encoding document: %s", err)
+				}
+				size = len(data)
+			}
+
+			b.ReportMetric(float64(size), "B/payload")
+		})
+	}
+}
+
+func testDocument() types.DocumentData {
+	version := "v1.2.3"
+
+	return types.DocumentData{
+		Ranges: map[types.ID]types.RangeData{
+			"1": {
+				StartLine:      1,
+				StartCharacter: 2,
+				EndLine:        3,
+				EndCharacter:   4,
+				ResultSetData: types.ResultSetData{
+					DefinitionResultID: "5",
+					ReferenceResultID:  `"6"`,
+					HoverResultID:      "7",
+					MonikerIDs:         types.NewIDSet("8", `"9"`),
+				},
+			},
+			`"2"`: {
+				StartLine:      300,
+				StartCharacter: 0,
+				EndLine:        300,
+				EndCharacter:   12,
+				ResultSetData: types.ResultSetData{
+					ImplementationResultID: "10",
+					MonikerIDs:             types.NewIDSet(),
+				},
+				Tag: &types.SymbolTagData{
+					Text: "func Foo()",
+					Kind: 12,
+					FullRange: types.Range{
+						Start: types.Position{Line: 300, Character: 0},
+						End:   types.Position{Line: 310, Character: 1},
+					},
+				},
+			},
+		},
+		HoverResults: map[types.ID]string{"7": "```go\nfunc Foo()\n```"},
+		Monikers: map[types.ID]types.MonikerData{
+			"8":   {Kind: "export", Scheme: "gomod", Identifier: "foo:Foo", PackageInformationID: "11"},
+			`"9"`: {Kind: "import", Scheme: "gomod", Identifier: "bar:Bar"},
+		},
+		PackageInformation: map[types.ID]types.PackageInformationData{
+			"11": {Name: "foo", Version: &version},
+			"12": {Name: "bar"},
+		},
+	}
+}
+
+func testResultChunk() types.ResultChunkData {
+	return types.ResultChunkData{
+		DocumentPaths: map[types.ID]string{"1": "foo.go", `"2"`: "bar/baz.go"},
+		DocumentIDRangeIDs: map[types.ID][]types.DocumentIDRangeID{
+			"5":    {{DocumentID: "1", RangeID: "1"}, {DocumentID: `"2"`, RangeID: `"2"`}},
+			`"6"`:  {{DocumentID: "1", RangeID: "1"}},
+			"-200": {},
+		},
+	}
+}
+
+// benchmarkDocument returns a document shaped like those of a typical bundle. This is the
+// same document as is created by shared/encoding/benchmark.ts, which benchmarks decoding.
+func benchmarkDocument(numRanges int) types.DocumentData {
+	version := "v0.9.0"
+	document := types.DocumentData{
+		Ranges:             make(map[types.ID]types.RangeData, numRanges),
+		HoverResults:       make(map[types.ID]string, numRanges/4),
+		Monikers:           make(map[types.ID]types.MonikerData, numRanges),
+		PackageInformation: map[types.ID]types.PackageInformationData{"1": {Name: "github.com/sourcegraph/lsif-go", Version: &version}},
+	}
+
+	for i := 0; i < numRanges; i++ {
+		id := 10 + i*4
+		r := types.RangeData{
+			StartLine:      i,
+			StartCharacter: 4,
+			EndLine:        i,
+			EndCharacter:   16,
+			ResultSetData: types.ResultSetData{
+				DefinitionResultID: types.ID(fmt.Sprint(id + 1)),
+				ReferenceResultID:  types.ID(fmt.Sprint(id + 2)),
+				MonikerIDs:         types.NewIDSet(types.ID(fmt.Sprint(id + 3))),
+			},
+		}
+		if i%4 == 0 {
+			r.HoverResultID = types.ID(fmt.Sprint(id + 3))
+			document.HoverResults[r.HoverResultID] = fmt.Sprintf("```go\nfunc Symbol%d() error\n```", i)
+		}
+
+		document.Ranges[types.ID(fmt.Sprint(id))] = r
+		document.Monikers[types.ID(fmt.Sprint(id+3))] = types.MonikerData{
+			Kind:                 "export",
+			Scheme:               "gomod",
+			Identifier:           fmt.Sprintf("github.com/sourcegraph/lsif-go/protocol:Symbol%d", i),
+			PackageInformationID: "1",
+		}
+	}
+
+	return document
+}
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"unicode/utf16"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// HashKey hashes a string or numeric identifier into the range [0, maxIndex). This
+// must match the hashKey function used by the bundle manager to find the result chunk
+// for a definition or reference result. The hash algorithm here is similar to the one
+// used in Java's String.hashCode and operates on UTF-16 code units.
+func HashKey(id types.ID, maxIndex int) int {
+	var hash int32
+	for _, chr := range utf16.Encode([]rune(id.String())) {
+		hash = (hash << 5) - hash + int32(chr)
+	}
+
+	// Hash value may be negative - must unset sign bit before modulus
+	h := int64(hash)
+	if h < 0 {
+		h = -h
+	}
+
+	return int(h % int64(maxIndex))
+}
@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+func TestHashKey(t *testing.T) {
+	// Expected values generated by the hashKey function of the bundle manager
+	testCases := []struct {
+		id       types.ID
+		maxIndex int
+		expected int
+	}{
+		{`"foo"`, 997, 877},
+		{`"bar"`, 997, 590},
+		{`"github.com/sourcegraph/sourcegraph/internal/lsif:Foo"`, 997, 778},
+		{`"ünïcödé"`, 997, 64},
+		{`"日本語"`, 997, 940},
+		{`"😀x"`, 997, 364},
+		{`""`, 997, 0},
+		{`123456789`, 1000, 635},
+		{`"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"`, 7, 5},
+	}
+
+	for _, testCase := range testCases {
+		if actual := HashKey(testCase.id, testCase.maxIndex); actual != testCase.expected {
+			t.Errorf("unexpected hash for %s. want=%d have=%d", testCase.id, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Package sqlite writes the SQLite bundle files that are served by the
+// precise-code-intel-bundle-manager. The schema written here mirrors the TypeORM
+// entities of the bundle manager so that the bundles are indistinguishable from
+// those produced by the TypeScript worker.
+package sqlite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	// Register the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// InternalVersion is the internal version of our SQLite databases. We need to keep
+// this in case we add something that can't be done transparently; if we change how we
+// process something in the future we'll need to consider a number of previous versions
+// while we update or re-process the already-uploaded data.
+const InternalVersion = "0.1.0"
+
+// SchemaVersion is the version of the schema of the bundles written here. This must
+// match CURRENT_SCHEMA_VERSION of the bundle manager, which migrates bundles written
+// with an older schema.
+const SchemaVersion = 3
+
+// PayloadEncoding is the encoding of the document and result chunk payloads of a bundle.
+// The names match the PayloadEncoding type of the bundle manager.
+type PayloadEncoding string
+
+const (
+	// PayloadEncodingGzipJSON is the gzipped JSON representation of a payload.
+	PayloadEncodingGzipJSON PayloadEncoding = "gzip-json"
+
+	// PayloadEncodingGzipBinary is the gzipped binary representation of a payload, which
+	// the bundle manager decodes with considerably less CPU and fewer allocations.
+	PayloadEncodingGzipBinary PayloadEncoding = "gzip-binary"
+)
+
+// ParsePayloadEncoding returns the payload encoding with the given name.
+func ParsePayloadEncoding(value string) (PayloadEncoding, error) {
+	switch encoding := PayloadEncoding(value); encoding {
+	case PayloadEncodingGzipJSON, PayloadEncodingGzipBinary:
+		return encoding, nil
+	}
+
+	return "", fmt.Errorf("unsupported payload encoding %s", value)
+}
+
+// table describes a table of a bundle and the columns to be indexed.
+type table struct {
+	name    string
+	columns []string
+	indexes [][]string
+}
+
+// symbolColumns are the columns of both the definitions and references tables.
+var symbolColumns = []string{
+	`"id" integer PRIMARY KEY NOT NULL`,
+	`"scheme" text NOT NULL`,
+	`"identifier" text NOT NULL`,
+	`"documentPath" text NOT NULL`,
+	`"startLine" integer NOT NULL`,
+	`"endLine" integer NOT NULL`,
+	`"startCharacter" integer NOT NULL`,
+	`"endCharacter" integer NOT NULL`,
+}
+
+// tables are the tables of a bundle.
+var tables = []table{
+	{
+		name: "meta",
+		columns: []string{
+			`"id" integer PRIMARY KEY NOT NULL`,
+			`"lsifVersion" text NOT NULL`,
+			`"sourcegraphVersion" text NOT NULL`,
+			`"numResultChunks" integer NOT NULL`,
+			`"schemaVersion" integer NOT NULL`,
+			`"payloadEncoding" text NOT NULL DEFAULT ('gzip-json')`,
+		},
+	},
+	{
+		name: "documents",
+		columns: []string{
+			`"path" text PRIMARY KEY NOT NULL`,
+			`"data" blob NOT NULL`,
+		},
+	},
+	{
+		name: "resultChunks",
+		columns: []string{
+			`"id" integer PRIMARY KEY NOT NULL`,
+			`"data" blob NOT NULL`,
+		},
+	},
+	{name: "definitions", columns: symbolColumns, indexes: [][]string{{"scheme", "identifier"}}},
+	{name: "references", columns: symbolColumns, indexes: [][]string{{"scheme", "identifier"}}},
+	{
+		name: "diagnostics",
+		columns: []string{
+			`"id" integer PRIMARY KEY NOT NULL`,
+			`"documentPath" text NOT NULL`,
+			`"severity" integer`,
+			`"code" text`,
+			`"message" text NOT NULL`,
+			`"source" text`,
+			`"startLine" integer NOT NULL`,
+			`"endLine" integer NOT NULL`,
+			`"startCharacter" integer NOT NULL`,
+			`"endCharacter" integer NOT NULL`,
+		},
+		indexes: [][]string{{"documentPath"}},
+	},
+}
+
+// Writer populates a new SQLite bundle. All writes are performed within a single
+// transaction that is committed by Close.
+type Writer struct {
+	db                   *sql.DB
+	payloadEncoding      PayloadEncoding
+	tx                   *sql.Tx
+	documentStatement    *sql.Stmt
+	resultChunkStatement *sql.Stmt
+	definitionStatement  *sql.Stmt
+	referenceStatement   *sql.Stmt
+	diagnosticStatement  *sql.Stmt
+	numDefinitions       int
+	numReferences        int
+	numDiagnostics       int
+}
+
+// NewWriter creates a bundle at the given path and prepares its tables for writing. The
+// document and result chunk payloads are written with the given encoding.
+func NewWriter(filename string, payloadEncoding PayloadEncoding) (_ *Writer, err error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = db.Close()
+		}
+	}()
+
+	for _, pragma := range []string{"PRAGMA synchronous = OFF", "PRAGMA journal_mode = OFF"} {
+		if _, err := db.Exec(pragma); err != nil {
+			return nil, errors.Wrap(err, pragma)
+		}
+	}
+
+	for _, t := range tables {
+		if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE %q (%s)`, t.name, strings.Join(t.columns, ", "))); err != nil {
+			return nil, errors.Wrap(err, "creating table")
+		}
+
+		for _, columns := range t.indexes {
+			quoted := make([]string, 0, len(columns))
+			for _, column := range columns {
+				quoted = append(quoted, fmt.Sprintf("%q", column))
+			}
+
+			query := fmt.Sprintf(`CREATE INDEX %q ON %q (%s)`, indexName(t.name, columns), t.name, strings.Join(quoted, ", "))
+			if _, err := db.Exec(query); err != nil {
+				return nil, errors.Wrap(err, "creating index")
+			}
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{db: db, payloadEncoding: payloadEncoding, tx: tx}
+	statements := []struct {
+		target **sql.Stmt
+		query  string
+	}{
+		{&w.documentStatement, `INSERT INTO "documents" ("path", "data") VALUES (?, ?)`},
+		{&w.resultChunkStatement, `INSERT INTO "resultChunks" ("id", "data") VALUES (?, ?)`},
+		{&w.definitionStatement, symbolInsertQuery("definitions")},
+		{&w.referenceStatement, symbolInsertQuery("references")},
+		{&w.diagnosticStatement, `INSERT INTO "diagnostics" ("id", "documentPath", "severity", "code", "message", "source", "startLine", "endLine", "startCharacter", "endCharacter") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+	}
+	for _, s := range statements {
+		if *s.target, err = tx.Prepare(s.query); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// symbolInsertQuery returns the insert query for the definitions or references table.
+func symbolInsertQuery(tableName string) string {
+	return fmt.Sprintf(`INSERT INTO %q ("id", "scheme", "identifier", "documentPath", "startLine", "endLine", "startCharacter", "endCharacter") VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, tableName)
+}
+
+// indexName returns the name TypeORM generates for an index on the given table and
+// columns. Using the same name stops the bundle manager from recreating the index when
+// it synchronizes the schema of the bundle on open.
+func indexName(tableName string, columns []string) string {
+	sorted := append([]string(nil), columns...)
+	sort.Strings(sorted)
+
+	sum := sha1.Sum([]byte(strings.Replace(tableName, ".", "_", -1) + "_" + strings.Join(sorted, "_")))
+	return "IDX_" + hex.EncodeToString(sum[:])[:26]
+}
+
+// WriteMeta inserts the metadata row. This stores the number of result chunks so that
+// the bundle manager can compute stable hashes at query time.
+func (w *Writer) WriteMeta(lsifVersion string, numResultChunks int) error {
+	_, err := w.tx.Exec(
+		`INSERT INTO "meta" ("id", "lsifVersion", "sourcegraphVersion", "numResultChunks", "schemaVersion", "payloadEncoding") VALUES (1, ?, ?, ?, ?, ?)`,
+		lsifVersion,
+		InternalVersion,
+		numResultChunks,
+		SchemaVersion,
+		string(w.payloadEncoding),
+	)
+	return err
+}
+
+// WriteDocument inserts the encoded data of a single document.
+func (w *Writer) WriteDocument(path string, document types.DocumentData) error {
+	data, err := w.encode(document, func() []byte { return encodeDocumentBinary(document) })
+	if err != nil {
+		return err
+	}
+
+	_, err = w.documentStatement.Exec(path, data)
+	return err
+}
+
+// WriteResultChunk inserts the encoded data of a single result chunk.
+func (w *Writer) WriteResultChunk(id int, resultChunk types.ResultChunkData) error {
+	data, err := w.encode(resultChunk, func() []byte { return encodeResultChunkBinary(resultChunk) })
+	if err != nil {
+		return err
+	}
+
+	_, err = w.resultChunkStatement.Exec(id, data)
+	return err
+}
+
+// WriteDefinition inserts a row into the definitions table.
+func (w *Writer) WriteDefinition(location types.Location) error {
+	w.numDefinitions++
+	return writeLocation(w.definitionStatement, w.numDefinitions, location)
+}
+
+// WriteReference inserts a row into the references table.
+func (w *Writer) WriteReference(location types.Location) error {
+	w.numReferences++
+	return writeLocation(w.referenceStatement, w.numReferences, location)
+}
+
+func writeLocation(statement *sql.Stmt, id int, l types.Location) error {
+	_, err := statement.Exec(id, l.Scheme, l.Identifier, l.DocumentPath, l.StartLine, l.EndLine, l.StartCharacter, l.EndCharacter)
+	return err
+}
+
+// WriteDiagnostic inserts a row into the diagnostics table.
+func (w *Writer) WriteDiagnostic(documentPath string, d types.DiagnosticData) error {
+	w.numDiagnostics++
+
+	_, err := w.diagnosticStatement.Exec(
+		w.numDiagnostics,
+		documentPath,
+		d.Severity,
+		d.Code,
+		d.Message,
+		d.Source,
+		d.StartLine,
+		d.EndLine,
+		d.StartCharacter,
+		d.EndCharacter,
+	)
+	return err
+}
+
+// Close commits the transaction if err is nil and rolls it back otherwise, then closes
+// the database. Returns the first error that occurred.
+func (w *Writer) Close(err error) error {
+	for _, statement := range []*sql.Stmt{w.documentStatement, w.resultChunkStatement, w.definitionStatement, w.referenceStatement, w.diagnosticStatement} {
+		if closeErr := statement.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err == nil {
+		err = w.tx.Commit()
+	} else {
+		_ = w.tx.Rollback()
+	}
+
+	if closeErr := w.db.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// encode returns the payload of value in the encoding of the bundle. The binary function
+// returns the binary representation of value.
+func (w *Writer) encode(value interface{}, binary func() []byte) ([]byte, error) {
+	if w.payloadEncoding == PayloadEncodingGzipBinary {
+		return gzipBytes(binary())
+	}
+
+	return gzipJSON(value)
+}
+
+// gzipBytes returns the gzipped representation of data.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gzipJSON returns the gzipped JSON representation of value.
+func gzipJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+
+	if err := json.NewEncoder(gzipWriter).Encode(value); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,230 @@
+// Package types contains the data types shared by the correlation and SQLite
+// writing stages of LSIF conversion. The JSON encoding of these types must stay
+// compatible with the data read by the precise-code-intel-bundle-manager.
+package types
+
+import (
+	"encoding/json"
+	"sort"
+
+	codeinteltypes "github.com/sourcegraph/sourcegraph/internal/codeintel/types"
+)
+
+// ID is the identifier of a vertex or edge in an LSIF dump.
+type ID = codeinteltypes.ID
+
+// IDSet is a set of identifiers.
+type IDSet map[ID]struct{}
+
+// NewIDSet creates a set containing the given identifiers.
+func NewIDSet(ids ...ID) IDSet {
+	s := IDSet{}
+	for _, id := range ids {
+		s[id] = struct{}{}
+	}
+	return s
+}
+
+// Add inserts the identifier into the set.
+func (s IDSet) Add(id ID) {
+	s[id] = struct{}{}
+}
+
+// Contains determines if the identifier is a member of the set.
+func (s IDSet) Contains(id ID) bool {
+	_, ok := s[id]
+	return ok
+}
+
+// Keys returns the members of the set in a deterministic order.
+func (s IDSet) Keys() []ID {
+	ids := make([]ID, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	SortIDs(ids)
+	return ids
+}
+
+// MarshalJSON encodes the set in the format expected by the bundle manager.
+func (s IDSet) MarshalJSON() ([]byte, error) {
+	values := make([]interface{}, 0, len(s))
+	for _, id := range s.Keys() {
+		values = append(values, id)
+	}
+	return json.Marshal(encodedValue{Type: "set", Value: values})
+}
+
+// SortIDs sorts the given identifiers in the order JavaScript's default sort
+// would order them (by their string values).
+func SortIDs(ids []ID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+}
+
+// encodedValue is the JSON representation of an ES6 map or set value as produced
+// by the `dumpJSON` function of the TypeScript code intel services.
+type encodedValue struct {
+	Type  string        `json:"type"`
+	Value []interface{} `json:"value"`
+}
+
+// encodeMap returns the JSON-representable form of a map with the given keys. The
+// value of each key is provided by the value function.
+func encodeMap(keys []ID, value func(id ID) interface{}) encodedValue {
+	SortIDs(keys)
+
+	pairs := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, []interface{}{key, value(key)})
+	}
+	return encodedValue{Type: "map", Value: pairs}
+}
+
+// Position is a zero-indexed line and character offset in a document.
+type Position = codeinteltypes.Position
+
+// Range is a pair of start and end positions.
+type Range = codeinteltypes.Range
+
+// ResultSetData holds the results attached to a range or a result set. Empty
+// identifiers denote a missing result.
+type ResultSetData struct {
+	DefinitionResultID     ID    `json:"definitionResultId,omitempty"`
+	ReferenceResultID      ID    `json:"referenceResultId,omitempty"`
+	ImplementationResultID ID    `json:"implementationResultId,omitempty"`
+	HoverResultID          ID    `json:"hoverResultId,omitempty"`
+	MonikerIDs             IDSet `json:"monikerIds"`
+}
+
+// SymbolTagData describes the symbol declared or defined at a range.
+type SymbolTagData struct {
+	Text      string `json:"text"`
+	Kind      int    `json:"kind"`
+	FullRange Range  `json:"fullRange"`
+}
+
+// RangeData holds the position of a range within a document along with its
+// (flattened) results.
+type RangeData struct {
+	StartLine      int `json:"startLine"`
+	StartCharacter int `json:"startCharacter"`
+	EndLine        int `json:"endLine"`
+	EndCharacter   int `json:"endCharacter"`
+	ResultSetData
+	Tag *SymbolTagData `json:"tag,omitempty"`
+}
+
+// MonikerData holds the data of a moniker vertex.
+type MonikerData = codeinteltypes.MonikerData
+
+// PackageInformationData holds the data of a package information vertex.
+type PackageInformationData = codeinteltypes.PackageInformationData
+
+// DiagnosticData holds a single diagnostic reported by an indexer.
+type DiagnosticData struct {
+	Severity       *int
+	Code           *string
+	Message        string
+	Source         *string
+	StartLine      int
+	StartCharacter int
+	EndLine        int
+	EndCharacter   int
+}
+
+// DocumentData is the data stored for a single document of a dump.
+type DocumentData struct {
+	Ranges             map[ID]RangeData
+	HoverResults       map[ID]string
+	Monikers           map[ID]MonikerData
+	PackageInformation map[ID]PackageInformationData
+}
+
+// MarshalJSON encodes the document in the format expected by the bundle manager.
+func (d DocumentData) MarshalJSON() ([]byte, error) {
+	rangeIDs := make([]ID, 0, len(d.Ranges))
+	for id := range d.Ranges {
+		rangeIDs = append(rangeIDs, id)
+	}
+	hoverResultIDs := make([]ID, 0, len(d.HoverResults))
+	for id := range d.HoverResults {
+		hoverResultIDs = append(hoverResultIDs, id)
+	}
+	monikerIDs := make([]ID, 0, len(d.Monikers))
+	for id := range d.Monikers {
+		monikerIDs = append(monikerIDs, id)
+	}
+	packageInformationIDs := make([]ID, 0, len(d.PackageInformation))
+	for id := range d.PackageInformation {
+		packageInformationIDs = append(packageInformationIDs, id)
+	}
+
+	return json.Marshal(struct {
+		Ranges             encodedValue `json:"ranges"`
+		HoverResults       encodedValue `json:"hoverResults"`
+		Monikers           encodedValue `json:"monikers"`
+		PackageInformation encodedValue `json:"packageInformation"`
+	}{
+		Ranges:             encodeMap(rangeIDs, func(id ID) interface{} { return d.Ranges[id] }),
+		HoverResults:       encodeMap(hoverResultIDs, func(id ID) interface{} { return d.HoverResults[id] }),
+		Monikers:           encodeMap(monikerIDs, func(id ID) interface{} { return d.Monikers[id] }),
+		PackageInformation: encodeMap(packageInformationIDs, func(id ID) interface{} { return d.PackageInformation[id] }),
+	})
+}
+
+// DocumentIDRangeID is a pair of document and range identifiers.
+type DocumentIDRangeID struct {
+	DocumentID ID `json:"documentId"`
+	RangeID    ID `json:"rangeId"`
+}
+
+// ResultChunkData is the data stored for a single result chunk of a dump. A result
+// chunk holds the ranges of a subset of the definition and reference results.
+type ResultChunkData struct {
+	DocumentPaths      map[ID]string
+	DocumentIDRangeIDs map[ID][]DocumentIDRangeID
+}
+
+// MarshalJSON encodes the result chunk in the format expected by the bundle manager.
+func (c ResultChunkData) MarshalJSON() ([]byte, error) {
+	documentIDs := make([]ID, 0, len(c.DocumentPaths))
+	for id := range c.DocumentPaths {
+		documentIDs = append(documentIDs, id)
+	}
+	resultIDs := make([]ID, 0, len(c.DocumentIDRangeIDs))
+	for id := range c.DocumentIDRangeIDs {
+		resultIDs = append(resultIDs, id)
+	}
+
+	return json.Marshal(struct {
+		DocumentPaths      encodedValue `json:"documentPaths"`
+		DocumentIDRangeIDs encodedValue `json:"documentIdRangeIds"`
+	}{
+		DocumentPaths:      encodeMap(documentIDs, func(id ID) interface{} { return c.DocumentPaths[id] }),
+		DocumentIDRangeIDs: encodeMap(resultIDs, func(id ID) interface{} { return c.DocumentIDRangeIDs[id] }),
+	})
+}
+
+// Location is a range within a document that is associated with a moniker.
+type Location struct {
+	Scheme         string
+	Identifier     string
+	DocumentPath   string
+	StartLine      int
+	StartCharacter int
+	EndLine        int
+	EndCharacter   int
+}
+
+// Package describes a package provided by or depended on by a dump.
+type Package struct {
+	Scheme  string
+	Name    string
+	Version *string
+}
+
+// PackageReference describes the identifiers a dump imports from a package.
+type PackageReference struct {
+	Package     Package
+	Identifiers []string
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPClientOptions configures the connection pooling and timeouts of the client used
+// to send requests to the bundle manager.
+type HTTPClientOptions struct {
+	// MaxConnsPerHost is the maximum number of concurrent connections to a single host.
+	MaxConnsPerHost int
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections to a single host
+	// kept open for reuse.
+	MaxIdleConnsPerHost int
+
+	// DialTimeout is the maximum time to wait for a connection to be established.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout is the maximum time to wait for a TLS handshake.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the maximum time to wait for the response headers once
+	// the request (including its body) has been written.
+	ResponseHeaderTimeout time.Duration
+}
+
+// NewHTTPClient creates a client with a dedicated transport configured by the given
+// options. Unlike http.DefaultClient, requests that cannot connect or do not receive a
+// response in time fail instead of blocking the worker. No overall timeout is set, as
+// streaming a large upload or bundle can take arbitrarily long.
+func NewHTTPClient(opts HTTPClientOptions) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   opts.DialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          opts.MaxIdleConnsPerHost,
+			MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       opts.MaxConnsPerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
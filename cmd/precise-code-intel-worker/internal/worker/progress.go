@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"math"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/db"
+)
+
+// The steps of the conversion of an upload. These must match the LsifUploadStage type of
+// the TypeScript services.
+const (
+	stageDownloading  = "downloading"
+	stageCorrelating  = "correlating"
+	stageWriting      = "writing"
+	stageDependencies = "dependencies"
+	stageUploading    = "uploading"
+)
+
+// stageRanges is the range of the overall progress (as a percentage) covered by each
+// conversion step. This must match STAGE_RANGES in worker/conversion/progress.ts.
+var stageRanges = map[string][2]int{
+	stageDownloading:  {0, 5},
+	stageCorrelating:  {5, 55},
+	stageWriting:      {55, 90},
+	stageDependencies: {90, 95},
+	stageUploading:    {95, 100},
+}
+
+// conversionProgress tracks the progress of the conversion of an upload and records it as it
+// advances. Records are throttled so that a step that advances quickly does not issue a write
+// for every reported fraction. A failure to record progress is logged and does not fail the
+// conversion.
+type conversionProgress struct {
+	record   func(stage string, progress int) error
+	interval time.Duration
+	now      func() time.Time
+
+	lastStage      string
+	lastProgress   int
+	lastRecordedAt time.Time
+}
+
+// newProgress creates a conversionProgress that records the progress of the given upload
+// outside of the conversion transaction, so that it is visible while the upload is being
+// converted.
+func (w *Worker) newProgress(ctx context.Context, id int) *conversionProgress {
+	return &conversionProgress{
+		record: func(stage string, progress int) error {
+			return db.UpdateProgress(ctx, w.DB, id, stage, progress)
+		},
+		interval: w.ProgressInterval,
+		now:      time.Now,
+	}
+}
+
+// report records that the given fraction (between 0 and 1) of a conversion step has completed.
+// A progress of a step that has already been recorded is only recorded if it has advanced and
+// the interval has passed since the last record. A nil progress records nothing.
+func (p *conversionProgress) report(stage string, fraction float64) {
+	if p == nil {
+		return
+	}
+
+	bounds := stageRanges[stage]
+	progress := bounds[0] + int(math.Floor(float64(bounds[1]-bounds[0])*math.Max(0, math.Min(1, fraction))))
+	now := p.now()
+
+	if stage == p.lastStage && (progress <= p.lastProgress || now.Sub(p.lastRecordedAt) < p.interval) {
+		return
+	}
+
+	p.lastStage = stage
+	p.lastProgress = progress
+	p.lastRecordedAt = now
+
+	if err := p.record(stage, progress); err != nil {
+		log15.Warn("Failed to record conversion progress", "stage", stage, "progress", progress, "error", err)
+	}
+}
+
+// progressReader reports the fraction of a file of known size that has been read from it.
+type progressReader struct {
+	r        io.Reader
+	size     int64
+	read     int64
+	progress func(fraction float64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.size > 0 {
+		r.progress(float64(r.read) / float64(r.size))
+	} else {
+		r.progress(1)
+	}
+
+	return n, err
+}
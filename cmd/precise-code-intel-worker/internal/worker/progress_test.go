@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConversionProgress(t *testing.T) {
+	type record struct {
+		stage    string
+		progress int
+	}
+
+	var records []record
+	now := time.Unix(0, 0)
+
+	progress := &conversionProgress{
+		record: func(stage string, progress int) error {
+			records = append(records, record{stage, progress})
+			return nil
+		},
+		interval: time.Second,
+		now:      func() time.Time { return now },
+	}
+
+	progress.report(stageDownloading, 0)
+	progress.report(stageCorrelating, 0)
+	now = now.Add(500 * time.Millisecond)
+	progress.report(stageCorrelating, 0.5) // within interval
+	now = now.Add(time.Second)
+	progress.report(stageCorrelating, 0.5)
+	now = now.Add(1500 * time.Millisecond)
+	progress.report(stageCorrelating, 0.5) // did not advance
+	progress.report(stageWriting, 1)
+
+	expected := []record{
+		{stageDownloading, 0},
+		{stageCorrelating, 5},
+		{stageCorrelating, 30},
+		{stageWriting, 90},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("unexpected records. want=%v have=%v", expected, records)
+	}
+}
+
+func TestConversionProgressRecordError(t *testing.T) {
+	progress := &conversionProgress{
+		record:   func(stage string, progress int) error { return errors.New("oops") },
+		interval: time.Second,
+		now:      time.Now,
+	}
+
+	// Must not panic
+	progress.report(stageDownloading, 0)
+
+	var nilProgress *conversionProgress
+	nilProgress.report(stageDownloading, 0)
+}
+
+func TestProgressReader(t *testing.T) {
+	var fractions []float64
+	r := &progressReader{
+		r:        bytes.NewReader(make([]byte, 10)),
+		size:     10,
+		progress: func(fraction float64) { fractions = append(fractions, fraction) },
+	}
+
+	buf := make([]byte, 4)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Read(buf); err != nil {
+			t.Fatalf("unexpected error reading: %s", err)
+		}
+	}
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatalf("unexpected error reading: %s", err)
+	}
+
+	if expected := []float64{0.4, 0.8, 1, 1}; !reflect.DeepEqual(fractions, expected) {
+		t.Errorf("unexpected fractions. want=%v have=%v", expected, fractions)
+	}
+}
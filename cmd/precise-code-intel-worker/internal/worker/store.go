@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/bloomfilter"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/conversion"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/db"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/types"
+)
+
+// maxTraversalLimit is the maximum number of commits traversed when determining which
+// dumps are visible from the tip of the default branch.
+const maxTraversalLimit = 100
+
+// advisoryLockIDSalt is the salt of the advisory lock identifiers. This must match the
+// ADVISORY_LOCK_ID_SALT constant of the TypeScript services so that both take the same locks.
+const advisoryLockIDSalt = 1688730858
+
+// acquireTransactionLock acquires the Postgres advisory lock with the given name. The lock
+// is released when the transaction ends.
+func acquireTransactionLock(ctx context.Context, tx db.Execer, name string) error {
+	return db.Exec(ctx, tx, sqlf.Sprintf("SELECT pg_advisory_xact_lock(%s)", lockID(name)))
+}
+
+// lockID returns the advisory lock identifier of the given name as created by the TypeScript
+// services: the signed CRC-32 of the name multiplied by the salt. The product is computed in
+// floating point and sent to Postgres in its shortest decimal representation, so the same
+// is done here.
+func lockID(name string) int64 {
+	product := float64(int32(crc32.ChecksumIEEE([]byte(name)))) * advisoryLockIDSalt
+
+	id, err := strconv.ParseInt(strconv.FormatFloat(product, 'f', -1, 64), 10, 64)
+	if err != nil {
+		// The magnitude of the product is below 2^62, so this cannot happen
+		panic(err)
+	}
+
+	return id
+}
+
+// deleteOverlappingDumps deletes existing dumps from the same repository, commit, and
+// indexer that overlap with the given root (where the existing root is a prefix of the
+// given root, or vice versa). The deletion is recorded in the audit log of each removed
+// upload.
+func deleteOverlappingDumps(ctx context.Context, tx db.Execer, upload db.Upload) error {
+	return db.Exec(ctx, tx, sqlf.Sprintf(`
+		WITH deleted AS (
+			DELETE FROM lsif_uploads
+			WHERE repository_id = %s AND "commit" = %s AND indexer = %s AND state = 'completed'
+			AND (%s LIKE (root || '%%') OR root LIKE (%s || '%%'))
+			RETURNING id
+		)
+		INSERT INTO lsif_upload_events (upload_id, event, source, message)
+		SELECT id, 'purged', 'worker', %s FROM deleted
+	`, upload.RepositoryID, upload.Commit, upload.Indexer, upload.Root, upload.Root, "Replaced by an overlapping upload"))
+}
+
+// addPackages inserts the packages provided by the given dump.
+func addPackages(ctx context.Context, tx db.Execer, dumpID int, packages []types.Package) error {
+	if len(packages) == 0 {
+		return nil
+	}
+
+	var values []*sqlf.Query
+	for _, pkg := range packages {
+		values = append(values, sqlf.Sprintf("(%s, %s, %s, %s)", pkg.Scheme, pkg.Name, pkg.Version, dumpID))
+	}
+
+	return db.Exec(ctx, tx, sqlf.Sprintf(
+		`INSERT INTO lsif_packages (scheme, name, version, dump_id) VALUES %s ON CONFLICT DO NOTHING`,
+		sqlf.Join(values, ","),
+	))
+}
+
+// addReferences inserts the packages depended on by the given dump along with a bloom
+// filter of the identifiers imported from each package.
+func addReferences(ctx context.Context, tx db.Execer, dumpID int, references []types.PackageReference) error {
+	if len(references) == 0 {
+		return nil
+	}
+
+	var values []*sqlf.Query
+	for _, reference := range references {
+		filter, err := bloomfilter.CreateFilter(reference.Identifiers)
+		if err != nil {
+			return err
+		}
+
+		pkg := reference.Package
+		values = append(values, sqlf.Sprintf("(%s, %s, %s, %s, %s)", pkg.Scheme, pkg.Name, pkg.Version, filter, dumpID))
+	}
+
+	return db.Exec(ctx, tx, sqlf.Sprintf(
+		`INSERT INTO lsif_references (scheme, name, version, filter, dump_id) VALUES %s`,
+		sqlf.Join(values, ","),
+	))
+}
+
+// addStatistics inserts the document statistics of the given dump. The number of files
+// in the dump root is nil if the files could not be listed.
+func addStatistics(ctx context.Context, tx db.Execer, dumpID int, statistics conversion.DocumentStatistics, numFilesInRoot *int) error {
+	documentsByLanguage, err := json.Marshal(statistics.DocumentsByLanguage)
+	if err != nil {
+		return err
+	}
+
+	return db.Exec(ctx, tx, sqlf.Sprintf(`
+		INSERT INTO lsif_dump_statistics (dump_id, num_documents, num_ranges, num_files_in_root, documents_by_language)
+		VALUES (%s, %s, %s, %s, %s)
+	`, dumpID, statistics.NumDocuments, statistics.NumRanges, numFilesInRoot, string(documentsByLanguage)))
+}
+
+// hasCommit determines if the commit graph of the given repository includes the given commit.
+func hasCommit(ctx context.Context, tx db.Execer, repositoryID int, commit string) (bool, error) {
+	q := sqlf.Sprintf(`SELECT EXISTS (SELECT 1 FROM lsif_commits WHERE repository_id = %s AND "commit" = %s)`, repositoryID, commit)
+
+	var exists bool
+	err := tx.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&exists)
+	return exists, err
+}
+
+// updateCommits inserts the given commit parentage data for a repository. The input
+// is a map from commits to their parent commits. Commits without a parent should have
+// an empty list of parents, but should still be present in the map.
+func updateCommits(ctx context.Context, tx db.Execer, repositoryID int, commits map[string][]string) error {
+	var values []*sqlf.Query
+	for commit, parentCommits := range commits {
+		if len(parentCommits) == 0 {
+			values = append(values, sqlf.Sprintf("(%s, %s, NULL)", repositoryID, commit))
+		}
+
+		for _, parentCommit := range parentCommits {
+			values = append(values, sqlf.Sprintf("(%s, %s, %s)", repositoryID, commit, parentCommit))
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return db.Exec(ctx, tx, sqlf.Sprintf(
+		`INSERT INTO lsif_commits (repository_id, "commit", parent_commit) VALUES %s ON CONFLICT DO NOTHING`,
+		sqlf.Join(values, ","),
+	))
+}
+
+// ancestorLineage is a recursive CTE `lineage` that returns ancestors of the commit for
+// the given repository. This assumes that the repository identifier and commit are the
+// first two arguments of the query.
+const ancestorLineage = `
+	RECURSIVE lineage(id, "commit", parent, repository_id) AS (
+		SELECT c.* FROM lsif_commits c WHERE c.repository_id = %s AND c."commit" = %s
+		UNION
+		SELECT c.* FROM lineage a JOIN lsif_commits c ON a.repository_id = c.repository_id AND a.parent = c."commit"
+	)
+`
+
+// visibleDumps is a set of CTE definitions assuming the definition of a previous CTE
+// named `lineage`. This creates the CTE `visible_ids`, which gathers the set of dump
+// identifiers whose commit occurs in `lineage` (within the traversal limit) and whose
+// root does not overlap another visible dump from the same indexer.
+const visibleDumps = `
+	limited_lineage AS (
+		SELECT a.*, row_number() OVER() as n from lineage a LIMIT %s
+	),
+	lineage_with_dumps AS (
+		SELECT a.*, d.root, d.indexer, d.id as dump_id FROM limited_lineage a
+		JOIN lsif_dumps d ON d.repository_id = a.repository_id AND d."commit" = a."commit"
+		WHERE NOT d.excluded
+	),
+	visible_ids AS (
+		SELECT DISTINCT t1.dump_id as id FROM lineage_with_dumps t1 WHERE NOT EXISTS (
+			SELECT 1 FROM lineage_with_dumps t2
+			WHERE t2.n < t1.n AND t1.indexer = t2.indexer AND (
+				t2.root LIKE (t1.root || '%%') OR
+				t1.root LIKE (t2.root || '%%')
+			)
+		)
+	)
+`
+
+// updateDumpsVisibleFromTip determines the set of dumps that are visible from the given
+// commit and sets their visible_at_tip flag. The flag is unset for each invisible dump
+// of the repository.
+func updateDumpsVisibleFromTip(ctx context.Context, tx db.Execer, repositoryID int, tipCommit string) error {
+	return db.Exec(ctx, tx, sqlf.Sprintf(`
+		WITH `+ancestorLineage+`, `+visibleDumps+`
+		UPDATE lsif_dumps d
+		SET visible_at_tip = id IN (SELECT * from visible_ids)
+		WHERE d.repository_id = %s AND (d.id IN (SELECT * from visible_ids) OR d.visible_at_tip)
+	`, repositoryID, tipCommit, maxTraversalLimit, repositoryID))
+}
+
+// markSupersededDumps marks the dumps for the same repository, root, and indexer at an
+// ancestor of the given upload's commit as superseded by the given upload. Dumps visible
+// at the tip of the default branch are never marked. If markForDeletion is set, the
+// superseded dumps are also marked for deletion. They can be restored until they are
+// purged by the janitor once the restore window has passed.
+func markSupersededDumps(ctx context.Context, tx db.Execer, upload db.Upload, markForDeletion bool) error {
+	return db.Exec(ctx, tx, sqlf.Sprintf(`
+		WITH `+ancestorLineage+`,
+		superseded AS (
+			UPDATE lsif_uploads u
+			SET
+				superseded_by = %s,
+				state = CASE WHEN %s THEN 'deleting' ELSE u.state END,
+				state_before_delete = CASE WHEN %s THEN u.state ELSE u.state_before_delete END,
+				deleted_at = CASE WHEN %s THEN now() ELSE u.deleted_at END
+			WHERE u.id IN (
+				SELECT d.id FROM lineage l
+				JOIN lsif_dumps d ON d.repository_id = l.repository_id AND d."commit" = l."commit"
+				WHERE d.root = %s AND d.indexer = %s AND d.id != %s AND NOT d.visible_at_tip AND d.superseded_by IS NULL
+			)
+			RETURNING u.id
+		)
+		INSERT INTO lsif_upload_events (upload_id, event, state, source, message)
+		SELECT id, 'deleted', 'deleting', 'worker', %s FROM superseded WHERE %s
+	`,
+		upload.RepositoryID, upload.Commit,
+		upload.ID, markForDeletion, markForDeletion, markForDeletion,
+		upload.Root, upload.Indexer, upload.ID,
+		fmt.Sprintf("Superseded by upload %d", upload.ID), markForDeletion,
+	))
+}
@@ -0,0 +1,15 @@
+package worker
+
+import "testing"
+
+func TestLockID(t *testing.T) {
+	// Identifiers created by createLockId of the TypeScript services
+	for name, expected := range map[string]int64{
+		"visibility-1": -907511726545402900,
+		"x":            -3262177756413524000,
+	} {
+		if id := lockID(name); id != expected {
+			t.Errorf("unexpected lock id for %q. want=%d have=%d", name, expected, id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/db"
+)
+
+// startConversionSpan starts the span that covers the conversion of the given upload. The
+// span context serialized into the upload's tracing context when it was enqueued by the
+// api-server is extracted so that the conversion appears in the same trace as the upload
+// request. An empty or malformed tracing context starts a new trace.
+func startConversionSpan(ctx context.Context, tracer opentracing.Tracer, upload db.Upload) (opentracing.Span, context.Context) {
+	opts := []opentracing.StartSpanOption{
+		opentracing.Tag{Key: "uploadID", Value: upload.ID},
+		opentracing.Tag{Key: "repositoryID", Value: upload.RepositoryID},
+		opentracing.Tag{Key: "commit", Value: upload.Commit},
+		opentracing.Tag{Key: "root", Value: upload.Root},
+	}
+
+	if publisher, ok := extractTracingContext(tracer, upload.TracingContext); ok {
+		opts = append(opts, opentracing.FollowsFrom(publisher))
+	}
+
+	span := tracer.StartSpan("Upload selected for conversion", opts...)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// extractTracingContext deserializes the span context stored in the tracing_context column
+// of an upload, which is a JSON-encoded text map.
+func extractTracingContext(tracer opentracing.Tracer, tracingContext string) (opentracing.SpanContext, bool) {
+	carrier := opentracing.TextMapCarrier{}
+	if err := json.Unmarshal([]byte(tracingContext), &carrier); err != nil || len(carrier) == 0 {
+		return nil, false
+	}
+
+	spanContext, err := tracer.Extract(opentracing.TextMap, carrier)
+	if err != nil {
+		return nil, false
+	}
+
+	return spanContext, true
+}
+
+// finishSpan records the given error (if any) on the span and finishes it.
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+	}
+
+	span.Finish()
+}
+
+// injectTracingHeaders adds the headers that propagate the span of the given context to
+// the bundle manager, so that the spans it creates are parented to the conversion.
+func injectTracingHeaders(ctx context.Context, tracer opentracing.Tracer, req *http.Request) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		_ = tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	}
+}
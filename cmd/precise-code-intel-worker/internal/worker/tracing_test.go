@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/db"
+)
+
+func TestStartConversionSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	publisher := tracer.StartSpan("Enqueue upload")
+
+	carrier := opentracing.TextMapCarrier{}
+	if err := tracer.Inject(publisher.Context(), opentracing.TextMap, carrier); err != nil {
+		t.Fatalf("unexpected error injecting span context: %s", err)
+	}
+	tracingContext, err := json.Marshal(carrier)
+	if err != nil {
+		t.Fatalf("unexpected error marshalling tracing context: %s", err)
+	}
+
+	span, ctx := startConversionSpan(context.Background(), tracer, db.Upload{ID: 42, TracingContext: string(tracingContext)})
+	span.Finish()
+
+	if opentracing.SpanFromContext(ctx) != span {
+		t.Errorf("expected span to be attached to context")
+	}
+
+	finished := tracer.FinishedSpans()
+	if len(finished) != 1 {
+		t.Fatalf("unexpected number of finished spans. want=%d have=%d", 1, len(finished))
+	}
+	if finished[0].ParentID != publisher.Context().(mocktracer.MockSpanContext).SpanID {
+		t.Errorf("expected conversion span to follow from the enqueue span")
+	}
+	if finished[0].Tag("uploadID") != 42 {
+		t.Errorf("unexpected uploadID tag. want=%d have=%v", 42, finished[0].Tag("uploadID"))
+	}
+}
+
+func TestStartConversionSpanEmptyTracingContext(t *testing.T) {
+	for _, tracingContext := range []string{"{}", "", "malformed"} {
+		tracer := mocktracer.New()
+		span, _ := startConversionSpan(context.Background(), tracer, db.Upload{ID: 42, TracingContext: tracingContext})
+		span.Finish()
+
+		if parentID := tracer.FinishedSpans()[0].ParentID; parentID != 0 {
+			t.Errorf("expected new trace for tracing context %q. have parent=%d", tracingContext, parentID)
+		}
+	}
+}
@@ -0,0 +1,542 @@
+// Package worker dequeues LSIF uploads and converts them into SQLite bundles.
+package worker
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/lib/pq"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/conversion"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/db"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/shards"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/sqlite"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// UploadQueuedChannel is the Postgres notification channel on which the identifier of an
+// upload is sent when the upload is inserted or requeued.
+const UploadQueuedChannel = "lsif_upload_queued"
+
+// Worker polls Postgres for queued uploads and converts them one at a time.
+type Worker struct {
+	// DB is the Postgres database containing the lsif_uploads table.
+	DB *sql.DB
+
+	// BundleManagers assigns each upload to the precise-code-intel-bundle-manager shard
+	// that stores its raw upload and bundle.
+	BundleManagers *shards.Ring
+
+	// HTTPClient is the client used to send requests to the bundle manager. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// InternalAPIToken is the shared secret sent as a bearer token with each request to
+	// the bundle manager. No token is sent if empty.
+	InternalAPIToken string
+
+	// StorageRoot is the directory in which raw uploads and bundles are temporarily stored.
+	StorageRoot string
+
+	// ID identifies this worker process in the uploads it dequeues.
+	ID string
+
+	// PollInterval is the time to wait between polls when no upload is queued.
+	PollInterval time.Duration
+
+	// HeartbeatInterval is the time between heartbeats recorded for the upload being
+	// converted. No heartbeats are recorded if zero.
+	HeartbeatInterval time.Duration
+
+	// ProgressInterval is the minimum time between two records of the progress of the
+	// same conversion step. Progress is always recorded when a new step begins.
+	ProgressInterval time.Duration
+
+	// Listener receives the notifications sent on UploadQueuedChannel. A notification
+	// wakes an idle worker before its poll interval has elapsed. If nil, the worker
+	// only polls.
+	Listener *pq.Listener
+
+	// DeleteSupersededDumps controls whether dumps superseded by a converted upload are
+	// also marked for deletion.
+	DeleteSupersededDumps bool
+
+	// PayloadEncoding is the encoding of the document and result chunk payloads of the
+	// bundles written by the worker. Defaults to gzip-json if empty.
+	PayloadEncoding sqlite.PayloadEncoding
+
+	// Tracer is used to trace the conversion of each upload. If nil, the global tracer
+	// is used.
+	Tracer opentracing.Tracer
+}
+
+func (w *Worker) tracer() opentracing.Tracer {
+	if w.Tracer != nil {
+		return w.Tracer
+	}
+	return opentracing.GlobalTracer()
+}
+
+// Start polls for queued uploads until the context is canceled.
+func (w *Worker) Start(ctx context.Context) {
+	for {
+		ok, err := w.dequeueAndProcess(ctx)
+		if err != nil {
+			log15.Error("Failed to dequeue upload", "error", err)
+		}
+
+		if ok && err == nil {
+			// Immediately poll again if we converted an upload
+			continue
+		}
+
+		if !w.waitForUploads(ctx) {
+			return
+		}
+	}
+}
+
+// waitForUploads blocks until an upload may have been queued: either a notification is
+// received by the listener or the poll interval elapses. Returns false if the context is
+// canceled first.
+func (w *Worker) waitForUploads(ctx context.Context) bool {
+	var notifications <-chan *pq.Notification
+	if w.Listener != nil {
+		notifications = w.Listener.Notify
+	}
+
+	select {
+	case <-notifications:
+		// A nil notification is sent once the listener reconnects, as notifications may
+		// have been lost while disconnected. Either way, the next poll dequeues any upload
+		// announced by notifications that are still buffered, so these are discarded.
+		drain(notifications)
+
+	case <-time.After(w.PollInterval):
+		if w.Listener != nil {
+			// The listener cannot detect a silently dropped connection on its own
+			if err := w.Listener.Ping(); err != nil {
+				log15.Warn("Failed to ping notification listener", "error", err)
+			}
+		}
+
+	case <-ctx.Done():
+		return false
+	}
+
+	return true
+}
+
+// drain discards the notifications buffered in the given channel.
+func drain(notifications <-chan *pq.Notification) {
+	for {
+		select {
+		case <-notifications:
+		default:
+			return
+		}
+	}
+}
+
+// dequeueAndProcess selects the next queued upload and converts it. The upload row stays
+// locked in a transaction for the duration of the conversion. If the conversion fails, all
+// changes made during the conversion are discarded and the upload is marked as errored. A
+// bundle already sent to the bundle manager by a conversion that does not commit is removed
+// again. Returns false if there were no queued uploads.
+func (w *Worker) dequeueAndProcess(ctx context.Context) (bool, error) {
+	upload, tx, ok, err := db.Dequeue(ctx, w.DB, w.ID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	heartbeatCtx, stopHeartbeats := context.WithCancel(ctx)
+	heartbeatsDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatsDone)
+		w.sendHeartbeats(heartbeatCtx, upload.ID)
+	}()
+	defer func() {
+		stopHeartbeats()
+		<-heartbeatsDone
+	}()
+
+	log15.Debug("Selected upload to convert", "uploadID", upload.ID)
+
+	uploaded, completed, err := w.processLocked(ctx, tx, upload)
+	err = db.Done(tx, err)
+
+	if uploaded && (!completed || err != nil) {
+		// The bundle is not reachable from a completed upload. If it cannot be removed
+		// now, it is removed by the bundle manager's janitor as a dead bundle.
+		if removeErr := w.removeBundle(ctx, upload.ID); removeErr != nil {
+			log15.Warn("Failed to remove bundle of failed conversion", "uploadID", upload.ID, "error", removeErr)
+		}
+	}
+
+	return true, err
+}
+
+// processLocked converts the given upload within the transaction that holds its lock. If the
+// conversion fails, all changes made during the conversion are discarded and the upload is
+// marked as errored. Returns whether a bundle was sent to the bundle manager and whether the
+// upload was marked as complete.
+func (w *Worker) processLocked(ctx context.Context, tx *sql.Tx, upload db.Upload) (uploaded, completed bool, err error) {
+	span, ctx := startConversionSpan(ctx, w.tracer(), upload)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT conversion"); err != nil {
+		finishSpan(span, err)
+		return false, false, err
+	}
+
+	uploaded, processErr := w.process(ctx, tx, upload, w.newProgress(ctx, upload.ID))
+	finishSpan(span, processErr)
+
+	if processErr != nil {
+		if errors.Cause(processErr) == db.ErrNotProcessing {
+			log15.Info("Discarding conversion of upload that is no longer processing", "uploadID", upload.ID)
+		} else {
+			log15.Error("Failed to convert upload", "uploadID", upload.ID, "error", processErr)
+		}
+
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT conversion"); err != nil {
+			return uploaded, false, err
+		}
+
+		return uploaded, false, db.MarkErrored(ctx, tx, upload.ID, processErr.Error(), fmt.Sprintf("%+v", processErr))
+	}
+
+	log15.Info("Converted upload", "repositoryID", upload.RepositoryID, "commit", upload.Commit, "root", upload.Root)
+	return uploaded, true, nil
+}
+
+// sendHeartbeats records a heartbeat for the given upload every heartbeat interval until
+// the context is canceled. Heartbeats are sent on a separate connection from the one that
+// converts the upload, so that they are visible to the janitor before the conversion commits.
+func (w *Worker) sendHeartbeats(ctx context.Context, id int) {
+	if w.HeartbeatInterval <= 0 {
+		return
+	}
+
+	for {
+		select {
+		case <-time.After(w.HeartbeatInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		ok, err := db.Heartbeat(ctx, w.DB, id, w.ID)
+		if err != nil {
+			if ctx.Err() == nil {
+				log15.Warn("Failed to record heartbeat", "uploadID", id, "error", err)
+			}
+			continue
+		}
+		if !ok {
+			// The conversion has finished (or the upload was reset) before this heartbeat
+			log15.Debug("Upload is no longer processed by this worker", "uploadID", id, "workerID", w.ID)
+			return
+		}
+	}
+}
+
+// process converts the raw upload into a bundle, populates the cross-dump package data,
+// and sends the bundle to the bundle manager. The progress of each step is reported to the
+// given progress. Returns true if the bundle has been sent to the bundle manager, even if a
+// later step fails.
+func (w *Worker) process(ctx context.Context, tx *sql.Tx, upload db.Upload, progress *conversionProgress) (uploaded bool, err error) {
+	name, err := ioutil.TempDir(w.StorageRoot, "upload-")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(name); err == nil {
+			err = removeErr
+		}
+	}()
+
+	sourcePath := filepath.Join(name, "upload.lsif.gz")
+	targetPath := filepath.Join(name, "bundle.sqlite")
+
+	progress.report(stageDownloading, 0)
+	if err := w.download(ctx, upload, sourcePath); err != nil {
+		return false, errors.Wrap(err, "downloading raw upload")
+	}
+
+	result, err := w.convert(ctx, upload, sourcePath, targetPath, progress)
+	if err != nil {
+		return false, errors.Wrap(err, "converting upload")
+	}
+
+	// Add packages and references to Postgres
+	progress.report(stageDependencies, 0)
+	if err := addPackages(ctx, tx, upload.ID, result.Packages); err != nil {
+		return false, errors.Wrap(err, "inserting packages")
+	}
+	if err := addReferences(ctx, tx, upload.ID, result.References); err != nil {
+		return false, errors.Wrap(err, "inserting references")
+	}
+
+	// Add document statistics to Postgres
+	numFilesInRoot := countFilesInRoot(ctx, upload, result.Statistics.DocumentsByLanguage)
+	if err := addStatistics(ctx, tx, upload.ID, result.Statistics, numFilesInRoot); err != nil {
+		return false, errors.Wrap(err, "inserting statistics")
+	}
+
+	// db.Upload the bundle where it can be found by the api-server. This is the last progress
+	// recorded, as the upload row is updated within the transaction from here on, after which
+	// recording its progress would block until the transaction commits.
+	progress.report(stageUploading, 0)
+	if err := w.upload(ctx, upload.ID, targetPath); err != nil {
+		return false, errors.Wrap(err, "uploading bundle")
+	}
+
+	// Remove overlapping dumps that would cause a unique index error once this upload has
+	// transitioned into the completed state. As this is done in a transaction, we do not
+	// delete the files on disk right away. These files will be cleaned up by the bundle
+	// manager in a future cleanup task.
+	if err := deleteOverlappingDumps(ctx, tx, upload); err != nil {
+		return true, errors.Wrap(err, "deleting overlapping dumps")
+	}
+
+	// Update the conversion state before updating the commit graph, as the next step
+	// assumes that the processed upload is present in the dumps view. The remainder of
+	// the task may still fail, in which case the conversion is rolled back.
+	if err := db.MarkComplete(ctx, tx, upload.ID); err != nil {
+		return true, errors.Wrap(err, "marking upload as complete")
+	}
+
+	if err := updateCommitsAndDumpsVisibleFromTip(ctx, tx, upload); err != nil {
+		return true, errors.Wrap(err, "updating commits and visibility")
+	}
+
+	// Mark older dumps for the same root and indexer as superseded by this dump. These
+	// dumps will be pruned before any other dump once the disk is under pressure, unless
+	// they are marked for deletion right away.
+	if err := markSupersededDumps(ctx, tx, upload, w.DeleteSupersededDumps); err != nil {
+		return true, errors.Wrap(err, "marking superseded dumps")
+	}
+
+	return true, nil
+}
+
+// convert decompresses the raw upload and writes the bundle to the target path. The progress
+// of correlating the upload is the fraction of the compressed file read so far.
+func (w *Worker) convert(ctx context.Context, upload db.Upload, sourcePath, targetPath string, progress *conversionProgress) (*conversion.Result, error) {
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	progress.report(stageCorrelating, 0)
+	r, err := gzip.NewReader(&progressReader{
+		r:        f,
+		size:     fi.Size(),
+		progress: func(fraction float64) { progress.report(stageCorrelating, fraction) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	directoryChildren := func(ctx context.Context, dirnames []string) (map[string][]string, error) {
+		return gitserver.DirectoryChildren(ctx, upload.RepositoryID, upload.Commit, dirnames)
+	}
+
+	payloadEncoding := w.PayloadEncoding
+	if payloadEncoding == "" {
+		payloadEncoding = sqlite.PayloadEncodingGzipJSON
+	}
+
+	onProgress := func(fraction float64) { progress.report(stageWriting, fraction) }
+	return conversion.Convert(ctx, r, upload.Root, targetPath, payloadEncoding, directoryChildren, onProgress)
+}
+
+// countFilesInRoot counts the number of files tracked by git within the root of the given
+// upload that are written in one of the languages of the dump's documents. This is used to
+// determine the percentage of relevant files covered by the dump, so files the indexer cannot
+// cover (e.g. images or files of other languages) are not counted. Returns nil if the files
+// cannot be listed, as this should not cause the conversion to fail.
+func countFilesInRoot(ctx context.Context, upload db.Upload, documentsByLanguage map[string]int) *int {
+	files, err := gitserver.TrackedFiles(ctx, upload.RepositoryID, upload.Commit, upload.Root)
+	if err != nil {
+		log15.Warn("Failed to list files in dump root", "uploadID", upload.ID, "error", err)
+		return nil
+	}
+
+	numFiles := 0
+	for _, file := range files {
+		if _, ok := documentsByLanguage[conversion.LanguageFromPath(file)]; ok {
+			numFiles++
+		}
+	}
+	return &numFiles
+}
+
+// updateCommitsAndDumpsVisibleFromTip updates the known commits of the upload's repository
+// starting from both the upload's commit and the tip of the default branch, then updates the
+// visible_at_tip flag of the dumps of the repository. The update holds the same advisory lock
+// as the TypeScript services, so that concurrent updates of a repository are serialized.
+func updateCommitsAndDumpsVisibleFromTip(ctx context.Context, tx *sql.Tx, upload db.Upload) error {
+	if err := acquireTransactionLock(ctx, tx, fmt.Sprintf("visibility-%d", upload.RepositoryID)); err != nil {
+		return err
+	}
+
+	tipCommit, err := gitserver.Head(ctx, upload.RepositoryID)
+	if err != nil {
+		return err
+	}
+	if tipCommit == "" {
+		return errors.New("no tip commit available for repository")
+	}
+
+	commits := map[string][]string{}
+	for _, commit := range []string{upload.Commit, tipCommit} {
+		// If the tip is ahead of this commit, we also want to discover all of the commits
+		// between this commit and the tip so that we can accurately determine what is
+		// visible from the tip.
+		known, err := hasCommit(ctx, tx, upload.RepositoryID, commit)
+		if err != nil {
+			return err
+		}
+		if known {
+			continue
+		}
+
+		near, err := gitserver.CommitsNear(ctx, upload.RepositoryID, commit)
+		if err != nil {
+			return err
+		}
+		for commit, parents := range near {
+			commits[commit] = append(commits[commit], parents...)
+		}
+	}
+
+	if err := updateCommits(ctx, tx, upload.RepositoryID, commits); err != nil {
+		return err
+	}
+
+	return updateDumpsVisibleFromTip(ctx, tx, upload.RepositoryID, tipCommit)
+}
+
+// download writes the raw upload stored by the bundle manager to the given path. If a
+// checksum was recorded for the upload, the downloaded payload must match it so that an
+// upload corrupted in transit or on disk is not converted.
+func (w *Worker) download(ctx context.Context, upload db.Upload, filename string) error {
+	req, err := w.newRequest(ctx, upload.ID, "GET", fmt.Sprintf("/uploads/%d", upload.ID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ctxhttp.Do(ctx, w.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), resp.Body); err != nil {
+		return err
+	}
+
+	if checksum := hex.EncodeToString(hash.Sum(nil)); upload.Checksum != "" && checksum != upload.Checksum {
+		return fmt.Errorf("checksum mismatch: expected %s, received %s", upload.Checksum, checksum)
+	}
+
+	return nil
+}
+
+// upload sends the bundle at the given path to the bundle manager.
+func (w *Worker) upload(ctx context.Context, uploadID int, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// A previous attempt to convert this upload may have already written a bundle, which
+	// is replaced
+	req, err := w.newRequest(ctx, uploadID, "POST", fmt.Sprintf("/dbs/%d?force=true", uploadID), f)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := ctxhttp.Do(ctx, w.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// removeBundle removes the bundle of the given upload from the bundle manager.
+func (w *Worker) removeBundle(ctx context.Context, uploadID int) error {
+	req, err := w.newRequest(ctx, uploadID, "DELETE", fmt.Sprintf("/dbs/%d", uploadID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ctxhttp.Do(ctx, w.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// newRequest creates a request to the given path of the bundle manager shard of the given
+// upload that carries the internal API token, if one is configured, and the span of the
+// given context.
+func (w *Worker) newRequest(ctx context.Context, uploadID int, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, w.BundleManagers.ShardFor(uploadID)+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	injectTracingHeaders(ctx, w.tracer(), req)
+
+	if w.InternalAPIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.InternalAPIToken)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/shards"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+)
+
+func TestWaitForUploadsNotification(t *testing.T) {
+	notifications := make(chan *pq.Notification, 4)
+	notifications <- &pq.Notification{Channel: UploadQueuedChannel, Extra: "1"}
+	notifications <- &pq.Notification{Channel: UploadQueuedChannel, Extra: "2"}
+	notifications <- nil
+
+	w := &Worker{PollInterval: time.Hour, Listener: &pq.Listener{Notify: notifications}}
+	if !w.waitForUploads(context.Background()) {
+		t.Fatalf("expected wait to succeed")
+	}
+	if len(notifications) != 0 {
+		t.Errorf("expected buffered notifications to be drained, %d remain", len(notifications))
+	}
+}
+
+func TestWaitForUploadsPollInterval(t *testing.T) {
+	w := &Worker{PollInterval: time.Millisecond}
+	if !w.waitForUploads(context.Background()) {
+		t.Fatalf("expected wait to succeed")
+	}
+}
+
+func TestWaitForUploadsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := &Worker{PollInterval: time.Hour, Listener: &pq.Listener{Notify: make(chan *pq.Notification)}}
+	if w.waitForUploads(ctx) {
+		t.Fatalf("expected wait to be canceled")
+	}
+}
+
+func TestRemoveBundle(t *testing.T) {
+	var method, path, authorization string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, authorization = r.Method, r.URL.Path, r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	w := &Worker{BundleManagers: newTestRing(t, ts.URL), InternalAPIToken: "secret"}
+	if err := w.removeBundle(context.Background(), 42); err != nil {
+		t.Fatalf("unexpected error removing bundle: %s", err)
+	}
+	if method != "DELETE" || path != "/dbs/42" {
+		t.Errorf("unexpected request. want=%s %s have=%s %s", "DELETE", "/dbs/42", method, path)
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("unexpected authorization header. want=%q have=%q", "Bearer secret", authorization)
+	}
+}
+
+func TestUploadReplacesBundle(t *testing.T) {
+	var method, uri string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, uri = r.Method, r.URL.RequestURI()
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	filename := filepath.Join(tempDir, "bundle.sqlite")
+	if err := ioutil.WriteFile(filename, []byte("bundle"), 0644); err != nil {
+		t.Fatalf("unexpected error writing bundle: %s", err)
+	}
+
+	w := &Worker{BundleManagers: newTestRing(t, ts.URL)}
+	if err := w.upload(context.Background(), 42, filename); err != nil {
+		t.Fatalf("unexpected error uploading bundle: %s", err)
+	}
+	if method != "POST" || uri != "/dbs/42?force=true" {
+		t.Errorf("unexpected request. want=%s %s have=%s %s", "POST", "/dbs/42?force=true", method, uri)
+	}
+	if string(body) != "bundle" {
+		t.Errorf("unexpected body. want=%q have=%q", "bundle", body)
+	}
+}
+
+func TestSendHeartbeats(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+
+	if _, err := dbconn.Global.Exec(`
+		INSERT INTO lsif_uploads (id, repository_id, "commit", indexer, tracing_context, state, worker_id, last_heartbeat_at)
+		VALUES (1, 50, $1, 'lsif-go', '{}', 'processing', 'worker-1', now() - interval '1 hour')
+	`, strings.Repeat("a", 40)); err != nil {
+		t.Fatalf("unexpected error inserting upload: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := &Worker{DB: dbconn.Global, ID: "worker-1", HeartbeatInterval: time.Millisecond}
+		w.sendHeartbeats(ctx, 1)
+	}()
+
+	for recorded := false; !recorded; time.Sleep(time.Millisecond) {
+		if err := dbconn.Global.QueryRow(`
+			SELECT last_heartbeat_at > now() - interval '1 minute' FROM lsif_uploads WHERE id = 1
+		`).Scan(&recorded); err != nil {
+			t.Fatalf("unexpected error reading upload: %s", err)
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSendHeartbeatsStopsForOtherWorker(t *testing.T) {
+	dbtesting.SetupGlobalTestDB(t)
+
+	if _, err := dbconn.Global.Exec(`
+		INSERT INTO lsif_uploads (id, repository_id, "commit", indexer, tracing_context, state, worker_id)
+		VALUES (1, 50, $1, 'lsif-go', '{}', 'processing', 'worker-2')
+	`, strings.Repeat("a", 40)); err != nil {
+		t.Fatalf("unexpected error inserting upload: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := &Worker{DB: dbconn.Global, ID: "worker-1", HeartbeatInterval: time.Millisecond}
+		w.sendHeartbeats(context.Background(), 1)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected heartbeats to stop for an upload processed by another worker")
+	}
+}
+
+func newTestRing(t *testing.T, url string) *shards.Ring {
+	ring, err := shards.NewRing([]string{url})
+	if err != nil {
+		t.Fatalf("unexpected error creating ring: %s", err)
+	}
+	return ring
+}
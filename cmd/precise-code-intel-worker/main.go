@@ -0,0 +1,160 @@
+// Command precise-code-intel-worker is a service that converts queued LSIF uploads
+// into the SQLite bundles served by the precise-code-intel-bundle-manager.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/lib/pq"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/shards"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/sqlite"
+	"github.com/sourcegraph/sourcegraph/cmd/precise-code-intel-worker/internal/worker"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/internal/debugserver"
+	"github.com/sourcegraph/sourcegraph/internal/env"
+	"github.com/sourcegraph/sourcegraph/internal/tracer"
+)
+
+func main() {
+	var (
+		bundleManagerURL  = env.Get("PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL", "http://precise-code-intel-bundle-manager:3187", "HTTP address for internal precise code intel bundle manager server")
+		bundleManagerURLs = env.Get("PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS", "", "comma-separated HTTP addresses of the precise code intel bundle manager shards (defaults to PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL)")
+		internalAPIToken  = env.Get("PRECISE_CODE_INTEL_INTERNAL_API_TOKEN", "", "shared secret sent as a bearer token to the precise code intel bundle manager (authentication is disabled if empty)")
+		storageRoot       = env.Get("LSIF_STORAGE_ROOT", "lsif-storage", "directory to temporarily store LSIF uploads and SQLite files")
+		pollInterval      = env.Get("POLLING_INTERVAL", "10s", "interval between polls of the database for unconverted uploads when no upload has been announced by a notification")
+		heartbeatInterval = env.Get("HEARTBEAT_INTERVAL", "5s", "interval between heartbeats recorded for the upload being converted")
+		progressInterval  = env.Get("PROGRESS_UPDATE_INTERVAL", "1s", "minimum interval between two records of the progress of the same conversion step")
+		deleteSuperseded  = env.Get("DELETE_SUPERSEDED_DUMPS", "true", "mark dumps superseded by a newly converted dump for deletion")
+		payloadEncoding   = env.Get("BUNDLE_PAYLOAD_ENCODING", "gzip-json", "encoding of the document and result chunk payloads of converted bundles (gzip-json or gzip-binary)")
+
+		maxConnsPerHost       = env.Get("BUNDLE_MANAGER_MAX_CONNECTIONS_PER_HOST", "64", "maximum number of concurrent connections to the bundle manager")
+		maxIdleConnsPerHost   = env.Get("BUNDLE_MANAGER_MAX_IDLE_CONNECTIONS_PER_HOST", "16", "maximum number of idle connections to the bundle manager kept open for reuse")
+		connectTimeout        = env.Get("BUNDLE_MANAGER_CONNECT_TIMEOUT", "5s", "maximum time to wait for a connection to the bundle manager")
+		responseHeaderTimeout = env.Get("BUNDLE_MANAGER_RESPONSE_TIMEOUT", "2m", "maximum time to wait for the response headers of the bundle manager once a request has been sent")
+	)
+
+	env.Lock()
+	env.HandleHelpFlag()
+	log.SetFlags(0)
+	tracer.Init()
+
+	interval, err := time.ParseDuration(pollInterval)
+	if err != nil {
+		log.Fatalf("Invalid POLLING_INTERVAL: %s", err)
+	}
+
+	encoding, err := sqlite.ParsePayloadEncoding(payloadEncoding)
+	if err != nil {
+		log.Fatalf("Invalid BUNDLE_PAYLOAD_ENCODING: %s", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Failed to determine hostname: %s", err)
+	}
+
+	httpClientOptions := worker.HTTPClientOptions{
+		MaxConnsPerHost:       mustParseInt("BUNDLE_MANAGER_MAX_CONNECTIONS_PER_HOST", maxConnsPerHost),
+		MaxIdleConnsPerHost:   mustParseInt("BUNDLE_MANAGER_MAX_IDLE_CONNECTIONS_PER_HOST", maxIdleConnsPerHost),
+		DialTimeout:           mustParseDuration("BUNDLE_MANAGER_CONNECT_TIMEOUT", connectTimeout),
+		TLSHandshakeTimeout:   mustParseDuration("BUNDLE_MANAGER_CONNECT_TIMEOUT", connectTimeout),
+		ResponseHeaderTimeout: mustParseDuration("BUNDLE_MANAGER_RESPONSE_TIMEOUT", responseHeaderTimeout),
+	}
+
+	// Bundles must be sent to the shard from which the api-server will query them
+	if bundleManagerURLs == "" {
+		bundleManagerURLs = bundleManagerURL
+	}
+	bundleManagers, err := shards.NewRing(shards.ParseURLs(bundleManagerURLs))
+	if err != nil {
+		log.Fatalf("Invalid PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS: %s", err)
+	}
+
+	if err := os.MkdirAll(storageRoot, os.ModePerm); err != nil {
+		log.Fatalf("Failed to create LSIF_STORAGE_ROOT: %s", err)
+	}
+
+	if err := dbconn.ConnectToDB(""); err != nil {
+		log.Fatalf("Failed to connect to database: %s", err)
+	}
+
+	listener := dbconn.NewListener("", func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log15.Error("Notification listener connection event", "event", event, "error", err)
+		}
+	})
+	defer listener.Close()
+
+	// A failure here is not fatal, as the worker also polls for queued uploads
+	if err := listener.Listen(worker.UploadQueuedChannel); err != nil {
+		log15.Error("Failed to listen for queued uploads", "error", err)
+	}
+
+	go debugserver.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancelOnSignal(cancel)
+
+	w := &worker.Worker{
+		DB:                dbconn.Global,
+		BundleManagers:    bundleManagers,
+		HTTPClient:        worker.NewHTTPClient(httpClientOptions),
+		InternalAPIToken:  internalAPIToken,
+		StorageRoot:       storageRoot,
+		ID:                fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		PollInterval:      interval,
+		HeartbeatInterval: mustParseDuration("HEARTBEAT_INTERVAL", heartbeatInterval),
+		ProgressInterval:  mustParseDuration("PROGRESS_UPDATE_INTERVAL", progressInterval),
+		Listener:          listener,
+
+		DeleteSupersededDumps: mustParseBool("DELETE_SUPERSEDED_DUMPS", deleteSuperseded),
+		PayloadEncoding:       encoding,
+	}
+
+	log15.Info("precise-code-intel-worker: polling for uploads")
+	w.Start(ctx)
+}
+
+// mustParseInt parses the value of the given environment variable as an integer.
+func mustParseInt(name, value string) int {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %s", name, err)
+	}
+	return i
+}
+
+// mustParseBool parses the value of the given environment variable as a boolean.
+func mustParseBool(name, value string) bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %s", name, err)
+	}
+	return b
+}
+
+// mustParseDuration parses the value of the given environment variable as a duration.
+func mustParseDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %s", name, err)
+	}
+	return d
+}
+
+// cancelOnSignal cancels the worker context on SIGINT or SIGTERM. The upload being
+// converted at that time is rolled back and will be picked up again by another worker.
+func cancelOnSignal(cancel context.CancelFunc) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	cancel()
+}
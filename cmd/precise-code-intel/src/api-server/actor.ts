@@ -0,0 +1,15 @@
+import express from 'express'
+import { UploadEventOrigin } from '../shared/store/uploads'
+
+/** The header set by the frontend identifying the user on whose behalf a request is made. */
+export const ACTOR_HEADER = 'X-Sourcegraph-Actor'
+
+/**
+ * Determine the origin of the upload events caused by an API request.
+ *
+ * @param req The express request.
+ */
+export const originFromRequest = (req: express.Request): UploadEventOrigin => ({
+    source: 'api',
+    actor: req.header(ACTOR_HEADER) || undefined,
+})
@@ -14,9 +14,20 @@ import { UploadManager } from '../shared/store/uploads'
 import { waitForConfiguration } from '../shared/config/config'
 import { DumpManager } from '../shared/store/dumps'
 import { DependencyManager } from '../shared/store/dependencies'
-import { SRC_FRONTEND_INTERNAL } from '../shared/config/settings'
+import { READINESS_CHECK_TIMEOUT, SRC_FRONTEND_INTERNAL } from '../shared/config/settings'
 import { startExpressApp } from '../shared/api/init'
 import { createInternalRouter } from './routes/internal'
+import { createEventRouter } from './routes/events'
+import { createAdminRouter } from './routes/admin'
+import { createDependencyRouter } from './routes/dependencies'
+import { createDumpRouter } from './routes/dumps'
+import { createCoverageRouter } from './routes/coverage'
+import { createJanitorRouter } from '../shared/api/janitor'
+import { QueryEventLog } from './events'
+import { QueryResultCache } from './backend/cache'
+import { checkPeer, checkPostgres, checkWritableDirectory, createReadinessRouter } from '../shared/api/readiness'
+import { closeServer, onShutdown } from '../shared/shutdown'
+import { NotificationListener, UPLOAD_STATE_CHANNEL } from '../shared/database/notifications'
 
 /**
  * Runs the HTTP server that accepts LSIF dump uploads and responds to LSIF requests.
@@ -41,32 +52,87 @@ async function main(logger: Logger): Promise<void> {
     const dumpManager = new DumpManager(connection)
     const uploadManager = new UploadManager(connection)
     const dependencyManager = new DependencyManager(connection)
-    const backend = new Backend(dumpManager, dependencyManager, SRC_FRONTEND_INTERNAL)
+    const resultCache = new QueryResultCache(settings.QUERY_RESULT_CACHE_CAPACITY, settings.QUERY_RESULT_CACHE_TTL)
+    const backend = new Backend(
+        dumpManager,
+        dependencyManager,
+        SRC_FRONTEND_INTERNAL,
+        undefined,
+        undefined,
+        resultCache
+    )
+    const eventLog = new QueryEventLog(settings.QUERY_EVENT_LOG_SIZE)
+
+    // Listen for upload state changes to stream to clients waiting for conversions
+    const uploadStateListener = new NotificationListener(connection, UPLOAD_STATE_CHANNEL, logger)
+    await uploadStateListener.start()
 
     // Start background tasks
-    startTasks(connection, uploadManager, logger)
+    const taskRunner = startTasks(connection, dumpManager, uploadManager, resultCache, logger)
 
     const routers = [
-        createUploadRouter(dumpManager, uploadManager, logger),
-        createLsifRouter(connection, backend, uploadManager, logger, tracer),
-        createInternalRouter(dumpManager, uploadManager, logger),
+        createUploadRouter(connection, dumpManager, uploadManager, resultCache, uploadStateListener, logger),
+        createLsifRouter(connection, backend, uploadManager, eventLog, logger, tracer),
+        createInternalRouter(connection, dumpManager, uploadManager, resultCache, logger),
+        createEventRouter(dumpManager, eventLog),
+        createDependencyRouter(dumpManager, dependencyManager),
+        createDumpRouter(dumpManager, logger),
+        createCoverageRouter(dumpManager, logger),
+        createAdminRouter(uploadManager, logger),
+        createJanitorRouter(taskRunner),
+        createReadinessRouter(
+            [
+                checkPostgres(connection),
+                checkWritableDirectory('storage', settings.STORAGE_ROOT),
+                ...settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS.map(url => checkPeer(`bundle-manager ${url}`, url)),
+            ],
+            READINESS_CHECK_TIMEOUT * 1000
+        ),
     ]
 
     // Start server
-    startExpressApp({ port: settings.HTTP_PORT, routers, logger, tracer, selectHistogram })
+    const server = startExpressApp({
+        port: settings.HTTP_PORT,
+        routers,
+        logger,
+        tracer,
+        selectHistogram,
+        selectTimeout,
+    })
+
+    // Drain in-flight requests and tasks before exiting. Stopping the listener ends the
+    // upload watch streams, which would otherwise hold the server open.
+    onShutdown(logger, settings.SHUTDOWN_TIMEOUT * 1000, async () => {
+        await Promise.all([uploadStateListener.stop(), closeServer(server), taskRunner.stop()])
+        await connection.close()
+    })
 }
 
-function selectHistogram(route: string): promClient.Histogram<string> | undefined {
-    switch (route) {
-        case '/upload':
-            return metrics.httpUploadDurationHistogram
+/** The routes that query the bundles of one or more dumps. */
+const queryRoutes = [
+    '/exists',
+    '/definitions',
+    '/references',
+    '/moniker/locations',
+    '/implementations',
+    '/hover',
+    '/hovers',
+    '/position',
+    '/ranges',
+    '/diagnostics',
+    '/symbols',
+]
 
-        case '/exists':
-        case '/request':
-            return metrics.httpQueryDurationHistogram
+function selectHistogram(route: string): promClient.Histogram<string> | undefined {
+    if (route === '/upload') {
+        return metrics.httpUploadDurationHistogram
     }
 
-    return undefined
+    return queryRoutes.includes(route) ? metrics.httpQueryDurationHistogram : undefined
+}
+
+function selectTimeout(route: string): number | undefined {
+    return queryRoutes.includes(route) ? settings.QUERY_REQUEST_TIMEOUT * 1000 : undefined
 }
 
 // Initialize logger
@@ -1,12 +1,11 @@
 import * as sinon from 'sinon'
 import * as lsif from 'lsif-protocol'
 import * as pgModels from '../../shared/models/pg'
-import { Backend, sortMonikers } from './backend'
-import { DependencyManager } from '../../shared/store/dependencies'
-import { DumpManager } from '../../shared/store/dumps'
+import { Backend, compareDumps, isRepositoryAllowed, sortMonikers } from './backend'
+import { DependencyStore } from '../../shared/store/dependencies'
+import { DumpStore } from '../../shared/store/dumps'
 import { Database } from './database'
-import { createCleanPostgresDatabase } from '../../shared/test-util'
-import { Connection } from 'typeorm'
+import { createMockDependencyStore, createMockDumpStore } from '../../shared/test-util'
 import { OrderedLocationSet, ResolvedInternalLocation } from './location'
 import { ReferencePaginationCursor } from './cursor'
 import { range } from 'lodash'
@@ -25,6 +24,14 @@ const zeroUpload: pgModels.LsifUpload = {
     failureStacktrace: null,
     tracingContext: '',
     visibleAtTip: false,
+    supersededBy: null,
+    pinned: false,
+    excluded: false,
+    checksum: null,
+    attempts: 0,
+    lastRetriedAt: null,
+    deletedAt: null,
+    stateBeforeDelete: null,
 }
 
 const zeroDump: pgModels.LsifDump = {
@@ -64,35 +71,62 @@ const createTestDatabase = (dbs: Map<pgModels.DumpId, Database>) => (dumpId: pgM
 }
 
 describe('Backend', () => {
-    let connection!: Connection
-    let cleanup!: () => Promise<void>
-    let dumpManager!: DumpManager
-    let dependencyManager!: DependencyManager
-
-    beforeAll(async () => {
-        ;({ connection, cleanup } = await createCleanPostgresDatabase())
-    })
-
-    afterAll(async () => {
-        if (cleanup) {
-            await cleanup()
-        }
-    })
+    let dumpStore!: DumpStore
+    let dependencyStore!: DependencyStore
 
     beforeEach(() => {
-        dumpManager = new DumpManager(connection)
-        dependencyManager = new DependencyManager(connection)
+        dumpStore = createMockDumpStore()
+        dependencyStore = createMockDependencyStore()
     })
 
     describe('exists', () => {
         it('should return closest dumps with file', async () => {
+            // Commit graph traversal
+            sinon.stub(dumpStore, 'findClosestDumps').resolves([
+                { ...zeroDump, id: 1 },
+                { ...zeroDump, id: 2 },
+                { ...zeroDump, id: 3 },
+                { ...zeroDump, id: 4 },
+            ])
+
+            // Batched path existence check
+            const spy = sinon.stub().resolves([true, false, false, true])
+
+            const dumps = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(
+                    new Map([
+                        [1, new Database(1)],
+                        [2, new Database(2)],
+                        [3, new Database(3)],
+                        [4, new Database(4)],
+                    ])
+                ),
+                spy
+            ).exists(42, 'deadbeef', '/foo/bar/baz.ts')
+
+            expect(dumps).toEqual([
+                { ...zeroDump, id: 1 },
+                { ...zeroDump, id: 4 },
+            ])
+            expect(spy.args[0][0]).toEqual([
+                { dumpId: 1, path: '/foo/bar/baz.ts' },
+                { dumpId: 2, path: '/foo/bar/baz.ts' },
+                { dumpId: 3, path: '/foo/bar/baz.ts' },
+                { dumpId: 4, path: '/foo/bar/baz.ts' },
+            ])
+        })
+
+        it('should check each dump if batched checks are unsupported', async () => {
             const database1 = new Database(1)
             const database2 = new Database(2)
             const database3 = new Database(3)
             const database4 = new Database(4)
 
             // Commit graph traversal
-            sinon.stub(dumpManager, 'findClosestDumps').resolves([
+            sinon.stub(dumpStore, 'findClosestDumps').resolves([
                 { ...zeroDump, id: 1 },
                 { ...zeroDump, id: 2 },
                 { ...zeroDump, id: 3 },
@@ -106,8 +140,8 @@ describe('Backend', () => {
             const spy4 = sinon.stub(database4, 'exists').resolves(true)
 
             const dumps = await new Backend(
-                dumpManager,
-                dependencyManager,
+                dumpStore,
+                dependencyStore,
                 '',
                 createTestDatabase(
                     new Map([
@@ -116,7 +150,8 @@ describe('Backend', () => {
                         [3, database3],
                         [4, database4],
                     ])
-                )
+                ),
+                () => Promise.resolve(undefined)
             ).exists(42, 'deadbeef', '/foo/bar/baz.ts')
 
             expect(dumps).toEqual([
@@ -130,15 +165,75 @@ describe('Backend', () => {
         })
     })
 
+    describe('existsMany', () => {
+        it('should check each path against the dumps whose root contains it', async () => {
+            // Commit graph traversal
+            const stub = sinon.stub(dumpStore, 'findClosestDumps').resolves([
+                { ...zeroDump, id: 1, root: '' },
+                { ...zeroDump, id: 2, root: 'web/' },
+            ])
+
+            // Batched path existence check
+            const spy = sinon.stub().resolves([false, true, true, false])
+
+            const results = await new Backend(dumpStore, dependencyStore, '', undefined, spy).existsMany(
+                42,
+                'deadbeef',
+                ['web/a.ts', 'b.ts', 'c.ts']
+            )
+
+            expect(results).toEqual(
+                new Map([
+                    ['web/a.ts', true],
+                    ['b.ts', true],
+                    ['c.ts', false],
+                ])
+            )
+            expect(stub.args[0].slice(0, 3)).toEqual([42, 'deadbeef', undefined])
+            expect(spy.args[0][0]).toEqual([
+                { dumpId: 1, path: 'web/a.ts' },
+                { dumpId: 2, path: 'a.ts' },
+                { dumpId: 1, path: 'b.ts' },
+                { dumpId: 1, path: 'c.ts' },
+            ])
+        })
+
+        it('should check each path individually if batched checks are unsupported', async () => {
+            const database1 = new Database(1)
+
+            // Commit graph traversal
+            sinon.stub(dumpStore, 'findClosestDumps').resolves([{ ...zeroDump, id: 1, root: '' }])
+
+            // Path existence check
+            const spy = sinon.stub(database1, 'exists').callsFake(path => Promise.resolve(path === 'b.ts'))
+
+            const results = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(new Map([[1, database1]])),
+                () => Promise.resolve(undefined)
+            ).existsMany(42, 'deadbeef', ['a.ts', 'b.ts'])
+
+            expect(results).toEqual(
+                new Map([
+                    ['a.ts', false],
+                    ['b.ts', true],
+                ])
+            )
+            expect(spy.args.map(args => args[0])).toEqual(['a.ts', 'b.ts'])
+        })
+    })
+
     describe('definitions', () => {
         it('should return definitions from database', async () => {
             const database1 = new Database(1)
 
             // Loading source dump
-            sinon.stub(dumpManager, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
 
             // Resolving target dumps
-            sinon.stub(dumpManager, 'getDumpsByIds').resolves(
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
                 new Map([
                     [1, { ...zeroDump, id: 1 }],
                     [2, { ...zeroDump, id: 2 }],
@@ -156,8 +251,8 @@ describe('Backend', () => {
             ])
 
             const locations = await new Backend(
-                dumpManager,
-                dependencyManager,
+                dumpStore,
+                dependencyStore,
                 '',
                 createTestDatabase(new Map([[1, database1]]))
             ).definitions(42, 'deadbeef', '/foo/bar/baz.ts', { line: 5, character: 10 }, 1)
@@ -174,10 +269,10 @@ describe('Backend', () => {
             const database1 = new Database(1)
 
             // Loading source dump
-            sinon.stub(dumpManager, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
 
             // Resolving target dumps
-            sinon.stub(dumpManager, 'getDumpsByIds').resolves(
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
                 new Map([
                     [1, { ...zeroDump, id: 1 }],
                     [2, { ...zeroDump, id: 2 }],
@@ -192,6 +287,9 @@ describe('Backend', () => {
             // Moniker resolution
             sinon.stub(database1, 'monikersByPosition').resolves([monikersWithPackageInformation])
 
+            // Package resolution (imported monikers are not defined in a known package)
+            sinon.stub(database1, 'packageInformation').resolves(undefined)
+
             // Moniker search
             sinon.stub(database1, 'monikerResults').resolves({
                 locations: [
@@ -204,8 +302,8 @@ describe('Backend', () => {
             })
 
             const locations = await new Backend(
-                dumpManager,
-                dependencyManager,
+                dumpStore,
+                dependencyStore,
                 '',
                 createTestDatabase(new Map([[1, database1]]))
             ).definitions(42, 'deadbeef', '/foo/bar/baz.ts', { line: 5, character: 10 }, 1)
@@ -223,10 +321,10 @@ describe('Backend', () => {
             const database2 = new Database(2)
 
             // Loading source dump
-            sinon.stub(dumpManager, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
 
             // Resolving target dumps
-            sinon.stub(dumpManager, 'getDumpsByIds').resolves(
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
                 new Map([
                     [1, { ...zeroDump, id: 1 }],
                     [2, { ...zeroDump, id: 2 }],
@@ -245,7 +343,7 @@ describe('Backend', () => {
             sinon.stub(database1, 'packageInformation').resolves({ name: 'pkg2', version: '0.0.1' })
 
             // Package resolution
-            sinon.stub(dependencyManager, 'getPackage').resolves({
+            sinon.stub(dependencyStore, 'getPackage').resolves({
                 id: 71,
                 scheme: 'test',
                 name: 'pkg2',
@@ -269,8 +367,8 @@ describe('Backend', () => {
             })
 
             const locations = await new Backend(
-                dumpManager,
-                dependencyManager,
+                dumpStore,
+                dependencyStore,
                 '',
                 createTestDatabase(
                     new Map([
@@ -289,6 +387,41 @@ describe('Backend', () => {
         })
     })
 
+    describe('implementations', () => {
+        it('should return implementations from database', async () => {
+            const database1 = new Database(1)
+
+            // Loading source dump
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+
+            // Resolving target dumps
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
+                new Map([
+                    [1, { ...zeroDump, id: 1 }],
+                    [2, { ...zeroDump, id: 2 }],
+                ])
+            )
+
+            // In-database implementations
+            sinon.stub(database1, 'implementations').resolves([
+                { dumpId: 1, path: '1.ts', range: makeRange(1) },
+                { dumpId: 2, path: '2.ts', range: makeRange(2) },
+            ])
+
+            const locations = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(new Map([[1, database1]]))
+            ).implementations(42, 'deadbeef', '/foo/bar/baz.ts', { line: 5, character: 10 }, 1)
+
+            expect(locations).toEqual([
+                { dump: { ...zeroDump, id: 1 }, path: '1.ts', range: makeRange(1) },
+                { dump: { ...zeroDump, id: 2 }, path: '2.ts', range: makeRange(2) },
+            ])
+        })
+    })
+
     describe('references', () => {
         const queryAllReferences = async (
             backend: Backend,
@@ -336,7 +469,7 @@ describe('Backend', () => {
             locationsPerDump: number,
             pageLimit: number,
             remoteDumpLimit: number
-        ): Promise<void> => {
+        ): Promise<number[]> => {
             const numDatabases = 2 + numSameRepoDumps + numRemoteRepoDumps
             const numLocations = numDatabases * locationsPerDump
 
@@ -381,7 +514,7 @@ describe('Backend', () => {
             }
 
             // Loading source dump
-            sinon.stub(dumpManager, 'getDumpById').callsFake(id => {
+            sinon.stub(dumpStore, 'getDumpById').callsFake(id => {
                 if (id <= dumps.length) {
                     return Promise.resolve(dumps[id - 1])
                 }
@@ -390,14 +523,14 @@ describe('Backend', () => {
             })
 
             // Resolving target dumps
-            sinon.stub(dumpManager, 'getDumpsByIds').resolves(dumpMap)
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(dumpMap)
 
             // Package resolution
-            sinon.stub(dependencyManager, 'getPackage').resolves(definitionPackage)
+            sinon.stub(dependencyStore, 'getPackage').resolves(definitionPackage)
 
             // Same-repo package references
             const sameRepoStub = sinon
-                .stub(dependencyManager, 'getSameRepoRemotePackageReferences')
+                .stub(dependencyStore, 'getSameRepoRemotePackageReferences')
                 .callsFake(({ limit, offset }) =>
                     Promise.resolve({
                         packageReferences: sameRepoDumps.slice(offset, offset + limit),
@@ -408,7 +541,7 @@ describe('Backend', () => {
 
             // Remote repo package references
             const remoteRepoStub = sinon
-                .stub(dependencyManager, 'getPackageReferences')
+                .stub(dependencyStore, 'getPackageReferences')
                 .callsFake(({ limit, offset }) =>
                     Promise.resolve({
                         packageReferences: remoteRepoDumps.slice(offset, offset + limit),
@@ -424,7 +557,14 @@ describe('Backend', () => {
             sinon.stub(databases[0], 'packageInformation').resolves({ name: 'pkg2', version: '0.0.1' })
 
             // Same dump results
-            const referenceStub = sinon.stub(databases[0], 'references').resolves(new OrderedLocationSet(getChunk(0)))
+            const referenceStub = sinon
+                .stub(databases[0], 'references')
+                .callsFake((path, position, { skip = 0, take = locationsPerDump }) =>
+                    Promise.resolve({
+                        locations: new OrderedLocationSet(getChunk(0).slice(skip, skip + take)),
+                        count: locationsPerDump,
+                    })
+                )
 
             const monikerStubs: sinon.SinonStub<
                 Parameters<Database['monikerResults']>,
@@ -434,21 +574,28 @@ describe('Backend', () => {
             // Local moniker results
             sinon.stub(databases[0], 'monikerResults').resolves({ locations: [], count: 0 })
 
-            // Remote dump results
+            // Remote dump results. Later dumps respond first so that results are not returned in
+            // the order in which the concurrent requests complete.
+            const yieldTimes = async (n: number): Promise<void> => {
+                for (let i = 0; i < n; i++) {
+                    await Promise.resolve()
+                }
+            }
+
             for (let i = 1; i < numDatabases; i++) {
                 monikerStubs.push(
-                    sinon.stub(databases[i], 'monikerResults').callsFake((model, moniker, { skip = 0, take = 10 }) =>
-                        Promise.resolve({
-                            locations: getChunk(i).slice(skip, skip + take),
-                            count: locationsPerDump,
+                    sinon
+                        .stub(databases[i], 'monikerResults')
+                        .callsFake(async (model, moniker, { skip = 0, take = 10 }) => {
+                            await yieldTimes(((numDatabases - i) % 3) * 2)
+                            return { locations: getChunk(i).slice(skip, skip + take), count: locationsPerDump }
                         })
-                    )
                 )
             }
 
             // Read all reference pages
             const { locations: resolvedLocations, pageSizes } = await queryAllReferences(
-                new Backend(dumpManager, dependencyManager, '', createTestDatabase(databaseMap)),
+                new Backend(dumpStore, dependencyStore, '', createTestDatabase(databaseMap)),
                 42,
                 'deadbeef',
                 '/foo/bar/baz.ts',
@@ -471,9 +618,14 @@ describe('Backend', () => {
             expect(sameRepoStub.callCount).toEqual(expectedCalls(numSameRepoDumps, remoteDumpLimit))
             expect(remoteRepoStub.callCount).toEqual(expectedCalls(numRemoteRepoDumps, remoteDumpLimit))
             expect(referenceStub.callCount).toEqual(expectedCalls(locationsPerDump, pageLimit))
-            for (const stub of monikerStubs) {
-                expect(stub.callCount).toEqual(expectedCalls(locationsPerDump, pageLimit))
+            expect(monikerStubs[0].callCount).toEqual(expectedCalls(locationsPerDump, pageLimit))
+            for (const stub of monikerStubs.slice(1)) {
+                // Remote dumps are queried concurrently, so a dump may be queried for a page that
+                // is filled by the dumps preceding it
+                expect(stub.callCount).toBeGreaterThanOrEqual(expectedCalls(locationsPerDump, pageLimit))
             }
+
+            return pageSizes
         }
 
         it('should return references in source and definition dumps', () => assertPagedReferences(0, 0, 1, 10, 5))
@@ -485,6 +637,83 @@ describe('Backend', () => {
         it('should respect large page size', () => assertPagedReferences(25, 25, 25, 1000, 5))
         it('should respect small remote dumps page size', () => assertPagedReferences(25, 25, 25, 10, 1))
         it('should respect large remote dumps page size', () => assertPagedReferences(25, 25, 25, 10, 25))
+
+        // A page is filled from the next phase once a phase is exhausted
+        it('should fill a page from the same dump and the definition dump', async () => {
+            expect((await assertPagedReferences(0, 0, 1, 2, 5))[0]).toEqual(2)
+        })
+
+        it('should fill a page from the definition dump and same-repo dumps', async () => {
+            expect((await assertPagedReferences(2, 0, 1, 3, 1))[0]).toEqual(3)
+        })
+
+        it('should fill a page from same-repo dumps and remote repositories', async () => {
+            expect((await assertPagedReferences(1, 2, 1, 4, 1))[0]).toEqual(4)
+        })
+
+        it('should fill a page from every phase', async () => {
+            expect((await assertPagedReferences(2, 2, 1, 6, 1))[0]).toEqual(6)
+        })
+
+        const assertUniqueReferences = async (
+            definitionDumpId: number,
+            sameRepoDumpIds: number[],
+            expectedDumpIds: number[]
+        ): Promise<void> => {
+            // Dump 1 is the source dump, and the references table of each dump holds its own location
+            const dumps = range(0, 3).map(i => ({ ...zeroDump, id: i + 1 }))
+            const databases = dumps.map(dump => new Database(dump.id))
+            const databaseMap = new Map(dumps.map((dump, i) => [dump.id, databases[i]]))
+            const locations = dumps.map((dump, i) => ({ dumpId: dump.id, path: `${dump.id}.ts`, range: makeRange(i) }))
+
+            sinon.stub(dumpStore, 'getDumpById').callsFake(id => Promise.resolve(dumps[id - 1]))
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(new Map(dumps.map(dump => [dump.id, dump])))
+            sinon.stub(dependencyStore, 'getPackage').resolves({
+                ...zeroPackage,
+                dump: dumps[definitionDumpId - 1],
+                dump_id: definitionDumpId,
+            })
+            sinon.stub(dependencyStore, 'getSameRepoRemotePackageReferences').resolves({
+                packageReferences: sameRepoDumpIds.map(id => ({ ...zeroPackage, dump: dumps[id - 1], dump_id: id })),
+                totalCount: sameRepoDumpIds.length,
+                newOffset: sameRepoDumpIds.length,
+            })
+            sinon.stub(dependencyStore, 'getPackageReferences').resolves({
+                packageReferences: [],
+                totalCount: 0,
+                newOffset: 0,
+            })
+
+            sinon.stub(databases[0], 'monikersByPosition').resolves([monikersWithPackageInformation])
+            sinon.stub(databases[0], 'packageInformation').resolves({ name: 'pkg2', version: '0.0.1' })
+            sinon.stub(databases[0], 'references').resolves({
+                locations: new OrderedLocationSet([locations[0]]),
+                count: 1,
+            })
+            for (const [i, database] of databases.entries()) {
+                sinon.stub(database, 'monikerResults').resolves({ locations: [locations[i]], count: 1 })
+            }
+
+            // Read all reference pages, one location at a time
+            const { locations: resolvedLocations } = await queryAllReferences(
+                new Backend(dumpStore, dependencyStore, '', createTestDatabase(databaseMap)),
+                42,
+                'deadbeef',
+                '/foo/bar/baz.ts',
+                { line: 5, character: 10 },
+                1,
+                1
+            )
+
+            expect(resolvedLocations.map(({ dump }) => dump.id)).toEqual(expectedDumpIds)
+        }
+
+        // Each dump is searched by a single phase, so a location is never returned on two pages
+        it('should not search the source dump again when it defines the package', () =>
+            assertUniqueReferences(1, [2], [1, 2]))
+
+        it('should not search the definition dump again when it references the package', () =>
+            assertUniqueReferences(2, [2, 3], [1, 2, 3]))
     })
 
     describe('hover', () => {
@@ -492,7 +721,7 @@ describe('Backend', () => {
             const database1 = new Database(1)
 
             // Loading source dump
-            sinon.stub(dumpManager, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
 
             // In-database hover
             sinon.stub(database1, 'hover').resolves({
@@ -501,8 +730,8 @@ describe('Backend', () => {
             })
 
             const hover = await new Backend(
-                dumpManager,
-                dependencyManager,
+                dumpStore,
+                dependencyStore,
                 '',
                 createTestDatabase(new Map([[1, database1]]))
             ).hover(42, 'deadbeef', '/foo/bar/baz.ts', { line: 5, character: 10 }, 1)
@@ -518,10 +747,10 @@ describe('Backend', () => {
             const database2 = new Database(2)
 
             // Loading source dump
-            sinon.stub(dumpManager, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
 
             // Resolving target dumps
-            sinon.stub(dumpManager, 'getDumpsByIds').resolves(new Map([[2, { ...zeroDump, id: 2 }]]))
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(new Map([[2, { ...zeroDump, id: 2 }]]))
 
             // In-database hover
             sinon.stub(database1, 'hover').resolves(null)
@@ -542,8 +771,8 @@ describe('Backend', () => {
             })
 
             const hover = await new Backend(
-                dumpManager,
-                dependencyManager,
+                dumpStore,
+                dependencyStore,
                 '',
                 createTestDatabase(
                     new Map([
@@ -555,6 +784,308 @@ describe('Backend', () => {
 
             expect(hover).toEqual({ text: 'hover text', range: makeRange(1) })
         })
+
+        it('should try each definition in order', async () => {
+            const database1 = new Database(1)
+            const database2 = new Database(2)
+            const database3 = new Database(3)
+            const database4 = new Database(4)
+
+            // Loading source dump
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+
+            // Resolving target dumps
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
+                new Map([
+                    [2, { ...zeroDump, id: 2 }],
+                    [3, { ...zeroDump, id: 3 }],
+                    [4, { ...zeroDump, id: 4 }],
+                ])
+            )
+
+            // In-database hover
+            sinon.stub(database1, 'hover').resolves(null)
+
+            // In-database definitions
+            sinon.stub(database1, 'definitions').resolves([
+                { dumpId: 2, path: '2.ts', range: makeRange(2) },
+                { dumpId: 3, path: '3.ts', range: makeRange(3) },
+                { dumpId: 4, path: '4.ts', range: makeRange(4) },
+            ])
+
+            // Remote-database hovers
+            const hover2 = sinon
+                .stub(database2, 'hover')
+                .rejects(Object.assign(new Error('Bundle not found'), { status: 404, code: 'bundle_not_found' }))
+            const hover3 = sinon.stub(database3, 'hover').resolves(null)
+            sinon.stub(database4, 'hover').resolves({ text: 'hover text', range: makeRange(4) })
+
+            const backend = new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(
+                    new Map([
+                        [1, database1],
+                        [2, database2],
+                        [3, database3],
+                        [4, database4],
+                    ])
+                )
+            )
+
+            for (const character of [10, 11]) {
+                const hover = await backend.hover(42, 'deadbeef', '/foo/bar/baz.ts', { line: 5, character }, 1)
+                expect(hover).toEqual({ text: 'hover text', range: makeRange(4) })
+            }
+
+            // Definitions without hover content are not queried again
+            expect(hover2.callCount).toEqual(1)
+            expect(hover3.callCount).toEqual(1)
+        })
+    })
+
+    describe('hovers', () => {
+        it('should fall back to definition hover content for missing positions', async () => {
+            const database1 = new Database(1)
+            const database2 = new Database(2)
+
+            // Loading source dump
+            sinon.stub(dumpStore, 'getDumpById').resolves({ ...zeroDump, id: 1 })
+
+            // Resolving target dumps
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(new Map([[2, { ...zeroDump, id: 2 }]]))
+
+            // In-database hovers
+            sinon.stub(database1, 'hovers').resolves([{ text: 'local hover text', range: makeRange(1) }, null])
+
+            // In-database definitions
+            sinon.stub(database1, 'definitions').resolves([
+                {
+                    dumpId: 2,
+                    path: '2.ts',
+                    range: makeRange(2),
+                },
+            ])
+
+            // Remote-database hover
+            sinon.stub(database2, 'hover').resolves({
+                text: 'remote hover text',
+                range: makeRange(3),
+            })
+
+            const hovers = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(
+                    new Map([
+                        [1, database1],
+                        [2, database2],
+                    ])
+                )
+            ).hovers(
+                42,
+                'deadbeef',
+                '/foo/bar/baz.ts',
+                [
+                    { line: 5, character: 10 },
+                    { line: 6, character: 10 },
+                ],
+                1
+            )
+
+            expect(hovers).toEqual([
+                { text: 'local hover text', range: makeRange(1) },
+                { text: 'remote hover text', range: makeRange(3) },
+            ])
+        })
+    })
+
+    describe('position', () => {
+        it('should return hover content, definitions, and the first page of references', async () => {
+            const database1 = new Database(1)
+            const dump1 = { ...zeroDump, id: 1 }
+            const dump2 = { ...zeroDump, id: 2 }
+
+            // Loading source dump
+            sinon.stub(dumpStore, 'getDumpById').resolves(dump1)
+
+            // Resolving target dumps
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
+                new Map([
+                    [1, dump1],
+                    [2, dump2],
+                ])
+            )
+
+            // In-database hover
+            sinon.stub(database1, 'hover').resolves({ text: 'hover text', range: makeRange(1) })
+
+            // In-database definitions
+            sinon.stub(database1, 'definitions').resolves([{ dumpId: 2, path: '2.ts', range: makeRange(2) }])
+
+            // Moniker resolution
+            sinon.stub(database1, 'monikersByPosition').resolves([])
+
+            // In-database references
+            sinon.stub(database1, 'references').resolves({
+                locations: new OrderedLocationSet(
+                    range(0, 3).map(i => ({ dumpId: 1, path: '1.ts', range: makeRange(i) }))
+                ),
+                count: 3,
+            })
+
+            const result = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(new Map([[1, database1]]))
+            ).position(42, 'deadbeef', '/foo/bar/baz.ts', { line: 5, character: 10 }, { limit: 2 }, undefined, 1)
+
+            expect(result).toEqual({
+                hover: { text: 'hover text', range: makeRange(1) },
+                definitions: [{ dump: dump2, path: '2.ts', range: makeRange(2) }],
+                references: {
+                    locations: [
+                        { dump: dump1, path: '1.ts', range: makeRange(0) },
+                        { dump: dump1, path: '1.ts', range: makeRange(1) },
+                    ],
+                    newCursor: {
+                        phase: 'same-dump',
+                        dumpId: 1,
+                        path: '/foo/bar/baz.ts',
+                        position: { line: 5, character: 10 },
+                        monikers: [],
+                        skipResults: 2,
+                    },
+                },
+            })
+        })
+
+        it('should drop definitions and hover content in repositories that may not be read', async () => {
+            const database1 = new Database(1)
+            const database3 = new Database(3)
+            const dump1 = { ...zeroDump, id: 1, repositoryId: 42 }
+            const dump2 = { ...zeroDump, id: 2, repositoryId: 51 }
+            const dump3 = { ...zeroDump, id: 3, repositoryId: 50 }
+
+            // Loading source dump
+            sinon.stub(dumpStore, 'getDumpById').resolves(dump1)
+
+            // Resolving target dumps
+            sinon.stub(dumpStore, 'getDumpsByIds').resolves(
+                new Map([
+                    [1, dump1],
+                    [2, dump2],
+                    [3, dump3],
+                ])
+            )
+
+            // In-database hover
+            sinon.stub(database1, 'hover').resolves(null)
+
+            // In-database definitions
+            sinon.stub(database1, 'definitions').resolves([
+                { dumpId: 2, path: '2.ts', range: makeRange(2) },
+                { dumpId: 3, path: '3.ts', range: makeRange(3) },
+            ])
+
+            // Moniker resolution
+            sinon.stub(database1, 'monikersByPosition').resolves([])
+
+            // In-database references
+            sinon.stub(database1, 'references').resolves({
+                locations: new OrderedLocationSet(
+                    range(0, 3).map(i => ({ dumpId: 1, path: '1.ts', range: makeRange(i) }))
+                ),
+                count: 3,
+            })
+
+            // Remote-database hover (dump 2 must not be opened)
+            sinon.stub(database3, 'hover').resolves({ text: 'hover text', range: makeRange(3) })
+
+            const result = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(
+                    new Map([
+                        [1, database1],
+                        [3, database3],
+                    ])
+                )
+            ).position(
+                42,
+                'deadbeef',
+                '/foo/bar/baz.ts',
+                { line: 5, character: 10 },
+                { limit: 2, allowedRepositoryIds: new Set([50]) },
+                undefined,
+                1
+            )
+
+            expect(result && result.hover).toEqual({ text: 'hover text', range: makeRange(3) })
+            expect(result && result.definitions).toEqual([{ dump: dump3, path: '3.ts', range: makeRange(3) }])
+        })
+    })
+
+    describe('monikerLocations', () => {
+        it('should query each dump that may contain the moniker', async () => {
+            const database1 = new Database(1)
+            const database2 = new Database(2)
+            const dump1 = { ...zeroDump, id: 1, root: 'a/' }
+            const dump2 = { ...zeroDump, id: 2, root: 'b/' }
+
+            // Candidate dumps
+            const monikerDumpsStub = sinon
+                .stub(dependencyStore, 'getMonikerDumps')
+                .resolves({ dumps: [dump1, dump2], totalCount: 3, newOffset: 2 })
+
+            // Per-dump moniker results
+            sinon.stub(database1, 'monikerResults').resolves({
+                locations: [{ dumpId: 1, path: '1.ts', range: makeRange(1) }],
+                count: 1,
+            })
+            sinon.stub(database2, 'monikerResults').resolves({
+                locations: [
+                    { dumpId: 2, path: '2.ts', range: makeRange(2) },
+                    { dumpId: 2, path: '2.ts', range: makeRange(3) },
+                ],
+                count: 2,
+            })
+
+            const scope = { repositoryId: 42, commit: 'deadbeef' }
+            const result = await new Backend(
+                dumpStore,
+                dependencyStore,
+                '',
+                createTestDatabase(
+                    new Map([
+                        [1, database1],
+                        [2, database2],
+                    ])
+                )
+            ).monikerLocations({ scheme: 'npm', identifier: 'x' }, 'reference', scope, 2, 0)
+
+            expect(result).toEqual({
+                locations: [
+                    { dump: dump1, path: 'a/1.ts', range: makeRange(1) },
+                    { dump: dump2, path: 'b/2.ts', range: makeRange(2) },
+                    { dump: dump2, path: 'b/2.ts', range: makeRange(3) },
+                ],
+                totalCount: 3,
+                newOffset: 2,
+            })
+            expect(monikerDumpsStub.args[0][0]).toMatchObject({
+                scheme: 'npm',
+                identifier: 'x',
+                kind: 'reference',
+                scope,
+                limit: 2,
+                offset: 0,
+            })
+        })
     })
 })
 
@@ -630,3 +1161,32 @@ describe('sortMonikers', () => {
         expect(sortMonikers(monikers)).toEqual(monikers)
     })
 })
+
+describe('compareDumps', () => {
+    it('should order dumps by distance, root, indexer, then id', () => {
+        const dumps = [
+            { ...zeroDump, id: 1, distance: 2, root: 'a/', indexer: 'lsif-go' },
+            { ...zeroDump, id: 2, distance: 1, root: '', indexer: 'lsif-go' },
+            { ...zeroDump, id: 3, distance: 1, root: 'a/b/', indexer: 'lsif-go' },
+            { ...zeroDump, id: 4, distance: 1, root: 'a/b/', indexer: 'lsif-go' },
+            { ...zeroDump, id: 5, distance: 1, root: 'a/b/', indexer: 'lsif-tsc' },
+            { ...zeroDump, id: 6, distance: 1, root: 'c/d/', indexer: 'lsif-go' },
+        ]
+
+        expect([...dumps].sort(compareDumps).map(d => d.id)).toEqual([3, 4, 6, 5, 2, 1])
+    })
+})
+
+describe('isRepositoryAllowed', () => {
+    it('should allow every repository without a list of allowed repositories', () => {
+        expect(isRepositoryAllowed(42, undefined, 50)).toBeTruthy()
+    })
+
+    it('should allow only listed repositories and the queried repository', () => {
+        const allowedRepositoryIds = new Set([50])
+        expect(isRepositoryAllowed(42, allowedRepositoryIds, 42)).toBeTruthy()
+        expect(isRepositoryAllowed(42, allowedRepositoryIds, 50)).toBeTruthy()
+        expect(isRepositoryAllowed(42, allowedRepositoryIds, 51)).toBeFalsy()
+        expect(isRepositoryAllowed(42, new Set(), 51)).toBeFalsy()
+    })
+})
@@ -1,12 +1,13 @@
 import * as sqliteModels from '../../shared/models/sqlite'
 import * as lsp from 'vscode-languageserver-protocol'
 import * as pgModels from '../../shared/models/pg'
+import * as metrics from '../metrics'
 import { addTags, logSpan, TracingContext } from '../../shared/tracing'
-import { Database } from './database'
-import { DumpManager } from '../../shared/store/dumps'
-import { DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT } from '../../shared/constants'
-import { DependencyManager } from '../../shared/store/dependencies'
-import { isDefined } from '../../shared/util'
+import { Database, existsBatch, isBundleNotFoundError } from './database'
+import { DumpStore, LsifDumpWithDistance } from '../../shared/store/dumps'
+import { DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT, MAX_CONCURRENT_EXISTS_REQUESTS } from '../../shared/constants'
+import { DependencyStore } from '../../shared/store/dependencies'
+import { findConcurrently, mapConcurrently } from '../../shared/util'
 import {
     DefinitionMonikersReferenceCursor,
     ReferencePaginationContext,
@@ -14,14 +15,42 @@ import {
     RemoteDumpReferenceCursor,
     SameDumpReferenceCursor,
 } from './cursor'
-import { InternalLocation, ResolvedInternalLocation } from './location'
+import { InternalLocation, OrderedResolvedLocationSet, ResolvedInternalLocation } from './location'
 import { isEqual, uniqWith } from 'lodash'
+import * as settings from '../settings'
+import { QueryResultCache } from './cache'
+import { HoverData } from '../../shared/encoding/hover'
+import { slicePage } from '../../shared/api/pagination/slice'
+import { definitionMonikerTiers, searchMonikerTiers } from './monikers'
+
+/** A diagnostic reported by an indexer along with the dump that contains it. */
+export interface DumpDiagnostic extends sqliteModels.DiagnosticData {
+    /** The dump containing the diagnostic. */
+    dump: pgModels.LsifDump
+}
 
-interface PaginatedInternalLocations {
+export interface PaginatedInternalLocations {
     locations: ResolvedInternalLocation[]
     newCursor?: ReferencePaginationCursor
 }
 
+/** The code intelligence data for a single source position. */
+export interface PositionData {
+    /** The hover content of the symbol at the position. */
+    hover: HoverData | null
+    /** The definitions of the symbol at the position. */
+    definitions: ResolvedInternalLocation[]
+    /** The first page of references to the symbol at the position. */
+    references: PaginatedInternalLocations
+}
+
+/** A dump, its database, and a tracing context tagged with the commit of the dump. */
+interface DumpAndDatabase {
+    dump: pgModels.LsifDump
+    database: Database
+    ctx: TracingContext
+}
+
 /**
  * A wrapper around code intelligence operations. This class deals with logic that spans
  * multiple repositories or commits. For single-dump logic, see the `Database` class.
@@ -30,20 +59,29 @@ export class Backend {
     /**
      * Create a new `Backend`.
      *
-     * @param dumpManager The dumps manager instance.
-     * @param dependencyManager The dependency manager instance.
+     * @param dumpStore The dumps store (usually a `DumpManager` instance).
+     * @param dependencyStore The dependency store (usually a `DependencyManager` instance).
      * @param frontendUrl The url of the frontend internal API.
      * @param createDatabase Function used to create a database instance from a dump.
+     * @param batchExists Function used to check the existence of documents in multiple dumps at once.
+     * @param resultCache The cache of definition and hover results.
      */
     constructor(
-        private dumpManager: DumpManager,
-        private dependencyManager: DependencyManager,
+        private dumpStore: DumpStore,
+        private dependencyStore: DependencyStore,
         private frontendUrl: string,
-        private createDatabase: (dumpId: pgModels.DumpId) => Database = dumpId => new Database(dumpId)
+        private createDatabase: (dumpId: pgModels.DumpId) => Database = dumpId => new Database(dumpId),
+        private batchExists: typeof existsBatch = existsBatch,
+        private resultCache: QueryResultCache = new QueryResultCache(
+            settings.QUERY_RESULT_CACHE_CAPACITY,
+            settings.QUERY_RESULT_CACHE_TTL
+        )
     ) {}
 
     /**
-     * Determine if data exists for a particular document.
+     * Determine if data exists for a particular document. The returned dumps are ordered by
+     * commit distance, then by root specificity (deepest root first), then by indexer name,
+     * and finally by identifier, so that callers can pick the best dump deterministically.
      *
      * @param repositoryId The repository identifier.
      * @param commit The commit.
@@ -55,13 +93,166 @@ export class Backend {
         commit: string,
         path: string,
         ctx: TracingContext = {}
-    ): Promise<pgModels.LsifDump[]> {
-        return (await this.findClosestDatabases(repositoryId, commit, path, ctx)).map(({ dump }) => dump)
+    ): Promise<LsifDumpWithDistance[]> {
+        return (await this.findClosestDatabases(repositoryId, commit, path, ctx))
+            .map(({ dump }) => dump)
+            .sort(compareDumps)
+    }
+
+    /**
+     * Determine if data exists for each of the given documents. The closest dumps of the commit
+     * are resolved once, and every path is checked against each dump whose root contains it with
+     * a single request to the bundle manager. Returns a map from each path to whether any such
+     * dump contains it.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param paths The paths of the documents.
+     * @param ctx The tracing context.
+     */
+    public async existsMany(
+        repositoryId: number,
+        commit: string,
+        paths: string[],
+        ctx: TracingContext = {}
+    ): Promise<Map<string, boolean>> {
+        const results = new Map(paths.map((path): [string, boolean] => [path, false]))
+
+        const closestDumps =
+            paths.length > 0
+                ? await this.dumpStore.findClosestDumps(repositoryId, commit, undefined, ctx, this.frontendUrl)
+                : []
+
+        const checks: { dumpId: pgModels.DumpId; path: string; file: string }[] = []
+        for (const path of results.keys()) {
+            for (const dump of closestDumps) {
+                if (path.startsWith(dump.root)) {
+                    checks.push({ dumpId: dump.id, path: pathToDatabase(dump.root, path), file: path })
+                }
+            }
+        }
+
+        if (checks.length === 0) {
+            return results
+        }
+
+        // Older bundle managers do not support batched existence checks, in which case
+        // we check each path in each database individually.
+
+        const exists =
+            (await this.batchExists(checks.map(({ dumpId, path }) => ({ dumpId, path })), ctx)) ||
+            (await mapConcurrently(checks, MAX_CONCURRENT_EXISTS_REQUESTS, ({ dumpId, path }) =>
+                this.createDatabase(dumpId).exists(path, ctx)
+            ))
+
+        for (const [i, { file }] of checks.entries()) {
+            if (exists[i]) {
+                results.set(file, true)
+            }
+        }
+
+        return results
+    }
+
+    /**
+     * Return the symbol outline of a document. Returns undefined if no dump can be loaded to
+     * answer this query.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     */
+    public async documentSymbols(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        dumpId: number,
+        ctx: TracingContext = {}
+    ): Promise<sqliteModels.DocumentSymbolData[] | undefined> {
+        const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
+        if (!closestDumpAndDatabase) {
+            if (ctx.logger) {
+                ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
+            }
+
+            return undefined
+        }
+        const { dump, database, ctx: newCtx } = closestDumpAndDatabase
+
+        return database.documentSymbols(pathToDatabase(dump.root, path), newCtx)
+    }
+
+    /**
+     * Return a summary of the code intelligence available for each range of a document that
+     * intersects the given (inclusive) window of lines. Returns undefined if no dump can be
+     * loaded to answer this query.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document.
+     * @param startLine The first line of the window (0-indexed, inclusive).
+     * @param endLine The last line of the window (0-indexed, inclusive).
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     */
+    public async ranges(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        startLine: number,
+        endLine: number,
+        dumpId: number,
+        ctx: TracingContext = {}
+    ): Promise<sqliteModels.RangeSummaryData[] | undefined> {
+        const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
+        if (!closestDumpAndDatabase) {
+            if (ctx.logger) {
+                ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
+            }
+
+            return undefined
+        }
+        const { dump, database, ctx: newCtx } = closestDumpAndDatabase
+
+        return database.ranges(pathToDatabase(dump.root, path), startLine, endLine, newCtx)
+    }
+
+    /**
+     * Return the diagnostics reported for a document by the indexers of every dump that
+     * contains it. Diagnostics are grouped by dump in the same order as `exists`, and their
+     * paths are relative to the repository root.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document.
+     * @param ctx The tracing context.
+     */
+    public async diagnostics(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        ctx: TracingContext = {}
+    ): Promise<DumpDiagnostic[]> {
+        const closestDatabases = await this.findClosestDatabases(repositoryId, commit, path, ctx)
+        closestDatabases.sort((a, b) => compareDumps(a.dump, b.dump))
+
+        const diagnostics = await Promise.all(
+            closestDatabases.map(async ({ dump, database, ctx }) =>
+                (await database.diagnostics(pathToDatabase(dump.root, path), {}, ctx)).diagnostics.map(
+                    diagnostic => ({ ...diagnostic, path: `${dump.root}${diagnostic.path}`, dump })
+                )
+            )
+        )
+
+        return diagnostics.flat()
     }
 
     /**
      * Return the location for the symbol at the given position. Returns undefined if no dump can
-     * be loaded to answer this query.
+     * be loaded to answer this query. Results are cached by dump, path, and position. The cache
+     * is bypassed when the context carries a debug log, so that the explanation is complete.
      *
      * @param repositoryId The repository identifier.
      * @param commit The commit.
@@ -69,16 +260,53 @@ export class Backend {
      * @param position The current hover position.
      * @param dumpId The identifier of the dump to load.
      * @param ctx The tracing context.
+     * @param dumpAndDatabase The already loaded dump with the given identifier, if any.
      */
-    public async definitions(
+    public definitions(
         repositoryId: number,
         commit: string,
         path: string,
         position: lsp.Position,
         dumpId: number,
-        ctx: TracingContext = {}
+        ctx: TracingContext = {},
+        dumpAndDatabase?: DumpAndDatabase
     ): Promise<ResolvedInternalLocation[] | undefined> {
-        const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
+        if (ctx.debug) {
+            ctx.debug.recordCacheBypass()
+            return this.uncachedDefinitions(repositoryId, commit, path, position, dumpId, ctx, dumpAndDatabase)
+        }
+
+        return this.resultCache.withValue(
+            'definitions',
+            { dumpId, path, position },
+            repositoryId,
+            () => this.uncachedDefinitions(repositoryId, commit, path, position, dumpId, ctx, dumpAndDatabase),
+            locations => locations.map(({ dump }) => dump.repositoryId)
+        )
+    }
+
+    /**
+     * Return the location for the symbol at the given position without consulting the result
+     * cache. Returns undefined if no dump can be loaded to answer this query.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     * @param dumpAndDatabase The already loaded dump with the given identifier, if any.
+     */
+    private async uncachedDefinitions(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        position: lsp.Position,
+        dumpId: number,
+        ctx: TracingContext = {},
+        dumpAndDatabase?: DumpAndDatabase
+    ): Promise<ResolvedInternalLocation[] | undefined> {
+        const closestDumpAndDatabase = dumpAndDatabase || (await this.closestDatabase(dumpId, ctx))
         if (!closestDumpAndDatabase) {
             if (ctx.logger) {
                 ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
@@ -95,7 +323,7 @@ export class Backend {
         const dbDefinitions = await database.definitions(pathInDb, position, newCtx)
         const definitions = dbDefinitions.map(loc => locationFromDatabase(dump.root, loc))
         if (definitions.length > 0) {
-            return this.resolveLocations(definitions)
+            return recordResults(ctx, 'same-dump', await this.resolveLocations(definitions, ctx))
         }
 
         // Try to find definitions in other dumps
@@ -104,16 +332,16 @@ export class Backend {
             return []
         }
 
-        // First, we find the monikers for each range, from innermost to
-        // outermost, such that the set of monikers for reach range is sorted by
-        // priority. Then, we perform a search for each moniker, in sequence,
-        // until valid results are found.
-        for (const monikers of rangeMonikers) {
-            for (const moniker of monikers) {
+        // Search the monikers of each range in tiers: imported monikers are resolved in the
+        // dump of the package that defines them, and export and local monikers are searched
+        // in the definitions table of our own database, in case there was a definition that
+        // wasn't properly attached to a result set but did have the correct monikers attached.
+        // The merged results of the first tier with valid results are returned.
+        const match = await searchMonikerTiers(
+            definitionMonikerTiers(rangeMonikers),
+            settings.MAX_CONCURRENT_REMOTE_DUMP_REQUESTS,
+            async (moniker): Promise<InternalLocation[]> => {
                 if (moniker.kind === 'import') {
-                    // This symbol was imported from another database. See if we have
-                    // a remote definition for it.
-
                     const { locations: remoteDefinitions } = await this.lookupMoniker(
                         dumpId,
                         pathInDb,
@@ -122,28 +350,65 @@ export class Backend {
                         {},
                         ctx
                     )
-                    if (remoteDefinitions.length > 0) {
-                        return this.resolveLocations(remoteDefinitions)
-                    }
-                } else {
-                    // This symbol was not imported from another database. We search the definitions
-                    // table of our own database in case there was a definition that wasn't properly
-                    // attached to a result set but did have the correct monikers attached.
-
-                    const { locations: monikerResults } = await database.monikerResults(
-                        sqliteModels.DefinitionModel,
-                        moniker,
-                        {},
-                        ctx
-                    )
-                    const localDefinitions = monikerResults.map(loc => locationFromDatabase(dump.root, loc))
-                    if (localDefinitions.length > 0) {
-                        return this.resolveLocations(localDefinitions)
-                    }
+                    return remoteDefinitions
                 }
+
+                const { locations: monikerResults } = await database.monikerResults(
+                    sqliteModels.DefinitionModel,
+                    moniker,
+                    {},
+                    ctx
+                )
+                return monikerResults.map(loc => locationFromDatabase(dump.root, loc))
             }
+        )
+        if (!match) {
+            return []
         }
-        return []
+
+        return recordResults(
+            ctx,
+            match.kind === 'import' ? 'remote-moniker' : 'same-dump-moniker',
+            await this.resolveLocations(match.locations, ctx)
+        )
+    }
+
+    /**
+     * Return a list of locations which implement the symbol at the given position. Only the
+     * dump closest to the given commit is searched. Returns undefined if no dump can be loaded
+     * to answer this query.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     */
+    public async implementations(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        position: lsp.Position,
+        dumpId: number,
+        ctx: TracingContext = {}
+    ): Promise<ResolvedInternalLocation[] | undefined> {
+        const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
+        if (!closestDumpAndDatabase) {
+            if (ctx.logger) {
+                ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
+            }
+
+            return undefined
+        }
+        const { dump, database, ctx: newCtx } = closestDumpAndDatabase
+
+        // Construct path within dump
+        const pathInDb = pathToDatabase(dump.root, path)
+
+        const dbImplementations = await database.implementations(pathInDb, position, newCtx)
+        const implementations = dbImplementations.map(loc => locationFromDatabase(dump.root, loc))
+        return this.resolveLocations(implementations, ctx)
     }
 
     /**
@@ -158,6 +423,7 @@ export class Backend {
      * @param remoteDumpLimit The maximum number of remote dumps to query in one operation.
      * @param dumpId The identifier of the dump to load.
      * @param ctx The tracing context.
+     * @param dumpAndDatabase The already loaded dump with the given identifier, if any.
      */
     public async references(
         repositoryId: number,
@@ -167,20 +433,29 @@ export class Backend {
         paginationContext: ReferencePaginationContext = { limit: 10 },
         remoteDumpLimit = DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT,
         dumpId: number,
-        ctx: TracingContext = {}
+        ctx: TracingContext = {},
+        dumpAndDatabase?: DumpAndDatabase
     ): Promise<PaginatedInternalLocations | undefined> {
         if (paginationContext.cursor) {
+            if (ctx.debug) {
+                const dump = await this.dumpStore.getDumpById(paginationContext.cursor.dumpId, ctx)
+                if (dump) {
+                    ctx.debug.recordDump(dump)
+                }
+            }
+
             return this.handleReferencePaginationCursor(
                 repositoryId,
                 commit,
                 remoteDumpLimit,
                 paginationContext.limit,
                 paginationContext.cursor,
+                paginationContext.allowedRepositoryIds,
                 ctx
             )
         }
 
-        const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
+        const closestDumpAndDatabase = dumpAndDatabase || (await this.closestDatabase(dumpId, ctx))
         if (!closestDumpAndDatabase) {
             if (ctx.logger) {
                 ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
@@ -212,29 +487,37 @@ export class Backend {
             remoteDumpLimit,
             paginationContext.limit,
             cursor,
+            paginationContext.allowedRepositoryIds,
             newCtx
         )
     }
 
     /**
-     * Return the hover content for the symbol at the given position. Returns undefined if no dump can
-     * be loaded to answer this query.
+     * Return the hover content, the definitions, and the first page of references of the symbol
+     * at the given position. The dump is loaded once and shared by the three queries, which are
+     * performed concurrently. Returns undefined if no dump can be loaded to answer this query.
+     * Definitions in repositories that the requesting user may not read are dropped from every
+     * result, and the hover content is never read from such a definition.
      *
      * @param repositoryId The repository identifier.
      * @param commit The commit.
      * @param path The path of the document to which the position belongs.
      * @param position The current hover position.
+     * @param paginationContext Context describing the first page of references.
+     * @param remoteDumpLimit The maximum number of remote dumps to query in one operation.
      * @param dumpId The identifier of the dump to load.
      * @param ctx The tracing context.
      */
-    public async hover(
+    public async position(
         repositoryId: number,
         commit: string,
         path: string,
         position: lsp.Position,
+        paginationContext: ReferencePaginationContext,
+        remoteDumpLimit = DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT,
         dumpId: number,
         ctx: TracingContext = {}
-    ): Promise<{ text: string; range: lsp.Range } | null | undefined> {
+    ): Promise<PositionData | undefined> {
         const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
         if (!closestDumpAndDatabase) {
             if (ctx.logger) {
@@ -243,26 +526,326 @@ export class Backend {
 
             return undefined
         }
+
+        // The cached hover content may have been read from a definition in any repository, so
+        // the cache is bypassed when the readable repositories are restricted
+        const { allowedRepositoryIds } = paginationContext
+        const [hover, definitions, references] = await Promise.all([
+            allowedRepositoryIds
+                ? this.uncachedHover(
+                      repositoryId,
+                      commit,
+                      path,
+                      position,
+                      dumpId,
+                      ctx,
+                      closestDumpAndDatabase,
+                      allowedRepositoryIds
+                  )
+                : this.hover(repositoryId, commit, path, position, dumpId, ctx, closestDumpAndDatabase),
+            this.definitions(repositoryId, commit, path, position, dumpId, ctx, closestDumpAndDatabase),
+            this.references(
+                repositoryId,
+                commit,
+                path,
+                position,
+                { ...paginationContext, cursor: undefined },
+                remoteDumpLimit,
+                dumpId,
+                ctx,
+                closestDumpAndDatabase
+            ),
+        ])
+
+        return {
+            hover: hover || null,
+            definitions: (definitions || []).filter(({ dump }) =>
+                isRepositoryAllowed(repositoryId, allowedRepositoryIds, dump.repositoryId)
+            ),
+            references: references || { locations: [] },
+        }
+    }
+
+    /**
+     * Return the definitions or references of the symbol with the given moniker in a page of
+     * the dumps that may contain it, without requiring a source position. The dumps are found
+     * through the packages and references tables and are queried concurrently. The search is
+     * restricted to the dumps visible from the given commit of a repository, or to the dumps
+     * visible at the tip of their repository when no scope is given.
+     *
+     * @param moniker The target moniker.
+     * @param kind Whether to return definitions or references.
+     * @param scope The repository and commit whose visible dumps are searched.
+     * @param limit The maximum number of dumps to query.
+     * @param offset The number of candidate dumps to skip.
+     * @param ctx The tracing context.
+     */
+    public async monikerLocations(
+        moniker: Pick<sqliteModels.MonikerData, 'scheme' | 'identifier'>,
+        kind: 'definition' | 'reference',
+        scope: { repositoryId: number; commit: string } | undefined,
+        limit: number,
+        offset: number,
+        ctx: TracingContext = {}
+    ): Promise<{ locations: ResolvedInternalLocation[]; totalCount: number; newOffset: number }> {
+        const { dumps, totalCount, newOffset } = await this.dependencyStore.getMonikerDumps({
+            ...moniker,
+            kind,
+            scope,
+            limit,
+            offset,
+            ctx,
+        })
+
+        logSpan(ctx, 'moniker_dumps', {
+            dumps: dumps.map(dump => ({ repositoryId: dump.repositoryId, commit: dump.commit, root: dump.root })),
+        })
+
+        const model = kind === 'definition' ? sqliteModels.DefinitionModel : sqliteModels.ReferenceModel
+        const results = await mapConcurrently(dumps, settings.MAX_CONCURRENT_REMOTE_DUMP_REQUESTS, async dump => {
+            metrics.queryRemoteDumpsCounter.labels('moniker-locations').inc()
+            const { locations } = await this.createDatabase(dump.id).monikerResults(model, moniker, {}, ctx)
+            return locations.map(({ path, range }) => ({ dump, path: `${dump.root}${path}`, range }))
+        })
+
+        const locations = new OrderedResolvedLocationSet(results.flat()).values
+        return { locations: recordResults(ctx, 'moniker', locations), totalCount, newOffset }
+    }
+
+    /**
+     * Return the hover content for the symbol at the given position. Returns undefined if no dump can
+     * be loaded to answer this query. Results are cached by dump, path, and position. The cache is
+     * bypassed when the context carries a debug log, so that the explanation is complete.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     * @param dumpAndDatabase The already loaded dump with the given identifier, if any.
+     */
+    public hover(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        position: lsp.Position,
+        dumpId: number,
+        ctx: TracingContext = {},
+        dumpAndDatabase?: DumpAndDatabase
+    ): Promise<HoverData | null | undefined> {
+        if (ctx.debug) {
+            ctx.debug.recordCacheBypass()
+            return this.uncachedHover(repositoryId, commit, path, position, dumpId, ctx, dumpAndDatabase)
+        }
+
+        return this.resultCache.withValue('hover', { dumpId, path, position }, repositoryId, () =>
+            this.uncachedHover(repositoryId, commit, path, position, dumpId, ctx, dumpAndDatabase)
+        )
+    }
+
+    /**
+     * Return the hover content for the symbol at the given position without consulting the result
+     * cache. Returns undefined if no dump can be loaded to answer this query.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     * @param dumpAndDatabase The already loaded dump with the given identifier, if any.
+     * @param allowedRepositoryIds The repositories that the requesting user may read (undefined means all).
+     */
+    private async uncachedHover(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        position: lsp.Position,
+        dumpId: number,
+        ctx: TracingContext = {},
+        dumpAndDatabase?: DumpAndDatabase,
+        allowedRepositoryIds?: Set<number>
+    ): Promise<HoverData | null | undefined> {
+        const closestDumpAndDatabase = dumpAndDatabase || (await this.closestDatabase(dumpId, ctx))
+        if (!closestDumpAndDatabase) {
+            if (ctx.logger) {
+                ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
+            }
+
+            return undefined
+        }
         const { dump, database, ctx: newCtx } = closestDumpAndDatabase
 
         // Try to find hover in the same dump
         const hover = await database.hover(pathToDatabase(dump.root, path), position, newCtx)
         if (hover !== null) {
+            if (ctx.debug) {
+                ctx.debug.recordResults('same-dump-hover', [dump.id])
+            }
+
             return hover
         }
 
-        // If we don't have a local hover, lookup the definitions of the range and read the hover
-        // data from the remote database. This can happen when the indexer only gives a moniker but
-        // does not give hover data for externally defined symbols.
+        return this.hoverFromDefinition(
+            repositoryId,
+            commit,
+            path,
+            position,
+            dumpId,
+            newCtx,
+            closestDumpAndDatabase,
+            allowedRepositoryIds
+        )
+    }
 
-        const locations = await this.definitions(repositoryId, commit, path, position, dumpId, ctx)
-        if (!locations || locations.length === 0) {
+    /**
+     * Return the hover content for each of the given positions within the same document. The
+     * hover data for all positions is requested from the bundle manager in a single request.
+     * Positions without hover data in the same dump fall back to the hover data attached to
+     * their definition, with at most `MAX_CONCURRENT_HOVER_FALLBACKS` fallbacks resolved at
+     * once. Returns undefined if no dump can be loaded to answer this query.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document to which the positions belong.
+     * @param positions The hover positions.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     */
+    public async hovers(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        positions: lsp.Position[],
+        dumpId: number,
+        ctx: TracingContext = {}
+    ): Promise<(HoverData | null)[] | undefined> {
+        const closestDumpAndDatabase = await this.closestDatabase(dumpId, ctx)
+        if (!closestDumpAndDatabase) {
+            if (ctx.logger) {
+                ctx.logger.warn('No database could be loaded', { repositoryId, commit, path })
+            }
+
+            return undefined
+        }
+        const { dump, database, ctx: newCtx } = closestDumpAndDatabase
+
+        // Try to find hovers in the same dump
+        const hovers = await database.hovers(pathToDatabase(dump.root, path), positions, newCtx)
+
+        // Each fallback queries the definitions of its position, which may fan out to remote
+        // dumps, so only a few are resolved at once
+        return mapConcurrently(hovers, settings.MAX_CONCURRENT_HOVER_FALLBACKS, (hover, i) =>
+            hover !== null
+                ? Promise.resolve(hover)
+                : this.hoverFromDefinition(
+                      repositoryId,
+                      commit,
+                      path,
+                      positions[i],
+                      dumpId,
+                      newCtx,
+                      closestDumpAndDatabase
+                  )
+        )
+    }
+
+    /**
+     * Lookup the definitions of the range at the given position and read the hover data from the
+     * database that contains the definition. This is used when the dump containing the position
+     * does not have local hover data. This can happen when the indexer only gives a moniker but
+     * does not give hover data for externally defined symbols. Each definition is tried in order
+     * until one has hover data, so that hovers on re-exported symbols resolve even when the first
+     * definition (e.g. the re-export) has none. Definitions in repositories that the requesting
+     * user may not read are skipped.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit.
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param dumpId The identifier of the dump to load.
+     * @param ctx The tracing context.
+     * @param dumpAndDatabase The already loaded dump with the given identifier, if any.
+     * @param allowedRepositoryIds The repositories that the requesting user may read (undefined means all).
+     */
+    private async hoverFromDefinition(
+        repositoryId: number,
+        commit: string,
+        path: string,
+        position: lsp.Position,
+        dumpId: number,
+        ctx: TracingContext = {},
+        dumpAndDatabase?: DumpAndDatabase,
+        allowedRepositoryIds?: Set<number>
+    ): Promise<HoverData | null> {
+        const locations = await this.definitions(repositoryId, commit, path, position, dumpId, ctx, dumpAndDatabase)
+        if (!locations) {
             return null
         }
 
-        const { dump: definitionDump, path: definitionPath, range } = locations[0]
-        const definitionDatabase = this.createDatabase(definitionDump.id)
-        return definitionDatabase.hover(pathToDatabase(definitionDump.root, definitionPath), range.start, newCtx)
+        for (const { dump: definitionDump, path: definitionPath, range } of locations) {
+            if (!isRepositoryAllowed(repositoryId, allowedRepositoryIds, definitionDump.repositoryId)) {
+                continue
+            }
+
+            const hover = await this.definitionHover(definitionDump, definitionPath, range.start, ctx)
+            if (hover !== null) {
+                if (ctx.debug) {
+                    ctx.debug.recordResults('definition-hover', [definitionDump.id])
+                }
+
+                return hover
+            }
+        }
+
+        return null
+    }
+
+    /**
+     * Return the hover content at the start of a definition. A dump whose bundle is missing from
+     * the bundle manager has no hover content. Results, including the absence of hover content,
+     * are cached so that definitions without hover data are not queried again by later hovers
+     * that fall back to them.
+     *
+     * @param dump The dump containing the definition.
+     * @param path The path of the document containing the definition, relative to the repository root.
+     * @param position The start of the definition.
+     * @param ctx The tracing context.
+     */
+    private async definitionHover(
+        dump: pgModels.LsifDump,
+        path: string,
+        position: lsp.Position,
+        ctx: TracingContext = {}
+    ): Promise<HoverData | null> {
+        const query = async (): Promise<HoverData | null> => {
+            try {
+                return await this.createDatabase(dump.id).hover(pathToDatabase(dump.root, path), position, ctx)
+            } catch (error) {
+                if (!isBundleNotFoundError(error)) {
+                    throw error
+                }
+
+                if (ctx.logger) {
+                    ctx.logger.warn('Skipping definition in dump without a bundle', { dumpId: dump.id, path })
+                }
+
+                return null
+            }
+        }
+
+        if (ctx.debug) {
+            return query()
+        }
+
+        const hover = await this.resultCache.withValue(
+            'definition-hover',
+            { dumpId: dump.id, path, position },
+            dump.repositoryId,
+            query
+        )
+        return hover || null
     }
 
     /**
@@ -276,13 +859,20 @@ export class Backend {
      *
      * This method will return any locations found in this page of results as well as a cursor
      * indicating how to execute the next page of results. If the cursor is undefined there are no
-     * more results.
+     * more results. Phases are advanced until `limit` locations are gathered or every phase is
+     * exhausted, so a page is only partially filled if it is the last page. Each dump is searched
+     * by a single phase (a later phase skips the source dump and the dump that defines the
+     * package), and each phase removes duplicates before it takes its page, so a location is never
+     * returned on more than one page. A location returned by more than one phase of the same page
+     * is returned only once, in the position of its first occurrence. Locations in repositories
+     * that the requesting user may not read are dropped.
      *
      * @param repositoryId The repository identifier.
      * @param commit The target commit.
      * @param remoteDumpLimit The maximum number of remote dumps to query in one operation.
      * @param limit The maximum number of locations to return on this page.
      * @param cursor The pagination cursor.
+     * @param allowedRepositoryIds The repositories that the requesting user may read (undefined means all).
      * @param ctx The tracing context.
      */
     private async handleReferencePaginationCursor(
@@ -291,6 +881,57 @@ export class Backend {
         remoteDumpLimit: number,
         limit: number,
         cursor: ReferencePaginationCursor,
+        allowedRepositoryIds: Set<number> | undefined,
+        ctx: TracingContext = {}
+    ): Promise<PaginatedInternalLocations> {
+        const seen = new OrderedResolvedLocationSet()
+        let locations: ResolvedInternalLocation[] = []
+        let newCursor: ReferencePaginationCursor | undefined = cursor
+
+        while (newCursor && locations.length < limit) {
+            const phase = newCursor.phase
+            const page = await this.handleReferencePaginationPhase(
+                repositoryId,
+                commit,
+                remoteDumpLimit,
+                limit - locations.length,
+                newCursor,
+                allowedRepositoryIds,
+                ctx
+            )
+
+            const allowedLocations = page.locations.filter(({ dump }) =>
+                isRepositoryAllowed(repositoryId, allowedRepositoryIds, dump.repositoryId)
+            )
+            locations = locations.concat(recordResults(ctx, phase, seen.pushAll(allowedLocations)))
+            newCursor = page.newCursor
+        }
+
+        return { locations, newCursor }
+    }
+
+    /**
+     * Query the results of the current phase of a reference request described by the given
+     * cursor. This returns the locations found in this phase along with a cursor for the
+     * remaining results of the same phase or, once this phase is exhausted, a cursor for the
+     * first results of the next phase. The cursor is undefined when no phase has further
+     * results.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The target commit.
+     * @param remoteDumpLimit The maximum number of remote dumps to query in one operation.
+     * @param limit The maximum number of locations to return.
+     * @param cursor The pagination cursor.
+     * @param allowedRepositoryIds The repositories that the requesting user may read (undefined means all).
+     * @param ctx The tracing context.
+     */
+    private async handleReferencePaginationPhase(
+        repositoryId: number,
+        commit: string,
+        remoteDumpLimit: number,
+        limit: number,
+        cursor: ReferencePaginationCursor,
+        allowedRepositoryIds: Set<number> | undefined,
         ctx: TracingContext = {}
     ): Promise<PaginatedInternalLocations> {
         /**
@@ -300,38 +941,20 @@ export class Backend {
          * to construct the cursor for the next phase of pagination. When no further data are
          * available in any phase, the factory returns undefined.
          *
-         * If the locations from the handler function do not produce a full page of results, the
-         * next page of results are evaluated with a modified limit.
-         *
          * @param handler The handler for the current page of results.
          * @param makeCursor A factory that creates a cursor for the next phase of pagination.
          */
-        const recur = async (
+        const advance = async (
             handler: () => Promise<PaginatedInternalLocations>,
             makeCursor: () => Promise<ReferencePaginationCursor | undefined> | ReferencePaginationCursor | undefined
         ): Promise<PaginatedInternalLocations> => {
-            const { locations, newCursor: originalCursor } = await handler()
-            const newCursor = originalCursor || (await makeCursor())
-            if (!newCursor) {
-                return { locations }
-            }
-
-            limit -= locations.length
-            if (limit <= 0) {
-                return { locations, newCursor }
-            }
-
-            const {
-                locations: nextPageLocations,
-                newCursor: nextPageNewCursor,
-            } = await this.handleReferencePaginationCursor(repositoryId, commit, remoteDumpLimit, limit, newCursor, ctx)
-
-            return { locations: locations.concat(nextPageLocations), newCursor: nextPageNewCursor }
+            const { locations, newCursor } = await handler()
+            return { locations, newCursor: newCursor || (await makeCursor()) }
         }
 
         switch (cursor.phase) {
             case 'same-dump': {
-                return recur(
+                return advance(
                     () => this.performSameDumpReferences(limit, cursor, ctx),
                     () => ({
                         dumpId: cursor.dumpId,
@@ -345,7 +968,7 @@ export class Backend {
             }
 
             case 'definition-monikers': {
-                return recur(
+                return advance(
                     () => this.performDefinitionMonikersReferences(limit, cursor, ctx),
                     async (): Promise<ReferencePaginationCursor | undefined> => {
                         for (const moniker of cursor.monikers) {
@@ -377,7 +1000,7 @@ export class Backend {
             }
 
             case 'same-repo': {
-                return recur(
+                return advance(
                     () =>
                         this.performSameRepositoryRemoteReferences(
                             repositoryId,
@@ -404,8 +1027,16 @@ export class Backend {
             }
 
             case 'remote-repo': {
-                return recur(
-                    () => this.performRemoteReferences(repositoryId, remoteDumpLimit, limit, cursor, ctx),
+                return advance(
+                    () =>
+                        this.performRemoteReferences(
+                            repositoryId,
+                            remoteDumpLimit,
+                            limit,
+                            cursor,
+                            allowedRepositoryIds,
+                            ctx
+                        ),
                     () => undefined
                 )
             }
@@ -416,13 +1047,12 @@ export class Backend {
      * Search the LSIF reference results and the references table of the current dump. This method
      * returns a cursor if there are reference results locations remaining for a subsequent page.
      *
-     * Implementation detail: this method brings all of the LSIF and references table results into
-     * memory so that they can be reasonable deduplicated. We splice the combined results by index
-     * and return pages in this manner rather than pulling back a set of results from the SQLite
-     * database. This step turns out to be quite necessary, as a single LSIF dump can have a fair
-     * amount of reference duplication when looking at both result set edges and attached monikers.
-     * Skipping this step may return several pages of duplicated results if not batched in this
-     * manner.
+     * Implementation detail: this method brings the LSIF reference results that precede the end of
+     * this page into memory so that they can be deduplicated against the references table results.
+     * A single LSIF dump can have a fair amount of reference duplication when looking at both result
+     * set edges and attached monikers, and skipping this step may return several pages of duplicated
+     * results. Each source is queried only until the set holds one location more than is needed for
+     * this page, which tells us whether a subsequent page exists.
      *
      * @param limit The maximum number of locations to return on this page.
      * @param cursor The pagination cursor.
@@ -433,40 +1063,54 @@ export class Backend {
         cursor: SameDumpReferenceCursor,
         ctx: TracingContext = {}
     ): Promise<PaginatedInternalLocations> {
-        const dumpAndDatabase = await this.getDumpAndDatabaseById(cursor.dumpId)
+        const dumpAndDatabase = await this.getDumpAndDatabaseById(cursor.dumpId, ctx)
         if (!dumpAndDatabase) {
             return { locations: [] }
         }
         const { dump, database } = dumpAndDatabase
 
-        // First get all LSIF reference result locations for the given position.
-        const locationSet = await database.references(cursor.path, cursor.position, ctx)
+        // First get the LSIF reference result locations for the given position. Only the
+        // locations up to the end of this page (plus one) are needed. If there are fewer,
+        // every LSIF location is fetched and moniker results can be deduplicated against
+        // all of them.
+        const target = cursor.skipResults + limit + 1
+        const { locations: locationSet } = await database.references(
+            cursor.path,
+            cursor.position,
+            { take: target },
+            ctx
+        )
 
         // Search the references table of the current dump. This search is necessary because
         // we want a 'Find References' operation on a reference to also return references to
-        // the governing definition, and those may not be fully linked in the LSIF data. This
-        // method returns a cursor if there are reference rows remaining for a subsequent page.
+        // the governing definition, and those may not be fully linked in the LSIF data.
         for (const moniker of cursor.monikers) {
-            const { locations: monikerLocations } = await database.monikerResults(
-                sqliteModels.ReferenceModel,
-                moniker,
-                {},
-                ctx
-            )
+            let skip = 0
+            while (locationSet.size < target) {
+                const { locations: monikerLocations, count } = await database.monikerResults(
+                    sqliteModels.ReferenceModel,
+                    moniker,
+                    { skip, take: target - locationSet.size },
+                    ctx
+                )
+
+                for (const location of monikerLocations) {
+                    locationSet.push(location)
+                }
 
-            for (const location of monikerLocations) {
-                locationSet.push(location)
+                skip += monikerLocations.length
+                if (monikerLocations.length === 0 || skip >= count) {
+                    break
+                }
             }
         }
 
         // Get the page's slice of results
-        const slicedLocations = locationSet.values.slice(cursor.skipResults, cursor.skipResults + limit)
-        const newOffset = cursor.skipResults + limit
-        const newCursor = { ...cursor, skipResults: cursor.skipResults + limit }
+        const { page, nextOffset } = slicePage(locationSet.values, cursor.skipResults, limit)
 
         return {
-            locations: await this.resolveLocations(slicedLocations.map(loc => locationFromDatabase(dump.root, loc))),
-            newCursor: newOffset < locationSet.values.length ? newCursor : undefined,
+            locations: await this.resolveLocations(page.map(loc => locationFromDatabase(dump.root, loc)), ctx),
+            newCursor: nextOffset !== undefined ? { ...cursor, skipResults: nextOffset } : undefined,
         }
     }
 
@@ -486,33 +1130,35 @@ export class Backend {
         cursor: DefinitionMonikersReferenceCursor,
         ctx: TracingContext = {}
     ): Promise<PaginatedInternalLocations> {
-        for (const moniker of cursor.monikers) {
-            if (moniker.kind !== 'import') {
-                continue
-            }
-
-            // Get locations in the defining package
-            const { locations, count } = await this.lookupMoniker(
-                cursor.dumpId,
-                cursor.path,
-                moniker,
-                sqliteModels.ReferenceModel,
-                { take: limit, skip: cursor.skipResults },
-                ctx
-            )
+        // Get locations in the defining package of each imported moniker
+        const match = await findConcurrently(
+            cursor.monikers.filter(moniker => moniker.kind === 'import'),
+            settings.MAX_CONCURRENT_REMOTE_DUMP_REQUESTS,
+            moniker =>
+                this.lookupMoniker(
+                    cursor.dumpId,
+                    cursor.path,
+                    moniker,
+                    sqliteModels.ReferenceModel,
+                    { take: limit, skip: cursor.skipResults },
+                    ctx,
+                    // The references table of the source dump was searched by the same-dump phase
+                    cursor.dumpId
+                ),
+            ({ locations }) => locations.length > 0
+        )
+        if (!match) {
+            return { locations: [] }
+        }
 
-            if (locations.length > 0) {
-                const newOffset = cursor.skipResults + locations.length
-                const newCursor = { ...cursor, skipResults: cursor.skipResults + limit }
+        const { locations, count } = match.result
+        const newOffset = cursor.skipResults + locations.length
+        const newCursor = { ...cursor, skipResults: cursor.skipResults + limit }
 
-                return {
-                    locations: await this.resolveLocations(locations),
-                    newCursor: newOffset < count ? newCursor : undefined,
-                }
-            }
+        return {
+            locations: await this.resolveLocations(locations, ctx),
+            newCursor: newOffset < count ? newCursor : undefined,
         }
-
-        return { locations: [] }
     }
 
     /**
@@ -537,8 +1183,8 @@ export class Backend {
         cursor: RemoteDumpReferenceCursor,
         ctx: TracingContext = {}
     ): Promise<PaginatedInternalLocations> {
-        const getPackageReferences = (): ReturnType<DependencyManager['getSameRepoRemotePackageReferences']> =>
-            this.dependencyManager.getSameRepoRemotePackageReferences({
+        const getPackageReferences = (): ReturnType<DependencyStore['getSameRepoRemotePackageReferences']> =>
+            this.dependencyStore.getSameRepoRemotePackageReferences({
                 repositoryId,
                 commit,
                 scheme: cursor.scheme,
@@ -565,12 +1211,14 @@ export class Backend {
      * has attached package information, then Postgres is queried for the packages that require
      * this particular moniker identifier. These dumps are opened, and their references tables are
      * queried for the target moniker. This method returns a cursor if there are additional dumps
-     * to process on a subsequent page.
+     * to process on a subsequent page. Dumps of repositories that the requesting user may not read
+     * are not queried.
      *
      * @param repositoryId The repository identifier.
      * @param remoteDumpLimit The maximum number of remote dumps to query in one operation.
      * @param limit The maximum number of locations to return on this page.
      * @param cursor The pagination cursor.
+     * @param allowedRepositoryIds The repositories that the requesting user may read (undefined means all).
      * @param ctx The tracing context.
      */
     private async performRemoteReferences(
@@ -578,10 +1226,11 @@ export class Backend {
         remoteDumpLimit: number,
         limit: number,
         cursor: RemoteDumpReferenceCursor,
+        allowedRepositoryIds: Set<number> | undefined,
         ctx: TracingContext = {}
     ): Promise<PaginatedInternalLocations> {
-        const getPackageReferences = (): ReturnType<DependencyManager['getPackageReferences']> =>
-            this.dependencyManager.getPackageReferences({
+        const getPackageReferences = (): ReturnType<DependencyStore['getPackageReferences']> =>
+            this.dependencyStore.getPackageReferences({
                 repositoryId,
                 scheme: cursor.scheme,
                 name: cursor.name,
@@ -596,6 +1245,7 @@ export class Backend {
             dumpId: cursor.dumpId,
             moniker: { scheme: cursor.scheme, identifier: cursor.identifier },
             getPackageReferences,
+            isDumpAllowed: dump => isRepositoryAllowed(repositoryId, allowedRepositoryIds, dump.repositoryId),
             limit,
             cursor,
             ctx,
@@ -603,7 +1253,12 @@ export class Backend {
     }
 
     /**
-     * Query the given dumps for references to the given moniker.
+     * Query the given dumps for references to the given moniker. The dumps of a batch are
+     * queried in windows of up to `MAX_CONCURRENT_REMOTE_DUMP_REQUESTS` dumps at once, and
+     * the results of consecutive dumps are returned in cursor order until `limit` locations
+     * are gathered, so the locations of a page do not depend on the order in which the
+     * requests complete. The batches of dumps are ordered so that the dumps of different
+     * repositories are interleaved.
      *
      * @param args Parameter bag.
      */
@@ -611,6 +1266,7 @@ export class Backend {
         dumpId,
         moniker,
         getPackageReferences,
+        isDumpAllowed = () => true,
         limit,
         cursor,
         ctx = {},
@@ -625,6 +1281,8 @@ export class Backend {
             newOffset: number
             totalCount: number
         }>
+        /** A function that determines if a dump of the next batch may be queried. */
+        isDumpAllowed?: (dump: pgModels.LsifDump) => boolean
         /** The maximum number of locations to return on this page. */
         limit: number
         /** The pagination cursor. */
@@ -639,62 +1297,91 @@ export class Backend {
                 references: packageReferences.map(r => ({ repositoryId: r.dump.repositoryId, commit: r.dump.commit })),
             })
 
-            cursor.dumpIds = packageReferences.map(r => r.dump.id)
+            // The references of the dump that defines the package were gathered by the definition
+            // monikers phase, so it is skipped here for the same reason as the source dump below
+            const packageEntity = await this.dependencyStore.getPackage(cursor.scheme, cursor.name, cursor.version)
+            const definitionDumpId = packageEntity ? packageEntity.dump.id : undefined
+
+            cursor.dumpIds = packageReferences
+                .filter(r => isDumpAllowed(r.dump) && r.dump.id !== definitionDumpId)
+                .map(r => r.dump.id)
             cursor.skipDumpsWhenBatching = newOffset
             cursor.totalDumpsWhenBatching = totalCount
         }
 
-        for (const [i, batchDumpId] of cursor.dumpIds.entries()) {
-            if (i < cursor.skipDumpsInBatch) {
-                continue
-            }
-
-            // Skip the remote reference that show up for ourselves - we've already gathered
-            // these in the previous step of the references query.
-            if (batchDumpId === dumpId) {
-                continue
-            }
+        // Skip the dumps processed on a previous page, as well as the remote reference that
+        // show up for ourselves - we've already gathered these in the previous step of the
+        // references query.
+        const batch = cursor.dumpIds
+            .map((batchDumpId, i) => ({ batchDumpId, i }))
+            .filter(({ batchDumpId, i }) => i >= cursor.skipDumpsInBatch && batchDumpId !== dumpId)
+
+        const nextBatchCursor =
+            cursor.skipDumpsWhenBatching < cursor.totalDumpsWhenBatching
+                ? { ...cursor, dumpIds: [], skipDumpsInBatch: 0, skipResultsInDump: 0 }
+                : undefined
+
+        let locations: ResolvedInternalLocation[] = []
+        const windowSize = Math.max(1, settings.MAX_CONCURRENT_REMOTE_DUMP_REQUESTS)
+
+        for (let offset = 0; offset < batch.length; offset += windowSize) {
+            const take = limit - locations.length
+            const results = await mapConcurrently(
+                batch.slice(offset, offset + windowSize),
+                windowSize,
+                async ({ batchDumpId, i }) => {
+                    const dumpAndDatabase = await this.getDumpAndDatabaseById(batchDumpId, ctx)
+                    if (!dumpAndDatabase) {
+                        return undefined
+                    }
+                    const { dump, database } = dumpAndDatabase
+                    metrics.queryRemoteDumpsCounter.labels('references').inc()
 
-            const dumpAndDatabase = await this.getDumpAndDatabaseById(batchDumpId)
-            if (!dumpAndDatabase) {
-                continue
-            }
-            const { dump, database } = dumpAndDatabase
+                    // Only the first dump of the page may have been partially returned on a previous page
+                    const skip = i === cursor.skipDumpsInBatch ? cursor.skipResultsInDump : 0
+                    const { locations, count } = await database.monikerResults(
+                        sqliteModels.ReferenceModel,
+                        moniker,
+                        { take, skip },
+                        ctx
+                    )
 
-            const { locations, count } = await database.monikerResults(
-                sqliteModels.ReferenceModel,
-                moniker,
-                { take: limit, skip: cursor.skipResultsInDump },
-                ctx
+                    return { i, dump, skip, locations, count }
+                }
             )
 
-            if (locations.length > 0) {
-                const newResultOffset = cursor.skipResultsInDump + locations.length
-                const moreDumps = i + 1 < cursor.dumpIds.length
-                const nextCursor = { ...cursor, skipResultsInDump: cursor.skipResultsInDump + limit }
-                const nextDumpCursor = { ...cursor, skipDumpsInBatch: i + 1, skipResultsInDump: 0 }
-                const nextBatchCursor = {
-                    ...cursor,
-                    dumpIds: [],
-                    skipDumpsInBatch: 0,
-                    skipResultsInDump: 0,
+            for (const result of results) {
+                if (!result) {
+                    continue
                 }
 
-                return {
-                    locations: await this.resolveLocations(locations.map(loc => locationFromDatabase(dump.root, loc))),
-                    newCursor:
-                        newResultOffset < count
-                            ? nextCursor
-                            : moreDumps
-                            ? nextDumpCursor
-                            : cursor.skipDumpsWhenBatching < cursor.totalDumpsWhenBatching
-                            ? nextBatchCursor
-                            : undefined,
+                const { i, dump, skip } = result
+                const dumpLocations = result.locations.slice(0, limit - locations.length)
+                locations = locations.concat(
+                    await this.resolveLocations(dumpLocations.map(loc => locationFromDatabase(dump.root, loc)), ctx)
+                )
+
+                if (skip + dumpLocations.length < result.count) {
+                    // Continue with the remaining results of this dump on the next page
+                    return {
+                        locations,
+                        newCursor: { ...cursor, skipDumpsInBatch: i, skipResultsInDump: skip + dumpLocations.length },
+                    }
+                }
+
+                if (locations.length >= limit) {
+                    return {
+                        locations,
+                        newCursor:
+                            i + 1 < cursor.dumpIds.length
+                                ? { ...cursor, skipDumpsInBatch: i + 1, skipResultsInDump: 0 }
+                                : nextBatchCursor,
+                    }
                 }
             }
         }
 
-        return { locations: [] }
+        return { locations, newCursor: nextBatchCursor }
     }
 
     /**
@@ -709,6 +1396,7 @@ export class Backend {
      * @param model The target model.
      * @param pagination A limit and offset to use for the query.
      * @param ctx The tracing context.
+     * @param skipDumpId A dump whose locations were already gathered. It is never searched again.
      */
     private async lookupMoniker(
         dumpId: pgModels.DumpId,
@@ -716,19 +1404,20 @@ export class Backend {
         moniker: sqliteModels.MonikerData,
         model: typeof sqliteModels.DefinitionModel | typeof sqliteModels.ReferenceModel,
         pagination: { skip?: number; take?: number },
-        ctx: TracingContext = {}
+        ctx: TracingContext = {},
+        skipDumpId?: pgModels.DumpId
     ): Promise<{ locations: InternalLocation[]; count: number }> {
         const packageInformation = await this.lookupPackageInformation(dumpId, path, moniker, ctx)
         if (!packageInformation) {
             return { locations: [], count: 0 }
         }
 
-        const packageEntity = await this.dependencyManager.getPackage(
+        const packageEntity = await this.dependencyStore.getPackage(
             moniker.scheme,
             packageInformation.name,
             packageInformation.version
         )
-        if (!packageEntity) {
+        if (!packageEntity || packageEntity.dump.id === skipDumpId) {
             return { locations: [], count: 0 }
         }
 
@@ -739,6 +1428,10 @@ export class Backend {
             packageCommit: packageEntity.dump.commit,
         })
 
+        metrics.queryRemoteDumpsCounter
+            .labels(model === sqliteModels.DefinitionModel ? 'definitions' : 'references')
+            .inc()
+
         const { locations, count } = await this.createDatabase(packageEntity.dump.id).monikerResults(
             model,
             moniker,
@@ -796,12 +1489,16 @@ export class Backend {
     private async closestDatabase(
         dumpId: number,
         ctx: TracingContext = {}
-    ): Promise<{ dump: pgModels.LsifDump; database: Database; ctx: TracingContext } | undefined> {
-        const dumpAndDatabase = await this.getDumpAndDatabaseById(dumpId)
+    ): Promise<DumpAndDatabase | undefined> {
+        const dumpAndDatabase = await this.getDumpAndDatabaseById(dumpId, ctx)
         if (!dumpAndDatabase) {
             return undefined
         }
 
+        if (ctx.debug) {
+            ctx.debug.recordDump(dumpAndDatabase.dump)
+        }
+
         return { ...dumpAndDatabase, ctx: addTags(ctx, { closestCommit: dumpAndDatabase.dump.commit }) }
     }
 
@@ -825,40 +1522,48 @@ export class Backend {
         commit: string,
         path: string,
         ctx: TracingContext = {}
-    ): Promise<{ dump: pgModels.LsifDump; database: Database; ctx: TracingContext }[]> {
+    ): Promise<{ dump: LsifDumpWithDistance; database: Database; ctx: TracingContext }[]> {
         // Find all closest dumps. Each database is guaranteed to have a root that is a
         // prefix of the given path, but does not guarantee that the path actually exists
         // in that dump.
 
-        const closestDumps = await this.dumpManager.findClosestDumps(repositoryId, commit, path, ctx, this.frontendUrl)
-
-        // Concurrently ensure that each database contains the target file. If it does
-        // not contain data for that file, return undefined and filter it from the list
-        // before returning.
-
-        return (
-            await Promise.all(
-                closestDumps.map(async dump => {
-                    const database = this.createDatabase(dump.id)
-                    const taggedCtx = addTags(ctx, { closestCommit: dump.commit })
+        const closestDumps = await this.dumpStore.findClosestDumps(repositoryId, commit, path, ctx, this.frontendUrl)
+        if (closestDumps.length === 0) {
+            return []
+        }
 
-                    return (await database.exists(pathToDatabase(dump.root, path), taggedCtx))
-                        ? { database, dump, ctx: taggedCtx }
-                        : undefined
-                })
-            )
-        ).filter(isDefined)
+        const databases = closestDumps.map(dump => ({
+            dump,
+            database: this.createDatabase(dump.id),
+            ctx: addTags(ctx, { closestCommit: dump.commit }),
+        }))
+        const checks = closestDumps.map(dump => ({ dumpId: dump.id, path: pathToDatabase(dump.root, path) }))
+
+        // Ensure that each database contains the target file with a single request to the
+        // bundle manager. Older bundle managers do not support batched existence checks, in
+        // which case we check each database individually. Databases that do not contain data
+        // for the file are filtered from the list before returning.
+
+        const exists =
+            (await this.batchExists(checks, ctx)) ||
+            (await mapConcurrently(databases, MAX_CONCURRENT_EXISTS_REQUESTS, ({ database, ctx: taggedCtx }, i) =>
+                database.exists(checks[i].path, taggedCtx)
+            ))
+
+        return databases.filter((_, i) => exists[i])
     }
 
     /**
      * Create a database for the dump with the given identifier.
      *
      * @param dumpId The dump id.
+     * @param ctx The tracing context.
      */
     private async getDumpAndDatabaseById(
-        dumpId: number
+        dumpId: number,
+        ctx: TracingContext = {}
     ): Promise<{ dump: pgModels.LsifDump; database: Database } | undefined> {
-        const dump = await this.dumpManager.getDumpById(dumpId)
+        const dump = await this.dumpStore.getDumpById(dumpId, ctx)
         if (!dump) {
             return undefined
         }
@@ -866,9 +1571,20 @@ export class Backend {
         return { dump, database: this.createDatabase(dump.id) }
     }
 
-    /** Bulk populate the dump model for internal locations. */
-    private async resolveLocations(locations: InternalLocation[]): Promise<ResolvedInternalLocation[]> {
-        const dumps = await this.dumpManager.getDumpsByIds(Array.from(new Set(locations.map(({ dumpId }) => dumpId))))
+    /**
+     * Bulk populate the dump model for internal locations.
+     *
+     * @param locations The internal locations.
+     * @param ctx The tracing context.
+     */
+    private async resolveLocations(
+        locations: InternalLocation[],
+        ctx: TracingContext = {}
+    ): Promise<ResolvedInternalLocation[]> {
+        const dumps = await this.dumpStore.getDumpsByIds(
+            Array.from(new Set(locations.map(({ dumpId }) => dumpId))),
+            ctx
+        )
 
         const resolvedLocations: ResolvedInternalLocation[] = []
         for (const { dumpId, path, range } of locations) {
@@ -884,6 +1600,26 @@ export class Backend {
     }
 }
 
+/**
+ * Record the phase and dump that produced each of the given locations in the debug log of
+ * the tracing context, if one is attached. Returns the locations unchanged.
+ *
+ * @param ctx The tracing context.
+ * @param phase The phase of the query that produced the locations.
+ * @param locations The resolved locations.
+ */
+function recordResults(
+    ctx: TracingContext,
+    phase: string,
+    locations: ResolvedInternalLocation[]
+): ResolvedInternalLocation[] {
+    if (ctx.debug) {
+        ctx.debug.recordResults(phase, locations.map(({ dump }) => dump.id))
+    }
+
+    return locations
+}
+
 /**
  * Converts a file in the repository to the corresponding file in the
  * database.
@@ -943,3 +1679,40 @@ export function sortMonikers(monikers: sqliteModels.MonikerData[]): sqliteModels
     monikers.sort((a, b) => monikerKindPreferences.indexOf(a.kind) - monikerKindPreferences.indexOf(b.kind))
     return monikers
 }
+
+/**
+ * Compare two dumps returned from a closest dump query. Dumps with a smaller commit
+ * distance come first. Ties are broken by preferring deeper roots, then by indexer
+ * name, then by dump identifier.
+ *
+ * @param a The first dump.
+ * @param b The second dump.
+ */
+export function compareDumps(a: LsifDumpWithDistance, b: LsifDumpWithDistance): number {
+    return (
+        a.distance - b.distance ||
+        b.root.length - a.root.length ||
+        a.indexer.localeCompare(b.indexer) ||
+        a.id - b.id
+    )
+}
+
+/**
+ * Determine if locations in the given repository may be returned to the requesting user.
+ * Locations in the queried repository may always be returned.
+ *
+ * @param repositoryId The identifier of the queried repository.
+ * @param allowedRepositoryIds The repositories that the requesting user may read (undefined means all).
+ * @param candidateRepositoryId The identifier of the repository containing the location.
+ */
+export function isRepositoryAllowed(
+    repositoryId: number,
+    allowedRepositoryIds: Set<number> | undefined,
+    candidateRepositoryId: number
+): boolean {
+    return (
+        !allowedRepositoryIds ||
+        candidateRepositoryId === repositoryId ||
+        allowedRepositoryIds.has(candidateRepositoryId)
+    )
+}
@@ -0,0 +1,85 @@
+import { QueryResultCache } from './cache'
+
+describe('QueryResultCache', () => {
+    const position = (line: number) => ({ dumpId: 1, path: 'foo.ts', position: { line, character: 5 } })
+
+    it('should cache results by position', async () => {
+        const cache = new QueryResultCache(10, 60)
+        const factory = jest.fn(() => Promise.resolve('foo'))
+
+        expect(await cache.withValue('hover', position(1), 42, factory)).toEqual('foo')
+        expect(await cache.withValue('hover', position(1), 42, factory)).toEqual('foo')
+        expect(await cache.withValue('hover', position(2), 42, factory)).toEqual('foo')
+        expect(await cache.withValue('definitions', position(2), 42, factory)).toEqual('foo')
+        expect(factory).toHaveBeenCalledTimes(3)
+    })
+
+    it('should share concurrent computations', async () => {
+        const cache = new QueryResultCache(10, 60)
+        const factory = jest.fn(() => Promise.resolve('foo'))
+
+        const values = await Promise.all([
+            cache.withValue('hover', position(1), 42, factory),
+            cache.withValue('hover', position(1), 42, factory),
+        ])
+        expect(values).toEqual(['foo', 'foo'])
+        expect(factory).toHaveBeenCalledTimes(1)
+    })
+
+    it('should not cache undefined results or errors', async () => {
+        const cache = new QueryResultCache(10, 60)
+        const factory1 = jest.fn(() => Promise.resolve(undefined))
+        const factory2 = jest.fn(() => Promise.reject(new Error('oops')))
+
+        await cache.withValue('hover', position(1), 42, factory1)
+        await cache.withValue('hover', position(1), 42, factory1)
+        await expect(cache.withValue('hover', position(2), 42, factory2)).rejects.toThrow('oops')
+        await expect(cache.withValue('hover', position(2), 42, factory2)).rejects.toThrow('oops')
+        expect(factory1).toHaveBeenCalledTimes(2)
+        expect(factory2).toHaveBeenCalledTimes(2)
+    })
+
+    it('should evict the least recently used result', async () => {
+        const cache = new QueryResultCache(2, 60)
+        const factory = jest.fn(() => Promise.resolve('foo'))
+
+        await cache.withValue('hover', position(1), 42, factory)
+        await cache.withValue('hover', position(2), 42, factory)
+        await cache.withValue('hover', position(1), 42, factory)
+        await cache.withValue('hover', position(3), 42, factory)
+        expect(factory).toHaveBeenCalledTimes(3)
+
+        await cache.withValue('hover', position(1), 42, factory)
+        expect(factory).toHaveBeenCalledTimes(3)
+        await cache.withValue('hover', position(2), 42, factory)
+        expect(factory).toHaveBeenCalledTimes(4)
+    })
+
+    it('should expire old results', async () => {
+        const cache = new QueryResultCache(10, 0)
+        const factory = jest.fn(() => Promise.resolve('foo'))
+
+        await cache.withValue('hover', position(1), 42, factory)
+        await cache.withValue('hover', position(1), 42, factory)
+        expect(factory).toHaveBeenCalledTimes(2)
+    })
+
+    it('should invalidate results that depend on a repository', async () => {
+        const cache = new QueryResultCache(10, 60)
+        const factory = jest.fn(() => Promise.resolve([50]))
+        const dependencies = (value: number[]): number[] => value
+
+        await cache.withValue('definitions', position(1), 42, factory, dependencies)
+        await cache.withValue('definitions', position(2), 43, factory, dependencies)
+
+        cache.invalidateRepository(42)
+        await cache.withValue('definitions', position(1), 42, factory, dependencies)
+        await cache.withValue('definitions', position(2), 43, factory, dependencies)
+        expect(factory).toHaveBeenCalledTimes(3)
+
+        cache.invalidateRepository(50)
+        await cache.withValue('definitions', position(1), 42, factory, dependencies)
+        await cache.withValue('definitions', position(2), 43, factory, dependencies)
+        expect(factory).toHaveBeenCalledTimes(5)
+    })
+})
@@ -0,0 +1,168 @@
+import * as lsp from 'vscode-languageserver-protocol'
+import * as metrics from '../metrics'
+
+/** A cached query result. */
+interface CacheEntry {
+    /** The name of the operation that computed the result. */
+    operation: string
+
+    /** The promise that will resolve the query result. */
+    promise: Promise<unknown>
+
+    /** The time (in milliseconds since the epoch) that this entry was created. */
+    createdAt: number
+
+    /**
+     * The repositories whose dumps were used to compute the result. This initially
+     * contains only the queried repository, and is extended with the repositories of
+     * the dumps referenced by the result once it resolves.
+     */
+    repositoryIds: Set<number>
+}
+
+/** The position within a dump that a query result belongs to. */
+export interface QueryPosition {
+    /** The identifier of the queried dump. */
+    dumpId: number
+
+    /** The path of the document, relative to the repository root. */
+    path: string
+
+    /** The queried position. */
+    position: lsp.Position
+}
+
+/**
+ * An LRU cache of code intelligence query results keyed by operation, dump, path, and
+ * position. Resolving a definition can require queries to several bundles and to other
+ * dumps, and the same position is often queried repeatedly (e.g. when hovering over an
+ * identifier). Concurrent queries for the same key share a single computation.
+ *
+ * Results are stale once the set of dumps of a repository changes. Entries are removed
+ * by `invalidateRepository` when this process changes the dumps of a repository. Entries
+ * also expire after a fixed time so that changes made by other processes (such as the
+ * worker converting a new upload) are eventually observed.
+ */
+export class QueryResultCache {
+    /** A map from keys to entries ordered by last-touch (least recently used first). */
+    private entries = new Map<string, CacheEntry>()
+
+    /**
+     * Create a new `QueryResultCache`.
+     *
+     * @param max The maximum number of entries in the cache (<= 0 disables the cache).
+     * @param ttl The number of seconds after which an entry is no longer used.
+     */
+    constructor(private max: number, private ttl: number) {}
+
+    /**
+     * Return the cached result of the given operation at the given position. If there is no
+     * such result, compute it with `factory` and add it to the cache. Undefined results and
+     * errors are not cached.
+     *
+     * @param operation The name of the operation.
+     * @param queryPosition The queried dump, path, and position.
+     * @param repositoryId The identifier of the queried repository.
+     * @param factory The function used to compute the result.
+     * @param dependencies A function that returns the repositories referenced by the result.
+     */
+    public async withValue<T>(
+        operation: string,
+        { dumpId, path, position }: QueryPosition,
+        repositoryId: number,
+        factory: () => Promise<T | undefined>,
+        dependencies: (value: T) => number[] = () => []
+    ): Promise<T | undefined> {
+        if (this.max <= 0) {
+            return factory()
+        }
+
+        const key = JSON.stringify([operation, dumpId, path, position.line, position.character])
+
+        const entry = this.entries.get(key)
+        if (entry && Date.now() - entry.createdAt < this.ttl * 1000) {
+            // Move to the end of the iteration order
+            this.entries.delete(key)
+            this.entries.set(key, entry)
+            metrics.queryResultCacheEventsCounter.labels(operation, 'hit').inc()
+            return entry.promise as Promise<T | undefined>
+        }
+
+        if (entry) {
+            this.remove(key)
+            metrics.queryResultCacheEventsCounter.labels(operation, 'expiration').inc()
+        }
+        metrics.queryResultCacheEventsCounter.labels(operation, 'miss').inc()
+
+        const promise = factory()
+        const newEntry = { operation, promise, createdAt: Date.now(), repositoryIds: new Set([repositoryId]) }
+        this.entries.set(key, newEntry)
+        metrics.queryResultCacheSizeGauge.set(this.entries.size)
+
+        for (const oldestKey of this.entries.keys()) {
+            if (this.entries.size <= this.max) {
+                break
+            }
+
+            const { operation: oldestOperation } = this.entries.get(oldestKey) as CacheEntry
+            this.remove(oldestKey)
+            metrics.queryResultCacheEventsCounter.labels(oldestOperation, 'eviction').inc()
+        }
+
+        let value: T | undefined
+        try {
+            value = await promise
+        } catch (error) {
+            this.removeIfCurrent(key, newEntry)
+            throw error
+        }
+
+        if (value === undefined) {
+            this.removeIfCurrent(key, newEntry)
+        } else {
+            for (const id of dependencies(value)) {
+                newEntry.repositoryIds.add(id)
+            }
+        }
+
+        return value
+    }
+
+    /**
+     * Remove all results computed from the dumps of the given repository. This should be
+     * called whenever the set of dumps (or their visibility) of a repository changes.
+     *
+     * @param repositoryId The repository identifier.
+     */
+    public invalidateRepository(repositoryId: number): void {
+        for (const [key, entry] of this.entries) {
+            if (entry.repositoryIds.has(repositoryId)) {
+                this.remove(key)
+                metrics.queryResultCacheEventsCounter.labels(entry.operation, 'invalidation').inc()
+            }
+        }
+    }
+
+    /**
+     * Remove the given entry unless it has already been replaced or removed, e.g. by an
+     * invalidation that happened while its result was being computed.
+     *
+     * @param key The cache key.
+     * @param entry The cache entry.
+     */
+    private removeIfCurrent(key: string, entry: CacheEntry): void {
+        if (this.entries.get(key) === entry) {
+            this.remove(key)
+        }
+    }
+
+    /**
+     * Remove the entry with the given key and update the size gauge.
+     *
+     * @param key The cache key.
+     */
+    private remove(key: string): void {
+        this.entries.delete(key)
+        metrics.queryResultCacheSizeGauge.set(this.entries.size)
+    }
+}
@@ -8,6 +8,13 @@ export interface ReferencePaginationContext {
 
     /** Context describing the next page of results. */
     cursor?: ReferencePaginationCursor
+
+    /**
+     * The repositories that the requesting user may read. Locations in other repositories are
+     * not returned, except for locations in the queried repository. All repositories may be
+     * read when this is undefined.
+     */
+    allowedRepositoryIds?: Set<number>
 }
 
 /** Context describing the next page of results. */
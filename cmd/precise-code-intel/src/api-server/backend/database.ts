@@ -1,11 +1,277 @@
 import * as sqliteModels from '../../shared/models/sqlite'
 import * as lsp from 'vscode-languageserver-protocol'
 import * as pgModels from '../../shared/models/pg'
-import { TracingContext } from '../../shared/tracing'
+import { addTags, logAndTraceCall, TracingContext, tracingHeaders } from '../../shared/tracing'
+import { authorizationHeaders } from '../../shared/api/middleware/auth'
+import { CancelledError, withCancellation } from '../../shared/cancellation'
 import { parseJSON } from '../../shared/encoding/json'
+import { BundleManagerStats, combineBundleManagerStats } from '../../shared/stats'
+import { BundleVerification } from '../../shared/verification'
+import { ShardRing } from '../../shared/shards'
 import * as settings from '../settings'
-import got from 'got'
+import * as metrics from '../metrics'
+import { Response } from 'got'
+import { createBundleManagerClient } from '../../shared/api/client'
+import { CircuitBreaker } from '../../shared/circuit-breaker'
 import { InternalLocation, OrderedLocationSet } from './location'
+import {
+    BundleManagerCapabilities,
+    BundleManagerCapability,
+    LEGACY_BUNDLE_MANAGER_CAPABILITIES,
+    versionedRoute,
+} from '../../shared/capabilities'
+import { decodeHover, decodeHovers, HoverData } from '../../shared/encoding/hover'
+import { slicePage } from '../../shared/api/pagination/slice'
+import { BundleManagerGrpcClient } from '../../shared/grpc/client'
+import { BundleManagerMethods, decodeMoniker, UnaryMethod } from '../../shared/grpc/bundle-manager'
+import { BUNDLE_MANAGER_TRANSPORT } from '../../shared/config/settings'
+import delay from 'delay'
+
+/** The bundle manager shards across which bundles are distributed by dump identifier. */
+const shards = new ShardRing(settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS)
+
+/** The HTTP client used for all requests to the bundle managers. */
+export const bundleManagerClient = createBundleManagerClient()
+
+/**
+ * The gRPC client used for the bundle queries that have a gRPC method, if the gRPC transport
+ * is enabled. Other requests are sent over HTTP.
+ */
+const bundleManagerGrpcClient = BUNDLE_MANAGER_TRANSPORT === 'grpc' ? new BundleManagerGrpcClient() : undefined
+
+/**
+ * Return the URL of the bundle manager that stores the bundle of the given dump.
+ *
+ * @param dumpId The identifier of the dump.
+ */
+export function bundleManagerUrl(dumpId: pgModels.DumpId): string {
+    return shards.shardFor(dumpId)
+}
+
+/**
+ * Determine if an error returned from a bundle manager request indicates that the bundle
+ * manager is unhealthy. Client errors and cancelled requests do not.
+ *
+ * @param error The error thrown by got.
+ */
+function isBundleManagerFailure(error: unknown): boolean {
+    if (error instanceof CancelledError) {
+        return false
+    }
+
+    // Errors of gRPC calls carry the status the HTTP API would have responded with
+    const { response, status } = error as { response?: { statusCode: number }; status?: number }
+    const statusCode = response ? response.statusCode : status
+    return statusCode === undefined || statusCode >= 500
+}
+
+/** A circuit breaker for each bundle manager shard, indexed by URL. */
+const circuitBreakers = new Map(
+    shards.urls.map(url => [
+        url,
+        new CircuitBreaker(
+            `Bundle manager ${url}`,
+            settings.BUNDLE_MANAGER_CIRCUIT_BREAKER_THRESHOLD,
+            settings.BUNDLE_MANAGER_CIRCUIT_BREAKER_COOLDOWN * 1000,
+            isBundleManagerFailure
+        ),
+    ])
+)
+
+/**
+ * Invoke the given function, which sends a request to the given bundle manager, through the
+ * circuit breaker of that bundle manager.
+ *
+ * @param shard The URL of the bundle manager.
+ * @param f The function that sends the request.
+ */
+function withCircuitBreaker<T>(shard: string, f: () => Promise<T>): Promise<T> {
+    const circuitBreaker = circuitBreakers.get(shard)
+    return circuitBreaker ? circuitBreaker.call(f) : f()
+}
+
+/**
+ * Return the delay (in milliseconds) before the given retry of a bundle manager request.
+ *
+ * @param attemptCount The number of the retry (starting at 1).
+ */
+function retryDelay(attemptCount: number): number {
+    return Math.min(
+        settings.BUNDLE_MANAGER_RETRY_BASE_DELAY_MS * 2 ** (attemptCount - 1),
+        settings.BUNDLE_MANAGER_RETRY_MAX_DELAY_MS
+    )
+}
+
+/**
+ * Send a GET request to a bundle manager. Requests that fail with a network error, a timeout,
+ * or a 5xx response are retried with exponential backoff.
+ *
+ * @param shard The URL of the bundle manager.
+ * @param url The URL of the request.
+ * @param ctx The tracing context.
+ */
+function getFromBundleManager(shard: string, url: URL, ctx: TracingContext): Promise<Response<string>> {
+    return withCircuitBreaker(shard, () =>
+        withCancellation(ctx.cancellation, () =>
+            bundleManagerClient.get(url.href, {
+                headers: { ...tracingHeaders(ctx), ...authorizationHeaders() },
+                timeout: { request: settings.BUNDLE_MANAGER_REQUEST_TIMEOUT * 1000 },
+                retry: {
+                    limit: settings.BUNDLE_MANAGER_MAX_RETRIES,
+                    methods: ['GET'],
+                    calculateDelay: ({ attemptCount, computedValue }) =>
+                        computedValue === 0 ? 0 : retryDelay(attemptCount),
+                },
+                hooks: { beforeRetry: [() => metrics.bundleManagerRequestRetriesCounter.inc()] },
+            })
+        )
+    )
+}
+
+/**
+ * Send a POST request with a JSON payload to a bundle manager. These requests are not retried.
+ *
+ * @param shard The URL of the bundle manager.
+ * @param url The URL of the request.
+ * @param payload The request payload.
+ * @param ctx The tracing context.
+ */
+function postToBundleManager<T>(shard: string, url: URL, payload: T, ctx: TracingContext): Promise<Response<string>> {
+    return withCircuitBreaker(shard, () =>
+        withCancellation(ctx.cancellation, () =>
+            bundleManagerClient.post(url.href, {
+                headers: { ...tracingHeaders(ctx), ...authorizationHeaders(), 'Content-Type': 'application/json' },
+                body: JSON.stringify(payload),
+                timeout: { request: settings.BUNDLE_MANAGER_REQUEST_TIMEOUT * 1000 },
+                retry: 0,
+            })
+        )
+    )
+}
+
+/**
+ * Call a method of the gRPC API of a bundle manager. Calls that fail with a network error, a
+ * timeout, or a server error are retried with exponential backoff, as are GET requests (see
+ * getFromBundleManager). Calls of a method the bundle manager does not implement are not.
+ *
+ * @param client The gRPC client.
+ * @param shard The URL of the bundle manager.
+ * @param method The name of the method.
+ * @param request The request message.
+ * @param ctx The tracing context.
+ */
+function callBundleManager<M extends UnaryMethod>(
+    client: BundleManagerGrpcClient,
+    shard: string,
+    method: M,
+    request: BundleManagerMethods[M][0],
+    ctx: TracingContext
+): Promise<BundleManagerMethods[M][1]> {
+    return withCircuitBreaker(shard, async () => {
+        for (let attemptCount = 1; ; attemptCount++) {
+            try {
+                return await client.call(shard, method, request, {
+                    timeoutMs: settings.BUNDLE_MANAGER_REQUEST_TIMEOUT * 1000,
+                    ctx,
+                })
+            } catch (error) {
+                if (
+                    attemptCount > settings.BUNDLE_MANAGER_MAX_RETRIES ||
+                    !isBundleManagerFailure(error) ||
+                    error.status === 501
+                ) {
+                    throw error
+                }
+
+                metrics.bundleManagerRequestRetriesCounter.inc()
+                await delay(retryDelay(attemptCount))
+            }
+        }
+    })
+}
+
+/** The capabilities of each bundle manager shard and the time they expire, indexed by URL. */
+const capabilitiesCache = new Map<string, { capabilities: Promise<BundleManagerCapabilities>; expiresAt: number }>()
+
+/**
+ * Retrieve the capabilities of the given bundle manager. Capabilities are cached for
+ * BUNDLE_MANAGER_CAPABILITIES_TTL seconds so that an upgraded bundle manager is detected.
+ * A bundle manager without a capability discovery endpoint is assumed to only serve the
+ * unversioned routes.
+ *
+ * @param shard The URL of the bundle manager.
+ * @param ctx The tracing context.
+ */
+export function getBundleManagerCapabilities(
+    shard: string,
+    ctx: TracingContext = {}
+): Promise<BundleManagerCapabilities> {
+    const cached = capabilitiesCache.get(shard)
+    if (cached && cached.expiresAt > Date.now()) {
+        return cached.capabilities
+    }
+
+    const capabilities = (async () => {
+        try {
+            const resp = await getFromBundleManager(shard, new URL('/v1/capabilities', shard), ctx)
+            return parseJSON<BundleManagerCapabilities>(resp.body)
+        } catch (error) {
+            if (error.response && error.response.statusCode === 404) {
+                return LEGACY_BUNDLE_MANAGER_CAPABILITIES
+            }
+
+            throw error
+        }
+    })()
+
+    // Do not remember failures, the next request should try again
+    capabilities.catch(() => capabilitiesCache.delete(shard))
+
+    capabilitiesCache.set(shard, {
+        capabilities,
+        expiresAt: Date.now() + settings.BUNDLE_MANAGER_CAPABILITIES_TTL * 1000,
+    })
+    return capabilities
+}
+
+/**
+ * The capabilities a bundle manager must have to serve each database method. Methods that are
+ * not listed are supported by every bundle manager.
+ */
+const requiredCapabilities = new Map<string, BundleManagerCapability>([
+    ['implementations', 'implementations'],
+    ['hovers', 'hovers'],
+    ['ranges', 'ranges'],
+    ['documentSymbols', 'documentSymbols'],
+    ['diagnostics', 'diagnostics'],
+    ['verify', 'verify'],
+])
+
+/**
+ * Return the URL of the given database method on a bundle manager with the given capabilities.
+ * Throws an error with a 501 status if the bundle manager does not support the method.
+ *
+ * @param shard The URL of the bundle manager.
+ * @param capabilities The capabilities of the bundle manager.
+ * @param dumpId The identifier of the dump.
+ * @param method The name of the database method.
+ */
+function databaseMethodUrl(
+    shard: string,
+    capabilities: BundleManagerCapabilities,
+    dumpId: pgModels.DumpId,
+    method: string
+): URL {
+    const capability = requiredCapabilities.get(method)
+    if (capability && !capabilities.capabilities.includes(capability)) {
+        throw Object.assign(new Error(`Bundle manager ${shard} does not support ${method} queries`), {
+            status: 501,
+            code: 'unsupported_by_bundle_manager',
+        })
+    }
+
+    return new URL(versionedRoute(capabilities, `/dbs/${dumpId}/${method}`), shard)
+}
 
 /** A wrapper around operations related to a single SQLite dump. */
 export class Database {
@@ -17,7 +283,12 @@ export class Database {
      * @param path The path of the document.
      * @param ctx The tracing context.
      */
-    public exists(path: string, ctx: TracingContext = {}): Promise<boolean> {
+    public async exists(path: string, ctx: TracingContext = {}): Promise<boolean> {
+        if (bundleManagerGrpcClient) {
+            const { exists } = await this.call(bundleManagerGrpcClient, 'Exists', { dumpId: this.dumpId, path }, ctx)
+            return exists
+        }
+
         return this.request('exists', new URLSearchParams({ path }), ctx)
     }
 
@@ -33,6 +304,17 @@ export class Database {
         position: lsp.Position,
         ctx: TracingContext = {}
     ): Promise<InternalLocation[]> {
+        if (bundleManagerGrpcClient) {
+            const { locations } = await this.call(
+                bundleManagerGrpcClient,
+                'Definitions',
+                { dumpId: this.dumpId, path, position, skip: 0, take: null },
+                ctx
+            )
+
+            return locations.map(location => ({ ...location, dumpId: this.dumpId }))
+        }
+
         const locations = await this.request<{ path: string; range: lsp.Range }[]>(
             'definitions',
             new URLSearchParams({ path, line: String(position.line), character: String(position.character) }),
@@ -43,24 +325,79 @@ export class Database {
     }
 
     /**
-     * Return a list of unique locations that reference the symbol at the given position.
+     * Return a page of the unique locations that reference the symbol at the given position,
+     * along with the total number of such locations. Bundle managers that cannot paginate
+     * locations return all of them, in which case the page is taken here.
      *
      * @param path The path of the document to which the position belongs.
      * @param position The current hover position.
+     * @param pagination A limit and offset to use for the query.
      * @param ctx The tracing context.
      */
     public async references(
         path: string,
         position: lsp.Position,
+        { skip = 0, take }: { skip?: number; take?: number },
         ctx: TracingContext = {}
-    ): Promise<OrderedLocationSet> {
+    ): Promise<{ locations: OrderedLocationSet; count: number }> {
+        const searchParams = new URLSearchParams({
+            path,
+            line: String(position.line),
+            character: String(position.character),
+        })
+        const toLocationSet = (locations: { path: string; range: lsp.Range }[]): OrderedLocationSet =>
+            new OrderedLocationSet(locations.map(location => ({ ...location, dumpId: this.dumpId })))
+
+        if (bundleManagerGrpcClient) {
+            const { locations, count } = await this.call(
+                bundleManagerGrpcClient,
+                'References',
+                { dumpId: this.dumpId, path, position, skip, take: take === undefined ? null : { value: take } },
+                ctx
+            )
+
+            return { locations: toLocationSet(locations), count }
+        }
+
+        const capabilities = await getBundleManagerCapabilities(bundleManagerUrl(this.dumpId), ctx)
+        if (capabilities.capabilities.includes('paginatedLocations')) {
+            searchParams.set('skip', String(skip))
+            if (take !== undefined) {
+                searchParams.set('take', String(take))
+            }
+
+            const { locations, count } = await this.request<{
+                locations: { path: string; range: lsp.Range }[]
+                count: number
+            }>('references', searchParams, ctx)
+
+            return { locations: toLocationSet(locations), count }
+        }
+
+        const locations = await this.request<{ path: string; range: lsp.Range }[]>('references', searchParams, ctx)
+        const { page } = slicePage(locations, skip, take === undefined ? locations.length : take)
+        return { locations: toLocationSet(page), count: locations.length }
+    }
+
+    /**
+     * Return a list of locations that implement the symbol at the given position.
+     *
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param ctx The tracing context.
+     */
+    public async implementations(
+        path: string,
+        position: lsp.Position,
+        ctx: TracingContext = {}
+    ): Promise<InternalLocation[]> {
         const locations = await this.request<{ path: string; range: lsp.Range }[]>(
-            'references',
+            'implementations',
             new URLSearchParams({ path, line: String(position.line), character: String(position.character) }),
             ctx
         )
 
-        return new OrderedLocationSet(locations.map(location => ({ ...location, dumpId: this.dumpId })))
+        return locations.map(location => ({ ...location, dumpId: this.dumpId }))
     }
 
     /**
@@ -70,16 +407,41 @@ export class Database {
      * @param position The current hover position.
      * @param ctx The tracing context.
      */
-    public hover(
+    public async hover(path: string, position: lsp.Position, ctx: TracingContext = {}): Promise<HoverData | null> {
+        if (bundleManagerGrpcClient) {
+            const { hover } = await this.call(
+                bundleManagerGrpcClient,
+                'Hover',
+                { dumpId: this.dumpId, path, position },
+                ctx
+            )
+
+            return hover
+        }
+
+        return decodeHover(
+            await this.request<unknown>(
+                'hover',
+                new URLSearchParams({ path, line: String(position.line), character: String(position.character) }),
+                ctx
+            )
+        )
+    }
+
+    /**
+     * Return the hover content for the symbols at each of the given positions. The resulting
+     * list is aligned with the input positions.
+     *
+     * @param path The path of the document to which the positions belong.
+     * @param positions The hover positions.
+     * @param ctx The tracing context.
+     */
+    public async hovers(
         path: string,
-        position: lsp.Position,
+        positions: lsp.Position[],
         ctx: TracingContext = {}
-    ): Promise<{ text: string; range: lsp.Range } | null> {
-        return this.request(
-            'hover',
-            new URLSearchParams({ path, line: String(position.line), character: String(position.character) }),
-            ctx
-        )
+    ): Promise<(HoverData | null)[]> {
+        return decodeHovers(await this.requestWithBody<unknown, unknown>('hovers', path, { path, positions }, ctx))
     }
 
     /**
@@ -91,11 +453,22 @@ export class Database {
      * @param position The user's hover position.
      * @param ctx The tracing context.
      */
-    public monikersByPosition(
+    public async monikersByPosition(
         path: string,
         position: lsp.Position,
         ctx: TracingContext = {}
     ): Promise<sqliteModels.MonikerData[][]> {
+        if (bundleManagerGrpcClient) {
+            const { ranges } = await this.call(
+                bundleManagerGrpcClient,
+                'MonikersByPosition',
+                { dumpId: this.dumpId, path, position },
+                ctx
+            )
+
+            return ranges.map(({ monikers }) => monikers.map(decodeMoniker))
+        }
+
         return this.request(
             'monikersByPosition',
             new URLSearchParams({ path, line: String(position.line), character: String(position.character) }),
@@ -119,6 +492,24 @@ export class Database {
         pagination: { skip?: number; take?: number },
         ctx: TracingContext = {}
     ): Promise<{ locations: InternalLocation[]; count: number }> {
+        if (bundleManagerGrpcClient) {
+            const { locations, count } = await this.call(
+                bundleManagerGrpcClient,
+                'MonikerResults',
+                {
+                    dumpId: this.dumpId,
+                    model: model === sqliteModels.DefinitionModel ? 'DEFINITION' : 'REFERENCE',
+                    scheme: moniker.scheme,
+                    identifier: moniker.identifier,
+                    skip: pagination.skip || 0,
+                    take: pagination.take === undefined ? null : { value: pagination.take },
+                },
+                ctx
+            )
+
+            return { locations: locations.map(location => ({ ...location, dumpId: this.dumpId })), count }
+        }
+
         let p: {} | { skip: string } | { take: string } | { skip: string; take: string } = {}
         if (pagination.skip !== undefined) {
             p = { ...p, skip: String(pagination.skip) }
@@ -151,11 +542,27 @@ export class Database {
      * @param packageInformationId The identifier of the package information data.
      * @param ctx The tracing context.
      */
-    public packageInformation(
+    public async packageInformation(
         path: string,
         packageInformationId: sqliteModels.PackageInformationId,
         ctx: TracingContext = {}
     ): Promise<sqliteModels.PackageInformationData | undefined> {
+        if (bundleManagerGrpcClient) {
+            const { packageInformation } = await this.call(
+                bundleManagerGrpcClient,
+                'PackageInformation',
+                { dumpId: this.dumpId, path, packageInformationId: String(packageInformationId) },
+                ctx
+            )
+
+            if (!packageInformation) {
+                return undefined
+            }
+
+            const { name, version } = packageInformation
+            return { name, version: version ? version.value : null }
+        }
+
         return this.request(
             'packageInformation',
             new URLSearchParams({ path, packageInformationId: String(packageInformationId) }),
@@ -163,13 +570,263 @@ export class Database {
         )
     }
 
+    /**
+     * Return a summary of the code intelligence available for each range of a document that
+     * intersects the given (inclusive) window of lines, ordered by position.
+     *
+     * @param path The path of the document.
+     * @param startLine The first line of the window (0-indexed, inclusive).
+     * @param endLine The last line of the window (0-indexed, inclusive).
+     * @param ctx The tracing context.
+     */
+    public ranges(
+        path: string,
+        startLine: number,
+        endLine: number,
+        ctx: TracingContext = {}
+    ): Promise<sqliteModels.RangeSummaryData[]> {
+        return this.request(
+            'ranges',
+            new URLSearchParams({ path, startLine: String(startLine), endLine: String(endLine) }),
+            ctx
+        )
+    }
+
+    /**
+     * Return the symbol outline of a document, ordered by position.
+     *
+     * @param path The path of the document.
+     * @param ctx The tracing context.
+     */
+    public documentSymbols(path: string, ctx: TracingContext = {}): Promise<sqliteModels.DocumentSymbolData[]> {
+        return this.request('documentSymbols', new URLSearchParams({ path }), ctx)
+    }
+
+    /**
+     * Return the diagnostics reported by the indexer for this dump. If a path is supplied,
+     * only the diagnostics of that document are returned.
+     *
+     * @param path The path of the document, if diagnostics should be restricted to one document.
+     * @param pagination A limit and offset to use for the query.
+     * @param ctx The tracing context.
+     */
+    public diagnostics(
+        path: string | undefined,
+        pagination: { skip?: number; take?: number },
+        ctx: TracingContext = {}
+    ): Promise<{ diagnostics: sqliteModels.DiagnosticData[]; count: number }> {
+        const searchParams = new URLSearchParams()
+        if (path !== undefined) {
+            searchParams.set('path', path)
+        }
+        if (pagination.skip !== undefined) {
+            searchParams.set('skip', String(pagination.skip))
+        }
+        if (pagination.take !== undefined) {
+            searchParams.set('take', String(pagination.take))
+        }
+
+        return this.request('diagnostics', searchParams, ctx)
+    }
+
+    /**
+     * Check the integrity of the bundle of this dump. Failed checks are reported in the
+     * result rather than as an error.
+     *
+     * @param ctx The tracing context.
+     */
+    public verify(ctx: TracingContext = {}): Promise<BundleVerification> {
+        return this.requestWithBody('verify', null, {}, ctx)
+    }
+
     //
     //
 
-    private async request<T>(method: string, searchParams: URLSearchParams, ctx: TracingContext): Promise<T> {
-        const url = new URL(`/dbs/${this.dumpId}/${method}`, settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL)
-        url.search = searchParams.toString()
-        const resp = await got.get(url.href)
-        return parseJSON(resp.body)
+    private request<T>(method: string, searchParams: URLSearchParams, ctx: TracingContext): Promise<T> {
+        const shard = bundleManagerUrl(this.dumpId)
+
+        return this.traceRequest(method, shard, searchParams.get('path'), ctx, async ctx => {
+            const url = databaseMethodUrl(shard, await getBundleManagerCapabilities(shard, ctx), this.dumpId, method)
+            url.search = searchParams.toString()
+
+            const resp = await getFromBundleManager(shard, url, ctx).catch(forwardClientError)
+            return parseJSON(resp.body)
+        })
+    }
+
+    private call<M extends UnaryMethod>(
+        client: BundleManagerGrpcClient,
+        method: M,
+        request: BundleManagerMethods[M][0] & { path?: string },
+        ctx: TracingContext
+    ): Promise<BundleManagerMethods[M][1]> {
+        const shard = bundleManagerUrl(this.dumpId)
+
+        return this.traceRequest(method, shard, request.path === undefined ? null : request.path, ctx, ctx =>
+            callBundleManager(client, shard, method, request, ctx)
+        )
+    }
+
+    private requestWithBody<T, R>(method: string, path: string | null, payload: T, ctx: TracingContext): Promise<R> {
+        const shard = bundleManagerUrl(this.dumpId)
+
+        return this.traceRequest(method, shard, path, ctx, async ctx => {
+            const url = databaseMethodUrl(shard, await getBundleManagerCapabilities(shard, ctx), this.dumpId, method)
+            const resp = await postToBundleManager(shard, url, payload, ctx).catch(forwardClientError)
+            return parseJSON(resp.body)
+        })
+    }
+
+    /**
+     * Invoke the given function, which sends a request to the bundle manager, in a child span
+     * tagged with this dump and the given path. The span of the request is propagated to the
+     * bundle manager so that the spans it creates are parented to the child span.
+     *
+     * @param method The name of the bundle operation.
+     * @param shard The URL of the bundle manager.
+     * @param path The path of the queried document, if any.
+     * @param ctx The tracing context.
+     * @param f The function that sends the request.
+     */
+    private traceRequest<T>(
+        method: string,
+        shard: string,
+        path: string | null,
+        ctx: TracingContext,
+        f: (ctx: TracingContext) => Promise<T>
+    ): Promise<T> {
+        return logAndTraceCall(ctx, `Querying bundle (${method})`, ctx =>
+            f(addTags(ctx, { dumpId: this.dumpId, ...(path === null ? {} : { path }), bundleManager: shard }))
+        )
+    }
+}
+
+/**
+ * Rethrow a client error response from the bundle manager (e.g. a missing or malformed
+ * bundle) as an error with the same status and error code so that it is reported to the
+ * caller as such instead of as an internal error. Other errors are rethrown unchanged.
+ *
+ * @param error The error thrown by got.
+ */
+function forwardClientError(error: {
+    response?: { statusCode: number; body: unknown }
+    message: string
+}): never {
+    const statusCode = error.response?.statusCode
+    if (statusCode === undefined || statusCode < 400 || statusCode >= 500 || statusCode === 499) {
+        throw error
+    }
+
+    let payload: { error?: string; code?: string } = {}
+    try {
+        payload = JSON.parse(String(error.response?.body))
+    } catch {
+        // Not a structured error response
+    }
+
+    throw Object.assign(new Error(payload.error || error.message), { status: statusCode, code: payload.code })
+}
+
+/**
+ * Determine if the given error was forwarded from a bundle manager response reporting that
+ * the bundle of the queried dump does not exist.
+ *
+ * @param error The error thrown by a bundle manager request.
+ */
+export function isBundleNotFoundError(error: unknown): boolean {
+    const { status, code } = error as { status?: number; code?: string }
+    return status === 404 && code === 'bundle_not_found'
+}
+
+/**
+ * Determine if data exists for each of the given documents with a single request to each
+ * bundle manager shard. The resulting list is aligned with the input checks. Resolves to
+ * undefined if any bundle manager is too old to support batched existence checks.
+ *
+ * @param checks The dump identifiers and document paths to check.
+ * @param ctx The tracing context.
+ */
+export async function existsBatch(
+    checks: { dumpId: pgModels.DumpId; path: string }[],
+    ctx: TracingContext = {}
+): Promise<boolean[] | undefined> {
+    // Group the checks by shard, remembering the position of each check in the input
+    const indexesByShard = new Map<string, number[]>()
+    for (const [index, { dumpId }] of checks.entries()) {
+        const shard = bundleManagerUrl(dumpId)
+        const indexes = indexesByShard.get(shard)
+        if (indexes) {
+            indexes.push(index)
+        } else {
+            indexesByShard.set(shard, [index])
+        }
     }
+
+    const resultsByShard = await Promise.all(
+        Array.from(indexesByShard.entries()).map(([shard, indexes]) =>
+            existsBatchOnShard(shard, indexes.map(index => checks[index]), ctx)
+        )
+    )
+
+    const results = new Array<boolean>(checks.length)
+    for (const [i, indexes] of Array.from(indexesByShard.values()).entries()) {
+        const shardResults = resultsByShard[i]
+        if (!shardResults) {
+            return undefined
+        }
+
+        for (const [j, index] of indexes.entries()) {
+            results[index] = shardResults[j]
+        }
+    }
+
+    return results
+}
+
+/**
+ * Determine if data exists for each of the given documents stored on a single bundle manager.
+ * Resolves to undefined if the bundle manager does not support batched existence checks.
+ *
+ * @param shard The URL of the bundle manager.
+ * @param checks The dump identifiers and document paths to check.
+ * @param ctx The tracing context.
+ */
+async function existsBatchOnShard(
+    shard: string,
+    checks: { dumpId: pgModels.DumpId; path: string }[],
+    ctx: TracingContext
+): Promise<boolean[] | undefined> {
+    return logAndTraceCall(ctx, 'Querying bundles (exists)', async ctx => {
+        ctx = addTags(ctx, { bundleManager: shard, numChecks: checks.length })
+
+        const capabilities = await getBundleManagerCapabilities(shard, ctx)
+        if (!capabilities.capabilities.includes('batchExists')) {
+            return undefined
+        }
+
+        const resp = await postToBundleManager(
+            shard,
+            new URL(versionedRoute(capabilities, '/dbs/exists'), shard),
+            { checks: checks.map(({ dumpId, path }) => ({ id: dumpId, path })) },
+            ctx
+        )
+
+        return parseJSON<boolean[]>(resp.body)
+    })
+}
+
+/**
+ * Retrieve the disk and cache usage of the bundle managers, combined over all shards.
+ *
+ * @param ctx The tracing context.
+ */
+export async function getBundleManagerStats(ctx: TracingContext = {}): Promise<BundleManagerStats> {
+    return combineBundleManagerStats(
+        await Promise.all(
+            shards.urls.map(async shard => {
+                const resp = await getFromBundleManager(shard, new URL('/stats', shard), ctx)
+                return parseJSON<BundleManagerStats>(resp.body)
+            })
+        )
+    )
 }
@@ -0,0 +1,65 @@
+import { formatHover, markdownToPlaintext, negotiateHoverFormat, sanitizeMarkdown } from './hover'
+
+describe('negotiateHoverFormat', () => {
+    it('should prefer the format parameter', () => {
+        expect(negotiateHoverFormat('plaintext', 'text/markdown')).toEqual('plaintext')
+    })
+
+    it('should fall back to the accept header', () => {
+        expect(negotiateHoverFormat(undefined, 'text/markdown')).toEqual('markdown')
+        expect(negotiateHoverFormat(undefined, 'application/json, text/plain;q=0.9')).toEqual('plaintext')
+    })
+
+    it('should default to raw', () => {
+        expect(negotiateHoverFormat(undefined, undefined)).toEqual('raw')
+        expect(negotiateHoverFormat(undefined, 'application/json')).toEqual('raw')
+    })
+})
+
+describe('sanitizeMarkdown', () => {
+    it('should escape html outside of code', () => {
+        expect(sanitizeMarkdown('a <b>bold</b> `<T>`\n```ts\nf<T>()\n```')).toEqual(
+            'a &lt;b>bold&lt;/b> `<T>`\n```ts\nf<T>()\n```'
+        )
+    })
+
+    it('should remove links with unsafe schemes', () => {
+        expect(sanitizeMarkdown('[docs](https://example.com) [x](javascript:void) [y](foo&#58;bar)')).toEqual(
+            '[docs](https://example.com) x y'
+        )
+    })
+})
+
+describe('markdownToPlaintext', () => {
+    it('should unwrap code blocks', () => {
+        expect(markdownToPlaintext('```go\nfunc f()\n```')).toEqual('func f()')
+    })
+
+    it('should remove markdown syntax', () => {
+        expect(markdownToPlaintext('# Title\n\n**bold** _it_ [link](http://x) `a*b*c`')).toEqual(
+            'Title\n\nbold it link a*b*c'
+        )
+    })
+})
+
+describe('formatHover', () => {
+    it('should not truncate short text', () => {
+        expect(formatHover('abcdef', 'raw', 100)).toEqual({ text: 'abcdef', truncated: false })
+        expect(formatHover('abcdef', 'raw', -1)).toEqual({ text: 'abcdef', truncated: false })
+    })
+
+    it('should truncate long text', () => {
+        expect(formatHover('x'.repeat(20), 'plaintext', 10)).toEqual({ text: 'xx…', truncated: true })
+    })
+
+    it('should close truncated code blocks', () => {
+        expect(formatHover('```ts\n' + 'a'.repeat(50) + '\n```', 'raw', 20)).toEqual({
+            text: '```ts\naaaaaa\n```\n…',
+            truncated: true,
+        })
+    })
+
+    it('should not split multi-byte characters', () => {
+        expect(formatHover('é'.repeat(10), 'raw', 11)).toEqual({ text: 'é…', truncated: true })
+    })
+})
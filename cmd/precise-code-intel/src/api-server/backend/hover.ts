@@ -0,0 +1,190 @@
+import { StringDecoder } from 'string_decoder'
+
+/**
+ * The formats in which hover text can be returned. Hover text is stored as Markdown by the
+ * worker (see `normalizeHover`). The raw format returns it unchanged, the markdown format
+ * returns it with embedded HTML and unsafe links neutralized, and the plaintext format
+ * returns it with Markdown syntax removed.
+ */
+export type HoverFormat = 'raw' | 'markdown' | 'plaintext'
+
+/** The valid values of the hover format query parameter. */
+export const HOVER_FORMATS: HoverFormat[] = ['raw', 'markdown', 'plaintext']
+
+/** The text appended to hover text that has been truncated. */
+const ELLIPSIS = '…'
+
+/** A formatted hover text. */
+export interface FormattedHover {
+    /** The hover text in the requested format. */
+    text: string
+
+    /** Whether the text was truncated to fit the byte limit. */
+    truncated: boolean
+}
+
+/**
+ * Determine the format in which to return hover text. An explicit format parameter takes
+ * precedence over the Accept header. Requests that specify neither receive the raw text.
+ *
+ * @param format The value of the format query parameter.
+ * @param accept The value of the Accept header.
+ */
+export function negotiateHoverFormat(format: HoverFormat | undefined, accept: string | undefined): HoverFormat {
+    if (format) {
+        return format
+    }
+
+    const mediaTypes = (accept || '').split(',').map(value => value.split(';')[0].trim().toLowerCase())
+    for (const mediaType of mediaTypes) {
+        if (mediaType === 'text/markdown') {
+            return 'markdown'
+        }
+        if (mediaType === 'text/plain') {
+            return 'plaintext'
+        }
+    }
+
+    return 'raw'
+}
+
+/**
+ * Convert the given hover text into the given format and truncate it to the given number
+ * of bytes (when encoded as UTF-8). Truncated text ends with an ellipsis, and a truncated
+ * Markdown code block is closed so that the remainder of the text is not rendered as code.
+ *
+ * @param text The hover text as stored in the bundle.
+ * @param format The requested format.
+ * @param maxBytes The maximum size of the text in bytes (< 0 means no limit).
+ */
+export function formatHover(text: string, format: HoverFormat, maxBytes: number): FormattedHover {
+    const formatted =
+        format === 'markdown' ? sanitizeMarkdown(text) : format === 'plaintext' ? markdownToPlaintext(text) : text
+
+    if (maxBytes < 0 || Buffer.byteLength(formatted) <= maxBytes) {
+        return { text: formatted, truncated: false }
+    }
+
+    // Leave room for the ellipsis and a closing fence
+    const suffixBytes = Buffer.byteLength(`\n\`\`\`\n${ELLIPSIS}`)
+    let truncated = truncateBytes(formatted, Math.max(0, maxBytes - suffixBytes))
+    if (format !== 'plaintext' && splitFences(truncated).length % 2 === 0) {
+        truncated = `${truncated}\n\`\`\`\n`
+    }
+
+    return { text: `${truncated}${ELLIPSIS}`, truncated: true }
+}
+
+/**
+ * Neutralize the parts of the given Markdown text that a renderer could turn into active
+ * content. Outside of code, the opening angle bracket of HTML tags and comments is escaped
+ * and links to schemes other than http, https, and mailto are removed (keeping the link
+ * text). Code blocks and code spans are left untouched as they are rendered literally.
+ *
+ * @param text The Markdown text.
+ */
+export function sanitizeMarkdown(text: string): string {
+    return mapProse(text, prose =>
+        prose
+            .replace(/!?\[([^\]]*)\]\(([^)\s]*)[^)]*\)/g, (match, label, target) =>
+                isSafeLinkTarget(target) ? match : label
+            )
+            .replace(/<(?=[A-Za-z/!?])/g, '&lt;')
+    )
+}
+
+/**
+ * Remove Markdown syntax from the given text. Code blocks are replaced by their contents,
+ * horizontal rules by blank lines, and links by their text. Emphasis, headings, code spans,
+ * and HTML tags are unwrapped.
+ *
+ * @param text The Markdown text.
+ */
+export function markdownToPlaintext(text: string): string {
+    return splitFences(text)
+        .map((segment, i) => {
+            if (i % 2 === 1) {
+                // Drop the language of the code block
+                return segment.replace(/^[^\n]*\n?/, '').replace(/\n$/, '')
+            }
+
+            return mapCodeSpans(
+                segment,
+                prose =>
+                    prose
+                        .replace(/^\s*(?:-{3,}|\*{3,}|_{3,})\s*$/gm, '')
+                        .replace(/^#{1,6}\s+/gm, '')
+                        .replace(/!?\[([^\]]*)\]\([^)]*\)/g, '$1')
+                        .replace(/<\/?[A-Za-z][^>]*>/g, '')
+                        .replace(/(\*\*|__)(.+?)\1/g, '$2')
+                        .replace(/(\*|_)(\S(?:.*?\S)?)\1/g, '$2')
+                        .replace(/\\([\\`*_{}[\]()#+\-.!<>])/g, '$1'),
+                code => code
+            )
+        })
+        .join('')
+        .replace(/\n{3,}/g, '\n\n')
+        .trim()
+}
+
+/**
+ * Split the given Markdown text on code fences. Segments at even indexes are outside of a
+ * code block and segments at odd indexes are the contents of a code block (including the
+ * language on the opening fence line). An unterminated code block yields an even number of
+ * segments.
+ *
+ * @param text The Markdown text.
+ */
+function splitFences(text: string): string[] {
+    return text.split(/^```/m)
+}
+
+/**
+ * Apply the given function to the parts of the given Markdown text that are not code.
+ *
+ * @param text The Markdown text.
+ * @param fn The function to apply to prose.
+ */
+function mapProse(text: string, fn: (prose: string) => string): string {
+    return splitFences(text)
+        .map((segment, i) => (i % 2 === 1 ? segment : mapCodeSpans(segment, fn, code => `\`${code}\``)))
+        .join('```')
+}
+
+/**
+ * Apply the given functions to the prose and to the contents of the code spans of the given
+ * Markdown text (outside of code blocks), respectively.
+ *
+ * @param text The Markdown text.
+ * @param prose The function to apply to prose.
+ * @param code The function to apply to the contents of each code span.
+ */
+function mapCodeSpans(text: string, prose: (text: string) => string, code: (text: string) => string): string {
+    return text
+        .split(/`([^`\n]*)`/)
+        .map((segment, i) => (i % 2 === 1 ? code(segment) : prose(segment)))
+        .join('')
+}
+
+/**
+ * Determine if the given link target uses the http, https, or mailto scheme or is a relative
+ * link. Relative links containing a colon or an entity reference (which renderers decode, so
+ * it can hide a colon) are rejected as they may be interpreted as having another scheme.
+ *
+ * @param target The link target.
+ */
+function isSafeLinkTarget(target: string): boolean {
+    const unwrapped = target.replace(/^<|>$/g, '')
+    return /^(?:https?|mailto):/i.test(unwrapped) || !/[:&]/.test(unwrapped)
+}
+
+/**
+ * Truncate the given text to at most the given number of bytes when encoded as UTF-8,
+ * without splitting a multi-byte character.
+ *
+ * @param text The text.
+ * @param maxBytes The maximum number of bytes.
+ */
+function truncateBytes(text: string, maxBytes: number): string {
+    return new StringDecoder('utf8').write(Buffer.from(text).slice(0, maxBytes))
+}
@@ -0,0 +1,43 @@
+import * as lsp from 'vscode-languageserver-protocol'
+import * as pgModels from '../../shared/models/pg'
+import { OrderedLocationSet, OrderedResolvedLocationSet } from './location'
+
+const makeRange = (line: number, character: number): lsp.Range => ({
+    start: { line, character },
+    end: { line, character: character + 5 },
+})
+
+const makeDump = (id: number): pgModels.LsifDump => ({ id } as pgModels.LsifDump)
+
+describe('OrderedLocationSet', () => {
+    it('should key locations by dump, path, and range', () => {
+        const set = new OrderedLocationSet([
+            { dumpId: 1, path: 'a.ts', range: makeRange(1, 2) },
+            { dumpId: 1, path: 'a.ts', range: makeRange(1, 2) },
+            { dumpId: 2, path: 'a.ts', range: makeRange(1, 2) },
+            { dumpId: 1, path: 'b.ts', range: makeRange(1, 2) },
+            { dumpId: 1, path: 'a.ts', range: makeRange(1, 3) },
+        ])
+
+        expect(set.values).toEqual([
+            { dumpId: 1, path: 'a.ts', range: makeRange(1, 2) },
+            { dumpId: 2, path: 'a.ts', range: makeRange(1, 2) },
+            { dumpId: 1, path: 'b.ts', range: makeRange(1, 2) },
+            { dumpId: 1, path: 'a.ts', range: makeRange(1, 3) },
+        ])
+    })
+})
+
+describe('OrderedResolvedLocationSet', () => {
+    it('should return only unseen locations from each batch', () => {
+        const set = new OrderedResolvedLocationSet()
+        const a = { dump: makeDump(1), path: 'a.ts', range: makeRange(1, 2) }
+        const b = { dump: makeDump(1), path: 'b.ts', range: makeRange(3, 4) }
+        const c = { dump: makeDump(2), path: 'a.ts', range: makeRange(1, 2) }
+
+        expect(set.pushAll([a, b, a])).toEqual([a, b])
+        expect(set.pushAll([{ ...b, dump: makeDump(1) }, c])).toEqual([c])
+        expect(set.pushAll([])).toEqual([])
+        expect(set.values).toEqual([a, b, c])
+    })
+})
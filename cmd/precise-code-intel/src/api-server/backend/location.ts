@@ -18,6 +18,17 @@ export interface ResolvedInternalLocation {
     range: lsp.Range
 }
 
+/**
+ * Create a key that identifies a location by its dump, path, and range.
+ *
+ * @param dumpId The identifier of the dump that contains the location.
+ * @param path The path of the location.
+ * @param range The range of the location.
+ */
+function locationKey(dumpId: pgModels.DumpId, path: string, range: lsp.Range): string {
+    return [dumpId, path, range.start.line, range.start.character, range.end.line, range.end.character].join(':')
+}
+
 /** A duplicate-free list of locations ordered by time of insertion. */
 export class OrderedLocationSet extends OrderedSet<InternalLocation> {
     /**
@@ -26,17 +37,32 @@ export class OrderedLocationSet extends OrderedSet<InternalLocation> {
      * @param values A set of values used to seed the set.
      */
     constructor(values?: InternalLocation[]) {
-        super(
-            (value: InternalLocation): string =>
-                [
-                    value.dumpId,
-                    value.path,
-                    value.range.start.line,
-                    value.range.start.character,
-                    value.range.end.line,
-                    value.range.end.character,
-                ].join(':'),
-            values
-        )
+        super(({ dumpId, path, range }: InternalLocation): string => locationKey(dumpId, path, range), values)
+    }
+}
+
+/**
+ * A duplicate-free list of resolved locations ordered by time of insertion. Locations are
+ * keyed the same way as an `OrderedLocationSet`, so a location is a duplicate of another
+ * exactly when both refer to the same range of the same path in the same dump.
+ */
+export class OrderedResolvedLocationSet extends OrderedSet<ResolvedInternalLocation> {
+    /**
+     * Create a new ordered resolved locations set.
+     *
+     * @param values A set of values used to seed the set.
+     */
+    constructor(values?: ResolvedInternalLocation[]) {
+        super(({ dump, path, range }: ResolvedInternalLocation): string => locationKey(dump.id, path, range), values)
+    }
+
+    /**
+     * Insert each of the given locations into the set and return the ones that were not
+     * already present, in their original order.
+     *
+     * @param locations The candidate locations.
+     */
+    public pushAll(locations: ResolvedInternalLocation[]): ResolvedInternalLocation[] {
+        return locations.filter(location => this.push(location))
     }
 }
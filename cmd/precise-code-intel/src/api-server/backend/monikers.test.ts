@@ -0,0 +1,88 @@
+import * as lsif from 'lsif-protocol'
+import * as sqliteModels from '../../shared/models/sqlite'
+import { definitionMonikerTiers, searchMonikerTiers } from './monikers'
+import { InternalLocation } from './location'
+
+const makeLocation = (dumpId: number, line: number): InternalLocation => ({
+    dumpId,
+    path: `${dumpId}.ts`,
+    range: { start: { line, character: 0 }, end: { line, character: 5 } },
+})
+
+describe('definitionMonikerTiers', () => {
+    it('should order tiers by import, export, then local', () => {
+        const local = { kind: lsif.MonikerKind.local, scheme: 'tsc', identifier: 'a' }
+        const exported = { kind: lsif.MonikerKind.export, scheme: 'npm', identifier: 'b' }
+        const imported = { kind: lsif.MonikerKind.import, scheme: 'npm', identifier: 'c' }
+
+        expect(definitionMonikerTiers([[local, exported, imported]])).toEqual([[imported], [exported], [local]])
+    })
+
+    it('should keep inner ranges first within a tier and remove duplicates', () => {
+        const inner = { kind: lsif.MonikerKind.import, scheme: 'npm', identifier: 'inner' }
+        const outer = { kind: lsif.MonikerKind.import, scheme: 'tsc', identifier: 'outer' }
+        const local = { kind: lsif.MonikerKind.local, scheme: 'tsc', identifier: 'local' }
+
+        expect(definitionMonikerTiers([[inner, local], [outer, { ...inner }]])).toEqual([[inner, outer], [local]])
+    })
+
+    it('should omit empty tiers', () => {
+        expect(definitionMonikerTiers([])).toEqual([])
+    })
+})
+
+describe('searchMonikerTiers', () => {
+    const npmImport = { kind: lsif.MonikerKind.import, scheme: 'npm', identifier: 'a' }
+    const tscImport = { kind: lsif.MonikerKind.import, scheme: 'tsc', identifier: 'a' }
+    const npmExport = { kind: lsif.MonikerKind.export, scheme: 'npm', identifier: 'a' }
+
+    const search = (results: Map<string, InternalLocation[]>) => (
+        moniker: sqliteModels.MonikerData
+    ): Promise<InternalLocation[]> => Promise.resolve(results.get(`${moniker.kind}:${moniker.scheme}`) || [])
+
+    it('should prefer the results of a higher tier', async () => {
+        const results = new Map([
+            ['import:npm', [makeLocation(2, 1)]],
+            ['export:npm', [makeLocation(1, 1)]],
+        ])
+
+        expect(await searchMonikerTiers([[npmImport], [npmExport]], 2, search(results))).toEqual({
+            kind: lsif.MonikerKind.import,
+            locations: [makeLocation(2, 1)],
+        })
+    })
+
+    it('should merge and deduplicate the results of every scheme of a tier', async () => {
+        const results = new Map([
+            ['import:npm', [makeLocation(2, 1), makeLocation(2, 2)]],
+            ['import:tsc', [makeLocation(2, 2), makeLocation(3, 1)]],
+        ])
+
+        expect(await searchMonikerTiers([[npmImport, tscImport]], 1, search(results))).toEqual({
+            kind: lsif.MonikerKind.import,
+            locations: [makeLocation(2, 1), makeLocation(2, 2), makeLocation(3, 1)],
+        })
+    })
+
+    it('should fall back to another scheme of the same tier', async () => {
+        const results = new Map([['import:tsc', [makeLocation(3, 1)]]])
+
+        expect(await searchMonikerTiers([[npmImport, tscImport], [npmExport]], 2, search(results))).toEqual({
+            kind: lsif.MonikerKind.import,
+            locations: [makeLocation(3, 1)],
+        })
+    })
+
+    it('should fall back to a lower tier', async () => {
+        const results = new Map([['export:npm', [makeLocation(1, 1)]]])
+
+        expect(await searchMonikerTiers([[npmImport, tscImport], [npmExport]], 2, search(results))).toEqual({
+            kind: lsif.MonikerKind.export,
+            locations: [makeLocation(1, 1)],
+        })
+    })
+
+    it('should return undefined without results', async () => {
+        expect(await searchMonikerTiers([[npmImport], [npmExport]], 2, search(new Map()))).toBeUndefined()
+    })
+})
@@ -0,0 +1,54 @@
+import * as lsif from 'lsif-protocol'
+import * as sqliteModels from '../../shared/models/sqlite'
+import { isEqual, uniqWith } from 'lodash'
+import { mapConcurrently } from '../../shared/util'
+import { InternalLocation, OrderedLocationSet } from './location'
+
+/**
+ * The order in which moniker kinds are searched for definitions. An import moniker names
+ * the definition in the package that exports the symbol, which is preferred over a match
+ * of an export or local moniker in the current dump.
+ */
+export const DEFINITION_MONIKER_PRECEDENCE = [lsif.MonikerKind.import, lsif.MonikerKind.export, lsif.MonikerKind.local]
+
+/**
+ * Group the monikers attached to the ranges at a position into the tiers in which they are
+ * searched for definitions, ordered by `DEFINITION_MONIKER_PRECEDENCE`. Within a tier,
+ * monikers of inner ranges occur before monikers of outer ranges. Duplicate monikers are
+ * removed, and tiers without monikers are omitted.
+ *
+ * @param rangeMonikers The monikers of each range containing the position, innermost first.
+ */
+export function definitionMonikerTiers(rangeMonikers: sqliteModels.MonikerData[][]): sqliteModels.MonikerData[][] {
+    const monikers = uniqWith(rangeMonikers.flat(), isEqual)
+
+    const tiers = DEFINITION_MONIKER_PRECEDENCE.map(kind => monikers.filter(moniker => moniker.kind === kind))
+    return tiers.filter(tier => tier.length > 0)
+}
+
+/**
+ * Search the tiers of monikers in order. Every moniker of a tier is searched, as indexers may
+ * attach monikers of several schemes to the same symbol and any of them may be the one that
+ * matches. The deduplicated results of the first tier with any results are returned along
+ * with the kind of that tier, so that a scheme without results falls back to the others of
+ * its tier before a lower tier is considered. Returns undefined if no moniker has results.
+ *
+ * @param tiers The tiers of monikers, as returned by `definitionMonikerTiers`.
+ * @param limit The maximum number of concurrent searches.
+ * @param search The function that returns the locations matching a moniker.
+ */
+export async function searchMonikerTiers(
+    tiers: sqliteModels.MonikerData[][],
+    limit: number,
+    search: (moniker: sqliteModels.MonikerData) => Promise<InternalLocation[]>
+): Promise<{ kind: lsif.MonikerKind; locations: InternalLocation[] } | undefined> {
+    for (const tier of tiers) {
+        const results = await mapConcurrently(tier, limit, search)
+        const locationSet = new OrderedLocationSet(results.flat())
+        if (locationSet.size > 0) {
+            return { kind: tier[0].kind, locations: locationSet.values }
+        }
+    }
+
+    return undefined
+}
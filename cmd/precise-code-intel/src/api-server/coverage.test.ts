@@ -0,0 +1,70 @@
+import * as pgModels from '../shared/models/pg'
+import { computeFileCoverage, summarizeDumpGroups } from './coverage'
+
+const makeDump = (id: number, root: string, commit: string, processedAt: Date): pgModels.LsifDump =>
+    ({ id, root, commit, processedAt } as pgModels.LsifDump)
+
+describe('summarizeDumpGroups', () => {
+    it('should report the commits of each root and the last index time of each indexer', () => {
+        const t1 = new Date('2020-01-01T00:00:00Z')
+        const t2 = new Date('2020-01-02T00:00:00Z')
+        const t3 = new Date('2020-01-03T00:00:00Z')
+
+        const { roots, indexers } = summarizeDumpGroups([
+            { root: '', indexer: 'lsif-tsc', dumps: [makeDump(3, '', 'c', t2), makeDump(1, '', 'a', t1)] },
+            { root: 'web/', indexer: 'lsif-go', dumps: [makeDump(4, 'web/', 'c', t1)] },
+            { root: 'web/', indexer: 'lsif-tsc', dumps: [makeDump(5, 'web/', 'c', t3), makeDump(2, 'web/', 'c', t1)] },
+        ])
+
+        expect(roots).toEqual([
+            { root: '', indexer: 'lsif-tsc', commits: ['c', 'a'], lastIndexedAt: t2 },
+            { root: 'web/', indexer: 'lsif-go', commits: ['c'], lastIndexedAt: t1 },
+            { root: 'web/', indexer: 'lsif-tsc', commits: ['c'], lastIndexedAt: t3 },
+        ])
+        expect(indexers).toEqual([
+            { indexer: 'lsif-go', lastIndexedAt: t1 },
+            { indexer: 'lsif-tsc', lastIndexedAt: t3 },
+        ])
+    })
+})
+
+describe('computeFileCoverage', () => {
+    const now = new Date()
+    const dumps = [makeDump(1, '', 'c', now), makeDump(2, 'web/', 'c', now)]
+
+    it('should count files with data in any dump containing them', async () => {
+        const exists = jest.fn((checks: { dumpId: number; path: string }[]) =>
+            Promise.resolve(checks.map(({ dumpId, path }) => (dumpId === 1 ? path === 'a.go' : path === 'b.ts')))
+        )
+
+        expect(await computeFileCoverage(['a.go', 'web/b.ts', 'web/c.ts', 'd.md'], dumps, exists)).toEqual({
+            changedFiles: 4,
+            coveredFiles: 2,
+            fraction: 0.5,
+        })
+        expect(exists.mock.calls[0][0]).toEqual([
+            { dumpId: 1, path: 'a.go' },
+            { dumpId: 1, path: 'web/b.ts' },
+            { dumpId: 2, path: 'b.ts' },
+            { dumpId: 1, path: 'web/c.ts' },
+            { dumpId: 2, path: 'c.ts' },
+            { dumpId: 1, path: 'd.md' },
+        ])
+    })
+
+    it('should not check files without a containing dump', async () => {
+        const exists = jest.fn(() => Promise.resolve([]))
+
+        expect(await computeFileCoverage(['a.go'], [], exists)).toEqual({
+            changedFiles: 1,
+            coveredFiles: 0,
+            fraction: 0,
+        })
+        expect(await computeFileCoverage([], dumps, exists)).toEqual({
+            changedFiles: 0,
+            coveredFiles: 0,
+            fraction: null,
+        })
+        expect(exists).not.toHaveBeenCalled()
+    })
+})
@@ -0,0 +1,97 @@
+import * as pgModels from '../shared/models/pg'
+import { DumpGroup } from '../shared/store/dumps'
+import { TracingContext } from '../shared/tracing'
+import { uniq } from 'lodash'
+
+/** The completed dumps of a repository that share a root and indexer. */
+export interface RootCoverage {
+    /** The root of the dumps. */
+    root: string
+    /** The indexer that produced the dumps. */
+    indexer: string
+    /** The commits with a completed dump, from most to least recently processed. */
+    commits: string[]
+    /** The time the most recent dump was processed. */
+    lastIndexedAt: Date
+}
+
+/** The most recent activity of an indexer within a repository. */
+export interface IndexerCoverage {
+    /** The name of the indexer. */
+    indexer: string
+    /** The time the most recent dump produced by the indexer was processed. */
+    lastIndexedAt: Date
+}
+
+/** The share of recently changed files of a repository that have precise code intelligence. */
+export interface FileCoverage {
+    /** The number of recently changed files that were checked. */
+    changedFiles: number
+    /** The number of checked files with data in a dump visible at tip. */
+    coveredFiles: number
+    /** The ratio of covered to checked files, or null if no files were checked. */
+    fraction: number | null
+}
+
+/**
+ * Summarize the dump groups of a repository by root and by indexer. Indexers are ordered by
+ * name.
+ *
+ * @param groups The dump groups of the repository, as returned by `DumpManager.getDumpGroups`.
+ */
+export function summarizeDumpGroups(groups: DumpGroup[]): { roots: RootCoverage[]; indexers: IndexerCoverage[] } {
+    const roots = groups.map(({ root, indexer, dumps }) => ({
+        root,
+        indexer,
+        commits: uniq(dumps.map(dump => dump.commit)),
+        lastIndexedAt: dumps[0].processedAt,
+    }))
+
+    const lastIndexedAtByIndexer = new Map<string, Date>()
+    for (const { indexer, lastIndexedAt } of roots) {
+        const previous = lastIndexedAtByIndexer.get(indexer)
+        if (!previous || previous < lastIndexedAt) {
+            lastIndexedAtByIndexer.set(indexer, lastIndexedAt)
+        }
+    }
+
+    const indexers = Array.from(lastIndexedAtByIndexer.entries())
+        .map(([indexer, lastIndexedAt]) => ({ indexer, lastIndexedAt }))
+        .sort((a, b) => a.indexer.localeCompare(b.indexer))
+
+    return { roots, indexers }
+}
+
+/**
+ * Determine how many of the given files have data in one of the given dumps. Only dumps
+ * whose root contains a file are checked for that file, and all checks are made at once.
+ *
+ * @param paths The repo-root-relative paths of the files.
+ * @param dumps The dumps visible at the tip of the repository.
+ * @param exists The function that determines if each dump has data for a path relative to its root.
+ * @param ctx The tracing context.
+ */
+export async function computeFileCoverage(
+    paths: string[],
+    dumps: pgModels.LsifDump[],
+    exists: (checks: { dumpId: pgModels.DumpId; path: string }[], ctx: TracingContext) => Promise<boolean[]>,
+    ctx: TracingContext = {}
+): Promise<FileCoverage> {
+    const checks: { dumpId: pgModels.DumpId; path: string; file: number }[] = []
+    for (const [file, path] of paths.entries()) {
+        for (const dump of dumps) {
+            if (path.startsWith(dump.root)) {
+                checks.push({ dumpId: dump.id, path: path.slice(dump.root.length), file })
+            }
+        }
+    }
+
+    const results = checks.length > 0 ? await exists(checks.map(({ dumpId, path }) => ({ dumpId, path })), ctx) : []
+    const coveredFiles = new Set(checks.filter((_, i) => results[i]).map(({ file }) => file)).size
+
+    return {
+        changedFiles: paths.length,
+        coveredFiles,
+        fraction: paths.length > 0 ? coveredFiles / paths.length : null,
+    }
+}
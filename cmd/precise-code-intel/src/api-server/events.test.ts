@@ -0,0 +1,49 @@
+import * as sinon from 'sinon'
+import * as pgModels from '../shared/models/pg'
+import { Connection } from 'typeorm'
+import { DumpManager } from '../shared/store/dumps'
+import { QueryEventLog } from './events'
+
+describe('QueryEventLog', () => {
+    const zeroEvent = {
+        timestamp: new Date(),
+        operation: 'definitions',
+        repositoryId: 42,
+        commit: 'c',
+        dumpId: 1,
+        resultCount: 3,
+        latency: 25,
+    }
+
+    it('should determine staleness from the dump commit', async () => {
+        const dumpManager = new DumpManager({} as Connection)
+        sinon.stub(dumpManager, 'getDumpsByIds').resolves(
+            new Map([
+                [1, { id: 1, commit: 'c' } as pgModels.LsifDump],
+                [2, { id: 2, commit: 'b' } as pgModels.LsifDump],
+            ])
+        )
+
+        const eventLog = new QueryEventLog(10)
+        eventLog.record({ ...zeroEvent, dumpId: 1 })
+        eventLog.record({ ...zeroEvent, dumpId: 2 })
+        eventLog.record({ ...zeroEvent, dumpId: 3 })
+
+        const events = await eventLog.export(dumpManager)
+        expect(events.map(e => e.servedStale)).toEqual([false, true, null])
+        expect(events.map(e => Object.keys(e))).not.toContainEqual(expect.arrayContaining(['commit']))
+    })
+
+    it('should filter by repository', async () => {
+        const dumpManager = new DumpManager({} as Connection)
+        sinon.stub(dumpManager, 'getDumpsByIds').resolves(new Map())
+
+        const eventLog = new QueryEventLog(10)
+        eventLog.record({ ...zeroEvent, repositoryId: 42, resultCount: 1 })
+        eventLog.record({ ...zeroEvent, repositoryId: 43, resultCount: 2 })
+        eventLog.record({ ...zeroEvent, repositoryId: 42, resultCount: 3 })
+
+        const events = await eventLog.export(dumpManager, 42)
+        expect(events.map(e => e.resultCount)).toEqual([1, 3])
+    })
+})
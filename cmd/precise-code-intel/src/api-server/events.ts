@@ -0,0 +1,79 @@
+import { RingBuffer } from '../shared/datastructures/ring-buffer'
+import { DumpManager } from '../shared/store/dumps'
+
+/** A code intelligence query served by the API server. */
+export interface QueryEvent {
+    /** The time the query was received. */
+    timestamp: Date
+    /** The name of the query operation (e.g. `definitions`). */
+    operation: string
+    /** The repository identifier of the query. */
+    repositoryId: number
+    /** The commit of the query. This value is used to determine staleness and is not exported. */
+    commit: string
+    /** The identifier of the dump used to answer the query. */
+    dumpId: number
+    /** The number of results returned from the query. */
+    resultCount: number
+    /** The time in milliseconds taken to answer the query. */
+    latency: number
+}
+
+/** An anonymized query event as returned from the export endpoint. */
+export interface ExportedQueryEvent extends Omit<QueryEvent, 'commit'> {
+    /**
+     * Whether the query was answered by a dump at a commit other than the requested commit.
+     * This value is null if the dump no longer exists.
+     */
+    servedStale: boolean | null
+}
+
+/**
+ * An in-memory log of the most recent code intelligence queries. This log lets site admins
+ * measure the adoption and hit rate of precise code intelligence per repository.
+ */
+export class QueryEventLog {
+    private events: RingBuffer<QueryEvent>
+
+    /**
+     * Create a new `QueryEventLog`.
+     *
+     * @param capacity The maximum number of events to retain.
+     */
+    constructor(capacity: number) {
+        this.events = new RingBuffer<QueryEvent>(capacity)
+    }
+
+    /**
+     * Record a query event.
+     *
+     * @param event The query event.
+     */
+    public record(event: QueryEvent): void {
+        this.events.push(event)
+    }
+
+    /**
+     * Return the retained events in the order they were recorded, optionally filtered by
+     * repository. The dumps referenced by the events are bulk loaded in order to determine
+     * which queries were answered by a dump at a different commit.
+     *
+     * @param dumpManager The dumps manager instance.
+     * @param repositoryId The repository identifier to filter by.
+     */
+    public async export(dumpManager: DumpManager, repositoryId?: number): Promise<ExportedQueryEvent[]> {
+        const events = this.events.values.filter(
+            event => repositoryId === undefined || event.repositoryId === repositoryId
+        )
+        if (events.length === 0) {
+            return []
+        }
+
+        const dumps = await dumpManager.getDumpsByIds(Array.from(new Set(events.map(({ dumpId }) => dumpId))))
+
+        return events.map(({ commit, ...event }) => {
+            const dump = dumps.get(event.dumpId)
+            return { ...event, servedStale: dump ? dump.commit !== commit : null }
+        })
+    }
+}
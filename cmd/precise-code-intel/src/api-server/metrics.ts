@@ -17,6 +17,63 @@ export const httpQueryDurationHistogram = new promClient.Histogram({
     buckets: [0.2, 0.5, 1, 2, 5, 10, 30],
 })
 
+export const uploadsRejectedCounter = new promClient.Counter({
+    name: 'lsif_uploads_rejected_total',
+    help: 'The number of uploads rejected because a limit on queued uploads was reached.',
+    labelNames: ['quota'],
+})
+
+//
+// Query Metrics
+
+export const queryDurationHistogram = new promClient.Histogram({
+    name: 'lsif_query_duration_seconds',
+    help: 'Total time spent resolving code intelligence queries.',
+    labelNames: ['operation'],
+    buckets: [0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10, 30],
+})
+
+export const queryErrorsCounter = new promClient.Counter({
+    name: 'lsif_query_errors_total',
+    help: 'The number of errors that occurred while resolving a code intelligence query.',
+    labelNames: ['operation'],
+})
+
+export const queryResultsHistogram = new promClient.Histogram({
+    name: 'lsif_query_results',
+    help: 'The number of results returned from a code intelligence query.',
+    labelNames: ['operation'],
+    buckets: [0, 1, 5, 10, 50, 100, 500, 1000],
+})
+
+export const queryRemoteDumpsCounter = new promClient.Counter({
+    name: 'lsif_query_remote_dumps_total',
+    help: 'The number of dumps other than the queried dump consulted while resolving a code intelligence query.',
+    labelNames: ['operation'],
+})
+
+//
+// Bundle Manager Client Metrics
+
+export const bundleManagerRequestRetriesCounter = new promClient.Counter({
+    name: 'lsif_bundle_manager_request_retries_total',
+    help: 'The number of retried requests to a bundle manager.',
+})
+
+//
+// Query Result Cache Metrics
+
+export const queryResultCacheSizeGauge = new promClient.Gauge({
+    name: 'lsif_query_result_cache_size',
+    help: 'The current number of cached query results.',
+})
+
+export const queryResultCacheEventsCounter = new promClient.Counter({
+    name: 'lsif_query_result_cache_events_total',
+    help: 'The number of query result cache hits, misses, expirations, evictions, and invalidations.',
+    labelNames: ['operation', 'type'],
+})
+
 //
 // Database Metrics
 
@@ -38,3 +95,17 @@ export const unconvertedUploadSizeGauge = new promClient.Gauge({
     name: 'lsif_unconverted_upload_size',
     help: 'The current number of uploads that have are pending conversion.',
 })
+
+//
+// Janitor Metrics
+
+export const janitorUploadsRemovedCounter = new promClient.Counter({
+    name: 'lsif_janitor_uploads_removed_total',
+    help: 'The number of upload records removed by the janitor.',
+    labelNames: ['reason'],
+})
+
+export const janitorUploadEventsRemovedCounter = new promClient.Counter({
+    name: 'lsif_janitor_upload_events_removed_total',
+    help: 'The number of upload lifecycle events removed by the janitor.',
+})
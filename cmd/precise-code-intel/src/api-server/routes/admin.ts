@@ -0,0 +1,146 @@
+import * as pgModels from '../../shared/models/pg'
+import * as settings from '../settings'
+import * as validation from '../../shared/api/middleware/validation'
+import express from 'express'
+import { wrap } from 'async-middleware'
+import { IndexerQueueStatus, UploadManager } from '../../shared/store/uploads'
+import { TracingContext, addTags } from '../../shared/tracing'
+import { Span } from 'opentracing'
+import { Logger } from 'winston'
+import { BundleManagerStats } from '../../shared/stats'
+import { getBundleManagerStats } from '../backend/database'
+
+/** Every upload state, in the order they are reported. */
+const uploadStates: pgModels.LsifUploadState[] = ['queued', 'processing', 'completed', 'errored', 'failed', 'deleting']
+
+/**
+ * Create a router containing the endpoints used by site admins to monitor LSIF storage.
+ *
+ * @param uploadManager The uploads manager instance.
+ * @param logger The logger instance.
+ */
+export function createAdminRouter(uploadManager: UploadManager, logger: Logger): express.Router {
+    const router = express.Router()
+
+    /**
+     * Create a tracing context from the request logger and tracing span
+     * tagged with the given values.
+     *
+     * @param req The express request.
+     * @param tags The tags to apply to the logger and span.
+     */
+    const createTracingContext = (
+        req: express.Request & { span?: Span },
+        tags: { [K: string]: unknown }
+    ): TracingContext => addTags({ logger, span: req.span }, tags)
+
+    interface StatsResponse {
+        bundleManager: BundleManagerStats
+        uploads: { [K in pgModels.LsifUploadState]: number }
+    }
+
+    router.get(
+        '/admin/stats',
+        wrap(
+            async (req: express.Request, res: express.Response<StatsResponse>): Promise<void> => {
+                const ctx = createTracingContext(req, {})
+                const [bundleManager, counts] = await Promise.all([
+                    getBundleManagerStats(ctx),
+                    uploadManager.getCountsByState(),
+                ])
+
+                const uploads = {} as StatsResponse['uploads']
+                for (const state of uploadStates) {
+                    uploads[state] = counts.get(state) || 0
+                }
+
+                res.json({ bundleManager, uploads })
+            }
+        )
+    )
+
+    interface QueueQueryArgs {
+        uploadId?: number
+    }
+
+    interface QueuedUploadStatus {
+        id: number
+        placeInQueue: number | null
+        estimatedWait: number | null
+    }
+
+    interface QueueResponse {
+        queued: number
+        processing: number
+        oldestQueuedAge: number | null
+        throughput: number
+        estimatedWait: number | null
+        indexers: IndexerQueueStatus[]
+        upload?: QueuedUploadStatus
+    }
+
+    router.get(
+        '/queue',
+        validation.validationMiddleware([validation.validateOptionalInt('uploadId')]),
+        wrap(
+            async (req: express.Request, res: express.Response<QueueResponse>): Promise<void> => {
+                const { uploadId }: QueueQueryArgs = req.query
+                const indexers = await uploadManager.getQueueStatus(settings.QUEUE_THROUGHPUT_WINDOW)
+
+                let queued = 0
+                let processing = 0
+                let recentlyFinished = 0
+                let oldestQueuedAge: number | null = null
+                for (const status of indexers) {
+                    queued += status.queued
+                    processing += status.processing
+                    recentlyFinished += status.recentlyFinished
+                    if (status.oldestQueuedAge !== null) {
+                        oldestQueuedAge = Math.max(oldestQueuedAge || 0, status.oldestQueuedAge)
+                    }
+                }
+
+                // Uploads are converted in the order they were uploaded regardless of indexer,
+                // so the wait for a queued upload is estimated from the number of uploads ahead
+                // of it and the rate at which all uploads have recently been converted.
+                const rate = recentlyFinished / settings.QUEUE_THROUGHPUT_WINDOW
+                const estimateWait = (count: number): number | null => {
+                    if (count === 0) {
+                        return 0
+                    }
+
+                    return rate > 0 ? Math.round(count / rate) : null
+                }
+
+                let upload: QueuedUploadStatus | undefined
+                if (uploadId !== undefined) {
+                    const { placeInQueue } = (await uploadManager.getUpload(uploadId)) || {}
+                    if (placeInQueue === undefined) {
+                        throw Object.assign(new Error('Upload not found'), {
+                            status: 404,
+                            code: 'upload_not_found',
+                        })
+                    }
+
+                    upload = {
+                        id: uploadId,
+                        placeInQueue,
+                        estimatedWait: placeInQueue === null ? null : estimateWait(placeInQueue),
+                    }
+                }
+
+                res.json({
+                    queued,
+                    processing,
+                    oldestQueuedAge,
+                    throughput: Math.round(rate * 60 * 60),
+                    estimatedWait: estimateWait(queued),
+                    indexers,
+                    upload,
+                })
+            }
+        )
+    )
+
+    return router
+}
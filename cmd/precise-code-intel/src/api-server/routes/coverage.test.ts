@@ -0,0 +1,30 @@
+import express from 'express'
+import got from 'got'
+import { AddressInfo } from 'net'
+import { Server } from 'http'
+import { createCoverageRouter } from './coverage'
+import { createSilentLogger } from '../../shared/logging'
+import { DumpManager } from '../../shared/store/dumps'
+
+describe('createCoverageRouter', () => {
+    let server!: Server
+
+    beforeEach(() => {
+        // Invalid requests are rejected before the dump manager is consulted
+        const dumpManager = ({} as unknown) as DumpManager
+        server = express().use(createCoverageRouter(dumpManager, createSilentLogger())).listen(0)
+    })
+
+    afterEach(() => {
+        server.close()
+    })
+
+    const get = (path: string): Promise<unknown> =>
+        got.get(`http://localhost:${(server.address() as AddressInfo).port}${path}`).json()
+
+    it('should reject windows shorter than one day', async () => {
+        for (const days of ['0', '-7', 'seven']) {
+            await expect(get(`/coverage/repository/42?days=${days}`)).rejects.toThrow('422')
+        }
+    })
+})
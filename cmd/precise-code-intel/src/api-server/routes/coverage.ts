@@ -0,0 +1,102 @@
+import * as settings from '../settings'
+import * as validation from '../../shared/api/middleware/validation'
+import express from 'express'
+import { wrap } from 'async-middleware'
+import { Span } from 'opentracing'
+import { Logger } from 'winston'
+import { DumpManager } from '../../shared/store/dumps'
+import { SRC_FRONTEND_INTERNAL } from '../../shared/config/settings'
+import { addTags, TracingContext } from '../../shared/tracing'
+import { cancellationFromResponse } from '../../shared/cancellation'
+import { getRecentlyChangedFiles } from '../../shared/gitserver/gitserver'
+import { Database, existsBatch } from '../backend/database'
+import { MAX_CONCURRENT_EXISTS_REQUESTS } from '../../shared/constants'
+import { mapConcurrently } from '../../shared/util'
+import {
+    computeFileCoverage,
+    FileCoverage,
+    IndexerCoverage,
+    RootCoverage,
+    summarizeDumpGroups,
+} from '../coverage'
+
+/**
+ * Create a router containing the endpoints that report how much of a repository is covered
+ * by precise code intelligence, so that teams can track its adoption.
+ *
+ * @param dumpManager The dumps manager instance.
+ * @param logger The logger instance.
+ */
+export function createCoverageRouter(dumpManager: DumpManager, logger: Logger): express.Router {
+    const router = express.Router()
+
+    /**
+     * Create a tracing context from the request logger and tracing span
+     * tagged with the given values.
+     *
+     * @param req The express request.
+     * @param tags The tags to apply to the logger and span.
+     */
+    const createTracingContext = (
+        req: express.Request & { span?: Span },
+        tags: { [K: string]: unknown }
+    ): TracingContext =>
+        addTags({ logger, span: req.span, cancellation: req.res && cancellationFromResponse(req.res) }, tags)
+
+    /**
+     * Determine if each dump has data for the paired path with a single request to each
+     * bundle manager, falling back to one request per check for older bundle managers.
+     *
+     * @param checks The dump identifiers and document paths to check.
+     * @param ctx The tracing context.
+     */
+    const exists = async (checks: { dumpId: number; path: string }[], ctx: TracingContext): Promise<boolean[]> =>
+        (await existsBatch(checks, ctx)) ||
+        mapConcurrently(checks, MAX_CONCURRENT_EXISTS_REQUESTS, ({ dumpId, path }) =>
+            new Database(dumpId).exists(path, ctx)
+        )
+
+    interface CoverageQueryArgs {
+        days?: number
+    }
+
+    interface CoverageResponse {
+        roots: RootCoverage[]
+        indexers: IndexerCoverage[]
+        recentFiles: FileCoverage & { days: number; truncated: boolean }
+    }
+
+    router.get(
+        '/coverage/repository/:id([0-9]+)',
+        validation.validationMiddleware([validation.validateOptionalPositiveInt('days')]),
+        wrap(
+            async (req: express.Request, res: express.Response<CoverageResponse>): Promise<void> => {
+                const repositoryId = parseInt(req.params.id, 10)
+                const { days = settings.COVERAGE_WINDOW_DAYS }: CoverageQueryArgs = req.query
+                const ctx = createTracingContext(req, { repositoryId, days })
+                const { roots, indexers } = summarizeDumpGroups(await dumpManager.getDumpGroups(repositoryId))
+
+                // Files changed on the default branch within the window are checked against the
+                // dumps visible at its tip. Repositories unknown to gitserver have no such files.
+                const frontendUrl = SRC_FRONTEND_INTERNAL
+                const tip = await dumpManager.discoverTip({ repositoryId, frontendUrl, ctx })
+                const since = new Date(Date.now() - days * 24 * 60 * 60 * 1000)
+                const changedFiles = tip
+                    ? await getRecentlyChangedFiles({ frontendUrl, repositoryId, commit: tip, since, ctx })
+                    : []
+
+                const paths = changedFiles.slice(0, settings.MAX_COVERAGE_FILES)
+                const visibleDumps = await dumpManager.getVisibleDumps(repositoryId)
+                const fileCoverage = await computeFileCoverage(paths, visibleDumps, exists, ctx)
+
+                res.json({
+                    roots,
+                    indexers,
+                    recentFiles: { ...fileCoverage, days, truncated: paths.length < changedFiles.length },
+                })
+            }
+        )
+    )
+
+    return router
+}
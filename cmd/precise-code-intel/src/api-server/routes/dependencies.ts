@@ -0,0 +1,124 @@
+import * as pgModels from '../../shared/models/pg'
+import * as settings from '../settings'
+import * as validation from '../../shared/api/middleware/validation'
+import express from 'express'
+import { wrap } from 'async-middleware'
+import { DumpManager } from '../../shared/store/dumps'
+import { DependencyManager, DumpDependency } from '../../shared/store/dependencies'
+import { extractLimitOffset } from '../../shared/api/pagination/limit-offset'
+import { offsetLinks } from '../../shared/api/pagination/link'
+
+/**
+ * Create a router containing the endpoints that relate dumps by the packages they provide
+ * and use. These endpoints expose the cross-repository dependency graph and allow users to
+ * discover which dump provides a given package.
+ *
+ * @param dumpManager The dumps manager instance.
+ * @param dependencyManager The dependency manager instance.
+ */
+export function createDependencyRouter(dumpManager: DumpManager, dependencyManager: DependencyManager): express.Router {
+    const router = express.Router()
+
+    interface DependenciesQueryArgs {
+        uploadId: number
+    }
+
+    interface DependenciesResponse {
+        dependencies: DumpDependency[]
+        totalCount: number
+    }
+
+    interface DependentsResponse {
+        dependents: DumpDependency[]
+        totalCount: number
+    }
+
+    /**
+     * Throw a not found error if the given upload is not a completed dump.
+     *
+     * @param uploadId The upload identifier.
+     */
+    const ensureDump = async (uploadId: number): Promise<void> => {
+        if (!(await dumpManager.getDumpById(uploadId))) {
+            throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
+        }
+    }
+
+    router.get(
+        '/dependencies',
+        validation.validationMiddleware([
+            validation.validateInt('uploadId'),
+            validation.validateLimit,
+            validation.validateOffset,
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<DependenciesResponse>): Promise<void> => {
+                const { uploadId }: DependenciesQueryArgs = req.query
+                const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_DUMP_PAGE_SIZE)
+                await ensureDump(uploadId)
+
+                const { dependencies, totalCount } = await dependencyManager.getDependencies(uploadId, limit, offset)
+                res.set('Link', offsetLinks(req, { limit, offset, totalCount }))
+                res.json({ dependencies, totalCount })
+            }
+        )
+    )
+
+    router.get(
+        '/dependents',
+        validation.validationMiddleware([
+            validation.validateInt('uploadId'),
+            validation.validateLimit,
+            validation.validateOffset,
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<DependentsResponse>): Promise<void> => {
+                const { uploadId }: DependenciesQueryArgs = req.query
+                const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_DUMP_PAGE_SIZE)
+                await ensureDump(uploadId)
+
+                const { dependents, totalCount } = await dependencyManager.getDependents(uploadId, limit, offset)
+                res.set('Link', offsetLinks(req, { limit, offset, totalCount }))
+                res.json({ dependents, totalCount })
+            }
+        )
+    )
+
+    interface PackagesQueryArgs {
+        scheme?: string
+        query?: string
+    }
+
+    interface PackagesResponse {
+        packages: pgModels.PackageModel[]
+        totalCount: number
+    }
+
+    router.get(
+        '/packages',
+        validation.validationMiddleware([
+            validation.validateOptionalString('scheme'),
+            validation.validateQuery,
+            validation.validateLimit,
+            validation.validateOffset,
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<PackagesResponse>): Promise<void> => {
+                const { scheme, query }: PackagesQueryArgs = req.query
+                const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_DUMP_PAGE_SIZE)
+
+                const { packages, totalCount } = await dependencyManager.searchPackages({
+                    scheme: scheme || '',
+                    query: query || '',
+                    limit,
+                    offset,
+                })
+
+                res.set('Link', offsetLinks(req, { limit, offset, totalCount }))
+                res.json({ packages, totalCount })
+            }
+        )
+    )
+
+    return router
+}
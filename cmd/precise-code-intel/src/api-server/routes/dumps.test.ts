@@ -0,0 +1,115 @@
+import * as sinon from 'sinon'
+import * as pgModels from '../../shared/models/pg'
+import express from 'express'
+import got from 'got'
+import { AddressInfo } from 'net'
+import { Server } from 'http'
+import { createDumpRouter } from './dumps'
+import { createMockDumpDebugStore } from '../../shared/test-util'
+import { createSilentLogger } from '../../shared/logging'
+import { DumpDebugStore } from '../../shared/store/dumps'
+
+const zeroUpload: pgModels.LsifUpload = {
+    id: 0,
+    repositoryId: 0,
+    commit: '',
+    root: '',
+    indexer: '',
+    state: 'queued',
+    uploadedAt: new Date(),
+    startedAt: null,
+    finishedAt: null,
+    failureSummary: null,
+    failureStacktrace: null,
+    tracingContext: '',
+    visibleAtTip: false,
+    supersededBy: null,
+    pinned: false,
+    excluded: false,
+    checksum: null,
+    attempts: 0,
+    lastRetriedAt: null,
+    deletedAt: null,
+    stateBeforeDelete: null,
+}
+
+const zeroDump: pgModels.LsifDump = {
+    ...zeroUpload,
+    state: 'completed',
+    processedAt: new Date(),
+}
+
+describe('createDumpRouter', () => {
+    let dumpStore!: DumpDebugStore
+    let server!: Server
+
+    beforeEach(() => {
+        dumpStore = createMockDumpDebugStore()
+        server = express().use(createDumpRouter(dumpStore, createSilentLogger())).listen(0)
+    })
+
+    afterEach(() => {
+        server.close()
+    })
+
+    const get = <T>(path: string): Promise<T> =>
+        got.get(`http://localhost:${(server.address() as AddressInfo).port}${path}`).json<T>()
+
+    describe('/closest', () => {
+        it('should track the discovered commits without modifying them', async () => {
+            const commits = new Map([
+                ['deadbeef', new Set(['cafebabe'])],
+                ['cafebabe', new Set<string>()],
+            ])
+            const snapshot = new Map(Array.from(commits, ([commit, parents]) => [commit, new Set(parents)]))
+
+            sinon.stub(dumpStore, 'discoverCommits').resolves(commits)
+            const updateCommits = sinon.stub(dumpStore, 'updateCommits').resolves()
+            sinon.stub(dumpStore, 'findClosestDumps').resolves([{ ...zeroDump, id: 1, distance: 1 }])
+
+            const { dumps } = await get<{ dumps: { id: number }[] }>(
+                '/dumps/repository/42/closest?commit=deadbeef&path=foo/bar.ts'
+            )
+
+            expect(dumps.map(({ id }) => id)).toEqual([1])
+            expect(updateCommits.calledOnce).toBeTruthy()
+            expect(updateCommits.firstCall.args.slice(0, 2)).toEqual([42, snapshot])
+            expect(commits).toEqual(snapshot)
+        })
+
+        it('should report the dumps of each root once, nearest first', async () => {
+            sinon.stub(dumpStore, 'discoverCommits').resolves(new Map())
+            sinon.stub(dumpStore, 'updateCommits').resolves()
+            sinon.stub(dumpStore, 'getDumpGroups').resolves([
+                { root: '', indexer: 'lsif-go', dumps: [] },
+                { root: 'web/', indexer: 'lsif-tsc', dumps: [] },
+                { root: 'web/', indexer: 'lsif-eslint', dumps: [] },
+            ])
+
+            const findClosestDumps = sinon.stub(dumpStore, 'findClosestDumps')
+            findClosestDumps.withArgs(42, 'deadbeef', '').resolves([
+                { ...zeroDump, id: 3, distance: 2 },
+                { ...zeroDump, id: 1, distance: 5 },
+            ])
+            findClosestDumps.withArgs(42, 'deadbeef', 'web/').resolves([
+                { ...zeroDump, id: 2, distance: 2 },
+                { ...zeroDump, id: 1, distance: 5 },
+            ])
+
+            const { dumps } = await get<{ dumps: { id: number; distance: number }[] }>(
+                '/dumps/repository/42/closest?commit=deadbeef'
+            )
+
+            expect(findClosestDumps.callCount).toEqual(2)
+            expect(dumps.map(({ id, distance }) => ({ id, distance }))).toEqual([
+                { id: 2, distance: 2 },
+                { id: 3, distance: 2 },
+                { id: 1, distance: 5 },
+            ])
+        })
+
+        it('should require a commit', async () => {
+            await expect(get('/dumps/repository/42/closest')).rejects.toThrow('422')
+        })
+    })
+})
@@ -0,0 +1,97 @@
+import * as validation from '../../shared/api/middleware/validation'
+import express from 'express'
+import { wrap } from 'async-middleware'
+import { Span } from 'opentracing'
+import { Logger } from 'winston'
+import { DumpDebugStore, DumpGroup, LsifDumpWithDistance } from '../../shared/store/dumps'
+import { SRC_FRONTEND_INTERNAL } from '../../shared/config/settings'
+import { addTags, TracingContext } from '../../shared/tracing'
+import { cancellationFromResponse } from '../../shared/cancellation'
+import { uniq, uniqBy } from 'lodash'
+
+/**
+ * Create a router containing the endpoints that describe the dumps of a repository. These
+ * endpoints help to diagnose why precise code intelligence is or is not available for a
+ * given file.
+ *
+ * @param dumpManager The dumps manager instance.
+ * @param logger The logger instance.
+ */
+export function createDumpRouter(dumpManager: DumpDebugStore, logger: Logger): express.Router {
+    const router = express.Router()
+
+    /**
+     * Create a tracing context from the request logger and tracing span
+     * tagged with the given values.
+     *
+     * @param req The express request.
+     * @param tags The tags to apply to the logger and span.
+     */
+    const createTracingContext = (
+        req: express.Request & { span?: Span },
+        tags: { [K: string]: unknown }
+    ): TracingContext =>
+        addTags({ logger, span: req.span, cancellation: req.res && cancellationFromResponse(req.res) }, tags)
+
+    interface DumpsResponse {
+        groups: DumpGroup[]
+    }
+
+    interface ClosestDumpsQueryArgs {
+        commit: string
+        path?: string
+    }
+
+    interface ClosestDumpsResponse {
+        dumps: LsifDumpWithDistance[]
+    }
+
+    router.get(
+        '/dumps/repository/:id([0-9]+)',
+        wrap(
+            async (req: express.Request, res: express.Response<DumpsResponse>): Promise<void> => {
+                res.json({ groups: await dumpManager.getDumpGroups(parseInt(req.params.id, 10)) })
+            }
+        )
+    )
+
+    router.get(
+        '/dumps/repository/:id([0-9]+)/closest',
+        validation.validationMiddleware([
+            validation.validateNonEmptyString('commit'),
+            validation.validateOptionalString('path'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<ClosestDumpsResponse>): Promise<void> => {
+                const repositoryId = parseInt(req.params.id, 10)
+                const { commit, path }: ClosestDumpsQueryArgs = req.query
+                const ctx = createTracingContext(req, { repositoryId, commit, path })
+
+                // Track the commit as a query for it would, so that the lineage is the same
+                const frontendUrl = SRC_FRONTEND_INTERNAL
+                await dumpManager.updateCommits(
+                    repositoryId,
+                    await dumpManager.discoverCommits({ repositoryId, commit, frontendUrl, ctx }),
+                    ctx
+                )
+
+                // Without a path, report the dumps that would be chosen for a file in each root
+                const paths =
+                    path !== undefined
+                        ? [path]
+                        : uniq((await dumpManager.getDumpGroups(repositoryId)).map(({ root }) => root))
+
+                const dumps: LsifDumpWithDistance[] = []
+                for (const candidate of paths) {
+                    dumps.push(...(await dumpManager.findClosestDumps(repositoryId, commit, candidate, ctx)))
+                }
+
+                res.json({
+                    dumps: uniqBy(dumps, dump => dump.id).sort((a, b) => a.distance - b.distance || a.id - b.id),
+                })
+            }
+        )
+    )
+
+    return router
+}
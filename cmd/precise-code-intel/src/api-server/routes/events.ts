@@ -0,0 +1,36 @@
+import * as validation from '../../shared/api/middleware/validation'
+import express from 'express'
+import { wrap } from 'async-middleware'
+import { DumpManager } from '../../shared/store/dumps'
+import { ExportedQueryEvent, QueryEventLog } from '../events'
+
+/**
+ * Create a router containing the query event export endpoints.
+ *
+ * @param dumpManager The dumps manager instance.
+ * @param eventLog The query event log.
+ */
+export function createEventRouter(dumpManager: DumpManager, eventLog: QueryEventLog): express.Router {
+    const router = express.Router()
+
+    interface EventsQueryArgs {
+        repositoryId?: number
+    }
+
+    interface EventsResponse {
+        events: ExportedQueryEvent[]
+    }
+
+    router.get(
+        '/events',
+        validation.validationMiddleware([validation.validateOptionalInt('repositoryId')]),
+        wrap(
+            async (req: express.Request, res: express.Response<EventsResponse>): Promise<void> => {
+                const { repositoryId }: EventsQueryArgs = req.query
+                res.json({ events: await eventLog.export(dumpManager, repositoryId) })
+            }
+        )
+    )
+
+    return router
+}
@@ -1,25 +1,35 @@
+import * as validation from '../../shared/api/middleware/validation'
+import { requireToken } from '../../shared/api/middleware/auth'
 import express from 'express'
 import { wrap } from 'async-middleware'
-import { UploadManager } from '../../shared/store/uploads'
+import { JANITOR_ORIGIN, UploadManager } from '../../shared/store/uploads'
 import { DumpManager } from '../../shared/store/dumps'
-import { EntityManager } from 'typeorm'
-import { SRC_FRONTEND_INTERNAL } from '../../shared/config/settings'
+import { Connection, EntityManager } from 'typeorm'
+import { JANITOR_DRY_RUN, SRC_FRONTEND_INTERNAL } from '../../shared/config/settings'
+import * as settings from '../settings'
+import * as metrics from '../metrics'
 import { TracingContext, addTags } from '../../shared/tracing'
 import { Span } from 'opentracing'
 import { Logger } from 'winston'
-import { updateCommitsAndDumpsVisibleFromTip } from '../../shared/visibility'
+import { groupParentCommits, updateCommitsAndDumpsVisibleFromTip } from '../../shared/visibility'
 import { json } from 'body-parser'
+import { body } from 'express-validator'
+import { QueryResultCache } from '../backend/cache'
 
 /**
  * Create a router containing the endpoints used by the bundle manager.
  *
+ * @param connection The Postgres connection.
  * @param dumpManager The dumps manager instance.
  * @param uploadManager The uploads manager instance.
+ * @param resultCache The cache of query results to invalidate when the dumps of a repository change.
  * @param logger The logger instance.
  */
 export function createInternalRouter(
+    connection: Connection,
     dumpManager: DumpManager,
     uploadManager: UploadManager,
+    resultCache: QueryResultCache,
     logger: Logger
 ): express.Router {
     const router = express.Router()
@@ -53,28 +63,112 @@ export function createInternalRouter(
         )
     )
 
+    interface CommitsQueryArgs {
+        repositoryId: number
+    }
+
+    interface CommitsBody {
+        commits: { commit: string; parent: string | null }[]
+        tip?: string
+    }
+
+    router.post(
+        '/commits',
+        requireToken(),
+        json(),
+        validation.validationMiddleware([
+            validation.validateInt('repositoryId'),
+            body('commits').isArray(),
+            body('commits.*.commit').isString().not().isEmpty(),
+            body('commits.*.parent').optional({ nullable: true }).isString().not().isEmpty(),
+            body('tip').optional().isString().not().isEmpty(),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<never>): Promise<void> => {
+                const { repositoryId }: CommitsQueryArgs = req.query
+                const { commits, tip }: CommitsBody = req.body
+                const ctx = createTracingContext(req, { repositoryId, numCommits: commits.length })
+
+                // Fall back to asking the frontend for the tip of the default branch
+                const tipCommit =
+                    tip || (await dumpManager.discoverTip({ repositoryId, frontendUrl: SRC_FRONTEND_INTERNAL, ctx }))
+                if (tipCommit === undefined) {
+                    throw Object.assign(new Error('No tip commit available for repository'), {
+                        status: 422,
+                        code: 'unknown_tip',
+                    })
+                }
+
+                await connection.transaction(async entityManager => {
+                    await dumpManager.updateCommits(
+                        repositoryId,
+                        groupParentCommits(commits.map(({ commit, parent }) => ({ commit, parent: parent || null }))),
+                        ctx,
+                        entityManager
+                    )
+                    await dumpManager.updateDumpsVisibleFromTip(repositoryId, tipCommit, ctx, entityManager)
+                })
+
+                resultCache.invalidateRepository(repositoryId)
+
+                res.status(204).send()
+            }
+        )
+    )
+
+    interface AccessBody {
+        ids: number[]
+    }
+
+    router.post(
+        '/access',
+        requireToken(),
+        json(),
+        validation.validationMiddleware([body('ids').isArray(), body('ids.*').isInt()]),
+        wrap(
+            async (req: express.Request, res: express.Response<never>): Promise<void> => {
+                const { ids }: AccessBody = req.body
+                await dumpManager.recordAccess(ids)
+                res.status(204).send()
+            }
+        )
+    )
+
+    interface PruneBody {
+        ids?: number[]
+    }
+
     type PruneResponse = { id: number } | null
 
     router.post(
         '/prune',
+        requireToken(),
+        json(),
+        validation.validationMiddleware([body('ids').optional().isArray(), body('ids.*').isInt()]),
         wrap(
             async (req: express.Request, res: express.Response<PruneResponse>): Promise<void> => {
+                const { ids }: PruneBody = req.body || {}
                 const ctx = createTracingContext(req, {})
 
-                const dump = await dumpManager.getOldestPrunableDump()
+                // A sharded bundle manager can only reclaim space by pruning the dumps it stores
+                const dump = await dumpManager.getLeastRecentlyUsedPrunableDump(settings.PRUNE_POLICY, ids)
                 if (!dump) {
                     res.json(null)
                     return
                 }
 
-                logger.info('Pruning dump', {
-                    repository: dump.repositoryId,
-                    commit: dump.commit,
-                    root: dump.root,
-                })
+                const fields = { repository: dump.repositoryId, commit: dump.commit, root: dump.root }
+                if (JANITOR_DRY_RUN) {
+                    // Report that nothing can be pruned so that the caller stops asking for dumps
+                    logger.info('Would prune dump', fields)
+                    res.json(null)
+                    return
+                }
+
+                logger.info('Pruning dump', fields)
 
                 // This delete cascades to the packages and references tables as well
-                await uploadManager.deleteUpload(
+                const deleted = await uploadManager.deleteUpload(
                     dump.id,
                     (entityManager: EntityManager, repositoryId: number): Promise<void> =>
                         updateCommitsAndDumpsVisibleFromTip({
@@ -83,8 +177,14 @@ export function createInternalRouter(
                             frontendUrl: SRC_FRONTEND_INTERNAL,
                             repositoryId,
                             ctx,
-                        })
+                        }),
+                    JANITOR_ORIGIN,
+                    'Pruned to reclaim disk space'
                 )
+                if (deleted) {
+                    resultCache.invalidateRepository(dump.repositoryId)
+                    metrics.janitorUploadsRemovedCounter.labels('pruned').inc()
+                }
 
                 res.json({ id: dump.id })
             }
@@ -0,0 +1,103 @@
+import * as sinon from 'sinon'
+import express from 'express'
+import got from 'got'
+import { AddressInfo } from 'net'
+import { Backend } from '../backend/backend'
+import { Connection } from 'typeorm'
+import { createLsifRouter } from './lsif'
+import { createSilentLogger } from '../../shared/logging'
+import { QueryEventLog } from '../events'
+import { Server } from 'http'
+import { UploadManager } from '../../shared/store/uploads'
+
+describe('createLsifRouter', () => {
+    let references!: sinon.SinonStub
+    let position!: sinon.SinonStub
+    let server!: Server
+
+    beforeEach(() => {
+        references = sinon.stub().resolves({ locations: [], newCursor: undefined })
+        position = sinon.stub().resolves({ hover: null, definitions: [], references: { locations: [] } })
+        const backend = ({ references, position } as unknown) as Backend
+
+        server = express()
+            .use(
+                createLsifRouter(
+                    ({} as unknown) as Connection,
+                    backend,
+                    ({} as unknown) as UploadManager,
+                    new QueryEventLog(10),
+                    createSilentLogger(),
+                    undefined
+                )
+            )
+            .listen(0)
+    })
+
+    afterEach(() => {
+        server.close()
+    })
+
+    const url = (path: string): string => `http://localhost:${(server.address() as AddressInfo).port}${path}`
+    const query = 'repositoryId=42&commit=deadbeef&path=foo.ts&line=1&character=2&uploadId=7'
+
+    describe('/references', () => {
+        it('should read the allowed repositories from the body of a POST request', async () => {
+            await got.post(url(`/references?${query}`), { json: { allowedRepositoryIds: [50, 51] } }).json()
+
+            expect(references.calledOnce).toBeTruthy()
+            expect(references.firstCall.args[4].allowedRepositoryIds).toEqual(new Set([50, 51]))
+        })
+
+        it('should read the allowed repositories from the query string of a GET request', async () => {
+            await got.get(url(`/references?${query}&allowedRepositoryIds=50,51`)).json()
+
+            expect(references.firstCall.args[4].allowedRepositoryIds).toEqual(new Set([50, 51]))
+        })
+
+        it('should not filter when no allowed repositories are given', async () => {
+            await got.post(url(`/references?${query}`), { json: {} }).json()
+
+            expect(references.firstCall.args[4].allowedRepositoryIds).toBeUndefined()
+        })
+
+        it('should reject malformed allowed repositories', async () => {
+            const request = got.post(url(`/references?${query}`), { json: { allowedRepositoryIds: ['x'] } }).json()
+
+            await expect(request).rejects.toThrow('422')
+            expect(references.called).toBeFalsy()
+        })
+    })
+
+    describe('/position', () => {
+        const positionQuery = query.replace('deadbeef', 'deadbeef'.repeat(5))
+
+        it('should pass the allowed repositories to the backend', async () => {
+            await got.get(url(`/position?${positionQuery}&allowedRepositoryIds=50`)).json()
+
+            expect(position.calledOnce).toBeTruthy()
+            expect(position.firstCall.args[4].allowedRepositoryIds).toEqual(new Set([50]))
+        })
+
+        it('should read the allowed repositories from the body of a POST request', async () => {
+            await got.post(url(`/position?${positionQuery}`), { json: { allowedRepositoryIds: [50, 51] } }).json()
+
+            expect(position.calledOnce).toBeTruthy()
+            expect(position.firstCall.args[4].allowedRepositoryIds).toEqual(new Set([50, 51]))
+        })
+
+        it('should reject malformed allowed repositories', async () => {
+            const request = got
+                .post(url(`/position?${positionQuery}`), { json: { allowedRepositoryIds: ['x'] } })
+                .json()
+
+            await expect(request).rejects.toThrow('422')
+            expect(position.called).toBeFalsy()
+        })
+
+        it('should reject an abbreviated commit', async () => {
+            await expect(got.get(url(`/position?${query}`)).json()).rejects.toThrow('422')
+            expect(position.called).toBeFalsy()
+        })
+    })
+})
@@ -2,29 +2,37 @@ import * as constants from '../../shared/constants'
 import * as fs from 'mz/fs'
 import * as lsp from 'vscode-languageserver-protocol'
 import * as nodepath from 'path'
+import * as metrics from '../metrics'
 import * as settings from '../settings'
+import * as sqliteModels from '../../shared/models/sqlite'
 import * as validation from '../../shared/api/middleware/validation'
+import { requireToken } from '../../shared/api/middleware/auth'
 import express from 'express'
 import * as uuid from 'uuid'
 import { addTags, logAndTraceCall, TracingContext } from '../../shared/tracing'
-import { Backend } from '../backend/backend'
+import { cancellationFromResponse } from '../../shared/cancellation'
+import { Backend, PaginatedInternalLocations } from '../backend/backend'
 import { encodeCursor } from '../../shared/api/pagination/cursor'
 import { Logger } from 'winston'
 import { nextLink } from '../../shared/api/pagination/link'
-import { pipeline as _pipeline } from 'stream'
-import { promisify } from 'util'
 import { Span, Tracer } from 'opentracing'
 import { wrap } from 'async-middleware'
 import { extractLimitOffset } from '../../shared/api/pagination/limit-offset'
+import { slicePage } from '../../shared/api/pagination/slice'
 import { UploadManager } from '../../shared/store/uploads'
-import { readGzippedJsonElementsFromFile } from '../../shared/input'
-import * as lsif from 'lsif-protocol'
 import { ReferencePaginationCursor } from '../backend/cursor'
-import { LsifUpload } from '../../shared/models/pg'
-import got from 'got'
+import { LsifDumpWithDistance } from '../../shared/store/dumps'
+import { json } from 'body-parser'
+import { body } from 'express-validator'
 import { Connection } from 'typeorm'
-
-const pipeline = promisify(_pipeline)
+import { QueryEventLog } from '../events'
+import { checkContentEncoding, receiveUpload, sendUpload } from '../upload'
+import { CHECKSUM_HEADER } from '../../shared/checksum'
+import { formatHover, HOVER_FORMATS, HoverFormat, negotiateHoverFormat } from '../backend/hover'
+import { originFromRequest } from '../actor'
+import { acceptsNdjson, writeNdjson } from '../../shared/api/ndjson'
+import { ResolvedInternalLocation } from '../backend/location'
+import { QueryDebugInfo, QueryDebugLog } from '../../shared/debug'
 
 /**
  * Create a router containing the LSIF upload and query endpoints.
@@ -32,6 +40,7 @@ const pipeline = promisify(_pipeline)
  * @param connection The Postgres connection.
  * @param backend The backend instance.
  * @param uploadManager The uploads manager instance.
+ * @param eventLog The query event log.
  * @param logger The logger instance.
  * @param tracer The tracer instance.
  */
@@ -39,6 +48,7 @@ export function createLsifRouter(
     connection: Connection,
     backend: Backend,
     uploadManager: UploadManager,
+    eventLog: QueryEventLog,
     logger: Logger,
     tracer: Tracer | undefined
 ): express.Router {
@@ -60,9 +70,48 @@ export function createLsifRouter(
         return root.endsWith('/') ? root : root + '/'
     }
 
+    /**
+     * Throw an error with a 429 status if enqueuing another upload for the given repository
+     * would exceed the limit on queued uploads for the repository or for all repositories.
+     * The count is not taken in the transaction that enqueues the upload, so concurrent
+     * uploads may exceed the limits slightly.
+     *
+     * @param repositoryId The repository identifier.
+     * @param res The express response.
+     */
+    const checkQueueQuota = async (repositoryId: number, res: express.Response): Promise<void> => {
+        if (settings.MAX_QUEUED_UPLOADS_PER_REPOSITORY <= 0 && settings.MAX_QUEUED_UPLOADS <= 0) {
+            return
+        }
+
+        const { repository, total } = await uploadManager.countQueued(repositoryId)
+        const exceeded = (count: number, limit: number): boolean => limit > 0 && count >= limit
+
+        let quota: 'repository' | 'global' | undefined
+        if (exceeded(repository, settings.MAX_QUEUED_UPLOADS_PER_REPOSITORY)) {
+            quota = 'repository'
+        } else if (exceeded(total, settings.MAX_QUEUED_UPLOADS)) {
+            quota = 'global'
+        }
+
+        if (quota) {
+            metrics.uploadsRejectedCounter.labels(quota).inc()
+            res.set('Retry-After', String(settings.QUEUE_QUOTA_RETRY_AFTER))
+            throw Object.assign(
+                new Error(
+                    quota === 'repository'
+                        ? 'Too many uploads are queued for this repository'
+                        : 'Too many uploads are queued'
+                ),
+                { status: 429, code: 'upload_quota_exceeded' }
+            )
+        }
+    }
+
     /**
      * Create a tracing context from the request logger and tracing span
-     * tagged with the given values.
+     * tagged with the given values. The context is cancelled once the client
+     * disconnects before the response has been written.
      *
      * @param req The express request.
      * @param tags The tags to apply to the logger and span.
@@ -70,13 +119,88 @@ export function createLsifRouter(
     const createTracingContext = (
         req: express.Request & { span?: Span },
         tags: { [K: string]: unknown }
-    ): TracingContext => addTags({ logger, span: req.span }, tags)
+    ): TracingContext =>
+        addTags({ logger, span: req.span, cancellation: req.res && cancellationFromResponse(req.res) }, tags)
+
+    /**
+     * Create a tracing context for a code intelligence query. If the client requested an
+     * explanation of the query, either with the `debug` query parameter or with the `X-Debug`
+     * header, a debug log is attached to the context.
+     *
+     * @param req The express request.
+     * @param tags The tags to apply to the logger and span.
+     */
+    const createQueryContext = (
+        req: express.Request & { span?: Span },
+        tags: { [K: string]: unknown }
+    ): TracingContext => {
+        const header = req.header('X-Debug')
+        const debug = req.query.debug === true || header === 'true' || header === '1'
+        return { ...createTracingContext(req, tags), debug: debug ? new QueryDebugLog() : undefined }
+    }
+
+    /**
+     * Return the `debug` field of a query response, which is only present when the tracing
+     * context of the query carries a debug log.
+     *
+     * @param ctx The tracing context of the query.
+     */
+    const debugResponse = ({ debug }: TracingContext): { debug?: QueryDebugInfo } =>
+        debug ? { debug: debug.toJSON() } : {}
+
+    /**
+     * Invoke the given query, recording its duration and whether or not it failed in the
+     * query metrics labeled with the given operation.
+     *
+     * @param operation The name of the query operation.
+     * @param f The function that performs the query.
+     */
+    const instrumentOperation = async <T>(operation: string, f: () => Promise<T>): Promise<T> => {
+        const end = metrics.queryDurationHistogram.labels(operation).startTimer()
+        try {
+            return await f()
+        } catch (error) {
+            metrics.queryErrorsCounter.labels(operation).inc()
+            throw error
+        } finally {
+            end()
+        }
+    }
+
+    /**
+     * Record a successful code intelligence query in the event log and in the query
+     * result metrics.
+     *
+     * @param operation The name of the query operation.
+     * @param args The repository and commit of the query, and the identifier of the dump that answered it.
+     * @param resultCount The number of results returned from the query.
+     * @param timestamp The time the query was received.
+     */
+    const recordQueryEvent = (
+        operation: string,
+        { repositoryId, commit, dumpId }: { repositoryId: number; commit: string; dumpId: number },
+        resultCount: number,
+        timestamp: Date
+    ): void => {
+        metrics.queryResultsHistogram.labels(operation).observe(resultCount)
+
+        eventLog.record({
+            timestamp,
+            operation,
+            repositoryId,
+            commit,
+            dumpId,
+            resultCount,
+            latency: Date.now() - timestamp.getTime(),
+        })
+    }
 
     interface UploadQueryArgs {
         repositoryId: number
         commit: string
         root?: string
         indexerName?: string
+        onDuplicate?: 'reuse' | 'replace'
     }
 
     interface UploadResponse {
@@ -85,45 +209,93 @@ export function createLsifRouter(
 
     router.post(
         '/upload',
+        requireToken(),
         validation.validationMiddleware([
             validation.validateInt('repositoryId'),
             validation.validateNonEmptyString('commit').matches(commitPattern),
             validation.validateOptionalString('root'),
             validation.validateOptionalString('indexerName'),
+            validation.validateOptionalEnum('onDuplicate', ['reuse', 'replace']),
         ]),
         wrap(
             async (req: express.Request, res: express.Response<UploadResponse>): Promise<void> => {
-                const { repositoryId, commit, root: rootRaw, indexerName }: UploadQueryArgs = req.query
+                const { repositoryId, commit, root: rootRaw, indexerName, onDuplicate }: UploadQueryArgs = req.query
 
                 const root = sanitizeRoot(rootRaw)
                 const ctx = createTracingContext(req, { repositoryId, commit, root })
+
+                checkContentEncoding(req.header('Content-Encoding'))
+
+                const contentLength = parseInt(req.headers['content-length'] || '', 10)
+                if (contentLength > settings.MAX_UPLOAD_SIZE_BYTES) {
+                    throw Object.assign(
+                        new Error(`LSIF upload exceeds the maximum size of ${settings.MAX_UPLOAD_SIZE_BYTES} bytes`),
+                        { status: 413, code: 'upload_too_large' }
+                    )
+                }
+
+                // Reject the upload before reading the payload
+                await checkQueueQuota(repositoryId, res)
+
                 const filename = nodepath.join(settings.STORAGE_ROOT, uuid.v4())
-                const output = fs.createWriteStream(filename)
-                await logAndTraceCall(ctx, 'Receiving dump', () => pipeline(req, output))
 
                 try {
-                    const indexer = indexerName || (await findIndexer(filename))
+                    const { metadata, checksum } = await logAndTraceCall(ctx, 'Receiving dump', () =>
+                        receiveUpload(req, filename, settings.MAX_UPLOAD_SIZE_BYTES, req.header(CHECKSUM_HEADER))
+                    )
+
+                    const indexer = indexerName || metadata.indexer
                     if (!indexer) {
-                        throw new Error('Could not find tool type in metadata vertex at the start of the dump.')
+                        throw Object.assign(
+                            new Error('Invalid LSIF upload: the metadata does not name the indexer in its toolInfo'),
+                            { status: 422, code: 'invalid_lsif' }
+                        )
+                    }
+
+                    const key = { repositoryId, commit, root, indexer }
+                    if (onDuplicate === 'reuse') {
+                        // An identical upload that is converted or being converted makes this one redundant
+                        const duplicate = await uploadManager.findDuplicate(key, checksum)
+                        if (duplicate) {
+                            logger.info('Reusing duplicate upload', { id: duplicate.id, state: duplicate.state })
+                            res.status(duplicate.state === 'completed' ? 200 : 202).send({ id: duplicate.id })
+                            return
+                        }
                     }
 
                     const id = await connection.transaction(async entityManager => {
                         // Add upload record
                         const uploadId = await uploadManager.enqueue(
-                            { repositoryId, commit, root, indexer },
+                            {
+                                ...key,
+                                checksum,
+                                lsifVersion: metadata.lsifVersion,
+                                positionEncoding: metadata.positionEncoding,
+                                projectRoot: metadata.projectRoot,
+                                format: metadata.format,
+                            },
                             entityManager,
                             tracer,
-                            ctx.span
+                            ctx.span,
+                            originFromRequest(req)
                         )
 
-                        // Upload the payload file where it can be found by the worker
-                        await logAndTraceCall(ctx, 'Uploading payload to bundle manager', () =>
-                            pipeline(
-                                fs.createReadStream(filename),
-                                got.stream.post(
-                                    new URL(`/uploads/${uploadId}`, settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL).href
-                                )
+                        if (onDuplicate === 'replace') {
+                            // Queued uploads for the same data would only be converted to be replaced
+                            const superseded = await uploadManager.supersedeQueued(
+                                uploadId,
+                                key,
+                                entityManager,
+                                originFromRequest(req)
                             )
+                            if (superseded.length > 0) {
+                                logger.info('Superseded queued uploads', { id: uploadId, superseded })
+                            }
+                        }
+
+                        // Upload the payload file where it can be found by the worker
+                        await logAndTraceCall(ctx, 'Uploading payload to bundle manager', taggedCtx =>
+                            sendUpload(filename, uploadId, checksum, taggedCtx)
                         )
 
                         return uploadId
@@ -134,8 +306,12 @@ export function createLsifRouter(
                     // asynchronously.
                     res.status(202).send({ id })
                 } finally {
-                    // Remove local file
-                    await fs.unlink(filename)
+                    // Remove local file, which does not exist if the upload was rejected early
+                    await fs.unlink(filename).catch(error => {
+                        if (error.code !== 'ENOENT') {
+                            throw error
+                        }
+                    })
                 }
             }
         )
@@ -148,7 +324,8 @@ export function createLsifRouter(
     }
 
     interface ExistsResponse {
-        uploads: LsifUpload[]
+        uploads: LsifDumpWithDistance[]
+        totalCount: number
     }
 
     router.get(
@@ -157,13 +334,67 @@ export function createLsifRouter(
             validation.validateInt('repositoryId'),
             validation.validateNonEmptyString('commit').matches(commitPattern),
             validation.validateNonEmptyString('path'),
+            validation.validateLimit,
+            validation.validateOffset,
         ]),
         wrap(
             async (req: express.Request, res: express.Response<ExistsResponse>): Promise<void> => {
                 const { repositoryId, commit, path }: ExistsQueryArgs = req.query
+                const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_DUMP_PAGE_SIZE)
                 const ctx = createTracingContext(req, { repositoryId, commit })
-                const uploads = await backend.exists(repositoryId, commit, path, ctx)
-                res.json({ uploads })
+                const dumps = await instrumentOperation('exists', () => backend.exists(repositoryId, commit, path, ctx))
+                metrics.queryResultsHistogram.labels('exists').observe(dumps.length)
+                const { page: uploads, nextOffset } = slicePage(dumps, offset, limit)
+
+                if (nextOffset !== undefined) {
+                    res.set('Link', nextLink(req, { limit, offset: nextOffset }))
+                }
+
+                res.json({ uploads, totalCount: dumps.length })
+            }
+        )
+    )
+
+    interface ExistsManyQueryArgs {
+        repositoryId: number
+        commit: string
+    }
+
+    interface ExistsManyBody {
+        paths: string[]
+    }
+
+    interface ExistsManyResponse {
+        paths: { [path: string]: boolean }
+    }
+
+    router.post(
+        '/exists',
+        json(),
+        validation.validationMiddleware([
+            validation.validateInt('repositoryId'),
+            validation.validateNonEmptyString('commit').matches(commitPattern),
+            body('paths').isArray(),
+            body('paths.*').isString(),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<ExistsManyResponse>): Promise<void> => {
+                const { repositoryId, commit }: ExistsManyQueryArgs = req.query
+                const { paths }: ExistsManyBody = req.body
+                const ctx = createTracingContext(req, { repositoryId, commit, numPaths: paths.length })
+                const results = await instrumentOperation('existsMany', () =>
+                    backend.existsMany(repositoryId, commit, paths, ctx)
+                )
+
+                const existing = Array.from(results.values()).filter(exists => exists).length
+                metrics.queryResultsHistogram.labels('existsMany').observe(existing)
+
+                const response: { [path: string]: boolean } = {}
+                for (const [path, exists] of results) {
+                    response[path] = exists
+                }
+
+                res.json({ paths: response })
             }
         )
     )
@@ -177,8 +408,16 @@ export function createLsifRouter(
         uploadId: number
     }
 
+    interface LocationResponse {
+        repositoryId: number
+        commit: string
+        path: string
+        range: lsp.Range
+    }
+
     interface LocationsResponse {
-        locations: { repositoryId: number; commit: string; path: string; range: lsp.Range }[]
+        locations: LocationResponse[]
+        debug?: QueryDebugInfo
     }
 
     router.get(
@@ -190,24 +429,22 @@ export function createLsifRouter(
             validation.validateInt('line'),
             validation.validateInt('character'),
             validation.validateInt('uploadId'),
+            validation.validateOptionalBoolean('debug'),
         ]),
         wrap(
             async (req: express.Request, res: express.Response<LocationsResponse>): Promise<void> => {
                 const { repositoryId, commit, path, line, character, uploadId }: FilePositionArgs = req.query
-                const ctx = createTracingContext(req, { repositoryId, commit, path })
+                const ctx = createQueryContext(req, { repositoryId, commit, path })
+                const timestamp = new Date()
 
-                const locations = await backend.definitions(
-                    repositoryId,
-                    commit,
-                    path,
-                    { line, character },
-                    uploadId,
-                    ctx
+                const locations = await instrumentOperation('definitions', () =>
+                    backend.definitions(repositoryId, commit, path, { line, character }, uploadId, ctx)
                 )
                 if (locations === undefined) {
-                    throw Object.assign(new Error('LSIF upload not found'), { status: 404 })
+                    throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
                 }
 
+                recordQueryEvent('definitions', { repositoryId, commit, dumpId: uploadId }, locations.length, timestamp)
                 res.send({
                     locations: locations.map(l => ({
                         repositoryId: l.dump.repositoryId,
@@ -215,18 +452,14 @@ export function createLsifRouter(
                         path: l.path,
                         range: l.range,
                     })),
+                    ...debugResponse(ctx),
                 })
             }
         )
     )
 
-    interface ReferencesQueryArgs extends FilePositionArgs {
-        commit: string
-        cursor: ReferencePaginationCursor | undefined
-    }
-
     router.get(
-        '/references',
+        '/implementations',
         validation.validationMiddleware([
             validation.validateInt('repositoryId'),
             validation.validateNonEmptyString('commit'),
@@ -234,48 +467,254 @@ export function createLsifRouter(
             validation.validateInt('line'),
             validation.validateInt('character'),
             validation.validateInt('uploadId'),
-            validation.validateLimit,
-            validation.validateCursor<ReferencePaginationCursor>(),
         ]),
         wrap(
             async (req: express.Request, res: express.Response<LocationsResponse>): Promise<void> => {
-                const { repositoryId, commit, path, line, character, uploadId, cursor }: ReferencesQueryArgs = req.query
-                const { limit } = extractLimitOffset(req.query, settings.DEFAULT_REFERENCES_PAGE_SIZE)
+                const { repositoryId, commit, path, line, character, uploadId }: FilePositionArgs = req.query
                 const ctx = createTracingContext(req, { repositoryId, commit, path })
+                const timestamp = new Date()
+
+                const locations = await instrumentOperation('implementations', () =>
+                    backend.implementations(repositoryId, commit, path, { line, character }, uploadId, ctx)
+                )
+                if (locations === undefined) {
+                    throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
+                }
 
+                recordQueryEvent(
+                    'implementations',
+                    { repositoryId, commit, dumpId: uploadId },
+                    locations.length,
+                    timestamp
+                )
+                res.send({
+                    locations: locations.map(l => ({
+                        repositoryId: l.dump.repositoryId,
+                        commit: l.dump.commit,
+                        path: l.path,
+                        range: l.range,
+                    })),
+                })
+            }
+        )
+    )
+
+    /**
+     * Convert a location resolved by the backend into the format returned to the client.
+     *
+     * @param location The resolved location.
+     */
+    const formatLocation = ({ dump, path, range }: ResolvedInternalLocation): LocationResponse => ({
+        repositoryId: dump.repositoryId,
+        commit: dump.commit,
+        path,
+        range,
+    })
+
+    interface ReferencesQueryArgs extends FilePositionArgs {
+        commit: string
+        cursor: ReferencePaginationCursor | undefined
+        allowedRepositoryIds: number[] | undefined
+    }
+
+    interface ReferencesBody {
+        allowedRepositoryIds: number[] | undefined
+    }
+
+    /**
+     * Respond with a page of references, or with every page if the client accepts ndjson.
+     * The allowed repositories are read from the body of POST requests and from the query
+     * string otherwise.
+     *
+     * @param req The request.
+     * @param res The response.
+     */
+    const handleReferences = async (req: express.Request, res: express.Response<LocationsResponse>): Promise<void> => {
+        const { repositoryId, commit, path, line, character, uploadId, cursor }: ReferencesQueryArgs = req.query
+        const { allowedRepositoryIds }: ReferencesBody | ReferencesQueryArgs =
+            req.method === 'POST' ? req.body : req.query
+        const { limit } = extractLimitOffset(req.query, settings.DEFAULT_REFERENCES_PAGE_SIZE)
+        const ctx = createQueryContext(req, { repositoryId, commit, path })
+        const timestamp = new Date()
+
+        // A cursor carries the dump from which the page is resolved, which is not
+        // necessarily the upload the client requested.
+        const dumpId = cursor ? cursor.dumpId : uploadId
+
+        const resolvePage = (pageCursor: ReferencePaginationCursor | undefined): Promise<PaginatedInternalLocations> =>
+            instrumentOperation('references', async () => {
                 const result = await backend.references(
                     repositoryId,
                     commit,
                     path,
                     { line, character },
-                    { limit, cursor },
+                    {
+                        limit,
+                        cursor: pageCursor,
+                        allowedRepositoryIds: allowedRepositoryIds && new Set(allowedRepositoryIds),
+                    },
                     constants.DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT,
                     uploadId,
                     ctx
                 )
                 if (result === undefined) {
-                    throw Object.assign(new Error('LSIF upload not found'), { status: 404 })
+                    throw Object.assign(new Error('LSIF upload not found'), {
+                        status: 404,
+                        code: 'dump_not_found',
+                    })
+                }
+
+                return result
+            })
+
+        if (acceptsNdjson(req)) {
+            // Follow the cursor of each page until the result set is exhausted. The
+            // locations of each page are written as soon as the page is resolved, so
+            // the full result set is never held in memory. Streamed responses do not
+            // carry debug output.
+            let resultCount = 0
+            await writeNdjson(
+                res,
+                (async function* () {
+                    let pageCursor = cursor
+                    do {
+                        const { locations, newCursor } = await resolvePage(pageCursor)
+                        resultCount += locations.length
+                        yield* locations.map(formatLocation)
+                        pageCursor = newCursor
+                    } while (pageCursor)
+                })()
+            )
+
+            recordQueryEvent('references', { repositoryId, commit, dumpId }, resultCount, timestamp)
+            return
+        }
+
+        const { locations, newCursor } = await resolvePage(cursor)
+        recordQueryEvent('references', { repositoryId, commit, dumpId }, locations.length, timestamp)
+
+        const encodedCursor = encodeCursor<ReferencePaginationCursor>(newCursor)
+        if (encodedCursor) {
+            res.set('Link', nextLink(req, { limit, cursor: encodedCursor }))
+        }
+
+        res.json({ locations: locations.map(formatLocation), ...debugResponse(ctx) })
+    }
+
+    const referencesValidators = [
+        validation.validateInt('repositoryId'),
+        validation.validateNonEmptyString('commit'),
+        validation.validateNonEmptyString('path'),
+        validation.validateInt('line'),
+        validation.validateInt('character'),
+        validation.validateInt('uploadId'),
+        validation.validateLimit,
+        validation.validateCursor<ReferencePaginationCursor>(),
+        validation.validateOptionalBoolean('debug'),
+    ]
+
+    // The allowed repositories of a user can number in the thousands, so they may also be
+    // sent in the body of a POST request rather than in the query string.
+    router.get(
+        '/references',
+        validation.validationMiddleware([
+            ...referencesValidators,
+            validation.validateOptionalIntList('allowedRepositoryIds'),
+        ]),
+        wrap(handleReferences)
+    )
+
+    router.post(
+        '/references',
+        json({ limit: '10mb' }),
+        validation.validationMiddleware([
+            ...referencesValidators,
+            body('allowedRepositoryIds').optional().isArray(),
+            body('allowedRepositoryIds.*').isInt().toInt(),
+        ]),
+        wrap(handleReferences)
+    )
+
+    interface MonikerLocationsQueryArgs {
+        scheme: string
+        identifier: string
+        kind: 'definition' | 'reference'
+        repositoryId?: number
+        commit?: string
+        global?: boolean
+    }
+
+    interface MonikerLocationsResponse {
+        locations: LocationResponse[]
+        totalCount: number
+        debug?: QueryDebugInfo
+    }
+
+    router.get(
+        '/moniker/locations',
+        validation.validationMiddleware([
+            validation.validateNonEmptyString('scheme'),
+            validation.validateNonEmptyString('identifier'),
+            validation.validateNonEmptyString('kind').isIn(['definition', 'reference']),
+            validation.validateOptionalInt('repositoryId'),
+            validation.validateOptionalString('commit'),
+            validation.validateOptionalBoolean('global'),
+            validation.validateLimit,
+            validation.validateOffset,
+            validation.validateOptionalBoolean('debug'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<MonikerLocationsResponse>): Promise<void> => {
+                const { scheme, identifier, kind, repositoryId, commit, global }: MonikerLocationsQueryArgs = req.query
+                const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_DUMP_PAGE_SIZE)
+
+                let scope: { repositoryId: number; commit: string } | undefined
+                if (!global) {
+                    if (repositoryId === undefined || !commit) {
+                        throw Object.assign(
+                            new Error('Either a repositoryId and commit or the global flag must be supplied'),
+                            { status: 400, code: 'missing_scope' }
+                        )
+                    }
+
+                    scope = { repositoryId, commit }
                 }
 
-                const { locations, newCursor } = result
-                const encodedCursor = encodeCursor<ReferencePaginationCursor>(newCursor)
-                if (encodedCursor) {
-                    res.set('Link', nextLink(req, { limit, cursor: encodedCursor }))
+                const ctx = createQueryContext(req, { scheme, identifier, kind, repositoryId, commit })
+                const { locations, totalCount, newOffset } = await instrumentOperation('moniker-locations', () =>
+                    backend.monikerLocations({ scheme, identifier }, kind, scope, limit, offset, ctx)
+                )
+                metrics.queryResultsHistogram.labels('moniker-locations').observe(locations.length)
+
+                if (newOffset < totalCount) {
+                    res.set('Link', nextLink(req, { limit, offset: newOffset }))
                 }
 
-                res.json({
-                    locations: locations.map(l => ({
-                        repositoryId: l.dump.repositoryId,
-                        commit: l.dump.commit,
-                        path: l.path,
-                        range: l.range,
-                    })),
-                })
+                res.json({ locations: locations.map(formatLocation), totalCount, ...debugResponse(ctx) })
             }
         )
     )
 
-    type HoverResponse = { text: string; range: lsp.Range } | null
+    type HoverResponse = { text: string; range: lsp.Range; truncated: boolean } | null
+
+    /**
+     * Convert the given hover result into the format requested by the client and truncate
+     * its text to the configured size limit.
+     *
+     * @param req The express request.
+     * @param hover The hover result.
+     */
+    const formatHoverResponse = (
+        req: express.Request,
+        hover: { text: string; range: lsp.Range } | null
+    ): HoverResponse => {
+        if (hover === null) {
+            return null
+        }
+
+        const format = negotiateHoverFormat(req.query.format as HoverFormat | undefined, req.header('Accept'))
+        return { ...formatHover(hover.text, format, settings.MAX_HOVER_TEXT_SIZE_BYTES), range: hover.range }
+    }
 
     router.get(
         '/hover',
@@ -286,38 +725,314 @@ export function createLsifRouter(
             validation.validateInt('line'),
             validation.validateInt('character'),
             validation.validateInt('uploadId'),
+            validation.validateOptionalEnum('format', HOVER_FORMATS),
+            validation.validateOptionalBoolean('debug'),
         ]),
         wrap(
-            async (req: express.Request, res: express.Response<HoverResponse>): Promise<void> => {
+            async (
+                req: express.Request,
+                res: express.Response<{ hover: HoverResponse; debug?: QueryDebugInfo }>
+            ): Promise<void> => {
                 const { repositoryId, commit, path, line, character, uploadId }: FilePositionArgs = req.query
-                const ctx = createTracingContext(req, { repositoryId, commit, path })
+                const ctx = createQueryContext(req, { repositoryId, commit, path })
+                const timestamp = new Date()
 
-                const result = await backend.hover(repositoryId, commit, path, { line, character }, uploadId, ctx)
+                const result = await instrumentOperation('hover', () =>
+                    backend.hover(repositoryId, commit, path, { line, character }, uploadId, ctx)
+                )
                 if (result === undefined) {
-                    throw Object.assign(new Error('LSIF upload not found'), { status: 404 })
+                    throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
                 }
 
-                res.json(result)
+                recordQueryEvent('hover', { repositoryId, commit, dumpId: uploadId }, result ? 1 : 0, timestamp)
+
+                res.json({ hover: formatHoverResponse(req, result), ...debugResponse(ctx) })
             }
         )
     )
 
-    return router
-}
+    interface HoversQueryArgs {
+        repositoryId: number
+        commit: string
+        path: string
+        uploadId: number
+    }
 
-/**
- * Read and decode the first entry of the dump. If the entry exists, encodes a metadata vertex,
- * and contains a tool info name field, return the contents of that field; otherwise undefined.
- *
- * @param filename The filename to read.
- */
-async function findIndexer(filename: string): Promise<string | undefined> {
-    for await (const element of readGzippedJsonElementsFromFile(filename) as AsyncIterable<lsif.Vertex | lsif.Edge>) {
-        if (element.type === lsif.ElementTypes.vertex && element.label === lsif.VertexLabels.metaData) {
-            return element.toolInfo?.name
+    interface HoversBody {
+        positions: lsp.Position[]
+    }
+
+    interface HoversResponse {
+        hovers: HoverResponse[]
+    }
+
+    router.post(
+        '/hovers',
+        json(),
+        validation.validationMiddleware([
+            validation.validateInt('repositoryId'),
+            validation.validateNonEmptyString('commit'),
+            validation.validateNonEmptyString('path'),
+            validation.validateInt('uploadId'),
+            validation.validateOptionalEnum('format', HOVER_FORMATS),
+            body('positions').isArray(),
+            body('positions.*.line').isInt().toInt(),
+            body('positions.*.character').isInt().toInt(),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<HoversResponse>): Promise<void> => {
+                const { repositoryId, commit, path, uploadId }: HoversQueryArgs = req.query
+                const { positions }: HoversBody = req.body
+                const ctx = createTracingContext(req, { repositoryId, commit, path, numPositions: positions.length })
+                const timestamp = new Date()
+
+                const hovers = await instrumentOperation('hovers', () =>
+                    backend.hovers(repositoryId, commit, path, positions, uploadId, ctx)
+                )
+                if (hovers === undefined) {
+                    throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
+                }
+
+                const resultCount = hovers.filter(hover => hover !== null).length
+                recordQueryEvent('hovers', { repositoryId, commit, dumpId: uploadId }, resultCount, timestamp)
+
+                res.json({ hovers: hovers.map(hover => formatHoverResponse(req, hover)) })
+            }
+        )
+    )
+
+    interface PositionQueryArgs extends FilePositionArgs {
+        allowedRepositoryIds: number[] | undefined
+    }
+
+    interface PositionResponse {
+        hover: HoverResponse
+        definitions: LocationResponse[]
+        references: LocationResponse[]
+        referencesCursor?: string
+        debug?: QueryDebugInfo
+    }
+
+    interface PositionBody {
+        allowedRepositoryIds: number[] | undefined
+    }
+
+    /**
+     * Respond with the hover text, definitions, and first page of references of a position.
+     * The allowed repositories are read from the body of POST requests and from the query
+     * string otherwise.
+     *
+     * @param req The request.
+     * @param res The response.
+     */
+    const handlePosition = async (req: express.Request, res: express.Response<PositionResponse>): Promise<void> => {
+        const { repositoryId, commit, path, line, character, uploadId }: PositionQueryArgs = req.query
+        const { allowedRepositoryIds }: PositionBody | PositionQueryArgs = req.method === 'POST' ? req.body : req.query
+        const { limit } = extractLimitOffset(req.query, settings.DEFAULT_REFERENCES_PAGE_SIZE)
+        const ctx = createQueryContext(req, { repositoryId, commit, path })
+        const timestamp = new Date()
+
+        const result = await instrumentOperation('position', () =>
+            backend.position(
+                repositoryId,
+                commit,
+                path,
+                { line, character },
+                { limit, allowedRepositoryIds: allowedRepositoryIds && new Set(allowedRepositoryIds) },
+                constants.DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT,
+                uploadId,
+                ctx
+            )
+        )
+        if (result === undefined) {
+            throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
         }
-        break
+
+        const { hover, definitions, references } = result
+        const resultCount = (hover ? 1 : 0) + definitions.length + references.locations.length
+        recordQueryEvent('position', { repositoryId, commit, dumpId: uploadId }, resultCount, timestamp)
+
+        res.json({
+            hover: formatHoverResponse(req, hover),
+            definitions: definitions.map(formatLocation),
+            references: references.locations.map(formatLocation),
+            referencesCursor: encodeCursor<ReferencePaginationCursor>(references.newCursor),
+            ...debugResponse(ctx),
+        })
+    }
+
+    const positionValidators = [
+        validation.validateInt('repositoryId'),
+        validation.validateNonEmptyString('commit').matches(commitPattern),
+        validation.validateNonEmptyString('path'),
+        validation.validateInt('line'),
+        validation.validateInt('character'),
+        validation.validateInt('uploadId'),
+        validation.validateLimit,
+        validation.validateOptionalEnum('format', HOVER_FORMATS),
+        validation.validateOptionalBoolean('debug'),
+    ]
+
+    // As for references, the allowed repositories may also be sent in the body of a POST request
+    router.get(
+        '/position',
+        validation.validationMiddleware([
+            ...positionValidators,
+            validation.validateOptionalIntList('allowedRepositoryIds'),
+        ]),
+        wrap(handlePosition)
+    )
+
+    router.post(
+        '/position',
+        json({ limit: '10mb' }),
+        validation.validationMiddleware([
+            ...positionValidators,
+            body('allowedRepositoryIds').optional().isArray(),
+            body('allowedRepositoryIds.*').isInt().toInt(),
+        ]),
+        wrap(handlePosition)
+    )
+
+    interface SymbolsQueryArgs {
+        repositoryId: number
+        commit: string
+        path: string
+        uploadId: number
     }
 
-    return undefined
+    interface SymbolsResponse {
+        symbols: sqliteModels.DocumentSymbolData[]
+    }
+
+    router.get(
+        '/symbols',
+        validation.validationMiddleware([
+            validation.validateInt('repositoryId'),
+            validation.validateNonEmptyString('commit'),
+            validation.validateNonEmptyString('path'),
+            validation.validateInt('uploadId'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<SymbolsResponse>): Promise<void> => {
+                const { repositoryId, commit, path, uploadId }: SymbolsQueryArgs = req.query
+                const ctx = createTracingContext(req, { repositoryId, commit, path })
+                const timestamp = new Date()
+
+                const symbols = await instrumentOperation('symbols', () =>
+                    backend.documentSymbols(repositoryId, commit, path, uploadId, ctx)
+                )
+                if (symbols === undefined) {
+                    throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
+                }
+
+                recordQueryEvent('symbols', { repositoryId, commit, dumpId: uploadId }, symbols.length, timestamp)
+
+                res.json({ symbols })
+            }
+        )
+    )
+
+    interface RangesQueryArgs {
+        repositoryId: number
+        commit: string
+        path: string
+        startLine: number
+        endLine: number
+        uploadId: number
+    }
+
+    interface RangesResponse {
+        ranges: sqliteModels.RangeSummaryData[]
+    }
+
+    router.get(
+        '/ranges',
+        validation.validationMiddleware([
+            validation.validateInt('repositoryId'),
+            validation.validateNonEmptyString('commit'),
+            validation.validateNonEmptyString('path'),
+            validation.validateInt('startLine'),
+            validation.validateInt('endLine'),
+            validation.validateInt('uploadId'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<RangesResponse>): Promise<void> => {
+                const { repositoryId, commit, path, startLine, endLine, uploadId }: RangesQueryArgs = req.query
+                const ctx = createTracingContext(req, { repositoryId, commit, path, startLine, endLine })
+                const timestamp = new Date()
+
+                const ranges = await instrumentOperation('ranges', () =>
+                    backend.ranges(repositoryId, commit, path, startLine, endLine, uploadId, ctx)
+                )
+                if (ranges === undefined) {
+                    throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
+                }
+
+                recordQueryEvent('ranges', { repositoryId, commit, dumpId: uploadId }, ranges.length, timestamp)
+
+                res.json({ ranges })
+            }
+        )
+    )
+
+    interface DiagnosticsQueryArgs {
+        repositoryId: number
+        commit: string
+        path: string
+    }
+
+    interface DiagnosticsResponse {
+        diagnostics: {
+            repositoryId: number
+            commit: string
+            path: string
+            range: lsp.Range
+            severity: number | null
+            code: string | null
+            message: string
+            source: string | null
+            uploadId: number
+        }[]
+        totalCount: number
+    }
+
+    router.get(
+        '/diagnostics',
+        validation.validationMiddleware([
+            validation.validateInt('repositoryId'),
+            validation.validateNonEmptyString('commit').matches(commitPattern),
+            validation.validateNonEmptyString('path'),
+            validation.validateLimit,
+            validation.validateOffset,
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<DiagnosticsResponse>): Promise<void> => {
+                const { repositoryId, commit, path }: DiagnosticsQueryArgs = req.query
+                const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_DIAGNOSTICS_PAGE_SIZE)
+                const ctx = createTracingContext(req, { repositoryId, commit, path })
+                const allDiagnostics = await instrumentOperation('diagnostics', () =>
+                    backend.diagnostics(repositoryId, commit, path, ctx)
+                )
+                metrics.queryResultsHistogram.labels('diagnostics').observe(allDiagnostics.length)
+                const { page: diagnostics, nextOffset } = slicePage(allDiagnostics, offset, limit)
+
+                if (nextOffset !== undefined) {
+                    res.set('Link', nextLink(req, { limit, offset: nextOffset }))
+                }
+
+                res.json({
+                    diagnostics: diagnostics.map(({ dump, ...diagnostic }) => ({
+                        ...diagnostic,
+                        repositoryId: dump.repositoryId,
+                        commit: dump.commit,
+                        uploadId: dump.id,
+                    })),
+                    totalCount: allDiagnostics.length,
+                })
+            }
+        )
+    )
+
+    return router
 }
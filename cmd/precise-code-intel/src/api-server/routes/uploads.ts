@@ -1,36 +1,53 @@
 import * as pgModels from '../../shared/models/pg'
 import * as settings from '../settings'
 import * as validation from '../../shared/api/middleware/validation'
+import { requireToken } from '../../shared/api/middleware/auth'
 import express from 'express'
-import { nextLink } from '../../shared/api/pagination/link'
+import { formatLinks, LinkParams, nextLink, offsetLinks } from '../../shared/api/pagination/link'
+import { encodeCursor } from '../../shared/api/pagination/cursor'
 import { wrap } from 'async-middleware'
 import { extractLimitOffset } from '../../shared/api/pagination/limit-offset'
-import { UploadManager, LsifUploadWithPlaceInQueue } from '../../shared/store/uploads'
+import { UploadManager, LsifUploadWithPlaceInQueue, UploadCursor } from '../../shared/store/uploads'
 import { DumpManager } from '../../shared/store/dumps'
-import { EntityManager } from 'typeorm'
+import { Connection, EntityManager } from 'typeorm'
 import { SRC_FRONTEND_INTERNAL } from '../../shared/config/settings'
 import { TracingContext, addTags } from '../../shared/tracing'
+import { cancellationFromResponse } from '../../shared/cancellation'
 import { Span } from 'opentracing'
 import { Logger } from 'winston'
 import { updateCommitsAndDumpsVisibleFromTip } from '../../shared/visibility'
+import { QueryResultCache } from '../backend/cache'
+import { originFromRequest } from '../actor'
+import { Database } from '../backend/database'
+import { BundleVerification } from '../../shared/verification'
+import { ServerSentEvent, writeServerSentEvents } from '../../shared/api/sse'
+import { NotificationListener } from '../../shared/database/notifications'
+import { watchUploadProgress, watchUploadState } from '../watch'
 
 /**
  * Create a router containing the upload endpoints.
  *
+ * @param connection The Postgres connection.
  * @param dumpManager The dumps manager instance.
  * @param uploadManager The uploads manager instance.
+ * @param resultCache The cache of query results to invalidate when the dumps of a repository change.
+ * @param uploadStateListener The listener of notifications sent when the state of an upload changes.
  * @param logger The logger instance.
  */
 export function createUploadRouter(
+    connection: Connection,
     dumpManager: DumpManager,
     uploadManager: UploadManager,
+    resultCache: QueryResultCache,
+    uploadStateListener: NotificationListener,
     logger: Logger
 ): express.Router {
     const router = express.Router()
 
     /**
      * Create a tracing context from the request logger and tracing span
-     * tagged with the given values.
+     * tagged with the given values. The context is cancelled once the client
+     * disconnects before the response has been written.
      *
      * @param req The express request.
      * @param tags The tags to apply to the logger and span.
@@ -38,16 +55,70 @@ export function createUploadRouter(
     const createTracingContext = (
         req: express.Request & { span?: Span },
         tags: { [K: string]: unknown }
-    ): TracingContext => addTags({ logger, span: req.span }, tags)
+    ): TracingContext =>
+        addTags({ logger, span: req.span, cancellation: req.res && cancellationFromResponse(req.res) }, tags)
 
     interface UploadsQueryArgs {
         query: string
         state?: pgModels.LsifUploadState
         visibleAtTip?: boolean
+        pagination?: 'offset' | 'cursor'
+        cursor?: UploadCursor
     }
 
     type UploadResponse = LsifUploadWithPlaceInQueue
 
+    interface AllUploadsQueryArgs {
+        state?: pgModels.LsifUploadState
+        indexer?: string
+        repositoryId?: number
+        uploadedAfter?: Date
+        uploadedBefore?: Date
+        cursor?: UploadCursor
+    }
+
+    interface AllUploadsResponse {
+        uploads: LsifUploadWithPlaceInQueue[]
+    }
+
+    router.get(
+        '/uploads',
+        validation.validationMiddleware([
+            validation.validateLsifUploadState,
+            validation.validateOptionalString('indexer'),
+            validation.validateOptionalInt('repositoryId'),
+            validation.validateOptionalDate('uploadedAfter'),
+            validation.validateOptionalDate('uploadedBefore'),
+            validation.validateLimit,
+            validation.validateCursor<UploadCursor>(),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<AllUploadsResponse>): Promise<void> => {
+                const {
+                    state,
+                    indexer,
+                    repositoryId,
+                    uploadedAfter,
+                    uploadedBefore,
+                    cursor,
+                }: AllUploadsQueryArgs = req.query
+                const { limit } = extractLimitOffset(req.query, settings.DEFAULT_UPLOAD_PAGE_SIZE)
+                const { uploads, nextCursor } = await uploadManager.getAllUploads(
+                    { state, indexer, repositoryId, uploadedAfter, uploadedBefore },
+                    limit,
+                    cursor
+                )
+
+                const encodedCursor = encodeCursor<UploadCursor>(nextCursor)
+                if (encodedCursor) {
+                    res.set('Link', nextLink(req, { limit, cursor: encodedCursor }))
+                }
+
+                res.json({ uploads })
+            }
+        )
+    )
+
     router.get(
         '/uploads/:id([0-9]+)',
         wrap(
@@ -60,34 +131,375 @@ export function createUploadRouter(
 
                 throw Object.assign(new Error('Upload not found'), {
                     status: 404,
+                    code: 'upload_not_found',
                 })
             }
         )
     )
 
+    /** The number of upload watch and progress streams currently open. */
+    let openStreams = 0
+
+    /**
+     * Write the given events of an upload to the response as server-sent events. Rejects the
+     * request if the maximum number of streams is already open.
+     *
+     * @param res The HTTP response.
+     * @param events The events to write.
+     */
+    const writeUploadEvents = async (res: express.Response, events: AsyncIterable<ServerSentEvent>): Promise<void> => {
+        if (settings.MAX_UPLOAD_STREAMS > 0 && openStreams >= settings.MAX_UPLOAD_STREAMS) {
+            throw Object.assign(new Error('Too many upload streams are open'), { status: 503, code: 'server_busy' })
+        }
+
+        openStreams++
+        try {
+            await writeServerSentEvents(res, events)
+        } finally {
+            openStreams--
+        }
+    }
+
+    router.get(
+        '/uploads/:id([0-9]+)/progress',
+        wrap(
+            async (req: express.Request, res: express.Response): Promise<void> => {
+                const upload = await uploadManager.getUpload(parseInt(req.params.id, 10))
+                if (!upload) {
+                    throw Object.assign(new Error('Upload not found'), {
+                        status: 404,
+                        code: 'upload_not_found',
+                    })
+                }
+
+                await writeUploadEvents(
+                    res,
+                    watchUploadProgress(
+                        upload,
+                        id => uploadManager.getUpload(id),
+                        uploadStateListener,
+                        settings.UPLOAD_WATCH_RECHECK_INTERVAL * 1000,
+                        cancellationFromResponse(res)
+                    )
+                )
+            }
+        )
+    )
+
+    router.get(
+        '/uploads/:id([0-9]+)/watch',
+        wrap(
+            async (req: express.Request, res: express.Response): Promise<void> => {
+                const upload = await uploadManager.getUpload(parseInt(req.params.id, 10))
+                if (!upload) {
+                    throw Object.assign(new Error('Upload not found'), {
+                        status: 404,
+                        code: 'upload_not_found',
+                    })
+                }
+
+                await writeUploadEvents(
+                    res,
+                    watchUploadState(
+                        upload,
+                        id => uploadManager.getUpload(id),
+                        uploadStateListener,
+                        settings.UPLOAD_WATCH_RECHECK_INTERVAL * 1000,
+                        cancellationFromResponse(res)
+                    )
+                )
+            }
+        )
+    )
+
+    /**
+     * Create a function that updates the dumps visible at the tip of a repository and
+     * invalidates the cached query results of the repository.
+     *
+     * @param ctx The tracing context.
+     */
+    const createVisibilityUpdater = (ctx: TracingContext) => async (
+        entityManager: EntityManager,
+        repositoryId: number
+    ): Promise<void> => {
+        await updateCommitsAndDumpsVisibleFromTip({
+            entityManager,
+            dumpManager,
+            frontendUrl: SRC_FRONTEND_INTERNAL,
+            repositoryId,
+            ctx,
+        })
+
+        resultCache.invalidateRepository(repositoryId)
+    }
+
     router.delete(
         '/uploads/:id([0-9]+)',
+        requireToken(),
         wrap(
             async (req: express.Request, res: express.Response<never>): Promise<void> => {
                 const id = parseInt(req.params.id, 10)
                 const ctx = createTracingContext(req, { id })
 
-                const updateVisibility = (entityManager: EntityManager, repositoryId: number): Promise<void> =>
-                    updateCommitsAndDumpsVisibleFromTip({
-                        entityManager,
-                        dumpManager,
-                        frontendUrl: SRC_FRONTEND_INTERNAL,
-                        repositoryId,
-                        ctx,
+                // The upload is only marked as deleted here so that it can be restored. It is
+                // removed for good by the janitor once the restore window has passed.
+                if (await uploadManager.softDeleteUpload(id, createVisibilityUpdater(ctx), originFromRequest(req))) {
+                    logger.info('Deleted upload', { id })
+                    res.status(204).send()
+                    return
+                }
+
+                throw Object.assign(new Error('Upload not found'), {
+                    status: 404,
+                    code: 'upload_not_found',
+                })
+            }
+        )
+    )
+
+    router.post(
+        '/uploads/:id([0-9]+)/restore',
+        requireToken(),
+        wrap(
+            async (req: express.Request, res: express.Response<UploadResponse>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const ctx = createTracingContext(req, { id })
+
+                const result = await uploadManager.restoreUpload(
+                    id,
+                    settings.UPLOAD_RESTORE_WINDOW,
+                    createVisibilityUpdater(ctx),
+                    originFromRequest(req)
+                )
+
+                if (result === 'expired') {
+                    throw Object.assign(new Error('Upload was deleted too long ago to be restored'), {
+                        status: 422,
+                        code: 'restore_window_expired',
                     })
+                }
 
-                if (await uploadManager.deleteUpload(id, updateVisibility)) {
-                    res.status(204).send()
+                if (result === 'conflict') {
+                    throw Object.assign(new Error('Upload has been superseded by a newer upload'), {
+                        status: 409,
+                        code: 'upload_superseded',
+                    })
+                }
+
+                const upload = result && (await uploadManager.getUpload(id))
+                if (!upload) {
+                    throw Object.assign(new Error('Upload not found'), {
+                        status: 404,
+                        code: 'upload_not_found',
+                    })
+                }
+
+                logger.info('Restored upload', { id, state: upload.state })
+                res.send(upload)
+            }
+        )
+    )
+
+    router.post(
+        '/uploads/:id([0-9]+)/retry',
+        requireToken(),
+        wrap(
+            async (req: express.Request, res: express.Response<UploadResponse>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const state = await uploadManager.requeue(id, settings.MAX_UPLOAD_ATTEMPTS, originFromRequest(req))
+                const upload = await uploadManager.getUpload(id)
+                if (!upload) {
+                    throw Object.assign(new Error('Upload not found'), {
+                        status: 404,
+                        code: 'upload_not_found',
+                    })
+                }
+
+                if (!state) {
+                    throw Object.assign(new Error(`Upload is ${upload.state}, not errored`), {
+                        status: 422,
+                        code: 'upload_not_errored',
+                    })
+                }
+
+                logger.info('Retried upload', { id, state, attempts: upload.attempts })
+                res.send(upload)
+            }
+        )
+    )
+
+    interface VerifyQueryArgs {
+        requeue?: boolean
+    }
+
+    interface VerifyResponse {
+        verification: BundleVerification
+        upload: UploadResponse
+    }
+
+    router.post(
+        '/uploads/:id([0-9]+)/verify',
+        requireToken(),
+        validation.validationMiddleware([validation.validateOptionalBoolean('requeue')]),
+        wrap(
+            async (req: express.Request, res: express.Response<VerifyResponse>): Promise<void> => {
+                const { requeue }: VerifyQueryArgs = req.query
+                const id = parseInt(req.params.id, 10)
+                const ctx = createTracingContext(req, { id })
+
+                const upload = await uploadManager.getUpload(id)
+                if (!upload) {
+                    throw Object.assign(new Error('Upload not found'), {
+                        status: 404,
+                        code: 'upload_not_found',
+                    })
+                }
+
+                if (upload.state !== 'completed') {
+                    throw Object.assign(new Error(`Upload is ${upload.state}, not completed`), {
+                        status: 422,
+                        code: 'upload_not_completed',
+                    })
+                }
+
+                const verification = await new Database(id).verify(ctx)
+                if (!verification.valid) {
+                    // Flag the upload so that the corrupted bundle is no longer queried
+                    const origin = originFromRequest(req)
+                    const updateVisibility = createVisibilityUpdater(ctx)
+                    if (await uploadManager.markCorrupted(id, verification.problems, updateVisibility, origin)) {
+                        logger.warn('Bundle failed verification', { id, problems: verification.problems })
+
+                        if (requeue) {
+                            await uploadManager.requeue(id, settings.MAX_UPLOAD_ATTEMPTS, origin)
+                        }
+                    }
+                }
+
+                res.send({ verification, upload: (await uploadManager.getUpload(id)) || upload })
+            }
+        )
+    )
+
+    interface EventsResponse {
+        events: pgModels.LsifUploadEvent[]
+    }
+
+    router.get(
+        '/uploads/:id([0-9]+)/events',
+        wrap(
+            async (req: express.Request, res: express.Response<EventsResponse>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+
+                // Events outlive the upload they describe, so a purged upload still has a history
+                const events = await uploadManager.getEvents(id)
+                if (events.length > 0 || (await uploadManager.getUpload(id))) {
+                    res.send({ events })
                     return
                 }
 
                 throw Object.assign(new Error('Upload not found'), {
                     status: 404,
+                    code: 'upload_not_found',
+                })
+            }
+        )
+    )
+
+    type OverrideResponse = pgModels.LsifDump
+
+    /**
+     * Create a handler that sets a visibility override flag on a dump and then
+     * recalculates the dumps visible from the tip of the dump's repository.
+     *
+     * @param name The name of the override for logging.
+     * @param set The function that updates the override flag.
+     */
+    const createOverrideHandler = (
+        name: string,
+        set: (id: number, entityManager: EntityManager) => Promise<pgModels.LsifDump | undefined>
+    ) =>
+        wrap(
+            async (req: express.Request, res: express.Response<OverrideResponse>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const ctx = createTracingContext(req, { id })
+
+                const dump = await connection.transaction(async entityManager => {
+                    const dump = await set(id, entityManager)
+                    if (dump) {
+                        await updateCommitsAndDumpsVisibleFromTip({
+                            entityManager,
+                            dumpManager,
+                            frontendUrl: SRC_FRONTEND_INTERNAL,
+                            repositoryId: dump.repositoryId,
+                            ctx,
+                        })
+                    }
+
+                    return dump
+                })
+
+                if (dump) {
+                    resultCache.invalidateRepository(dump.repositoryId)
+                    logger.info('Updated dump visibility override', { id, override: name })
+                    res.send(dump)
+                    return
+                }
+
+                throw Object.assign(new Error('Dump not found'), {
+                    status: 404,
+                    code: 'dump_not_found',
+                })
+            }
+        )
+
+    router.post(
+        '/uploads/:id([0-9]+)/pin',
+        requireToken(),
+        createOverrideHandler('pin', (id, entityManager) => dumpManager.setPinned(id, true, entityManager))
+    )
+
+    router.delete(
+        '/uploads/:id([0-9]+)/pin',
+        requireToken(),
+        createOverrideHandler('unpin', (id, entityManager) => dumpManager.setPinned(id, false, entityManager))
+    )
+
+    router.post(
+        '/uploads/:id([0-9]+)/exclude',
+        requireToken(),
+        createOverrideHandler('exclude', (id, entityManager) => dumpManager.setExcluded(id, true, entityManager))
+    )
+
+    router.delete(
+        '/uploads/:id([0-9]+)/exclude',
+        requireToken(),
+        createOverrideHandler('include', (id, entityManager) => dumpManager.setExcluded(id, false, entityManager))
+    )
+
+    interface StatisticsResponse {
+        numDocuments: number
+        numRanges: number
+        numFilesInRoot: number | null
+        documentsByLanguage: { [language: string]: number }
+        coverage: number | null
+    }
+
+    router.get(
+        '/uploads/:id([0-9]+)/statistics',
+        wrap(
+            async (req: express.Request, res: express.Response<StatisticsResponse>): Promise<void> => {
+                const statistics = await dumpManager.getStatistics(parseInt(req.params.id, 10))
+                if (statistics) {
+                    const { numDocuments, numRanges, numFilesInRoot, documentsByLanguage } = statistics
+                    const coverage = numFilesInRoot ? Math.min(numDocuments / numFilesInRoot, 1) : null
+                    res.send({ numDocuments, numRanges, numFilesInRoot, documentsByLanguage, coverage })
+                    return
+                }
+
+                throw Object.assign(new Error('Statistics not found'), {
+                    status: 404,
+                    code: 'statistics_not_found',
                 })
             }
         )
@@ -95,7 +507,7 @@ export function createUploadRouter(
 
     interface UploadsResponse {
         uploads: LsifUploadWithPlaceInQueue[]
-        totalCount: number
+        totalCount?: number
     }
 
     router.get(
@@ -106,13 +518,39 @@ export function createUploadRouter(
             validation.validateOptionalBoolean('visibleAtTip'),
             validation.validateLimit,
             validation.validateOffset,
+            validation.validateOptionalEnum('pagination', ['offset', 'cursor']),
+            validation.validateCursor<UploadCursor>(),
         ]),
         wrap(
             async (req: express.Request, res: express.Response<UploadsResponse>): Promise<void> => {
-                const { query, state, visibleAtTip }: UploadsQueryArgs = req.query
+                const { query, state, visibleAtTip, pagination, cursor }: UploadsQueryArgs = req.query
                 const { limit, offset } = extractLimitOffset(req.query, settings.DEFAULT_UPLOAD_PAGE_SIZE)
+                const repositoryId = parseInt(req.params.id, 10)
+
+                if (pagination === 'cursor' || cursor) {
+                    // Cursor pagination does not need to skip over (or count) the uploads of
+                    // previous pages, which is expensive for repositories with many uploads
+                    const { uploads, nextCursor } = await uploadManager.getAllUploads(
+                        { repositoryId, state, query, visibleAtTip },
+                        limit,
+                        cursor
+                    )
+
+                    const links: { [rel: string]: LinkParams } = {
+                        first: { limit, pagination: 'cursor', cursor: null, offset: null },
+                    }
+                    const encodedCursor = encodeCursor<UploadCursor>(nextCursor)
+                    if (encodedCursor) {
+                        links.next = { limit, pagination: 'cursor', cursor: encodedCursor, offset: null }
+                    }
+
+                    res.set('Link', formatLinks(req, links))
+                    res.json({ uploads })
+                    return
+                }
+
                 const { uploads, totalCount } = await uploadManager.getUploads(
-                    parseInt(req.params.id, 10),
+                    repositoryId,
                     state,
                     query,
                     !!visibleAtTip,
@@ -120,10 +558,7 @@ export function createUploadRouter(
                     offset
                 )
 
-                if (offset + uploads.length < totalCount) {
-                    res.set('Link', nextLink(req, { limit, offset: offset + uploads.length }))
-                }
-
+                res.set('Link', offsetLinks(req, { limit, offset, totalCount }))
                 res.json({ uploads, totalCount })
             }
         )
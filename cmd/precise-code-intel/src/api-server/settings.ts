@@ -1,4 +1,7 @@
-import { readEnvInt } from '../shared/settings'
+import { readEnvBool, readEnvDuration, readEnvInt } from '../shared/settings'
+import { UploadMaxAges } from '../shared/store/uploads'
+import { PrunePolicy } from '../shared/store/dumps'
+import { parseShardUrls } from '../shared/shards'
 
 /** Which port to run the LSIF API server on. Defaults to 3186. */
 export const HTTP_PORT = readEnvInt('HTTP_PORT', 3186)
@@ -7,29 +10,216 @@ export const HTTP_PORT = readEnvInt('HTTP_PORT', 3186)
 export const PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL =
     process.env.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL || 'http://localhost:3187'
 
+/**
+ * HTTP addresses for internal LSIF bundle manager servers, as a comma-separated list.
+ * Bundles are sharded across these servers by dump identifier. Defaults to the single
+ * server given by PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL.
+ */
+export const PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS = parseShardUrls(
+    process.env.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS || PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL
+)
+
+/** The maximum time (in seconds) to wait for a response to a query sent to a bundle manager. */
+export const BUNDLE_MANAGER_REQUEST_TIMEOUT = readEnvInt('BUNDLE_MANAGER_REQUEST_TIMEOUT', 30)
+
+/**
+ * How many times to retry a GET request to a bundle manager that fails with a network error or
+ * a 5xx response. Other requests are not retried as they may not be idempotent.
+ */
+export const BUNDLE_MANAGER_MAX_RETRIES = readEnvInt('BUNDLE_MANAGER_MAX_RETRIES', 2)
+
+/** How long to wait (in milliseconds) before the first retry. The delay doubles on each subsequent retry. */
+export const BUNDLE_MANAGER_RETRY_BASE_DELAY_MS = readEnvInt('BUNDLE_MANAGER_RETRY_BASE_DELAY_MS', 100)
+
+/** The maximum time (in milliseconds) to wait between retries. */
+export const BUNDLE_MANAGER_RETRY_MAX_DELAY_MS = readEnvInt('BUNDLE_MANAGER_RETRY_MAX_DELAY_MS', 2000)
+
+/**
+ * The number of consecutive failed requests to a bundle manager after which requests to it are
+ * rejected without being sent (<= 0 disables circuit breaking).
+ */
+export const BUNDLE_MANAGER_CIRCUIT_BREAKER_THRESHOLD = readEnvInt('BUNDLE_MANAGER_CIRCUIT_BREAKER_THRESHOLD', 5)
+
+/** The time (in seconds) to reject requests to a failing bundle manager before trying it again. */
+export const BUNDLE_MANAGER_CIRCUIT_BREAKER_COOLDOWN = readEnvInt('BUNDLE_MANAGER_CIRCUIT_BREAKER_COOLDOWN', 10)
+
+/** The time (in seconds) after which the capabilities of a bundle manager are queried again. */
+export const BUNDLE_MANAGER_CAPABILITIES_TTL = readEnvInt('BUNDLE_MANAGER_CAPABILITIES_TTL', 60)
+
+/**
+ * The maximum time (in seconds) to spend on a code intelligence query before responding with
+ * a 503 and abandoning the work performed on behalf of the request (<= 0 means no limit).
+ */
+export const QUERY_REQUEST_TIMEOUT = readEnvInt('QUERY_REQUEST_TIMEOUT', 60)
+
 /** Where on the file system to temporarily store LSIF uploads. This need not be a persistent volume. */
 export const STORAGE_ROOT = process.env.LSIF_STORAGE_ROOT || 'lsif-storage'
 
+/** The maximum size (in bytes) of a compressed LSIF upload. */
+export const MAX_UPLOAD_SIZE_BYTES = readEnvInt('MAX_UPLOAD_SIZE_BYTES', 2 * 1024 * 1024 * 1024) // 2GiB
+
+/** The maximum size (in bytes) of the decompressed prefix of an upload that may hold its metadata. */
+export const MAX_METADATA_SIZE_BYTES = readEnvInt('MAX_METADATA_SIZE_BYTES', 1024 * 1024) // 1MiB
+
+/** The size (in bytes) of the chunks in which uploads are sent to the bundle manager. */
+export const UPLOAD_CHUNK_SIZE_BYTES = readEnvInt('UPLOAD_CHUNK_SIZE_BYTES', 1024 * 1024 * 64) // 64MiB
+
+/** How many times to retry sending a single upload chunk to the bundle manager. */
+export const MAX_CHUNK_UPLOAD_RETRIES = readEnvInt('MAX_CHUNK_UPLOAD_RETRIES', 5)
+
+/** The maximum number of remote dumps queried concurrently while resolving a cross-dump query. */
+export const MAX_CONCURRENT_REMOTE_DUMP_REQUESTS = readEnvInt('MAX_CONCURRENT_REMOTE_DUMP_REQUESTS', 5)
+
+/**
+ * The maximum number of positions of a batched hover request whose hover content is resolved
+ * through their definitions concurrently.
+ */
+export const MAX_CONCURRENT_HOVER_FALLBACKS = readEnvInt('MAX_CONCURRENT_HOVER_FALLBACKS', 5)
+
+/**
+ * The maximum number of queued uploads of a single repository. Uploads for a repository at this
+ * limit are rejected until some of its queued uploads are converted (<= 0 means no limit).
+ */
+export const MAX_QUEUED_UPLOADS_PER_REPOSITORY = readEnvInt('MAX_QUEUED_UPLOADS_PER_REPOSITORY', 100)
+
+/** The maximum number of queued uploads over all repositories (<= 0 means no limit). */
+export const MAX_QUEUED_UPLOADS = readEnvInt('MAX_QUEUED_UPLOADS', 10000)
+
+/** The time (in seconds) that clients rejected by an enqueue quota are asked to wait before retrying. */
+export const QUEUE_QUOTA_RETRY_AFTER = readEnvInt('QUEUE_QUOTA_RETRY_AFTER', 60)
+
 /** The default number of results to return from the upload endpoints. */
 export const DEFAULT_UPLOAD_PAGE_SIZE = readEnvInt('DEFAULT_UPLOAD_PAGE_SIZE', 50)
 
+/**
+ * The interval (in seconds) after which the upload watch and progress streams read an upload again
+ * without having received a notification, in case a notification was lost.
+ */
+export const UPLOAD_WATCH_RECHECK_INTERVAL = readEnvInt('UPLOAD_WATCH_RECHECK_INTERVAL', 30)
+
+/**
+ * The maximum number of upload watch and progress streams open at once. Further streams are rejected
+ * until one is closed (<= 0 means no limit).
+ */
+export const MAX_UPLOAD_STREAMS = readEnvInt('MAX_UPLOAD_STREAMS', 1000)
+
 /** The default number of results to return from the dumps endpoint. */
 export const DEFAULT_DUMP_PAGE_SIZE = readEnvInt('DEFAULT_DUMP_PAGE_SIZE', 50)
 
+/** The number of days of history in which changed files are checked by the coverage endpoint. */
+export const COVERAGE_WINDOW_DAYS = readEnvInt('COVERAGE_WINDOW_DAYS', 30)
+
+/** The maximum number of recently changed files checked by the coverage endpoint, most recent first. */
+export const MAX_COVERAGE_FILES = readEnvInt('MAX_COVERAGE_FILES', 1000)
+
 /** The default number of location results to return when performing a find-references operation. */
 export const DEFAULT_REFERENCES_PAGE_SIZE = readEnvInt('DEFAULT_REFERENCES_PAGE_SIZE', 100)
 
+/** The default number of results to return from the diagnostics endpoint. */
+export const DEFAULT_DIAGNOSTICS_PAGE_SIZE = readEnvInt('DEFAULT_DIAGNOSTICS_PAGE_SIZE', 100)
+
+/** The maximum number of definition and hover results cached in memory (<= 0 disables the cache). */
+export const QUERY_RESULT_CACHE_CAPACITY = readEnvInt('QUERY_RESULT_CACHE_CAPACITY', 10000)
+
+/**
+ * The number of seconds after which a cached definition or hover result is computed again. Results
+ * are invalidated when this process changes the dumps of a repository, so this only bounds how long
+ * stale results can be served after a change made by another process (such as a new conversion).
+ */
+export const QUERY_RESULT_CACHE_TTL = readEnvInt('QUERY_RESULT_CACHE_TTL', 60)
+
+/** The maximum size (in bytes) of the hover text returned for a single position (< 0 means no limit). */
+export const MAX_HOVER_TEXT_SIZE_BYTES = readEnvInt('MAX_HOVER_TEXT_SIZE_BYTES', 64 * 1024) // 64KiB
+
+/**
+ * The time (in seconds) over which finished conversions are counted to estimate the throughput
+ * of the workers, from which the queue introspection endpoint estimates wait times.
+ */
+export const QUEUE_THROUGHPUT_WINDOW = readEnvInt('QUEUE_THROUGHPUT_WINDOW', 60 * 60) // 1 hour
+
 /** The interval (in seconds) to invoke the updateQueueSizeGaugeInterval task. */
 export const UPDATE_QUEUE_SIZE_GAUGE_INTERVAL = readEnvInt('UPDATE_QUEUE_SIZE_GAUGE_INTERVAL', 5)
 
 /** The interval (in seconds) to run the resetStalledUploads task. */
 export const RESET_STALLED_UPLOADS_INTERVAL = readEnvInt('RESET_STALLED_UPLOADS_INTERVAL', 60)
 
-/** The maximum age (in seconds) that an upload can be unlocked and in the `processing` state. */
-export const STALLED_UPLOAD_MAX_AGE = readEnvInt('STALLED_UPLOAD_MAX_AGE', 5)
+/**
+ * The maximum age (in seconds, or a duration such as `2m`) of the last heartbeat of an upload in
+ * the `processing` state. An unlocked upload whose heartbeat is older than this is moved back into
+ * the `queued` state. Workers record a heartbeat every HEARTBEAT_INTERVAL (5 seconds by default),
+ * so this must span several heartbeats to tolerate a slow database or a busy worker. It must be at
+ * least MIN_STALLED_UPLOAD_MAX_AGE. The time a conversion may take overall is only bounded by the
+ * processing entry of UPLOAD_MAX_AGES, after which the upload is removed.
+ */
+export const STALLED_UPLOAD_MAX_AGE = readEnvDuration('STALLED_UPLOAD_MAX_AGE', 60)
+
+/** The smallest accepted value of STALLED_UPLOAD_MAX_AGE (in seconds). */
+export const MIN_STALLED_UPLOAD_MAX_AGE = 15
+
+/** The interval (in seconds) to run the refreshVisibleDumps task. */
+export const REFRESH_VISIBLE_DUMPS_INTERVAL = readEnvInt('REFRESH_VISIBLE_DUMPS_INTERVAL', 60 * 10) // 10 minutes
+
+/** The interval (in seconds) to run the refreshNearestUploads task. */
+export const REFRESH_NEAREST_UPLOADS_INTERVAL = readEnvInt('REFRESH_NEAREST_UPLOADS_INTERVAL', 10)
+
+/** The maximum number of repositories whose nearest uploads are refreshed in one run. */
+export const NEAREST_UPLOADS_REFRESH_BATCH_SIZE = readEnvInt('NEAREST_UPLOADS_REFRESH_BATCH_SIZE', 10)
+
+/**
+ * The maximum number of times an errored upload can be retried. Retrying an upload beyond
+ * this limit moves it into the terminal failed state.
+ */
+export const MAX_UPLOAD_ATTEMPTS = readEnvInt('MAX_UPLOAD_ATTEMPTS', 3)
 
 /** The interval (in seconds) to invoke the cleanOldUploads task. */
 export const CLEAN_OLD_UPLOADS_INTERVAL = readEnvInt('CLEAN_OLD_UPLOADS_INTERVAL', 60 * 60 * 8) // 8 hours
 
-/** The maximum age (in seconds) that an upload (completed or queued) will remain in Postgres. */
+/** The default maximum age (in seconds) that an unconverted upload will remain in Postgres. */
 export const UPLOAD_MAX_AGE = readEnvInt('UPLOAD_UPLOAD_AGE', 60 * 60 * 24 * 7) // 1 week
+
+/**
+ * The maximum age (in seconds) of uploads in each state before they are removed by the
+ * cleanOldUploads task. A negative value disables removal for that state. Failed uploads
+ * are retained for inspection by default. Completed uploads are only removed by pruning.
+ */
+export const UPLOAD_MAX_AGES: UploadMaxAges = {
+    queued: readEnvInt('JANITOR_QUEUED_UPLOAD_MAX_AGE', UPLOAD_MAX_AGE),
+    processing: readEnvInt('JANITOR_PROCESSING_UPLOAD_MAX_AGE', UPLOAD_MAX_AGE),
+    errored: readEnvInt('JANITOR_ERRORED_UPLOAD_MAX_AGE', UPLOAD_MAX_AGE),
+    failed: readEnvInt('JANITOR_FAILED_UPLOAD_MAX_AGE', -1),
+}
+
+/**
+ * The policy that determines which dumps may be pruned, either to reduce the disk usage of the
+ * bundle manager or to enforce MAX_DUMPS_PER_REPOSITORY. By default, only dumps visible from
+ * the tip of the default branch are protected. Set JANITOR_PROTECT_DUMPS_NEWER_THAN to a number
+ * of seconds to also protect recently uploaded dumps.
+ */
+export const PRUNE_POLICY: PrunePolicy = {
+    protectVisibleAtTip: readEnvBool('JANITOR_PROTECT_VISIBLE_AT_TIP', true),
+    protectNewerThan: readEnvInt('JANITOR_PROTECT_DUMPS_NEWER_THAN', -1),
+}
+
+/** The interval (in seconds) to invoke the pruneExcessDumps task. */
+export const PRUNE_EXCESS_DUMPS_INTERVAL = readEnvInt('PRUNE_EXCESS_DUMPS_INTERVAL', 60 * 60) // 1 hour
+
+/** The maximum number of dumps retained per repository (< 0 means no limit). */
+export const MAX_DUMPS_PER_REPOSITORY = readEnvInt('JANITOR_MAX_DUMPS_PER_REPOSITORY', -1)
+
+/** The number of seconds after deletion that an upload can be restored. */
+export const UPLOAD_RESTORE_WINDOW = readEnvInt('JANITOR_UPLOAD_RESTORE_WINDOW', 60 * 60 * 24) // 1 day
+
+/** The interval (in seconds) to invoke the purgeDeletedUploads task. */
+export const PURGE_DELETED_UPLOADS_INTERVAL = readEnvInt('PURGE_DELETED_UPLOADS_INTERVAL', 60 * 60) // 1 hour
+
+/** The maximum age (in seconds) of upload lifecycle events (< 0 means events are kept forever). */
+export const UPLOAD_EVENT_MAX_AGE = readEnvInt('JANITOR_UPLOAD_EVENT_MAX_AGE', 60 * 60 * 24 * 90) // 90 days
+
+/** The interval (in seconds) to invoke the purgeOldUploadEvents task. */
+export const PURGE_UPLOAD_EVENTS_INTERVAL = readEnvInt('PURGE_UPLOAD_EVENTS_INTERVAL', 60 * 60) // 1 hour
+
+/** The maximum number of code intelligence query events retained in memory for export. */
+export const QUERY_EVENT_LOG_SIZE = readEnvInt('QUERY_EVENT_LOG_SIZE', 10000)
+
+/** The maximum time (in seconds) to wait for in-flight requests and tasks to complete on shutdown. */
+export const SHUTDOWN_TIMEOUT = readEnvInt('SHUTDOWN_TIMEOUT', 30)
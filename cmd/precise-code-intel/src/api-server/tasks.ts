@@ -1,20 +1,38 @@
 import * as settings from './settings'
-import { Connection } from 'typeorm'
+import { Connection, EntityManager } from 'typeorm'
 import { Logger } from 'winston'
-import { UploadManager } from '../shared/store/uploads'
+import { JANITOR_ORIGIN, UploadManager } from '../shared/store/uploads'
+import { DumpManager } from '../shared/store/dumps'
 import { ExclusivePeriodicTaskRunner } from '../shared/tasks'
 import * as metrics from './metrics'
 import { createSilentLogger } from '../shared/logging'
 import { TracingContext } from '../shared/tracing'
+import { JANITOR_DRY_RUN, SRC_FRONTEND_INTERNAL, USE_NEAREST_UPLOADS } from '../shared/config/settings'
+import { updateCommitsAndDumpsVisibleFromTip } from '../shared/visibility'
+import { QueryResultCache } from './backend/cache'
 
 /**
- * Begin running cleanup tasks on a schedule in the background.
+ * Begin running cleanup tasks on a schedule in the background. Returns the task runner
+ * so that the tasks can be stopped on shutdown. Throws if the janitor is misconfigured.
  *
  * @param connection The Postgres connection.
+ * @param dumpManager The dumps manager instance.
  * @param uploadManager The uploads manager instance.
+ * @param resultCache The cache of query results to invalidate when the dumps of a repository change.
  * @param logger The logger instance.
  */
-export function startTasks(connection: Connection, uploadManager: UploadManager, logger: Logger): void {
+export function startTasks(
+    connection: Connection,
+    dumpManager: DumpManager,
+    uploadManager: UploadManager,
+    resultCache: QueryResultCache,
+    logger: Logger
+): ExclusivePeriodicTaskRunner {
+    // A shorter maximum age would requeue the uploads of live workers that are slow to record a heartbeat
+    if (settings.STALLED_UPLOAD_MAX_AGE < settings.MIN_STALLED_UPLOAD_MAX_AGE) {
+        throw new Error(`STALLED_UPLOAD_MAX_AGE must be at least ${settings.MIN_STALLED_UPLOAD_MAX_AGE} seconds`)
+    }
+
     const runner = new ExclusivePeriodicTaskRunner(connection, logger)
 
     runner.register({
@@ -36,7 +54,38 @@ export function startTasks(connection: Connection, uploadManager: UploadManager,
         task: ({ ctx }) => cleanOldUploads(uploadManager, ctx),
     })
 
+    runner.register({
+        name: 'Purging deleted uploads',
+        intervalMs: settings.PURGE_DELETED_UPLOADS_INTERVAL,
+        task: ({ ctx }) => purgeDeletedUploads(uploadManager, ctx),
+    })
+
+    runner.register({
+        name: 'Purging old upload events',
+        intervalMs: settings.PURGE_UPLOAD_EVENTS_INTERVAL,
+        task: ({ ctx }) => purgeOldUploadEvents(uploadManager, ctx),
+    })
+
+    runner.register({
+        name: 'Pruning excess dumps',
+        intervalMs: settings.PRUNE_EXCESS_DUMPS_INTERVAL,
+        task: ({ ctx }) => pruneExcessDumps(dumpManager, uploadManager, resultCache, ctx),
+    })
+
+    runner.register({
+        name: 'Refreshing dumps visible from tip',
+        intervalMs: settings.REFRESH_VISIBLE_DUMPS_INTERVAL,
+        task: ({ ctx }) => refreshVisibleDumps(connection, dumpManager, ctx),
+    })
+
+    runner.register({
+        name: 'Refreshing nearest uploads',
+        intervalMs: settings.REFRESH_NEAREST_UPLOADS_INTERVAL,
+        task: ({ ctx }) => refreshNearestUploads(dumpManager, ctx),
+    })
+
     runner.run()
+    return runner
 }
 
 /**
@@ -49,8 +98,9 @@ async function updateQueueSizeGauge(uploadManager: UploadManager): Promise<void>
 }
 
 /**
- * Move all unlocked uploads that have been in `processing` state for longer than
- * `STALLED_UPLOAD_MAX_AGE` back to the `queued` state.
+ * Move all unlocked uploads in the `processing` state whose worker has not recorded a
+ * heartbeat within `STALLED_UPLOAD_MAX_AGE` back to the `queued` state. Each reset upload is
+ * logged as a warning, followed by a summary of the run.
  *
  * @param uploadManager The uploads manager instance.
  * @param ctx The tracing context.
@@ -59,13 +109,24 @@ async function resetStalledUploads(
     uploadManager: UploadManager,
     { logger = createSilentLogger() }: TracingContext
 ): Promise<void> {
-    for (const id of await uploadManager.resetStalled(settings.STALLED_UPLOAD_MAX_AGE)) {
-        logger.debug('Reset stalled upload conversion', { id })
+    const start = Date.now()
+    const uploads = await uploadManager.resetStalled(settings.STALLED_UPLOAD_MAX_AGE)
+    for (const { id, repositoryId, age } of uploads) {
+        logger.warn('Reset stalled upload conversion', { id, repository: repositoryId, ageSeconds: Math.round(age) })
     }
+
+    // Log a summary of every run so that a burst of resets stands out in aggregated logs
+    logger.info('Reset stalled uploads', {
+        count: uploads.length,
+        repositories: new Set(uploads.map(({ repositoryId }) => repositoryId)).size,
+        maxAgeSeconds: settings.STALLED_UPLOAD_MAX_AGE,
+        durationMs: Date.now() - start,
+    })
 }
 
 /**
- * Remove all upload data older than `UPLOAD_MAX_AGE`.
+ * Remove all upload data older than the maximum age of its state (see `UPLOAD_MAX_AGES`).
+ * In dry-run mode, the uploads that would be removed are logged instead.
  *
  * @param uploadManager The uploads manager instance.
  * @param ctx The tracing context.
@@ -74,8 +135,156 @@ async function cleanOldUploads(
     uploadManager: UploadManager,
     { logger = createSilentLogger() }: TracingContext
 ): Promise<void> {
-    const count = await uploadManager.clean(settings.UPLOAD_MAX_AGE)
+    const ids = await uploadManager.clean(settings.UPLOAD_MAX_AGES, JANITOR_DRY_RUN)
+    if (ids.length > 0) {
+        logger.debug(JANITOR_DRY_RUN ? 'Would clean old uploads' : 'Cleaned old uploads', { ids })
+    }
+    if (!JANITOR_DRY_RUN) {
+        metrics.janitorUploadsRemovedCounter.labels('expired').inc(ids.length)
+    }
+}
+
+/**
+ * Remove all uploads deleted longer than `UPLOAD_RESTORE_WINDOW` ago. The bundle manager removes
+ * the files of these uploads once their records are gone. In dry-run mode, the uploads that would
+ * be removed are logged instead.
+ *
+ * @param uploadManager The uploads manager instance.
+ * @param ctx The tracing context.
+ */
+async function purgeDeletedUploads(
+    uploadManager: UploadManager,
+    { logger = createSilentLogger() }: TracingContext
+): Promise<void> {
+    const ids = await uploadManager.purgeDeleted(settings.UPLOAD_RESTORE_WINDOW, JANITOR_DRY_RUN)
+    if (ids.length > 0) {
+        logger.debug(JANITOR_DRY_RUN ? 'Would purge deleted uploads' : 'Purged deleted uploads', { ids })
+    }
+    if (!JANITOR_DRY_RUN) {
+        metrics.janitorUploadsRemovedCounter.labels('deleted').inc(ids.length)
+    }
+}
+
+/**
+ * Remove all upload lifecycle events older than `UPLOAD_EVENT_MAX_AGE`. In dry-run mode, the
+ * number of events that would be removed is logged instead.
+ *
+ * @param uploadManager The uploads manager instance.
+ * @param ctx The tracing context.
+ */
+async function purgeOldUploadEvents(
+    uploadManager: UploadManager,
+    { logger = createSilentLogger() }: TracingContext
+): Promise<void> {
+    if (settings.UPLOAD_EVENT_MAX_AGE < 0) {
+        return
+    }
+
+    const count = await uploadManager.purgeEvents(settings.UPLOAD_EVENT_MAX_AGE, JANITOR_DRY_RUN)
     if (count > 0) {
-        logger.debug('Cleaned old uploads', { count })
+        logger.debug(JANITOR_DRY_RUN ? 'Would purge old upload events' : 'Purged old upload events', { count })
+    }
+    if (!JANITOR_DRY_RUN) {
+        metrics.janitorUploadEventsRemovedCounter.inc(count)
+    }
+}
+
+/**
+ * Remove the oldest dumps of each repository with more than `MAX_DUMPS_PER_REPOSITORY` dumps.
+ * Dumps protected by the prune policy are not removed. In dry-run mode, the dumps that would
+ * be removed are logged instead.
+ *
+ * @param dumpManager The dumps manager instance.
+ * @param uploadManager The uploads manager instance.
+ * @param resultCache The cache of query results to invalidate.
+ * @param ctx The tracing context.
+ */
+async function pruneExcessDumps(
+    dumpManager: DumpManager,
+    uploadManager: UploadManager,
+    resultCache: QueryResultCache,
+    { logger = createSilentLogger() }: TracingContext
+): Promise<void> {
+    if (settings.MAX_DUMPS_PER_REPOSITORY < 0) {
+        return
+    }
+
+    for (const dump of await dumpManager.getExcessDumps(settings.MAX_DUMPS_PER_REPOSITORY, settings.PRUNE_POLICY)) {
+        const fields = { id: dump.id, repository: dump.repositoryId, commit: dump.commit, root: dump.root }
+        if (JANITOR_DRY_RUN) {
+            logger.info('Would prune excess dump', fields)
+            continue
+        }
+
+        logger.info('Pruning excess dump', fields)
+
+        // This delete cascades to the packages and references tables as well
+        const deleted = await uploadManager.deleteUpload(
+            dump.id,
+            (entityManager: EntityManager, repositoryId: number): Promise<void> =>
+                updateCommitsAndDumpsVisibleFromTip({
+                    entityManager,
+                    dumpManager,
+                    frontendUrl: SRC_FRONTEND_INTERNAL,
+                    repositoryId,
+                    ctx: { logger },
+                }),
+            JANITOR_ORIGIN,
+            'Exceeded the maximum number of dumps per repository'
+        )
+        if (deleted) {
+            resultCache.invalidateRepository(dump.repositoryId)
+            metrics.janitorUploadsRemovedCounter.labels('excess').inc()
+        }
+    }
+}
+
+/**
+ * Recalculate the dumps visible from the tip of the default branch of every repository
+ * with LSIF data. This catches changes to the tip that happen without a new upload. A
+ * failure to refresh one repository does not prevent the others from being refreshed.
+ *
+ * @param connection The Postgres connection.
+ * @param dumpManager The dumps manager instance.
+ * @param ctx The tracing context.
+ */
+async function refreshVisibleDumps(
+    connection: Connection,
+    dumpManager: DumpManager,
+    { logger = createSilentLogger() }: TracingContext
+): Promise<void> {
+    for (const repositoryId of await dumpManager.getRepositoryIds()) {
+        try {
+            await connection.transaction(entityManager =>
+                updateCommitsAndDumpsVisibleFromTip({
+                    entityManager,
+                    dumpManager,
+                    frontendUrl: SRC_FRONTEND_INTERNAL,
+                    repositoryId,
+                    ctx: { logger },
+                })
+            )
+        } catch (error) {
+            logger.error('Failed to refresh dumps visible from tip', { repositoryId, error })
+        }
+    }
+}
+
+/**
+ * Rebuild the nearest uploads of repositories whose commits or dumps changed since their last
+ * refresh, at most `NEAREST_UPLOADS_REFRESH_BATCH_SIZE` repositories per run.
+ *
+ * @param dumpManager The dumps manager instance.
+ * @param ctx The tracing context.
+ */
+async function refreshNearestUploads(dumpManager: DumpManager, ctx: TracingContext): Promise<void> {
+    if (!USE_NEAREST_UPLOADS) {
+        return
+    }
+
+    const { logger = createSilentLogger() } = ctx
+    const repositoryIds = await dumpManager.refreshNearestUploads(settings.NEAREST_UPLOADS_REFRESH_BATCH_SIZE, ctx)
+    if (repositoryIds.length > 0) {
+        logger.debug('Refreshed nearest uploads', { repositoryIds })
     }
 }
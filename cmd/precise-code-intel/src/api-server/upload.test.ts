@@ -0,0 +1,188 @@
+import * as crypto from 'crypto'
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import * as zlib from 'mz/zlib'
+import rmfr from 'rmfr'
+import { checkContentEncoding, parseMetadata, receiveUpload } from './upload'
+import { encodeProtobufField as field } from '../shared/test-util'
+import { Readable } from 'stream'
+
+describe('receiveUpload', () => {
+    let tempPath!: string
+
+    beforeAll(async () => {
+        tempPath = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterAll(async () => {
+        await rmfr(tempPath)
+    })
+
+    const lines = [
+        {
+            type: 'vertex',
+            label: 'metaData',
+            version: '0.4.3',
+            positionEncoding: 'utf-16',
+            projectRoot: 'file:///',
+            toolInfo: { name: 'lsif-tsc' },
+        },
+        { type: 'vertex', label: 'project' },
+        { type: 'vertex', label: 'document' },
+        { type: 'edge', label: 'item' },
+    ]
+
+    const payload = (): Promise<Buffer> => zlib.gzip(lines.map(l => JSON.stringify(l)).join('\n'))
+
+    it('should write the payload and find the indexer', async () => {
+        const filename = path.join(tempPath, 'upload')
+        const contents = await payload()
+
+        const checksum = crypto.createHash('sha256').update(contents).digest('hex')
+
+        expect(await receiveUpload(Readable.from([contents]), filename, contents.length, checksum)).toEqual({
+            metadata: {
+                format: 'lsif',
+                indexer: 'lsif-tsc',
+                lsifVersion: '0.4.3',
+                positionEncoding: 'utf-16',
+                projectRoot: 'file:///',
+            },
+            checksum,
+        })
+        expect(await fs.readFile(filename)).toEqual(contents)
+    })
+
+    it('should reject payloads without a metadata vertex', async () => {
+        const contents = await zlib.gzip(lines.slice(1).map(l => JSON.stringify(l)).join('\n'))
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'no-metadata'), 1024)
+        ).rejects.toMatchObject({ status: 422, code: 'invalid_lsif' })
+    })
+
+    it('should detect SCIP indexes', async () => {
+        const index = Buffer.concat([
+            field(1, [field(2, [field(1, 'scip-go')]), field(3, 'file:///src')]),
+            field(2, [field(1, 'main.go')]),
+        ])
+        const contents = await zlib.gzip(index)
+
+        const { metadata } = await receiveUpload(Readable.from([contents]), path.join(tempPath, 'scip'), 1024)
+        expect(metadata).toEqual({ format: 'scip', indexer: 'scip-go', projectRoot: 'file:///src' })
+    })
+
+    it('should reject SCIP indexes that do not start with metadata', async () => {
+        const index = Buffer.concat([field(2, [field(1, 'main.go')]), field(1, [field(3, 'file:///src')])])
+        const contents = await zlib.gzip(index)
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'scip-no-metadata'), 1024)
+        ).rejects.toMatchObject({ status: 422, code: 'invalid_lsif' })
+    })
+
+    it('should reject payloads whose metadata is too large', async () => {
+        // A first line that does not end within the metadata size limit
+        const contents = await zlib.gzip(`{"type": "vertex", "label": "${'a'.repeat(2 * 1024 * 1024)}"}`)
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'long-line'), contents.length)
+        ).rejects.toMatchObject({ status: 422, code: 'invalid_lsif' })
+    })
+
+    it('should reject payloads that are not gzipped', async () => {
+        const contents = Buffer.from(lines.map(l => JSON.stringify(l)).join('\n'))
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'plain'), 1024)
+        ).rejects.toMatchObject({ status: 400, code: 'malformed_upload' })
+    })
+
+    it('should reject zstd payloads as unsupported', async () => {
+        // A zstd frame header followed by arbitrary bytes
+        const contents = Buffer.concat([Buffer.from([0x28, 0xb5, 0x2f, 0xfd]), Buffer.from('compressed')])
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'zstd'), 1024)
+        ).rejects.toMatchObject({ status: 415, code: 'unsupported_encoding' })
+    })
+
+    it('should reject truncated payloads', async () => {
+        const contents = (await payload()).slice(0, -10)
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'truncated'), 1024)
+        ).rejects.toMatchObject({ status: 400, code: 'malformed_upload' })
+    })
+
+    it('should reject payloads that do not match the checksum', async () => {
+        const contents = await payload()
+        const checksum = crypto.createHash('sha256').update('other').digest('hex')
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'mismatch'), 1024, checksum)
+        ).rejects.toMatchObject({ status: 400, code: 'checksum_mismatch' })
+    })
+
+    it('should reject payloads that are too large', async () => {
+        const contents = await payload()
+
+        await expect(
+            receiveUpload(Readable.from([contents]), path.join(tempPath, 'large'), contents.length - 1)
+        ).rejects.toMatchObject({ status: 413, code: 'upload_too_large' })
+    })
+})
+
+describe('checkContentEncoding', () => {
+    it('should accept gzip and missing encodings', () => {
+        expect(() => checkContentEncoding(undefined)).not.toThrow()
+        expect(() => checkContentEncoding('gzip')).not.toThrow()
+        expect(() => checkContentEncoding(' GZIP ')).not.toThrow()
+    })
+
+    it('should reject other encodings', () => {
+        expect(() => checkContentEncoding('zstd')).toThrow('Unsupported LSIF upload encoding zstd')
+        expect(() => checkContentEncoding('br')).toThrow('Unsupported LSIF upload encoding br')
+    })
+})
+
+describe('parseMetadata', () => {
+    const metaData = {
+        type: 'vertex',
+        label: 'metaData',
+        version: '0.4.3',
+        positionEncoding: 'utf-16',
+        projectRoot: 'file:///src',
+        toolInfo: { name: 'lsif-go' },
+    }
+
+    it('should extract metadata', () => {
+        expect(parseMetadata(metaData)).toEqual({
+            format: 'lsif',
+            indexer: 'lsif-go',
+            lsifVersion: '0.4.3',
+            positionEncoding: 'utf-16',
+            projectRoot: 'file:///src',
+        })
+    })
+
+    it('should reject unsupported versions', () => {
+        for (const version of ['0.3.0', '0.6.0', '1.0.0', 'latest', undefined]) {
+            expect(() => parseMetadata({ ...metaData, version })).toThrow('unsupported LSIF version')
+        }
+    })
+
+    it('should reject unsupported position encodings', () => {
+        expect(() => parseMetadata({ ...metaData, positionEncoding: 'utf-8' })).toThrow(
+            'unsupported position encoding utf-8'
+        )
+    })
+
+    it('should reject invalid project roots', () => {
+        expect(() => parseMetadata({ ...metaData, projectRoot: 'not a uri' })).toThrow('is not a valid URI')
+    })
+
+    it('should reject elements that are not metadata vertices', () => {
+        expect(() => parseMetadata({ type: 'vertex', label: 'project' })).toThrow('must be a metaData vertex')
+    })
+})
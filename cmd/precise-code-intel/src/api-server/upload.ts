@@ -0,0 +1,495 @@
+import * as fs from 'mz/fs'
+import * as lsif from 'lsif-protocol'
+import * as settings from './settings'
+import pRetry from 'p-retry'
+import { createGunzip } from 'zlib'
+import { finished, pipeline as _pipeline, Readable, Transform, TransformCallback } from 'stream'
+import { promisify } from 'util'
+import { CHECKSUM_HEADER, checksumFile, checksumMismatchError, ChecksumStream } from '../shared/checksum'
+import { addTags, logAndTraceCall, TracingContext, tracingHeaders } from '../shared/tracing'
+import { authorizationHeaders } from '../shared/api/middleware/auth'
+import { bundleManagerClient, bundleManagerUrl } from './backend/database'
+import { isApiError } from '../shared/api/middleware/errors'
+import { decodeMetadata, ScipMetadata } from '../shared/encoding/scip'
+import { readFieldHeader, WireType } from '../shared/encoding/protobuf'
+import { detectFormat } from '../shared/input'
+import { LsifUploadFormat } from '../shared/models/pg'
+
+const pipeline = promisify(_pipeline)
+
+/** The magic number at the start of every Zstandard frame. */
+const ZSTD_MAGIC_NUMBER = Buffer.from([0x28, 0xb5, 0x2f, 0xfd])
+
+/** The content encodings of LSIF uploads that can be decoded. */
+const SUPPORTED_CONTENT_ENCODINGS = ['gzip', 'identity']
+
+/** The oldest version of the LSIF protocol that can be converted. */
+const MIN_LSIF_VERSION = [0, 4, 0]
+
+/** The first version of the LSIF protocol that is too new to be converted. */
+const MAX_LSIF_VERSION = [0, 6, 0]
+
+/** The SCIP protocol versions that can be converted. */
+const SUPPORTED_SCIP_VERSIONS = [0]
+
+/** The metadata of an upload, as described by the metadata vertex or message at its start. */
+export interface UploadMetadata {
+    /** The format of the upload. */
+    format: LsifUploadFormat
+
+    /** The name of the indexer that produced the upload, if given. */
+    indexer?: string
+
+    /** The version of the LSIF protocol used by the upload. Not given for SCIP uploads. */
+    lsifVersion?: string
+
+    /**
+     * The encoding of the character offsets of positions in the upload. Not given for SCIP
+     * uploads, which may declare an encoding per document.
+     */
+    positionEncoding?: string
+
+    /** The URI of the root of the indexed project. */
+    projectRoot: string
+}
+
+/**
+ * Create an error indicating that an upload is compressed in a format that cannot be decoded.
+ * Only gzip is supported: Zstandard requires a native module that is not a dependency of
+ * this service.
+ *
+ * @param encoding The name of the unsupported encoding.
+ */
+export function unsupportedEncodingError(encoding: string): Error {
+    return Object.assign(new Error(`Unsupported LSIF upload encoding ${encoding}: uploads must be gzipped`), {
+        status: 415,
+        code: 'unsupported_encoding',
+    })
+}
+
+/**
+ * Throw an unsupported encoding error if the given Content-Encoding header names an encoding
+ * that is not supported. Uploads are always expected to be gzipped, so the header is optional.
+ *
+ * @param contentEncoding The value of the Content-Encoding header.
+ */
+export function checkContentEncoding(contentEncoding: string | undefined): void {
+    const encoding = (contentEncoding || '').trim().toLowerCase()
+    if (encoding !== '' && !SUPPORTED_CONTENT_ENCODINGS.includes(encoding)) {
+        throw unsupportedEncodingError(encoding)
+    }
+}
+
+/**
+ * Write a gzipped LSIF upload to the given file. The payload is decompressed as it is
+ * received so that payloads which are not gzipped, are truncated, or do not begin with
+ * valid JSON are rejected with a bad request error without reading the file a second
+ * time. Payloads larger than the given size are rejected with a payload too large error.
+ * If an expected checksum is supplied, payloads with a different SHA-256 digest are also
+ * rejected with a bad request error.
+ *
+ * The payload is either gzipped JSON lines, which must begin with a metadata vertex that
+ * describes a supported version of LSIF with UTF-16 position offsets, or a gzipped SCIP (or
+ * LSIF-typed) protobuf index, which must begin with its metadata message. In both cases the
+ * metadata must name a valid project root URI. Payloads that do not are rejected with an
+ * unprocessable entity error. Resolves to the digest of the payload and its metadata.
+ *
+ * @param input The request body.
+ * @param filename The file to which the upload is written.
+ * @param maxSizeBytes The maximum size of the compressed payload.
+ * @param expectedChecksum The hex-encoded SHA-256 digest supplied by the client.
+ */
+export async function receiveUpload(
+    input: Readable,
+    filename: string,
+    maxSizeBytes: number,
+    expectedChecksum?: string
+): Promise<{ metadata: UploadMetadata; checksum: string }> {
+    const limiter = new SizeLimiter(maxSizeBytes)
+    const gunzip = createGunzip()
+    input.pipe(limiter)
+    limiter.pipe(gunzip)
+
+    // Ensure we forward errors reading the request to the pipeline below
+    input.on('error', error => limiter.destroy(error))
+
+    finished(limiter, error => {
+        if (error) {
+            // Discard the remainder of the request body so that an error response can be sent
+            input.unpipe(limiter)
+            input.resume()
+            gunzip.destroy()
+        }
+    })
+
+    let received = false
+    const inspection = readMetadata(gunzip).catch(error => {
+        // Explain why a Zstandard payload cannot be read rather than reporting a bad gzip header
+        const malformedError = isApiError(error)
+            ? error
+            : limiter.head.equals(ZSTD_MAGIC_NUMBER)
+            ? unsupportedEncodingError('zstd')
+            : Object.assign(new Error(`Malformed LSIF upload: ${String(error?.message)}`), {
+                  status: 400,
+                  code: 'malformed_upload',
+              })
+
+        if (!received) {
+            // Stop receiving the request
+            limiter.destroy(malformedError)
+        }
+
+        throw malformedError
+    })
+
+    // Errors are reported by the pipeline while the request is being received
+    inspection.catch(() => {
+        /* noop */
+    })
+
+    const checksumStream = new ChecksumStream()
+    await pipeline(limiter, checksumStream, fs.createWriteStream(filename))
+    received = true
+
+    if (limiter.size === 0) {
+        throw Object.assign(new Error('Malformed LSIF upload: empty payload'), {
+            status: 400,
+            code: 'malformed_upload',
+        })
+    }
+
+    const checksum = checksumStream.digest()
+    if (expectedChecksum && expectedChecksum.toLowerCase() !== checksum) {
+        throw checksumMismatchError(expectedChecksum, checksum)
+    }
+
+    return { metadata: await inspection, checksum }
+}
+
+/**
+ * Return the size of each chunk of an upload that the bundle manager has already received,
+ * indexed by chunk index. If the chunks cannot be listed, no chunks are assumed to have been
+ * received.
+ *
+ * @param url The URL of the chunks endpoint of the upload.
+ * @param ctx The tracing context.
+ */
+async function receivedChunkSizes(url: string, ctx: TracingContext): Promise<Map<number, number>> {
+    try {
+        const resp = await bundleManagerClient.get(url, {
+            headers: { ...tracingHeaders(ctx), ...authorizationHeaders() },
+        })
+        const chunks: { index: number; size: number }[] = JSON.parse(resp.body)
+        return new Map(chunks.map(({ index, size }) => [index, size]))
+    } catch (error) {
+        if (ctx.logger) {
+            ctx.logger.warn('Failed to list received chunks', { url, error })
+        }
+
+        return new Map()
+    }
+}
+
+/**
+ * Send the given upload file to the bundle manager in chunks of `UPLOAD_CHUNK_SIZE_BYTES`.
+ * Each chunk is sent along with its checksum and is retried independently, so a failed
+ * transfer resumes from the chunk that failed rather than from the start of the file. The
+ * bundle manager concatenates the chunks once all of them have been received and verifies
+ * the result against the checksum of the entire upload. Chunks that the bundle manager has
+ * already received from an earlier attempt to send the same upload are not sent again.
+ *
+ * @param filename The upload file.
+ * @param uploadId The identifier of the upload.
+ * @param checksum The hex-encoded SHA-256 digest of the upload file.
+ * @param ctx The tracing context.
+ */
+export async function sendUpload(
+    filename: string,
+    uploadId: number,
+    checksum: string,
+    ctx: TracingContext = {}
+): Promise<void> {
+    const { size } = await fs.stat(filename)
+    const numChunks = Math.max(1, Math.ceil(size / settings.UPLOAD_CHUNK_SIZE_BYTES))
+    const url = (path: string): string =>
+        new URL(`/uploads/${uploadId}/${path}`, bundleManagerUrl(uploadId)).href
+
+    const received = await receivedChunkSizes(url('chunks'), ctx)
+
+    for (let index = 0; index < numChunks; index++) {
+        // Byte ranges are inclusive
+        const range = {
+            start: index * settings.UPLOAD_CHUNK_SIZE_BYTES,
+            end: Math.min((index + 1) * settings.UPLOAD_CHUNK_SIZE_BYTES, size) - 1,
+        }
+
+        // Each received chunk was verified against its checksum, and a chunk that does not
+        // belong to this file is caught by the checksum of the stitched upload
+        if (received.get(index) === range.end - range.start + 1) {
+            continue
+        }
+
+        const chunkChecksum = await checksumFile(filename, range)
+
+        await logAndTraceCall(addTags(ctx, { index }), 'Sending chunk', chunkCtx =>
+            pRetry(
+                async () => {
+                    try {
+                        await pipeline(
+                            fs.createReadStream(filename, range),
+                            bundleManagerClient.stream.post(url(String(index)), {
+                                headers: {
+                                    ...tracingHeaders(chunkCtx),
+                                    ...authorizationHeaders(),
+                                    [CHECKSUM_HEADER]: chunkChecksum,
+                                    'Content-Length': String(range.end - range.start + 1),
+                                },
+                            })
+                        )
+                    } catch (error) {
+                        // Retrying will not help if the upload is unknown to the bundle manager, but
+                        // other failures (including checksum mismatches) may be transient
+                        if (error.response && error.response.statusCode === 404) {
+                            throw new pRetry.AbortError(error)
+                        }
+
+                        throw error
+                    }
+                },
+                { retries: settings.MAX_CHUNK_UPLOAD_RETRIES, randomize: true }
+            )
+        )
+    }
+
+    await logAndTraceCall(ctx, 'Stitching chunks', stitchCtx =>
+        bundleManagerClient.post(url('stitch'), {
+            headers: { ...tracingHeaders(stitchCtx), ...authorizationHeaders(), 'Content-Type': 'application/json' },
+            body: JSON.stringify({ numChunks, checksum }),
+        })
+    )
+}
+
+/** A transform stream that fails once more than a maximum number of bytes have passed through it. */
+class SizeLimiter extends Transform {
+    public size = 0
+
+    /** The first bytes of the stream, used to identify the compression format of the payload. */
+    public head = Buffer.alloc(0)
+
+    /**
+     * Create a new SizeLimiter.
+     *
+     * @param maxSizeBytes The maximum number of bytes.
+     */
+    constructor(private maxSizeBytes: number) {
+        super()
+    }
+
+    public _transform(chunk: Buffer, _encoding: string, callback: TransformCallback): void {
+        this.size += chunk.length
+        if (this.head.length < ZSTD_MAGIC_NUMBER.length) {
+            this.head = Buffer.concat([this.head, chunk]).slice(0, ZSTD_MAGIC_NUMBER.length)
+        }
+
+        if (this.size > this.maxSizeBytes) {
+            callback(
+                Object.assign(new Error(`LSIF upload exceeds the maximum size of ${this.maxSizeBytes} bytes`), {
+                    status: 413,
+                    code: 'upload_too_large',
+                })
+            )
+            return
+        }
+
+        callback(undefined, chunk)
+    }
+}
+
+/**
+ * Read the metadata at the start of the decompressed upload. The metadata of a JSON lines
+ * upload is the metadata vertex on its first non-empty line, and the metadata of a SCIP
+ * index is its first field. The remainder of the stream is drained so that a truncated
+ * payload causes an error. Throws an unprocessable entity error if the upload does not
+ * start with valid metadata, or if the metadata does not end within `MAX_METADATA_SIZE_BYTES`
+ * bytes, so that a payload consisting of one huge line is not buffered in memory.
+ *
+ * @param decompressed The decompressed upload.
+ */
+async function readMetadata(decompressed: AsyncIterable<Buffer>): Promise<UploadMetadata> {
+    let head = Buffer.alloc(0)
+    let metadata: UploadMetadata | undefined
+
+    for await (const chunk of decompressed) {
+        if (metadata) {
+            continue
+        }
+
+        head = Buffer.concat([head, chunk])
+        metadata = parseHead(head, false)
+
+        if (!metadata && head.length > settings.MAX_METADATA_SIZE_BYTES) {
+            throw invalidUploadError(
+                `the metadata must be within the first ${settings.MAX_METADATA_SIZE_BYTES} bytes of the upload`
+            )
+        }
+    }
+
+    return metadata || (parseHead(head, true) as UploadMetadata)
+}
+
+/**
+ * Return the metadata at the start of the given prefix of a decompressed upload, or
+ * undefined if more of the upload must be read before the metadata is complete.
+ *
+ * @param head A prefix of the decompressed upload.
+ * @param complete Whether the prefix is the entire upload.
+ */
+function parseHead(head: Buffer, complete: boolean): UploadMetadata | undefined {
+    const format = detectFormat(head)
+    if (format === undefined) {
+        return complete ? parseMetadata(undefined) : undefined
+    }
+
+    if (format === 'lsif') {
+        const lines = head.toString().split('\n')
+        if (!complete) {
+            // The last line may be incomplete
+            lines.pop()
+        }
+
+        const line = lines.find(value => value.trim() !== '')
+        return line === undefined ? undefined : parseMetadata(JSON.parse(line))
+    }
+
+    const header = readFieldHeader(head, 0)
+    if (header && (header.field !== 1 || header.wireType !== WireType.lengthDelimited)) {
+        throw invalidUploadError('the first field of a SCIP index must be its metadata')
+    }
+
+    if (!header || header.offset + header.length > head.length) {
+        if (complete) {
+            throw invalidUploadError('the metadata of the SCIP index is truncated')
+        }
+
+        return undefined
+    }
+
+    return parseScipMetadata(decodeMetadata(head.slice(header.offset, header.offset + header.length)))
+}
+
+/**
+ * Create an unprocessable entity error describing why an upload is invalid.
+ *
+ * @param message The reason the upload is invalid.
+ */
+function invalidUploadError(message: string): Error {
+    return Object.assign(new Error(`Invalid LSIF upload: ${message}`), { status: 422, code: 'invalid_lsif' })
+}
+
+/**
+ * Return the metadata described by the given LSIF element. Throws an unprocessable entity
+ * error if the element is not a metadata vertex, if it describes an unsupported version of
+ * LSIF or position encoding, or if its project root is not a valid URI.
+ *
+ * @param element The first element of the upload.
+ */
+export function parseMetadata(element: unknown): UploadMetadata {
+    const vertex = element as Partial<lsif.MetaData> | undefined
+    if (
+        typeof vertex !== 'object' ||
+        vertex === null ||
+        vertex.type !== lsif.ElementTypes.vertex ||
+        vertex.label !== lsif.VertexLabels.metaData
+    ) {
+        throw invalidUploadError('the first element must be a metaData vertex')
+    }
+
+    const { version, positionEncoding, projectRoot, toolInfo } = vertex
+    if (typeof version !== 'string' || !isSupportedVersion(version)) {
+        const expected = `at least ${MIN_LSIF_VERSION.join('.')} and below ${MAX_LSIF_VERSION.join('.')}`
+        throw invalidUploadError(`unsupported LSIF version ${String(version)} (expected ${expected})`)
+    }
+
+    if (positionEncoding !== 'utf-16') {
+        throw invalidUploadError(`unsupported position encoding ${String(positionEncoding)} (expected utf-16)`)
+    }
+
+    if (typeof projectRoot !== 'string' || !isValidUri(projectRoot)) {
+        throw invalidUploadError(`project root ${String(projectRoot)} is not a valid URI`)
+    }
+
+    return { format: 'lsif', indexer: toolInfo?.name, lsifVersion: version, positionEncoding, projectRoot }
+}
+
+/**
+ * Return the upload metadata described by the given SCIP metadata message. Throws an
+ * unprocessable entity error if it describes an unsupported protocol version or if its
+ * project root is not a valid URI.
+ *
+ * @param metadata The decoded metadata of a SCIP index.
+ */
+export function parseScipMetadata({ version, toolInfo, projectRoot }: ScipMetadata): UploadMetadata {
+    if (!SUPPORTED_SCIP_VERSIONS.includes(version)) {
+        throw invalidUploadError(`unsupported SCIP protocol version ${version}`)
+    }
+
+    if (!isValidUri(projectRoot)) {
+        throw invalidUploadError(`project root ${projectRoot} is not a valid URI`)
+    }
+
+    return { format: 'scip', indexer: toolInfo?.name || undefined, projectRoot }
+}
+
+/**
+ * Determine if the given LSIF version is within the range of supported versions.
+ *
+ * @param version The version of the upload.
+ */
+function isSupportedVersion(version: string): boolean {
+    const parsed = parseVersion(version)
+    return (
+        parsed !== undefined &&
+        compareVersions(parsed, MIN_LSIF_VERSION) >= 0 &&
+        compareVersions(parsed, MAX_LSIF_VERSION) < 0
+    )
+}
+
+/**
+ * Parse a version of the form `major.minor.patch`. A trailing prerelease or build suffix
+ * is ignored. Returns undefined if the version is malformed.
+ *
+ * @param version The version string.
+ */
+function parseVersion(version: string): number[] | undefined {
+    const match = version.match(/^(\d+)\.(\d+)\.(\d+)(?:[-+].*)?$/)
+    return match ? match.slice(1).map(part => parseInt(part, 10)) : undefined
+}
+
+/**
+ * Compare two parsed versions component-wise.
+ *
+ * @param a The first version.
+ * @param b The second version.
+ */
+function compareVersions(a: number[], b: number[]): number {
+    for (let i = 0; i < a.length; i++) {
+        if (a[i] !== b[i]) {
+            return a[i] - b[i]
+        }
+    }
+
+    return 0
+}
+
+/**
+ * Determine if the given value is an absolute URI.
+ *
+ * @param value The candidate URI.
+ */
+function isValidUri(value: string): boolean {
+    try {
+        return new URL(value).protocol !== ''
+    } catch {
+        return false
+    }
+}
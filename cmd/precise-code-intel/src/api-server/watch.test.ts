@@ -0,0 +1,139 @@
+import * as sinon from 'sinon'
+import * as pgModels from '../shared/models/pg'
+import { NotificationSubscriber } from '../shared/database/notifications'
+import { watchUploadProgress, watchUploadState } from './watch'
+
+/** A notification listener whose notifications are sent by the test. */
+class FakeListener {
+    public subscribers = new Set<NotificationSubscriber>()
+
+    public subscribe(subscriber: NotificationSubscriber): () => void {
+        this.subscribers.add(subscriber)
+        return () => this.subscribers.delete(subscriber)
+    }
+
+    public notify(payload: string): void {
+        for (const subscriber of this.subscribers) {
+            subscriber.onNotification(payload)
+        }
+    }
+
+    public stop(): void {
+        for (const subscriber of this.subscribers) {
+            subscriber.onStop()
+        }
+    }
+}
+
+describe('watchUploadState', () => {
+    const upload = (state: pgModels.LsifUploadState): pgModels.LsifUpload => ({ id: 1, state } as pgModels.LsifUpload)
+
+    it('should emit the state transitions signalled by notifications', async () => {
+        const listener = new FakeListener()
+        const getUpload = sinon.stub()
+        getUpload.onCall(0).resolves(upload('queued'))
+        getUpload.onCall(1).resolves(upload('processing'))
+        getUpload.onCall(2).resolves(upload('completed'))
+
+        const events = watchUploadState(upload('queued'), getUpload, listener, 60000)[Symbol.asyncIterator]()
+        expect(await events.next()).toEqual({
+            done: false,
+            value: { event: 'state', data: { previousState: null, upload: upload('queued') } },
+        })
+
+        const next = events.next()
+        listener.notify('2')
+        listener.notify('1')
+        expect(await next).toEqual({
+            done: false,
+            value: { event: 'state', data: { previousState: 'queued', upload: upload('processing') } },
+        })
+
+        listener.notify('1')
+        expect(await events.next()).toEqual({
+            done: false,
+            value: { event: 'state', data: { previousState: 'processing', upload: upload('completed') } },
+        })
+
+        expect(await events.next()).toEqual({ done: true, value: undefined })
+        expect(getUpload.callCount).toEqual(3)
+        expect(listener.subscribers.size).toEqual(0)
+    })
+
+    it('should read the upload again after the recheck interval', async () => {
+        const listener = new FakeListener()
+        const getUpload = sinon.stub()
+        getUpload.onCall(0).resolves(upload('processing'))
+        getUpload.onCall(1).resolves(upload('errored'))
+
+        const events = watchUploadState(upload('processing'), getUpload, listener, 1)[Symbol.asyncIterator]()
+        await events.next()
+        expect(await events.next()).toEqual({
+            done: false,
+            value: { event: 'state', data: { previousState: 'processing', upload: upload('errored') } },
+        })
+    })
+
+    it('should end once the listener is stopped', async () => {
+        const listener = new FakeListener()
+        const getUpload = sinon.stub().resolves(upload('queued'))
+
+        const events = watchUploadState(upload('queued'), getUpload, listener, 60000)[Symbol.asyncIterator]()
+        await events.next()
+
+        const next = events.next()
+        listener.stop()
+        expect(await next).toEqual({ done: true, value: undefined })
+        expect(listener.subscribers.size).toEqual(0)
+    })
+
+    it('should throw once the upload is removed', async () => {
+        const listener = new FakeListener()
+        const getUpload = sinon.stub().resolves(undefined)
+
+        const events = watchUploadState(upload('queued'), getUpload, listener, 60000)[Symbol.asyncIterator]()
+        await events.next()
+        await expect(events.next()).rejects.toThrow('Upload not found')
+        expect(listener.subscribers.size).toEqual(0)
+    })
+})
+
+describe('watchUploadProgress', () => {
+    const upload = (
+        state: pgModels.LsifUploadState,
+        stage: pgModels.LsifUploadStage | null,
+        progress: number
+    ): pgModels.LsifUpload => ({ id: 1, state, stage, progress } as pgModels.LsifUpload)
+
+    it('should emit the progress changes signalled by notifications', async () => {
+        const listener = new FakeListener()
+        const getUpload = sinon.stub()
+        getUpload.onCall(0).resolves(upload('processing', 'correlating', 10))
+        getUpload.onCall(1).resolves(upload('processing', 'correlating', 10))
+        getUpload.onCall(2).resolves(upload('processing', 'writing', 60))
+        getUpload.onCall(3).resolves(upload('completed', null, 100))
+
+        const events = watchUploadProgress(upload('queued', null, 0), getUpload, listener, 60000)[
+            Symbol.asyncIterator
+        ]()
+        const values = []
+        for (const payload of [undefined, '1', '1', '1']) {
+            const next = events.next()
+            if (payload) {
+                listener.notify(payload)
+            }
+            values.push((await next).value)
+        }
+
+        expect(values).toEqual([
+            { event: 'progress', data: { state: 'queued', stage: null, progress: 0 } },
+            { event: 'progress', data: { state: 'processing', stage: 'correlating', progress: 10 } },
+            { event: 'progress', data: { state: 'processing', stage: 'writing', progress: 60 } },
+            { event: 'progress', data: { state: 'completed', stage: null, progress: 100 } },
+        ])
+
+        expect(await events.next()).toEqual({ done: true, value: undefined })
+        expect(getUpload.callCount).toEqual(4)
+        expect(listener.subscribers.size).toEqual(0)
+    })
+})
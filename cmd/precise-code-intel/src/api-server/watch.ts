@@ -0,0 +1,153 @@
+import * as pgModels from '../shared/models/pg'
+import { Cancellation } from '../shared/cancellation'
+import { NotificationListener } from '../shared/database/notifications'
+import { ServerSentEvent } from '../shared/api/sse'
+
+/** The states in which an upload remains until it is retried, restored, or removed. */
+const finalStates: pgModels.LsifUploadState[] = ['completed', 'errored', 'failed', 'deleting']
+
+/**
+ * Yield a `state` event carrying the given upload, then another each time the state of the upload
+ * changes, until the upload reaches a final state. Each event carries the current upload and the
+ * state it was in before the change (null for the first event). The stream ends without a final
+ * state if the listener is stopped or the given cancellation fires, and ends with an error if the
+ * upload is removed.
+ *
+ * @param upload The current upload.
+ * @param getUpload Read the upload with the given identifier.
+ * @param listener The listener of the upload state channel.
+ * @param recheckInterval The interval (in milliseconds) after which the upload is read without a notification.
+ * @param cancellation A cancellation that ends the stream.
+ */
+export async function* watchUploadState<T extends pgModels.LsifUpload>(
+    upload: T,
+    getUpload: (id: number) => Promise<T | undefined>,
+    listener: Pick<NotificationListener, 'subscribe'>,
+    recheckInterval: number,
+    cancellation?: Cancellation
+): AsyncIterable<ServerSentEvent> {
+    let previous: T | undefined
+    for await (const current of watchUpload(upload, getUpload, listener, recheckInterval, cancellation)) {
+        if (!previous || current.state !== previous.state) {
+            yield { event: 'state', data: { previousState: previous ? previous.state : null, upload: current } }
+        }
+
+        previous = current
+    }
+}
+
+/**
+ * Yield a `progress` event carrying the state, stage, and progress of the given upload, then another
+ * each time one of these changes, until the upload reaches a final state. The stream ends in the same
+ * way as the stream of `watchUploadState`.
+ *
+ * @param upload The current upload.
+ * @param getUpload Read the upload with the given identifier.
+ * @param listener The listener of the upload state channel.
+ * @param recheckInterval The interval (in milliseconds) after which the upload is read without a notification.
+ * @param cancellation A cancellation that ends the stream.
+ */
+export async function* watchUploadProgress<T extends pgModels.LsifUpload>(
+    upload: T,
+    getUpload: (id: number) => Promise<T | undefined>,
+    listener: Pick<NotificationListener, 'subscribe'>,
+    recheckInterval: number,
+    cancellation?: Cancellation
+): AsyncIterable<ServerSentEvent> {
+    let previous: string | undefined
+    for await (const current of watchUpload(upload, getUpload, listener, recheckInterval, cancellation)) {
+        const data = { state: current.state, stage: current.stage, progress: current.progress }
+        const serialized = JSON.stringify(data)
+        if (serialized !== previous) {
+            yield { event: 'progress', data }
+            previous = serialized
+        }
+    }
+}
+
+/**
+ * Yield the given upload, then the upload as read again each time it may have changed, until the
+ * upload reaches a final state.
+ *
+ * The upload is read again each time a notification carrying its identifier is received by the
+ * given listener. As notifications can be lost while the listener reconnects, the upload is also
+ * read again once the given interval passes without a notification. The iteration ends if the
+ * listener is stopped or the given cancellation fires, and throws if the upload is removed.
+ *
+ * @param upload The current upload.
+ * @param getUpload Read the upload with the given identifier.
+ * @param listener The listener of the upload state channel.
+ * @param recheckInterval The interval (in milliseconds) after which the upload is read without a notification.
+ * @param cancellation A cancellation that ends the iteration.
+ */
+async function* watchUpload<T extends pgModels.LsifUpload>(
+    upload: T,
+    getUpload: (id: number) => Promise<T | undefined>,
+    listener: Pick<NotificationListener, 'subscribe'>,
+    recheckInterval: number,
+    cancellation?: Cancellation
+): AsyncIterable<T> {
+    // The state may have changed between reading the upload and subscribing
+    let notified = true
+    let stopped = false
+    let wake = (): void => {
+        /* noop */
+    }
+
+    const stop = (): void => {
+        stopped = true
+        wake()
+    }
+
+    const unsubscribe = listener.subscribe({
+        onNotification: payload => {
+            if (parseInt(payload, 10) === upload.id) {
+                notified = true
+                wake()
+            }
+        },
+        onStop: stop,
+    })
+
+    const removeCancellationListener = cancellation
+        ? cancellation.onCancel(stop)
+        : () => {
+              /* noop */
+          }
+
+    try {
+        let current = upload
+        yield current
+
+        while (!finalStates.includes(current.state)) {
+            if (!notified && !stopped) {
+                await new Promise<void>(resolve => {
+                    const timer = setTimeout(resolve, recheckInterval)
+                    wake = () => {
+                        clearTimeout(timer)
+                        resolve()
+                    }
+                })
+            }
+
+            if (stopped) {
+                return
+            }
+
+            notified = false
+            const next = await getUpload(upload.id)
+            if (!next) {
+                throw Object.assign(new Error('Upload not found'), {
+                    status: 404,
+                    code: 'upload_not_found',
+                })
+            }
+
+            current = next
+            yield current
+        }
+    } finally {
+        unsubscribe()
+        removeCancellationListener()
+    }
+}
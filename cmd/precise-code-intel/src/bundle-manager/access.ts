@@ -0,0 +1,26 @@
+/**
+ * The identifiers of the dumps queried since the last report to the API server. Accesses are
+ * reported in batches so that queries do not wait on a write to Postgres.
+ */
+export class AccessBatch {
+    private ids = new Set<number>()
+
+    /**
+     * Record an access of the given dump.
+     *
+     * @param id The identifier of the dump.
+     */
+    public record(id: number): void {
+        this.ids.add(id)
+    }
+
+    /** Return the identifiers of the dumps accessed since the last call and reset the batch. */
+    public take(): number[] {
+        const ids = Array.from(this.ids)
+        this.ids.clear()
+        return ids
+    }
+}
+
+/** The dumps queried on this bundle manager since the last report. */
+export const accessBatch = new AccessBatch()
@@ -85,6 +85,33 @@ describe('GenericCache', () => {
         expect(factory.callCount).toEqual(1)
     })
 
+    it('should share failed fills and retry them on the next request', async () => {
+        const factory = sinon.stub<string[], Promise<string>>()
+        const { wait, done } = createBarrierPromise()
+        factory.onFirstCall().returns(wait.then(() => Promise.reject(new Error('oops'))))
+        factory.onSecondCall().resolves('bar')
+
+        const cache = new GenericCache<string, string>(
+            5,
+            () => 1,
+            () => {
+                /* noop */
+            },
+            testMetrics
+        )
+        const p1 = cache.withValue('foo', factory, v => Promise.resolve(v))
+        const p2 = cache.withValue('foo', factory, v => Promise.resolve(v))
+        done()
+
+        await expect(p1).rejects.toThrow('oops')
+        await expect(p2).rejects.toThrow('oops')
+        expect(factory.callCount).toEqual(1)
+        expect(cache.occupancy()).toEqual({ entries: 0, size: 0, max: 5 })
+
+        expect(await cache.withValue('foo', factory, v => Promise.resolve(v))).toEqual('bar')
+        expect(factory.callCount).toEqual(2)
+    })
+
     it('should call dispose function on eviction', async () => {
         const values = [
             'foo', // foo
@@ -235,4 +262,48 @@ describe('GenericCache', () => {
         await Promise.all([p1, p2, wait1])
         expect(disposer.args).toEqual([['foo']])
     })
+
+    it('should replace expired entries', async () => {
+        const factory = sinon.stub<string[], Promise<string>>()
+        factory.onFirstCall().resolves('foo')
+        factory.onSecondCall().resolves('bar')
+
+        const { wait, done } = createBarrierPromise()
+        const disposer = sinon.spy(done)
+
+        // A zero TTL expires entries immediately
+        const cache = new GenericCache<string, string>(5, () => 1, disposer, testMetrics, 0)
+        expect(await cache.withValue('foo', factory, v => Promise.resolve(v))).toEqual('foo')
+        expect(await cache.withValue('foo', factory, v => Promise.resolve(v))).toEqual('bar')
+
+        await wait
+        expect(disposer.args).toEqual([['foo']])
+        expect(cache.occupancy()).toEqual({ entries: 1, size: 1, max: 5 })
+    })
+
+    it('should bust keys matching a predicate', async () => {
+        const disposer = sinon.spy()
+        const cache = new GenericCache<string, string>(5, () => 1, disposer, testMetrics)
+
+        for (const key of ['a::1', 'a::2', 'b::1']) {
+            await cache.withValue(key, () => Promise.resolve(key), () => Promise.resolve())
+        }
+
+        await cache.bustKeys(key => key.startsWith('a::'))
+        expect(disposer.args).toEqual([['a::1'], ['a::2']])
+        expect(cache.occupancy()).toEqual({ entries: 1, size: 1, max: 5 })
+    })
+
+    it('should report occupancy', async () => {
+        const cache = new GenericCache<string, string>(10, v => v.length, () => Promise.resolve(), testMetrics)
+        expect(cache.occupancy()).toEqual({ entries: 0, size: 0, max: 10 })
+
+        await cache.withValue('foo', () => Promise.resolve('foo'), () => Promise.resolve())
+        await cache.withValue('bar', () => Promise.resolve('bonk'), () => Promise.resolve())
+        expect(cache.occupancy()).toEqual({ entries: 2, size: 7, max: 10 })
+
+        // Evicts foo
+        await cache.withValue('baz', () => Promise.resolve('quux'), () => Promise.resolve())
+        expect(cache.occupancy()).toEqual({ entries: 2, size: 8, max: 10 })
+    })
 })
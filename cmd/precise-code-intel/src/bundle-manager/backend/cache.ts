@@ -3,8 +3,18 @@ import * as metrics from '../metrics'
 import promClient from 'prom-client'
 import Yallist from 'yallist'
 import { Connection, EntityManager } from 'typeorm'
-import { createSqliteConnection } from '../../shared/database/sqlite'
+import { Database as SqliteDatabase } from 'sqlite3'
+import {
+    closeSqliteDatabase,
+    createSqliteConnection,
+    openSqliteDatabase,
+    SqliteConnectionOptions,
+} from '../../shared/database/sqlite'
+import { StatementCache } from '../../shared/database/statements'
+import { ConnectionPool, ConnectionPoolOptions } from './pool'
 import { Logger } from 'winston'
+import { CacheOccupancy } from '../../shared/stats'
+import { estimateSize } from './footprint'
 
 /** A wrapper around a cache value promise. */
 interface CacheEntry<K, V> {
@@ -14,6 +24,9 @@ interface CacheEntry<K, V> {
     /** The promise that will resolve the cache value. */
     promise: Promise<V>
 
+    /** The time (in milliseconds since the epoch) that this entry was created. */
+    createdAt: number
+
     /**
      * The size of the promise value, once resolved. This value is
      * initially zero and is updated once an appropriate can be
@@ -82,17 +95,34 @@ export class GenericCache<K, V> {
      * @param sizeFunction A function that determines the size of a cache item.
      * @param disposeFunction A function that disposes of evicted cache items.
      * @param cacheMetrics The bag of metrics to use for this instance of the cache.
+     * @param ttl The number of seconds after which an entry is no longer used (< 0 means never).
      */
     constructor(
         private max: number,
         private sizeFunction: (value: V) => number,
         private disposeFunction: (value: V) => Promise<void> | void,
-        private cacheMetrics: CacheMetrics
+        private cacheMetrics: CacheMetrics,
+        private ttl = -1
     ) {}
 
     /** Remove all values from the cache. */
-    public async flush(): Promise<void> {
-        await Promise.all(Array.from(this.cache.keys()).map(key => this.bustKey(key)))
+    public flush(): Promise<void> {
+        return this.bustKeys(() => true)
+    }
+
+    /**
+     * Remove all keys matching the given predicate from the cache. This
+     * blocks until all current readers of the removed values have completed.
+     *
+     * @param predicate The function that determines if a key should be removed.
+     */
+    public async bustKeys(predicate: (key: K) => boolean): Promise<void> {
+        await Promise.all(Array.from(this.cache.keys()).filter(predicate).map(key => this.bustKey(key)))
+    }
+
+    /** Return the number of entries and the current and maximum size of the cache. */
+    public occupancy(): CacheOccupancy {
+        return { entries: this.cache.size, size: this.size, max: this.max }
     }
 
     /**
@@ -156,9 +186,15 @@ export class GenericCache<K, V> {
         // Wait for the value to resolve. We do this first in case the value
         // was still under construction. This simplifies the rest of the logic
         // below, as readers can never be negative once the promise value has
-        // resolved.
+        // resolved. If the value could not be constructed, there is nothing to
+        // dispose.
 
-        const value = await promise
+        let value: V
+        try {
+            value = await promise
+        } catch {
+            return
+        }
 
         if (readers > 0) {
             // There's someone holding the cache value. Create a barrier promise
@@ -188,7 +224,17 @@ export class GenericCache<K, V> {
      */
     private async getEntry(key: K, factory: () => Promise<V>): Promise<CacheEntry<K, V>> {
         const node = this.cache.get(key)
-        if (node) {
+        if (node && this.ttl >= 0 && Date.now() - node.value.createdAt >= this.ttl * 1000) {
+            // The entry is too old to be used. Remove it from the cache so that it
+            // is replaced below. The value is disposed once its current readers have
+            // completed, but we do not need to wait for that here.
+            this.bustKey(key).catch(() => {
+                /* noop */
+            })
+
+            // Log cache event
+            this.cacheMetrics.eventsCounter.labels('expiration').inc()
+        } else if (node) {
             // Move to head of list
             this.lruList.unshiftNode(node)
 
@@ -212,7 +258,7 @@ export class GenericCache<K, V> {
         // the same key will create a duplicate cache entry.
 
         const promise = factory()
-        const newEntry = { key, promise, size: 0, readers: 1, waiter: undefined }
+        const newEntry = { key, promise, createdAt: Date.now(), size: 0, readers: 1, waiter: undefined }
 
         // Add to head of list
         this.lruList.unshift(newEntry)
@@ -227,9 +273,29 @@ export class GenericCache<K, V> {
 
         // Now that another call to getEntry will find the cache entry
         // and early-out, we can block here and wait to resolve the
-        // value, then update the entry and cache sizes.
+        // value, then update the entry and cache sizes. Concurrent
+        // requests for the same key share this single factory call.
+
+        let value: V
+        try {
+            value = await promise
+        } catch (error) {
+            // The factory failed. Callers that are already waiting on this
+            // entry receive the same error, but we remove the entry so that
+            // the next request for the key retries the factory instead of
+            // receiving a cached error. The entry may have already been
+            // removed by a call to bustKey.
+
+            newEntry.readers--
+            if (head && this.cache.get(key) === head) {
+                this.removeNode(head, 0)
+            }
+
+            // Log cache event
+            this.cacheMetrics.eventsCounter.labels('error').inc()
+            throw error
+        }
 
-        const value = await promise
         await this.resolved(newEntry, value)
         return newEntry
     }
@@ -289,30 +355,74 @@ export class GenericCache<K, V> {
     }
 }
 
-/** A cache of SQLite database connections indexed by database filenames. */
-export class ConnectionCache extends GenericCache<string, Connection> {
+/** An open SQLite connection along with a database handle and the statements prepared on it. */
+export interface SqliteHandle {
+    /** The connection. */
+    connection: Connection
+
+    /** A database handle of the same file, as typeorm does not expose the handle of the connection. */
+    sqliteDatabase: SqliteDatabase
+
+    /** The prepared statements of the database handle. */
+    statements: StatementCache
+}
+
+/** A pooled SQLite handle along with the suffix of its connection name. */
+export interface PooledHandle extends SqliteHandle {
+    nameId: number
+}
+
+/** Options that control how the connections of a `ConnectionCache` are opened and pooled. */
+export interface ConnectionCacheOptions extends SqliteConnectionOptions, Partial<ConnectionPoolOptions> {
+    /** The maximum number of prepared statements kept for each connection (0 disables caching). */
+    statementCacheSize?: number
+
+    /** The queries prepared when a connection is opened and kept prepared until it is closed. */
+    pinnedQueries?: string[]
+}
+
+/**
+ * A cache of pools of SQLite database connections indexed by database filenames. Each
+ * cached pool counts as a single entry regardless of the number of connections it holds.
+ */
+export class ConnectionCache extends GenericCache<string, ConnectionPool<PooledHandle>> {
+    /**
+     * The suffixes of connection names that are not used by an open connection. Names are
+     * reused so that the number of connections registered with typeorm stays bounded.
+     */
+    private static freeNameIds: number[] = []
+
+    /** The next suffix of a connection name that has never been used. */
+    private static nextNameId = 0
+
     /**
      * Create a new `ConnectionCache` with the given maximum (soft) size for
      * all items in the cache.
+     *
+     * @param max The maximum number of databases with open connections.
+     * @param options The options used to open and pool new connections.
+     * @param ttl The number of seconds after which a connection pool is reopened (< 0 means never).
      */
-    constructor(max: number) {
+    constructor(max: number, private options: ConnectionCacheOptions = {}, ttl = -1) {
         super(
             max,
-            // Each handle is roughly the same size.
+            // Each pool is roughly the same size.
             () => 1,
-            // Close the underlying file handle on cache eviction.
-            connection => connection.close(),
+            // Close the underlying file handles on cache eviction.
+            pool => pool.close(),
             {
                 sizeGauge: metrics.connectionCacheSizeGauge,
                 eventsCounter: metrics.connectionCacheEventsCounter,
-            }
+            },
+            ttl
         )
     }
 
     /**
      * Invoke `callback` with a SQLite connection object obtained from the
      * cache or created on cache miss. This connection is guaranteed not to
-     * be disposed by cache eviction while the callback is active.
+     * be disposed by cache eviction or used by another caller while the
+     * callback is active.
      *
      * @param database The database filename.
      * @param entities The set of entities to create on a new connection.
@@ -327,7 +437,31 @@ export class ConnectionCache extends GenericCache<string, Connection> {
         logger: Logger,
         callback: (connection: Connection) => Promise<T>
     ): Promise<T> {
-        return this.withValue(database, () => createSqliteConnection(database, entities, logger), callback)
+        return this.withHandle(database, entities, logger, ({ connection }) => callback(connection))
+    }
+
+    /**
+     * Like `withConnection`, but will invoke the callback with the prepared
+     * statements of the connection as well.
+     *
+     * @param database The database filename.
+     * @param entities The set of entities to create on a new connection.
+     * @param logger The logger instance.
+     * @param callback The function invoke with the SQLite connection and its statements.
+     */
+    public withHandle<T>(
+        database: string,
+        // Decorators are not possible type check
+        // eslint-disable-next-line @typescript-eslint/ban-types
+        entities: Function[],
+        logger: Logger,
+        callback: (handle: SqliteHandle) => Promise<T>
+    ): Promise<T> {
+        return this.withValue(
+            database,
+            () => this.openPool(database, entities, logger),
+            pool => pool.withConnection(callback)
+        )
     }
 
     /**
@@ -349,40 +483,118 @@ export class ConnectionCache extends GenericCache<string, Connection> {
     ): Promise<T> {
         return this.withConnection(database, entities, logger, connection => connection.transaction(callback))
     }
-}
 
-/**
- * A wrapper around a cache value that retains its encoded size. In order to keep
- * the in-memory limit of these decoded items, we use this value as the cache entry
- * size. This assumes that the size of the encoded text is a good proxy for the size
- * of the in-memory representation.
- */
-export interface EncodedJsonCacheValue<T> {
-    /** The size of the encoded value. */
-    size: number
+    /**
+     * Create a connection pool for the given database. The first connection is opened
+     * before returning so that a database that cannot be opened is not cached.
+     *
+     * @param database The database filename.
+     * @param entities The set of entities to create on a new connection.
+     * @param logger The logger instance.
+     */
+    private async openPool(
+        database: string,
+        // Decorators are not possible type check
+        // eslint-disable-next-line @typescript-eslint/ban-types
+        entities: Function[],
+        logger: Logger
+    ): Promise<ConnectionPool<PooledHandle>> {
+        const {
+            maxOpen = 1,
+            maxIdle = 1,
+            statementCacheSize = 0,
+            pinnedQueries = [],
+            ...connectionOptions
+        } = this.options
+
+        const pool = new ConnectionPool<PooledHandle>(
+            async () => {
+                const freeNameId = ConnectionCache.freeNameIds.pop()
+                const nameId = freeNameId !== undefined ? freeNameId : ConnectionCache.nextNameId++
+                try {
+                    const connection = await createSqliteConnection(database, entities, logger, {
+                        ...connectionOptions,
+                        name: `${database}#${nameId}`,
+                    })
+
+                    let sqliteDatabase: SqliteDatabase | undefined
+                    try {
+                        sqliteDatabase = await openSqliteDatabase(database, connectionOptions)
+                        const statements = new StatementCache(sqliteDatabase, statementCacheSize)
+                        try {
+                            await statements.pin(pinnedQueries)
+                        } catch (error) {
+                            await statements.finalize()
+                            throw error
+                        }
+
+                        return { connection, sqliteDatabase, statements, nameId }
+                    } catch (error) {
+                        if (sqliteDatabase) {
+                            await closeSqliteDatabase(sqliteDatabase)
+                        }
+                        await connection.close()
+                        throw error
+                    }
+                } catch (error) {
+                    ConnectionCache.freeNameIds.push(nameId)
+                    throw error
+                }
+            },
+            async ({ connection, sqliteDatabase, statements, nameId }) => {
+                try {
+                    await statements.finalize()
+                    await closeSqliteDatabase(sqliteDatabase)
+                    await connection.close()
+                } finally {
+                    ConnectionCache.freeNameIds.push(nameId)
+                }
+            },
+            { maxOpen, maxIdle },
+            {
+                openGauge: metrics.connectionPoolOpenGauge,
+                idleGauge: metrics.connectionPoolIdleGauge,
+                waitsCounter: metrics.connectionPoolWaitsCounter,
+                waitDurationHistogram: metrics.connectionPoolWaitDurationHistogram,
+            }
+        )
 
-    /** The decoded value. */
-    data: T
+        try {
+            await pool.withConnection(() => Promise.resolve())
+        } catch (error) {
+            await pool.close()
+            throw error
+        }
+
+        return pool
+    }
 }
 
-/** A cache of decoded values encoded as JSON and gzipped in a SQLite database. */
-class EncodedJsonCache<K, V> extends GenericCache<K, EncodedJsonCacheValue<V>> {
+/**
+ * A cache of decoded values encoded as JSON and gzipped in a SQLite database. The size
+ * of each entry is the estimated number of bytes the decoded value occupies in memory,
+ * so that the maximum size of the cache is a memory budget. The size of the encoded
+ * value is not used as it is compressed and is a poor proxy for the decoded size.
+ */
+class EncodedJsonCache<K, V> extends GenericCache<K, V> {
     /**
      * Create a new `EncodedJsonCache` with the given maximum (soft) size for
      * all items in the cache.
      *
-     * @param max The maximum size of the cache before an eviction.
+     * @param max The maximum number of bytes held by the cache before an eviction.
      * @param cacheMetrics The bag of metrics to use for this instance of the cache.
+     * @param ttl The number of seconds after which a value is decoded again (< 0 means never).
      */
-    constructor(max: number, cacheMetrics: CacheMetrics) {
+    constructor(max: number, cacheMetrics: CacheMetrics, ttl = -1) {
         super(
             max,
-            v => v.size,
+            estimateSize,
             // Let GC handle the cleanup of the object on cache eviction.
             () => {
                 /* noop */
             },
-            cacheMetrics
+            cacheMetrics,
+            ttl
         )
     }
 }
@@ -396,13 +608,18 @@ export class DocumentCache extends EncodedJsonCache<string, sqliteModels.Documen
      * Create a new `DocumentCache` with the given maximum (soft) size for
      * all items in the cache.
      *
-     * @param max The maximum size of the cache before an eviction.
+     * @param max The maximum number of bytes held by the cache before an eviction.
+     * @param ttl The number of seconds after which a value is decoded again (< 0 means never).
      */
-    constructor(max: number) {
-        super(max, {
-            sizeGauge: metrics.documentCacheSizeGauge,
-            eventsCounter: metrics.documentCacheEventsCounter,
-        })
+    constructor(max: number, ttl = -1) {
+        super(
+            max,
+            {
+                sizeGauge: metrics.documentCacheSizeGauge,
+                eventsCounter: metrics.documentCacheEventsCounter,
+            },
+            ttl
+        )
     }
 }
 
@@ -415,12 +632,47 @@ export class ResultChunkCache extends EncodedJsonCache<string, sqliteModels.Resu
      * Create a new `ResultChunkCache` with the given maximum (soft) size for
      * all items in the cache.
      *
-     * @param max The maximum size of the cache before an eviction.
+     * @param max The maximum number of bytes held by the cache before an eviction.
+     * @param ttl The number of seconds after which a value is decoded again (< 0 means never).
+     */
+    constructor(max: number, ttl = -1) {
+        super(
+            max,
+            {
+                sizeGauge: metrics.resultChunkCacheSizeGauge,
+                eventsCounter: metrics.resultChunkCacheEventsCounter,
+            },
+            ttl
+        )
+    }
+}
+
+/** A bundle downloaded from object storage to the local disk. */
+export interface CachedBundle {
+    /** The path of the local copy of the bundle. */
+    filename: string
+
+    /** The size of the bundle in bytes. */
+    size: number
+}
+
+/**
+ * A cache of bundles downloaded from object storage to the local disk, indexed by bundle
+ * key. The size of each entry is the size of its file, so that the maximum size of the
+ * cache is a disk budget. A bundle is not removed from disk while it is being queried.
+ */
+export class BundleFileCache extends GenericCache<string, CachedBundle> {
+    /**
+     * Create a new `BundleFileCache` with the given maximum (soft) size for
+     * all items in the cache.
+     *
+     * @param max The maximum number of bytes of bundles held on disk before an eviction.
+     * @param disposeFunction A function that removes an evicted bundle from disk.
      */
-    constructor(max: number) {
-        super(max, {
-            sizeGauge: metrics.resultChunkCacheSizeGauge,
-            eventsCounter: metrics.resultChunkCacheEventsCounter,
+    constructor(max: number, disposeFunction: (bundle: CachedBundle) => Promise<void>) {
+        super(max, ({ size }) => size, disposeFunction, {
+            sizeGauge: metrics.bundleCacheSizeGauge,
+            eventsCounter: metrics.bundleCacheEventsCounter,
         })
     }
 }
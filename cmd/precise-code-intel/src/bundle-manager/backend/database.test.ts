@@ -1,17 +1,30 @@
+import * as sinon from 'sinon'
 import * as sqliteModels from '../../shared/models/sqlite'
-import { comparePosition, findRanges, mapRangesToInternalLocations, Database } from './database'
+import {
+    comparePosition,
+    findRanges,
+    mapRangesToInternalLocations,
+    parseMonikerIdentifier,
+    symbolsFromTags,
+    Database,
+} from './database'
+import { InternalLocation } from './location'
 import * as fs from 'mz/fs'
 import * as nodepath from 'path'
 import { convertLsif } from '../../worker/conversion/importer'
 import { PathExistenceChecker } from '../../worker/conversion/existence'
 import rmfr from 'rmfr'
 import * as uuid from 'uuid'
+import { PayloadEncoding } from '../../shared/encoding/payload'
 
 describe('Database', () => {
     let storageRoot!: string
     let database!: Database
 
-    const makeDatabase = async (filename: string): Promise<Database> => {
+    const makeDatabase = async (
+        filename: string,
+        payloadEncoding: PayloadEncoding = 'gzip-json'
+    ): Promise<Database> => {
         // Create a filesystem read stream for the given test file. This will cover
         // the cases where `yarn test` is run from the root or from the lsif directory.
         const sourceFile = nodepath.join(
@@ -30,6 +43,7 @@ describe('Database', () => {
                 commit: 'ad3507cbeb18d1ed2b8a0f6354dea88a101197f3',
                 root: '',
             }),
+            payloadEncoding,
         })
 
         return new Database(1, databaseFile)
@@ -46,6 +60,18 @@ describe('Database', () => {
         }
     })
 
+    describe('warm', () => {
+        it('should read documents and their result chunks into the caches', async () => {
+            const warmed = await makeDatabase('lsif-go@ad3507cb.lsif.gz')
+            const before = Database.cacheOccupancy()
+            await warmed.warm(['cmd/lsif-go/main.go', 'missing.go'])
+            const after = Database.cacheOccupancy()
+
+            expect(after.documents.entries).toEqual(before.documents.entries + 1)
+            expect(after.resultChunks.entries).toBeGreaterThan(before.resultChunks.entries)
+        })
+    })
+
     describe('exists', () => {
         it('should check document path', async () => {
             expect(await database.exists('cmd/lsif-go/main.go')).toEqual(true)
@@ -59,12 +85,15 @@ describe('Database', () => {
             // `\ts, err := indexer.Index()` -> `\t Index() (*Stats, error)`
             //                      ^^^^^           ^^^^^
 
-            expect(await database.definitions('cmd/lsif-go/main.go', { line: 110, character: 22 })).toEqual([
-                {
-                    path: 'internal/index/indexer.go',
-                    range: { start: { line: 20, character: 1 }, end: { line: 20, character: 6 } },
-                },
-            ])
+            expect(await database.definitions('cmd/lsif-go/main.go', { line: 110, character: 22 })).toEqual({
+                locations: [
+                    {
+                        path: 'internal/index/indexer.go',
+                        range: { start: { line: 20, character: 1 }, end: { line: 20, character: 6 } },
+                    },
+                ],
+                count: 1,
+            })
         })
     })
 
@@ -79,20 +108,63 @@ describe('Database', () => {
             // -> `\t\t\trangeID, err = i.w.EmitRange(lspRange(ipos, ident.Name, false))`
             //                              ^^^^^^^^^
 
-            expect((await database.references('protocol/writer.go', { line: 85, character: 20 })).values).toEqual([
-                {
-                    path: 'protocol/writer.go',
-                    range: { start: { line: 85, character: 17 }, end: { line: 85, character: 26 } },
-                },
-                {
-                    path: 'internal/index/indexer.go',
-                    range: { start: { line: 529, character: 22 }, end: { line: 529, character: 31 } },
-                },
-                {
-                    path: 'internal/index/indexer.go',
-                    range: { start: { line: 380, character: 22 }, end: { line: 380, character: 31 } },
-                },
-            ])
+            expect(await database.references('protocol/writer.go', { line: 85, character: 20 })).toEqual({
+                locations: [
+                    {
+                        path: 'protocol/writer.go',
+                        range: { start: { line: 85, character: 17 }, end: { line: 85, character: 26 } },
+                    },
+                    {
+                        path: 'internal/index/indexer.go',
+                        range: { start: { line: 529, character: 22 }, end: { line: 529, character: 31 } },
+                    },
+                    {
+                        path: 'internal/index/indexer.go',
+                        range: { start: { line: 380, character: 22 }, end: { line: 380, character: 31 } },
+                    },
+                ],
+                count: 3,
+            })
+        })
+
+        it('should page references', async () => {
+            const position = { line: 85, character: 20 }
+            expect(await database.references('protocol/writer.go', position, { skip: 1, take: 1 })).toEqual({
+                locations: [
+                    {
+                        path: 'internal/index/indexer.go',
+                        range: { start: { line: 529, character: 22 }, end: { line: 529, character: 31 } },
+                    },
+                ],
+                count: 3,
+            })
+            expect(await database.references('protocol/writer.go', position, { skip: 5 })).toEqual({
+                locations: [],
+                count: 3,
+            })
+        })
+
+        it('should only read the documents of the page', async () => {
+            const getDocumentByPath = sinon.spy(
+                (database as unknown) as { getDocumentByPath: (path: string) => Promise<unknown> },
+                'getDocumentByPath'
+            )
+
+            try {
+                const position = { line: 85, character: 20 }
+                expect(await database.references('protocol/writer.go', position, { take: 1 })).toEqual({
+                    locations: [
+                        {
+                            path: 'protocol/writer.go',
+                            range: { start: { line: 85, character: 17 }, end: { line: 85, character: 26 } },
+                        },
+                    ],
+                    count: 3,
+                })
+                expect(getDocumentByPath.args.map(([path]) => path)).toEqual(['protocol/writer.go'])
+            } finally {
+                getDocumentByPath.restore()
+            }
         })
     })
 
@@ -113,6 +185,45 @@ describe('Database', () => {
         })
     })
 
+    describe('hovers', () => {
+        it('should return hover text aligned with positions', async () => {
+            const hovers = await database.hovers('internal/index/indexer.go', [
+                { line: 628, character: 20 },
+                { line: 0, character: 0 },
+            ])
+
+            expect(hovers).toHaveLength(2)
+            expect(hovers[0]?.range).toEqual({ start: { line: 628, character: 18 }, end: { line: 628, character: 30 } })
+            expect(hovers[1]).toBeNull()
+        })
+
+        it('should return nulls for an unknown document', async () => {
+            expect(await database.hovers('missing.go', [{ line: 628, character: 20 }])).toEqual([null])
+        })
+    })
+
+    describe('ranges', () => {
+        it('should return ranges intersecting the window', async () => {
+            const ranges = await database.ranges('internal/index/indexer.go', 628, 628)
+
+            expect(ranges).toContainEqual(
+                expect.objectContaining({
+                    range: { start: { line: 628, character: 18 }, end: { line: 628, character: 30 } },
+                    hasDefinitions: true,
+                    hasHover: true,
+                })
+            )
+            for (const { range } of ranges) {
+                expect(range.start.line).toBeLessThanOrEqual(628)
+                expect(range.end.line).toBeGreaterThanOrEqual(628)
+            }
+        })
+
+        it('should return an empty list for an unknown document', async () => {
+            expect(await database.ranges('missing.go', 0, 100)).toEqual([])
+        })
+    })
+
     describe('monikersByPosition', () => {
         it('should return correct range and document with monikers', async () => {
             // `func NewMetaData(id, root string, info ToolInfo) *MetaData {`
@@ -135,43 +246,43 @@ describe('Database', () => {
         const edgeLocations = [
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 600, character: 1 }, end: { line: 600, character: 5 } },
+                range: { start: { line: 410, character: 5 }, end: { line: 410, character: 9 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 644, character: 1 }, end: { line: 644, character: 5 } },
+                range: { start: { line: 440, character: 1 }, end: { line: 440, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 507, character: 1 }, end: { line: 507, character: 5 } },
+                range: { start: { line: 462, character: 1 }, end: { line: 462, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 553, character: 1 }, end: { line: 553, character: 5 } },
+                range: { start: { line: 484, character: 1 }, end: { line: 484, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 462, character: 1 }, end: { line: 462, character: 5 } },
+                range: { start: { line: 507, character: 1 }, end: { line: 507, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 484, character: 1 }, end: { line: 484, character: 5 } },
+                range: { start: { line: 530, character: 1 }, end: { line: 530, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 410, character: 5 }, end: { line: 410, character: 9 } },
+                range: { start: { line: 553, character: 1 }, end: { line: 553, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 622, character: 1 }, end: { line: 622, character: 5 } },
+                range: { start: { line: 600, character: 1 }, end: { line: 600, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 440, character: 1 }, end: { line: 440, character: 5 } },
+                range: { start: { line: 622, character: 1 }, end: { line: 622, character: 5 } },
             },
             {
                 path: 'protocol/protocol.go',
-                range: { start: { line: 530, character: 1 }, end: { line: 530, character: 5 } },
+                range: { start: { line: 644, character: 1 }, end: { line: 644, character: 5 } },
             },
         ]
 
@@ -203,6 +314,25 @@ describe('Database', () => {
             expect(count).toEqual(10)
         })
 
+        it('should return disjoint pages', async () => {
+            const locations: InternalLocation[] = []
+            for (let skip = 0; skip < 10; skip += 3) {
+                const { locations: page, count } = await database.monikerResults(
+                    sqliteModels.DefinitionModel,
+                    {
+                        scheme: 'gomod',
+                        identifier: 'github.com/sourcegraph/lsif-go/protocol:Edge',
+                    },
+                    { skip, take: 3 }
+                )
+
+                expect(count).toEqual(10)
+                locations.push(...page)
+            }
+
+            expect(locations).toEqual(edgeLocations)
+        })
+
         it('should query references table', async () => {
             const { locations, count } = await database.monikerResults(
                 sqliteModels.ReferenceModel,
@@ -222,6 +352,46 @@ describe('Database', () => {
             expect(count).toEqual(1)
         })
     })
+
+    describe('verify', () => {
+        it('should accept a converted bundle', async () => {
+            const verification = await database.verify(10)
+            expect(verification.problems).toEqual([])
+            expect(verification.valid).toEqual(true)
+            expect(verification.numSampledDocuments).toEqual(10)
+            expect(verification.numSampledResultChunks).toBeGreaterThan(0)
+        })
+
+        it('should report a file that is not a database', async () => {
+            const filename = nodepath.join(storageRoot, uuid.v4())
+            await fs.writeFile(filename, 'not a database'.repeat(1024))
+
+            const verification = await new Database(2, filename).verify(10)
+            expect(verification.valid).toEqual(false)
+            expect(verification.problems).toHaveLength(1)
+        })
+    })
+
+    describe('payload encodings', () => {
+        it('should answer the same queries from binary payloads', async () => {
+            const binaryDatabase = await makeDatabase('lsif-go@ad3507cb.lsif.gz', 'gzip-binary')
+
+            for (const [path, position] of [
+                ['cmd/lsif-go/main.go', { line: 110, character: 22 }],
+                ['internal/index/indexer.go', { line: 628, character: 20 }],
+            ] as [string, { line: number; character: number }][]) {
+                expect(await binaryDatabase.definitions(path, position)).toEqual(
+                    await database.definitions(path, position)
+                )
+                expect(await binaryDatabase.references(path, position)).toEqual(
+                    await database.references(path, position)
+                )
+                expect(await binaryDatabase.hover(path, position)).toEqual(await database.hover(path, position))
+            }
+
+            expect((await binaryDatabase.verify(10)).problems).toEqual([])
+        })
+    })
 })
 
 describe('findRanges', () => {
@@ -312,6 +482,67 @@ describe('findRanges', () => {
             range1,
         ])
     })
+
+    it('should order ranges with the same start by their end', () => {
+        const range1 = {
+            startLine: 1,
+            startCharacter: 3,
+            endLine: 3,
+            endCharacter: 5,
+            monikerIds: new Set<sqliteModels.MonikerId>(),
+        }
+        const range2 = {
+            startLine: 1,
+            startCharacter: 3,
+            endLine: 1,
+            endCharacter: 8,
+            monikerIds: new Set<sqliteModels.MonikerId>(),
+        }
+        const range3 = {
+            startLine: 1,
+            startCharacter: 3,
+            endLine: 1,
+            endCharacter: 8,
+            monikerIds: new Set<sqliteModels.MonikerId>(),
+            definitionResultId: 'x',
+        }
+
+        // Identical ranges keep their relative order
+        expect(findRanges([range1, range2, range3], { line: 1, character: 4 })).toEqual([range2, range3, range1])
+        expect(findRanges([range3, range1, range2], { line: 1, character: 4 })).toEqual([range3, range2, range1])
+    })
+
+    it('should order overlapping ranges by containment depth', () => {
+        const outer = {
+            startLine: 0,
+            startCharacter: 0,
+            endLine: 10,
+            endCharacter: 0,
+            monikerIds: new Set<sqliteModels.MonikerId>(),
+        }
+        const overlapping = {
+            startLine: 5,
+            startCharacter: 0,
+            endLine: 20,
+            endCharacter: 0,
+            monikerIds: new Set<sqliteModels.MonikerId>(),
+        }
+        const nested = {
+            startLine: 1,
+            startCharacter: 0,
+            endLine: 9,
+            endCharacter: 0,
+            monikerIds: new Set<sqliteModels.MonikerId>(),
+        }
+
+        for (const ranges of [
+            [outer, overlapping, nested],
+            [nested, outer, overlapping],
+            [overlapping, nested, outer],
+        ]) {
+            expect(findRanges(ranges, { line: 7, character: 0 })).toEqual([nested, overlapping, outer])
+        }
+    })
 })
 
 describe('comparePosition', () => {
@@ -376,3 +607,68 @@ describe('mapRangesToInternalLocations', () => {
         expect(locations).toHaveLength(3)
     })
 })
+
+describe('symbolsFromTags', () => {
+    const createTaggedRange = (
+        text: string,
+        kind: number,
+        startLine: number,
+        endLine: number
+    ): sqliteModels.RangeData => ({
+        startLine,
+        startCharacter: 4,
+        endLine: startLine,
+        endCharacter: 4 + text.length,
+        monikerIds: new Set<sqliteModels.MonikerId>(),
+        tag: {
+            text,
+            kind,
+            fullRange: { start: { line: startLine, character: 0 }, end: { line: endLine, character: 1 } },
+        },
+    })
+
+    it('should nest symbols by full range', () => {
+        const symbols = symbolsFromTags([
+            createTaggedRange('Foo', 5, 1, 10),
+            createTaggedRange('bar', 6, 2, 4),
+            createTaggedRange('baz', 6, 5, 9),
+            createTaggedRange('x', 13, 6, 6),
+            createTaggedRange('main', 12, 12, 14),
+        ])
+
+        expect(symbols.map(({ name, container }) => ({ name, container }))).toEqual([
+            { name: 'Foo', container: null },
+            { name: 'bar', container: 'Foo' },
+            { name: 'baz', container: 'Foo' },
+            { name: 'x', container: 'baz' },
+            { name: 'main', container: null },
+        ])
+    })
+
+    it('should not depend on the order of the ranges', () => {
+        const symbols = symbolsFromTags([
+            createTaggedRange('x', 13, 6, 6),
+            createTaggedRange('main', 12, 12, 14),
+            createTaggedRange('baz', 6, 5, 9),
+            createTaggedRange('Foo', 5, 1, 10),
+        ])
+
+        expect(symbols.map(({ name, container }) => ({ name, container }))).toEqual([
+            { name: 'x', container: 'baz' },
+            { name: 'main', container: null },
+            { name: 'baz', container: 'Foo' },
+            { name: 'Foo', container: null },
+        ])
+    })
+})
+
+describe('parseMonikerIdentifier', () => {
+    it('should split the container and name', () => {
+        expect(parseMonikerIdentifier('github.com/sourcegraph/lsif-go/protocol:Foo.Bar')).toEqual({
+            name: 'Bar',
+            container: 'Foo',
+        })
+        expect(parseMonikerIdentifier('src/index:a.b.c')).toEqual({ name: 'c', container: 'a.b' })
+        expect(parseMonikerIdentifier('main')).toEqual({ name: 'main', container: null })
+    })
+})
@@ -3,21 +3,77 @@ import * as sqliteModels from '../../shared/models/sqlite'
 import * as lsp from 'vscode-languageserver-protocol'
 import * as metrics from '../metrics'
 import * as pgModels from '../../shared/models/pg'
-import { Connection } from 'typeorm'
+import { Connection, EntityNotFoundError } from 'typeorm'
 import { DefaultMap } from '../../shared/datastructures/default-map'
-import { gunzipJSON } from '../../shared/encoding/json'
+import { decodePayload, PayloadEncoding } from '../../shared/encoding/payload'
 import { hashKey } from '../../shared/models/hash'
 import { instrument } from '../../shared/metrics'
 import { logSpan, TracingContext, logAndTraceCall, addTags } from '../../shared/tracing'
 import { mustGet } from '../../shared/maps'
 import { Logger } from 'winston'
 import { createSilentLogger } from '../../shared/logging'
-import { InternalLocation, OrderedLocationSet } from './location'
+import { InternalLocation } from './location'
 import * as settings from '../settings'
+import { isDefined } from '../../shared/util'
+import { BundleManagerStats } from '../../shared/stats'
+import { BundleVerification } from '../../shared/verification'
+import { readPayloadEncoding, readSchemaVersion } from './migrations'
+import { slicePage } from '../../shared/api/pagination/slice'
+import { StatementCache } from '../../shared/database/statements'
+import { RangeIndex, sortEnclosingRanges } from './ranges'
 
 /** The maximum number of results in a logSpan value. */
 const MAX_SPAN_ARRAY_LENGTH = 20
 
+/** A row of a definitions or references table read by `Database.monikerResults`. */
+type MonikerResultRow = Pick<
+    sqliteModels.DefinitionModel,
+    'documentPath' | 'startLine' | 'startCharacter' | 'endLine' | 'endCharacter'
+>
+
+/** The query that reads the encoded data of a document by path. */
+const DOCUMENT_QUERY = 'SELECT data FROM documents WHERE path = ?'
+
+/** The query that reads the encoded data of a result chunk by identifier. */
+const RESULT_CHUNK_QUERY = 'SELECT data FROM resultChunks WHERE id = ?'
+
+/**
+ * Create the queries that read a page of the rows of a definitions or references table
+ * matching a moniker, and that count all such rows.
+ *
+ * @param table The name of the table.
+ */
+function createMonikerQueries(table: string): { page: string; count: string } {
+    return {
+        page: `
+            SELECT documentPath, startLine, startCharacter, endLine, endCharacter FROM "${table}"
+            WHERE scheme = ? AND identifier = ?
+            ORDER BY documentPath, startLine, startCharacter, id
+            LIMIT ? OFFSET ?
+        `,
+        count: `SELECT COUNT(*) AS count FROM "${table}" WHERE scheme = ? AND identifier = ?`,
+    }
+}
+
+/** The queries that read the definitions matching a moniker. */
+const DEFINITION_QUERIES = createMonikerQueries('definitions')
+
+/** The queries that read the references matching a moniker. */
+const REFERENCE_QUERIES = createMonikerQueries('references')
+
+/**
+ * The queries run on most requests. These are prepared once when a connection to a bundle
+ * is opened and reused by every request instead of being parsed and planned each time.
+ */
+const PINNED_QUERIES = [
+    DOCUMENT_QUERY,
+    RESULT_CHUNK_QUERY,
+    DEFINITION_QUERIES.page,
+    DEFINITION_QUERIES.count,
+    REFERENCE_QUERIES.page,
+    REFERENCE_QUERIES.count,
+]
+
 /** A wrapper around operations related to a single SQLite dump. */
 export class Database {
     /**
@@ -25,9 +81,41 @@ export class Database {
      * metadata row. This map is populated lazily as the values are needed.
      */
     private static numResultChunks = new Map<string, number>()
-    private static connectionCache = new cache.ConnectionCache(settings.CONNECTION_CACHE_CAPACITY)
-    private static documentCache = new cache.DocumentCache(settings.DOCUMENT_CACHE_CAPACITY)
-    private static resultChunkCache = new cache.ResultChunkCache(settings.RESULT_CHUNK_CACHE_CAPACITY)
+
+    /**
+     * A static map of database paths to the encoding of their document and result chunk
+     * payloads. This map is populated lazily as the values are needed.
+     */
+    private static payloadEncodings = new Map<string, PayloadEncoding>()
+
+    /**
+     * A static map of decoded documents to an index of their ranges. An index is built when
+     * a document is decoded and is retained for as long as the document is cached.
+     */
+    private static rangeIndexes = new WeakMap<sqliteModels.DocumentData, RangeIndex>()
+    private static connectionCache = new cache.ConnectionCache(
+        settings.CONNECTION_CACHE_CAPACITY,
+        {
+            // Bundles are never modified once converted
+            readOnly: true,
+            mmapSizeBytes: settings.SQLITE_MMAP_SIZE_BYTES,
+            cacheSizeKiB: settings.SQLITE_CACHE_SIZE_KIB,
+            busyTimeoutMs: settings.SQLITE_BUSY_TIMEOUT_MS,
+            maxOpen: settings.SQLITE_MAX_OPEN_CONNECTIONS,
+            maxIdle: settings.SQLITE_MAX_IDLE_CONNECTIONS,
+            statementCacheSize: settings.SQLITE_STATEMENT_CACHE_SIZE,
+            pinnedQueries: PINNED_QUERIES,
+        },
+        settings.CACHE_ENTRY_TTL
+    )
+    private static documentCache = new cache.DocumentCache(
+        settings.DOCUMENT_CACHE_MEMORY_BUDGET_BYTES,
+        settings.CACHE_ENTRY_TTL
+    )
+    private static resultChunkCache = new cache.ResultChunkCache(
+        settings.RESULT_CHUNK_CACHE_MEMORY_BUDGET_BYTES,
+        settings.CACHE_ENTRY_TTL
+    )
 
     /**
      * Create a new `Database` with the given dump record, and the SQLite file
@@ -38,6 +126,48 @@ export class Database {
      */
     constructor(private dumpId: pgModels.DumpId, private databasePath: string) {}
 
+    /**
+     * Close all cached SQLite connections and drop all cached documents and result chunks.
+     * This waits for in-flight queries using a cached connection to complete.
+     */
+    public static async closeAll(): Promise<void> {
+        await Promise.all([
+            Database.connectionCache.flush(),
+            Database.documentCache.flush(),
+            Database.resultChunkCache.flush(),
+        ])
+        Database.numResultChunks.clear()
+        Database.payloadEncodings.clear()
+    }
+
+    /**
+     * Close the cached SQLite connection and drop all cached documents and result chunks
+     * of the database at the given path. This must be called when a database file is
+     * replaced so that queries do not read data from the previous file. This waits for
+     * in-flight queries using the cached connection to complete.
+     *
+     * @param databasePath The path to the database file.
+     */
+    public static async invalidate(databasePath: string): Promise<void> {
+        const prefix = `${databasePath}::`
+        await Promise.all([
+            Database.connectionCache.bustKey(databasePath),
+            Database.documentCache.bustKeys(key => key.startsWith(prefix)),
+            Database.resultChunkCache.bustKeys(key => key.startsWith(prefix)),
+        ])
+        Database.numResultChunks.delete(databasePath)
+        Database.payloadEncodings.delete(databasePath)
+    }
+
+    /** Return the occupancy of each of the shared in-memory caches. */
+    public static cacheOccupancy(): BundleManagerStats['caches'] {
+        return {
+            connections: Database.connectionCache.occupancy(),
+            documents: Database.documentCache.occupancy(),
+            resultChunks: Database.resultChunkCache.occupancy(),
+        }
+    }
+
     /**
      * Determine if data exists for a particular document in this database.
      *
@@ -53,21 +183,76 @@ export class Database {
     }
 
     /**
-     * Return a list of locations that define the symbol at the given position.
+     * Open this database and read its metadata so that the first query against it does not
+     * pay for opening the database. The given documents and the result chunks referred to
+     * by their ranges are decoded into the document and result chunk caches. Documents that
+     * do not exist are skipped. Warming more data than fits the memory budgets of the caches
+     * evicts the data warmed first.
+     *
+     * @param paths The paths of the documents to decode.
+     * @param ctx The tracing context.
+     */
+    public warm(paths: string[] = [], ctx: TracingContext = {}): Promise<void> {
+        return this.logAndTraceCall(ctx, 'Warming up database', async ctx => {
+            const numResultChunks = await this.getNumResultChunks(ctx)
+            await this.getPayloadEncoding(ctx)
+
+            const indexes = new Set<number>()
+            for (const path of paths) {
+                const document = await this.getDocumentByPath(path, ctx)
+                if (!document) {
+                    continue
+                }
+
+                for (const range of document.ranges.values()) {
+                    const { definitionResultId, referenceResultId, implementationResultId } = range
+                    for (const id of [definitionResultId, referenceResultId, implementationResultId]) {
+                        if (id !== undefined) {
+                            indexes.add(hashKey(id, numResultChunks))
+                        }
+                    }
+                }
+            }
+
+            for (const index of indexes) {
+                await this.getResultChunk(index, ctx)
+            }
+
+            this.logSpan(ctx, 'warmed', { documents: paths.length, resultChunks: indexes.size })
+        })
+    }
+
+    /**
+     * Return the schema version of this database.
+     *
+     * @param ctx The tracing context.
+     */
+    public schemaVersion(ctx: TracingContext = {}): Promise<number> {
+        return this.logAndTraceCall(ctx, 'Reading schema version', ctx =>
+            this.withConnection(connection => readSchemaVersion(connection.manager), ctx.logger)
+        )
+    }
+
+    /**
+     * Return a page of the locations that define the symbol at the given position, along with
+     * the total number of such locations. Only the documents that contain a location of the
+     * page are read.
      *
      * @param path The path of the document to which the position belongs.
      * @param position The current hover position.
+     * @param pagination A limit and offset to use for the query.
      * @param ctx The tracing context.
      */
     public async definitions(
         path: string,
         position: lsp.Position,
+        pagination: { skip?: number; take?: number } = {},
         ctx: TracingContext = {}
-    ): Promise<InternalLocation[]> {
+    ): Promise<{ locations: InternalLocation[]; count: number }> {
         return this.logAndTraceCall(ctx, 'Fetching definitions', async ctx => {
             const { document, ranges } = await this.getRangeByPosition(path, position, ctx)
             if (!document || ranges.length === 0) {
-                return []
+                return { locations: [], count: 0 }
             }
 
             for (const range of ranges) {
@@ -82,32 +267,37 @@ export class Database {
                     numDefinitionResults: definitionResults.length,
                 })
 
-                return this.convertRangesToInternalLocations(path, document, definitionResults)
+                return this.convertPageToInternalLocations(path, document, uniqueResults(definitionResults), pagination)
             }
 
-            return []
+            return { locations: [], count: 0 }
         })
     }
 
     /**
-     * Return a list of unique locations that reference the symbol at the given position.
+     * Return a page of the unique locations that reference the symbol at the given position,
+     * along with the total number of such locations. Locations are deduplicated before the
+     * page is taken, so consecutive pages neither skip nor repeat a location. Only the
+     * documents that contain a location of the page are read.
      *
      * @param path The path of the document to which the position belongs.
      * @param position The current hover position.
+     * @param pagination A limit and offset to use for the query.
      * @param ctx The tracing context.
      */
     public async references(
         path: string,
         position: lsp.Position,
+        pagination: { skip?: number; take?: number } = {},
         ctx: TracingContext = {}
-    ): Promise<OrderedLocationSet> {
+    ): Promise<{ locations: InternalLocation[]; count: number }> {
         return this.logAndTraceCall(ctx, 'Fetching references', async ctx => {
             const { document, ranges } = await this.getRangeByPosition(path, position, ctx)
             if (!document || ranges.length === 0) {
-                return new OrderedLocationSet()
+                return { locations: [], count: 0 }
             }
 
-            const locationSet = new OrderedLocationSet()
+            let resultData: sqliteModels.DocumentPathRangeId[] = []
             for (const range of ranges) {
                 if (range.referenceResultId) {
                     const referenceResults = await this.getResultById(range.referenceResultId)
@@ -117,19 +307,48 @@ export class Database {
                         numReferenceResults: referenceResults.length,
                     })
 
-                    if (referenceResults.length > 0) {
-                        for (const location of await this.convertRangesToInternalLocations(
-                            path,
-                            document,
-                            referenceResults
-                        )) {
-                            locationSet.push(location)
-                        }
-                    }
+                    resultData = resultData.concat(referenceResults)
+                }
+            }
+
+            return this.convertPageToInternalLocations(path, document, uniqueResults(resultData), pagination)
+        })
+    }
+
+    /**
+     * Return a list of locations that implement the symbol at the given position.
+     *
+     * @param path The path of the document to which the position belongs.
+     * @param position The current hover position.
+     * @param ctx The tracing context.
+     */
+    public async implementations(
+        path: string,
+        position: lsp.Position,
+        ctx: TracingContext = {}
+    ): Promise<InternalLocation[]> {
+        return this.logAndTraceCall(ctx, 'Fetching implementations', async ctx => {
+            const { document, ranges } = await this.getRangeByPosition(path, position, ctx)
+            if (!document || ranges.length === 0) {
+                return []
+            }
+
+            for (const range of ranges) {
+                if (!range.implementationResultId) {
+                    continue
                 }
+
+                const implementationResults = await this.getResultById(range.implementationResultId)
+                this.logSpan(ctx, 'implementation_results', {
+                    implementationResultId: range.implementationResultId,
+                    implementationResults: implementationResults.slice(0, MAX_SPAN_ARRAY_LENGTH),
+                    numImplementationResults: implementationResults.length,
+                })
+
+                return this.convertRangesToInternalLocations(path, document, implementationResults)
             }
 
-            return locationSet
+            return []
         })
     }
 
@@ -151,23 +370,71 @@ export class Database {
                 return null
             }
 
-            for (const range of ranges) {
-                if (!range.hoverResultId) {
-                    continue
-                }
+            return this.hoverFromRanges(document, ranges, ctx)
+        })
+    }
 
-                this.logSpan(ctx, 'hover_result', { hoverResultId: range.hoverResultId })
+    /**
+     * Return the hover content for the symbols at each of the given positions. The document
+     * is fetched and decoded only once for the entire batch. The resulting list is aligned
+     * with the input positions: an entry is null if there is no hover data at that position.
+     *
+     * @param path The path of the document to which the positions belong.
+     * @param positions The hover positions.
+     * @param ctx The tracing context.
+     */
+    public async hovers(
+        path: string,
+        positions: lsp.Position[],
+        ctx: TracingContext = {}
+    ): Promise<({ text: string; range: lsp.Range } | null)[]> {
+        return this.logAndTraceCall(ctx, 'Fetching hovers', async ctx => {
+            const document = await this.getDocumentByPath(path, ctx)
+            if (!document) {
+                return positions.map(() => null)
+            }
 
-                // Extract text
-                const text = mustGet(document.hoverResults, range.hoverResultId, 'hoverResult')
+            return positions.map(position =>
+                this.hoverFromRanges(document, Database.rangesAtPosition(document, position), ctx)
+            )
+        })
+    }
 
-                // Return first defined hover result for the inner-most range. This response
-                // includes the entire range so that the highlighted portion in the UI can be
-                // accurate (rather than approximated by the tokenizer).
-                return { text, range: createRange(range) }
+    /**
+     * Return a summary of the code intelligence available for each range of a document that
+     * intersects the given (inclusive) window of lines, ordered by position. This lets clients
+     * prefetch data for an entire viewport in one request rather than querying each position.
+     *
+     * @param path The path of the document.
+     * @param startLine The first line of the window (0-indexed, inclusive).
+     * @param endLine The last line of the window (0-indexed, inclusive).
+     * @param ctx The tracing context.
+     */
+    public async ranges(
+        path: string,
+        startLine: number,
+        endLine: number,
+        ctx: TracingContext = {}
+    ): Promise<sqliteModels.RangeSummaryData[]> {
+        return this.logAndTraceCall(ctx, 'Fetching ranges', async ctx => {
+            const document = await this.getDocumentByPath(path, ctx)
+            if (!document) {
+                return []
             }
 
-            return null
+            const ranges = Array.from(document.ranges.values())
+                .filter(range => range.startLine <= endLine && range.endLine >= startLine)
+                .sort((a, b) => a.startLine - b.startLine || a.startCharacter - b.startCharacter)
+
+            this.logSpan(ctx, 'ranges', { numRanges: ranges.length })
+
+            return ranges.map(range => ({
+                range: createRange(range),
+                hasDefinitions: range.definitionResultId !== undefined,
+                hasReferences: range.referenceResultId !== undefined,
+                hasImplementations: range.implementationResultId !== undefined,
+                hasHover: range.hoverResultId !== undefined,
+            }))
         })
     }
 
@@ -198,7 +465,8 @@ export class Database {
     /**
      * Query the definitions or references table of `db` for items that match the given moniker.
      * Convert each result into an `InternalLocation`. The `pathTransformer` function is invoked
-     * on each result item to modify the resulting locations.
+     * on each result item to modify the resulting locations. Results are ordered by path and
+     * position so that consecutive pages neither skip nor repeat a location.
      *
      * @param model The constructor for the model type.
      * @param moniker The target moniker.
@@ -212,19 +480,15 @@ export class Database {
         ctx: TracingContext = {}
     ): Promise<{ locations: InternalLocation[]; count: number }> {
         return this.logAndTraceCall(ctx, 'Fetching moniker results', async ctx => {
-            const [results, count] = await this.withConnection(
-                connection =>
-                    connection
-                        .getRepository<sqliteModels.DefinitionModel | sqliteModels.ReferenceModel>(model)
-                        .findAndCount({
-                            where: {
-                                scheme: moniker.scheme,
-                                identifier: moniker.identifier,
-                            },
-                            ...pagination,
-                        }),
-                ctx.logger
-            )
+            const queries = model === sqliteModels.DefinitionModel ? DEFINITION_QUERIES : REFERENCE_QUERIES
+            const { skip = 0, take = -1 } = pagination
+            const parameters = [moniker.scheme, moniker.identifier]
+
+            const [results, count] = await this.withStatements(async statements => {
+                const rows = await statements.all<MonikerResultRow>(queries.page, [...parameters, take, skip])
+                const countRow = await statements.get<{ count: number }>(queries.count, parameters)
+                return [rows, countRow ? countRow.count : 0] as [MonikerResultRow[], number]
+            }, ctx.logger)
 
             this.logSpan(ctx, 'symbol_results', {
                 moniker,
@@ -265,9 +529,265 @@ export class Database {
         )
     }
 
+    /**
+     * Return the symbol outline of a document, ordered by position. Ranges tagged by the
+     * indexer as a declaration or definition provide the symbol name, kind, and full range,
+     * and the container of a symbol is the inner-most tagged symbol that encloses it. If no
+     * ranges of the document are tagged, the outline is built from the ranges that define a
+     * symbol with a non-local moniker, and the name and container are parsed from the moniker
+     * identifier.
+     *
+     * @param path The path of the document.
+     * @param ctx The tracing context.
+     */
+    public async documentSymbols(path: string, ctx: TracingContext = {}): Promise<sqliteModels.DocumentSymbolData[]> {
+        return this.logAndTraceCall(ctx, 'Fetching document symbols', async ctx => {
+            const document = await this.getDocumentByPath(path, ctx)
+            if (!document) {
+                return []
+            }
+
+            const taggedRanges = Array.from(document.ranges.values()).filter(range => range.tag !== undefined)
+            const symbols =
+                taggedRanges.length > 0 ? symbolsFromTags(taggedRanges) : await this.symbolsFromMonikers(path, document)
+
+            this.logSpan(ctx, 'document_symbols', {
+                symbols: symbols.slice(0, MAX_SPAN_ARRAY_LENGTH),
+                numSymbols: symbols.length,
+            })
+
+            return symbols.sort((a, b) => comparePositions(a.range.start, b.range.start))
+        })
+    }
+
+    /**
+     * Return the diagnostics reported by the indexer for this dump. If a path is supplied,
+     * only the diagnostics of that document are returned. Diagnostics are ordered by path
+     * and then by position.
+     *
+     * @param path The path of the document, if diagnostics should be restricted to one document.
+     * @param pagination A limit and offset to use for the query.
+     * @param ctx The tracing context.
+     */
+    public diagnostics(
+        path: string | undefined,
+        pagination: { skip?: number; take?: number },
+        ctx: TracingContext = {}
+    ): Promise<{ diagnostics: sqliteModels.DiagnosticData[]; count: number }> {
+        return this.logAndTraceCall(ctx, 'Fetching diagnostics', async ctx => {
+            const [results, count] = await this.withConnection(async connection => {
+                try {
+                    return await connection.getRepository(sqliteModels.DiagnosticModel).findAndCount({
+                        where: path === undefined ? {} : { documentPath: path },
+                        order: { documentPath: 'ASC', startLine: 'ASC', startCharacter: 'ASC', id: 'ASC' },
+                        ...pagination,
+                    })
+                } catch (error) {
+                    // Bundles converted before diagnostics were stored have no diagnostics table
+                    if (isMissingTableError(error)) {
+                        return [[], 0] as [sqliteModels.DiagnosticModel[], number]
+                    }
+
+                    throw error
+                }
+            }, ctx.logger)
+
+            this.logSpan(ctx, 'diagnostic_results', {
+                path,
+                results: results.slice(0, MAX_SPAN_ARRAY_LENGTH),
+                numResults: results.length,
+            })
+
+            const diagnostics = results.map(result => ({
+                path: result.documentPath,
+                range: createRange(result),
+                severity: result.severity,
+                code: result.code,
+                message: result.message,
+                source: result.source,
+            }))
+
+            return { diagnostics, count }
+        })
+    }
+
+    /**
+     * Check the integrity of this database. This runs the SQLite integrity check, validates
+     * the meta row and the identifiers of the result chunks against it, and decodes a random
+     * sample of documents and result chunks. The sampled rows are read from disk rather than
+     * from the shared caches. Failed checks are reported as problems instead of thrown, so
+     * that the caller can flag a corrupted bundle.
+     *
+     * @param sampleSize The maximum number of documents and of result chunks to decode.
+     * @param ctx The tracing context.
+     */
+    public verify(sampleSize: number, ctx: TracingContext = {}): Promise<BundleVerification> {
+        return this.logAndTraceCall(ctx, 'Verifying bundle', async ctx => {
+            const problems: string[] = []
+            let numSampledDocuments = 0
+            let numSampledResultChunks = 0
+
+            try {
+                await this.withConnection(async connection => {
+                    const integrity: { integrity_check: string }[] = await connection.query('PRAGMA integrity_check')
+                    for (const { integrity_check: message } of integrity) {
+                        if (message !== 'ok') {
+                            problems.push(`Integrity check failed: ${message}`)
+                        }
+                    }
+
+                    const metas = await connection.getRepository(sqliteModels.MetaModel).find()
+                    if (metas.length !== 1) {
+                        problems.push(`Expected one meta row, found ${metas.length}`)
+                        return
+                    }
+
+                    const { numResultChunks } = metas[0]
+                    if (!Number.isInteger(numResultChunks) || numResultChunks < 1) {
+                        problems.push(`Invalid number of result chunks ${numResultChunks}`)
+                        return
+                    }
+
+                    // Empty result chunks are not stored, so there may be fewer chunks than were
+                    // allocated, but no chunk may lie outside of the allocated range
+                    const [{ count, minId, maxId }]: { count: number; minId: number; maxId: number }[] =
+                        await connection.query(
+                            'SELECT COUNT(*) AS count, MIN(id) AS minId, MAX(id) AS maxId FROM resultChunks'
+                        )
+                    if (count > numResultChunks || minId < 0 || maxId >= numResultChunks) {
+                        problems.push(
+                            `Expected at most ${numResultChunks} result chunks, ` +
+                                `found ${count} with identifiers ${minId} to ${maxId}`
+                        )
+                    }
+
+                    const payloadEncoding = await readPayloadEncoding(connection.manager)
+
+                    const documents: { path: string; data: Buffer }[] = await connection.query(
+                        'SELECT path, data FROM documents ORDER BY random() LIMIT ?',
+                        [sampleSize]
+                    )
+                    for (const { path, data } of documents) {
+                        numSampledDocuments++
+                        try {
+                            const document = await decodePayload<sqliteModels.DocumentData>(data, payloadEncoding)
+                            if (!(document.ranges instanceof Map)) {
+                                throw new Error('missing ranges')
+                            }
+                        } catch (error) {
+                            problems.push(`Malformed document ${path}: ${String(error.message)}`)
+                        }
+                    }
+
+                    const resultChunks: { id: number; data: Buffer }[] = await connection.query(
+                        'SELECT id, data FROM resultChunks ORDER BY random() LIMIT ?',
+                        [sampleSize]
+                    )
+                    for (const { id, data } of resultChunks) {
+                        numSampledResultChunks++
+                        try {
+                            const resultChunk = await decodePayload<sqliteModels.ResultChunkData>(
+                                data,
+                                payloadEncoding
+                            )
+                            if (!(resultChunk.documentIdRangeIds instanceof Map)) {
+                                throw new Error('missing results')
+                            }
+
+                            // Each result must be stored in the chunk its identifier hashes to,
+                            // otherwise it can never be found
+                            for (const resultId of resultChunk.documentIdRangeIds.keys()) {
+                                if (hashKey(resultId, numResultChunks) !== id) {
+                                    throw new Error(`result ${resultId} is stored in the wrong chunk`)
+                                }
+                            }
+                        } catch (error) {
+                            problems.push(`Malformed result chunk ${id}: ${String(error.message)}`)
+                        }
+                    }
+                }, ctx.logger)
+            } catch (error) {
+                // The file may not be a database at all
+                problems.push(String(error.message))
+            }
+
+            this.logSpan(ctx, 'verification', {
+                problems: problems.slice(0, MAX_SPAN_ARRAY_LENGTH),
+                numProblems: problems.length,
+            })
+
+            return { valid: problems.length === 0, problems, numSampledDocuments, numSampledResultChunks }
+        })
+    }
+
     //
     // Helper Functions
 
+    /**
+     * Return the hover content of the inner-most range that has hover data attached. Returns
+     * null if none of the given ranges have hover data.
+     *
+     * @param document The document containing the ranges.
+     * @param ranges The ranges containing the hover position, ordered from inner-most to outer-most.
+     * @param ctx The tracing context.
+     */
+    private hoverFromRanges(
+        document: sqliteModels.DocumentData,
+        ranges: sqliteModels.RangeData[],
+        ctx: TracingContext
+    ): { text: string; range: lsp.Range } | null {
+        for (const range of ranges) {
+            if (!range.hoverResultId) {
+                continue
+            }
+
+            this.logSpan(ctx, 'hover_result', { hoverResultId: range.hoverResultId })
+
+            // Extract text
+            const text = mustGet(document.hoverResults, range.hoverResultId, 'hoverResult')
+
+            // Return first defined hover result for the inner-most range. This response
+            // includes the entire range so that the highlighted portion in the UI can be
+            // accurate (rather than approximated by the tokenizer).
+            return { text, range: createRange(range) }
+        }
+
+        return null
+    }
+
+    /**
+     * Create a symbol for each range of the document that is one of its own definitions
+     * and has a non-local moniker attached.
+     *
+     * @param path The path of the document.
+     * @param document The document object.
+     */
+    private async symbolsFromMonikers(
+        path: string,
+        document: sqliteModels.DocumentData
+    ): Promise<sqliteModels.DocumentSymbolData[]> {
+        const symbols: sqliteModels.DocumentSymbolData[] = []
+        for (const [id, range] of document.ranges) {
+            if (range.definitionResultId === undefined || range.monikerIds.size === 0) {
+                continue
+            }
+
+            const definitionResults = await this.getResultById(range.definitionResultId)
+            const isDefinition = definitionResults.some(
+                ({ documentPath, rangeId }) => documentPath === path && `${rangeId}` === `${id}`
+            )
+            if (!isDefinition) {
+                continue
+            }
+
+            const monikerId = range.monikerIds.values().next().value
+            const { identifier } = mustGet(document.monikers, monikerId, 'moniker')
+            symbols.push({ ...parseMonikerIdentifier(identifier), kind: null, range: createRange(range) })
+        }
+
+        return symbols
+    }
+
     /**
      * Return a parsed document that describes the given path. The result of this
      * method is cached across all database instances. If the document is not found
@@ -280,21 +800,27 @@ export class Database {
         path: string,
         ctx: TracingContext = {}
     ): Promise<sqliteModels.DocumentData | undefined> {
-        const factory = async (): Promise<cache.EncodedJsonCacheValue<sqliteModels.DocumentData>> => {
-            const document = await this.withConnection(
-                connection => connection.getRepository(sqliteModels.DocumentModel).findOneOrFail(path),
+        const factory = async (): Promise<sqliteModels.DocumentData> => {
+            const document = await this.withStatements(
+                statements =>
+                    statements.get<Pick<sqliteModels.DocumentModel, 'data'>>(DOCUMENT_QUERY, [path]),
                 ctx.logger
             )
-
-            return {
-                size: document.data.length,
-                data: await gunzipJSON<sqliteModels.DocumentData>(document.data),
+            if (!document) {
+                throw new EntityNotFoundError(sqliteModels.DocumentModel, path)
             }
+
+            const data = await decodePayload<sqliteModels.DocumentData>(
+                document.data,
+                await this.getPayloadEncoding(ctx)
+            )
+            Database.rangeIndexes.set(data, new RangeIndex(data.ranges.values()))
+            return data
         }
 
         try {
             return await Database.documentCache.withValue(`${this.databasePath}::${path}`, factory, document =>
-                Promise.resolve(document.data)
+                Promise.resolve(document)
             )
         } catch (error) {
             if (error.name === 'EntityNotFound') {
@@ -305,6 +831,26 @@ export class Database {
         }
     }
 
+    /**
+     * Return the ranges of the given document that contain the given position, inner-most
+     * ranges first. This uses the range index built when the document was decoded.
+     *
+     * @param document The decoded document.
+     * @param position The user's hover position.
+     */
+    private static rangesAtPosition(
+        document: sqliteModels.DocumentData,
+        position: lsp.Position
+    ): sqliteModels.RangeData[] {
+        let index = Database.rangeIndexes.get(document)
+        if (!index) {
+            index = new RangeIndex(document.ranges.values())
+            Database.rangeIndexes.set(document, index)
+        }
+
+        return index.find(position)
+    }
+
     /**
      * Return a parsed document that describes the given path as well as the ranges
      * from that document that contains the given position. If multiple ranges are
@@ -325,12 +871,35 @@ export class Database {
                 return { document: undefined, ranges: [] }
             }
 
-            const ranges = findRanges(document.ranges.values(), position)
+            const ranges = Database.rangesAtPosition(document, position)
             this.logSpan(ctx, 'matching_ranges', { ranges: cleanRanges(ranges) })
             return { document, ranges }
         })
     }
 
+    /**
+     * Convert a page of the given range-document pairs into `InternalLocation` objects and
+     * return them along with the total number of pairs. The pairs outside of the page are
+     * only counted, so the documents that contain them are never read.
+     *
+     * @param path The path of the document for this query.
+     * @param document The document object for this query.
+     * @param resultData A list of unique range ids and the document they belong to.
+     * @param pagination A limit and offset to use for the query.
+     */
+    private async convertPageToInternalLocations(
+        path: string,
+        document: sqliteModels.DocumentData,
+        resultData: sqliteModels.DocumentPathRangeId[],
+        { skip = 0, take = resultData.length }: { skip?: number; take?: number }
+    ): Promise<{ locations: InternalLocation[]; count: number }> {
+        const { page } = slicePage(resultData, skip, take)
+        return {
+            locations: await this.convertRangesToInternalLocations(path, document, page),
+            count: resultData.length,
+        }
+    }
+
     /**
      * Convert a set of range-document pairs (from a definition or reference query) into
      * a set of `InternalLocation` object. Each pair holds the range identifier as well as
@@ -380,7 +949,7 @@ export class Database {
      * document paths by looking into the result chunks table and parsing the
      * data associated with the given identifier.
      *
-     * @param id The identifier of the definition or reference result.
+     * @param id The identifier of the definition, reference, or implementation result.
      */
     private async getResultById(
         id: sqliteModels.DefinitionReferenceResultId
@@ -405,22 +974,31 @@ export class Database {
         ctx: TracingContext = {}
     ): Promise<sqliteModels.ResultChunkData> {
         // Find the result chunk index this id belongs to
-        const index = hashKey(id, await this.getNumResultChunks())
+        return this.getResultChunk(hashKey(id, await this.getNumResultChunks()), ctx)
+    }
 
-        const factory = async (): Promise<cache.EncodedJsonCacheValue<sqliteModels.ResultChunkData>> => {
-            const resultChunk = await this.withConnection(
-                connection => connection.getRepository(sqliteModels.ResultChunkModel).findOneOrFail(index),
+    /**
+     * Return the parsed result chunk with the given index.
+     *
+     * @param index The index of the result chunk.
+     * @param ctx The tracing context.
+     */
+    private getResultChunk(index: number, ctx: TracingContext = {}): Promise<sqliteModels.ResultChunkData> {
+        const factory = async (): Promise<sqliteModels.ResultChunkData> => {
+            const resultChunk = await this.withStatements(
+                statements =>
+                    statements.get<Pick<sqliteModels.ResultChunkModel, 'data'>>(RESULT_CHUNK_QUERY, [index]),
                 ctx.logger
             )
-
-            return {
-                size: resultChunk.data.length,
-                data: await gunzipJSON<sqliteModels.ResultChunkData>(resultChunk.data),
+            if (!resultChunk) {
+                throw new EntityNotFoundError(sqliteModels.ResultChunkModel, index)
             }
+
+            return decodePayload<sqliteModels.ResultChunkData>(resultChunk.data, await this.getPayloadEncoding(ctx))
         }
 
         return Database.resultChunkCache.withValue(`${this.databasePath}::${index}`, factory, resultChunk =>
-            Promise.resolve(resultChunk.data)
+            Promise.resolve(resultChunk)
         )
     }
 
@@ -444,6 +1022,23 @@ export class Database {
         return meta.numResultChunks
     }
 
+    /**
+     * Get the encoding of the document and result chunk payloads of this database.
+     *
+     * @param ctx The tracing context.
+     */
+    private async getPayloadEncoding(ctx: TracingContext = {}): Promise<PayloadEncoding> {
+        const payloadEncoding = Database.payloadEncodings.get(this.databasePath)
+        if (payloadEncoding !== undefined) {
+            return payloadEncoding
+        }
+
+        // Not in the shared map, need to query it
+        const encoding = await this.withConnection(connection => readPayloadEncoding(connection.manager), ctx.logger)
+        Database.payloadEncodings.set(this.databasePath, encoding)
+        return encoding
+    }
+
     /**
      * Invoke `callback` with a SQLite connection object obtained from the
      * cache or created on cache miss.
@@ -462,6 +1057,24 @@ export class Database {
         )
     }
 
+    /**
+     * Invoke `callback` with the prepared statements of a SQLite connection
+     * obtained from the cache or created on cache miss.
+     *
+     * @param callback The function invoke with the prepared statements.
+     * @param logger The logger instance.
+     */
+    private withStatements<T>(
+        callback: (statements: StatementCache) => Promise<T>,
+        logger: Logger = createSilentLogger()
+    ): Promise<T> {
+        return Database.connectionCache.withHandle(this.databasePath, sqliteModels.entities, logger, ({ statements }) =>
+            instrument(metrics.databaseQueryDurationHistogram, metrics.databaseQueryErrorsCounter, () =>
+                callback(statements)
+            )
+        )
+    }
+
     /**
      * Log and trace the execution of a function.
      *
@@ -485,6 +1098,36 @@ export class Database {
     }
 }
 
+/**
+ * Determine if the given error was caused by querying a table that does not exist in
+ * a bundle. Bundles are opened read-only, so tables introduced after a bundle has been
+ * converted are not created on demand.
+ *
+ * @param error The error thrown by the query.
+ */
+function isMissingTableError(error: unknown): boolean {
+    return error instanceof Error && error.message.includes('no such table')
+}
+
+/**
+ * Return the unique range-document pairs of the given list in the order in which
+ * `convertRangesToInternalLocations` returns their locations: grouped by document path
+ * in the order of the first occurrence of each path. Pages taken from this list are
+ * converted into consecutive slices of the full list of locations.
+ *
+ * @param resultData A list of range ids and the document they belong to.
+ */
+function uniqueResults(resultData: sqliteModels.DocumentPathRangeId[]): sqliteModels.DocumentPathRangeId[] {
+    const groupedResults = new DefaultMap<string, Set<sqliteModels.RangeId>>(() => new Set())
+    for (const { documentPath, rangeId } of resultData) {
+        groupedResults.getOrDefault(documentPath).add(rangeId)
+    }
+
+    return Array.from(groupedResults).flatMap(([documentPath, rangeIds]) =>
+        Array.from(rangeIds, rangeId => ({ documentPath, rangeId }))
+    )
+}
+
 /**
  * Return the set of ranges that contain the given position. If multiple ranges
  * are returned, then the inner-most ranges will occur before the outer-most
@@ -501,15 +1144,88 @@ export function findRanges(ranges: Iterable<sqliteModels.RangeData>, position: l
         }
     }
 
-    return filtered.sort((a, b) => {
-        if (comparePosition(a, { line: b.startLine, character: b.startCharacter }) === 0) {
-            return +1
+    return sortEnclosingRanges(filtered)
+}
+
+/**
+ * Create a symbol for each of the given tagged ranges. The container of each symbol is the
+ * inner-most symbol whose full range strictly encloses it. The full ranges of the symbols
+ * of a document are assumed to nest, so the containers are found in a single sweep over the
+ * symbols ordered by position, keeping a stack of the symbols that are still open.
+ *
+ * @param ranges The ranges with a declaration or definition tag.
+ */
+export function symbolsFromTags(ranges: sqliteModels.RangeData[]): sqliteModels.DocumentSymbolData[] {
+    const tags = ranges.map(({ tag }) => tag).filter(isDefined)
+
+    // Order outer symbols before the symbols they enclose
+    const ordered = Array.from(tags).sort(
+        (a, b) =>
+            comparePositions(a.fullRange.start, b.fullRange.start) || comparePositions(b.fullRange.end, a.fullRange.end)
+    )
+
+    const containers = new Map<sqliteModels.SymbolTagData, sqliteModels.SymbolTagData>()
+    const open: sqliteModels.SymbolTagData[] = []
+    for (const tag of ordered) {
+        while (open.length > 0 && !containsRange(open[open.length - 1].fullRange, tag.fullRange)) {
+            open.pop()
         }
 
-        return -1
+        // Symbols with an identical full range do not contain one another
+        for (let i = open.length - 1; i >= 0; i--) {
+            if (!containsRange(tag.fullRange, open[i].fullRange)) {
+                containers.set(tag, open[i])
+                break
+            }
+        }
+
+        open.push(tag)
+    }
+
+    return tags.map(tag => {
+        const container = containers.get(tag)
+        return { name: tag.text, kind: tag.kind, range: tag.fullRange, container: container ? container.text : null }
     })
 }
 
+/**
+ * Split a moniker identifier into a symbol name and the name of its container. Any package
+ * or module prefix (delimited by a colon) is discarded, and the remainder is split on its
+ * final dot.
+ *
+ * @param identifier The moniker identifier.
+ */
+export function parseMonikerIdentifier(identifier: string): { name: string; container: string | null } {
+    const qualifiedName = identifier.slice(identifier.lastIndexOf(':') + 1)
+    const index = qualifiedName.lastIndexOf('.')
+    if (index < 0) {
+        return { name: qualifiedName, container: null }
+    }
+
+    return { name: qualifiedName.slice(index + 1), container: qualifiedName.slice(0, index) }
+}
+
+/**
+ * Determine if the outer range encloses the inner range.
+ *
+ * @param outer The outer range.
+ * @param inner The inner range.
+ */
+function containsRange(outer: lsp.Range, inner: lsp.Range): boolean {
+    return comparePositions(outer.start, inner.start) <= 0 && comparePositions(inner.end, outer.end) <= 0
+}
+
+/**
+ * Compare two positions. Returns a negative number if the first position occurs
+ * before the second, a positive number if it occurs after, and zero if they are equal.
+ *
+ * @param a The first position.
+ * @param b The second position.
+ */
+function comparePositions(a: lsp.Position, b: lsp.Position): number {
+    return a.line - b.line || a.character - b.character
+}
+
 /**
  * Compare a position against a range. Returns 0 if the position occurs
  * within the range (inclusive bounds), -1 if the position occurs after
@@ -0,0 +1,30 @@
+import { estimateSize } from './footprint'
+
+describe('estimateSize', () => {
+    it('should grow with the size of strings', () => {
+        expect(estimateSize('a'.repeat(100))).toBeGreaterThan(estimateSize('a'.repeat(10)))
+        expect(estimateSize('a'.repeat(100)) - estimateSize('a'.repeat(10))).toEqual(180)
+    })
+
+    it('should count nested values', () => {
+        const range = { startLine: 1, startCharacter: 2, endLine: 3, endCharacter: 4, monikerIds: new Set([1, 2]) }
+        const document = {
+            ranges: new Map([[1, range]]),
+            hoverResults: new Map([[2, 'hover text']]),
+            monikers: new Map(),
+            packageInformation: new Map(),
+        }
+
+        expect(estimateSize(document)).toBeGreaterThan(
+            estimateSize(range) + estimateSize(range.monikerIds) + estimateSize('hover text')
+        )
+        expect(estimateSize({ ...document, ranges: new Map([[1, range], [2, range]]) })).toEqual(
+            estimateSize(document) + estimateSize(2) + estimateSize(range) + 24
+        )
+    })
+
+    it('should not count values stored directly in their container', () => {
+        expect(estimateSize({ a: true, b: undefined, c: null })).toEqual(estimateSize({}) + 24)
+        expect(estimateSize([true, false])).toEqual(estimateSize([]) + 16)
+    })
+})
@@ -0,0 +1,79 @@
+// Rough per-value costs (in bytes) of the V8 heap representation of decoded values. These
+// do not need to be exact: they only need to grow proportionally with the memory retained
+// by a value so that caches of decoded values can be bounded by a memory budget.
+
+/** The size of a pointer or an unboxed value stored in an object, array, or collection. */
+const SLOT_SIZE = 8
+
+/** The size of a boxed number. */
+const NUMBER_SIZE = 16
+
+/** The size of a string header. Each character is counted as two bytes. */
+const STRING_OVERHEAD = 16
+
+/** The size of an object header (map, properties, and elements pointers). */
+const OBJECT_OVERHEAD = 24
+
+/** The size of a Map or Set header, including its backing hash table. */
+const COLLECTION_OVERHEAD = 64
+
+/** The additional size of each entry in the hash table of a Map or Set (the chain pointer). */
+const COLLECTION_ENTRY_OVERHEAD = 8
+
+/**
+ * Estimate the number of bytes retained by the given value once it has been decoded into
+ * memory. This walks maps, sets, arrays, and plain objects, so it is intended for values
+ * like `DocumentData` and `ResultChunkData` that are decoded from JSON and do not contain
+ * cycles. Object keys are not counted as they are generally interned.
+ *
+ * @param value The decoded value.
+ */
+export function estimateSize(value: unknown): number {
+    switch (typeof value) {
+        case 'number':
+            return NUMBER_SIZE
+
+        case 'string':
+            return STRING_OVERHEAD + 2 * value.length
+
+        case 'object': {
+            if (value === null) {
+                return 0
+            }
+
+            if (value instanceof Map) {
+                let size = COLLECTION_OVERHEAD
+                for (const [k, v] of value) {
+                    size += 2 * SLOT_SIZE + COLLECTION_ENTRY_OVERHEAD + estimateSize(k) + estimateSize(v)
+                }
+                return size
+            }
+
+            if (value instanceof Set) {
+                let size = COLLECTION_OVERHEAD
+                for (const v of value) {
+                    size += SLOT_SIZE + COLLECTION_ENTRY_OVERHEAD + estimateSize(v)
+                }
+                return size
+            }
+
+            if (Array.isArray(value)) {
+                let size = OBJECT_OVERHEAD
+                for (const v of value) {
+                    size += SLOT_SIZE + estimateSize(v)
+                }
+                return size
+            }
+
+            let size = OBJECT_OVERHEAD
+            for (const v of Object.values(value as object)) {
+                size += SLOT_SIZE + estimateSize(v)
+            }
+            return size
+        }
+
+        default:
+            // Booleans and undefined are stored directly in the slot of their container
+            return 0
+    }
+}
@@ -0,0 +1,89 @@
+import * as fs from 'mz/fs'
+import * as nodepath from 'path'
+import * as uuid from 'uuid'
+import rmfr from 'rmfr'
+import { createSqliteConnection } from '../../shared/database/sqlite'
+import { createSilentLogger } from '../../shared/logging'
+import { CURRENT_SCHEMA_VERSION } from '../../shared/models/sqlite'
+import { migrateBundle, migrations, MigrationTracker, readPayloadEncoding, readSchemaVersion } from './migrations'
+
+describe('migrations', () => {
+    it('should be ordered and end at the current schema version', () => {
+        const versions = migrations.map(migration => migration.version)
+        expect(versions).toEqual([...versions].sort((a, b) => a - b))
+        expect(versions[versions.length - 1]).toEqual(CURRENT_SCHEMA_VERSION)
+    })
+})
+
+describe('migrateBundle', () => {
+    let storageRoot!: string
+
+    beforeAll(async () => {
+        storageRoot = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterAll(async () => {
+        if (storageRoot) {
+            await rmfr(storageRoot)
+        }
+    })
+
+    it('should migrate a bundle written without a schema version', async () => {
+        const filename = nodepath.join(storageRoot, uuid.v4())
+        const connection = await createSqliteConnection(filename, [], createSilentLogger())
+        try {
+            await connection.query(
+                'CREATE TABLE "meta" ("id" integer PRIMARY KEY NOT NULL, "lsifVersion" text NOT NULL, ' +
+                    '"sourcegraphVersion" text NOT NULL, "numResultChunks" integer NOT NULL)'
+            )
+            await connection.query('INSERT INTO "meta" VALUES (1, \'0.4.3\', \'0.1.0\', 4)')
+            expect(await readSchemaVersion(connection.manager)).toEqual(1)
+            expect(await readPayloadEncoding(connection.manager)).toEqual('gzip-json')
+        } finally {
+            await connection.close()
+        }
+
+        expect(await migrateBundle(filename)).toEqual(true)
+        expect((await fs.readdir(storageRoot)).filter(basename => basename.endsWith('.tmp'))).toEqual([])
+
+        const migrated = await createSqliteConnection(filename, [], createSilentLogger(), { readOnly: true })
+        try {
+            expect(await readSchemaVersion(migrated.manager)).toEqual(CURRENT_SCHEMA_VERSION)
+            expect(await readPayloadEncoding(migrated.manager)).toEqual('gzip-json')
+            expect(await migrated.query('SELECT "numResultChunks" FROM "meta"')).toEqual([{ numResultChunks: 4 }])
+            expect(await migrated.query('SELECT COUNT(*) AS count FROM "diagnostics"')).toEqual([{ count: 0 }])
+        } finally {
+            await migrated.close()
+        }
+    })
+})
+
+describe('MigrationTracker', () => {
+    it('should report progress', () => {
+        const tracker = new MigrationTracker()
+        tracker.recordVersion('1.lsif.db', CURRENT_SCHEMA_VERSION)
+        tracker.recordVersion('2.lsif.db', 1)
+        tracker.recordFailure('3.lsif.db', 1)
+        tracker.recordMigration('4.lsif.db')
+        tracker.retain(['1.lsif.db', '2.lsif.db', '3.lsif.db', '4.lsif.db'])
+
+        expect(tracker.isCurrent('1.lsif.db')).toEqual(true)
+        expect(tracker.isCurrent('2.lsif.db')).toEqual(false)
+        expect(tracker.progress()).toEqual({
+            currentVersion: CURRENT_SCHEMA_VERSION,
+            numCurrent: 2,
+            numOutdated: 2,
+            numMigrated: 1,
+            numFailed: 1,
+        })
+
+        tracker.retain(['1.lsif.db'])
+        expect(tracker.progress()).toEqual({
+            currentVersion: CURRENT_SCHEMA_VERSION,
+            numCurrent: 1,
+            numOutdated: 0,
+            numMigrated: 1,
+            numFailed: 0,
+        })
+    })
+})
@@ -0,0 +1,269 @@
+import * as fs from 'mz/fs'
+import * as sqliteModels from '../../shared/models/sqlite'
+import * as uuid from 'uuid'
+import { EntityManager } from 'typeorm'
+import { Stats } from 'fs'
+import { Logger } from 'winston'
+import { createSqliteConnection } from '../../shared/database/sqlite'
+import { createSilentLogger } from '../../shared/logging'
+import { BundleMigrationProgress } from '../../shared/stats'
+import { DEFAULT_PAYLOAD_ENCODING, parsePayloadEncoding, PayloadEncoding } from '../../shared/encoding/payload'
+
+/** A change to the schema of a bundle that brings it from the previous version to `version`. */
+export interface BundleMigration {
+    /** The schema version of a bundle once this migration has been applied. */
+    version: number
+
+    /** A short description of the change. */
+    description: string
+
+    /**
+     * Apply the change to a bundle. This is invoked within the transaction that also records
+     * the new schema version of the bundle.
+     *
+     * @param entityManager A transactional SQLite entity manager.
+     */
+    up(entityManager: EntityManager): Promise<void>
+}
+
+/**
+ * The migrations of the bundle schema, ordered by version. The version of the last
+ * migration must equal `CURRENT_SCHEMA_VERSION`.
+ */
+export const migrations: BundleMigration[] = [
+    {
+        version: 2,
+        description: 'Record the schema version and add the diagnostics table to bundles that lack it',
+        up: async entityManager => {
+            await entityManager.query('ALTER TABLE "meta" ADD COLUMN "schemaVersion" integer NOT NULL DEFAULT 1')
+
+            // Same name that typeorm generates for the index of `DiagnosticModel`
+            await entityManager.query(
+                [
+                    'CREATE TABLE IF NOT EXISTS "diagnostics" (',
+                    '"id" integer PRIMARY KEY NOT NULL, "documentPath" text NOT NULL, "severity" integer,',
+                    '"code" text, "message" text NOT NULL, "source" text, "startLine" integer NOT NULL,',
+                    '"endLine" integer NOT NULL, "startCharacter" integer NOT NULL, "endCharacter" integer NOT NULL)',
+                ].join(' ')
+            )
+            await entityManager.query(
+                'CREATE INDEX IF NOT EXISTS "IDX_be2e7e4370edd444f852a00a56" ON "diagnostics" ("documentPath")'
+            )
+        },
+    },
+    {
+        version: 3,
+        description: 'Record the encoding of document and result chunk payloads',
+        up: async entityManager => {
+            await entityManager.query(
+                `ALTER TABLE "meta" ADD COLUMN "payloadEncoding" text NOT NULL DEFAULT '${DEFAULT_PAYLOAD_ENCODING}'`
+            )
+        },
+    },
+]
+
+/**
+ * Return the schema version of the bundle open on the given connection. Bundles written
+ * before schema versions were recorded are at version 1.
+ *
+ * @param entityManager The SQLite entity manager.
+ */
+export async function readSchemaVersion(entityManager: EntityManager): Promise<number> {
+    const columns: { name: string }[] = await entityManager.query('PRAGMA table_info("meta")')
+    if (!columns.some(({ name }) => name === 'schemaVersion')) {
+        return 1
+    }
+
+    const rows: { schemaVersion: number }[] = await entityManager.query('SELECT "schemaVersion" FROM "meta"')
+    if (rows.length !== 1) {
+        throw new Error(`Expected one meta row, found ${rows.length}`)
+    }
+
+    return rows[0].schemaVersion
+}
+
+/**
+ * Return the encoding of the document and result chunk payloads of the bundle open on the
+ * given connection. Bundles written before the encoding was recorded use the default one.
+ *
+ * @param entityManager The SQLite entity manager.
+ */
+export async function readPayloadEncoding(entityManager: EntityManager): Promise<PayloadEncoding> {
+    const columns: { name: string }[] = await entityManager.query('PRAGMA table_info("meta")')
+    if (!columns.some(({ name }) => name === 'payloadEncoding')) {
+        return DEFAULT_PAYLOAD_ENCODING
+    }
+
+    const rows: { payloadEncoding: string }[] = await entityManager.query('SELECT "payloadEncoding" FROM "meta"')
+    if (rows.length !== 1) {
+        throw new Error(`Expected one meta row, found ${rows.length}`)
+    }
+
+    return parsePayloadEncoding(rows[0].payloadEncoding)
+}
+
+/**
+ * Bring the bundle at the given path up to `CURRENT_SCHEMA_VERSION`. The bundle is copied
+ * to a temporary file in the same directory, the pending migrations are applied to the copy
+ * in a single transaction, and the copy is then renamed over the original so that readers
+ * never observe a partially migrated bundle.
+ *
+ * The copy is discarded if the original is replaced or removed while it is migrated, e.g.
+ * by a rebalance, so that a stale bundle is never written back. Returns true if the bundle
+ * was replaced by its migrated copy. The caller must invalidate cached data of the bundle
+ * after a replacement.
+ *
+ * @param filename The path of the bundle.
+ * @param logger The logger instance.
+ */
+export async function migrateBundle(filename: string, logger: Logger = createSilentLogger()): Promise<boolean> {
+    const before = await fs.stat(filename)
+    const tempFilename = `${filename}.${uuid.v4()}.tmp`
+
+    try {
+        await fs.copyFile(filename, tempFilename)
+
+        // No entities are given so that the schema is not synchronized on open
+        const connection = await createSqliteConnection(tempFilename, [], logger)
+        try {
+            await connection.transaction(async entityManager => {
+                const version = await readSchemaVersion(entityManager)
+                for (const migration of migrations.filter(migration => migration.version > version)) {
+                    logger.debug('Migrating bundle', { filename, version: migration.version })
+                    await migration.up(entityManager)
+                }
+
+                await entityManager.query('UPDATE "meta" SET "schemaVersion" = ?', [
+                    sqliteModels.CURRENT_SCHEMA_VERSION,
+                ])
+            })
+        } finally {
+            await connection.close()
+        }
+
+        if (!(await isUnchanged(filename, before))) {
+            logger.debug('Discarding migration of a bundle replaced during the migration', { filename })
+            await fs.unlink(tempFilename)
+            return false
+        }
+
+        await fs.rename(tempFilename, filename)
+        return true
+    } catch (error) {
+        await fs.unlink(tempFilename).catch(() => {
+            /* noop */
+        })
+
+        throw error
+    }
+}
+
+/**
+ * Determine if the file at the given path is still the file described by the given stats.
+ *
+ * @param filename The path of the file.
+ * @param before The stats of the file taken earlier.
+ */
+async function isUnchanged(filename: string, before: Stats): Promise<boolean> {
+    try {
+        const after = await fs.stat(filename)
+        return after.ino === before.ino && after.mtimeMs === before.mtimeMs && after.size === before.size
+    } catch (error) {
+        if (error && error.code === 'ENOENT') {
+            return false
+        }
+
+        throw error
+    }
+}
+
+/**
+ * Tracks the schema version of each bundle seen by the background migration, so that the
+ * progress of a migration can be reported and bundles that are up to date are not opened
+ * again on the next run.
+ */
+export class MigrationTracker {
+    /** The last known schema version of each bundle, indexed by bundle key. */
+    private versions = new Map<string, number>()
+
+    /** The keys of bundles whose last migration attempt failed. */
+    private failed = new Set<string>()
+
+    /** The number of bundles migrated since startup. */
+    private numMigrated = 0
+
+    /**
+     * Forget about bundles that are no longer in the store.
+     *
+     * @param keys The keys of all bundles in the store.
+     */
+    public retain(keys: string[]): void {
+        const retained = new Set(keys)
+        for (const key of this.versions.keys()) {
+            if (!retained.has(key)) {
+                this.versions.delete(key)
+            }
+        }
+        for (const key of this.failed) {
+            if (!retained.has(key)) {
+                this.failed.delete(key)
+            }
+        }
+    }
+
+    /**
+     * Determine if the given bundle is known to be at the current schema version.
+     *
+     * @param key The bundle key.
+     */
+    public isCurrent(key: string): boolean {
+        return this.versions.get(key) === sqliteModels.CURRENT_SCHEMA_VERSION
+    }
+
+    /**
+     * Record the schema version of a bundle that was checked but not migrated.
+     *
+     * @param key The bundle key.
+     * @param version The schema version of the bundle.
+     */
+    public recordVersion(key: string, version: number): void {
+        this.versions.set(key, version)
+    }
+
+    /**
+     * Record a successful migration of a bundle.
+     *
+     * @param key The bundle key.
+     */
+    public recordMigration(key: string): void {
+        this.versions.set(key, sqliteModels.CURRENT_SCHEMA_VERSION)
+        this.failed.delete(key)
+        this.numMigrated++
+    }
+
+    /**
+     * Record a failed migration of a bundle at the given schema version.
+     *
+     * @param key The bundle key.
+     * @param version The schema version of the bundle.
+     */
+    public recordFailure(key: string, version: number): void {
+        this.versions.set(key, version)
+        this.failed.add(key)
+    }
+
+    /** Return the progress of the migration. */
+    public progress(): BundleMigrationProgress {
+        const versions = Array.from(this.versions.values())
+        return {
+            currentVersion: sqliteModels.CURRENT_SCHEMA_VERSION,
+            numCurrent: versions.filter(version => version === sqliteModels.CURRENT_SCHEMA_VERSION).length,
+            numOutdated: versions.filter(version => version < sqliteModels.CURRENT_SCHEMA_VERSION).length,
+            numMigrated: this.numMigrated,
+            numFailed: this.failed.size,
+        }
+    }
+}
+
+/** The migration progress of the bundles of this bundle manager. */
+export const migrationTracker = new MigrationTracker()
@@ -0,0 +1,88 @@
+import * as sinon from 'sinon'
+import promClient from 'prom-client'
+import { createBarrierPromise } from './cache'
+import { ConnectionPool } from './pool'
+
+describe('ConnectionPool', () => {
+    const testMetrics = {
+        openGauge: new promClient.Gauge({ name: 'test_pool_open', help: 'test_pool_open' }),
+        idleGauge: new promClient.Gauge({ name: 'test_pool_idle', help: 'test_pool_idle' }),
+        waitsCounter: new promClient.Counter({ name: 'test_pool_waits_total', help: 'test_pool_waits_total' }),
+        waitDurationHistogram: new promClient.Histogram({
+            name: 'test_pool_wait_duration_seconds',
+            help: 'test_pool_wait_duration_seconds',
+        }),
+    }
+
+    const createPool = (maxOpen: number, maxIdle: number) => {
+        let next = 0
+        const dispose = sinon.spy()
+        const pool = new ConnectionPool<number>(() => Promise.resolve(next++), dispose, { maxOpen, maxIdle }, testMetrics)
+        return { pool, dispose }
+    }
+
+    it('should reuse released connections', async () => {
+        const { pool, dispose } = createPool(2, 1)
+
+        expect(await pool.withConnection(c => Promise.resolve(c))).toEqual(0)
+        expect(await pool.withConnection(c => Promise.resolve(c))).toEqual(0)
+        expect(pool.stats()).toEqual({ open: 1, idle: 1, waiting: 0 })
+        expect(dispose.called).toBeFalsy()
+    })
+
+    it('should open connections up to the limit and queue further requests', async () => {
+        const { pool } = createPool(2, 2)
+        const { wait, done } = createBarrierPromise()
+
+        const seen: number[] = []
+        const use = (connection: number) => {
+            seen.push(connection)
+            return wait
+        }
+
+        const requests = [pool.withConnection(use), pool.withConnection(use), pool.withConnection(use)]
+        await new Promise(resolve => setTimeout(resolve, 0))
+        expect(seen).toEqual([0, 1])
+        expect(pool.stats()).toEqual({ open: 2, idle: 0, waiting: 1 })
+
+        done()
+        await Promise.all(requests)
+        expect(seen).toEqual([0, 1, 0])
+        expect(pool.stats()).toEqual({ open: 2, idle: 2, waiting: 0 })
+    })
+
+    it('should close released connections beyond the idle limit', async () => {
+        const { pool, dispose } = createPool(2, 1)
+        const { wait, done } = createBarrierPromise()
+
+        const requests = [pool.withConnection(() => wait), pool.withConnection(() => wait)]
+        done()
+        await Promise.all(requests)
+
+        expect(dispose.callCount).toEqual(1)
+        expect(pool.stats()).toEqual({ open: 1, idle: 1, waiting: 0 })
+    })
+
+    it('should let a waiting request open a connection after a failed open', async () => {
+        let calls = 0
+        const factory = () => (calls++ === 0 ? Promise.reject(new Error('oops')) : Promise.resolve(calls))
+        const pool = new ConnectionPool<number>(factory, () => undefined, { maxOpen: 1, maxIdle: 1 }, testMetrics)
+
+        const first = pool.withConnection(c => Promise.resolve(c))
+        const second = pool.withConnection(c => Promise.resolve(c))
+
+        await expect(first).rejects.toThrowError('oops')
+        expect(await second).toEqual(2)
+        expect(pool.stats()).toEqual({ open: 1, idle: 1, waiting: 0 })
+    })
+
+    it('should close idle connections and reject requests once closed', async () => {
+        const { pool, dispose } = createPool(1, 1)
+        await pool.withConnection(() => Promise.resolve())
+
+        await pool.close()
+        expect(dispose.callCount).toEqual(1)
+        expect(pool.stats()).toEqual({ open: 0, idle: 0, waiting: 0 })
+        await expect(pool.withConnection(() => Promise.resolve())).rejects.toThrowError('Connection pool is closed')
+    })
+})
@@ -0,0 +1,204 @@
+import promClient from 'prom-client'
+
+/** Options that control the number of connections held by a `ConnectionPool`. */
+export interface ConnectionPoolOptions {
+    /** The maximum number of connections open at once. */
+    maxOpen: number
+
+    /** The maximum number of unused connections kept open. */
+    maxIdle: number
+}
+
+/**
+ * A bag of prometheus metric objects shared by every instance of `ConnectionPool`.
+ * The gauges are adjusted relative to their current value so that they report the
+ * total over all pools.
+ */
+export interface ConnectionPoolMetrics {
+    /** A metric counting the connections currently open. */
+    openGauge: promClient.Gauge<string>
+
+    /** A metric counting the open connections that are not in use. */
+    idleGauge: promClient.Gauge<string>
+
+    /** A metric incremented each time a request waits for a connection to be released. */
+    waitsCounter: promClient.Counter<string>
+
+    /** A metric observing the time spent waiting for a connection to be released. */
+    waitDurationHistogram: promClient.Histogram<string>
+}
+
+/** The current number of connections held by a `ConnectionPool`. */
+export interface ConnectionPoolStats {
+    /** The number of open connections. */
+    open: number
+
+    /** The number of open connections that are not in use. */
+    idle: number
+
+    /** The number of requests waiting for a connection to be released. */
+    waiting: number
+}
+
+/** A request waiting for a connection to be released. */
+interface Waiter<C> {
+    resolve: (connection: C) => void
+    reject: (error: Error) => void
+}
+
+/**
+ * A pool of connections to the same database. At most `maxOpen` connections are open at
+ * once, and requests beyond that wait for a connection to be released. Released connections
+ * are kept open for the next request unless `maxIdle` connections are already unused, in
+ * which case they are closed.
+ */
+export class ConnectionPool<C> {
+    /** The open connections that are not in use, most recently released last. */
+    private idle: C[] = []
+
+    /** The requests waiting for a connection to be released, oldest first. */
+    private waiters: Waiter<C>[] = []
+
+    /** The number of open (or opening) connections. */
+    private open = 0
+
+    /** Whether or not the pool has been closed. */
+    private closed = false
+
+    /**
+     * Create a new `ConnectionPool`.
+     *
+     * @param factory The function used to open a new connection.
+     * @param disposeFunction The function used to close a connection.
+     * @param options The options that control the size of the pool.
+     * @param poolMetrics The bag of metrics to use for this pool.
+     */
+    constructor(
+        private factory: () => Promise<C>,
+        private disposeFunction: (connection: C) => Promise<void> | void,
+        private options: ConnectionPoolOptions,
+        private poolMetrics: ConnectionPoolMetrics
+    ) {}
+
+    /** Return the current number of connections held by the pool. */
+    public stats(): ConnectionPoolStats {
+        return { open: this.open, idle: this.idle.length, waiting: this.waiters.length }
+    }
+
+    /**
+     * Invoke `callback` with a connection from the pool. The connection is not used by any
+     * other caller until the callback completes.
+     *
+     * @param callback The function to invoke with the connection.
+     */
+    public async withConnection<T>(callback: (connection: C) => Promise<T>): Promise<T> {
+        const connection = await this.acquire()
+        try {
+            return await callback(connection)
+        } finally {
+            await this.release(connection)
+        }
+    }
+
+    /**
+     * Close the unused connections of the pool and reject the waiting requests. Connections
+     * that are in use are closed once released.
+     */
+    public async close(): Promise<void> {
+        this.closed = true
+
+        for (const { reject } of this.waiters.splice(0)) {
+            reject(new Error('Connection pool is closed'))
+        }
+
+        await Promise.all(this.idle.splice(0).map(connection => this.dispose(connection, true)))
+    }
+
+    /**
+     * Return an unused connection, opening a new one if fewer than `maxOpen` connections are
+     * open. Otherwise, wait for a connection to be released.
+     */
+    private async acquire(): Promise<C> {
+        if (this.closed) {
+            throw new Error('Connection pool is closed')
+        }
+
+        const connection = this.idle.pop()
+        if (connection !== undefined) {
+            this.poolMetrics.idleGauge.dec()
+            return connection
+        }
+
+        if (this.open < Math.max(1, this.options.maxOpen)) {
+            return this.openConnection()
+        }
+
+        this.poolMetrics.waitsCounter.inc()
+        const end = this.poolMetrics.waitDurationHistogram.startTimer()
+        try {
+            return await new Promise<C>((resolve, reject) => this.waiters.push({ resolve, reject }))
+        } finally {
+            end()
+        }
+    }
+
+    /** Open a new connection and count it against `maxOpen`. */
+    private async openConnection(): Promise<C> {
+        this.open++
+        this.poolMetrics.openGauge.inc()
+
+        try {
+            return await this.factory()
+        } catch (error) {
+            this.open--
+            this.poolMetrics.openGauge.dec()
+
+            // The slot reserved for this connection is free again. Let the oldest waiting
+            // request try to open its own connection so that it does not wait forever.
+            const waiter = this.waiters.shift()
+            if (waiter) {
+                this.openConnection().then(waiter.resolve, waiter.reject)
+            }
+
+            throw error
+        }
+    }
+
+    /**
+     * Hand the connection to the oldest waiting request, return it to the set of unused
+     * connections, or close it if `maxIdle` connections are already unused.
+     *
+     * @param connection The released connection.
+     */
+    private async release(connection: C): Promise<void> {
+        const waiter = this.waiters.shift()
+        if (waiter) {
+            waiter.resolve(connection)
+            return
+        }
+
+        if (!this.closed && this.idle.length < this.options.maxIdle) {
+            this.idle.push(connection)
+            this.poolMetrics.idleGauge.inc()
+            return
+        }
+
+        await this.dispose(connection, false)
+    }
+
+    /**
+     * Close the given connection and stop counting it against `maxOpen`.
+     *
+     * @param connection The connection.
+     * @param idle Whether or not the connection was counted as unused.
+     */
+    private async dispose(connection: C, idle: boolean): Promise<void> {
+        this.open--
+        this.poolMetrics.openGauge.dec()
+        if (idle) {
+            this.poolMetrics.idleGauge.dec()
+        }
+
+        await this.disposeFunction(connection)
+    }
+}
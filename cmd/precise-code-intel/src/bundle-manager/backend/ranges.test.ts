@@ -0,0 +1,85 @@
+import * as sqliteModels from '../../shared/models/sqlite'
+import { comparePosition } from './database'
+import { RangeIndex, sortEnclosingRanges } from './ranges'
+
+const createRange = (
+    startLine: number,
+    startCharacter: number,
+    endLine: number,
+    endCharacter: number
+): sqliteModels.RangeData => ({
+    startLine,
+    startCharacter,
+    endLine,
+    endCharacter,
+    monikerIds: new Set<sqliteModels.MonikerId>(),
+})
+
+describe('RangeIndex', () => {
+    const encloses = (outer: sqliteModels.RangeData, inner: sqliteModels.RangeData): boolean =>
+        comparePosition(outer, { line: inner.startLine, character: inner.startCharacter }) === 0 &&
+        comparePosition(outer, { line: inner.endLine, character: inner.endCharacter }) === 0
+
+    // A deterministic pseudo-random number generator so that failures are reproducible
+    const createRandom = (seed: number) => (n: number): number => {
+        seed = (seed * 16807) % 2147483647
+        return seed % n
+    }
+
+    it('should find nested ranges inner-most first', () => {
+        const outer = createRange(0, 0, 10, 0)
+        const middle = createRange(2, 0, 8, 5)
+        const inner = createRange(4, 3, 4, 9)
+        const other = createRange(12, 0, 12, 4)
+        const index = new RangeIndex([other, outer, inner, middle])
+
+        expect(index.size).toEqual(4)
+        expect(index.find({ line: 4, character: 5 })).toEqual([inner, middle, outer])
+        expect(index.find({ line: 4, character: 9 })).toEqual([inner, middle, outer])
+        expect(index.find({ line: 9, character: 0 })).toEqual([outer])
+        expect(index.find({ line: 12, character: 4 })).toEqual([other])
+        expect(index.find({ line: 11, character: 0 })).toEqual([])
+    })
+
+    it('should find the same ranges as a linear scan', () => {
+        const random = createRandom(42)
+
+        for (let i = 0; i < 200; i++) {
+            const ranges: sqliteModels.RangeData[] = []
+            for (let j = random(50); j > 0; j--) {
+                const startLine = random(20)
+                const startCharacter = random(10)
+                const endLine = startLine + (random(3) === 0 ? random(10) : 0)
+                const endCharacter = endLine === startLine ? startCharacter + random(10) : random(10)
+                ranges.push(createRange(startLine, startCharacter, endLine, endCharacter))
+            }
+
+            const index = new RangeIndex(ranges)
+            for (let j = 0; j < 20; j++) {
+                const position = { line: random(32), character: random(22) }
+                const expected = ranges.filter(range => comparePosition(range, position) === 0)
+                const actual = index.find(position)
+
+                expect(new Set(actual)).toEqual(new Set(expected))
+                expect(actual).toHaveLength(expected.length)
+
+                // No range may be enclosed by a different range preceding it
+                for (let k = 1; k < actual.length; k++) {
+                    for (const outer of actual.slice(0, k)) {
+                        expect(encloses(outer, actual[k]) && !encloses(actual[k], outer)).toBeFalsy()
+                    }
+                }
+            }
+        }
+    })
+})
+
+describe('sortEnclosingRanges', () => {
+    it('should order ranges with the same start by their end', () => {
+        const range1 = createRange(1, 0, 3, 0)
+        const range2 = createRange(1, 0, 1, 9)
+        const range3 = createRange(1, 0, 2, 0)
+
+        expect(sortEnclosingRanges([range1, range2, range3])).toEqual([range2, range3, range1])
+    })
+})
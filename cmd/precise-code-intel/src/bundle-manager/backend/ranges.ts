@@ -0,0 +1,173 @@
+import * as sqliteModels from '../../shared/models/sqlite'
+import * as lsp from 'vscode-languageserver-protocol'
+
+/**
+ * An index of the ranges of a document that finds the ranges containing a position in
+ * O(log n + k) time rather than by scanning every range of the document.
+ *
+ * The ranges are sorted by their start position and form an implicit balanced binary
+ * search tree: the root of the subtree over the slice `[lo, hi)` is the range at the
+ * midpoint of the slice. Each node records the greatest end position of all ranges in
+ * its subtree, so that subtrees whose ranges all end before the position are skipped,
+ * as are subtrees whose ranges all start after it.
+ */
+export class RangeIndex {
+    /** The ranges sorted by their start position. */
+    private ranges: sqliteModels.RangeData[]
+
+    /** The greatest end line of the ranges in the subtree rooted at each index. */
+    private maxEndLines: number[]
+
+    /** The greatest end character (on the greatest end line) in the subtree rooted at each index. */
+    private maxEndCharacters: number[]
+
+    /**
+     * Create a new `RangeIndex`.
+     *
+     * @param ranges The ranges of the document.
+     */
+    constructor(ranges: Iterable<sqliteModels.RangeData>) {
+        this.ranges = Array.from(ranges).sort(
+            (a, b) =>
+                a.startLine - b.startLine ||
+                a.startCharacter - b.startCharacter ||
+                a.endLine - b.endLine ||
+                a.endCharacter - b.endCharacter
+        )
+        this.maxEndLines = new Array(this.ranges.length)
+        this.maxEndCharacters = new Array(this.ranges.length)
+        this.computeMaxEnds(0, this.ranges.length)
+    }
+
+    /** The number of indexed ranges. */
+    public get size(): number {
+        return this.ranges.length
+    }
+
+    /**
+     * Return the ranges that contain the given position (inclusive bounds). If multiple
+     * ranges are returned, then the inner-most ranges will occur before the outer-most
+     * ranges.
+     *
+     * @param position The user's hover position.
+     */
+    public find(position: lsp.Position): sqliteModels.RangeData[] {
+        const ranges: sqliteModels.RangeData[] = []
+        this.collect(0, this.ranges.length, position, ranges)
+        return sortEnclosingRanges(ranges)
+    }
+
+    /**
+     * Record the greatest end position of the subtree over the slice `[lo, hi)` at the
+     * index of its root. Returns the index of the root, or -1 if the slice is empty.
+     *
+     * @param lo The first index of the slice.
+     * @param hi The index following the last index of the slice.
+     */
+    private computeMaxEnds(lo: number, hi: number): number {
+        if (lo >= hi) {
+            return -1
+        }
+
+        const mid = (lo + hi) >>> 1
+        let { endLine, endCharacter } = this.ranges[mid]
+        for (const child of [this.computeMaxEnds(lo, mid), this.computeMaxEnds(mid + 1, hi)]) {
+            if (
+                child >= 0 &&
+                (this.maxEndLines[child] > endLine ||
+                    (this.maxEndLines[child] === endLine && this.maxEndCharacters[child] > endCharacter))
+            ) {
+                endLine = this.maxEndLines[child]
+                endCharacter = this.maxEndCharacters[child]
+            }
+        }
+
+        this.maxEndLines[mid] = endLine
+        this.maxEndCharacters[mid] = endCharacter
+        return mid
+    }
+
+    /**
+     * Add the ranges of the subtree over the slice `[lo, hi)` that contain the given
+     * position to the given list, in order of their start position.
+     *
+     * @param lo The first index of the slice.
+     * @param hi The index following the last index of the slice.
+     * @param position The user's hover position.
+     * @param ranges The list to which matching ranges are added.
+     */
+    private collect(lo: number, hi: number, position: lsp.Position, ranges: sqliteModels.RangeData[]): void {
+        if (lo >= hi) {
+            return
+        }
+
+        const mid = (lo + hi) >>> 1
+        if (
+            this.maxEndLines[mid] < position.line ||
+            (this.maxEndLines[mid] === position.line && this.maxEndCharacters[mid] < position.character)
+        ) {
+            // Every range of this subtree ends before the position
+            return
+        }
+
+        this.collect(lo, mid, position, ranges)
+
+        const range = this.ranges[mid]
+        if (
+            range.startLine > position.line ||
+            (range.startLine === position.line && range.startCharacter > position.character)
+        ) {
+            // This range and every range following it start after the position
+            return
+        }
+
+        if (
+            range.endLine > position.line ||
+            (range.endLine === position.line && range.endCharacter >= position.character)
+        ) {
+            ranges.push(range)
+        }
+
+        this.collect(mid + 1, hi, position, ranges)
+    }
+}
+
+/**
+ * Sort ranges that all contain the same position so that inner-most ranges occur before
+ * outer-most ranges. Ranges are ordered by their containment depth: the number of other
+ * given ranges that enclose them. Ranges of equal depth, such as ranges that overlap but
+ * are not nested, are ordered by descending start and then ascending end position, and
+ * identical ranges keep their relative order. This sorts the given list in place.
+ *
+ * @param ranges The ranges containing a common position.
+ */
+export function sortEnclosingRanges(ranges: sqliteModels.RangeData[]): sqliteModels.RangeData[] {
+    const depths = new Map<sqliteModels.RangeData, number>()
+    for (const range of ranges) {
+        depths.set(range, ranges.filter(other => other !== range && containsRange(other, range)).length)
+    }
+
+    return ranges.sort(
+        (a, b) =>
+            (depths.get(b) || 0) - (depths.get(a) || 0) ||
+            b.startLine - a.startLine ||
+            b.startCharacter - a.startCharacter ||
+            a.endLine - b.endLine ||
+            a.endCharacter - b.endCharacter
+    )
+}
+
+/**
+ * Determine if the outer range encloses the inner range (inclusive bounds).
+ *
+ * @param outer The outer range.
+ * @param inner The inner range.
+ */
+function containsRange(outer: sqliteModels.RangeData, inner: sqliteModels.RangeData): boolean {
+    return (
+        (outer.startLine < inner.startLine ||
+            (outer.startLine === inner.startLine && outer.startCharacter <= inner.startCharacter)) &&
+        (inner.endLine < outer.endLine ||
+            (inner.endLine === outer.endLine && inner.endCharacter <= outer.endCharacter))
+    )
+}
@@ -0,0 +1,115 @@
+import * as constants from '../shared/constants'
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import * as settings from './settings'
+import * as uuid from 'uuid'
+import { logAndTraceCall, TracingContext } from '../shared/tracing'
+import { Database } from './backend/database'
+import { accessLog } from './warmup'
+import { accessBatch } from './access'
+import { BundleStore, bundleKey } from './storage'
+
+/**
+ * Invoke the given function with the database of the given dump and return its result. This
+ * is shared by the HTTP and gRPC APIs. Throws a not found error if the bundle does not exist
+ * and an unprocessable entity error if the bundle is not a valid SQLite database.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param id The identifier of the dump.
+ * @param requestedPath The path of the queried document, if any.
+ * @param handler The function to invoke with the database.
+ */
+export async function withBundleDatabase<T>(
+    bundleStore: BundleStore,
+    id: number,
+    requestedPath: string | undefined,
+    handler: (database: Database) => Promise<T>
+): Promise<T> {
+    try {
+        return await bundleStore.withLocalFile(bundleKey(id), filename => {
+            // Opening a missing file would create an empty database in its place
+            if (!filename) {
+                throw Object.assign(new Error('Bundle not found'), { status: 404, code: 'bundle_not_found' })
+            }
+            accessLog.record(id, requestedPath)
+            accessBatch.record(id)
+
+            return handler(new Database(id, filename))
+        })
+    } catch (error) {
+        if (isMalformedBundleError(error)) {
+            throw Object.assign(new Error(`Malformed bundle: ${String(error.message)}`), {
+                status: 422,
+                code: 'malformed_bundle',
+            })
+        }
+
+        throw error
+    }
+}
+
+/**
+ * Store the bundle of the given dump. The given function writes the received bundle to a
+ * temporary file in the uploads directory, which is then moved into the bundle store. This
+ * is shared by the HTTP and gRPC APIs. Throws a conflict error if the bundle already exists,
+ * unless the client has asked to replace it.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param id The identifier of the dump.
+ * @param force Whether the client has asked to replace an existing bundle.
+ * @param ctx The tracing context.
+ * @param write The function that writes the bundle to the given temporary file.
+ */
+export async function storeBundle(
+    bundleStore: BundleStore,
+    id: number,
+    force: boolean | undefined,
+    ctx: TracingContext,
+    write: (tempFilename: string) => Promise<void>
+): Promise<void> {
+    const key = bundleKey(id)
+    if (!force && (await bundleStore.size(key)) > 0) {
+        throw Object.assign(new Error('Bundle already exists'), { status: 409, code: 'bundle_exists' })
+    }
+
+    const tempFilename = path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR, `${uuid.v4()}.tmp`)
+
+    try {
+        await logAndTraceCall(ctx, 'Uploading payload', () => write(tempFilename))
+        await logAndTraceCall(ctx, 'Storing bundle', () => bundleStore.put(key, tempFilename))
+    } finally {
+        // The file has been moved into the store unless an error occurred
+        await fs.unlink(tempFilename).catch(() => {
+            /* noop */
+        })
+    }
+}
+
+/**
+ * Throw an incomplete payload error if the size of the received payload differs from the
+ * size announced by the client. This catches payloads that were cut short without the
+ * connection reporting an error. The payload was not stored, so this is reported as a
+ * server error.
+ *
+ * @param expected The number of bytes announced by the client.
+ * @param filename The file containing the received payload.
+ */
+export async function verifyPayloadSize(expected: number, filename: string): Promise<void> {
+    const { size } = await fs.stat(filename)
+    if (size !== expected) {
+        throw Object.assign(new Error(`Incomplete payload: expected ${expected} bytes, received ${size}`), {
+            status: 500,
+            code: 'incomplete_payload',
+        })
+    }
+}
+
+/**
+ * Determine if the given error was caused by opening or querying a file that is not a
+ * valid SQLite database.
+ *
+ * @param error The error thrown by the query.
+ */
+function isMalformedBundleError(error: unknown): error is Error {
+    return error instanceof Error && /SQLITE_(CORRUPT|NOTADB)/.test(error.message)
+}
@@ -0,0 +1,284 @@
+import * as fs from 'mz/fs'
+import * as grpc from '@grpc/grpc-js'
+import * as metrics from './metrics'
+import * as settings from './settings'
+import * as sqliteModels from '../shared/models/sqlite'
+import { addTags, TracingContext } from '../shared/tracing'
+import { BundleStore } from './storage'
+import { Cancellation } from '../shared/cancellation'
+import { FORMAT_HTTP_HEADERS, Span, Tracer } from 'opentracing'
+import { isAuthorized } from '../shared/api/middleware/auth'
+import { Logger } from 'winston'
+import { makeThrottleFactory } from './routes/uploads'
+import { pipeline as _pipeline, Readable } from 'stream'
+import { promisify } from 'util'
+import { storeBundle, verifyPayloadSize, withBundleDatabase } from './bundles'
+import {
+    bundleManagerService,
+    BundleManagerMethods,
+    encodeMoniker,
+    metadataHeaders,
+    toServiceError,
+    UnaryMethod,
+    wrap,
+} from '../shared/grpc/bundle-manager'
+
+const pipeline = promisify(_pipeline)
+
+type UploadBundleRequest = BundleManagerMethods['UploadBundle'][0]
+
+/**
+ * Create a server of the BundleManager gRPC service (see shared/grpc/bundle-manager.proto).
+ * Each method mirrors a route of the HTTP API and reads bundles from the same store, so the
+ * gRPC API can be served next to the HTTP API. Errors are reported with the gRPC status that
+ * corresponds to the status of the HTTP response.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param logger The logger instance.
+ * @param tracer The tracer instance.
+ */
+export function createGrpcServer(bundleStore: BundleStore, logger: Logger, tracer?: Tracer): grpc.Server {
+    const makeUploadThrottle = makeThrottleFactory(
+        settings.MAXIMUM_UPLOAD_BYTES_PER_SECOND,
+        settings.MAXIMUM_UPLOAD_CHUNK_BYTES
+    )
+
+    /**
+     * Invoke the given function, which handles a call of the given method, with a tracing context
+     * whose span is a child of the span propagated by the client. The context is cancelled once
+     * the client cancels the call or its deadline passes. Errors thrown by the function are sent
+     * to the client, and the duration of the call is recorded.
+     *
+     * @param method The name of the method.
+     * @param call The call.
+     * @param callback The function that sends the response or error to the client.
+     * @param timeoutMs The time (in milliseconds) after which the call fails, if any.
+     * @param handler The function that handles the call.
+     */
+    const handle = async <R>(
+        method: string,
+        call: grpc.ServerUnaryCall<unknown, R> | grpc.ServerReadableStream<unknown, R>,
+        callback: grpc.sendUnaryData<R>,
+        timeoutMs: number | undefined,
+        handler: (ctx: TracingContext) => Promise<R>
+    ): Promise<void> => {
+        const end = metrics.grpcRequestDurationHistogram.startTimer({ method })
+        const span = startSpan(tracer, method, call.metadata)
+        const cancellation = new Cancellation()
+        call.on('cancelled', () => cancellation.cancel())
+        const ctx = addTags({ logger, span, cancellation }, { method })
+
+        let timer: NodeJS.Timeout | undefined
+        const timeout = new Promise<never>((_, reject) => {
+            if (timeoutMs !== undefined && timeoutMs > 0) {
+                timer = setTimeout(() => {
+                    reject(
+                        Object.assign(new Error(`Request timed out after ${timeoutMs}ms`), {
+                            status: 503,
+                            code: 'request_timeout',
+                        })
+                    )
+                    cancellation.cancel()
+                }, timeoutMs)
+            }
+        })
+
+        try {
+            const response = await Promise.race([handler(ctx), timeout])
+            end({ code: grpc.status[grpc.status.OK] })
+            callback(null, response)
+        } catch (error) {
+            const serviceError = toServiceError(error)
+            end({ code: grpc.status[serviceError.code] })
+
+            if (serviceError.code === grpc.status.INTERNAL) {
+                logger.error('uncaught exception', { error, method })
+            } else {
+                logger.debug('request failed', { message: serviceError.details, method })
+            }
+
+            callback(serviceError, null)
+        } finally {
+            if (timer) {
+                clearTimeout(timer)
+            }
+            if (span) {
+                span.finish()
+            }
+        }
+    }
+
+    /**
+     * Create the handler of a method that queries a single bundle. Queries time out after
+     * QUERY_REQUEST_TIMEOUT seconds, as do the queries of the HTTP API.
+     *
+     * @param method The name of the method.
+     * @param query The function that answers the request.
+     */
+    const unary = <M extends UnaryMethod>(
+        method: M,
+        query: (request: BundleManagerMethods[M][0], ctx: TracingContext) => Promise<BundleManagerMethods[M][1]>
+    ) => (
+        call: grpc.ServerUnaryCall<BundleManagerMethods[M][0], BundleManagerMethods[M][1]>,
+        callback: grpc.sendUnaryData<BundleManagerMethods[M][1]>
+    ): Promise<void> =>
+        handle(method, call, callback, settings.QUERY_REQUEST_TIMEOUT * 1000, ctx =>
+            query(call.request, addTags(ctx, { id: call.request.dumpId }))
+        )
+
+    const implementation: { [K in keyof BundleManagerMethods]: grpc.UntypedHandleCall } = {
+        Exists: unary('Exists', ({ dumpId, path }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, path, async database => ({
+                exists: await database.exists(path, ctx),
+            }))
+        ),
+
+        Definitions: unary('Definitions', ({ dumpId, path, position, skip, take }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, path, database =>
+                database.definitions(path, position, { skip, take: take ? take.value : undefined }, ctx)
+            )
+        ),
+
+        References: unary('References', ({ dumpId, path, position, skip, take }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, path, database =>
+                database.references(path, position, { skip, take: take ? take.value : undefined }, ctx)
+            )
+        ),
+
+        Hover: unary('Hover', ({ dumpId, path, position }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, path, async database => ({
+                hover: await database.hover(path, position, ctx),
+            }))
+        ),
+
+        MonikersByPosition: unary('MonikersByPosition', ({ dumpId, path, position }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, path, async database => ({
+                ranges: (await database.monikersByPosition(path, position, ctx)).map(monikers => ({
+                    monikers: monikers.map(encodeMoniker),
+                })),
+            }))
+        ),
+
+        MonikerResults: unary('MonikerResults', ({ dumpId, model, scheme, identifier, skip, take }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, undefined, database =>
+                database.monikerResults(
+                    model === 'DEFINITION' ? sqliteModels.DefinitionModel : sqliteModels.ReferenceModel,
+                    { scheme, identifier },
+                    { skip, take: take ? take.value : undefined },
+                    ctx
+                )
+            )
+        ),
+
+        PackageInformation: unary('PackageInformation', ({ dumpId, path, packageInformationId }, ctx) =>
+            withBundleDatabase(bundleStore, dumpId, path, async database => {
+                const packageInformation = await database.packageInformation(path, packageInformationId, ctx)
+                return {
+                    packageInformation: packageInformation
+                        ? { name: packageInformation.name, version: wrap(packageInformation.version) }
+                        : null,
+                }
+            })
+        ),
+
+        UploadBundle: (
+            call: grpc.ServerReadableStream<UploadBundleRequest, {}>,
+            callback: grpc.sendUnaryData<{}>
+        ): Promise<void> =>
+            // Uploads take time proportional to the size of the bundle, so they do not time out
+            handle('UploadBundle', call, callback, undefined, async ctx => {
+                if (!isAuthorized(metadataHeaders(call.metadata).authorization)) {
+                    throw Object.assign(new Error('Missing or invalid authorization token'), {
+                        status: 401,
+                        code: 'unauthorized',
+                    })
+                }
+
+                const messages: AsyncIterator<UploadBundleRequest> = call[Symbol.asyncIterator]()
+                const { value: first } = await messages.next()
+                if (!first || !first.header) {
+                    throw Object.assign(new Error('The first message of an upload must be its header'), {
+                        status: 400,
+                        code: 'missing_header',
+                    })
+                }
+
+                const { dumpId, force, size } = first.header
+                await storeBundle(bundleStore, dumpId, force, addTags(ctx, { id: dumpId }), async tempFilename => {
+                    await pipeline(
+                        Readable.from(readBundleData(messages)),
+                        makeUploadThrottle(),
+                        fs.createWriteStream(tempFilename)
+                    )
+                    await verifyPayloadSize(size, tempFilename)
+                })
+
+                return {}
+            }),
+    }
+
+    const server = new grpc.Server()
+    server.addService(bundleManagerService, implementation)
+    return server
+}
+
+/**
+ * Bind the given server to the given port on all interfaces and start serving calls.
+ *
+ * @param server The gRPC server.
+ * @param port The port.
+ */
+export async function startGrpcServer(server: grpc.Server, port: number): Promise<void> {
+    await promisify(server.bindAsync.bind(server))(`0.0.0.0:${port}`, grpc.ServerCredentials.createInsecure())
+    server.start()
+}
+
+/**
+ * Stop accepting calls on the given server and wait for the calls in flight to finish.
+ *
+ * @param server The gRPC server.
+ */
+export function closeGrpcServer(server: grpc.Server): Promise<void> {
+    return promisify(server.tryShutdown.bind(server))()
+}
+
+/**
+ * Yield the contents of a bundle carried by the remaining messages of an upload. Throws an
+ * error if another message carries a header.
+ *
+ * @param messages The remaining messages of the upload.
+ */
+async function* readBundleData(messages: AsyncIterator<UploadBundleRequest>): AsyncIterable<Buffer> {
+    for (;;) {
+        const { done, value } = await messages.next()
+        if (done) {
+            return
+        }
+
+        if (!value.data || value.header) {
+            throw Object.assign(new Error('An upload must carry a single header'), {
+                status: 400,
+                code: 'unexpected_header',
+            })
+        }
+
+        yield value.data
+    }
+}
+
+/**
+ * Start a span for a call of the given method, as a child of the span propagated in the
+ * metadata of the call. Returns undefined if tracing is disabled.
+ *
+ * @param tracer The tracer instance.
+ * @param method The name of the method.
+ * @param metadata The metadata of the call.
+ */
+function startSpan(tracer: Tracer | undefined, method: string, metadata: grpc.Metadata): Span | undefined {
+    if (!tracer) {
+        return undefined
+    }
+
+    const parent = tracer.extract(FORMAT_HTTP_HEADERS, metadataHeaders(metadata))
+    return tracer.startSpan(`gRPC ${method}`, parent ? { childOf: parent } : {})
+}
@@ -3,18 +3,33 @@ import * as path from 'path'
 import * as settings from './settings'
 import * as metrics from './metrics'
 import promClient from 'prom-client'
+import express from 'express'
 import { createLogger } from '../shared/logging'
 import { ensureDirectory } from '../shared/paths'
 import { Logger } from 'winston'
 import { startExpressApp } from '../shared/api/init'
 import { createDatabaseRouter } from './routes/database'
 import { createUploadRouter } from './routes/uploads'
+import { createStatsRouter } from './routes/stats'
+import { createRebalanceRouter } from './routes/rebalance'
+import { createCapabilitiesRouter } from './routes/capabilities'
+import { createJanitorRouter } from '../shared/api/janitor'
 import { startTasks } from './tasks'
 import { createPostgresConnection } from '../shared/database/postgres'
 import { waitForConfiguration } from '../shared/config/config'
+import { closeServer, onShutdown } from '../shared/shutdown'
+import { Database } from './backend/database'
+import { createBundleStore } from './storage'
+import { checkPeer, checkPostgres, checkWritableDirectory, createReadinessRouter } from '../shared/api/readiness'
+import { checkFreeSpace } from './stats'
+import { BUNDLE_MANAGER_GRPC_PORT, READINESS_CHECK_TIMEOUT } from '../shared/config/settings'
+import { deprecatedEnvUses } from '../shared/settings'
+import { createTracer } from '../shared/tracing'
+import { accessLog, ACCESS_LOG_FILENAME, checkWarmUp, warmUp } from './warmup'
+import { closeGrpcServer, createGrpcServer, startGrpcServer } from './grpc'
 
 /**
- * Runs the HTTP server that stores and queries individual SQLite files.
+ * Runs the HTTP and gRPC servers that store and query individual SQLite files.
  *
  * @param logger The logger instance.
  */
@@ -25,26 +40,109 @@ async function main(logger: Logger): Promise<void> {
     // Read configuration from frontend
     const fetchConfiguration = await waitForConfiguration(logger)
 
+    // Configure distributed tracing
+    const tracer = createTracer('precise-code-intel-bundle-manager', fetchConfiguration())
+
+    // Report settings read from their deprecated names
+    for (const { key, deprecatedKey } of deprecatedEnvUses) {
+        logger.warn(`${deprecatedKey} is deprecated, set ${key} instead`)
+    }
+
     // Update cache capacities on startup
     metrics.connectionCacheCapacityGauge.set(settings.CONNECTION_CACHE_CAPACITY)
-    metrics.documentCacheCapacityGauge.set(settings.DOCUMENT_CACHE_CAPACITY)
-    metrics.resultChunkCacheCapacityGauge.set(settings.RESULT_CHUNK_CACHE_CAPACITY)
+    metrics.documentCacheCapacityGauge.set(settings.DOCUMENT_CACHE_MEMORY_BUDGET_BYTES)
+    metrics.resultChunkCacheCapacityGauge.set(settings.RESULT_CHUNK_CACHE_MEMORY_BUDGET_BYTES)
+    metrics.bundleCacheCapacityGauge.set(settings.BUNDLE_CACHE_MAXIMUM_SIZE_BYTES)
 
     // Ensure storage roots exist
     await ensureDirectory(settings.STORAGE_ROOT)
     await ensureDirectory(path.join(settings.STORAGE_ROOT, constants.DBS_DIR))
     await ensureDirectory(path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR))
 
+    // Fail fast on an unsupported or misconfigured storage backend
+    const bundleStore = await createBundleStore({
+        backend: settings.BUNDLE_STORAGE_BACKEND,
+        storageRoot: settings.STORAGE_ROOT,
+        bucket: settings.BUNDLE_STORAGE_BUCKET,
+        prefix: settings.BUNDLE_STORAGE_PREFIX,
+        endpoint: settings.BUNDLE_STORAGE_ENDPOINT,
+        region: settings.BUNDLE_STORAGE_REGION,
+        accessKeyId: settings.BUNDLE_STORAGE_ACCESS_KEY_ID,
+        secretAccessKey: settings.BUNDLE_STORAGE_SECRET_ACCESS_KEY,
+        cacheMaxSizeBytes: settings.BUNDLE_CACHE_MAXIMUM_SIZE_BYTES,
+        // Drop any data cached from a replaced or removed bundle
+        onInvalidate: filename => Database.invalidate(filename),
+    })
+
     // Create database connection
     const connection = await createPostgresConnection(fetchConfiguration(), logger)
 
+    // Open the bundles that were hot before the last shutdown; the readiness check fails
+    // until this finishes so that traffic is not routed to a cold bundle manager
+    const accessLogFilename = path.join(settings.STORAGE_ROOT, ACCESS_LOG_FILENAME)
+    await accessLog.load(accessLogFilename)
+    const warmup = warmUp(bundleStore, accessLog.recent(), logger)
+
     // Start background tasks
-    startTasks(connection, logger)
+    const taskRunner = startTasks(connection, bundleStore, logger)
+
+    // Serve the internal API under `/v1` as well as at the unversioned routes used by
+    // api-servers and workers that predate versioning
+    const internalRouters = [
+        createDatabaseRouter(bundleStore, logger),
+        createUploadRouter(bundleStore, logger),
+        createStatsRouter(),
+    ]
+    const versionedRouter = express.Router()
+    versionedRouter.use('/v1', ...internalRouters)
 
-    const routers = [createDatabaseRouter(logger), createUploadRouter(logger)]
+    const routers = [
+        ...internalRouters,
+        versionedRouter,
+        createCapabilitiesRouter(),
+        createRebalanceRouter(bundleStore, logger),
+        createJanitorRouter(taskRunner),
+        createReadinessRouter(
+            [
+                checkPostgres(connection),
+                checkWritableDirectory('storage', settings.STORAGE_ROOT),
+                checkFreeSpace(settings.STORAGE_ROOT, settings.READINESS_MIN_FREE_SPACE_PERCENT),
+                checkPeer('api-server', settings.PRECISE_CODE_INTEL_API_SERVER_URL),
+                checkWarmUp(warmup),
+            ],
+            READINESS_CHECK_TIMEOUT * 1000
+        ),
+    ]
 
     // Start server
-    startExpressApp({ port: settings.HTTP_PORT, routers, logger })
+    const server = startExpressApp({ port: settings.HTTP_PORT, routers, logger, tracer, selectTimeout })
+
+    // Serve the gRPC API next to the HTTP API, which remains the default transport of clients
+    const grpcServer = createGrpcServer(bundleStore, logger, tracer)
+    if (BUNDLE_MANAGER_GRPC_PORT > 0) {
+        await startGrpcServer(grpcServer, BUNDLE_MANAGER_GRPC_PORT)
+        logger.debug('gRPC server listening', { port: BUNDLE_MANAGER_GRPC_PORT })
+    }
+
+    // Drain in-flight requests and tasks before closing cached SQLite handles
+    onShutdown(logger, settings.SHUTDOWN_TIMEOUT * 1000, async () => {
+        await Promise.all([closeServer(server), closeGrpcServer(grpcServer), taskRunner.stop()])
+        await accessLog.save(accessLogFilename)
+        await Database.closeAll()
+        await connection.close()
+    })
+}
+
+/**
+ * Return the timeout of requests to the given route. Only queries of a single bundle time out,
+ * as uploads, verification, and rebalancing take time proportional to the size of a bundle.
+ *
+ * @param route The request path.
+ */
+function selectTimeout(route: string): number | undefined {
+    return /^(\/v1)?\/dbs\/([0-9]+\/(?!verify$)[a-zA-Z]+|exists)$/.test(route)
+        ? settings.QUERY_REQUEST_TIMEOUT * 1000
+        : undefined
 }
 
 // Initialize logger
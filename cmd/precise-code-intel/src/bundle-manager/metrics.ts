@@ -17,6 +17,16 @@ export const httpQueryDurationHistogram = new promClient.Histogram({
     buckets: [0.2, 0.5, 1, 2, 5, 10, 30],
 })
 
+//
+// gRPC Metrics
+
+export const grpcRequestDurationHistogram = new promClient.Histogram({
+    name: 'lsif_grpc_request_duration_seconds',
+    help: 'Total time spent on gRPC requests.',
+    labelNames: ['method', 'code'],
+    buckets: [0.2, 0.5, 1, 2, 5, 10, 30],
+})
+
 //
 // Database Metrics
 
@@ -46,38 +56,104 @@ export const connectionCacheSizeGauge = new promClient.Gauge({
 
 export const connectionCacheEventsCounter = new promClient.Counter({
     name: 'lsif_connection_cache_events_total',
-    help: 'The number of connection cache hits, misses, and evictions.',
+    help: 'The number of connection cache hits, misses, evictions, and failed fills.',
     labelNames: ['type'],
 })
 
+export const connectionPoolOpenGauge = new promClient.Gauge({
+    name: 'lsif_connection_pool_open_connections',
+    help: 'The current number of open SQLite connections over all cached bundles.',
+})
+
+export const connectionPoolIdleGauge = new promClient.Gauge({
+    name: 'lsif_connection_pool_idle_connections',
+    help: 'The current number of open SQLite connections that are not in use.',
+})
+
+export const connectionPoolWaitsCounter = new promClient.Counter({
+    name: 'lsif_connection_pool_waits_total',
+    help: 'The number of queries that waited for a SQLite connection of a bundle to be released.',
+})
+
+export const connectionPoolWaitDurationHistogram = new promClient.Histogram({
+    name: 'lsif_connection_pool_wait_duration_seconds',
+    help: 'Total time spent waiting for a SQLite connection of a bundle to be released.',
+    buckets: [0.01, 0.05, 0.1, 0.2, 0.5, 1, 2, 5],
+})
+
 export const documentCacheCapacityGauge = new promClient.Gauge({
     name: 'lsif_document_cache_capacity',
-    help: 'The maximum number of documents loaded in memory.',
+    help: 'The maximum number of bytes of decoded documents held in memory.',
 })
 
 export const documentCacheSizeGauge = new promClient.Gauge({
     name: 'lsif_document_cache_size',
-    help: 'The current number of documents loaded in memory.',
+    help: 'The current estimated number of bytes of decoded documents held in memory.',
 })
 
 export const documentCacheEventsCounter = new promClient.Counter({
     name: 'lsif_document_cache_events_total',
-    help: 'The number of document cache hits, misses, and evictions.',
+    help: 'The number of document cache hits, misses, evictions, and failed fills.',
     labelNames: ['type'],
 })
 
 export const resultChunkCacheCapacityGauge = new promClient.Gauge({
     name: 'lsif_results_chunk_cache_capacity',
-    help: 'The maximum number of result chunks loaded in memory.',
+    help: 'The maximum number of bytes of decoded result chunks held in memory.',
 })
 
 export const resultChunkCacheSizeGauge = new promClient.Gauge({
     name: 'lsif_results_chunk_cache_size',
-    help: 'The current number of result chunks loaded in memory.',
+    help: 'The current estimated number of bytes of decoded result chunks held in memory.',
 })
 
 export const resultChunkCacheEventsCounter = new promClient.Counter({
     name: 'lsif_results_chunk_cache_events_total',
-    help: 'The number of result chunk cache hits, misses, and evictions.',
+    help: 'The number of result chunk cache hits, misses, evictions, and failed fills.',
+    labelNames: ['type'],
+})
+
+export const bundleCacheCapacityGauge = new promClient.Gauge({
+    name: 'lsif_bundle_cache_capacity',
+    help: 'The maximum number of bytes of bundles downloaded from object storage held on disk.',
+})
+
+export const bundleCacheSizeGauge = new promClient.Gauge({
+    name: 'lsif_bundle_cache_size',
+    help: 'The current number of bytes of bundles downloaded from object storage held on disk.',
+})
+
+export const bundleCacheEventsCounter = new promClient.Counter({
+    name: 'lsif_bundle_cache_events_total',
+    help: 'The number of bundle cache hits, misses, evictions, and failed fills.',
     labelNames: ['type'],
 })
+
+//
+// Janitor Metrics
+
+export const janitorFilesRemovedCounter = new promClient.Counter({
+    name: 'lsif_janitor_files_removed_total',
+    help: 'The number of files removed by the janitor.',
+    labelNames: ['reason'],
+})
+
+export const janitorBytesReclaimedCounter = new promClient.Counter({
+    name: 'lsif_janitor_bytes_reclaimed_total',
+    help: 'The number of bytes freed by files removed by the janitor.',
+    labelNames: ['reason'],
+})
+
+//
+// Rebalance Metrics
+
+export const rebalancedBundlesCounter = new promClient.Counter({
+    name: 'lsif_rebalanced_bundles_total',
+    help: 'The number of bundles sent to another shard while rebalancing, by result.',
+    labelNames: ['result'],
+})
+
+export const rebalancedBytesCounter = new promClient.Counter({
+    name: 'lsif_rebalanced_bytes_total',
+    help: 'The number of bytes of bundles moved to another shard while rebalancing.',
+})
@@ -0,0 +1,64 @@
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import nock from 'nock'
+import rmfr from 'rmfr'
+import { S3ObjectStore } from './objectstore'
+
+describe('S3ObjectStore', () => {
+    const endpoint = 'http://objects'
+    const objectStore = new S3ObjectStore('lsif', { endpoint, accessKeyId: 'id', secretAccessKey: 'secret' })
+    let tempPath!: string
+
+    beforeEach(async () => {
+        tempPath = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterEach(async () => {
+        nock.cleanAll()
+        await rmfr(tempPath)
+    })
+
+    const listing = (keys: string[], continuationToken?: string): string =>
+        [
+            `<ListBucketResult><IsTruncated>${String(!!continuationToken)}</IsTruncated>`,
+            continuationToken ? `<NextContinuationToken>${continuationToken}</NextContinuationToken>` : '',
+            ...keys.map(key => `<Contents><Key>${key}</Key></Contents>`),
+            '</ListBucketResult>',
+        ].join('')
+
+    it('should list keys across pages', async () => {
+        nock(endpoint)
+            .get('/lsif')
+            .query({ 'list-type': '2', prefix: 'bundles/' })
+            .reply(200, listing(['bundles/1.lsif.db', 'bundles/a&amp;b'], 'next'))
+            .get('/lsif')
+            .query({ 'list-type': '2', prefix: 'bundles/', 'continuation-token': 'next' })
+            .reply(200, listing(['bundles/2.lsif.db']))
+
+        expect(await objectStore.list('bundles/')).toEqual(['bundles/1.lsif.db', 'bundles/a&b', 'bundles/2.lsif.db'])
+    })
+
+    it('should report the size of objects', async () => {
+        nock(endpoint)
+            .head('/lsif/1.lsif.db')
+            .reply(200, '', { 'Content-Length': '42' })
+            .head('/lsif/2.lsif.db')
+            .reply(404)
+
+        expect(await objectStore.size('1.lsif.db')).toEqual(42)
+        expect(await objectStore.size('2.lsif.db')).toBeUndefined()
+    })
+
+    it('should download objects', async () => {
+        nock(endpoint)
+            .get('/lsif/1.lsif.db')
+            .reply(200, 'foobar')
+            .get('/lsif/2.lsif.db')
+            .reply(404)
+
+        const filename = path.join(tempPath, 'download.tmp')
+        expect(await objectStore.download('1.lsif.db', filename)).toBeTruthy()
+        expect(await fs.readFile(filename, 'utf8')).toEqual('foobar')
+        expect(await objectStore.download('2.lsif.db', filename)).toBeFalsy()
+    })
+})
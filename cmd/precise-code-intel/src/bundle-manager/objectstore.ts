@@ -0,0 +1,254 @@
+import * as fs from 'mz/fs'
+import { Bucket, Storage } from '@google-cloud/storage'
+import { pipeline as _pipeline, Readable } from 'stream'
+import { promisify } from 'util'
+import { S3 } from 'aws-sdk'
+
+const pipeline = promisify(_pipeline)
+
+/**
+ * A bucket in an object store. Objects are addressed by their full key; the mapping of
+ * bundles to keys is left to the caller.
+ */
+export interface ObjectStore {
+    /**
+     * Write the contents of the given file as the object with the given key.
+     *
+     * @param key The key of the object.
+     * @param filename The file to upload.
+     */
+    putFile(key: string, filename: string): Promise<void>
+
+    /**
+     * Read the contents of the object with the given key.
+     *
+     * @param key The key of the object.
+     */
+    get(key: string): Readable
+
+    /**
+     * Write the contents of the object with the given key to the given file. Returns false if
+     * the object does not exist.
+     *
+     * @param key The key of the object.
+     * @param filename The file to write.
+     */
+    download(key: string, filename: string): Promise<boolean>
+
+    /**
+     * Return the size of the object with the given key, or undefined if it does not exist.
+     *
+     * @param key The key of the object.
+     */
+    size(key: string): Promise<number | undefined>
+
+    /**
+     * Remove the object with the given key. Removing a missing object is not an error.
+     *
+     * @param key The key of the object.
+     */
+    delete(key: string): Promise<void>
+
+    /**
+     * Return the keys of all objects whose key starts with the given prefix.
+     *
+     * @param prefix The prefix of the keys.
+     */
+    list(prefix: string): Promise<string[]>
+}
+
+/** The configuration of the client of an S3 bucket. */
+export interface S3ObjectStoreOptions {
+    /**
+     * The URL of an S3-compatible object store, such as MinIO. Defaults to the endpoint of
+     * Amazon S3 in the region of the bucket.
+     */
+    endpoint?: string
+
+    /** The region of the bucket. */
+    region?: string
+
+    /**
+     * The identifier of the access key used to access the bucket. If unset, credentials are
+     * read from the default credential chain of the AWS SDK.
+     */
+    accessKeyId?: string
+
+    /** The secret of the access key used to access the bucket. */
+    secretAccessKey?: string
+}
+
+/**
+ * A bucket in Amazon S3 or another S3-compatible object store. Buckets of a custom endpoint
+ * are addressed by path, as object stores such as MinIO do not support virtual-hosted buckets.
+ */
+export class S3ObjectStore implements ObjectStore {
+    private client: S3
+
+    /**
+     * Create a new `S3ObjectStore`.
+     *
+     * @param bucket The name of the bucket.
+     * @param options The location of the bucket and the credentials used to access it.
+     */
+    constructor(private bucket: string, { endpoint, region, accessKeyId, secretAccessKey }: S3ObjectStoreOptions) {
+        this.client = new S3({
+            endpoint: endpoint || undefined,
+            region: region || 'us-east-1',
+            s3ForcePathStyle: !!endpoint,
+            ...(accessKeyId && secretAccessKey ? { accessKeyId, secretAccessKey } : {}),
+        })
+    }
+
+    public async putFile(key: string, filename: string): Promise<void> {
+        // Large files are uploaded in parts, each of which is retried on failure
+        await this.client.upload({ Bucket: this.bucket, Key: key, Body: fs.createReadStream(filename) }).promise()
+    }
+
+    public get(key: string): Readable {
+        return this.client.getObject({ Bucket: this.bucket, Key: key }).createReadStream()
+    }
+
+    public async download(key: string, filename: string): Promise<boolean> {
+        try {
+            await pipeline(this.get(key), fs.createWriteStream(filename))
+            return true
+        } catch (error) {
+            if (isS3NotFoundError(error)) {
+                return false
+            }
+
+            throw error
+        }
+    }
+
+    public async size(key: string): Promise<number | undefined> {
+        try {
+            const { ContentLength } = await this.client.headObject({ Bucket: this.bucket, Key: key }).promise()
+            return ContentLength || 0
+        } catch (error) {
+            if (isS3NotFoundError(error)) {
+                return undefined
+            }
+
+            throw error
+        }
+    }
+
+    public async delete(key: string): Promise<void> {
+        await this.client.deleteObject({ Bucket: this.bucket, Key: key }).promise()
+    }
+
+    public async list(prefix: string): Promise<string[]> {
+        const keys: string[] = []
+        let continuationToken: string | undefined
+
+        do {
+            const page = await this.client
+                .listObjectsV2({ Bucket: this.bucket, Prefix: prefix, ContinuationToken: continuationToken })
+                .promise()
+
+            for (const { Key } of page.Contents || []) {
+                if (Key !== undefined) {
+                    keys.push(Key)
+                }
+            }
+
+            continuationToken = page.IsTruncated ? page.NextContinuationToken : undefined
+        } while (continuationToken)
+
+        return keys
+    }
+}
+
+/**
+ * Determine if the given error was returned by S3 for a missing object.
+ *
+ * @param error The error.
+ */
+function isS3NotFoundError(error: { statusCode?: number } | undefined): boolean {
+    return !!error && error.statusCode === 404
+}
+
+/** The configuration of the client of a Google Cloud Storage bucket. */
+export interface GcsObjectStoreOptions {
+    /** The URL of the storage API. Defaults to the public endpoint of Google Cloud Storage. */
+    endpoint?: string
+}
+
+/**
+ * A bucket in Google Cloud Storage. Credentials are read from the application default
+ * credentials, e.g. the file named by `GOOGLE_APPLICATION_CREDENTIALS` or the service
+ * account of the instance.
+ */
+export class GcsObjectStore implements ObjectStore {
+    private bucket: Bucket
+
+    /**
+     * Create a new `GcsObjectStore`.
+     *
+     * @param bucket The name of the bucket.
+     * @param options The location of the storage API.
+     */
+    constructor(bucket: string, { endpoint }: GcsObjectStoreOptions = {}) {
+        this.bucket = new Storage(endpoint ? { apiEndpoint: endpoint } : {}).bucket(bucket)
+    }
+
+    public async putFile(key: string, filename: string): Promise<void> {
+        await this.bucket.upload(filename, { destination: key })
+    }
+
+    public get(key: string): Readable {
+        return this.bucket.file(key).createReadStream()
+    }
+
+    public async download(key: string, filename: string): Promise<boolean> {
+        try {
+            await this.bucket.file(key).download({ destination: filename })
+            return true
+        } catch (error) {
+            if (isGcsNotFoundError(error)) {
+                return false
+            }
+
+            throw error
+        }
+    }
+
+    public async size(key: string): Promise<number | undefined> {
+        try {
+            const [metadata] = await this.bucket.file(key).getMetadata()
+            return parseInt(metadata.size, 10)
+        } catch (error) {
+            if (isGcsNotFoundError(error)) {
+                return undefined
+            }
+
+            throw error
+        }
+    }
+
+    public async delete(key: string): Promise<void> {
+        try {
+            await this.bucket.file(key).delete()
+        } catch (error) {
+            if (!isGcsNotFoundError(error)) {
+                throw error
+            }
+        }
+    }
+
+    public async list(prefix: string): Promise<string[]> {
+        const [files] = await this.bucket.getFiles({ prefix, autoPaginate: true })
+        return files.map(file => file.name)
+    }
+}
+
+/**
+ * Determine if the given error was returned by Google Cloud Storage for a missing object.
+ *
+ * @param error The error.
+ */
+function isGcsNotFoundError(error: { code?: number } | undefined): boolean {
+    return !!error && error.code === 404
+}
@@ -0,0 +1,25 @@
+import express from 'express'
+import { BUNDLE_MANAGER_API_VERSION, BundleManagerCapabilities } from '../../shared/capabilities'
+
+/** Create a router containing the capability discovery endpoint. */
+export function createCapabilitiesRouter(): express.Router {
+    const router = express.Router()
+
+    router.get('/v1/capabilities', (_, res: express.Response<BundleManagerCapabilities>) => {
+        res.json({
+            version: BUNDLE_MANAGER_API_VERSION,
+            capabilities: [
+                'batchExists',
+                'implementations',
+                'hovers',
+                'ranges',
+                'documentSymbols',
+                'diagnostics',
+                'verify',
+                'paginatedLocations',
+            ],
+        })
+    })
+
+    return router
+}
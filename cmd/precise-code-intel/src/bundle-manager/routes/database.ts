@@ -1,6 +1,7 @@
 import * as settings from '../settings'
 import express from 'express'
 import { addTags, TracingContext } from '../../shared/tracing'
+import { cancellationFromResponse } from '../../shared/cancellation'
 import { Logger } from 'winston'
 import { pipeline as _pipeline } from 'stream'
 import { Span } from 'opentracing'
@@ -8,9 +9,15 @@ import { wrap } from 'async-middleware'
 import { Database } from '../backend/database'
 import * as sqliteModels from '../../shared/models/sqlite'
 import { InternalLocation } from '../backend/location'
-import { dbFilename } from '../../shared/paths'
 import * as lsp from 'vscode-languageserver-protocol'
 import * as validation from '../../shared/api/middleware/validation'
+import { body } from 'express-validator'
+import { json } from 'body-parser'
+import { acceptsNdjson, writeNdjson } from '../../shared/api/ndjson'
+import { BundleVerification } from '../../shared/verification'
+import { BundleStore, bundleKey } from '../storage'
+import { withBundleDatabase } from '../bundles'
+import { encodeHover, encodeHovers, HoverData, HoverEnvelope, HoversEnvelope } from '../../shared/encoding/hover'
 
 /**
  * Create a router containing the SQLite query endpoints.
@@ -18,14 +25,16 @@ import * as validation from '../../shared/api/middleware/validation'
  * For now, each public method of Database (see sif/src/bundle-manager/backend/database.ts) is
  * exposed at `/<database-id>/<method>`. This interface is likely to change soon.
  *
+ * @param bundleStore The store of converted bundles.
  * @param logger The logger instance.
  */
-export function createDatabaseRouter(logger: Logger): express.Router {
+export function createDatabaseRouter(bundleStore: BundleStore, logger: Logger): express.Router {
     const router = express.Router()
 
     /**
      * Create a tracing context from the request logger and tracing span
-     * tagged with the given values.
+     * tagged with the given values. The context is cancelled once the client
+     * disconnects before the response has been written.
      *
      * @param req The express request.
      * @param tags The tags to apply to the logger and span.
@@ -33,21 +42,41 @@ export function createDatabaseRouter(logger: Logger): express.Router {
     const createTracingContext = (
         req: express.Request & { span?: Span },
         tags: { [K: string]: unknown }
-    ): TracingContext => addTags({ logger, span: req.span }, tags)
+    ): TracingContext =>
+        addTags({ logger, span: req.span, cancellation: req.res && cancellationFromResponse(req.res) }, tags)
 
+    /**
+     * Invoke the given handler with the database identified by the request and send its result.
+     * Throws a not found error if the bundle does not exist and an unprocessable entity
+     * error if the bundle is not a valid SQLite database.
+     *
+     * @param req The express request.
+     * @param res The express response.
+     * @param handler The function to invoke with the database.
+     * @param send The function that writes the result to the response. Defaults to a JSON response.
+     */
     const withDatabase = async <T>(
         req: express.Request,
         res: express.Response<T>,
-        handler: (database: Database, ctx?: TracingContext) => Promise<T>
+        handler: (database: Database, ctx?: TracingContext) => Promise<T>,
+        send: (payload: T) => Promise<void> | void = payload => {
+            res.json(payload)
+        }
     ): Promise<void> => {
         const id = parseInt(req.params.id, 10)
         const ctx = createTracingContext(req, { id })
-        const database = new Database(id, dbFilename(settings.STORAGE_ROOT, id))
-
-        const payload = await handler(database, ctx)
-        res.json(payload)
+        await send(await withBundleDatabase(bundleStore, id, requestedPath(req), database => handler(database, ctx)))
     }
 
+    /**
+     * Determine if the request was made to a versioned route. Results of versioned routes
+     * are wrapped in envelopes, while the unversioned routes retain their original format
+     * for clients that predate versioning.
+     *
+     * @param req The express request.
+     */
+    const isVersioned = (req: express.Request): boolean => req.baseUrl === '/v1'
+
     interface ExistsQueryArgs {
         path: string
     }
@@ -65,13 +94,63 @@ export function createDatabaseRouter(logger: Logger): express.Router {
         )
     )
 
-    interface DefinitionsQueryArgs {
+    interface BatchExistsBody {
+        checks: { id: number; path: string }[]
+    }
+
+    type BatchExistsResponse = boolean[]
+
+    router.post(
+        '/dbs/exists',
+        json(),
+        validation.validationMiddleware([
+            body('checks').isArray(),
+            body('checks.*.id').isInt().toInt(),
+            body('checks.*.path').isString().not().isEmpty(),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<BatchExistsResponse>): Promise<void> => {
+                const { checks }: BatchExistsBody = req.body
+                const ctx = createTracingContext(req, { numChecks: checks.length })
+
+                // Opening a missing file would create an empty database in its place, so a path
+                // is reported as missing from a bundle that does not exist on disk
+                const payload = await Promise.all(
+                    checks.map(async ({ id, path }) => {
+                        return bundleStore.withLocalFile(bundleKey(id), filename =>
+                            filename
+                                ? new Database(id, filename).exists(path, addTags(ctx, { id }))
+                                : Promise.resolve(false)
+                        )
+                    })
+                )
+
+                res.json(payload)
+            }
+        )
+    )
+
+    interface LocationsQueryArgs {
         path: string
         line: number
         character: number
+        skip?: number
+        take?: number
     }
 
-    type DefinitionsResponse = InternalLocation[]
+    /**
+     * The response of the definitions and references endpoints. Requests that supply a limit
+     * or an offset receive a page of locations and the total count. Other requests receive
+     * every location as a bare list, as clients that predate pagination expect.
+     */
+    type LocationsResponse = InternalLocation[] | { locations: InternalLocation[]; count: number }
+
+    /**
+     * Determine if the request asked for a page of locations.
+     *
+     * @param args The query args of the request.
+     */
+    const isPaginated = ({ skip, take }: LocationsQueryArgs): boolean => skip !== undefined || take !== undefined
 
     router.get(
         '/dbs/:id([0-9]+)/definitions',
@@ -79,37 +158,71 @@ export function createDatabaseRouter(logger: Logger): express.Router {
             validation.validateNonEmptyString('path'),
             validation.validateInt('line'),
             validation.validateInt('character'),
+            validation.validateOptionalInt('skip'),
+            validation.validateOptionalInt('take'),
         ]),
         wrap(
-            async (req: express.Request, res: express.Response<DefinitionsResponse>): Promise<void> => {
-                const { path, line, character }: DefinitionsQueryArgs = req.query
-                await withDatabase(req, res, (database, ctx) => database.definitions(path, { line, character }, ctx))
+            async (req: express.Request, res: express.Response<LocationsResponse>): Promise<void> => {
+                const args: LocationsQueryArgs = req.query
+                const { path, line, character, skip, take } = args
+                await withDatabase(req, res, async (database, ctx) => {
+                    const result = await database.definitions(path, { line, character }, { skip, take }, ctx)
+                    return isPaginated(args) ? result : result.locations
+                })
             }
         )
     )
 
-    interface ReferencesQueryArgs {
+    router.get(
+        '/dbs/:id([0-9]+)/references',
+        validation.validationMiddleware([
+            validation.validateNonEmptyString('path'),
+            validation.validateInt('line'),
+            validation.validateInt('character'),
+            validation.validateOptionalInt('skip'),
+            validation.validateOptionalInt('take'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<LocationsResponse>): Promise<void> => {
+                const args: LocationsQueryArgs = req.query
+                const { path, line, character, skip, take } = args
+
+                await withDatabase(
+                    req,
+                    res,
+                    async (database, ctx) => {
+                        const result = await database.references(path, { line, character }, { skip, take }, ctx)
+                        return isPaginated(args) ? result : result.locations
+                    },
+                    // Streamed responses are always a bare sequence of locations
+                    acceptsNdjson(req)
+                        ? payload => writeNdjson(res, Array.isArray(payload) ? payload : payload.locations)
+                        : undefined
+                )
+            }
+        )
+    )
+
+    interface ImplementationsQueryArgs {
         path: string
         line: number
         character: number
     }
 
-    type ReferencesResponse = InternalLocation[]
+    type ImplementationsResponse = InternalLocation[]
 
     router.get(
-        '/dbs/:id([0-9]+)/references',
+        '/dbs/:id([0-9]+)/implementations',
         validation.validationMiddleware([
             validation.validateNonEmptyString('path'),
             validation.validateInt('line'),
             validation.validateInt('character'),
         ]),
         wrap(
-            async (req: express.Request, res: express.Response<ReferencesResponse>): Promise<void> => {
-                const { path, line, character }: ReferencesQueryArgs = req.query
-                await withDatabase(
-                    req,
-                    res,
-                    async (database, ctx) => (await database.references(path, { line, character }, ctx)).values
+            async (req: express.Request, res: express.Response<ImplementationsResponse>): Promise<void> => {
+                const { path, line, character }: ImplementationsQueryArgs = req.query
+                await withDatabase(req, res, (database, ctx) =>
+                    database.implementations(path, { line, character }, ctx)
                 )
             }
         )
@@ -121,7 +234,7 @@ export function createDatabaseRouter(logger: Logger): express.Router {
         character: number
     }
 
-    type HoverResponse = { text: string; range: lsp.Range } | null
+    type HoverResponse = HoverEnvelope | HoverData | null
 
     router.get(
         '/dbs/:id([0-9]+)/hover',
@@ -133,7 +246,60 @@ export function createDatabaseRouter(logger: Logger): express.Router {
         wrap(
             async (req: express.Request, res: express.Response<HoverResponse>): Promise<void> => {
                 const { path, line, character }: HoverQueryArgs = req.query
-                await withDatabase(req, res, (database, ctx) => database.hover(path, { line, character }, ctx))
+                await withDatabase(req, res, async (database, ctx) => {
+                    const hover = await database.hover(path, { line, character }, ctx)
+                    return isVersioned(req) ? encodeHover(hover) : hover
+                })
+            }
+        )
+    )
+
+    interface RangesQueryArgs {
+        path: string
+        startLine: number
+        endLine: number
+    }
+
+    type RangesResponse = sqliteModels.RangeSummaryData[]
+
+    router.get(
+        '/dbs/:id([0-9]+)/ranges',
+        validation.validationMiddleware([
+            validation.validateNonEmptyString('path'),
+            validation.validateInt('startLine'),
+            validation.validateInt('endLine'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<RangesResponse>): Promise<void> => {
+                const { path, startLine, endLine }: RangesQueryArgs = req.query
+                await withDatabase(req, res, (database, ctx) => database.ranges(path, startLine, endLine, ctx))
+            }
+        )
+    )
+
+    interface HoversBody {
+        path: string
+        positions: lsp.Position[]
+    }
+
+    type HoversResponse = HoversEnvelope | (HoverData | null)[]
+
+    router.post(
+        '/dbs/:id([0-9]+)/hovers',
+        json(),
+        validation.validationMiddleware([
+            body('path').isString().not().isEmpty(),
+            body('positions').isArray(),
+            body('positions.*.line').isInt().toInt(),
+            body('positions.*.character').isInt().toInt(),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<HoversResponse>): Promise<void> => {
+                const { path, positions }: HoversBody = req.body
+                await withDatabase(req, res, async (database, ctx) => {
+                    const hovers = await database.hovers(path, positions, ctx)
+                    return isVersioned(req) ? encodeHovers(hovers) : hovers
+                })
             }
         )
     )
@@ -223,5 +389,80 @@ export function createDatabaseRouter(logger: Logger): express.Router {
         )
     )
 
+    interface DocumentSymbolsQueryArgs {
+        path: string
+    }
+
+    type DocumentSymbolsResponse = sqliteModels.DocumentSymbolData[]
+
+    router.get(
+        '/dbs/:id([0-9]+)/documentSymbols',
+        validation.validationMiddleware([validation.validateNonEmptyString('path')]),
+        wrap(
+            async (req: express.Request, res: express.Response<DocumentSymbolsResponse>): Promise<void> => {
+                const { path }: DocumentSymbolsQueryArgs = req.query
+                await withDatabase(req, res, (database, ctx) => database.documentSymbols(path, ctx))
+            }
+        )
+    )
+
+    interface DiagnosticsQueryArgs {
+        path?: string
+        skip?: number
+        take?: number
+    }
+
+    interface DiagnosticsResponse {
+        diagnostics: sqliteModels.DiagnosticData[]
+        count: number
+    }
+
+    router.get(
+        '/dbs/:id([0-9]+)/diagnostics',
+        validation.validationMiddleware([
+            validation.validateOptionalString('path'),
+            validation.validateOptionalInt('skip'),
+            validation.validateOptionalInt('take'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<DiagnosticsResponse>): Promise<void> => {
+                const { path, skip, take }: DiagnosticsQueryArgs = req.query
+                await withDatabase(req, res, (database, ctx) =>
+                    database.diagnostics(path || undefined, { skip, take }, ctx)
+                )
+            }
+        )
+    )
+
+    interface VerifyQueryArgs {
+        sampleSize?: number
+    }
+
+    type VerifyResponse = BundleVerification
+
+    router.post(
+        '/dbs/:id([0-9]+)/verify',
+        validation.validationMiddleware([validation.validateOptionalInt('sampleSize')]),
+        wrap(
+            async (req: express.Request, res: express.Response<VerifyResponse>): Promise<void> => {
+                const { sampleSize }: VerifyQueryArgs = req.query
+                await withDatabase(req, res, (database, ctx) =>
+                    database.verify(sampleSize === undefined ? settings.VERIFY_SAMPLE_SIZE : sampleSize, ctx)
+                )
+            }
+        )
+    )
+
     return router
 }
+
+/**
+ * Return the path of the document queried by the given request, if any. The path is given
+ * in the query string of most requests and in the body of the others.
+ *
+ * @param req The express request.
+ */
+function requestedPath(req: express.Request): string | undefined {
+    const path: unknown = req.query.path !== undefined ? req.query.path : req.body && req.body.path
+    return typeof path === 'string' ? path : undefined
+}
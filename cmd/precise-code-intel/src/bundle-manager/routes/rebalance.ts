@@ -0,0 +1,181 @@
+import express from 'express'
+import { Logger } from 'winston'
+import { Span } from 'opentracing'
+import { wrap } from 'async-middleware'
+import { pipeline as _pipeline } from 'stream'
+import { promisify } from 'util'
+import * as metrics from '../metrics'
+import * as settings from '../settings'
+import * as validation from '../../shared/api/middleware/validation'
+import { addTags, TracingContext, tracingHeaders } from '../../shared/tracing'
+import { createSilentLogger } from '../../shared/logging'
+import { authorizationHeaders, requireToken } from '../../shared/api/middleware/auth'
+import { idFromFilename } from '../../shared/paths'
+import { ShardRing } from '../../shared/shards'
+import { mapConcurrently } from '../../shared/util'
+import { BundleStore } from '../storage'
+import { createBundleManagerClient } from '../../shared/api/client'
+
+const pipeline = promisify(_pipeline)
+
+/** The HTTP client used to send bundles to other bundle managers. */
+const bundleManagerClient = createBundleManagerClient()
+
+/** The result of a rebalance. */
+export interface RebalanceResult {
+    /** The number of bundles moved (or that would be moved, in a dry run) to another shard. */
+    moved: number
+
+    /** The number of bytes of the moved bundles. */
+    movedBytes: number
+
+    /** The number of bundles that could not be moved. These remain on this shard. */
+    failed: number
+}
+
+/**
+ * Create a router containing the endpoint that moves bundles that belong to another shard
+ * onto that shard. This is called on each existing bundle manager after a shard has been
+ * added to (or before a shard is removed from) `PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS`.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param logger The logger instance.
+ */
+export function createRebalanceRouter(bundleStore: BundleStore, logger: Logger): express.Router {
+    const router = express.Router()
+
+    /**
+     * Create a tracing context from the request logger and tracing span
+     * tagged with the given values.
+     *
+     * @param req The express request.
+     * @param tags The tags to apply to the logger and span.
+     */
+    const createTracingContext = (
+        req: express.Request & { span?: Span },
+        tags: { [K: string]: unknown }
+    ): TracingContext => addTags({ logger, span: req.span }, tags)
+
+    // Only one rebalance runs at a time so that a bundle is not sent twice
+    let running = false
+
+    interface RebalanceQueryArgs {
+        dryRun?: boolean
+    }
+
+    router.post(
+        '/rebalance',
+        requireToken(),
+        validation.validationMiddleware([validation.validateOptionalBoolean('dryRun')]),
+        wrap(
+            async (req: express.Request, res: express.Response<RebalanceResult>): Promise<void> => {
+                const { dryRun }: RebalanceQueryArgs = req.query
+                const ctx = createTracingContext(req, { dryRun })
+
+                const self = settings.BUNDLE_MANAGER_SHARD_URL
+                if (!self || !settings.BUNDLE_MANAGER_URLS.includes(self)) {
+                    throw Object.assign(new Error('This bundle manager is not one of the configured shards'), {
+                        status: 409,
+                        code: 'sharding_not_configured',
+                    })
+                }
+
+                if (running) {
+                    throw Object.assign(new Error('A rebalance is already in progress'), {
+                        status: 409,
+                        code: 'rebalance_in_progress',
+                    })
+                }
+
+                running = true
+                try {
+                    res.json(
+                        await rebalanceBundles(
+                            bundleStore,
+                            new ShardRing(settings.BUNDLE_MANAGER_URLS),
+                            self,
+                            !!dryRun,
+                            ctx
+                        )
+                    )
+                } finally {
+                    running = false
+                }
+            }
+        )
+    )
+
+    return router
+}
+
+/**
+ * Send each bundle in the store that the ring assigns to another shard to that shard, then
+ * remove it from the store. A bundle that fails to transfer is logged and left in place so
+ * that a later rebalance can retry it.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param ring The ring of all shards.
+ * @param self The URL of this shard.
+ * @param dryRun Whether to only count the bundles that would be moved.
+ * @param ctx The tracing context.
+ */
+async function rebalanceBundles(
+    bundleStore: BundleStore,
+    ring: ShardRing,
+    self: string,
+    dryRun: boolean,
+    ctx: TracingContext
+): Promise<RebalanceResult> {
+    const { logger = createSilentLogger() } = ctx
+    const result: RebalanceResult = { moved: 0, movedBytes: 0, failed: 0 }
+
+    const misplaced: { id: number; key: string; shard: string }[] = []
+    for (const key of await bundleStore.list()) {
+        const id = idFromFilename(key)
+        if (id === undefined) {
+            continue
+        }
+
+        const shard = ring.shardFor(id)
+        if (shard !== self) {
+            misplaced.push({ id, key, shard })
+        }
+    }
+
+    await mapConcurrently(misplaced, settings.REBALANCE_CONCURRENCY, async ({ id, key, shard }) => {
+        const size = await bundleStore.size(key)
+        if (dryRun) {
+            result.moved++
+            result.movedBytes += size
+            return
+        }
+
+        try {
+            const url = new URL(`/dbs/${id}`, shard)
+            url.searchParams.set('force', 'true')
+
+            await pipeline(
+                bundleStore.get(key),
+                bundleManagerClient.stream.post(url.href, {
+                    // Allows the receiving shard to detect a truncated payload
+                    headers: { ...tracingHeaders(ctx), ...authorizationHeaders(), 'Content-Length': String(size) },
+                })
+            )
+        } catch (error) {
+            logger.error('Failed to move bundle', { id, shard, error: error && error.message })
+            metrics.rebalancedBundlesCounter.labels('failed').inc()
+            result.failed++
+            return
+        }
+
+        // The bundle is now served by its new shard, so the local copy can be dropped
+        await bundleStore.delete(key)
+        metrics.rebalancedBundlesCounter.labels('moved').inc()
+        metrics.rebalancedBytesCounter.inc(size)
+        result.moved++
+        result.movedBytes += size
+    })
+
+    logger.info(dryRun ? 'Would rebalance bundles' : 'Rebalanced bundles', { ...result })
+    return result
+}
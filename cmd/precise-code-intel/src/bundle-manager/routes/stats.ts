@@ -0,0 +1,21 @@
+import * as settings from '../settings'
+import express from 'express'
+import { wrap } from 'async-middleware'
+import { BundleManagerStats } from '../../shared/stats'
+import { collectStats } from '../stats'
+
+/** Create a router containing the disk and cache usage endpoint. */
+export function createStatsRouter(): express.Router {
+    const router = express.Router()
+
+    router.get(
+        '/stats',
+        wrap(
+            async (req: express.Request, res: express.Response<BundleManagerStats>): Promise<void> => {
+                res.json(await collectStats(settings.STORAGE_ROOT))
+            }
+        )
+    )
+
+    return router
+}
@@ -7,17 +7,34 @@ import { pipeline as _pipeline } from 'stream'
 import { promisify } from 'util'
 import * as fs from 'mz/fs'
 import * as settings from '../settings'
-import { dbFilename, uploadFilename } from '../../shared/paths'
+import * as path from 'path'
+import * as constants from '../../shared/constants'
+import * as validation from '../../shared/api/middleware/validation'
+import { requireToken } from '../../shared/api/middleware/auth'
+import * as uuid from 'uuid'
+import {
+    fsyncPath,
+    uploadChunkFilename,
+    uploadChunkIndexFromFilename,
+    uploadFilename,
+} from '../../shared/paths'
 import { ThrottleGroup, Throttle } from 'stream-throttle'
+import { CHECKSUM_HEADER, ChecksumStream, checksumMismatchError } from '../../shared/checksum'
+import { json } from 'body-parser'
+import { body } from 'express-validator'
+import { Readable } from 'stream'
+import { BundleStore, bundleKey } from '../storage'
+import { storeBundle, verifyPayloadSize } from '../bundles'
 
 const pipeline = promisify(_pipeline)
 
 /**
  * Create a router containing the upload endpoints.
  *
+ * @param bundleStore The store of converted bundles.
  * @param logger The logger instance.
  */
-export function createUploadRouter(logger: Logger): express.Router {
+export function createUploadRouter(bundleStore: BundleStore, logger: Logger): express.Router {
     const router = express.Router()
 
     const makeServeThrottle = makeThrottleFactory(
@@ -49,21 +66,166 @@ export function createUploadRouter(logger: Logger): express.Router {
                 const id = parseInt(req.params.id, 10)
                 const ctx = createTracingContext(req, { id })
                 const filename = uploadFilename(settings.STORAGE_ROOT, id)
+
                 const stream = fs.createReadStream(filename)
                 await logAndTraceCall(ctx, 'Serving payload', () => pipeline(stream, makeServeThrottle(), res))
             }
         )
     )
 
+    interface ForceQueryArgs {
+        force?: boolean
+    }
+
     router.post(
         '/uploads/:id([0-9]+)',
+        requireToken(),
+        validation.validationMiddleware([validation.validateOptionalBoolean('force')]),
         wrap(
             async (req: express.Request, res: express.Response<unknown>): Promise<void> => {
                 const id = parseInt(req.params.id, 10)
+                const { force }: ForceQueryArgs = req.query
                 const ctx = createTracingContext(req, { id })
                 const filename = uploadFilename(settings.STORAGE_ROOT, id)
-                const stream = fs.createWriteStream(filename)
-                await logAndTraceCall(ctx, 'Uploading payload', () => pipeline(req, makeUploadThrottle(), stream))
+                await ensureNotExists(filename, force)
+
+                await writeFileAtomically(filename, async tempFilename => {
+                    const checksum = new ChecksumStream()
+                    await logAndTraceCall(ctx, 'Uploading payload', () =>
+                        pipeline(req, makeUploadThrottle(), checksum, fs.createWriteStream(tempFilename))
+                    )
+
+                    await verifyContentLength(req, tempFilename)
+                    verifyChecksum(req.header(CHECKSUM_HEADER), checksum.digest())
+                })
+
+                res.send()
+            }
+        )
+    )
+
+    interface UploadChunkResponse {
+        checksum: string
+    }
+
+    router.post(
+        '/uploads/:id([0-9]+)/:index([0-9]+)',
+        requireToken(),
+        wrap(
+            async (req: express.Request, res: express.Response<UploadChunkResponse>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const index = parseInt(req.params.index, 10)
+                const ctx = createTracingContext(req, { id, index })
+
+                const expected = req.header(CHECKSUM_HEADER)
+                if (!expected) {
+                    throw Object.assign(new Error(`Missing ${CHECKSUM_HEADER} header`), {
+                        status: 400,
+                        code: 'missing_checksum',
+                    })
+                }
+
+                // Chunks may be re-sent by the client after a failed transfer, so they are
+                // always overwritten. Writing atomically ensures that a partially received
+                // chunk is never mistaken for a complete one.
+                const filename = uploadChunkFilename(settings.STORAGE_ROOT, id, index)
+                const checksum = new ChecksumStream()
+                let actual = ''
+
+                await writeFileAtomically(filename, async tempFilename => {
+                    await logAndTraceCall(ctx, 'Uploading chunk', () =>
+                        pipeline(req, makeUploadThrottle(), checksum, fs.createWriteStream(tempFilename))
+                    )
+
+                    await verifyContentLength(req, tempFilename)
+                    actual = checksum.digest()
+                    verifyChecksum(expected, actual)
+                })
+
+                res.send({ checksum: actual })
+            }
+        )
+    )
+
+    type ChunksResponse = { index: number; size: number }[]
+
+    router.get(
+        '/uploads/:id([0-9]+)/chunks',
+        wrap(
+            async (req: express.Request, res: express.Response<ChunksResponse>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const chunks = await findChunks(id)
+
+                res.send(
+                    await Promise.all(
+                        Array.from(chunks).map(async ([index, filename]) => ({
+                            index,
+                            size: (await fs.stat(filename)).size,
+                        }))
+                    )
+                )
+            }
+        )
+    )
+
+    interface StitchBody {
+        numChunks: number
+        checksum?: string
+    }
+
+    router.post(
+        '/uploads/:id([0-9]+)/stitch',
+        requireToken(),
+        json(),
+        validation.validationMiddleware([
+            body('numChunks').isInt({ min: 1 }).toInt(),
+            body('checksum').optional().isString(),
+            validation.validateOptionalBoolean('force'),
+        ]),
+        wrap(
+            async (req: express.Request, res: express.Response<unknown>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const { numChunks, checksum: expectedChecksum }: StitchBody = req.body
+                const { force }: ForceQueryArgs = req.query
+                const ctx = createTracingContext(req, { id, numChunks })
+
+                const filename = uploadFilename(settings.STORAGE_ROOT, id)
+                await ensureNotExists(filename, force)
+
+                const chunks = await findChunks(id)
+                const filenames = []
+                const missing = []
+                for (let index = 0; index < numChunks; index++) {
+                    const chunkFilename = chunks.get(index)
+                    if (chunkFilename) {
+                        filenames.push(chunkFilename)
+                    } else {
+                        missing.push(index)
+                    }
+                }
+
+                if (missing.length > 0) {
+                    throw Object.assign(new Error(`Missing chunks ${missing.join(', ')}`), {
+                        status: 400,
+                        code: 'missing_chunks',
+                    })
+                }
+
+                await writeFileAtomically(filename, async tempFilename => {
+                    const checksum = new ChecksumStream()
+                    await logAndTraceCall(ctx, 'Stitching chunks', () =>
+                        pipeline(
+                            Readable.from(concatenateFiles(filenames)),
+                            checksum,
+                            fs.createWriteStream(tempFilename)
+                        )
+                    )
+
+                    // Chunks are retained on mismatch so that the client can re-send the bad ones
+                    verifyChecksum(expectedChecksum, checksum.digest())
+                })
+
+                await Promise.all(Array.from(chunks.values()).map(chunkFilename => fs.unlink(chunkFilename)))
                 res.send()
             }
         )
@@ -71,13 +233,34 @@ export function createUploadRouter(logger: Logger): express.Router {
 
     router.post(
         '/dbs/:id([0-9]+)',
+        requireToken(),
+        validation.validationMiddleware([validation.validateOptionalBoolean('force')]),
         wrap(
             async (req: express.Request, res: express.Response<unknown>): Promise<void> => {
                 const id = parseInt(req.params.id, 10)
+                const { force }: ForceQueryArgs = req.query
                 const ctx = createTracingContext(req, { id })
-                const filename = dbFilename(settings.STORAGE_ROOT, id)
-                const stream = fs.createWriteStream(filename)
-                await logAndTraceCall(ctx, 'Uploading payload', () => pipeline(req, makeUploadThrottle(), stream))
+
+                await storeBundle(bundleStore, id, force, ctx, async tempFilename => {
+                    await pipeline(req, makeUploadThrottle(), fs.createWriteStream(tempFilename))
+                    await verifyContentLength(req, tempFilename)
+                })
+
+                res.send()
+            }
+        )
+    )
+
+    router.delete(
+        '/dbs/:id([0-9]+)',
+        requireToken(),
+        wrap(
+            async (req: express.Request, res: express.Response<unknown>): Promise<void> => {
+                const id = parseInt(req.params.id, 10)
+                const ctx = createTracingContext(req, { id })
+
+                // Removing a bundle that does not exist is not an error
+                await logAndTraceCall(ctx, 'Removing bundle', () => bundleStore.delete(bundleKey(id)))
                 res.send()
             }
         )
@@ -86,6 +269,110 @@ export function createUploadRouter(logger: Logger): express.Router {
     return router
 }
 
+/**
+ * Throw a checksum mismatch error if the digest supplied by the client does not match
+ * the digest of the received payload. Payloads without an expected digest are not verified.
+ *
+ * @param expected The hex-encoded digest supplied by the client.
+ * @param actual The hex-encoded digest of the received payload.
+ */
+function verifyChecksum(expected: string | undefined, actual: string): void {
+    if (expected && expected.toLowerCase() !== actual) {
+        throw checksumMismatchError(expected, actual)
+    }
+}
+
+/**
+ * Throw an incomplete payload error if the size of the received payload differs from the
+ * Content-Length of the request. This catches payloads that were cut short without the
+ * connection reporting an error. The payload was not stored, so this is reported as a
+ * server error. Requests without a Content-Length are not verified.
+ *
+ * @param req The express request.
+ * @param filename The file containing the received payload.
+ */
+async function verifyContentLength(req: express.Request, filename: string): Promise<void> {
+    const expected = parseInt(req.header('Content-Length') || '', 10)
+    if (!isNaN(expected)) {
+        await verifyPayloadSize(expected, filename)
+    }
+}
+
+/**
+ * Throw a conflict error if the given upload already exists, unless the client has asked
+ * to replace it.
+ *
+ * @param filename The file that is about to be written.
+ * @param force Whether the client has asked to replace an existing file.
+ */
+async function ensureNotExists(filename: string, force: boolean | undefined): Promise<void> {
+    if (!force && (await fs.exists(filename))) {
+        throw Object.assign(new Error('Upload already exists'), { status: 409, code: 'upload_exists' })
+    }
+}
+
+/**
+ * Write a file so that it is either completely written or not present at all. The given
+ * function writes the contents to a temporary file in the uploads directory, which is then
+ * flushed to disk and moved into place. The target directory is then flushed so that the
+ * rename survives a crash. The temporary file is removed if the function throws.
+ * Temporary files abandoned by a crash are removed by cleanFailedUploads.
+ *
+ * @param filename The file to write.
+ * @param write The function that writes the contents to the given temporary file.
+ */
+async function writeFileAtomically(filename: string, write: (tempFilename: string) => Promise<void>): Promise<void> {
+    const tempFilename = path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR, `${uuid.v4()}.tmp`)
+
+    try {
+        await write(tempFilename)
+
+        // Ensure the contents are durable before the file becomes visible
+        await fsyncPath(tempFilename)
+        await fs.rename(tempFilename, filename)
+    } catch (error) {
+        await fs.unlink(tempFilename).catch(() => {
+            /* noop */
+        })
+
+        throw error
+    }
+
+    // Ensure the rename is durable, so that the file does not disappear after a crash
+    await fsyncPath(path.dirname(filename))
+}
+
+/**
+ * Return a map from chunk index to the path of each chunk of the given upload that has
+ * been completely received.
+ *
+ * @param id The identifier of the upload.
+ */
+async function findChunks(id: number): Promise<Map<number, string>> {
+    const directory = path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR)
+
+    const chunks = new Map<number, string>()
+    for (const basename of await fs.readdir(directory)) {
+        const index = uploadChunkIndexFromFilename(basename, id)
+        if (index !== undefined) {
+            chunks.set(index, path.join(directory, basename))
+        }
+    }
+
+    return chunks
+}
+
+/**
+ * Yield the contents of each of the given files in order.
+ *
+ * @param filenames The files to read.
+ */
+async function* concatenateFiles(filenames: string[]): AsyncIterable<Buffer> {
+    for (const filename of filenames) {
+        yield* fs.createReadStream(filename)
+    }
+}
+
 /**
  * Create a function that will create a throttle that can be used as a stream
  * transformer. This transformer can limit both readable and writable streams.
@@ -93,7 +380,7 @@ export function createUploadRouter(logger: Logger): express.Router {
  * @param rate The maximum bit second of the stream.
  * @param chunksize The size of chunks used to break down larger slices of data.
  */
-function makeThrottleFactory(rate: number, chunksize: number): () => Throttle {
+export function makeThrottleFactory(rate: number, chunksize: number): () => Throttle {
     const opts = { rate, chunksize }
     const throttleGroup = new ThrottleGroup(opts)
     return () => throttleGroup.throttle(opts)
@@ -1,4 +1,5 @@
-import { readEnvInt } from '../shared/settings'
+import { readEnvInt, readEnvIntWithDeprecatedFallback } from '../shared/settings'
+import { parseShardUrls } from '../shared/shards'
 
 /** Which port to run the bundle manager API on. Defaults to 3187. */
 export const HTTP_PORT = readEnvInt('HTTP_PORT', 3187)
@@ -7,21 +8,147 @@ export const HTTP_PORT = readEnvInt('HTTP_PORT', 3187)
 export const PRECISE_CODE_INTEL_API_SERVER_URL =
     process.env.PRECISE_CODE_INTEL_API_SERVER_URL || 'http://localhost:3186'
 
+/**
+ * HTTP addresses of all bundle manager shards, as a comma-separated list in the same order
+ * and form given to the API server and worker. Empty when bundles are not sharded.
+ */
+export const BUNDLE_MANAGER_URLS = parseShardUrls(process.env.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS || '')
+
+/** HTTP address of this bundle manager as it appears in BUNDLE_MANAGER_URLS. */
+export const BUNDLE_MANAGER_SHARD_URL = parseShardUrls(process.env.PRECISE_CODE_INTEL_BUNDLE_MANAGER_SHARD_URL || '')[0]
+
+/**
+ * The maximum time (in seconds) to spend on a query of a single bundle before responding with
+ * a 503 and abandoning the work performed on behalf of the request (<= 0 means no limit). This
+ * should be shorter than the timeout of the API server so that the API server sees the error.
+ */
+export const QUERY_REQUEST_TIMEOUT = readEnvInt('QUERY_REQUEST_TIMEOUT', 30)
+
 /** Where on the file system to store LSIF files. This should be a persistent volume. */
 export const STORAGE_ROOT = process.env.LSIF_STORAGE_ROOT || 'lsif-storage'
 
 /**
- * The number of SQLite connections that can be opened at once. This
- * value may be exceeded for a short period if many handles are held
- * at once.
+ * Where converted bundles are stored: `filesystem` stores bundles under the storage root,
+ * and `s3` and `gcs` store bundles in a bucket of Amazon S3 (or another S3-compatible
+ * object store) and Google Cloud Storage.
+ */
+export const BUNDLE_STORAGE_BACKEND = process.env.BUNDLE_STORAGE_BACKEND || 'filesystem'
+
+/** The bucket that holds the bundles of the `s3` and `gcs` storage backends. */
+export const BUNDLE_STORAGE_BUCKET = process.env.BUNDLE_STORAGE_BUCKET || ''
+
+/**
+ * The prefix of the keys of the objects holding bundles, e.g. `bundles/`. Shards that share
+ * a bucket must use different prefixes.
+ */
+export const BUNDLE_STORAGE_PREFIX = process.env.BUNDLE_STORAGE_PREFIX || ''
+
+/**
+ * The URL of the object store, e.g. of a MinIO server. Defaults to the public endpoint of
+ * the storage backend.
+ */
+export const BUNDLE_STORAGE_ENDPOINT = process.env.BUNDLE_STORAGE_ENDPOINT || ''
+
+/** The region of an S3 bucket. Defaults to `us-east-1`. */
+export const BUNDLE_STORAGE_REGION = process.env.BUNDLE_STORAGE_REGION || ''
+
+/**
+ * The identifier of the access key used to access an S3 bucket. If unset, credentials are
+ * read from the default credential chain of the AWS SDK (e.g. `AWS_ACCESS_KEY_ID` or the
+ * instance role). GCS buckets are accessed with the application default credentials.
+ */
+export const BUNDLE_STORAGE_ACCESS_KEY_ID = process.env.BUNDLE_STORAGE_ACCESS_KEY_ID || ''
+
+/** The secret of the access key used to access an S3 bucket. */
+export const BUNDLE_STORAGE_SECRET_ACCESS_KEY = process.env.BUNDLE_STORAGE_SECRET_ACCESS_KEY || ''
+
+/**
+ * The maximum space (in bytes) used by bundles downloaded from object storage to the local
+ * disk. Bundles are queried from this cache, so it should hold the bundles queried often.
+ */
+export const BUNDLE_CACHE_MAXIMUM_SIZE_BYTES = readEnvInt('BUNDLE_CACHE_MAXIMUM_SIZE_BYTES', 1024 * 1024 * 1024 * 10)
+
+/**
+ * The number of SQLite bundles that can be opened at once. Each open bundle holds
+ * between one and `SQLITE_MAX_OPEN_CONNECTIONS` connections. This value may be
+ * exceeded for a short period if many handles are held at once.
  */
 export const CONNECTION_CACHE_CAPACITY = readEnvInt('CONNECTION_CACHE_CAPACITY', 100)
 
-/** The maximum number of documents that can be held in memory at once. */
-export const DOCUMENT_CACHE_CAPACITY = readEnvInt('DOCUMENT_CACHE_CAPACITY', 1024 * 1024 * 1024)
+/**
+ * The maximum age (in seconds) of cached SQLite connections, documents, and result chunks
+ * (< 0 means no limit). Entries are normally invalidated when a bundle is replaced, so this
+ * only bounds how long stale data can be served if that does not happen.
+ */
+export const CACHE_ENTRY_TTL = readEnvInt('CACHE_ENTRY_TTL', 60 * 60) // 1 hour
+
+/** The maximum number of bytes of each open SQLite bundle to memory-map. */
+export const SQLITE_MMAP_SIZE_BYTES = readEnvInt('SQLITE_MMAP_SIZE_BYTES', 1024 * 1024 * 256) // 256 MiB
+
+/** The maximum number of kibibytes used by the page cache of each open SQLite bundle. */
+export const SQLITE_CACHE_SIZE_KIB = readEnvInt('SQLITE_CACHE_SIZE_KIB', 1024 * 8) // 8 MiB
+
+/**
+ * The maximum number of SQLite connections open at once for each bundle held by the connection
+ * cache. Queries of a bundle beyond this limit wait for a connection to be released.
+ */
+export const SQLITE_MAX_OPEN_CONNECTIONS = readEnvInt('SQLITE_MAX_OPEN_CONNECTIONS', 2)
+
+/** The maximum number of unused SQLite connections kept open for each bundle held by the connection cache. */
+export const SQLITE_MAX_IDLE_CONNECTIONS = readEnvInt('SQLITE_MAX_IDLE_CONNECTIONS', 1)
+
+/** The number of milliseconds a query of a SQLite bundle waits for a lock before failing. */
+export const SQLITE_BUSY_TIMEOUT_MS = readEnvInt('SQLITE_BUSY_TIMEOUT_MS', 5000)
+
+/** The maximum number of prepared statements kept for each open SQLite connection (0 disables caching). */
+export const SQLITE_STATEMENT_CACHE_SIZE = readEnvInt('SQLITE_STATEMENT_CACHE_SIZE', 16)
+
+/**
+ * The maximum number of bytes (estimated) that decoded documents can occupy in memory at once.
+ * Falls back to the deprecated DOCUMENT_CACHE_CAPACITY.
+ */
+export const DOCUMENT_CACHE_MEMORY_BUDGET_BYTES = readEnvIntWithDeprecatedFallback(
+    'DOCUMENT_CACHE_MEMORY_BUDGET_BYTES',
+    'DOCUMENT_CACHE_CAPACITY',
+    1024 * 1024 * 512 // 512 MiB
+)
+
+/**
+ * The maximum number of bytes (estimated) that decoded result chunks can occupy in memory at once.
+ * Falls back to the deprecated RESULT_CHUNK_CACHE_CAPACITY.
+ */
+export const RESULT_CHUNK_CACHE_MEMORY_BUDGET_BYTES = readEnvIntWithDeprecatedFallback(
+    'RESULT_CHUNK_CACHE_MEMORY_BUDGET_BYTES',
+    'RESULT_CHUNK_CACHE_CAPACITY',
+    1024 * 1024 * 512 // 512 MiB
+)
+
+/** The number of documents and result chunks of a bundle decoded when verifying its integrity. */
+export const VERIFY_SAMPLE_SIZE = readEnvInt('VERIFY_SAMPLE_SIZE', 100)
+
+/**
+ * The number of most recently accessed bundles to open on startup before the bundle manager
+ * reports ready (0 disables the warm-up). At most `CONNECTION_CACHE_CAPACITY` bundles are opened.
+ */
+export const WARMUP_BUNDLE_COUNT = readEnvInt('WARMUP_BUNDLE_COUNT', 0)
 
-/** The maximum number of result chunks that can be held in memory at once. */
-export const RESULT_CHUNK_CACHE_CAPACITY = readEnvInt('RESULT_CHUNK_CACHE_CAPACITY', 1024 * 1024 * 1024)
+/**
+ * The number of most recently queried documents of each warmed bundle that are read into the
+ * document cache, along with the result chunks they refer to, during the warm-up.
+ */
+export const WARMUP_DOCUMENTS_PER_BUNDLE = readEnvInt('WARMUP_DOCUMENTS_PER_BUNDLE', 16)
+
+/** The maximum number of bundles opened at once during the warm-up. */
+export const WARMUP_CONCURRENCY = readEnvInt('WARMUP_CONCURRENCY', 4)
+
+/** The maximum time (in seconds) to spend warming up bundles on startup. */
+export const WARMUP_TIME_BUDGET = readEnvInt('WARMUP_TIME_BUDGET', 60)
+
+/** The interval (in seconds) to write the log of recently accessed bundles to disk. */
+export const SAVE_ACCESS_LOG_INTERVAL = readEnvInt('SAVE_ACCESS_LOG_INTERVAL', 60)
+
+/** The percentage of the storage root's filesystem that must be free for the bundle manager to report ready. */
+export const READINESS_MIN_FREE_SPACE_PERCENT = readEnvInt('READINESS_MIN_FREE_SPACE_PERCENT', 5)
 
 /** The interval (in seconds) to clean the dbs directory. */
 export const PURGE_OLD_DUMPS_INTERVAL = readEnvInt('PURGE_OLD_DUMPS_INTERVAL', 60 * 30)
@@ -38,6 +165,30 @@ export const CLEAN_FAILED_UPLOADS_INTERVAL = readEnvInt('CLEAN_FAILED_UPLOADS_IN
 /** The maximum age (in seconds) that the files for an unprocessed upload can remain on disk. */
 export const FAILED_UPLOAD_MAX_AGE = readEnvInt('FAILED_UPLOAD_MAX_AGE', 24 * 60 * 60)
 
+/** The maximum number of files the janitor stats or removes concurrently. */
+export const JANITOR_CONCURRENCY = readEnvInt('JANITOR_CONCURRENCY', 10)
+
+/**
+ * The maximum time (in seconds) that a single run of a janitor task may spend on disk cleanup
+ * (< 0 means no limit). Work left over when the budget runs out is picked up by the next run.
+ */
+export const JANITOR_TIME_BUDGET = readEnvInt('JANITOR_TIME_BUDGET', 60 * 5) // 5 minutes
+
+/** The interval (in seconds) to report the dumps that have been queried to the API server. */
+export const REPORT_ACCESS_INTERVAL = readEnvInt('REPORT_ACCESS_INTERVAL', 60)
+
+/** The interval (in seconds) to migrate bundles written with an older schema version. */
+export const MIGRATE_BUNDLES_INTERVAL = readEnvInt('MIGRATE_BUNDLES_INTERVAL', 60 * 10)
+
+/**
+ * The maximum time (in seconds) that a single run of the bundle migration may take (< 0 means
+ * no limit). Bundles left over when the budget runs out are migrated by the next run.
+ */
+export const MIGRATE_BUNDLES_TIME_BUDGET = readEnvInt('MIGRATE_BUNDLES_TIME_BUDGET', 60 * 5) // 5 minutes
+
+/** The maximum number of bundles sent to other shards at once while rebalancing. */
+export const REBALANCE_CONCURRENCY = readEnvInt('REBALANCE_CONCURRENCY', 4)
+
 /** How many times to retry requests to precise-code-intel-api-server in the background. */
 export const MAX_REQUEST_RETRIES = readEnvInt('MAX_REQUEST_RETRIES', 60)
 
@@ -58,3 +209,6 @@ export const MAXIMUM_UPLOAD_BYTES_PER_SECOND = readEnvInt('MAXIMUM_UPLOAD_BYTES_
 
 /** The maximum chunk size the server will use to receive upload payloads. */
 export const MAXIMUM_UPLOAD_CHUNK_BYTES = readEnvInt('MAXIMUM_UPLOAD_CHUNK_BYTES', 1024 * 1024 * 10) // 10 MiB
+
+/** The maximum time (in seconds) to wait for in-flight requests and tasks to complete on shutdown. */
+export const SHUTDOWN_TIMEOUT = readEnvInt('SHUTDOWN_TIMEOUT', 30)
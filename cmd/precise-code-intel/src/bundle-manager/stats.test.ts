@@ -0,0 +1,27 @@
+import { parseFreeSpacePercent } from './stats'
+
+describe('parseFreeSpacePercent', () => {
+    it('should compute the available percentage', () => {
+        const output = [
+            'Filesystem     1024-blocks     Used Available Capacity Mounted on',
+            '/dev/sda1         1000000   750000    250000      75% /lsif-storage',
+            '',
+        ].join('\n')
+
+        expect(parseFreeSpacePercent(output)).toEqual(25)
+    })
+
+    it('should handle names containing whitespace', () => {
+        const output = [
+            'Filesystem     1024-blocks     Used Available Capacity Mounted on',
+            'map auto_home        4000     1000      3000      25% /System/Volumes/Data/home dir',
+            '',
+        ].join('\n')
+
+        expect(parseFreeSpacePercent(output)).toEqual(75)
+    })
+
+    it('should throw on unexpected output', () => {
+        expect(() => parseFreeSpacePercent('df: /missing: No such file or directory\n')).toThrow('Unexpected df output')
+    })
+})
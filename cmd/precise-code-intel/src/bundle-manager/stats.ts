@@ -0,0 +1,88 @@
+import * as constants from '../shared/constants'
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import { child_process } from 'mz'
+import { Database } from './backend/database'
+import { BundleManagerStats } from '../shared/stats'
+import { dirsize, idFromFilename } from '../shared/paths'
+import { DependencyCheck } from '../shared/api/readiness'
+import { migrationTracker } from './backend/migrations'
+
+/**
+ * Calculate the disk usage of the given storage root, the occupancy of the in-memory caches,
+ * and the progress of the background migration of bundles.
+ *
+ * @param storageRoot The path where uploads and SQLite databases are stored.
+ */
+export async function collectStats(storageRoot: string): Promise<BundleManagerStats> {
+    const dbsDirectory = path.join(storageRoot, constants.DBS_DIR)
+    const uploadsDirectory = path.join(storageRoot, constants.UPLOADS_DIR)
+
+    const [basenames, dbsBytes, uploadsBytes, freeSpacePercent] = await Promise.all([
+        fs.readdir(dbsDirectory),
+        dirsize(dbsDirectory),
+        dirsize(uploadsDirectory),
+        freeSpace(storageRoot),
+    ])
+
+    return {
+        numBundles: basenames.filter(basename => idFromFilename(basename) !== undefined).length,
+        totalBytes: uploadsBytes + dbsBytes,
+        uploadsBytes,
+        dbsBytes,
+        freeSpacePercent,
+        caches: Database.cacheOccupancy(),
+        migrations: migrationTracker.progress(),
+    }
+}
+
+/**
+ * Create a check that the filesystem containing the given storage root has at least the
+ * given percentage of free space.
+ *
+ * @param storageRoot The path where uploads and SQLite databases are stored.
+ * @param minFreeSpacePercent The minimum percentage of free space.
+ */
+export function checkFreeSpace(storageRoot: string, minFreeSpacePercent: number): DependencyCheck {
+    return {
+        name: 'disk',
+        check: async () => {
+            const freeSpacePercent = await freeSpace(storageRoot)
+            if (freeSpacePercent < minFreeSpacePercent) {
+                throw new Error(
+                    `${freeSpacePercent.toFixed(1)}% of disk space is free, less than the minimum of ${minFreeSpacePercent}%`
+                )
+            }
+        },
+    }
+}
+
+/**
+ * Return the percentage of the filesystem containing the given path that is available to
+ * unprivileged users. This shells out to `df` as node does not expose `statfs`.
+ *
+ * @param directory The directory path.
+ */
+async function freeSpace(directory: string): Promise<number> {
+    const [stdout] = await child_process.execFile('df', ['-Pk', directory])
+    return parseFreeSpacePercent(stdout)
+}
+
+/**
+ * Return the percentage of available blocks from the output of `df -P`, which consists of
+ * a header line and a line with the fields `filesystem total used available capacity mount`.
+ * The numeric fields are matched by position relative to the capacity column so that
+ * filesystem and mount names containing whitespace are handled.
+ *
+ * @param output The output of `df -P`.
+ */
+export function parseFreeSpacePercent(output: string): number {
+    const match = output.match(/\s(\d+)\s+(\d+)\s+(\d+)\s+\d+%\s/)
+    if (!match) {
+        throw new Error(`Unexpected df output: ${output}`)
+    }
+
+    const total = parseInt(match[1], 10)
+    const available = parseInt(match[3], 10)
+    return total === 0 ? 0 : (available / total) * 100
+}
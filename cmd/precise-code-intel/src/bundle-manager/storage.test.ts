@@ -0,0 +1,218 @@
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import rmfr from 'rmfr'
+import { bundleKey, createBundleStore, FilesystemBundleStore, ObjectStoreBundleStore } from './storage'
+import { ObjectStore } from './objectstore'
+import { Readable } from 'stream'
+
+const read = async (stream: Readable): Promise<string> => {
+    const chunks: Buffer[] = []
+    for await (const chunk of stream) {
+        chunks.push(chunk)
+    }
+    return Buffer.concat(chunks).toString()
+}
+
+describe('FilesystemBundleStore', () => {
+    let tempPath!: string
+
+    beforeEach(async () => {
+        tempPath = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterEach(async () => {
+        await rmfr(tempPath)
+    })
+
+    const writeTempFile = async (name: string, contents: string): Promise<string> => {
+        const filename = path.join(tempPath, name)
+        await fs.writeFile(filename, contents)
+        return filename
+    }
+
+    it('should round-trip bundles', async () => {
+        const invalidated: string[] = []
+        const store = new FilesystemBundleStore(tempPath, filename => {
+            invalidated.push(path.basename(filename))
+            return Promise.resolve()
+        })
+
+        await store.put(bundleKey(1), await writeTempFile('1.tmp', 'foobar'))
+        await store.put(bundleKey(2), await writeTempFile('2.tmp', 'baz'))
+
+        expect((await store.list()).sort()).toEqual([bundleKey(1), bundleKey(2)])
+        expect(await read(store.get(bundleKey(1)))).toEqual('foobar')
+        expect(await store.size(bundleKey(2))).toEqual(3)
+
+        await store.delete(bundleKey(1))
+        await store.delete(bundleKey(1))
+        expect(await store.list()).toEqual([bundleKey(2)])
+        expect(await store.size(bundleKey(1))).toEqual(0)
+        expect(invalidated).toEqual([bundleKey(1), bundleKey(2), bundleKey(1), bundleKey(1)])
+    })
+
+    it('should not list partially written bundles', async () => {
+        await fs.writeFile(path.join(tempPath, `${bundleKey(1)}.partial.tmp`), 'foo')
+
+        const store = new FilesystemBundleStore(tempPath)
+        expect(await store.list()).toEqual([])
+    })
+
+    it('should not address files outside of its directory', async () => {
+        const store = new FilesystemBundleStore(path.join(tempPath, 'dbs'))
+        await fs.mkdir(path.join(tempPath, 'dbs'))
+        await store.put('../escape', await writeTempFile('escape.tmp', 'foo'))
+
+        expect(await store.list()).toEqual(['escape'])
+        expect(await fs.exists(path.join(tempPath, 'escape'))).toBeFalsy()
+    })
+
+    it('should give missing bundles no local file', async () => {
+        const store = new FilesystemBundleStore(tempPath)
+        await store.put(bundleKey(1), await writeTempFile('1.tmp', 'foo'))
+
+        expect(await store.withLocalFile(bundleKey(1), filename => Promise.resolve(filename))).toEqual(
+            path.join(tempPath, bundleKey(1))
+        )
+        expect(await store.withLocalFile(bundleKey(2), filename => Promise.resolve(filename))).toBeUndefined()
+    })
+})
+
+/** An object store that holds objects in memory and counts downloads. */
+class MemoryObjectStore implements ObjectStore {
+    public objects = new Map<string, string>()
+    public downloads = 0
+
+    public async putFile(key: string, filename: string): Promise<void> {
+        this.objects.set(key, await fs.readFile(filename, 'utf8'))
+    }
+
+    public get(key: string): Readable {
+        return Readable.from([this.objects.get(key) || ''])
+    }
+
+    public async download(key: string, filename: string): Promise<boolean> {
+        const contents = this.objects.get(key)
+        if (contents === undefined) {
+            return false
+        }
+
+        this.downloads++
+        await fs.writeFile(filename, contents)
+        return true
+    }
+
+    public size(key: string): Promise<number | undefined> {
+        const contents = this.objects.get(key)
+        return Promise.resolve(contents === undefined ? undefined : contents.length)
+    }
+
+    public delete(key: string): Promise<void> {
+        this.objects.delete(key)
+        return Promise.resolve()
+    }
+
+    public list(prefix: string): Promise<string[]> {
+        return Promise.resolve(Array.from(this.objects.keys()).filter(key => key.startsWith(prefix)))
+    }
+}
+
+describe('ObjectStoreBundleStore', () => {
+    let objectStore!: MemoryObjectStore
+    let tempPath!: string
+
+    beforeEach(async () => {
+        objectStore = new MemoryObjectStore()
+        tempPath = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterEach(async () => {
+        await rmfr(tempPath)
+    })
+
+    const createStore = (cacheMaxSizeBytes = 1024): ObjectStoreBundleStore =>
+        new ObjectStoreBundleStore(objectStore, 'bundles/', tempPath, cacheMaxSizeBytes)
+
+    const readLocalFile = (store: ObjectStoreBundleStore, key: string): Promise<string | undefined> =>
+        store.withLocalFile(key, async filename => (filename ? fs.readFile(filename, 'utf8') : undefined))
+
+    it('should download bundles once', async () => {
+        objectStore.objects.set(`bundles/${bundleKey(1)}`, 'foobar')
+
+        const store = createStore()
+        expect(await readLocalFile(store, bundleKey(1))).toEqual('foobar')
+        expect(await readLocalFile(store, bundleKey(1))).toEqual('foobar')
+        expect(objectStore.downloads).toEqual(1)
+    })
+
+    it('should download bundles again once evicted', async () => {
+        objectStore.objects.set(`bundles/${bundleKey(1)}`, 'foobar')
+        objectStore.objects.set(`bundles/${bundleKey(2)}`, 'bazbonk')
+
+        const store = createStore(10)
+        expect(await readLocalFile(store, bundleKey(1))).toEqual('foobar')
+        expect(await readLocalFile(store, bundleKey(2))).toEqual('bazbonk')
+        expect(await readLocalFile(store, bundleKey(1))).toEqual('foobar')
+        expect(objectStore.downloads).toEqual(3)
+    })
+
+    it('should give missing bundles no local file', async () => {
+        const store = createStore()
+        expect(await readLocalFile(store, bundleKey(1))).toBeUndefined()
+        expect(await fs.readdir(tempPath)).toEqual([])
+    })
+
+    it('should serve uploaded bundles from the cache', async () => {
+        const filename = path.join(tempPath, 'upload.tmp')
+        await fs.writeFile(filename, 'foobar')
+
+        const store = createStore()
+        await store.put(bundleKey(1), filename)
+        expect(objectStore.objects.get(`bundles/${bundleKey(1)}`)).toEqual('foobar')
+        expect(await readLocalFile(store, bundleKey(1))).toEqual('foobar')
+        expect(await fs.exists(filename)).toBeFalsy()
+        expect(objectStore.downloads).toEqual(0)
+    })
+
+    it('should remove the local copy of deleted bundles', async () => {
+        objectStore.objects.set(`bundles/${bundleKey(1)}`, 'foobar')
+
+        const store = createStore()
+        expect(await readLocalFile(store, bundleKey(1))).toEqual('foobar')
+        await store.delete(bundleKey(1))
+        expect(objectStore.objects.size).toEqual(0)
+        expect(await fs.readdir(tempPath)).toEqual([])
+    })
+
+    it('should list bundles under its prefix', async () => {
+        objectStore.objects.set(`bundles/${bundleKey(1)}`, 'foobar')
+        objectStore.objects.set(`bundles/nested/${bundleKey(2)}`, 'bazbonk')
+        objectStore.objects.set(`other/${bundleKey(3)}`, 'quux')
+
+        expect(await createStore().list()).toEqual([bundleKey(1)])
+    })
+})
+
+describe('createBundleStore', () => {
+    it('should reject unsupported backends', async () => {
+        await expect(createBundleStore({ backend: 'filesystem', storageRoot: 'lsif-storage' })).resolves.toBeTruthy()
+        await expect(createBundleStore({ backend: 'ftp', storageRoot: 'lsif-storage' })).rejects.toThrow(
+            'Unknown bundle storage backend'
+        )
+    })
+
+    it('should reject object store backends without a bucket', async () => {
+        await expect(createBundleStore({ backend: 's3', storageRoot: 'lsif-storage' })).rejects.toThrow(
+            'requires a bucket'
+        )
+        await expect(createBundleStore({ backend: 'gcs', storageRoot: 'lsif-storage' })).rejects.toThrow(
+            'requires a bucket'
+        )
+    })
+
+    it('should reject an access key id without a secret key', async () => {
+        await expect(
+            createBundleStore({ backend: 's3', storageRoot: 'lsif-storage', bucket: 'lsif', accessKeyId: 'id' })
+        ).rejects.toThrow('requires both an access key id and a secret key')
+    })
+})
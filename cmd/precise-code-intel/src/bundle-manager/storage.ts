@@ -0,0 +1,380 @@
+import * as constants from '../shared/constants'
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import * as uuid from 'uuid'
+import rmfr from 'rmfr'
+import { Readable } from 'stream'
+import { dbFilename, ensureDirectory, filesize, fsyncPath } from '../shared/paths'
+import { BundleFileCache, CachedBundle } from './backend/cache'
+import { GcsObjectStore, ObjectStore, S3ObjectStore } from './objectstore'
+
+/**
+ * A store of converted bundles. Bundles are addressed by a key, which is the basename of
+ * the bundle's database file (see `bundleKey`).
+ */
+export interface BundleStore {
+    /**
+     * Move the given file into the store as the bundle with the given key, replacing any
+     * existing bundle. The bundle is not visible under the key until it has been completely
+     * written. The file must be on the same filesystem as the storage root.
+     *
+     * @param key The bundle key.
+     * @param filename The file containing the bundle.
+     */
+    put(key: string, filename: string): Promise<void>
+
+    /**
+     * Read the contents of the bundle with the given key.
+     *
+     * @param key The bundle key.
+     */
+    get(key: string): Readable
+
+    /**
+     * Remove the bundle with the given key. Removing a missing bundle is not an error.
+     *
+     * @param key The bundle key.
+     */
+    delete(key: string): Promise<void>
+
+    /** Return the keys of all bundles in the store. */
+    list(): Promise<string[]>
+
+    /**
+     * Return the size (in bytes) of the bundle with the given key, or zero if it does not exist.
+     *
+     * @param key The bundle key.
+     */
+    size(key: string): Promise<number>
+
+    /**
+     * Invoke the given function with the path of a local file holding the bundle with the
+     * given key, or with undefined if the bundle does not exist. The file is not removed
+     * while the function runs.
+     *
+     * @param key The bundle key.
+     * @param callback The function to invoke with the path of the bundle.
+     */
+    withLocalFile<T>(key: string, callback: (filename: string | undefined) => Promise<T>): Promise<T>
+
+    /**
+     * Write the local file of the bundle with the given key back to the store after it has
+     * been modified in place. This must be called from within `withLocalFile`.
+     *
+     * @param key The bundle key.
+     * @param filename The path given to `withLocalFile`.
+     */
+    persist(key: string, filename: string): Promise<void>
+}
+
+/** The configuration of a bundle store. */
+export interface BundleStoreOptions {
+    /** The name of the backend: `filesystem`, `s3`, or `gcs`. */
+    backend: string
+
+    /** The path where uploads and SQLite databases are stored. */
+    storageRoot: string
+
+    /** The bucket that holds the bundles of an object store backend. */
+    bucket?: string
+
+    /** The prefix of the keys of the objects holding bundles in the bucket. */
+    prefix?: string
+
+    /** The URL of the object store. Defaults to the public endpoint of the backend. */
+    endpoint?: string
+
+    /** The region of an S3 bucket. */
+    region?: string
+
+    /**
+     * The identifier of the access key used to access an S3 bucket. Defaults to the credential
+     * chain of the AWS SDK.
+     */
+    accessKeyId?: string
+
+    /** The secret of the access key used to access an S3 bucket. */
+    secretAccessKey?: string
+
+    /** The maximum number of bytes of bundles downloaded from the bucket kept on disk. */
+    cacheMaxSizeBytes?: number
+
+    /**
+     * A function invoked with the path of a local bundle file once its contents have been
+     * replaced or removed, so that data read from the file can be discarded.
+     */
+    onInvalidate?: (filename: string) => Promise<void>
+}
+
+/**
+ * Create the bundle store for the given backend. The `s3` and `gcs` backends store bundles in
+ * a bucket of Amazon S3 (or another S3-compatible object store) and Google Cloud Storage. As
+ * SQLite can only open files on a local disk, queried bundles are downloaded to a disk cache
+ * under the storage root. The cache is emptied here, as the bundles it holds may have been
+ * replaced while the bundle manager was not running.
+ *
+ * A misconfigured backend is rejected so that the bundle manager fails on startup rather
+ * than silently writing bundles to local disk.
+ *
+ * @param options The configuration of the store.
+ */
+export async function createBundleStore({
+    backend,
+    storageRoot,
+    bucket = '',
+    prefix = '',
+    endpoint,
+    region,
+    accessKeyId,
+    secretAccessKey,
+    cacheMaxSizeBytes = 0,
+    onInvalidate = () => Promise.resolve(),
+}: BundleStoreOptions): Promise<BundleStore> {
+    const objectStores: { [K: string]: () => ObjectStore } = {
+        s3: () => new S3ObjectStore(bucket, { endpoint, region, accessKeyId, secretAccessKey }),
+        gcs: () => new GcsObjectStore(bucket, { endpoint }),
+    }
+
+    if (backend === 'filesystem') {
+        return new FilesystemBundleStore(path.join(storageRoot, constants.DBS_DIR), onInvalidate)
+    }
+
+    if (!(backend in objectStores)) {
+        throw new Error(`Unknown bundle storage backend ${backend}`)
+    }
+
+    if (!bucket) {
+        throw new Error(`Bundle storage backend ${backend} requires a bucket`)
+    }
+
+    if (!accessKeyId !== !secretAccessKey) {
+        throw new Error(`Bundle storage backend ${backend} requires both an access key id and a secret key`)
+    }
+
+    const cacheDirectory = path.join(storageRoot, constants.BUNDLE_CACHE_DIR)
+    await rmfr(cacheDirectory)
+    await ensureDirectory(cacheDirectory)
+
+    return new ObjectStoreBundleStore(objectStores[backend](), prefix, cacheDirectory, cacheMaxSizeBytes, onInvalidate)
+}
+
+/**
+ * Return the key of the bundle for the given dump.
+ *
+ * @param id The identifier of the dump.
+ */
+export function bundleKey(id: number): string {
+    return path.basename(dbFilename('', id))
+}
+
+/** A bundle store backed by a directory on the local filesystem. */
+export class FilesystemBundleStore implements BundleStore {
+    /**
+     * Create a new `FilesystemBundleStore`.
+     *
+     * @param directory The directory that contains the bundles.
+     * @param onInvalidate A function invoked with the path of a replaced or removed bundle.
+     */
+    constructor(
+        private directory: string,
+        private onInvalidate: (filename: string) => Promise<void> = () => Promise.resolve()
+    ) {}
+
+    public async put(key: string, filename: string): Promise<void> {
+        // Ensure the contents are durable before the bundle becomes visible, and that the
+        // rename is durable so that the bundle does not disappear after a crash
+        await fsyncPath(filename)
+        await fs.rename(filename, this.filename(key))
+        await fsyncPath(this.directory)
+        await this.onInvalidate(this.filename(key))
+    }
+
+    public get(key: string): Readable {
+        return fs.createReadStream(this.filename(key))
+    }
+
+    public async delete(key: string): Promise<void> {
+        try {
+            await fs.unlink(this.filename(key))
+        } catch (error) {
+            if (!(error && error.code === 'ENOENT')) {
+                throw error
+            }
+        }
+
+        await this.onInvalidate(this.filename(key))
+    }
+
+    public async list(): Promise<string[]> {
+        return (await fs.readdir(this.directory)).filter(basename => !basename.endsWith('.tmp'))
+    }
+
+    public size(key: string): Promise<number> {
+        return filesize(this.filename(key))
+    }
+
+    public async withLocalFile<T>(key: string, callback: (filename: string | undefined) => Promise<T>): Promise<T> {
+        const filename = this.filename(key)
+        return callback((await fs.exists(filename)) ? filename : undefined)
+    }
+
+    public persist(): Promise<void> {
+        // Bundles are modified in place
+        return Promise.resolve()
+    }
+
+    /**
+     * Return the path of the file holding the bundle with the given key. Keys are basenames,
+     * so a key cannot refer to a file outside of the store's directory.
+     *
+     * @param key The bundle key.
+     */
+    private filename(key: string): string {
+        return path.join(this.directory, path.basename(key))
+    }
+}
+
+/** An error thrown when downloading a bundle that is not in the bucket. */
+class MissingBundleError extends Error {}
+
+/**
+ * A bundle store backed by a bucket in an object store. Bundles are queried from a local copy
+ * held by an LRU disk cache. Each download of a bundle is written to a new file, so that a
+ * replaced bundle is never confused with the previous copy by the caches of open databases.
+ *
+ * A cached bundle is assumed to be current. Bundles are only written by the bundle manager
+ * that serves them, which replaces its cached copy on write. Shards sharing a bucket must
+ * use different key prefixes.
+ */
+export class ObjectStoreBundleStore implements BundleStore {
+    /** The local copies of bundles. */
+    private cache: BundleFileCache
+
+    /**
+     * Create a new `ObjectStoreBundleStore`.
+     *
+     * @param objectStore The bucket that holds the bundles.
+     * @param prefix The prefix of the keys of the objects holding bundles.
+     * @param cacheDirectory The directory that holds the local copies of bundles.
+     * @param cacheMaxSizeBytes The maximum number of bytes of local copies of bundles.
+     * @param onInvalidate A function invoked with the path of a local copy before it is removed.
+     */
+    constructor(
+        private objectStore: ObjectStore,
+        private prefix: string,
+        private cacheDirectory: string,
+        cacheMaxSizeBytes: number,
+        private onInvalidate: (filename: string) => Promise<void> = () => Promise.resolve()
+    ) {
+        this.cache = new BundleFileCache(cacheMaxSizeBytes, async ({ filename }) => {
+            await this.onInvalidate(filename)
+            await fs.unlink(filename).catch(() => {
+                /* noop */
+            })
+        })
+    }
+
+    public async put(key: string, filename: string): Promise<void> {
+        await this.objectStore.putFile(this.objectKey(key), filename)
+
+        // Replace the local copy with the new bundle, which is likely to be queried soon. If
+        // the bundle was downloaded again in the meantime, that copy is kept instead.
+        await this.cache.bustKey(key)
+        const cachedFilename = this.cacheFilename(key)
+        await fs.rename(filename, cachedFilename)
+        const { size } = await fs.stat(cachedFilename)
+
+        let cached = false
+        await this.cache.withValue(
+            key,
+            () => {
+                cached = true
+                return Promise.resolve({ filename: cachedFilename, size })
+            },
+            () => Promise.resolve()
+        )
+
+        if (!cached) {
+            await fs.unlink(cachedFilename)
+        }
+    }
+
+    public get(key: string): Readable {
+        return this.objectStore.get(this.objectKey(key))
+    }
+
+    public async delete(key: string): Promise<void> {
+        await this.objectStore.delete(this.objectKey(key))
+        await this.cache.bustKey(key)
+    }
+
+    public async list(): Promise<string[]> {
+        return (await this.objectStore.list(this.prefix))
+            .map(objectKey => objectKey.slice(this.prefix.length))
+            .filter(key => key !== '' && !key.includes('/'))
+    }
+
+    public async size(key: string): Promise<number> {
+        return (await this.objectStore.size(this.objectKey(key))) || 0
+    }
+
+    public async withLocalFile<T>(key: string, callback: (filename: string | undefined) => Promise<T>): Promise<T> {
+        try {
+            return await this.cache.withValue(
+                key,
+                () => this.download(key),
+                ({ filename }) => callback(filename)
+            )
+        } catch (error) {
+            if (error instanceof MissingBundleError) {
+                return callback(undefined)
+            }
+
+            throw error
+        }
+    }
+
+    public persist(key: string, filename: string): Promise<void> {
+        return this.objectStore.putFile(this.objectKey(key), filename)
+    }
+
+    /**
+     * Download the bundle with the given key to a new file in the cache directory.
+     *
+     * @param key The bundle key.
+     */
+    private async download(key: string): Promise<CachedBundle> {
+        const filename = this.cacheFilename(key)
+
+        try {
+            if (!(await this.objectStore.download(this.objectKey(key), filename))) {
+                throw new MissingBundleError(`Bundle ${key} does not exist`)
+            }
+        } catch (error) {
+            await fs.unlink(filename).catch(() => {
+                /* noop */
+            })
+            throw error
+        }
+
+        return { filename, size: await filesize(filename) }
+    }
+
+    /**
+     * Return the key of the object holding the bundle with the given key.
+     *
+     * @param key The bundle key.
+     */
+    private objectKey(key: string): string {
+        return `${this.prefix}${path.basename(key)}`
+    }
+
+    /**
+     * Return a new path for a local copy of the bundle with the given key.
+     *
+     * @param key The bundle key.
+     */
+    private cacheFilename(key: string): string {
+        return path.join(this.cacheDirectory, `${uuid.v4()}-${path.basename(key)}`)
+    }
+}
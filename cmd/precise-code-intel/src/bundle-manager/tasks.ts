@@ -1,4 +1,5 @@
 import * as settings from './settings'
+import * as metrics from './metrics'
 import { Connection } from 'typeorm'
 import { Logger } from 'winston'
 import { ExclusivePeriodicTaskRunner } from '../shared/tasks'
@@ -8,24 +9,39 @@ import * as path from 'path'
 import { chunk } from 'lodash'
 import { createSilentLogger } from '../shared/logging'
 import { TracingContext } from '../shared/tracing'
-import { dbFilename, idFromFilename } from '../shared/paths'
+import { filesize, idFromFilename } from '../shared/paths'
 import got from 'got'
+import { authorizationHeaders } from '../shared/api/middleware/auth'
+import { JANITOR_DRY_RUN } from '../shared/config/settings'
 import pRetry from 'p-retry'
 import { parseJSON } from '../shared/encoding/json'
+import { isDefined, mapConcurrently } from '../shared/util'
+import { BundleStore, bundleKey } from './storage'
+import { Database } from './backend/database'
+import { migrateBundle, migrationTracker } from './backend/migrations'
+import { CURRENT_SCHEMA_VERSION } from '../shared/models/sqlite'
+import { accessLog, ACCESS_LOG_FILENAME } from './warmup'
+import { accessBatch } from './access'
 
 /**
- * Begin running cleanup tasks on a schedule in the background.
+ * Begin running cleanup and migration tasks on a schedule in the background. Returns the
+ * task runner so that the tasks can be stopped on shutdown.
  *
  * @param connection The Postgres connection.
+ * @param bundleStore The store of converted bundles.
  * @param logger The logger instance.
  */
-export function startTasks(connection: Connection, logger: Logger): void {
+export function startTasks(
+    connection: Connection,
+    bundleStore: BundleStore,
+    logger: Logger
+): ExclusivePeriodicTaskRunner {
     const runner = new ExclusivePeriodicTaskRunner(connection, logger)
 
     runner.register({
         name: 'Purging old dumps',
         intervalMs: settings.PURGE_OLD_DUMPS_INTERVAL,
-        task: ({ ctx }) => purgeOldDumps(settings.STORAGE_ROOT, settings.DBS_DIR_MAXIMUM_SIZE_BYTES, ctx),
+        task: ({ ctx }) => purgeOldDumps(bundleStore, settings.DBS_DIR_MAXIMUM_SIZE_BYTES, ctx),
     })
 
     runner.register({
@@ -34,38 +50,101 @@ export function startTasks(connection: Connection, logger: Logger): void {
         task: ({ ctx }) => cleanFailedUploads(ctx),
     })
 
+    runner.register({
+        name: 'Migrating bundles',
+        intervalMs: settings.MIGRATE_BUNDLES_INTERVAL,
+        task: ({ ctx }) => migrateBundles(bundleStore, ctx),
+    })
+
+    runner.register({
+        name: 'Reporting bundle accesses',
+        intervalMs: settings.REPORT_ACCESS_INTERVAL,
+        task: () => reportAccess(),
+        silent: true,
+    })
+
+    if (settings.WARMUP_BUNDLE_COUNT > 0) {
+        runner.register({
+            name: 'Saving bundle access log',
+            intervalMs: settings.SAVE_ACCESS_LOG_INTERVAL,
+            task: () => accessLog.save(path.join(settings.STORAGE_ROOT, ACCESS_LOG_FILENAME)),
+            silent: true,
+        })
+    }
+
     runner.run()
+    return runner
 }
 
 /**
- * Remove dumps until the space occupied by the dbs directory is below
- * the given limit.
+ * Create a function that returns true once the given number of seconds have elapsed. A
+ * negative budget never runs out.
  *
- * @param storageRoot The path where SQLite databases are stored.
+ * @param seconds The time budget.
+ */
+function createDeadline(seconds: number): () => boolean {
+    const deadline = Date.now() + seconds * 1000
+    return () => seconds >= 0 && Date.now() >= deadline
+}
+
+/**
+ * Remove dumps until the space occupied by the bundle store is below
+ * the given limit. In dry-run mode, nothing is removed. The run stops
+ * early once `JANITOR_TIME_BUDGET` has elapsed.
+ *
+ * @param bundleStore The store of converted bundles.
  * @param maximumSizeBytes The maximum number of bytes (< 0 means no limit).
  * @param ctx The tracing context.
  */
 async function purgeOldDumps(
-    storageRoot: string,
+    bundleStore: BundleStore,
     maximumSizeBytes: number,
     { logger = createSilentLogger() }: TracingContext = {}
 ): Promise<void> {
+    const expired = createDeadline(settings.JANITOR_TIME_BUDGET)
+
     // First, remove all the files in the DB dir that don't have a corresponding
     // lsif_upload record in the database. This will happen in the cases where an
     // upload overlaps existing uploads which are deleted in batch from the db,
     // but not from disk. This can also happen if the db file is written during
     // processing but fails later while updating commits for that repo.
-    await removeDeadDumps(storageRoot, { logger })
+    await removeDeadDumps(bundleStore, expired, { logger })
 
     if (maximumSizeBytes < 0) {
         return Promise.resolve()
     }
 
-    let currentSizeBytes = await dirsize(path.join(storageRoot, constants.DBS_DIR))
+    const keys = await bundleStore.list()
+    let currentSizeBytes = (
+        await mapConcurrently(keys, settings.JANITOR_CONCURRENCY, key => bundleStore.size(key))
+    ).reduce((a, b) => a + b, 0)
+    if (JANITOR_DRY_RUN) {
+        if (currentSizeBytes > maximumSizeBytes) {
+            logger.info('Would prune dumps to reduce disk usage of the DB directory', {
+                currentSizeBytes,
+                softMaximumSizeBytes: maximumSizeBytes,
+            })
+        }
+
+        return
+    }
 
     while (currentSizeBytes > maximumSizeBytes) {
-        // While our current data usage is too big, find candidate dumps to delete
-        const payload: { id: number } = await makeServerRequest('/prune')
+        if (expired()) {
+            logger.warn('Janitor time budget exhausted while pruning dumps', {
+                currentSizeBytes,
+                softMaximumSizeBytes: maximumSizeBytes,
+            })
+
+            break
+        }
+
+        // While our current data usage is too big, find candidate dumps to delete. When
+        // bundles are sharded, only dumps stored by this shard reduce its data usage.
+        const payload: { id: number } = await makeServerRequest(
+            '/prune',
+            settings.BUNDLE_MANAGER_URLS.length > 1 ? { ids: keys.map(idFromFilename).filter(isDefined) } : undefined
+        )
         if (!payload) {
             logger.warn(
                 'Unable to reduce disk usage of the DB directory because deleting any single dump would drop in-use code intel for a repository.',
@@ -75,113 +154,265 @@ async function purgeOldDumps(
             break
         }
 
-        // Delete this dump and subtract its size from the current dir size
-        const filename = dbFilename(storageRoot, payload.id)
-        currentSizeBytes -= await filesize(filename)
-        await fs.unlink(filename)
+        // Delete this dump and subtract its size from the current store size
+        currentSizeBytes -= await removeBundle(bundleStore, bundleKey(payload.id), 'pruned')
     }
 }
 
 /**
- * Remove db files that are not reachable from a pending or completed upload record.
+ * Remove bundles that are not reachable from a pending or completed upload record. In
+ * dry-run mode, the bundles that would be removed are logged instead.
+ *
+ * The upload states are requested in batches of `DEAD_DUMP_BATCH_SIZE` bundles, and the dead
+ * bundles of each batch are removed by a pool of `JANITOR_CONCURRENCY` workers. No further
+ * batches are processed once the deadline has passed.
  *
- * @param storageRoot The path where SQLite databases are stored.
+ * @param bundleStore The store of converted bundles.
+ * @param expired A function that returns true once the time budget of the run has elapsed.
  * @param ctx The tracing context.
  */
 async function removeDeadDumps(
-    storageRoot: string,
+    bundleStore: BundleStore,
+    expired: () => boolean,
     { logger = createSilentLogger() }: TracingContext = {}
 ): Promise<void> {
     let count = 0
-    for (const basenames of chunk(
-        await fs.readdir(path.join(storageRoot, constants.DBS_DIR)),
-        settings.DEAD_DUMP_BATCH_SIZE
-    )) {
-        const pathsById = new Map<number, string>()
-        for (const basename of basenames) {
-            const id = idFromFilename(basename)
+    for (const keys of chunk(await bundleStore.list(), settings.DEAD_DUMP_BATCH_SIZE)) {
+        if (expired()) {
+            logger.warn('Janitor time budget exhausted while removing dead dumps', { count })
+            break
+        }
+
+        const keysById = new Map<number, string>()
+        for (const key of keys) {
+            const id = idFromFilename(key)
             if (!id) {
                 continue
             }
 
-            pathsById.set(id, path.join(storageRoot, constants.DBS_DIR, basename))
+            keysById.set(id, key)
         }
 
-        const states: Map<number, string> = await makeServerRequest('/uploads', { ids: Array.from(pathsById.keys()) })
-        for (const [id, dbPath] of pathsById.entries()) {
-            if (!states.has(id) || states.get(id) === 'errored') {
-                count++
-                await fs.unlink(dbPath)
+        const states: Map<number, string> = await makeServerRequest('/uploads', { ids: Array.from(keysById.keys()) })
+        const dead = Array.from(keysById.entries()).filter(([id]) => {
+            const state = states.get(id)
+            return !state || state === 'errored' || state === 'failed'
+        })
+
+        count += dead.length
+        if (JANITOR_DRY_RUN) {
+            for (const [id] of dead) {
+                logger.info('Would remove dead dump', { id })
             }
+
+            continue
         }
+
+        await mapConcurrently(dead, settings.JANITOR_CONCURRENCY, ([, key]) => removeBundle(bundleStore, key, 'dead'))
     }
 
     if (count > 0) {
-        logger.debug('Removed dead dumps', { count })
+        logger.debug(JANITOR_DRY_RUN ? 'Would remove dead dumps' : 'Removed dead dumps', { count })
     }
 }
 
 /**
  * Remove upload and temp files that are older than `FAILED_UPLOAD_MAX_AGE`. This assumes
  * that an upload conversion's total duration (from enqueue to completion) is less than this
- * interval during healthy operation.
+ * interval during healthy operation. In dry-run mode, the files that would be removed are
+ * logged instead.
+ *
+ * Files are checked by a pool of `JANITOR_CONCURRENCY` workers. Files that have not been
+ * checked once `JANITOR_TIME_BUDGET` has elapsed are left for the next run.
  *
  * @param ctx The tracing context.
  */
 async function cleanFailedUploads({ logger = createSilentLogger() }: TracingContext): Promise<void> {
+    const expired = createDeadline(settings.JANITOR_TIME_BUDGET)
+    const filenames = (await fs.readdir(path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR))).map(basename =>
+        path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR, basename)
+    )
+
     let count = 0
-    for (const basename of await fs.readdir(path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR))) {
-        if (await purgeFile(path.join(settings.STORAGE_ROOT, constants.UPLOADS_DIR, basename))) {
+    let skipped = 0
+    await mapConcurrently(filenames, settings.JANITOR_CONCURRENCY, async filename => {
+        if (expired()) {
+            skipped++
+            return
+        }
+
+        if (await purgeFile(filename, JANITOR_DRY_RUN)) {
+            if (JANITOR_DRY_RUN) {
+                logger.info('Would remove old file', { filename })
+            }
             count++
         }
+    })
+
+    if (skipped > 0) {
+        logger.warn('Janitor time budget exhausted while removing old files', { count, skipped })
     }
 
     if (count > 0) {
-        logger.debug('Removed old files', { count })
+        logger.debug(JANITOR_DRY_RUN ? 'Would remove old files' : 'Removed old files', { count })
+    }
+}
+
+/**
+ * Bring bundles written with an older schema version up to date, one bundle at a time. Each
+ * bundle is migrated on a copy that atomically replaces the original, so bundles can be
+ * queried during the migration. Bundles known to be up to date are not checked again, and
+ * bundles that have not been checked once `MIGRATE_BUNDLES_TIME_BUDGET` has elapsed are left
+ * for the next run. The progress is reported by the `/stats` endpoint.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param ctx The tracing context.
+ */
+async function migrateBundles(
+    bundleStore: BundleStore,
+    { logger = createSilentLogger() }: TracingContext = {}
+): Promise<void> {
+    const expired = createDeadline(settings.MIGRATE_BUNDLES_TIME_BUDGET)
+    const keys = await bundleStore.list()
+    migrationTracker.retain(keys)
+
+    let count = 0
+    for (const key of keys) {
+        const id = idFromFilename(key)
+        if (!id || migrationTracker.isCurrent(key)) {
+            continue
+        }
+
+        if (expired()) {
+            logger.warn('Time budget exhausted while migrating bundles', { count })
+            break
+        }
+
+        let version = 1
+        try {
+            await bundleStore.withLocalFile(key, async filename => {
+                if (!filename) {
+                    // Removed since the bundles were listed
+                    return
+                }
+
+                version = await new Database(id, filename).schemaVersion({ logger })
+                if (version >= CURRENT_SCHEMA_VERSION) {
+                    migrationTracker.recordVersion(key, version)
+                    return
+                }
+
+                if (await migrateBundle(filename, logger)) {
+                    await Database.invalidate(filename)
+                    await bundleStore.persist(key, filename)
+                    migrationTracker.recordMigration(key)
+                    count++
+                }
+            })
+        } catch (error) {
+            logger.error('Failed to migrate bundle', { id, version, error: error && error.message })
+            migrationTracker.recordFailure(key, version)
+        }
+    }
+
+    if (count > 0) {
+        logger.debug('Migrated bundles', { count, version: CURRENT_SCHEMA_VERSION })
+    }
+}
+
+/**
+ * Send the identifiers of the dumps queried since the last report to the API server, which
+ * records their access time so that the least recently used dumps are pruned first. If the
+ * report fails, the accesses are sent with the next report.
+ */
+async function reportAccess(): Promise<void> {
+    const ids = accessBatch.take()
+    if (ids.length === 0) {
+        return
+    }
+
+    try {
+        await got.post(new URL('/access', settings.PRECISE_CODE_INTEL_API_SERVER_URL).href, {
+            headers: { ...authorizationHeaders(), 'Content-Type': 'application/json' },
+            body: JSON.stringify({ ids }),
+        })
+    } catch (error) {
+        for (const id of ids) {
+            accessBatch.record(id)
+        }
+
+        throw error
     }
 }
 
 /**
  * Remove the given file if it was last modified longer than `FAILED_UPLOAD_MAX_AGE` seconds
- * ago. Returns true if the file was removed and false otherwise.
+ * ago. Returns true if the file was (or, in a dry run, would have been) removed and false
+ * otherwise. Files removed concurrently, e.g. by a finished conversion, are ignored.
  *
  * @param filename The file to remove.
+ * @param dryRun If true, do not remove the file.
  */
-async function purgeFile(filename: string): Promise<boolean> {
-    if (Date.now() - (await fs.stat(filename)).mtimeMs < settings.FAILED_UPLOAD_MAX_AGE * 1000) {
+async function purgeFile(filename: string, dryRun: boolean): Promise<boolean> {
+    let mtimeMs: number
+    try {
+        mtimeMs = (await fs.stat(filename)).mtimeMs
+    } catch (error) {
+        if (error && error.code === 'ENOENT') {
+            return false
+        }
+
+        throw error
+    }
+
+    if (Date.now() - mtimeMs < settings.FAILED_UPLOAD_MAX_AGE * 1000) {
         return false
     }
 
-    await fs.unlink(filename)
+    if (!dryRun) {
+        await removeFile(filename, 'expired')
+    }
     return true
 }
 
 /**
- * Calculate the cumulative size of all plain files in a directory, non-recursively.
+ * Remove the given file and record its removal in the janitor metrics. Returns the size of
+ * the removed file.
  *
- * @param directory The directory path.
+ * @param filename The file to remove.
+ * @param reason The reason for the removal, used as a metric label.
  */
-async function dirsize(directory: string): Promise<number> {
-    return (
-        await Promise.all((await fs.readdir(directory)).map(filename => filesize(path.join(directory, filename))))
-    ).reduce((a, b) => a + b, 0)
+async function removeFile(filename: string, reason: 'expired'): Promise<number> {
+    const size = await filesize(filename)
+    await fs.unlink(filename)
+    recordRemoval(reason, size)
+    return size
 }
 
 /**
- * Get the file size or zero if it doesn't exist.
+ * Remove the given bundle and record its removal in the janitor metrics. Returns the size of
+ * the removed bundle.
  *
- * @param filename The filename.
+ * @param bundleStore The store of converted bundles.
+ * @param key The bundle key.
+ * @param reason The reason for the removal, used as a metric label.
  */
-async function filesize(filename: string): Promise<number> {
-    try {
-        return (await fs.stat(filename)).size
-    } catch (error) {
-        if (!(error && error.code === 'ENOENT')) {
-            throw error
-        }
+async function removeBundle(bundleStore: BundleStore, key: string, reason: 'pruned' | 'dead'): Promise<number> {
+    const size = await bundleStore.size(key)
+    await bundleStore.delete(key)
+    recordRemoval(reason, size)
+    return size
+}
 
-        return 0
-    }
+/**
+ * Record the removal of a file or bundle in the janitor metrics.
+ *
+ * @param reason The reason for the removal.
+ * @param size The size of the removed file or bundle.
+ */
+function recordRemoval(reason: 'pruned' | 'dead' | 'expired', size: number): void {
+    metrics.janitorFilesRemovedCounter.labels(reason).inc()
+    metrics.janitorBytesReclaimedCounter.labels(reason).inc(size)
 }
 
 async function makeServerRequest<T, R>(route: string, payload?: T): Promise<R> {
@@ -190,7 +421,7 @@ async function makeServerRequest<T, R>(route: string, payload?: T): Promise<R> {
             parseJSON(
                 (
                     await got.post(new URL(route, settings.PRECISE_CODE_INTEL_API_SERVER_URL).href, {
-                        headers: { 'Content-Type': 'application/json' },
+                        headers: { ...authorizationHeaders(), 'Content-Type': 'application/json' },
                         body: JSON.stringify(payload),
                     })
                 ).body
@@ -0,0 +1,99 @@
+import * as fs from 'mz/fs'
+import * as nodepath from 'path'
+import rmfr from 'rmfr'
+import { AccessLog, checkWarmUp } from './warmup'
+
+describe('AccessLog', () => {
+    let storageRoot!: string
+
+    beforeAll(async () => {
+        storageRoot = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterAll(async () => {
+        if (storageRoot) {
+            await rmfr(storageRoot)
+        }
+    })
+
+    it('should remember the most recently accessed bundles', () => {
+        const log = new AccessLog(3)
+        for (const id of [1, 2, 3, 1, 4]) {
+            log.record(id)
+        }
+
+        expect(log.recent().map(({ id }) => id)).toEqual([4, 1, 3])
+    })
+
+    it('should remember the most recently queried documents of each bundle', () => {
+        const log = new AccessLog(3, 2)
+        log.record(1, 'a.ts')
+        log.record(1, 'b.ts')
+        log.record(2)
+        log.record(1, 'c.ts')
+        log.record(1, 'b.ts')
+
+        expect(log.recent()).toEqual([
+            { id: 1, paths: ['b.ts', 'c.ts'] },
+            { id: 2, paths: [] },
+        ])
+    })
+
+    it('should not remember bundles when disabled', () => {
+        const log = new AccessLog(0)
+        log.record(1)
+        expect(log.recent()).toEqual([])
+    })
+
+    it('should round-trip through disk', async () => {
+        const filename = nodepath.join(storageRoot, 'access.json')
+        const log = new AccessLog(3, 2)
+        for (const id of [1, 2, 3]) {
+            log.record(id, `${id}.ts`)
+        }
+        await log.save(filename)
+
+        const loaded = new AccessLog(2, 2)
+        await loaded.load(filename)
+        expect(loaded.recent()).toEqual([
+            { id: 3, paths: ['3.ts'] },
+            { id: 2, paths: ['2.ts'] },
+        ])
+    })
+
+    it('should load logs of bundle identifiers', async () => {
+        const filename = nodepath.join(storageRoot, 'ids.json')
+        await fs.writeFile(filename, '[3, 2, 1]')
+
+        const log = new AccessLog(3, 2)
+        await log.load(filename)
+        expect(log.recent()).toEqual([
+            { id: 3, paths: [] },
+            { id: 2, paths: [] },
+            { id: 1, paths: [] },
+        ])
+    })
+
+    it('should ignore a missing or malformed log', async () => {
+        const filename = nodepath.join(storageRoot, 'malformed.json')
+        await fs.writeFile(filename, '[1, 2')
+
+        const log = new AccessLog(3)
+        await log.load(filename)
+        expect(log.recent()).toEqual([])
+        await log.load(nodepath.join(storageRoot, 'missing.json'))
+        expect(log.recent()).toEqual([])
+    })
+})
+
+describe('checkWarmUp', () => {
+    it('should fail until the warm-up has finished', async () => {
+        let finish!: () => void
+        const { check } = checkWarmUp(new Promise(resolve => (finish = resolve)))
+
+        await expect(check()).rejects.toThrow('warming up bundles')
+        finish()
+        await Promise.resolve()
+        await expect(check()).resolves.toBeUndefined()
+    })
+})
@@ -0,0 +1,191 @@
+import * as fs from 'mz/fs'
+import * as settings from './settings'
+import * as uuid from 'uuid'
+import { Logger } from 'winston'
+import { Database } from './backend/database'
+import { DependencyCheck } from '../shared/api/readiness'
+import { BundleStore, bundleKey } from './storage'
+import { mapConcurrently } from '../shared/util'
+
+/** The name of the file relative to the storage root that holds the bundle access log. */
+export const ACCESS_LOG_FILENAME = 'bundle-access.json'
+
+/** A recently accessed bundle. */
+export interface AccessedBundle {
+    /** The identifier of the bundle. */
+    id: number
+
+    /** The paths of the recently queried documents of the bundle, most recently queried first. */
+    paths: string[]
+}
+
+/**
+ * A bounded list of the most recently accessed bundles and, for each bundle, of its most
+ * recently queried documents. The log is written to disk periodically and on shutdown so
+ * that the bundles and documents that were hot before a restart can be read again on startup.
+ */
+export class AccessLog {
+    /** The recently queried document paths of accessed bundles, ordered from least to most recently accessed. */
+    private bundles = new Map<number, string[]>()
+
+    /**
+     * Create a new `AccessLog`.
+     *
+     * @param capacity The maximum number of bundles to remember.
+     * @param pathCapacity The maximum number of document paths to remember for each bundle.
+     */
+    constructor(private capacity: number, private pathCapacity = 0) {}
+
+    /**
+     * Record an access of the given bundle.
+     *
+     * @param id The identifier of the bundle.
+     * @param path The path of the queried document, if any.
+     */
+    public record(id: number, path?: string): void {
+        if (this.capacity <= 0) {
+            return
+        }
+
+        const paths = this.bundles.get(id) || []
+        this.set(id, path === undefined ? paths : [path, ...paths.filter(p => p !== path)])
+    }
+
+    /** Return the remembered bundles, most recently accessed first. */
+    public recent(): AccessedBundle[] {
+        return Array.from(this.bundles, ([id, paths]) => ({ id, paths })).reverse()
+    }
+
+    /**
+     * Replace the contents of the log with the log stored in the given file. A missing or
+     * unreadable file leaves the log empty. Logs written before document paths were recorded,
+     * which hold only bundle identifiers, are also accepted.
+     *
+     * @param filename The path of the access log.
+     */
+    public async load(filename: string): Promise<void> {
+        this.bundles.clear()
+
+        let entries: unknown
+        try {
+            entries = JSON.parse(await fs.readFile(filename, 'utf8'))
+        } catch {
+            return
+        }
+
+        if (!Array.isArray(entries)) {
+            return
+        }
+
+        for (const entry of entries.reverse()) {
+            const { id, paths } = typeof entry === 'number' ? { id: entry, paths: [] } : entry || {}
+            if (this.capacity > 0 && Number.isInteger(id)) {
+                this.set(id, Array.isArray(paths) ? paths.filter(path => typeof path === 'string') : [])
+            }
+        }
+    }
+
+    /**
+     * Write the log to the given file. The file is replaced atomically so that a crash while
+     * writing does not leave a truncated log behind.
+     *
+     * @param filename The path of the access log.
+     */
+    public async save(filename: string): Promise<void> {
+        const tempFilename = `${filename}.${uuid.v4()}.tmp`
+        await fs.writeFile(tempFilename, JSON.stringify(this.recent()))
+        await fs.rename(tempFilename, filename)
+    }
+
+    /**
+     * Remember the given bundle as the most recently accessed one and evict the least recently
+     * accessed bundle once the log exceeds its capacity.
+     *
+     * @param id The identifier of the bundle.
+     * @param paths The recently queried document paths of the bundle, most recently queried first.
+     */
+    private set(id: number, paths: string[]): void {
+        // Re-inserting moves the identifier to the end of the iteration order
+        this.bundles.delete(id)
+        this.bundles.set(id, paths.slice(0, this.pathCapacity))
+
+        if (this.bundles.size > this.capacity) {
+            for (const evicted of this.bundles.keys()) {
+                this.bundles.delete(evicted)
+                break
+            }
+        }
+    }
+}
+
+/** The access log of the bundles of this bundle manager. */
+export const accessLog = new AccessLog(settings.WARMUP_BUNDLE_COUNT, settings.WARMUP_DOCUMENTS_PER_BUNDLE)
+
+/**
+ * Open the given bundles and read their metadata so that the first queries after a restart
+ * do not pay for opening them. The recently queried documents of each bundle and the result
+ * chunks they refer to are also read into the document and result chunk caches. Bundles that
+ * no longer exist are skipped, and bundles that fail to open are logged and skipped. Bundles
+ * that have not been opened once `WARMUP_TIME_BUDGET` has elapsed are left cold. Bundles kept
+ * in object storage are downloaded to the local disk cache.
+ *
+ * @param bundleStore The store of converted bundles.
+ * @param bundles The bundles to open, in order of priority.
+ * @param logger The logger instance.
+ */
+export async function warmUp(bundleStore: BundleStore, bundles: AccessedBundle[], logger: Logger): Promise<void> {
+    if (bundles.length === 0) {
+        return
+    }
+
+    const deadline = Date.now() + settings.WARMUP_TIME_BUDGET * 1000
+    const start = Date.now()
+
+    // Bundles beyond the capacity of the connection cache would only evict earlier ones
+    const candidates = bundles.slice(0, settings.CONNECTION_CACHE_CAPACITY)
+
+    let count = 0
+    let skipped = 0
+    await mapConcurrently(candidates, settings.WARMUP_CONCURRENCY, async ({ id, paths }) => {
+        if (Date.now() >= deadline) {
+            skipped++
+            return
+        }
+
+        try {
+            await bundleStore.withLocalFile(bundleKey(id), async filename => {
+                if (filename) {
+                    await new Database(id, filename).warm(paths, { logger })
+                    count++
+                }
+            })
+        } catch (error) {
+            logger.warn('Failed to warm up bundle', { id, error: error && error.message })
+        }
+    })
+
+    if (skipped > 0) {
+        logger.warn('Time budget exhausted while warming up bundles', { count, skipped })
+    }
+
+    logger.info('Warmed up bundles', { count, durationMs: Date.now() - start })
+}
+
+/**
+ * Create a check that fails until the given warm-up has finished. A failed warm-up does not
+ * keep the bundle manager from becoming ready, as queries are answered by cold bundles.
+ *
+ * @param warmup The promise that resolves once the warm-up has finished.
+ */
+export function checkWarmUp(warmup: Promise<void>): DependencyCheck {
+    let done = false
+    const settle = (): void => {
+        done = true
+    }
+    warmup.then(settle, settle)
+
+    return {
+        name: 'warmup',
+        check: () => (done ? Promise.resolve() : Promise.reject(new Error('warming up bundles'))),
+    }
+}
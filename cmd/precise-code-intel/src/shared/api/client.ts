@@ -0,0 +1,50 @@
+import * as http from 'http'
+import * as https from 'https'
+import got, { Got } from 'got'
+import * as settings from '../config/settings'
+
+/** The connection pooling and timeout options of an HTTP client. */
+export interface HttpClientOptions {
+    /** The maximum number of concurrent connections to a single host. */
+    maxSocketsPerHost: number
+
+    /** The maximum number of idle connections to a single host kept open for reuse. */
+    maxFreeSocketsPerHost: number
+
+    /** The maximum time (in milliseconds) to wait for a connection (and TLS handshake) to be established. */
+    connectTimeoutMs: number
+
+    /** The maximum time (in milliseconds) to wait for response headers once the request has been sent. */
+    responseTimeoutMs: number
+}
+
+/**
+ * Create an HTTP client that keeps connections alive and reuses them across requests
+ * instead of opening a new connection for each request, and that fails requests that
+ * cannot connect or do not receive a response in time.
+ *
+ * @param options The connection pooling and timeout options.
+ */
+export function createHttpClient({
+    maxSocketsPerHost,
+    maxFreeSocketsPerHost,
+    connectTimeoutMs,
+    responseTimeoutMs,
+}: HttpClientOptions): Got {
+    const agentOptions = { keepAlive: true, maxSockets: maxSocketsPerHost, maxFreeSockets: maxFreeSocketsPerHost }
+
+    return got.extend({
+        agent: { http: new http.Agent(agentOptions), https: new https.Agent(agentOptions) },
+        timeout: { connect: connectTimeoutMs, secureConnect: connectTimeoutMs, response: responseTimeoutMs },
+    })
+}
+
+/** Create an HTTP client for requests to the bundle manager, configured by the environment. */
+export function createBundleManagerClient(): Got {
+    return createHttpClient({
+        maxSocketsPerHost: settings.BUNDLE_MANAGER_MAX_CONNECTIONS_PER_HOST,
+        maxFreeSocketsPerHost: settings.BUNDLE_MANAGER_MAX_IDLE_CONNECTIONS_PER_HOST,
+        connectTimeoutMs: settings.BUNDLE_MANAGER_CONNECT_TIMEOUT * 1000,
+        responseTimeoutMs: settings.BUNDLE_MANAGER_RESPONSE_TIMEOUT * 1000,
+    })
+}
@@ -1,12 +1,20 @@
+import * as http from 'http'
 import express from 'express'
 import promClient from 'prom-client'
 import { default as tracingMiddleware } from 'express-opentracing'
 import { errorHandler } from './middleware/errors'
 import { logger as loggingMiddleware } from 'express-winston'
 import { makeMetricsMiddleware } from './middleware/metrics'
+import { limitConcurrency, limitRate, requestTimeout } from './middleware/limits'
 import { Tracer } from 'opentracing'
 import { Logger } from 'winston'
 import { jsonReplacer } from '../encoding/json'
+import {
+    MAX_CONCURRENT_REQUESTS,
+    MAX_REQUESTS_PER_CLIENT,
+    RATE_LIMIT_WINDOW,
+    SERVER_BUSY_RETRY_AFTER,
+} from '../config/settings'
 
 export function startExpressApp({
     port,
@@ -14,17 +22,19 @@ export function startExpressApp({
     logger,
     tracer,
     selectHistogram = () => undefined,
+    selectTimeout = () => undefined,
 }: {
     port: number
     routers?: express.Router[]
     logger: Logger
     tracer?: Tracer
     selectHistogram?: (route: string) => promClient.Histogram<string> | undefined
-}): void {
+    selectTimeout?: (route: string) => number | undefined
+}): http.Server {
     const loggingOptions = {
         winstonInstance: logger,
         level: 'debug',
-        ignoredRoutes: ['/ping', '/healthz', '/metrics'],
+        ignoredRoutes: ['/ping', '/healthz', '/readyz', '/metrics'],
         requestWhitelist: ['method', 'url'],
         msg: 'Handled request',
     }
@@ -33,6 +43,9 @@ export function startExpressApp({
     app.use(tracingMiddleware({ tracer }))
     app.use(loggingMiddleware(loggingOptions))
     app.use(makeMetricsMiddleware(selectHistogram))
+    app.use(limitRate(MAX_REQUESTS_PER_CLIENT, RATE_LIMIT_WINDOW * 1000))
+    app.use(limitConcurrency(MAX_CONCURRENT_REQUESTS, SERVER_BUSY_RETRY_AFTER))
+    app.use(requestTimeout(selectTimeout))
     app.use(createMetaRouter())
 
     for (const route of routers) {
@@ -45,7 +58,7 @@ export function startExpressApp({
 
     app.set('json replacer', jsonReplacer)
 
-    app.listen(port, () => logger.debug('API server listening', { port }))
+    return app.listen(port, () => logger.debug('API server listening', { port }))
 }
 
 /** Create a router containing health and metrics endpoint. */
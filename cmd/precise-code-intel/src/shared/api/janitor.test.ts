@@ -0,0 +1,26 @@
+import { summarizeTaskStatuses } from './janitor'
+
+describe('summarizeTaskStatuses', () => {
+    it('should report the most recent run and error', () => {
+        const t1 = new Date('2020-04-01T00:00:00Z')
+        const t2 = new Date('2020-04-01T00:05:00Z')
+        const t3 = new Date('2020-04-01T00:10:00Z')
+
+        const phases = [
+            { name: 'a', lastRunAt: t3, lastDurationSeconds: 1, lastError: 'old', lastErrorAt: t1 },
+            { name: 'b', lastRunAt: t2, lastDurationSeconds: 2, lastError: 'new', lastErrorAt: t2 },
+            { name: 'c', lastRunAt: null, lastDurationSeconds: null, lastError: null, lastErrorAt: null },
+        ]
+
+        expect(summarizeTaskStatuses(phases)).toEqual({
+            lastRunAt: t3,
+            lastError: { phase: 'b', message: 'new', at: t2 },
+            phases,
+        })
+    })
+
+    it('should report tasks that have not run', () => {
+        const phases = [{ name: 'a', lastRunAt: null, lastDurationSeconds: null, lastError: null, lastErrorAt: null }]
+        expect(summarizeTaskStatuses(phases)).toEqual({ lastRunAt: null, lastError: null, phases })
+    })
+})
@@ -0,0 +1,52 @@
+import express from 'express'
+import { ExclusivePeriodicTaskRunner, TaskStatus } from '../tasks'
+
+/** The status of the periodic cleanup tasks of a service. */
+export interface JanitorStatus {
+    /** The time any task last started, or null if no task has run yet. */
+    lastRunAt: Date | null
+
+    /** The most recent error thrown by any task, or null if no task has failed. */
+    lastError: { phase: string; message: string; at: Date } | null
+
+    /** The status of each task. */
+    phases: TaskStatus[]
+}
+
+/**
+ * Create a router containing the endpoint that reports the status of the periodic tasks
+ * run by the given task runner.
+ *
+ * @param taskRunner The task runner.
+ */
+export function createJanitorRouter(taskRunner: ExclusivePeriodicTaskRunner): express.Router {
+    const router = express.Router()
+
+    router.get('/janitor/status', (_, res: express.Response<JanitorStatus>) => {
+        res.json(summarizeTaskStatuses(taskRunner.status()))
+    })
+
+    return router
+}
+
+/**
+ * Combine the status of each task into the status of the janitor as a whole.
+ *
+ * @param phases The status of each task.
+ */
+export function summarizeTaskStatuses(phases: TaskStatus[]): JanitorStatus {
+    let lastRunAt: Date | null = null
+    let lastError: JanitorStatus['lastError'] = null
+
+    for (const { name, lastRunAt: runAt, lastError: message, lastErrorAt: errorAt } of phases) {
+        if (runAt && (!lastRunAt || runAt > lastRunAt)) {
+            lastRunAt = runAt
+        }
+
+        if (message !== null && errorAt && (!lastError || errorAt > lastError.at)) {
+            lastError = { phase: name, message, at: errorAt }
+        }
+    }
+
+    return { lastRunAt, lastError, phases }
+}
@@ -0,0 +1,34 @@
+import express from 'express'
+import { authorizationHeaders, requireToken } from './auth'
+
+describe('requireToken', () => {
+    const run = (token: string, headers: { [name: string]: string }): unknown => {
+        const req = { header: (name: string) => headers[name] } as express.Request
+        let result: unknown = 'not called'
+        requireToken(token)(req, {} as express.Response, (error?: unknown) => {
+            result = error
+        })
+        return result
+    }
+
+    it('should accept matching tokens', () => {
+        expect(run('secret', authorizationHeaders('secret'))).toBeUndefined()
+        expect(run('secret', { Authorization: 'bearer secret' })).toBeUndefined()
+    })
+
+    it('should reject missing or mismatched tokens', () => {
+        for (const headers of [
+            {},
+            authorizationHeaders('secreT'),
+            authorizationHeaders('secrets'),
+            { Authorization: 'secret' },
+        ]) {
+            expect(run('secret', headers)).toMatchObject({ status: 401, code: 'unauthorized' })
+        }
+    })
+
+    it('should accept all requests when no token is configured', () => {
+        expect(run('', {})).toBeUndefined()
+        expect(authorizationHeaders('')).toEqual({})
+    })
+})
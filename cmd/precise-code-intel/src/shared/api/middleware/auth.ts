@@ -0,0 +1,67 @@
+import express from 'express'
+import { timingSafeEqual } from 'crypto'
+import { PRECISE_CODE_INTEL_INTERNAL_API_TOKEN } from '../../config/settings'
+
+/**
+ * Create a middleware function that rejects requests that do not supply the given shared
+ * secret as a bearer token in the Authorization header. Every request is accepted when the
+ * token is empty.
+ *
+ * @param token The shared secret.
+ */
+export const requireToken = (token: string = PRECISE_CODE_INTEL_INTERNAL_API_TOKEN) => (
+    req: express.Request,
+    res: express.Response,
+    next: express.NextFunction
+): void => {
+    if (isAuthorized(req.header('Authorization'), token)) {
+        next()
+        return
+    }
+
+    next(Object.assign(new Error('Missing or invalid authorization token'), { status: 401, code: 'unauthorized' }))
+}
+
+/**
+ * Create the headers that authorize a request to a route guarded by `requireToken`. No
+ * headers are returned when the token is empty.
+ *
+ * @param token The shared secret.
+ */
+export function authorizationHeaders(
+    token: string = PRECISE_CODE_INTEL_INTERNAL_API_TOKEN
+): { [name: string]: string } {
+    return token === '' ? {} : { Authorization: `Bearer ${token}` }
+}
+
+/**
+ * Determine if the value of an Authorization header authorizes a request to a route guarded
+ * by `requireToken`. Every request is authorized when the token is empty.
+ *
+ * @param header The value of the Authorization header.
+ * @param token The shared secret.
+ */
+export function isAuthorized(
+    header: string | undefined,
+    token: string = PRECISE_CODE_INTEL_INTERNAL_API_TOKEN
+): boolean {
+    return token === '' || isValidAuthorization(header, token)
+}
+
+/**
+ * Determine if the value of an Authorization header is a bearer token equal to the given
+ * token. The comparison is done in constant time.
+ *
+ * @param header The value of the Authorization header.
+ * @param token The expected token.
+ */
+function isValidAuthorization(header: string | undefined, token: string): boolean {
+    const match = header?.match(/^Bearer (.+)$/i)
+    if (!match) {
+        return false
+    }
+
+    const actual = Buffer.from(match[1])
+    const expected = Buffer.from(token)
+    return actual.length === expected.length && timingSafeEqual(actual, expected)
+}
@@ -1,19 +1,42 @@
 import express from 'express'
 import { Logger } from 'winston'
+import { Span } from 'opentracing'
 
-interface ErrorResponse {
-    message: string
+/** The JSON envelope of an error response. */
+export interface ErrorResponse {
+    /** A human-readable description of the error. */
+    error: string
+
+    /** A machine-readable identifier of the error condition. */
+    code: string
 }
 
 export interface ApiError {
     message: string
     status?: number
+    code?: string
 }
 
 export const isApiError = (val: unknown): val is ApiError => typeof val === 'object' && !!val && 'message' in val
 
 /**
- * Middleware function used to convert uncaught exceptions into 500 responses.
+ * The error codes used for errors that do not supply their own code, indexed by the
+ * status of the response.
+ */
+const defaultErrorCodes = new Map<number, string>([
+    [400, 'bad_request'],
+    [401, 'unauthorized'],
+    [404, 'not_found'],
+    [422, 'unprocessable_entity'],
+    [429, 'too_many_requests'],
+    [499, 'cancelled'],
+    [503, 'service_unavailable'],
+])
+
+/**
+ * Middleware function used to convert uncaught exceptions into error responses. Errors
+ * that do not specify a status are converted into 500 responses and are logged along
+ * with the identifier of the trace of the failed request.
  *
  * @param logger The logger instance.
  */
@@ -21,12 +44,12 @@ export const errorHandler = (
     logger: Logger
 ): ((
     error: unknown,
-    req: express.Request,
+    req: express.Request & { span?: Span },
     res: express.Response<ErrorResponse>,
     next: express.NextFunction
 ) => void) => (
     error: unknown,
-    req: express.Request,
+    req: express.Request & { span?: Span },
     res: express.Response<ErrorResponse>,
     // Express uses argument length to distinguish middleware and error handlers
     // eslint-disable-next-line @typescript-eslint/no-unused-vars
@@ -34,12 +57,16 @@ export const errorHandler = (
 ): void => {
     const status = (isApiError(error) && error.status) || 500
     const message = (isApiError(error) && error.message) || 'Unknown error'
+    const code = (isApiError(error) && error.code) || defaultErrorCodes.get(status) || 'internal_error'
+    const traceId = req.span?.context().toTraceId()
 
     if (status === 500) {
-        logger.error('uncaught exception', { error })
+        logger.error('uncaught exception', { error, traceId, method: req.method, url: req.url })
+    } else {
+        logger.debug('request failed', { message, code, status, traceId })
     }
 
     if (!res.headersSent) {
-        res.status(status).send({ message })
+        res.status(status).send({ error: message, code })
     }
 }
@@ -0,0 +1,83 @@
+import express from 'express'
+import got from 'got'
+import { AddressInfo } from 'net'
+import { limitRate, requestTimeout } from './limits'
+
+describe('requestTimeout', () => {
+    it('should discard writes after the request has timed out', async () => {
+        let lateWrite: Promise<void> | undefined
+        const app = express()
+        app.use(requestTimeout(() => 10))
+        app.get('/slow', (req, res) => {
+            lateWrite = new Promise(resolve => setTimeout(resolve, 50)).then(() => {
+                res.status(200).json({ late: true })
+            })
+        })
+
+        const server = app.listen(0)
+        try {
+            const { port } = server.address() as AddressInfo
+            const { statusCode, body } = await got.get(`http://localhost:${port}/slow`, {
+                throwHttpErrors: false,
+                responseType: 'json',
+            })
+
+            expect(statusCode).toEqual(503)
+            expect(body).toMatchObject({ code: 'request_timeout' })
+            await expect(lateWrite).resolves.toBeUndefined()
+        } finally {
+            server.close()
+        }
+    })
+})
+
+describe('limitRate', () => {
+    const run = (
+        middleware: ReturnType<typeof limitRate>,
+        ip: string,
+        path = '/definitions'
+    ): { error: unknown; headers: { [name: string]: string } } => {
+        const headers: { [name: string]: string } = {}
+        const res = {
+            set: (name: string, value: string) => {
+                headers[name] = value
+            },
+        } as express.Response
+
+        let error: unknown = 'not called'
+        middleware({ ip, path } as express.Request, res, (e?: unknown) => {
+            error = e
+        })
+        return { error, headers }
+    }
+
+    it('should reject clients over the limit', () => {
+        const middleware = limitRate(2, 1000, () => 0)
+
+        expect(run(middleware, 'a').error).toBeUndefined()
+        expect(run(middleware, 'a').error).toBeUndefined()
+        expect(run(middleware, 'b').error).toBeUndefined()
+
+        const { error, headers } = run(middleware, 'a')
+        expect(error).toMatchObject({ status: 429, code: 'rate_limited' })
+        expect(headers['Retry-After']).toEqual('1')
+    })
+
+    it('should reset counts at the start of each window', () => {
+        let time = 0
+        const middleware = limitRate(1, 1000, () => time)
+
+        expect(run(middleware, 'a').error).toBeUndefined()
+        expect(run(middleware, 'a').error).toMatchObject({ status: 429 })
+
+        time = 1000
+        expect(run(middleware, 'a').error).toBeUndefined()
+    })
+
+    it('should not limit meta routes or disabled limits', () => {
+        const middleware = limitRate(1, 1000, () => 0)
+        expect(run(middleware, 'a').error).toBeUndefined()
+        expect(run(middleware, 'a', '/healthz').error).toBeUndefined()
+        expect(run(limitRate(0, 1000), 'a').error).toBeUndefined()
+    })
+})
@@ -0,0 +1,124 @@
+import express from 'express'
+import onFinished from 'on-finished'
+import { cancellationFromResponse } from '../../cancellation'
+
+/** Routes that are never limited so that the service can always be probed and scraped. */
+const unlimitedRoutes = ['/ping', '/healthz', '/readyz', '/metrics']
+
+/**
+ * Create a middleware function that fails requests that take longer than the timeout
+ * selected for their route with a 503 response. The cancellation of the request (see
+ * `cancellationFromResponse`) fires once the timeout elapses so that the work performed
+ * on behalf of the request is abandoned. Anything the handler writes to the response
+ * afterwards is discarded. If the handler has already begun to stream its response, the
+ * connection is closed instead so that the client does not mistake the partial response
+ * for a complete one.
+ *
+ * @param selectTimeout Return the timeout (in milliseconds) of a route, or undefined if requests to the route do not time out.
+ */
+export const requestTimeout = (selectTimeout: (route: string) => number | undefined) => (
+    req: express.Request,
+    res: express.Response,
+    next: express.NextFunction
+): void => {
+    const timeout = selectTimeout(req.path)
+    if (timeout === undefined || timeout <= 0) {
+        next()
+        return
+    }
+
+    const cancellation = cancellationFromResponse(res)
+    const timer = setTimeout(() => {
+        if (res.headersSent) {
+            res.destroy()
+        } else {
+            res.status(503).send({ error: `Request timed out after ${timeout}ms`, code: 'request_timeout' })
+        }
+
+        discardWrites(res)
+        cancellation.cancel()
+    }, timeout)
+
+    onFinished(res, () => clearTimeout(timer))
+    next()
+}
+
+/**
+ * Replace the methods that write to the given response with methods that do nothing, so that
+ * a handler that is still running once its response has been sent cannot fail by writing to it.
+ *
+ * @param res The express response.
+ */
+function discardWrites(res: express.Response): void {
+    res.setHeader = (() => res) as typeof res.setHeader
+    res.writeHead = (() => res) as typeof res.writeHead
+    res.write = (() => true) as typeof res.write
+    res.end = (() => res) as typeof res.end
+}
+
+/**
+ * Create a middleware function that rejects requests with a 503 response while the given
+ * number of requests are already in flight.
+ *
+ * @param maxInFlight The maximum number of concurrent requests (<= 0 means no limit).
+ * @param retryAfter The time (in seconds) that rejected clients are asked to wait before retrying.
+ */
+export const limitConcurrency = (maxInFlight: number, retryAfter: number) => {
+    let inFlight = 0
+
+    return (req: express.Request, res: express.Response, next: express.NextFunction): void => {
+        if (maxInFlight <= 0 || unlimitedRoutes.includes(req.path)) {
+            next()
+            return
+        }
+
+        if (inFlight >= maxInFlight) {
+            res.set('Retry-After', String(retryAfter))
+            next(Object.assign(new Error('Too many requests are in flight'), { status: 503, code: 'server_busy' }))
+            return
+        }
+
+        inFlight++
+        onFinished(res, () => inFlight--)
+        next()
+    }
+}
+
+/**
+ * Create a middleware function that rejects requests with a 429 response once a client
+ * has made the given number of requests within the current window. Clients are identified
+ * by their remote address. Counts are reset for all clients at the start of each window.
+ *
+ * @param maxRequests The maximum number of requests per client per window (<= 0 means no limit).
+ * @param window The length of the window (in milliseconds).
+ * @param now A function that returns the current time in milliseconds. Overridable for testing.
+ */
+export const limitRate = (maxRequests: number, window: number, now: () => number = Date.now) => {
+    const counts = new Map<string, number>()
+    let windowStart = now()
+
+    return (req: express.Request, res: express.Response, next: express.NextFunction): void => {
+        if (maxRequests <= 0 || unlimitedRoutes.includes(req.path)) {
+            next()
+            return
+        }
+
+        const time = now()
+        if (time - windowStart >= window) {
+            counts.clear()
+            windowStart = time
+        }
+
+        const client = req.ip || ''
+        const count = (counts.get(client) || 0) + 1
+        counts.set(client, count)
+
+        if (count > maxRequests) {
+            res.set('Retry-After', String(Math.ceil((windowStart + window - time) / 1000)))
+            next(Object.assign(new Error('Too many requests'), { status: 429, code: 'rate_limited' }))
+            return
+        }
+
+        next()
+    }
+}
@@ -1,6 +1,7 @@
 import express from 'express'
 import { query, ValidationChain, validationResult, ValidationError } from 'express-validator'
 import { parseCursor } from '../pagination/cursor'
+import { ErrorResponse } from './errors'
 
 /**
  * Create a query string validator for a required non-empty string value.
@@ -40,6 +41,33 @@ export const validateInt = (key: string): ValidationChain => query(key).isInt().
  */
 export const validateOptionalInt = (key: string): ValidationChain => query(key).optional().isInt().toInt()
 
+/**
+ * Create a query string validator for a possibly empty integer value that is at least one.
+ *
+ * @param key The query string key.
+ */
+export const validateOptionalPositiveInt = (key: string): ValidationChain =>
+    query(key).optional().isInt({ min: 1 }).toInt()
+
+/**
+ * Create a query string validator for a possibly absent comma-separated list of integers. An
+ * empty value is an empty list.
+ *
+ * @param key The query string key.
+ */
+export const validateOptionalIntList = (key: string): ValidationChain =>
+    query(key)
+        .optional()
+        .matches(/^(\d+(,\d+)*)?$/)
+        .customSanitizer((value: string) => (value === '' ? [] : value.split(',').map(v => parseInt(v, 10))))
+
+/**
+ * Create a query string validator for a possibly empty ISO 8601 timestamp.
+ *
+ * @param key The query string key.
+ */
+export const validateOptionalDate = (key: string): ValidationChain => query(key).optional().isISO8601().toDate()
+
 /** A validator used for a string query field. */
 export const validateQuery = validateOptionalString('query')
 
@@ -48,7 +76,20 @@ export const validateQuery = validateOptionalString('query')
  *
  * @param key The query string key.
  */
-export const validateLsifUploadState = query('state').optional().isIn(['queued', 'completed', 'errored', 'processing'])
+export const validateLsifUploadState = query('state')
+    .optional()
+    .isIn(['queued', 'completed', 'errored', 'processing', 'failed', 'deleting'])
+
+/**
+ * Create a query string validator for a value that must be one of the given values.
+ *
+ * @param key The query string key.
+ * @param values The valid values.
+ */
+export const validateOptionalEnum = (key: string, values: string[]): ValidationChain =>
+    query(key)
+        .optional()
+        .isIn(values)
 
 /** Create a validator for an integer limit field. */
 export const validateLimit = validateOptionalInt('limit')
@@ -60,24 +101,35 @@ export const validateOffset = validateOptionalInt('offset')
 export const validateCursor = <T>(): ValidationChain =>
     validateOptionalString('cursor').customSanitizer(value => parseCursor<T>(value))
 
-interface ValidationErrorResponse {
+interface ValidationErrorResponse extends ErrorResponse {
     errors: Record<string, ValidationError>
 }
 
 /**
  * Middleware function used to apply a sequence of validators and then return
  * an unprocessable entity response with an error message if validation fails.
+ * Errors thrown by sanitizers (such as a malformed cursor) are passed to the
+ * error handler.
  */
 export const validationMiddleware = (chains: ValidationChain[]) => async (
     req: express.Request,
     res: express.Response<ValidationErrorResponse>,
     next: express.NextFunction
 ): Promise<void> => {
-    await Promise.all(chains.map(chain => chain.run(req)))
+    try {
+        await Promise.all(chains.map(chain => chain.run(req)))
+    } catch (error) {
+        next(error)
+        return
+    }
 
     const errors = validationResult(req)
     if (!errors.isEmpty()) {
-        res.status(422).send({ errors: errors.mapped() })
+        res.status(422).send({
+            error: 'Invalid request parameters',
+            code: 'invalid_parameters',
+            errors: errors.mapped(),
+        })
         return
     }
 
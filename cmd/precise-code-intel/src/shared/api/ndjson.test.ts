@@ -0,0 +1,79 @@
+import express from 'express'
+import { Writable } from 'stream'
+import { NDJSON_CONTENT_TYPE, writeNdjson } from './ndjson'
+
+/** A writable stream that stands in for an HTTP response. */
+class FakeResponse extends Writable {
+    public headers: { [name: string]: string } = {}
+    public chunks: string[] = []
+    public headersSent = false
+
+    constructor() {
+        // A tiny high water mark exercises the backpressure handling on every write
+        super({ highWaterMark: 1 })
+    }
+
+    public setHeader(name: string, value: string): void {
+        this.headers[name] = value
+    }
+
+    public _write(chunk: Buffer, encoding: string, callback: () => void): void {
+        this.headersSent = true
+        this.chunks.push(chunk.toString())
+        setImmediate(callback)
+    }
+
+    public get body(): string {
+        return this.chunks.join('')
+    }
+}
+
+describe('writeNdjson', () => {
+    it('should write each value on its own line', async () => {
+        const res = new FakeResponse()
+        await writeNdjson((res as unknown) as express.Response, generate([{ a: 1 }, { b: 2 }, 'c']))
+
+        expect(res.headers['Content-Type']).toEqual(NDJSON_CONTENT_TYPE)
+        expect(res.body).toEqual('{"a":1}\n{"b":2}\n"c"\n')
+    })
+
+    it('should write an empty body for an empty iterable', async () => {
+        const res = new FakeResponse()
+        await writeNdjson((res as unknown) as express.Response, [])
+
+        expect(res.headers['Content-Type']).toEqual(NDJSON_CONTENT_TYPE)
+        expect(res.body).toEqual('')
+    })
+
+    it('should write the error as the final line', async () => {
+        async function* values(): AsyncIterable<number> {
+            yield 1
+            throw Object.assign(new Error('LSIF upload not found'), { status: 404, code: 'dump_not_found' })
+        }
+
+        const res = new FakeResponse()
+        await expect(writeNdjson((res as unknown) as express.Response, values())).rejects.toThrow(
+            'LSIF upload not found'
+        )
+
+        expect(res.body).toEqual('1\n{"error":"LSIF upload not found","code":"dump_not_found"}\n')
+    })
+
+    it('should rethrow errors that occur before the first line', async () => {
+        // eslint-disable-next-line require-yield
+        async function* values(): AsyncIterable<number> {
+            throw new Error('oops')
+        }
+
+        const res = new FakeResponse()
+        await expect(writeNdjson((res as unknown) as express.Response, values())).rejects.toThrow('oops')
+        expect(res.body).toEqual('')
+    })
+})
+
+async function* generate<T>(values: T[]): AsyncIterable<T> {
+    for (const value of values) {
+        yield value
+        await Promise.resolve()
+    }
+}
@@ -0,0 +1,79 @@
+import express from 'express'
+import { isApiError } from './middleware/errors'
+
+/** The media type of a newline-delimited JSON payload, in which each line is a JSON value. */
+export const NDJSON_CONTENT_TYPE = 'application/x-ndjson'
+
+/**
+ * Determine if the client prefers a newline-delimited JSON response over a JSON response.
+ * Clients that do not send an accept header receive a JSON response.
+ *
+ * @param req The HTTP request.
+ */
+export const acceptsNdjson = (req: express.Request): boolean =>
+    req.accepts(['application/json', NDJSON_CONTENT_TYPE]) === NDJSON_CONTENT_TYPE
+
+/**
+ * Write each value yielded by the given iterable to the response as a single line of JSON.
+ * The next value is not requested until the response has drained, so that values are not
+ * buffered in memory when the client reads slower than the values are produced. Iteration
+ * stops early if the client disconnects.
+ *
+ * Once the first line has been written the status of the response can no longer change.
+ * If the iterable throws after that point, the error envelope is written as the final line
+ * before the error is rethrown.
+ *
+ * @param res The HTTP response.
+ * @param values The values to write.
+ */
+export async function writeNdjson(
+    res: express.Response,
+    values: Iterable<unknown> | AsyncIterable<unknown>
+): Promise<void> {
+    try {
+        for await (const value of values) {
+            if (!res.headersSent) {
+                res.setHeader('Content-Type', NDJSON_CONTENT_TYPE)
+            }
+
+            if (!res.write(`${JSON.stringify(value)}\n`)) {
+                await drained(res)
+            }
+
+            if (res.destroyed) {
+                return
+            }
+        }
+    } catch (error) {
+        if (res.headersSent && !res.destroyed) {
+            const message = (isApiError(error) && error.message) || 'Unknown error'
+            const code = (isApiError(error) && error.code) || 'internal_error'
+            res.end(`${JSON.stringify({ error: message, code })}\n`)
+        }
+
+        throw error
+    }
+
+    if (!res.headersSent) {
+        res.setHeader('Content-Type', NDJSON_CONTENT_TYPE)
+    }
+    res.end()
+}
+
+/**
+ * Wait until the given response can accept more data or has been closed.
+ *
+ * @param res The HTTP response.
+ */
+export function drained(res: express.Response): Promise<void> {
+    return new Promise(resolve => {
+        const done = (): void => {
+            res.off('drain', done)
+            res.off('close', done)
+            resolve()
+        }
+
+        res.on('drain', done)
+        res.on('close', done)
+    })
+}
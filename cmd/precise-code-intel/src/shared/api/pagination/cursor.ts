@@ -11,7 +11,10 @@ export function parseCursor<T>(cursorRaw: string | undefined): T | undefined {
     try {
         return JSON.parse(Buffer.from(cursorRaw, 'base64').toString())
     } catch {
-        throw Object.assign(new Error(`Malformed cursor supplied ${cursorRaw}`), { status: 400 })
+        throw Object.assign(new Error(`Malformed cursor supplied ${cursorRaw}`), {
+            status: 400,
+            code: 'malformed_cursor',
+        })
     }
 }
 
@@ -0,0 +1,46 @@
+import express from 'express'
+import { formatLinks, nextLink, offsetLinks } from './link'
+
+describe('links', () => {
+    const req = ({
+        protocol: 'http',
+        get: () => 'localhost:3186',
+        originalUrl: '/uploads/repository/1?query=foo&limit=10&offset=20',
+    } as unknown) as express.Request
+
+    const base = 'http://localhost:3186/uploads/repository/1'
+
+    it('should overwrite and remove query params', () => {
+        expect(nextLink(req, { offset: 30 })).toEqual(`<${base}?query=foo&limit=10&offset=30>; rel="next"`)
+        expect(formatLinks(req, { first: { query: null, offset: undefined } })).toEqual(
+            `<${base}?limit=10&offset=20>; rel="first"`
+        )
+    })
+
+    it('should link to the first, previous, next, and last pages', () => {
+        expect(offsetLinks(req, { limit: 10, offset: 20, totalCount: 45 })).toEqual(
+            [
+                `<${base}?query=foo&limit=10&offset=0>; rel="first"`,
+                `<${base}?query=foo&limit=10&offset=10>; rel="prev"`,
+                `<${base}?query=foo&limit=10&offset=30>; rel="next"`,
+                `<${base}?query=foo&limit=10&offset=40>; rel="last"`,
+            ].join(', ')
+        )
+    })
+
+    it('should omit the previous and next links on the first and last pages', () => {
+        expect(offsetLinks(req, { limit: 10, offset: 0, totalCount: 10 })).toEqual(
+            [
+                `<${base}?query=foo&limit=10&offset=0>; rel="first"`,
+                `<${base}?query=foo&limit=10&offset=0>; rel="last"`,
+            ].join(', ')
+        )
+
+        expect(offsetLinks(req, { limit: 10, offset: 0, totalCount: 0 })).toEqual(
+            [
+                `<${base}?query=foo&limit=10&offset=0>; rel="first"`,
+                `<${base}?query=foo&limit=10&offset=0>; rel="last"`,
+            ].join(', ')
+        )
+    })
+})
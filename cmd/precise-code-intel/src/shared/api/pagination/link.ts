@@ -1,23 +1,75 @@
 import express from 'express'
 
 /**
- * Create a link header payload with a next link based on the previous endpoint.
+ * The query params to overwrite in a link. A null value removes the param from the link,
+ * and an undefined value leaves the param of the original request untouched.
+ */
+export type LinkParams = { [name: string]: string | number | boolean | null | undefined }
+
+/**
+ * Create the URL of the current endpoint with the given query params overwritten.
  *
  * @param req The HTTP request.
  * @param params The query params to overwrite.
  */
-export function nextLink(
-    req: express.Request,
-    params: { [name: string]: string | number | boolean | undefined }
-): string {
+function linkUrl(req: express.Request, params: LinkParams): string {
     // Requests always have a host header
     // eslint-disable-next-line @typescript-eslint/no-non-null-assertion
     const url = new URL(`${req.protocol}://${req.get('host')!}${req.originalUrl}`)
     for (const [key, value] of Object.entries(params)) {
-        if (value !== undefined) {
+        if (value === null) {
+            url.searchParams.delete(key)
+        } else if (value !== undefined) {
             url.searchParams.set(key, String(value))
         }
     }
 
-    return `<${url.href}>; rel="next"`
+    return url.href
+}
+
+/**
+ * Create a link header payload with a link for each of the given relation types based on
+ * the current endpoint. See RFC 5988.
+ *
+ * @param req The HTTP request.
+ * @param links The query params to overwrite, indexed by relation type.
+ */
+export function formatLinks(req: express.Request, links: { [rel: string]: LinkParams }): string {
+    return Object.entries(links)
+        .map(([rel, params]) => `<${linkUrl(req, params)}>; rel="${rel}"`)
+        .join(', ')
+}
+
+/**
+ * Create a link header payload with a next link based on the previous endpoint.
+ *
+ * @param req The HTTP request.
+ * @param params The query params to overwrite.
+ */
+export function nextLink(req: express.Request, params: LinkParams): string {
+    return formatLinks(req, { next: params })
+}
+
+/**
+ * Create a link header payload with first, prev, next, and last links for an endpoint
+ * paginated by limit and offset. The prev and next links are omitted on the first and
+ * last pages, respectively.
+ *
+ * @param req The HTTP request.
+ * @param args The limit and offset of the current page and the total number of results.
+ */
+export function offsetLinks(
+    req: express.Request,
+    { limit, offset, totalCount }: { limit: number; offset: number; totalCount: number }
+): string {
+    const links: { [rel: string]: LinkParams } = { first: { limit, offset: 0 } }
+    if (offset > 0) {
+        links.prev = { limit, offset: Math.max(offset - limit, 0) }
+    }
+    if (offset + limit < totalCount) {
+        links.next = { limit, offset: offset + limit }
+    }
+    links.last = { limit, offset: Math.max(Math.floor((totalCount - 1) / limit) * limit, 0) }
+
+    return formatLinks(req, links)
 }
@@ -0,0 +1,29 @@
+import { slicePage } from './slice'
+
+describe('slicePage', () => {
+    const values = [1, 2, 3, 4, 5]
+
+    it('should return an empty page of an empty list', () => {
+        expect(slicePage([], 0, 10)).toEqual({ page: [], nextOffset: undefined })
+        expect(slicePage([], 5, 10)).toEqual({ page: [], nextOffset: undefined })
+    })
+
+    it('should return a partial page when the window overshoots', () => {
+        expect(slicePage(values, 3, 10)).toEqual({ page: [4, 5], nextOffset: undefined })
+        expect(slicePage(values, 5, 10)).toEqual({ page: [], nextOffset: undefined })
+        expect(slicePage(values, 50, 10)).toEqual({ page: [], nextOffset: undefined })
+    })
+
+    it('should not link past a page ending on the boundary', () => {
+        expect(slicePage(values, 0, 5)).toEqual({ page: values, nextOffset: undefined })
+        expect(slicePage(values, 3, 2)).toEqual({ page: [4, 5], nextOffset: undefined })
+        expect(slicePage(values, 2, 2)).toEqual({ page: [3, 4], nextOffset: 4 })
+    })
+
+    it('should clamp invalid offsets and limits', () => {
+        expect(slicePage(values, -2, 2)).toEqual({ page: [1, 2], nextOffset: 2 })
+        expect(slicePage(values, NaN, 2)).toEqual({ page: [1, 2], nextOffset: 2 })
+        expect(slicePage(values, 1, -1)).toEqual({ page: [], nextOffset: 1 })
+        expect(slicePage(values, 1.5, 1)).toEqual({ page: [2], nextOffset: 2 })
+    })
+})
@@ -0,0 +1,26 @@
+/**
+ * Clamp a pagination offset or limit to a non-negative integer. Values that are not finite
+ * (including those parsed from a malformed cursor) are treated as zero.
+ *
+ * @param value The offset or limit.
+ */
+function clampNonNegative(value: number): number {
+    return Number.isFinite(value) ? Math.max(0, Math.floor(value)) : 0
+}
+
+/**
+ * Return the page of the given values that starts at `offset` and holds at most `limit`
+ * values. Unlike a plain slice, a negative offset does not count from the end of the list
+ * and an offset past the end yields an empty page. The offset of the next page is returned
+ * only if values remain after this page.
+ *
+ * @param values The complete list of values.
+ * @param offset The number of values to skip.
+ * @param limit The maximum number of values to return.
+ */
+export function slicePage<T>(values: T[], offset: number, limit: number): { page: T[]; nextOffset?: number } {
+    const start = Math.min(clampNonNegative(offset), values.length)
+    const end = Math.min(start + clampNonNegative(limit), values.length)
+
+    return { page: values.slice(start, end), nextOffset: end < values.length ? end : undefined }
+}
@@ -0,0 +1,42 @@
+import { checkDependencies } from './readiness'
+
+describe('checkDependencies', () => {
+    it('should report ready when every check succeeds', async () => {
+        const response = await checkDependencies(
+            [
+                { name: 'a', check: () => Promise.resolve() },
+                { name: 'b', check: () => Promise.resolve() },
+            ],
+            1000
+        )
+
+        expect(response.ready).toBeTruthy()
+        expect(Object.keys(response.dependencies)).toEqual(['a', 'b'])
+        expect(response.dependencies.a.ready).toBeTruthy()
+        expect(response.dependencies.b.ready).toBeTruthy()
+    })
+
+    it('should report failed checks', async () => {
+        const response = await checkDependencies(
+            [
+                { name: 'a', check: () => Promise.resolve() },
+                { name: 'b', check: () => Promise.reject(new Error('connection refused')) },
+            ],
+            1000
+        )
+
+        expect(response.ready).toBeFalsy()
+        expect(response.dependencies.a.ready).toBeTruthy()
+        expect(response.dependencies.b).toMatchObject({ ready: false, error: 'connection refused' })
+    })
+
+    it('should fail checks that time out', async () => {
+        const response = await checkDependencies(
+            [{ name: 'a', check: () => new Promise(resolve => setTimeout(resolve, 1000)) }],
+            10
+        )
+
+        expect(response.ready).toBeFalsy()
+        expect(response.dependencies.a).toMatchObject({ ready: false, error: 'timed out after 10ms' })
+    })
+})
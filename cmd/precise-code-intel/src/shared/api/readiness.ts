@@ -0,0 +1,151 @@
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import * as uuid from 'uuid'
+import express from 'express'
+import got from 'got'
+import { wrap } from 'async-middleware'
+import { Connection } from 'typeorm'
+
+/** A check that a dependency of a service is available. */
+export interface DependencyCheck {
+    /** The name of the dependency in the readiness response. */
+    name: string
+
+    /** A function that throws if the dependency is unavailable. */
+    check: () => Promise<void>
+}
+
+/** The result of checking a single dependency. */
+export interface DependencyStatus {
+    /** Whether the dependency is available. */
+    ready: boolean
+
+    /** The reason the dependency is unavailable. */
+    error?: string
+
+    /** The time (in milliseconds) it took to check the dependency. */
+    durationMs: number
+}
+
+/** The payload of the readiness endpoint. */
+export interface ReadinessResponse {
+    /** Whether all dependencies are available. */
+    ready: boolean
+
+    /** The status of each dependency by name. */
+    dependencies: { [name: string]: DependencyStatus }
+}
+
+/**
+ * Run the given dependency checks concurrently. A check that does not complete within
+ * the given timeout is considered to have failed.
+ *
+ * @param checks The dependency checks.
+ * @param timeoutMs The maximum time (in milliseconds) to wait for each check.
+ */
+export async function checkDependencies(checks: DependencyCheck[], timeoutMs: number): Promise<ReadinessResponse> {
+    const statuses = await Promise.all(
+        checks.map(
+            async ({ check }): Promise<DependencyStatus> => {
+                const start = Date.now()
+                let timeout: NodeJS.Timeout | undefined
+
+                try {
+                    await Promise.race([
+                        check(),
+                        new Promise((_, reject) => {
+                            timeout = setTimeout(() => reject(new Error(`timed out after ${timeoutMs}ms`)), timeoutMs)
+                        }),
+                    ])
+
+                    return { ready: true, durationMs: Date.now() - start }
+                } catch (error) {
+                    return {
+                        ready: false,
+                        error: error instanceof Error ? error.message : String(error),
+                        durationMs: Date.now() - start,
+                    }
+                } finally {
+                    if (timeout) {
+                        clearTimeout(timeout)
+                    }
+                }
+            }
+        )
+    )
+
+    return {
+        ready: statuses.every(({ ready }) => ready),
+        dependencies: Object.fromEntries(checks.map(({ name }, i) => [name, statuses[i]])),
+    }
+}
+
+/**
+ * Create a router containing the readiness endpoint. Unlike `/healthz`, which only
+ * reports that the process is running, `/readyz` verifies the dependencies of the
+ * service and responds with a 503 if any of them is unavailable.
+ *
+ * @param checks The dependency checks.
+ * @param timeoutMs The maximum time (in milliseconds) to wait for each check.
+ */
+export function createReadinessRouter(checks: DependencyCheck[], timeoutMs: number): express.Router {
+    const router = express.Router()
+
+    router.get(
+        '/readyz',
+        wrap(
+            async (req: express.Request, res: express.Response<ReadinessResponse>): Promise<void> => {
+                const response = await checkDependencies(checks, timeoutMs)
+                res.status(response.ready ? 200 : 503).json(response)
+            }
+        )
+    )
+
+    return router
+}
+
+/**
+ * Create a check that the Postgres database responds to queries.
+ *
+ * @param connection The Postgres connection.
+ */
+export function checkPostgres(connection: Connection): DependencyCheck {
+    return {
+        name: 'postgres',
+        check: async () => {
+            await connection.query('SELECT 1')
+        },
+    }
+}
+
+/**
+ * Create a check that another precise-code-intel service responds to requests.
+ *
+ * @param name The name of the dependency.
+ * @param url The base url of the service.
+ */
+export function checkPeer(name: string, url: string): DependencyCheck {
+    return {
+        name,
+        check: async () => {
+            await got.get(new URL('/ping', url).href, { retry: 0 })
+        },
+    }
+}
+
+/**
+ * Create a check that a file can be written to the given directory.
+ *
+ * @param name The name of the dependency.
+ * @param directory The directory path.
+ */
+export function checkWritableDirectory(name: string, directory: string): DependencyCheck {
+    return {
+        name,
+        check: async () => {
+            const filename = path.join(directory, `.readyz-${uuid.v4()}`)
+            await fs.writeFile(filename, '')
+            await fs.unlink(filename)
+        },
+    }
+}
@@ -0,0 +1,72 @@
+import express from 'express'
+import { Writable } from 'stream'
+import { ServerSentEvent, SSE_CONTENT_TYPE, writeServerSentEvents } from './sse'
+
+/** A writable stream that stands in for an HTTP response. */
+class FakeResponse extends Writable {
+    public headers: { [name: string]: string } = {}
+    public chunks: string[] = []
+
+    constructor() {
+        // A tiny high water mark exercises the backpressure handling on every write
+        super({ highWaterMark: 1 })
+    }
+
+    public setHeader(name: string, value: string): void {
+        this.headers[name] = value
+    }
+
+    public flushHeaders(): void {
+        /* noop */
+    }
+
+    public _write(chunk: Buffer, encoding: string, callback: () => void): void {
+        this.chunks.push(chunk.toString())
+        setImmediate(callback)
+    }
+
+    public get body(): string {
+        return this.chunks.join('')
+    }
+}
+
+describe('writeServerSentEvents', () => {
+    it('should write each event', async () => {
+        const res = new FakeResponse()
+        await writeServerSentEvents(
+            (res as unknown) as express.Response,
+            generate([
+                { event: 'progress', data: { progress: 10 } },
+                { event: 'progress', data: { progress: 20 } },
+            ])
+        )
+
+        expect(res.headers['Content-Type']).toEqual(SSE_CONTENT_TYPE)
+        expect(res.body).toEqual(
+            'event: progress\ndata: {"progress":10}\n\nevent: progress\ndata: {"progress":20}\n\n'
+        )
+    })
+
+    it('should write the error as the final event', async () => {
+        async function* events(): AsyncIterable<ServerSentEvent> {
+            yield { event: 'progress', data: 1 }
+            throw Object.assign(new Error('Upload not found'), { status: 404, code: 'upload_not_found' })
+        }
+
+        const res = new FakeResponse()
+        await expect(writeServerSentEvents((res as unknown) as express.Response, events())).rejects.toThrow(
+            'Upload not found'
+        )
+
+        expect(res.body).toEqual(
+            'event: progress\ndata: 1\n\nevent: error\ndata: {"error":"Upload not found","code":"upload_not_found"}\n\n'
+        )
+    })
+})
+
+async function* generate<T>(values: T[]): AsyncIterable<T> {
+    for (const value of values) {
+        yield value
+        await Promise.resolve()
+    }
+}
@@ -0,0 +1,73 @@
+import express from 'express'
+import { drained } from './ndjson'
+import { isApiError } from './middleware/errors'
+
+/** The media type of a server-sent event stream. */
+export const SSE_CONTENT_TYPE = 'text/event-stream'
+
+/** A single event of a server-sent event stream. */
+export interface ServerSentEvent {
+    /** The name of the event, used by clients to dispatch it to a listener. */
+    event: string
+
+    /** The payload of the event, written as JSON. */
+    data: unknown
+}
+
+/**
+ * Write each event yielded by the given iterable to the response as a server-sent event.
+ * The headers are sent before the first event is requested so that the client sees the
+ * stream open even when the first event is slow to arrive. Iteration stops early if the
+ * client disconnects.
+ *
+ * If the iterable throws, the error envelope is written as a final `error` event before
+ * the error is rethrown.
+ *
+ * @param res The HTTP response.
+ * @param events The events to write.
+ */
+export async function writeServerSentEvents(
+    res: express.Response,
+    events: AsyncIterable<ServerSentEvent>
+): Promise<void> {
+    res.setHeader('Content-Type', SSE_CONTENT_TYPE)
+    res.setHeader('Cache-Control', 'no-cache')
+    // Disable response buffering in nginx, which would otherwise hold back events
+    res.setHeader('X-Accel-Buffering', 'no')
+    res.flushHeaders()
+
+    try {
+        for await (const event of events) {
+            if (res.destroyed) {
+                return
+            }
+
+            if (!res.write(formatEvent(event))) {
+                await drained(res)
+            }
+
+            if (res.destroyed) {
+                return
+            }
+        }
+    } catch (error) {
+        if (!res.destroyed) {
+            const message = (isApiError(error) && error.message) || 'Unknown error'
+            const code = (isApiError(error) && error.code) || 'internal_error'
+            res.end(formatEvent({ event: 'error', data: { error: message, code } }))
+        }
+
+        throw error
+    }
+
+    res.end()
+}
+
+/**
+ * Format an event in the wire format of a server-sent event stream.
+ *
+ * @param event The event.
+ */
+function formatEvent({ event, data }: ServerSentEvent): string {
+    return `event: ${event}\ndata: ${JSON.stringify(data)}\n\n`
+}
@@ -0,0 +1,93 @@
+import { Cancellation, CancelledError, withCancellation } from './cancellation'
+import { logAndTraceCall } from './tracing'
+
+describe('Cancellation', () => {
+    it('should invoke listeners once', () => {
+        const cancellation = new Cancellation()
+
+        let calls = 0
+        cancellation.onCancel(() => calls++)
+        cancellation.cancel()
+        cancellation.cancel()
+
+        expect(calls).toEqual(1)
+        expect(cancellation.isCancelled).toEqual(true)
+    })
+
+    it('should invoke late listeners immediately', () => {
+        const cancellation = new Cancellation()
+        cancellation.cancel()
+
+        let calls = 0
+        cancellation.onCancel(() => calls++)
+        expect(calls).toEqual(1)
+    })
+
+    it('should not invoke removed listeners', () => {
+        const cancellation = new Cancellation()
+
+        let calls = 0
+        const unregister = cancellation.onCancel(() => calls++)
+        unregister()
+        cancellation.cancel()
+
+        expect(calls).toEqual(0)
+    })
+})
+
+describe('withCancellation', () => {
+    const makeCancelable = (): Promise<string> & { cancel(): void; cancelled: boolean } => {
+        let reject: (error: Error) => void = () => {
+            /* noop */
+        }
+
+        const promise = new Promise<string>((_, r) => {
+            reject = r
+        })
+
+        return Object.assign(promise, {
+            cancelled: false,
+            cancel(): void {
+                this.cancelled = true
+                reject(new Error('cancelled'))
+            },
+        })
+    }
+
+    it('should cancel pending promise', async () => {
+        const cancellation = new Cancellation()
+        const promise = makeCancelable()
+        const result = withCancellation(cancellation, () => promise)
+
+        cancellation.cancel()
+        await expect(result).rejects.toBeInstanceOf(CancelledError)
+        expect(promise.cancelled).toEqual(true)
+    })
+
+    it('should not start work after cancellation', async () => {
+        const cancellation = new Cancellation()
+        cancellation.cancel()
+
+        let called = false
+        await expect(
+            withCancellation(cancellation, () => {
+                called = true
+                return makeCancelable()
+            })
+        ).rejects.toBeInstanceOf(CancelledError)
+        expect(called).toEqual(false)
+    })
+
+    it('should stop traced calls after cancellation', async () => {
+        const cancellation = new Cancellation()
+        cancellation.cancel()
+
+        let called = false
+        await expect(
+            logAndTraceCall({ cancellation }, 'test', () => {
+                called = true
+            })
+        ).rejects.toBeInstanceOf(CancelledError)
+        expect(called).toEqual(false)
+    })
+})
@@ -0,0 +1,131 @@
+import express from 'express'
+
+/**
+ * An error thrown when work is abandoned because the request that initiated it has been
+ * cancelled. The status is the non-standard "client closed request" code, which prevents
+ * the error handler from logging the error as an uncaught exception.
+ */
+export class CancelledError extends Error {
+    public readonly status = 499
+
+    constructor() {
+        super('Request cancelled')
+    }
+}
+
+/**
+ * A signal that the work performed on behalf of a request is no longer needed. Long-running
+ * operations should check the signal before starting each unit of work, and outgoing requests
+ * should be aborted once the signal fires.
+ */
+export class Cancellation {
+    private cancelled = false
+    private listeners = new Set<() => void>()
+
+    /** Whether or not the work has been cancelled. */
+    public get isCancelled(): boolean {
+        return this.cancelled
+    }
+
+    /** Cancel the work and invoke all registered listeners. Subsequent calls have no effect. */
+    public cancel(): void {
+        if (this.cancelled) {
+            return
+        }
+
+        this.cancelled = true
+        for (const listener of this.listeners) {
+            listener()
+        }
+        this.listeners.clear()
+    }
+
+    /**
+     * Register a function to invoke once the work is cancelled. The function is invoked
+     * immediately if the work has already been cancelled. Returns a function that removes
+     * the listener.
+     *
+     * @param listener The function to invoke on cancellation.
+     */
+    public onCancel(listener: () => void): () => void {
+        if (this.cancelled) {
+            listener()
+            return () => {
+                /* noop */
+            }
+        }
+
+        this.listeners.add(listener)
+        return () => this.listeners.delete(listener)
+    }
+
+    /** Throw a `CancelledError` if the work has been cancelled. */
+    public throwIfCancelled(): void {
+        if (this.cancelled) {
+            throw new CancelledError()
+        }
+    }
+}
+
+/**
+ * Create a cancellation that fires when the client closes its connection before the
+ * given response has been fully written. The cancellation is stored on the response so
+ * that subsequent calls (e.g. from middleware enforcing a request timeout and from the
+ * route handler) share the same cancellation.
+ *
+ * @param res The express response.
+ */
+export function cancellationFromResponse(res: express.Response): Cancellation {
+    const existing: unknown = res.locals.cancellation
+    if (existing instanceof Cancellation) {
+        return existing
+    }
+
+    const cancellation = new Cancellation()
+    res.locals.cancellation = cancellation
+
+    let finished = false
+    res.on('finish', () => {
+        finished = true
+    })
+    res.on('close', () => {
+        if (!finished) {
+            cancellation.cancel()
+        }
+    })
+
+    return cancellation
+}
+
+/**
+ * Invoke the given function with a promise that can be cancelled, such as the promise
+ * returned from a `got` request. The promise is cancelled if the given cancellation fires
+ * before it settles, in which case a `CancelledError` is thrown.
+ *
+ * @param cancellation The cancellation, if one is available.
+ * @param f The function that creates the cancelable promise.
+ */
+export async function withCancellation<T>(
+    cancellation: Cancellation | undefined,
+    f: () => Promise<T> & { cancel(): void }
+): Promise<T> {
+    if (!cancellation) {
+        return f()
+    }
+
+    cancellation.throwIfCancelled()
+    const promise = f()
+    const unregister = cancellation.onCancel(() => promise.cancel())
+
+    try {
+        return await promise
+    } catch (error) {
+        if (cancellation.isCancelled) {
+            throw new CancelledError()
+        }
+
+        throw error
+    } finally {
+        unregister()
+    }
+}
@@ -0,0 +1,11 @@
+import { LEGACY_BUNDLE_MANAGER_CAPABILITIES, versionedRoute } from './capabilities'
+
+describe('versionedRoute', () => {
+    it('should prefix routes with the API version', () => {
+        expect(versionedRoute({ version: 1, capabilities: [] }, '/dbs/42/hover')).toEqual('/v1/dbs/42/hover')
+    })
+
+    it('should not prefix routes of legacy bundle managers', () => {
+        expect(versionedRoute(LEGACY_BUNDLE_MANAGER_CAPABILITIES, '/dbs/42/hover')).toEqual('/dbs/42/hover')
+    })
+})
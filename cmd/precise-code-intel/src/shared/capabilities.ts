@@ -0,0 +1,41 @@
+/**
+ * The version of the internal bundle manager API. Routes of this version are served under
+ * `/v1`. Version 0 denotes a bundle manager that only serves unversioned routes and does
+ * not support capability discovery.
+ */
+export const BUNDLE_MANAGER_API_VERSION = 1
+
+/** An optional feature of the bundle manager API that an older bundle manager may not support. */
+export type BundleManagerCapability =
+    | 'batchExists'
+    | 'implementations'
+    | 'hovers'
+    | 'ranges'
+    | 'documentSymbols'
+    | 'diagnostics'
+    | 'verify'
+    | 'paginatedLocations'
+
+/** The response of the capability discovery endpoint of the bundle manager. */
+export interface BundleManagerCapabilities {
+    /** The version of the bundle manager API. */
+    version: number
+
+    /** The optional features that the bundle manager supports. */
+    capabilities: BundleManagerCapability[]
+}
+
+/** The capabilities assumed for a bundle manager that does not support capability discovery. */
+export const LEGACY_BUNDLE_MANAGER_CAPABILITIES: BundleManagerCapabilities = { version: 0, capabilities: [] }
+
+/**
+ * Return the path of the given bundle manager route for a bundle manager with the given
+ * capabilities. Routes are prefixed with the API version when the bundle manager serves
+ * versioned routes.
+ *
+ * @param capabilities The capabilities of the bundle manager.
+ * @param route The unversioned route (e.g. `/dbs/1/hover`).
+ */
+export function versionedRoute({ version }: BundleManagerCapabilities, route: string): string {
+    return version > 0 ? `/v${version}${route}` : route
+}
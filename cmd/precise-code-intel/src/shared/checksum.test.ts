@@ -0,0 +1,57 @@
+import * as fs from 'mz/fs'
+import * as path from 'path'
+import rmfr from 'rmfr'
+import { checksumFile, ChecksumStream } from './checksum'
+import { pipeline as _pipeline, Readable, Writable } from 'stream'
+import { promisify } from 'util'
+
+const pipeline = promisify(_pipeline)
+
+// Digests computed with `sha256sum`
+const helloWorldDigest = 'b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9'
+const worldDigest = '486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7'
+
+describe('checksumFile', () => {
+    let tempPath!: string
+
+    beforeAll(async () => {
+        tempPath = await fs.mkdtemp('test-', { encoding: 'utf8' })
+    })
+
+    afterAll(async () => {
+        await rmfr(tempPath)
+    })
+
+    it('should hash an entire file', async () => {
+        const filename = path.join(tempPath, 'full.txt')
+        await fs.writeFile(filename, 'hello world')
+        expect(await checksumFile(filename)).toEqual(helloWorldDigest)
+    })
+
+    it('should hash an inclusive byte range', async () => {
+        const filename = path.join(tempPath, 'range.txt')
+        await fs.writeFile(filename, 'hello world')
+        expect(await checksumFile(filename, { start: 6, end: 10 })).toEqual(worldDigest)
+    })
+})
+
+describe('ChecksumStream', () => {
+    it('should pass data through unchanged', async () => {
+        const checksum = new ChecksumStream()
+        const chunks: Buffer[] = []
+
+        await pipeline(
+            Readable.from([Buffer.from('hello '), Buffer.from('world')]),
+            checksum,
+            new Writable({
+                write: (chunk: Buffer, _encoding: string, callback: () => void): void => {
+                    chunks.push(chunk)
+                    callback()
+                },
+            })
+        )
+
+        expect(Buffer.concat(chunks).toString()).toEqual('hello world')
+        expect(checksum.digest()).toEqual(helloWorldDigest)
+    })
+})
@@ -0,0 +1,49 @@
+import * as crypto from 'crypto'
+import * as fs from 'mz/fs'
+import { Transform, TransformCallback } from 'stream'
+
+/** The header containing the hex-encoded SHA-256 digest of a request body. */
+export const CHECKSUM_HEADER = 'X-Checksum-Sha256'
+
+/** A pass-through stream that computes the SHA-256 digest of the data written to it. */
+export class ChecksumStream extends Transform {
+    private hash = crypto.createHash('sha256')
+
+    public _transform(chunk: Buffer, _encoding: string, callback: TransformCallback): void {
+        this.hash.update(chunk)
+        callback(undefined, chunk)
+    }
+
+    /** Return the hex-encoded digest. This must only be called once the stream has ended. */
+    public digest(): string {
+        return this.hash.digest('hex')
+    }
+}
+
+/**
+ * Compute the hex-encoded SHA-256 digest of a file, or of a byte range of a file.
+ *
+ * @param filename The file to read.
+ * @param options The inclusive byte range to read.
+ */
+export async function checksumFile(filename: string, options: { start?: number; end?: number } = {}): Promise<string> {
+    const hash = crypto.createHash('sha256')
+    for await (const chunk of fs.createReadStream(filename, options)) {
+        hash.update(chunk)
+    }
+
+    return hash.digest('hex')
+}
+
+/**
+ * Create an error indicating that a payload does not match the checksum supplied by the client.
+ *
+ * @param expected The digest supplied by the client.
+ * @param actual The digest of the received payload.
+ */
+export function checksumMismatchError(expected: string, actual: string): Error {
+    return Object.assign(new Error(`Checksum mismatch: expected ${expected}, received ${actual}`), {
+        status: 400,
+        code: 'checksum_mismatch',
+    })
+}
@@ -0,0 +1,72 @@
+import * as sinon from 'sinon'
+import { CircuitBreaker } from './circuit-breaker'
+
+describe('CircuitBreaker', () => {
+    let clock: sinon.SinonFakeTimers
+
+    beforeEach(() => {
+        clock = sinon.useFakeTimers()
+    })
+
+    afterEach(() => {
+        clock.restore()
+    })
+
+    const succeed = (): Promise<string> => Promise.resolve('ok')
+    const fail = (): Promise<string> => Promise.reject(new Error('oops'))
+
+    it('should open after consecutive failures', async () => {
+        const breaker = new CircuitBreaker('test', 2, 1000)
+
+        await expect(breaker.call(fail)).rejects.toThrow('oops')
+        await expect(breaker.call(succeed)).resolves.toEqual('ok')
+        await expect(breaker.call(fail)).rejects.toThrow('oops')
+        expect(breaker.state).toEqual('closed')
+        await expect(breaker.call(fail)).rejects.toThrow('oops')
+        expect(breaker.state).toEqual('open')
+
+        const f = jest.fn(succeed)
+        await expect(breaker.call(f)).rejects.toMatchObject({ status: 503, code: 'dependency_unavailable' })
+        expect(f).not.toHaveBeenCalled()
+    })
+
+    it('should close after a successful trial call', async () => {
+        const breaker = new CircuitBreaker('test', 1, 1000)
+
+        await expect(breaker.call(fail)).rejects.toThrow('oops')
+        expect(breaker.state).toEqual('open')
+
+        clock.tick(1000)
+        expect(breaker.state).toEqual('half-open')
+        await expect(breaker.call(succeed)).resolves.toEqual('ok')
+        expect(breaker.state).toEqual('closed')
+    })
+
+    it('should reopen after a failed trial call', async () => {
+        const breaker = new CircuitBreaker('test', 3, 1000)
+
+        for (let i = 0; i < 3; i++) {
+            await expect(breaker.call(fail)).rejects.toThrow('oops')
+        }
+
+        clock.tick(1000)
+        await expect(breaker.call(fail)).rejects.toThrow('oops')
+        expect(breaker.state).toEqual('open')
+    })
+
+    it('should ignore errors that are not failures', async () => {
+        const breaker = new CircuitBreaker('test', 1, 1000, error => !(error instanceof TypeError))
+
+        await expect(breaker.call(() => Promise.reject(new TypeError('bad request')))).rejects.toThrow('bad request')
+        expect(breaker.state).toEqual('closed')
+    })
+
+    it('should be disabled by a non-positive threshold', async () => {
+        const breaker = new CircuitBreaker('test', 0, 1000)
+
+        for (let i = 0; i < 10; i++) {
+            await expect(breaker.call(fail)).rejects.toThrow('oops')
+        }
+        expect(breaker.state).toEqual('closed')
+    })
+})
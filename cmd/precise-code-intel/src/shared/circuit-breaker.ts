@@ -0,0 +1,90 @@
+/** The state of a circuit breaker. */
+export type CircuitState = 'closed' | 'open' | 'half-open'
+
+/**
+ * A circuit breaker that stops calls to a failing dependency. After `threshold` consecutive
+ * failed calls, the circuit opens and calls are rejected immediately without invoking the
+ * dependency. Once `cooldownMs` have passed, a single trial call is let through: if it
+ * succeeds, the circuit closes again, otherwise it stays open for another cooldown period.
+ */
+export class CircuitBreaker {
+    /** The number of consecutive failed calls. */
+    private failures = 0
+
+    /** The time (in milliseconds since the epoch) that the circuit last opened. */
+    private openedAt: number | undefined
+
+    /** Whether a trial call is in flight while the circuit is half-open. */
+    private probing = false
+
+    /**
+     * Create a new `CircuitBreaker`.
+     *
+     * @param name The name of the dependency, used in the errors of rejected calls.
+     * @param threshold The number of consecutive failures that opens the circuit (<= 0 disables the breaker).
+     * @param cooldownMs The time (in milliseconds) the circuit stays open before a trial call.
+     * @param isFailure Determines if an error thrown by a call indicates the dependency is unhealthy.
+     */
+    constructor(
+        private name: string,
+        private threshold: number,
+        private cooldownMs: number,
+        private isFailure: (error: unknown) => boolean = () => true
+    ) {}
+
+    /** The current state of the circuit. */
+    public get state(): CircuitState {
+        if (this.openedAt === undefined) {
+            return 'closed'
+        }
+
+        return Date.now() - this.openedAt < this.cooldownMs ? 'open' : 'half-open'
+    }
+
+    /**
+     * Invoke the given function unless the circuit is open, and record whether it failed.
+     * Calls that are rejected throw an error with a 503 status.
+     *
+     * @param f The function that calls the dependency.
+     */
+    public async call<T>(f: () => Promise<T>): Promise<T> {
+        if (this.threshold <= 0) {
+            return f()
+        }
+
+        const state = this.state
+        if (state === 'open' || (state === 'half-open' && this.probing)) {
+            throw Object.assign(new Error(`${this.name} is unavailable`), {
+                status: 503,
+                code: 'dependency_unavailable',
+            })
+        }
+
+        const probe = state === 'half-open'
+        if (probe) {
+            this.probing = true
+        }
+
+        try {
+            const result = await f()
+            this.failures = 0
+            this.openedAt = undefined
+            return result
+        } catch (error) {
+            // Errors that say nothing about the health of the dependency (such as a client
+            // error or a cancelled request) neither count as a failure nor close the circuit
+            if (this.isFailure(error)) {
+                this.failures++
+                if (probe || this.failures >= this.threshold) {
+                    this.openedAt = Date.now()
+                }
+            }
+
+            throw error
+        } finally {
+            if (probe) {
+                this.probing = false
+            }
+        }
+    }
+}
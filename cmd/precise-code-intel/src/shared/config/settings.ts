@@ -1,4 +1,4 @@
-import { readEnvInt } from '../settings'
+import { readEnvBool, readEnvInt } from '../settings'
 
 /** HTTP address for internal frontend HTTP API. */
 export const SRC_FRONTEND_INTERNAL = process.env.SRC_FRONTEND_INTERNAL || 'sourcegraph-frontend-internal'
@@ -11,5 +11,99 @@ export const SRC_FRONTEND_INTERNAL = process.env.SRC_FRONTEND_INTERNAL || 'sourc
  */
 export const DELAY_BEFORE_UNREACHABLE_LOG = readEnvInt('DELAY_BEFORE_UNREACHABLE_LOG', 15)
 
+/** The maximum time (in seconds) to wait for each dependency check of the readiness endpoint. */
+export const READINESS_CHECK_TIMEOUT = readEnvInt('READINESS_CHECK_TIMEOUT', 5)
+
+/** The maximum number of concurrent connections to each bundle manager. */
+export const BUNDLE_MANAGER_MAX_CONNECTIONS_PER_HOST = readEnvInt('BUNDLE_MANAGER_MAX_CONNECTIONS_PER_HOST', 64)
+
+/** The maximum number of idle connections to each bundle manager kept open for reuse. */
+export const BUNDLE_MANAGER_MAX_IDLE_CONNECTIONS_PER_HOST = readEnvInt('BUNDLE_MANAGER_MAX_IDLE_CONNECTIONS_PER_HOST', 16)
+
+/** The maximum time (in seconds) to wait for a connection to a bundle manager to be established. */
+export const BUNDLE_MANAGER_CONNECT_TIMEOUT = readEnvInt('BUNDLE_MANAGER_CONNECT_TIMEOUT', 5)
+
+/**
+ * The maximum time (in seconds) to wait for the response headers of a bundle manager once a
+ * request has been sent. This is generous as stitching a large upload can take a while.
+ */
+export const BUNDLE_MANAGER_RESPONSE_TIMEOUT = readEnvInt('BUNDLE_MANAGER_RESPONSE_TIMEOUT', 60 * 2) // 2 minutes
+
+/** The transports over which the api-server and worker can send requests to a bundle manager. */
+export const BUNDLE_MANAGER_TRANSPORTS = ['http', 'grpc'] as const
+
+/** A transport over which requests are sent to a bundle manager. */
+export type BundleManagerTransport = typeof BUNDLE_MANAGER_TRANSPORTS[number]
+
+/**
+ * Return the bundle manager transport with the given name. Throws an error if the transport
+ * is not supported.
+ *
+ * @param value The name of the transport.
+ */
+export function parseBundleManagerTransport(value: string): BundleManagerTransport {
+    const transport = BUNDLE_MANAGER_TRANSPORTS.find(transport => transport === value)
+    if (!transport) {
+        throw new Error(`Unsupported bundle manager transport ${value}`)
+    }
+
+    return transport
+}
+
+/**
+ * The transport of bundle queries and bundle uploads sent to the bundle managers. The `grpc`
+ * transport uses the gRPC API of the bundle managers (see shared/grpc/bundle-manager.proto),
+ * which every bundle manager must serve on BUNDLE_MANAGER_GRPC_PORT. Requests without a gRPC
+ * method are always sent over HTTP.
+ */
+export const BUNDLE_MANAGER_TRANSPORT = parseBundleManagerTransport(process.env.BUNDLE_MANAGER_TRANSPORT || 'http')
+
+/** The port of the gRPC API of the bundle managers (<= 0 disables the gRPC API). */
+export const BUNDLE_MANAGER_GRPC_PORT = readEnvInt('BUNDLE_MANAGER_GRPC_PORT', 3189)
+
+/**
+ * The number of gRPC connections opened to each bundle manager. Requests are spread over
+ * the connections in turn.
+ */
+export const BUNDLE_MANAGER_GRPC_CONNECTIONS_PER_HOST = readEnvInt('BUNDLE_MANAGER_GRPC_CONNECTIONS_PER_HOST', 4)
+
+/**
+ * The maximum number of requests that a server handles at once. Requests beyond this limit
+ * are rejected with a 503 response (<= 0 means no limit).
+ */
+export const MAX_CONCURRENT_REQUESTS = readEnvInt('MAX_CONCURRENT_REQUESTS', 500)
+
+/** The time (in seconds) that clients rejected by MAX_CONCURRENT_REQUESTS are asked to wait before retrying. */
+export const SERVER_BUSY_RETRY_AFTER = readEnvInt('SERVER_BUSY_RETRY_AFTER', 1)
+
+/**
+ * The maximum number of requests that a single client (identified by its remote address) can
+ * make to a server within RATE_LIMIT_WINDOW seconds. Requests beyond this limit are rejected
+ * with a 429 response (<= 0 means no limit).
+ */
+export const MAX_REQUESTS_PER_CLIENT = readEnvInt('MAX_REQUESTS_PER_CLIENT', -1)
+
+/** The length (in seconds) of the window over which MAX_REQUESTS_PER_CLIENT is enforced. */
+export const RATE_LIMIT_WINDOW = readEnvInt('RATE_LIMIT_WINDOW', 1)
+
 /** How long to wait between polling config. */
 export const CONFIG_POLL_INTERVAL = 5
+
+/**
+ * The shared secret that callers of the internal precise-code-intel APIs must supply as a
+ * bearer token. Authentication is disabled when this value is empty.
+ */
+export const PRECISE_CODE_INTEL_INTERNAL_API_TOKEN = process.env.PRECISE_CODE_INTEL_INTERNAL_API_TOKEN || ''
+
+/**
+ * If true, the janitor tasks of the api-server and bundle manager log the uploads, dumps, and
+ * files that they would remove instead of removing them.
+ */
+export const JANITOR_DRY_RUN = readEnvBool('JANITOR_DRY_RUN', false)
+
+/**
+ * If true, closest dump queries read the visible dumps of a commit from the lsif_nearest_uploads
+ * table, which the api-server refreshes in the background, instead of traversing the commit graph
+ * on every query.
+ */
+export const USE_NEAREST_UPLOADS = readEnvBool('USE_NEAREST_UPLOADS', true)
@@ -4,6 +4,9 @@ export const DBS_DIR = 'dbs'
 /** The directory relative to the storage where raw dumps are uploaded. */
 export const UPLOADS_DIR = 'uploads'
 
+/** The directory relative to the storage where bundles downloaded from object storage are cached. */
+export const BUNDLE_CACHE_DIR = 'bundle-cache'
+
 /** The maximum number of rows to bulk insert in Postgres. */
 export const MAX_POSTGRES_BATCH_SIZE = 5000
 
@@ -25,3 +28,9 @@ export const MAX_COMMITS_PER_UPDATE = MAX_TRAVERSAL_LIMIT * 1.5
 
 /** The number of remote dumps we will query per page of reference results. */
 export const DEFAULT_REFERENCES_REMOTE_DUMP_LIMIT = 20
+
+/**
+ * The maximum number of concurrent existence checks sent to a bundle manager that does
+ * not support batched existence checks.
+ */
+export const MAX_CONCURRENT_EXISTS_REQUESTS = 10
@@ -0,0 +1,210 @@
+import * as settings from './settings'
+import { Connection } from 'typeorm'
+import { Logger } from 'winston'
+import { PostgresDriver } from 'typeorm/driver/postgres/PostgresDriver'
+
+/**
+ * The channel on which the identifier of an upload is sent when its state, stage, or progress
+ * changes or it is removed.
+ */
+export const UPLOAD_STATE_CHANNEL = 'lsif_upload_state'
+
+/** A subscriber of a notification listener. */
+export interface NotificationSubscriber {
+    /** Invoked with the payload of each notification received on the channel. */
+    onNotification(payload: string): void
+
+    /** Invoked once the listener has been stopped. No further notifications are received. */
+    onStop(): void
+}
+
+/** The subset of a node-postgres client used to receive notifications. */
+interface PostgresClient {
+    query(text: string): Promise<unknown>
+    on(event: 'notification', listener: (message: { channel: string; payload?: string }) => void): void
+    on(event: 'error', listener: (error: Error) => void): void
+    off(event: 'notification', listener: (message: { channel: string; payload?: string }) => void): void
+    off(event: 'error', listener: (error: Error) => void): void
+}
+
+/** A client held from the pool along with the function that returns it to the pool. */
+interface HeldClient {
+    client: PostgresClient
+    release: (error?: Error) => void
+}
+
+/**
+ * Receives the notifications sent on a single Postgres channel and dispatches their payloads to
+ * subscribers. All subscribers share one client held from the pool for the lifetime of the listener.
+ * If the client loses its connection, the channel is listened to again on a new client after a delay.
+ * Notifications sent while disconnected are lost, so subscribers must not rely on receiving every
+ * notification.
+ */
+export class NotificationListener {
+    private subscribers = new Set<NotificationSubscriber>()
+    private held?: HeldClient
+    private reconnectTimer?: NodeJS.Timeout
+    private stopped = false
+
+    /**
+     * Create a new `NotificationListener`.
+     *
+     * @param connection The Postgres connection.
+     * @param channel The name of the channel.
+     * @param logger The logger instance.
+     */
+    constructor(private connection: Connection, private channel: string, private logger: Logger) {}
+
+    /** Start listening on the channel. Failures are logged and retried in the background. */
+    public async start(): Promise<void> {
+        if (this.stopped || this.held) {
+            return
+        }
+
+        let held: HeldClient
+        try {
+            held = await this.listen()
+        } catch (error) {
+            this.logger.error('Failed to listen for notifications', { channel: this.channel, error })
+            this.scheduleReconnect()
+            return
+        }
+
+        if (this.stopped) {
+            await this.unlisten(held)
+            return
+        }
+
+        this.held = held
+        this.logger.debug('Listening for notifications', { channel: this.channel })
+    }
+
+    /**
+     * Register a subscriber. Returns a function that removes the subscriber. The subscriber
+     * is stopped immediately if the listener has already been stopped.
+     *
+     * @param subscriber The subscriber.
+     */
+    public subscribe(subscriber: NotificationSubscriber): () => void {
+        if (this.stopped) {
+            subscriber.onStop()
+            return () => {
+                /* noop */
+            }
+        }
+
+        this.subscribers.add(subscriber)
+        return () => this.subscribers.delete(subscriber)
+    }
+
+    /** Stop all subscribers, stop listening on the channel, and return the client to the pool. */
+    public async stop(): Promise<void> {
+        if (this.stopped) {
+            return
+        }
+        this.stopped = true
+
+        if (this.reconnectTimer) {
+            clearTimeout(this.reconnectTimer)
+            this.reconnectTimer = undefined
+        }
+
+        for (const subscriber of this.subscribers) {
+            subscriber.onStop()
+        }
+        this.subscribers.clear()
+
+        const held = this.held
+        this.held = undefined
+        if (held) {
+            await this.unlisten(held)
+        }
+    }
+
+    /** Hold a client from the pool and issue a LISTEN command on it. */
+    private async listen(): Promise<HeldClient> {
+        // Notifications are only delivered to the session that issued the LISTEN command, so
+        // a client is held from the pool directly instead of being borrowed for each query.
+        const [client, release]: [PostgresClient, (error?: Error) => void] = await (this.connection
+            .driver as PostgresDriver).obtainMasterConnection()
+
+        const held = { client, release }
+        client.on('notification', this.onNotification)
+        client.on('error', this.onError)
+
+        try {
+            await client.query(`LISTEN ${this.channel}`)
+        } catch (error) {
+            this.detach(held, error)
+            throw error
+        }
+
+        return held
+    }
+
+    /**
+     * Issue an UNLISTEN command on the given client and return it to the pool. The client
+     * is discarded if the command fails.
+     *
+     * @param held The held client.
+     */
+    private async unlisten(held: HeldClient): Promise<void> {
+        try {
+            await held.client.query(`UNLISTEN ${this.channel}`)
+        } catch (error) {
+            this.detach(held, error)
+            return
+        }
+
+        this.detach(held)
+    }
+
+    /**
+     * Remove the listeners from the given client and return it to the pool. A client that
+     * is released with an error is discarded by the pool.
+     *
+     * @param held The held client.
+     * @param error The error that made the client unusable, if any.
+     */
+    private detach(held: HeldClient, error?: Error): void {
+        held.client.off('notification', this.onNotification)
+        held.client.off('error', this.onError)
+        held.release(error)
+    }
+
+    private onNotification = ({ channel, payload }: { channel: string; payload?: string }): void => {
+        if (channel !== this.channel) {
+            return
+        }
+
+        for (const subscriber of this.subscribers) {
+            subscriber.onNotification(payload || '')
+        }
+    }
+
+    private onError = (error: Error): void => {
+        const held = this.held
+        if (!held) {
+            // The error occurred while listening and is handled by the caller
+            return
+        }
+
+        this.held = undefined
+        this.detach(held, error)
+        this.logger.error('Lost connection of notification listener', { channel: this.channel, error })
+        this.scheduleReconnect()
+    }
+
+    private scheduleReconnect(): void {
+        if (this.stopped || this.reconnectTimer) {
+            return
+        }
+
+        this.reconnectTimer = setTimeout(() => {
+            this.reconnectTimer = undefined
+            this.start().catch(() => {
+                /* noop */
+            })
+        }, settings.NOTIFICATION_RECONNECT_INTERVAL * 1000)
+    }
+}
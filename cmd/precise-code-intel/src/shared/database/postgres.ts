@@ -6,6 +6,7 @@ import { Connection, createConnection as _createConnection, EntityManager } from
 import { instrument } from '../metrics'
 import { Logger } from 'winston'
 import { PostgresConnectionCredentialsOptions } from 'typeorm/driver/postgres/PostgresConnectionCredentialsOptions'
+import { IsolationLevel } from 'typeorm/driver/types/IsolationLevel'
 import { TlsOptions } from 'tls'
 import { DatabaseLogger } from './logger'
 import * as settings from './settings'
@@ -16,7 +17,7 @@ import * as settings from './settings'
  * directory, as we watch the DB to ensure we're on at least this version prior to
  * making use of the DB (which the frontend may still be migrating).
  */
-const MINIMUM_MIGRATION_VERSION = 1528395666
+const MINIMUM_MIGRATION_VERSION = 1528395684
 
 /**
  * Create a Postgres connection. This creates a typorm connection pool with
@@ -164,12 +165,16 @@ export function instrumentQuery<T>(callback: () => Promise<T>): Promise<T> {
  *
  * @param connection The Postgres connection.
  * @param callback The function invoke with the entity manager.
+ * @param isolationLevel The isolation level of the transaction. Defaults to the isolation level of the server.
  */
 export function withInstrumentedTransaction<T>(
     connection: Connection,
-    callback: (connection: EntityManager) => Promise<T>
+    callback: (connection: EntityManager) => Promise<T>,
+    isolationLevel?: IsolationLevel
 ): Promise<T> {
-    return instrumentQuery(() => connection.transaction(callback))
+    return instrumentQuery(() =>
+        isolationLevel ? connection.transaction(isolationLevel, callback) : connection.transaction(callback)
+    )
 }
 
 /**
@@ -14,3 +14,6 @@ export const MIN_CONNECTION_RETRY_TIMEOUT = readEnvInt('MIN_CONNECTION_RETRY_TIM
 
 /** How long to wait (maximum, in seconds) between Postgres connection attempts. */
 export const MAX_CONNECTION_RETRY_TIMEOUT = readEnvInt('MAX_CONNECTION_RETRY_TIMEOUT', 1)
+
+/** How long to wait (in seconds) before reconnecting a notification listener whose connection was lost. */
+export const NOTIFICATION_RECONNECT_INTERVAL = readEnvInt('NOTIFICATION_RECONNECT_INTERVAL', 5)
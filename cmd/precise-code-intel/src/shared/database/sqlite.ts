@@ -1,28 +1,133 @@
+import * as sqlite3 from 'sqlite3'
 import { Connection, createConnection as _createConnection } from 'typeorm'
 import { Logger } from 'winston'
 import { DatabaseLogger } from './logger'
 
+/** Options that control how a SQLite database is opened. */
+export interface SqliteConnectionOptions {
+    /**
+     * Whether or not to open the database for reads only. The schema of a read-only database
+     * is not synchronized with the entities, and any attempt to write to it is rejected. This
+     * should be used for bundles that are never modified after conversion.
+     */
+    readOnly?: boolean
+
+    /** The maximum number of bytes of the database file to memory-map. */
+    mmapSizeBytes?: number
+
+    /** The maximum number of kibibytes to use for the page cache of the connection. */
+    cacheSizeKiB?: number
+
+    /** The number of milliseconds to wait for a lock on the database before failing a query. */
+    busyTimeoutMs?: number
+
+    /**
+     * The name of the connection, which defaults to the database filename. Connections that
+     * are open at the same time must have distinct names.
+     */
+    name?: string
+}
+
 /**
  * Create a SQLite connection from the given filename.
  *
  * @param database The database filename.
  * @param entities The set of expected entities present in this schema.
  * @param logger The logger instance.
+ * @param options The options that control how the database is opened.
  */
-export function createSqliteConnection(
+export async function createSqliteConnection(
     database: string,
     // Decorators are not possible type check
     // eslint-disable-next-line @typescript-eslint/ban-types
     entities: Function[],
-    logger: Logger
+    logger: Logger,
+    { readOnly = false, mmapSizeBytes, cacheSizeKiB, busyTimeoutMs, name = database }: SqliteConnectionOptions = {}
 ): Promise<Connection> {
-    return _createConnection({
+    const connection = await _createConnection({
         type: 'sqlite',
-        name: database,
+        name,
         database,
         entities,
-        synchronize: true,
+        synchronize: !readOnly,
         logger: new DatabaseLogger(logger),
         maxQueryExecutionTime: 1000,
     })
+
+    const pragmas = sqlitePragmas({ readOnly, mmapSizeBytes, cacheSizeKiB, busyTimeoutMs })
+    try {
+        for (const pragma of pragmas) {
+            await connection.query(pragma)
+        }
+    } catch (error) {
+        await connection.close()
+        throw error
+    }
+
+    return connection
+}
+
+/**
+ * Open a SQLite database handle from the given filename. Unlike a typeorm connection, the
+ * handle can prepare statements. It is configured with the same options as the connections
+ * created by `createSqliteConnection`, and must be closed with `closeSqliteDatabase`.
+ *
+ * @param database The database filename.
+ * @param options The options that control how the database is opened.
+ */
+export async function openSqliteDatabase(
+    database: string,
+    options: SqliteConnectionOptions = {}
+): Promise<sqlite3.Database> {
+    const mode = options.readOnly ? sqlite3.OPEN_READONLY : sqlite3.OPEN_READWRITE | sqlite3.OPEN_CREATE
+    const handle = await new Promise<sqlite3.Database>((resolve, reject) => {
+        const db = new sqlite3.Database(database, mode, (error: Error | null) => (error ? reject(error) : resolve(db)))
+    })
+
+    try {
+        for (const pragma of sqlitePragmas(options)) {
+            await new Promise((resolve, reject) =>
+                handle.run(pragma, (error: Error | null) => (error ? reject(error) : resolve()))
+            )
+        }
+    } catch (error) {
+        await closeSqliteDatabase(handle)
+        throw error
+    }
+
+    return handle
+}
+
+/**
+ * Close a SQLite database handle opened with `openSqliteDatabase`. All statements prepared
+ * on the handle must be finalized first.
+ *
+ * @param handle The database handle.
+ */
+export function closeSqliteDatabase(handle: sqlite3.Database): Promise<void> {
+    return new Promise((resolve, reject) => handle.close(error => (error ? reject(error) : resolve())))
+}
+
+/**
+ * Return the pragmas that apply the given options to a new SQLite connection.
+ *
+ * @param options The options that control how the database is opened.
+ */
+function sqlitePragmas({ readOnly, mmapSizeBytes, cacheSizeKiB, busyTimeoutMs }: SqliteConnectionOptions): string[] {
+    const pragmas = []
+    if (mmapSizeBytes !== undefined) {
+        pragmas.push(`PRAGMA mmap_size = ${mmapSizeBytes}`)
+    }
+    if (cacheSizeKiB !== undefined) {
+        // A negative value is interpreted as a number of kibibytes rather than pages
+        pragmas.push(`PRAGMA cache_size = ${-cacheSizeKiB}`)
+    }
+    if (busyTimeoutMs !== undefined) {
+        pragmas.push(`PRAGMA busy_timeout = ${busyTimeoutMs}`)
+    }
+    if (readOnly) {
+        pragmas.push('PRAGMA query_only = ON')
+    }
+
+    return pragmas
 }
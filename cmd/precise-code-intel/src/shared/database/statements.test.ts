@@ -0,0 +1,124 @@
+import * as fs from 'mz/fs'
+import * as nodepath from 'path'
+import * as uuid from 'uuid'
+import rmfr from 'rmfr'
+import { Database as SqliteDatabase } from 'sqlite3'
+import { closeSqliteDatabase, openSqliteDatabase } from './sqlite'
+import { StatementCache } from './statements'
+
+describe('StatementCache', () => {
+    let storageRoot!: string
+    let connection!: SqliteDatabase
+
+    beforeAll(async () => {
+        storageRoot = await fs.mkdtemp('test-', { encoding: 'utf8' })
+        connection = await openSqliteDatabase(nodepath.join(storageRoot, uuid.v4()))
+        await new Promise((resolve, reject) =>
+            connection.exec(
+                `
+                    CREATE TABLE "kv" ("key" text PRIMARY KEY NOT NULL, "value" integer NOT NULL);
+                    INSERT INTO kv VALUES ('a', 1), ('b', 2), ('c', 3);
+                `,
+                error => (error ? reject(error) : resolve())
+            )
+        )
+    })
+
+    afterAll(async () => {
+        if (connection) {
+            await closeSqliteDatabase(connection)
+        }
+        if (storageRoot) {
+            await rmfr(storageRoot)
+        }
+    })
+
+    it('should reuse prepared statements and evict the least recently used', async () => {
+        const statements = new StatementCache(connection, 2)
+        try {
+            const query = 'SELECT value FROM kv WHERE key = ?'
+            expect(await statements.get(query, ['a'])).toEqual({ value: 1 })
+            expect(await statements.get(query, ['b'])).toEqual({ value: 2 })
+            expect(await statements.get(query, ['z'])).toBeUndefined()
+            expect(statements.size).toEqual(1)
+
+            expect(await statements.all('SELECT key FROM kv ORDER BY key')).toEqual([
+                { key: 'a' },
+                { key: 'b' },
+                { key: 'c' },
+            ])
+            expect(await statements.all('SELECT COUNT(*) AS count FROM kv')).toEqual([{ count: 3 }])
+            expect(statements.size).toEqual(2)
+            expect(await statements.get(query, ['c'])).toEqual({ value: 3 })
+            expect(statements.size).toEqual(2)
+        } finally {
+            await statements.finalize()
+        }
+    })
+
+    it('should not keep statements that fail to prepare', async () => {
+        const statements = new StatementCache(connection, 2)
+        try {
+            await expect(statements.all('SELECT missing FROM kv')).rejects.toThrowError(/no such column/)
+            expect(statements.size).toEqual(0)
+        } finally {
+            await statements.finalize()
+        }
+    })
+
+    it('should reject every pending query of a statement that fails to prepare', async () => {
+        const statements = new StatementCache(connection, 2)
+        try {
+            const query = 'SELECT missing FROM kv WHERE key = ?'
+            const results = await Promise.all(
+                ['a', 'b', 'c'].map(key => statements.get(query, [key]).then(() => undefined, (error: Error) => error))
+            )
+            for (const error of results) {
+                expect(error && error.message).toMatch(/no such column/)
+            }
+
+            // A later query prepares the statement again rather than waiting on the failure
+            await expect(statements.get(query, ['a'])).rejects.toThrowError(/no such column/)
+            expect(statements.size).toEqual(0)
+        } finally {
+            await statements.finalize()
+        }
+    })
+
+    it('should keep statements whose queries fail', async () => {
+        const statements = new StatementCache(connection, 2)
+        try {
+            const query = 'SELECT value FROM kv WHERE key = ?'
+            await expect(statements.all(query, ['a', 'b'])).rejects.toThrowError(/SQLITE_RANGE/)
+            expect(await statements.get(query, ['a'])).toEqual({ value: 1 })
+            expect(statements.size).toEqual(1)
+        } finally {
+            await statements.finalize()
+        }
+    })
+
+    it('should finalize the prepared statements of a failed pin', async () => {
+        const handle = await openSqliteDatabase(nodepath.join(storageRoot, uuid.v4()))
+        const statements = new StatementCache(handle, 0)
+        await expect(statements.pin(['SELECT 1', 'SELECT 2', 'SELECT missing'])).rejects.toThrowError(/no such column/)
+        await statements.finalize()
+
+        // Closing a database with unfinalized statements fails
+        await closeSqliteDatabase(handle)
+    })
+
+    it('should run pinned statements outside of the cache', async () => {
+        const statements = new StatementCache(connection, 0)
+        try {
+            const query = 'SELECT value FROM kv WHERE key = ?'
+            await statements.pin([query])
+            expect(await statements.get(query, ['b'])).toEqual({ value: 2 })
+            expect(await statements.get("SELECT value FROM kv WHERE key = 'c'")).toEqual({ value: 3 })
+            expect(statements.size).toEqual(0)
+
+            await expect(statements.pin(['SELECT missing FROM kv'])).rejects.toThrowError(/no such column/)
+        } finally {
+            await statements.finalize()
+        }
+    })
+})
@@ -0,0 +1,159 @@
+import { Database as SqliteDatabase, Statement } from 'sqlite3'
+
+/**
+ * A cache of prepared statements of a single SQLite connection indexed by their SQL text,
+ * so that queries run on every request are not parsed and planned each time. Once more
+ * than `max` statements are cached, the least recently used statement is finalized.
+ * Statements prepared with `pin` are kept until the cache is finalized.
+ */
+export class StatementCache {
+    /** A map from SQL text to prepared statements, least recently used first. */
+    private statements = new Map<string, Promise<Statement>>()
+
+    /** A map from SQL text to pinned prepared statements. */
+    private pinned = new Map<string, Statement>()
+
+    /**
+     * Create a new `StatementCache`.
+     *
+     * @param database The SQLite database handle on which statements are prepared.
+     * @param max The maximum number of unpinned statements to keep prepared (0 disables the cache).
+     */
+    constructor(private database: SqliteDatabase, private max: number) {}
+
+    /** The number of prepared statements held by the cache, excluding pinned statements. */
+    public get size(): number {
+        return this.statements.size
+    }
+
+    /**
+     * Prepare the given queries and keep them prepared until the cache is finalized. This
+     * rejects if any query cannot be prepared, in which case no query is pinned.
+     *
+     * @param queries The SQL text of the queries.
+     */
+    public async pin(queries: string[]): Promise<void> {
+        const promises = queries.map(sql => this.prepareStatement(sql))
+
+        let statements: Statement[]
+        try {
+            statements = await Promise.all(promises)
+        } catch (error) {
+            // Finalize the queries that were prepared so that the database can be closed
+            await Promise.all(promises.map(promise => promise.then(finalizeStatement, () => undefined)))
+            throw error
+        }
+
+        for (const [i, statement] of statements.entries()) {
+            this.pinned.set(queries[i], statement)
+        }
+    }
+
+    /**
+     * Run the given query with a cached prepared statement and return all rows.
+     *
+     * @param sql The SQL text of the query.
+     * @param parameters The values bound to the placeholders of the query.
+     */
+    public async all<T>(sql: string, parameters: unknown[] = []): Promise<T[]> {
+        if (this.max <= 0 && !this.pinned.has(sql)) {
+            return new Promise((resolve, reject) =>
+                this.database.all(sql, parameters, (error: Error | null, rows: T[]) =>
+                    error ? reject(error) : resolve(rows)
+                )
+            )
+        }
+
+        const statement = this.pinned.get(sql) || (await this.getStatement(sql))
+        return new Promise((resolve, reject) =>
+            statement.all(parameters, (error: Error | null, rows: T[]) => (error ? reject(error) : resolve(rows)))
+        )
+    }
+
+    /**
+     * Run the given query with a cached prepared statement and return the first row, or
+     * undefined if the query returns no rows.
+     *
+     * @param sql The SQL text of the query.
+     * @param parameters The values bound to the placeholders of the query.
+     */
+    public async get<T>(sql: string, parameters: unknown[] = []): Promise<T | undefined> {
+        const rows = await this.all<T>(sql, parameters)
+        return rows.length > 0 ? rows[0] : undefined
+    }
+
+    /** Finalize all cached and pinned statements. This must be called before the connection is closed. */
+    public async finalize(): Promise<void> {
+        const statements = Array.from(this.statements.values())
+        const pinned = Array.from(this.pinned.values())
+        this.statements.clear()
+        this.pinned.clear()
+
+        await Promise.all([
+            ...statements.map(promise => promise.then(finalizeStatement, () => undefined)),
+            ...pinned.map(finalizeStatement),
+        ])
+    }
+
+    /**
+     * Return the prepared statement for the given query, preparing it on first use and
+     * finalizing the least recently used statement if the cache is full. A statement that
+     * fails to prepare is not cached.
+     *
+     * @param sql The SQL text of the query.
+     */
+    private getStatement(sql: string): Promise<Statement> {
+        const cached = this.statements.get(sql)
+        if (cached) {
+            // Move to the end of the map so that it is evicted last
+            this.statements.delete(sql)
+            this.statements.set(sql, cached)
+            return cached
+        }
+
+        const promise = this.prepareStatement(sql)
+        this.statements.set(sql, promise)
+        promise.catch(() => {
+            if (this.statements.get(sql) === promise) {
+                this.statements.delete(sql)
+            }
+        })
+
+        if (this.statements.size > this.max) {
+            const [evictedSql, evicted]: [string, Promise<Statement>] = this.statements.entries().next().value
+            this.statements.delete(evictedSql)
+            evicted.then(finalizeStatement, () => undefined)
+        }
+
+        return promise
+    }
+
+    /**
+     * Prepare the given query. Operations on a statement that failed to prepare are never
+     * completed, so the statement is only returned once it is known to be prepared.
+     *
+     * @param sql The SQL text of the query.
+     */
+    private prepareStatement(sql: string): Promise<Statement> {
+        return new Promise((resolve, reject) => {
+            const statement = this.database.prepare(sql, (error: Error | null) => {
+                if (error) {
+                    statement.finalize()
+                    reject(error)
+                    return
+                }
+
+                resolve(statement)
+            })
+        })
+    }
+}
+
+/**
+ * Finalize the given statement once its queued operations have completed.
+ *
+ * @param statement The statement.
+ */
+function finalizeStatement(statement: Statement): Promise<void> {
+    return new Promise(resolve => statement.finalize(() => resolve()))
+}
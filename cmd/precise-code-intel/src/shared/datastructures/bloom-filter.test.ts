@@ -1,4 +1,5 @@
 import { createFilter, testFilter } from './bloom-filter'
+import { range } from 'lodash'
 
 describe('testFilter', () => {
     it('should test set membership', async () => {
@@ -9,4 +10,28 @@ describe('testFilter', () => {
         expect(await testFilter(filter, 'bonk')).toBeFalsy()
         expect(await testFilter(filter, 'quux')).toBeFalsy()
     })
+
+    it('should not return false negatives', async () => {
+        const identifiers = range(1000).map(i => `member-${i}`)
+        const filter = await createFilter(identifiers)
+
+        for (const identifier of identifiers) {
+            expect(await testFilter(filter, identifier)).toBeTruthy()
+        }
+    })
+
+    it('should rarely return false positives', async () => {
+        const filter = await createFilter(range(1000).map(i => `member-${i}`))
+
+        let falsePositives = 0
+        for (const i of range(10000)) {
+            if (await testFilter(filter, `non-member-${i}`)) {
+                falsePositives++
+            }
+        }
+
+        // The default filter parameters give a false positive rate far below
+        // one in ten thousand for a set of this size.
+        expect(falsePositives).toBeLessThanOrEqual(1)
+    })
 })
@@ -24,4 +24,19 @@ describe('OrderedSet', () => {
 
         expect(set.values).toEqual(['bonk', 'baz', 'foo', 'bar'])
     })
+
+    it('should retain the first value for a key', () => {
+        const set = new OrderedSet<{ key: string; value: number }>(({ key }) => key)
+        expect(set.push({ key: 'foo', value: 1 })).toBe(true)
+        expect(set.push({ key: 'bar', value: 2 })).toBe(true)
+        expect(set.push({ key: 'foo', value: 3 })).toBe(false)
+
+        expect(set.size).toEqual(2)
+        expect(set.has({ key: 'foo', value: 4 })).toBe(true)
+        expect(set.has({ key: 'baz', value: 1 })).toBe(false)
+        expect(set.values).toEqual([
+            { key: 'foo', value: 1 },
+            { key: 'bar', value: 2 },
+        ])
+    })
 })
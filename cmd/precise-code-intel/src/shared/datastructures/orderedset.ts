@@ -23,8 +23,27 @@ export class OrderedSet<T> {
         return Array.from(this.set.values())
     }
 
-    /** Insert a value into the set if it hasn't been seen before. */
-    public push(value: T): void {
-        this.set.set(this.makeKey(value), value)
+    /** The number of distinct values in the set. */
+    public get size(): number {
+        return this.set.size
+    }
+
+    /** Determine if a value with the same key as the given value is in the set. */
+    public has(value: T): boolean {
+        return this.set.has(this.makeKey(value))
+    }
+
+    /**
+     * Insert a value into the set if it hasn't been seen before. The first value inserted
+     * for a key is retained. Returns true if the value was inserted.
+     */
+    public push(value: T): boolean {
+        const key = this.makeKey(value)
+        if (this.set.has(key)) {
+            return false
+        }
+
+        this.set.set(key, value)
+        return true
     }
 }
@@ -0,0 +1,26 @@
+import { RingBuffer } from './ring-buffer'
+
+describe('RingBuffer', () => {
+    it('should retain values in insertion order', () => {
+        const buffer = new RingBuffer<number>(5)
+        buffer.push(1)
+        buffer.push(2)
+        buffer.push(3)
+        expect(buffer.values).toEqual([1, 2, 3])
+    })
+
+    it('should evict the oldest values once full', () => {
+        const buffer = new RingBuffer<number>(3)
+        for (let i = 1; i <= 7; i++) {
+            buffer.push(i)
+        }
+
+        expect(buffer.values).toEqual([5, 6, 7])
+    })
+
+    it('should retain nothing with zero capacity', () => {
+        const buffer = new RingBuffer<number>(0)
+        buffer.push(1)
+        expect(buffer.values).toEqual([])
+    })
+})
@@ -0,0 +1,32 @@
+/** A fixed-capacity buffer that overwrites its oldest values once full. */
+export class RingBuffer<T> {
+    private buffer: T[] = []
+    private next = 0
+
+    /**
+     * Create a new ring buffer.
+     *
+     * @param capacity The maximum number of values retained by the buffer.
+     */
+    constructor(private capacity: number) {}
+
+    /** The retained values in insertion order. */
+    public get values(): T[] {
+        return this.buffer.slice(this.next).concat(this.buffer.slice(0, this.next))
+    }
+
+    /** Insert a value into the buffer, evicting the oldest value if the buffer is full. */
+    public push(value: T): void {
+        if (this.capacity <= 0) {
+            return
+        }
+
+        if (this.buffer.length < this.capacity) {
+            this.buffer.push(value)
+            return
+        }
+
+        this.buffer[this.next] = value
+        this.next = (this.next + 1) % this.capacity
+    }
+}
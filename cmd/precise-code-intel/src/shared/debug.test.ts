@@ -0,0 +1,41 @@
+import { QueryDebugLog } from './debug'
+import { logAndTraceCall } from './tracing'
+
+describe('QueryDebugLog', () => {
+    it('should merge consecutive results from the same phase and dump', () => {
+        const debug = new QueryDebugLog()
+        debug.recordResults('same-dump', [1, 1, 1])
+        debug.recordResults('definition-monikers', [2, 2])
+        debug.recordResults('remote-repo', [3, 4, 4])
+
+        expect(debug.toJSON().sources).toEqual([
+            { phase: 'same-dump', dumpId: 1, count: 3 },
+            { phase: 'definition-monikers', dumpId: 2, count: 2 },
+            { phase: 'remote-repo', dumpId: 3, count: 1 },
+            { phase: 'remote-repo', dumpId: 4, count: 2 },
+        ])
+    })
+
+    it('should keep the first recorded dump', () => {
+        const debug = new QueryDebugLog()
+        debug.recordDump({ id: 1, repositoryId: 50, commit: 'a', root: '', indexer: 'lsif-go' })
+        debug.recordDump({ id: 2, repositoryId: 51, commit: 'b', root: 'sub/' })
+
+        expect(debug.toJSON().dump).toEqual({ id: 1, repositoryId: 50, commit: 'a', root: '', indexer: 'lsif-go' })
+    })
+
+    it('should sum bloom filter counts', () => {
+        const debug = new QueryDebugLog()
+        debug.recordBloomFilter(10, 7)
+        debug.recordBloomFilter(5, 1)
+
+        expect(debug.toJSON().bloomFilter).toEqual({ scanned: 15, filtered: 8 })
+    })
+
+    it('should record timings of traced calls', async () => {
+        const debug = new QueryDebugLog()
+        await logAndTraceCall({ debug }, 'outer', ctx => logAndTraceCall(ctx, 'inner', () => 42))
+
+        expect(debug.toJSON().timings.map(({ step }) => step)).toEqual(['inner', 'outer'])
+    })
+})
@@ -0,0 +1,136 @@
+/** The dump chosen to answer a query. */
+export interface DebugDump {
+    id: number
+    repositoryId: number
+    commit: string
+    root: string
+    indexer?: string
+}
+
+/** A run of consecutive results produced by the same query phase and dump. */
+export interface DebugResultSource {
+    /** The phase of the query (e.g. 'same-dump' or 'remote-repo'). */
+    phase: string
+
+    /** The identifier of the dump that produced the results. */
+    dumpId: number
+
+    /** The number of results in this run. */
+    count: number
+}
+
+/** The duration of a single (possibly nested) step of a query. */
+export interface DebugTiming {
+    step: string
+    durationMs: number
+}
+
+/** The explanation of a query returned to clients that request debug output. */
+export interface QueryDebugInfo {
+    /** The dump chosen to answer the query. */
+    dump?: DebugDump
+
+    /** Whether or not the result cache was bypassed. */
+    cacheBypassed: boolean
+
+    /** The phase and dump that produced each result, in result order. */
+    sources: DebugResultSource[]
+
+    /** The number of remote dumps considered and removed by bloom filters. */
+    bloomFilter: { scanned: number; filtered: number }
+
+    /** The duration of each step, in the order in which the steps completed. */
+    timings: DebugTiming[]
+}
+
+/**
+ * Collects an explanation of how a single query produced its results. An instance is
+ * attached to the tracing context of a request when the client asks for debug output,
+ * and every operation that receives that context records what it did into it.
+ */
+export class QueryDebugLog {
+    private dump?: DebugDump
+    private cacheBypassed = false
+    private sources: DebugResultSource[] = []
+    private bloomFilter = { scanned: 0, filtered: 0 }
+    private timings: DebugTiming[] = []
+
+    /**
+     * Record the dump chosen to answer the query. Only the first dump is kept, as later
+     * dumps are opened on behalf of remote lookups.
+     *
+     * @param dump The dump.
+     */
+    public recordDump({
+        id,
+        repositoryId,
+        commit,
+        root,
+        indexer,
+    }: {
+        id: number
+        repositoryId: number
+        commit: string
+        root: string
+        indexer?: string
+    }): void {
+        if (!this.dump) {
+            this.dump = { id, repositoryId, commit, root, indexer }
+        }
+    }
+
+    /** Record that the result cache was not consulted. */
+    public recordCacheBypass(): void {
+        this.cacheBypassed = true
+    }
+
+    /**
+     * Record that the given phase produced results from the given dumps. Consecutive
+     * results from the same phase and dump are merged into a single source.
+     *
+     * @param phase The phase of the query.
+     * @param dumpIds The identifier of the dump of each result, in result order.
+     */
+    public recordResults(phase: string, dumpIds: number[]): void {
+        for (const dumpId of dumpIds) {
+            const last = this.sources[this.sources.length - 1]
+            if (last && last.phase === phase && last.dumpId === dumpId) {
+                last.count++
+            } else {
+                this.sources.push({ phase, dumpId, count: 1 })
+            }
+        }
+    }
+
+    /**
+     * Record the outcome of testing the bloom filters of a set of remote dumps.
+     *
+     * @param scanned The number of dumps whose bloom filter was tested.
+     * @param filtered The number of dumps removed because the identifier is not in their filter.
+     */
+    public recordBloomFilter(scanned: number, filtered: number): void {
+        this.bloomFilter.scanned += scanned
+        this.bloomFilter.filtered += filtered
+    }
+
+    /**
+     * Record the duration of a step.
+     *
+     * @param step The name of the step.
+     * @param durationMs The duration of the step in milliseconds.
+     */
+    public recordTiming(step: string, durationMs: number): void {
+        this.timings.push({ step, durationMs })
+    }
+
+    /** Return the collected explanation. */
+    public toJSON(): QueryDebugInfo {
+        return {
+            dump: this.dump,
+            cacheBypassed: this.cacheBypassed,
+            sources: this.sources.map(source => ({ ...source })),
+            bloomFilter: { ...this.bloomFilter },
+            timings: [...this.timings],
+        }
+    }
+}
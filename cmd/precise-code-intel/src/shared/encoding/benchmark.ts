@@ -0,0 +1,115 @@
+import * as lsif from 'lsif-protocol'
+import { decodePayload, encodePayload } from './payload'
+import { DocumentData } from '../models/sqlite'
+import { gunzip } from 'mz/zlib'
+import { parseJSON } from './json'
+
+/**
+ * Benchmarks the decoding of document payloads in each payload encoding, and the decoding
+ * of gzip-json payloads with `decodeJSON` against `JSON.parse`. Run with
+ * `yarn bench:encoding [numRanges]`. The script is run with a semi-space large enough
+ * to decode a single document without a scavenge, so that the heap growth over one
+ * decode approximates the number of bytes it allocates.
+ */
+async function main(): Promise<void> {
+    const numRanges = parseInt(process.argv[2] || '', 10) || 100000
+    const document = createDocument(numRanges)
+
+    const json = await encodePayload(document, 'gzip-json')
+    const binary = await encodePayload(document, 'gzip-binary')
+
+    console.log(`Decoding a document with ${numRanges} ranges`)
+    await benchmark('gzip-json', json, () => decodePayload(json, 'gzip-json'))
+    await benchmark('JSON.parse', json, async () => parseJSON((await gunzip(json)).toString()))
+    await benchmark('gzip-binary', binary, () => decodePayload(binary, 'gzip-binary'))
+}
+
+/**
+ * Decode a payload repeatedly and print the size of the payload, the mean decode time,
+ * and the mean number of bytes allocated by each decode.
+ *
+ * @param name The name of the decoder.
+ * @param payload The encoded document.
+ * @param decode A function that decodes the payload.
+ */
+async function benchmark(name: string, payload: Buffer, decode: () => Promise<unknown>): Promise<void> {
+    const iterations = 10
+
+    // Warm up the decoder so that the optimized code is measured
+    await decode()
+
+    let elapsed = 0
+    let allocated = 0
+    for (let i = 0; i < iterations; i++) {
+        collectGarbage()
+        const heapUsed = process.memoryUsage().heapUsed
+        const start = process.hrtime.bigint()
+        await decode()
+        elapsed += Number(process.hrtime.bigint() - start) / 1e6
+        allocated += process.memoryUsage().heapUsed - heapUsed
+    }
+
+    console.log(
+        [
+            name.padEnd(12),
+            `${payload.length} B/payload`.padStart(18),
+            `${(elapsed / iterations).toFixed(2)} ms/op`.padStart(14),
+            `${Math.round(allocated / iterations)} B/op`.padStart(16),
+        ].join('')
+    )
+}
+
+/**
+ * Create a document shaped like those of a typical bundle: each range has a definition
+ * and reference result and a moniker, and every fourth range has a hover result.
+ *
+ * @param numRanges The number of ranges in the document.
+ */
+function createDocument(numRanges: number): DocumentData {
+    const document: DocumentData = {
+        ranges: new Map(),
+        hoverResults: new Map(),
+        monikers: new Map(),
+        packageInformation: new Map([[1, { name: 'github.com/sourcegraph/lsif-go', version: 'v0.9.0' }]]),
+    }
+
+    for (let i = 0; i < numRanges; i++) {
+        const id = 10 + i * 4
+        const hoverResultId = i % 4 === 0 ? id + 3 : undefined
+
+        document.ranges.set(id, {
+            startLine: i,
+            startCharacter: 4,
+            endLine: i,
+            endCharacter: 16,
+            definitionResultId: id + 1,
+            referenceResultId: id + 2,
+            hoverResultId,
+            monikerIds: new Set([id + 3]),
+        })
+        document.monikers.set(id + 3, {
+            kind: lsif.MonikerKind.export,
+            scheme: 'gomod',
+            identifier: `github.com/sourcegraph/lsif-go/protocol:Symbol${i}`,
+            packageInformationId: 1,
+        })
+        if (hoverResultId !== undefined) {
+            document.hoverResults.set(hoverResultId, `\`\`\`go\nfunc Symbol${i}() error\n\`\`\``)
+        }
+    }
+
+    return document
+}
+
+/** Run a full garbage collection if node was started with `--expose-gc`. */
+function collectGarbage(): void {
+    const gc = (global as { gc?: () => void }).gc
+    if (gc) {
+        gc()
+    }
+}
+
+main().catch(error => {
+    console.error(error)
+    process.exit(1)
+})
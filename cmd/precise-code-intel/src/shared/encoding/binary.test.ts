@@ -0,0 +1,62 @@
+import { decodeBinary, encodeBinary } from './binary'
+import { dumpJSON, parseJSON } from './json'
+
+describe('encodeBinary', () => {
+    it('should round-trip the values supported by JSON', () => {
+        const values = [
+            null,
+            true,
+            false,
+            0,
+            127,
+            128,
+            -1,
+            -300,
+            Number.MAX_SAFE_INTEGER,
+            Number.MIN_SAFE_INTEGER,
+            1.5,
+            -2.25e100,
+            '',
+            'abc',
+            'ü∂ƒ',
+            [1, 'a', null, [true]],
+            { a: 1, b: { c: 'd' } },
+        ]
+
+        for (const value of values) {
+            expect(decodeBinary(encodeBinary(value))).toEqual(value)
+        }
+    })
+
+    it('should preserve maps and sets', () => {
+        const value = {
+            ranges: new Map<number | string, unknown>([
+                [1, { startLine: 1, monikerIds: new Set([2, 3]) }],
+                ['x', { startLine: 2, monikerIds: new Set() }],
+            ]),
+            names: new Set(['a', 'b']),
+        }
+
+        expect(decodeBinary(encodeBinary(value))).toEqual(value)
+    })
+
+    it('should decode to the same value as JSON', () => {
+        const value = {
+            ranges: new Map([[1, { startLine: 1, hoverResultId: undefined, monikerIds: new Set([2]) }]]),
+            list: [undefined, 1],
+        }
+
+        expect(decodeBinary(encodeBinary(value))).toEqual(parseJSON(dumpJSON(value)))
+    })
+
+    it('should write repeated strings once', () => {
+        const keys = Array.from({ length: 100 }, (_, i) => ({ identifier: 'a'.repeat(50), index: i }))
+        expect(encodeBinary(keys).length).toBeLessThan(dumpJSON(keys).length / 4)
+    })
+
+    it('should reject truncated values and unknown versions', () => {
+        const encoded = encodeBinary({ a: 'abc' })
+        expect(() => decodeBinary(encoded.slice(0, encoded.length - 1))).toThrowError('Truncated binary value')
+        expect(() => decodeBinary(Buffer.from([2, 0]))).toThrowError('Unsupported binary encoding version 2')
+    })
+})
@@ -0,0 +1,333 @@
+/**
+ * The version of the binary encoding, written as the first byte of every encoded value so
+ * that the format can change without misreading values written by a previous version.
+ */
+const BINARY_ENCODING_VERSION = 1
+
+/** The tags that precede each encoded value and identify its type. */
+enum Tag {
+    null = 0,
+    false = 1,
+    true = 2,
+    unsignedInteger = 3,
+    negativeInteger = 4,
+    double = 5,
+    string = 6,
+    stringReference = 7,
+    array = 8,
+    object = 9,
+    map = 10,
+    set = 11,
+}
+
+/**
+ * Return the binary representation of `value`. This encodes the same values as `dumpJSON`,
+ * including ES6 maps and sets, but is considerably cheaper to decode: integers are written
+ * as varints, and each distinct string (including object keys) is written once and then
+ * referred to by its index. As with JSON, object properties with an undefined value are
+ * omitted and undefined array elements are encoded as null.
+ *
+ * @param value The value to encode.
+ */
+export function encodeBinary<T>(value: T): Buffer {
+    const writer = new BinaryWriter()
+    writer.writeByte(BINARY_ENCODING_VERSION)
+    writer.writeValue(value)
+    return writer.finish()
+}
+
+/**
+ * Reverse the operation of `encodeBinary`. Throws if the value is truncated or was written
+ * by an unsupported version of the encoding.
+ *
+ * @param buffer The encoded value.
+ */
+export function decodeBinary<T>(buffer: Buffer): T {
+    if (buffer.length === 0 || buffer[0] !== BINARY_ENCODING_VERSION) {
+        throw new Error(`Unsupported binary encoding version ${buffer.length === 0 ? 'none' : buffer[0]}`)
+    }
+
+    const reader = new BinaryReader(buffer, 1)
+    const value = reader.readValue()
+    if (reader.offset !== buffer.length) {
+        throw new Error('Unexpected trailing bytes in binary value')
+    }
+
+    return value as T
+}
+
+/** A growable buffer into which values are encoded. */
+class BinaryWriter {
+    /** The encoded bytes, of which the first `offset` are used. */
+    private buffer = Buffer.allocUnsafe(1024)
+
+    /** The number of bytes written. */
+    private offset = 0
+
+    /** A map from each string written so far to its index. */
+    private strings = new Map<string, number>()
+
+    /** Return the encoded bytes. */
+    public finish(): Buffer {
+        return this.buffer.slice(0, this.offset)
+    }
+
+    /**
+     * Write a single byte.
+     *
+     * @param value The byte.
+     */
+    public writeByte(value: number): void {
+        this.reserve(1)
+        this.buffer[this.offset++] = value
+    }
+
+    /**
+     * Write the given value along with its tag.
+     *
+     * @param value The value.
+     */
+    public writeValue(value: unknown): void {
+        if (value === null || value === undefined) {
+            this.writeByte(Tag.null)
+        } else if (typeof value === 'boolean') {
+            this.writeByte(value ? Tag.true : Tag.false)
+        } else if (typeof value === 'number') {
+            this.writeNumber(value)
+        } else if (typeof value === 'string') {
+            this.writeString(value)
+        } else if (Array.isArray(value)) {
+            this.writeByte(Tag.array)
+            this.writeVarint(value.length)
+            for (const element of value) {
+                this.writeValue(element)
+            }
+        } else if (value instanceof Map) {
+            this.writeByte(Tag.map)
+            this.writeVarint(value.size)
+            for (const [key, element] of value) {
+                this.writeValue(key)
+                this.writeValue(element)
+            }
+        } else if (value instanceof Set) {
+            this.writeByte(Tag.set)
+            this.writeVarint(value.size)
+            for (const element of value) {
+                this.writeValue(element)
+            }
+        } else if (typeof value === 'object') {
+            const entries = Object.entries(value as object).filter(([, element]) => element !== undefined)
+            this.writeByte(Tag.object)
+            this.writeVarint(entries.length)
+            for (const [key, element] of entries) {
+                this.writeString(key)
+                this.writeValue(element)
+            }
+        } else {
+            throw new Error(`Unsupported value of type ${typeof value}`)
+        }
+    }
+
+    /**
+     * Write a number as a varint if it is a safe integer and as a double otherwise.
+     *
+     * @param value The number.
+     */
+    private writeNumber(value: number): void {
+        if (!Number.isSafeInteger(value) || Object.is(value, -0)) {
+            this.writeByte(Tag.double)
+            this.reserve(8)
+            this.offset = this.buffer.writeDoubleLE(value, this.offset)
+        } else if (value >= 0) {
+            this.writeByte(Tag.unsignedInteger)
+            this.writeVarint(value)
+        } else {
+            this.writeByte(Tag.negativeInteger)
+            this.writeVarint(-value)
+        }
+    }
+
+    /**
+     * Write a string, or a reference to the same string if it has been written before.
+     *
+     * @param value The string.
+     */
+    private writeString(value: string): void {
+        const index = this.strings.get(value)
+        if (index !== undefined) {
+            this.writeByte(Tag.stringReference)
+            this.writeVarint(index)
+            return
+        }
+
+        this.strings.set(value, this.strings.size)
+
+        const length = Buffer.byteLength(value)
+        this.writeByte(Tag.string)
+        this.writeVarint(length)
+        this.reserve(length)
+        this.offset += this.buffer.write(value, this.offset, length, 'utf8')
+    }
+
+    /**
+     * Write a non-negative safe integer in base 128, least significant group first.
+     *
+     * @param value The integer.
+     */
+    private writeVarint(value: number): void {
+        this.reserve(8)
+        while (value >= 0x80) {
+            this.buffer[this.offset++] = (value % 0x80) | 0x80
+            value = Math.floor(value / 0x80)
+        }
+        this.buffer[this.offset++] = value
+    }
+
+    /**
+     * Ensure that at least `size` more bytes can be written.
+     *
+     * @param size The number of bytes.
+     */
+    private reserve(size: number): void {
+        if (this.offset + size <= this.buffer.length) {
+            return
+        }
+
+        const buffer = Buffer.allocUnsafe(Math.max(this.buffer.length * 2, this.offset + size))
+        this.buffer.copy(buffer, 0, 0, this.offset)
+        this.buffer = buffer
+    }
+}
+
+/** A cursor over an encoded value. */
+class BinaryReader {
+    /** The strings read so far, in order. */
+    private strings: string[] = []
+
+    /**
+     * Create a new `BinaryReader`.
+     *
+     * @param buffer The encoded bytes.
+     * @param offset The offset of the next unread byte.
+     */
+    constructor(private buffer: Buffer, public offset: number) {}
+
+    /** Read a value along with its tag. */
+    public readValue(): unknown {
+        const tag = this.readByte()
+
+        switch (tag) {
+            case Tag.null:
+                return null
+            case Tag.false:
+                return false
+            case Tag.true:
+                return true
+            case Tag.unsignedInteger:
+                return this.readVarint()
+            case Tag.negativeInteger:
+                return -this.readVarint()
+            case Tag.double: {
+                this.expect(8)
+                const value = this.buffer.readDoubleLE(this.offset)
+                this.offset += 8
+                return value
+            }
+            case Tag.string:
+            case Tag.stringReference:
+                return this.readString(tag)
+            case Tag.array: {
+                const length = this.readVarint()
+                const value = new Array(length)
+                for (let i = 0; i < length; i++) {
+                    value[i] = this.readValue()
+                }
+                return value
+            }
+            case Tag.object: {
+                const size = this.readVarint()
+                const value: { [key: string]: unknown } = {}
+                for (let i = 0; i < size; i++) {
+                    const key = this.readString(this.readByte())
+                    value[key] = this.readValue()
+                }
+                return value
+            }
+            case Tag.map: {
+                const size = this.readVarint()
+                const value = new Map<unknown, unknown>()
+                for (let i = 0; i < size; i++) {
+                    const key = this.readValue()
+                    value.set(key, this.readValue())
+                }
+                return value
+            }
+            case Tag.set: {
+                const size = this.readVarint()
+                const value = new Set<unknown>()
+                for (let i = 0; i < size; i++) {
+                    value.add(this.readValue())
+                }
+                return value
+            }
+        }
+
+        throw new Error(`Unknown binary value tag ${tag} at offset ${this.offset - 1}`)
+    }
+
+    /**
+     * Read a string or a reference to a string read before.
+     *
+     * @param tag The tag preceding the string.
+     */
+    private readString(tag: number): string {
+        if (tag === Tag.stringReference) {
+            const index = this.readVarint()
+            if (index >= this.strings.length) {
+                throw new Error(`Unknown binary string reference ${index}`)
+            }
+
+            return this.strings[index]
+        }
+
+        if (tag !== Tag.string) {
+            throw new Error(`Expected a binary string at offset ${this.offset - 1}`)
+        }
+
+        const length = this.readVarint()
+        this.expect(length)
+        const value = this.buffer.toString('utf8', this.offset, this.offset + length)
+        this.offset += length
+        this.strings.push(value)
+        return value
+    }
+
+    /** Read a non-negative integer written by `BinaryWriter.writeVarint`. */
+    private readVarint(): number {
+        let value = 0
+        for (let multiplier = 1; ; multiplier *= 0x80) {
+            const byte = this.readByte()
+            value += (byte & 0x7f) * multiplier
+            if ((byte & 0x80) === 0) {
+                return value
+            }
+        }
+    }
+
+    /** Read a single byte. */
+    private readByte(): number {
+        this.expect(1)
+        return this.buffer[this.offset++]
+    }
+
+    /**
+     * Throw if fewer than `size` bytes remain.
+     *
+     * @param size The number of bytes.
+     */
+    private expect(size: number): void {
+        if (this.offset + size > this.buffer.length) {
+            throw new Error('Truncated binary value')
+        }
+    }
+}
@@ -0,0 +1,62 @@
+import { decodeJSON } from './decoder'
+import { dumpJSON, parseJSON } from './json'
+
+describe('decodeJSON', () => {
+    const decode = (value: string): unknown => decodeJSON(Buffer.from(value))
+
+    it('should decode the same values as parseJSON', () => {
+        const values = [
+            0,
+            -12,
+            '',
+            [],
+            {},
+            {
+                numbers: [1.5, -2, 3e21, -0.25, 12345678901234567890],
+                strings: ['héllo', '"quoted"', 'back\\slash', 'new\nline', '😀'],
+                literals: [null, true, false],
+            },
+            new Map<unknown, unknown>([
+                [1, { monikerIds: new Set(['a', 'b']) }],
+                ['k', new Map([[2, 3]])],
+            ]),
+            { type: 'other', value: [1] },
+        ]
+
+        for (const value of values) {
+            for (const encoded of [dumpJSON(value), JSON.stringify(JSON.parse(dumpJSON(value)), undefined, 2)]) {
+                expect(decode(encoded)).toEqual(parseJSON(encoded))
+            }
+        }
+    })
+
+    it('should decode escapes', () => {
+        expect(decode('"\\ud83d\\ude00\\/\\t\\u00e9"')).toEqual('😀/\té')
+    })
+
+    it('should decode maps and sets with reordered properties', () => {
+        expect(decode('{"value":[[1,2]],"type":"map"}')).toEqual(new Map([[1, 2]]))
+        expect(decode('{"type":"set","extra":1,"value":[1,2]}')).toEqual(new Set([1, 2]))
+    })
+
+    it('should reject malformed input', () => {
+        const values = ['', '{', '[1,]', '{"a" 1}', 'tru', '1 2', '"abc', '-', '"\\x"', '{"type":"map","value":[1]}']
+        for (const value of values) {
+            expect(() => decode(value)).toThrowError()
+        }
+    })
+
+    it('should reject the invalid JSON rejected by JSON.parse', () => {
+        const values = ['01', '-01', '00', '1.', '.5', '1.e5', '1e', '1e+', '+1', '0x10', '"tab\there"', '[1,2,]']
+        for (const value of values) {
+            expect(() => JSON.parse(value)).toThrowError()
+            expect(() => decode(value)).toThrowError()
+        }
+    })
+
+    it('should accept every number form allowed by JSON', () => {
+        for (const value of ['0', '-0', '0.5', '-0.5', '10', '1e5', '1E+5', '1e-5', '0e0', '123456789012345678']) {
+            expect(decode(value)).toEqual(JSON.parse(value))
+        }
+    })
+})
@@ -0,0 +1,465 @@
+/**
+ * Parse the UTF-8 encoded JSON representation of `value` as written by `dumpJSON`. This
+ * produces the same values as `parseJSON`, but reads the bytes directly instead of first
+ * converting them to a string, and adds the entries of encoded maps and sets to the map
+ * or set as they are read instead of materializing an array of key-value pairs and then
+ * copying it. For documents with hundreds of thousands of ranges, this avoids holding
+ * two or three copies of the payload in memory at once.
+ *
+ * @param buffer The encoded value.
+ */
+export function decodeJSON<T>(buffer: Buffer): T {
+    const decoder = new JSONDecoder(buffer)
+    decoder.skipWhitespace()
+    const value = decoder.readValue()
+    decoder.skipWhitespace()
+    if (decoder.offset !== buffer.length) {
+        throw decoder.error('Unexpected trailing bytes')
+    }
+
+    return value as T
+}
+
+/** Character codes of the JSON syntax. */
+const enum Char {
+    tab = 0x09,
+    newline = 0x0a,
+    carriageReturn = 0x0d,
+    space = 0x20,
+    quote = 0x22,
+    plus = 0x2b,
+    comma = 0x2c,
+    minus = 0x2d,
+    period = 0x2e,
+    zero = 0x30,
+    nine = 0x39,
+    colon = 0x3a,
+    upperE = 0x45,
+    openBracket = 0x5b,
+    backslash = 0x5c,
+    closeBracket = 0x5d,
+    lowerE = 0x65,
+    lowerF = 0x66,
+    lowerN = 0x6e,
+    lowerT = 0x74,
+    openBrace = 0x7b,
+    closeBrace = 0x7d,
+}
+
+/** The values of the single-character escape sequences in JSON strings. */
+const ESCAPES: { [char: string]: string } = {
+    '"': '"',
+    '\\': '\\',
+    '/': '/',
+    b: '\b',
+    f: '\f',
+    n: '\n',
+    r: '\r',
+    t: '\t',
+}
+
+/**
+ * The maximum number of digits of an integer that can be accumulated without loss of
+ * precision. Longer numbers are parsed by `Number`.
+ */
+const MAX_FAST_INTEGER_DIGITS = 15
+
+/** A cursor over an encoded JSON value. */
+class JSONDecoder {
+    /**
+     * Create a new `JSONDecoder`.
+     *
+     * @param buffer The encoded bytes.
+     * @param offset The offset of the next unread byte.
+     */
+    constructor(private buffer: Buffer, public offset: number = 0) {}
+
+    /** Read a value starting at the current offset. */
+    public readValue(): unknown {
+        switch (this.peek()) {
+            case Char.openBrace:
+                return this.readObject()
+            case Char.openBracket:
+                return this.readArray(undefined)
+            case Char.quote:
+                return this.readString()
+            case Char.lowerT:
+                return this.readLiteral('true', true)
+            case Char.lowerF:
+                return this.readLiteral('false', false)
+            case Char.lowerN:
+                return this.readLiteral('null', null)
+        }
+
+        return this.readNumber()
+    }
+
+    /** Skip any whitespace at the current offset. */
+    public skipWhitespace(): void {
+        while (this.offset < this.buffer.length) {
+            const char = this.buffer[this.offset]
+            if (char !== Char.space && char !== Char.newline && char !== Char.carriageReturn && char !== Char.tab) {
+                return
+            }
+
+            this.offset++
+        }
+    }
+
+    /**
+     * Create an error that refers to the current offset.
+     *
+     * @param message The error message.
+     */
+    public error(message: string): Error {
+        return new Error(`${message} in JSON at position ${this.offset}`)
+    }
+
+    /**
+     * Read an object. An object whose first property is a `type` of `map` or `set` followed
+     * by a `value` array is read directly into a map or set. Any other object that has a
+     * `type` of `map` or `set` is converted after it is read, as done by `parseJSON`.
+     */
+    private readObject(): unknown {
+        this.offset++
+        this.skipWhitespace()
+        if (this.peek() === Char.closeBrace) {
+            this.offset++
+            return {}
+        }
+
+        const value: { [key: string]: unknown } = {}
+        let collection: Map<unknown, unknown> | Set<unknown> | undefined
+        for (let first = true; ; first = false) {
+            const key = this.readKey()
+            if (first && key === 'type' && this.peek() === Char.quote) {
+                const type = this.readString()
+                if (type === 'map' || type === 'set') {
+                    collection = this.readCollection(type)
+                }
+
+                setProperty(value, key, type)
+            } else {
+                setProperty(value, key, this.readValue())
+            }
+
+            this.skipWhitespace()
+            if (this.peek() === Char.closeBrace) {
+                this.offset++
+                break
+            }
+
+            this.consume(Char.comma)
+            this.skipWhitespace()
+        }
+
+        if (collection) {
+            return collection
+        }
+
+        if (value.type === 'map') {
+            return new Map(value.value as Iterable<[unknown, unknown]>)
+        }
+        if (value.type === 'set') {
+            return new Set(value.value as Iterable<unknown>)
+        }
+
+        return value
+    }
+
+    /**
+     * Read the `value` property that follows the `type` property of an encoded map or set
+     * directly into a new collection. Returns undefined without consuming any input if the
+     * next property is not a `value` array, in which case the object is read as usual.
+     *
+     * @param type The type of the collection.
+     */
+    private readCollection(type: 'map' | 'set'): Map<unknown, unknown> | Set<unknown> | undefined {
+        const start = this.offset
+        this.skipWhitespace()
+        if (this.peek() !== Char.comma) {
+            this.offset = start
+            return undefined
+        }
+
+        this.offset++
+        this.skipWhitespace()
+        if (this.peek() !== Char.quote || this.readString() !== 'value') {
+            this.offset = start
+            return undefined
+        }
+
+        this.skipWhitespace()
+        this.consume(Char.colon)
+        this.skipWhitespace()
+        if (this.peek() !== Char.openBracket) {
+            this.offset = start
+            return undefined
+        }
+
+        if (type === 'set') {
+            const set = new Set<unknown>()
+            this.readArray(() => set.add(this.readValue()))
+            return set
+        }
+
+        const map = new Map<unknown, unknown>()
+        this.readArray(() => {
+            this.consume(Char.openBracket)
+            this.skipWhitespace()
+            const key = this.readValue()
+            this.skipWhitespace()
+            this.consume(Char.comma)
+            this.skipWhitespace()
+            const element = this.readValue()
+            this.skipWhitespace()
+            this.consume(Char.closeBracket)
+            map.set(key, element)
+        })
+
+        return map
+    }
+
+    /**
+     * Read an array. If a callback is given, it is invoked to read each element in place
+     * of collecting the elements into an array.
+     *
+     * @param readElement The callback that reads the next element.
+     */
+    private readArray(readElement: (() => void) | undefined): unknown[] | undefined {
+        this.offset++
+        this.skipWhitespace()
+
+        const value: unknown[] = []
+        if (this.peek() === Char.closeBracket) {
+            this.offset++
+            return readElement ? undefined : value
+        }
+
+        while (true) {
+            if (readElement) {
+                readElement()
+            } else {
+                value.push(this.readValue())
+            }
+
+            this.skipWhitespace()
+            if (this.peek() === Char.closeBracket) {
+                this.offset++
+                return readElement ? undefined : value
+            }
+
+            this.consume(Char.comma)
+            this.skipWhitespace()
+        }
+    }
+
+    /** Read an object key along with the colon that follows it. */
+    private readKey(): string {
+        if (this.peek() !== Char.quote) {
+            throw this.error('Expected a property name')
+        }
+
+        const key = this.readString()
+        this.skipWhitespace()
+        this.consume(Char.colon)
+        this.skipWhitespace()
+        return key
+    }
+
+    /** Read a string. Runs of characters without escapes are decoded from the bytes directly. */
+    private readString(): string {
+        this.offset++
+
+        let value = ''
+        let start = this.offset
+        while (true) {
+            if (this.offset >= this.buffer.length) {
+                throw this.error('Unterminated string')
+            }
+
+            const char = this.buffer[this.offset]
+            if (char === Char.quote) {
+                value += this.buffer.toString('utf8', start, this.offset)
+                this.offset++
+                return value
+            }
+
+            if (char < Char.space) {
+                throw this.error('Bad control character in string literal')
+            }
+
+            if (char !== Char.backslash) {
+                this.offset++
+                continue
+            }
+
+            value += this.buffer.toString('utf8', start, this.offset)
+            this.offset++
+            value += this.readEscape()
+            start = this.offset
+        }
+    }
+
+    /** Read the escape sequence that follows a backslash in a string. */
+    private readEscape(): string {
+        if (this.offset >= this.buffer.length) {
+            throw this.error('Unterminated string')
+        }
+
+        const char = String.fromCharCode(this.buffer[this.offset++])
+        if (char !== 'u') {
+            const value = ESCAPES[char]
+            if (value === undefined) {
+                throw this.error(`Bad escaped character ${char}`)
+            }
+
+            return value
+        }
+
+        const hex = this.buffer.toString('latin1', this.offset, this.offset + 4)
+        if (!/^[0-9a-fA-F]{4}$/.test(hex)) {
+            throw this.error('Bad unicode escape')
+        }
+
+        this.offset += 4
+        // Surrogate pairs are written as two escapes whose code units are concatenated
+        return String.fromCharCode(parseInt(hex, 16))
+    }
+
+    /**
+     * Read a number. Integers short enough to be represented exactly are accumulated
+     * digit by digit without creating an intermediate string. Numbers that do not match
+     * the JSON grammar, such as those with leading zeros, are rejected as by `JSON.parse`.
+     */
+    private readNumber(): number {
+        const start = this.offset
+        const negative = this.peek() === Char.minus
+        if (negative) {
+            this.offset++
+        }
+
+        const first = this.offset
+        let integer = 0
+        while (this.offset < this.buffer.length) {
+            const char = this.buffer[this.offset]
+            if (char < Char.zero || char > Char.nine) {
+                break
+            }
+
+            integer = integer * 10 + (char - Char.zero)
+            this.offset++
+        }
+
+        const digits = this.offset - first
+        if (digits === 0) {
+            this.offset = start
+            throw this.error('Unexpected token')
+        }
+        if (digits > 1 && this.buffer[first] === Char.zero) {
+            this.offset = start
+            throw this.error('Malformed number')
+        }
+
+        let isInteger = true
+        if (this.offset < this.buffer.length && this.buffer[this.offset] === Char.period) {
+            this.offset++
+            this.readDigits(start)
+            isInteger = false
+        }
+
+        const next = this.offset < this.buffer.length ? this.buffer[this.offset] : undefined
+        if (next === Char.lowerE || next === Char.upperE) {
+            this.offset++
+            const sign = this.offset < this.buffer.length ? this.buffer[this.offset] : undefined
+            if (sign === Char.plus || sign === Char.minus) {
+                this.offset++
+            }
+
+            this.readDigits(start)
+            isInteger = false
+        }
+
+        if (isInteger && digits <= MAX_FAST_INTEGER_DIGITS) {
+            return negative ? -integer : integer
+        }
+
+        return Number(this.buffer.toString('latin1', start, this.offset))
+    }
+
+    /**
+     * Read the digits of a fraction or an exponent. Throws if there are none.
+     *
+     * @param start The offset of the number being read.
+     */
+    private readDigits(start: number): void {
+        const first = this.offset
+        while (this.offset < this.buffer.length) {
+            const char = this.buffer[this.offset]
+            if (char < Char.zero || char > Char.nine) {
+                break
+            }
+
+            this.offset++
+        }
+
+        if (this.offset === first) {
+            this.offset = start
+            throw this.error('Malformed number')
+        }
+    }
+
+    /**
+     * Read the given literal.
+     *
+     * @param text The text of the literal.
+     * @param value The value of the literal.
+     */
+    private readLiteral<T>(text: string, value: T): T {
+        if (this.buffer.toString('latin1', this.offset, this.offset + text.length) !== text) {
+            throw this.error('Unexpected token')
+        }
+
+        this.offset += text.length
+        return value
+    }
+
+    /**
+     * Read the given character. Throws if the next character differs.
+     *
+     * @param char The expected character.
+     */
+    private consume(char: Char): void {
+        if (this.peek() !== char) {
+            throw this.error(`Expected ${String.fromCharCode(char)}`)
+        }
+
+        this.offset++
+    }
+
+    /** Return the next character without consuming it. Throws at the end of the input. */
+    private peek(): number {
+        if (this.offset >= this.buffer.length) {
+            throw this.error('Unexpected end of input')
+        }
+
+        return this.buffer[this.offset]
+    }
+}
+
+/**
+ * Set a property of a decoded object. A `__proto__` key is defined as an own property,
+ * as done by `JSON.parse`, instead of replacing the prototype of the object.
+ *
+ * @param object The object.
+ * @param key The property name.
+ * @param value The property value.
+ */
+function setProperty(object: { [key: string]: unknown }, key: string, value: unknown): void {
+    if (key === '__proto__') {
+        Object.defineProperty(object, key, { value, writable: true, enumerable: true, configurable: true })
+        return
+    }
+
+    object[key] = value
+}
@@ -0,0 +1,35 @@
+import { decodeHover, decodeHovers, encodeHover, encodeHovers } from './hover'
+
+describe('hover envelopes', () => {
+    const hover = {
+        text: 'foo',
+        range: { start: { line: 1, character: 2 }, end: { line: 1, character: 5 } },
+    }
+
+    it('should round trip hover results', () => {
+        for (const value of [hover, null]) {
+            expect(decodeHover(JSON.parse(JSON.stringify(encodeHover(value))))).toEqual(value)
+        }
+
+        const values = [hover, null, hover]
+        expect(decodeHovers(JSON.parse(JSON.stringify(encodeHovers(values))))).toEqual(values)
+    })
+
+    it('should preserve empty hover text', () => {
+        expect(decodeHover({ hover: { ...hover, text: '' } })).toEqual({ ...hover, text: '' })
+    })
+
+    it('should accept legacy payloads', () => {
+        expect(decodeHover(hover)).toEqual(hover)
+        expect(decodeHover(null)).toBeNull()
+        expect(decodeHovers([null, hover])).toEqual([null, hover])
+    })
+
+    it('should reject malformed payloads', () => {
+        expect(() => decodeHover(undefined)).toThrow()
+        expect(() => decodeHover({ hover: { text: 'foo' } })).toThrow()
+        expect(() => decodeHover({ hover: { text: 1, range: hover.range } })).toThrow()
+        expect(() => decodeHovers({ hovers: null })).toThrow()
+        expect(() => decodeHovers([{}])).toThrow()
+    })
+})
@@ -0,0 +1,101 @@
+import * as lsp from 'vscode-languageserver-protocol'
+
+/** The hover text of a range in a document. */
+export interface HoverData {
+    /** The hover text. */
+    text: string
+
+    /** The range that the hover text describes. */
+    range: lsp.Range
+}
+
+/**
+ * The JSON envelope of a single hover result. Wrapping the result distinguishes a position
+ * without hover text (`{"hover": null}`) from a missing or malformed response.
+ */
+export interface HoverEnvelope {
+    hover: HoverData | null
+}
+
+/** The JSON envelope of a list of hover results aligned with the requested positions. */
+export interface HoversEnvelope {
+    hovers: (HoverData | null)[]
+}
+
+/**
+ * Wrap a hover result in its envelope.
+ *
+ * @param hover The hover result.
+ */
+export function encodeHover(hover: HoverData | null): HoverEnvelope {
+    return { hover }
+}
+
+/**
+ * Wrap a list of hover results in its envelope.
+ *
+ * @param hovers The hover results.
+ */
+export function encodeHovers(hovers: (HoverData | null)[]): HoversEnvelope {
+    return { hovers }
+}
+
+/**
+ * Unwrap a hover result from its envelope. A bare hover result or null, as returned by
+ * bundle managers that predate envelopes, is also accepted. Throws an error if the value
+ * is not a well-formed hover result.
+ *
+ * @param value The decoded JSON payload.
+ */
+export function decodeHover(value: unknown): HoverData | null {
+    if (isObject(value) && 'hover' in value) {
+        return validateHover(value.hover)
+    }
+
+    return validateHover(value)
+}
+
+/**
+ * Unwrap a list of hover results from its envelope. A bare list, as returned by bundle
+ * managers that predate envelopes, is also accepted. Throws an error if the value is not
+ * a well-formed list of hover results.
+ *
+ * @param value The decoded JSON payload.
+ */
+export function decodeHovers(value: unknown): (HoverData | null)[] {
+    const hovers = isObject(value) && 'hovers' in value ? value.hovers : value
+    if (!Array.isArray(hovers)) {
+        throw new Error('Malformed hovers response: expected a list')
+    }
+
+    return hovers.map(validateHover)
+}
+
+/**
+ * Return the given value if it is null or a well-formed hover result. Throws an error otherwise.
+ *
+ * @param value The candidate hover result.
+ */
+function validateHover(value: unknown): HoverData | null {
+    if (value === null) {
+        return null
+    }
+
+    if (!isObject(value) || typeof value.text !== 'string' || !isRange(value.range)) {
+        throw new Error('Malformed hover response: expected null or an object with text and range')
+    }
+
+    return { text: value.text, range: value.range }
+}
+
+function isObject(value: unknown): value is { [key: string]: unknown } {
+    return typeof value === 'object' && value !== null && !Array.isArray(value)
+}
+
+function isRange(value: unknown): value is lsp.Range {
+    return isObject(value) && isPosition(value.start) && isPosition(value.end)
+}
+
+function isPosition(value: unknown): value is lsp.Position {
+    return isObject(value) && typeof value.line === 'number' && typeof value.character === 'number'
+}
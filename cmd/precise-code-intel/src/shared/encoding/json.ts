@@ -1,4 +1,5 @@
 import { gunzip, gzip } from 'mz/zlib'
+import { decodeJSON } from './decoder'
 
 /**
  * Return the gzipped JSON representation of `value`.
@@ -15,7 +16,7 @@ export function gzipJSON<T>(value: T): Promise<Buffer> {
  * @param value The value to decode.
  */
 export async function gunzipJSON<T>(value: Buffer): Promise<T> {
-    return parseJSON((await gunzip(value)).toString())
+    return decodeJSON(await gunzip(value))
 }
 
 /** The replacer used by dumpJSON to encode map and set values. */
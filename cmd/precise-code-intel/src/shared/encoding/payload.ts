@@ -0,0 +1,59 @@
+import { gunzip, gzip } from 'mz/zlib'
+import { decodeBinary, encodeBinary } from './binary'
+import { gunzipJSON, gzipJSON } from './json'
+
+/**
+ * The encodings of the document and result chunk payloads of a bundle. Bundles written
+ * before the encoding was recorded in their meta row use `gzip-json`.
+ */
+export type PayloadEncoding = 'gzip-json' | 'gzip-binary'
+
+/** The supported payload encodings. */
+export const PAYLOAD_ENCODINGS: PayloadEncoding[] = ['gzip-json', 'gzip-binary']
+
+/** The encoding of payloads of bundles that do not record one. */
+export const DEFAULT_PAYLOAD_ENCODING: PayloadEncoding = 'gzip-json'
+
+/**
+ * Return the encoded representation of `value` in the given encoding.
+ *
+ * @param value The value to encode.
+ * @param encoding The payload encoding.
+ */
+export async function encodePayload<T>(value: T, encoding: PayloadEncoding): Promise<Buffer> {
+    switch (encoding) {
+        case 'gzip-json':
+            return gzipJSON(value)
+        case 'gzip-binary':
+            return gzip(encodeBinary(value))
+    }
+}
+
+/**
+ * Reverse the operation of `encodePayload`.
+ *
+ * @param value The value to decode.
+ * @param encoding The payload encoding.
+ */
+export async function decodePayload<T>(value: Buffer, encoding: PayloadEncoding): Promise<T> {
+    switch (encoding) {
+        case 'gzip-json':
+            return gunzipJSON<T>(value)
+        case 'gzip-binary':
+            return decodeBinary<T>(await gunzip(value))
+    }
+}
+
+/**
+ * Return the given value if it is a supported payload encoding. Throws otherwise.
+ *
+ * @param value The name of the encoding.
+ */
+export function parsePayloadEncoding(value: string): PayloadEncoding {
+    const encoding = PAYLOAD_ENCODINGS.find(encoding => encoding === value)
+    if (!encoding) {
+        throw new Error(`Unsupported payload encoding ${value}`)
+    }
+
+    return encoding
+}
@@ -0,0 +1,187 @@
+/** The protobuf wire types. Groups (3 and 4) are deprecated and not supported. */
+export enum WireType {
+    varint = 0,
+    fixed64 = 1,
+    lengthDelimited = 2,
+    fixed32 = 5,
+}
+
+/** A single field of an encoded protobuf message. */
+export interface ProtobufField {
+    /** The field number. */
+    field: number
+
+    /** The wire type of the field. */
+    wireType: WireType
+
+    /**
+     * The value of the field. Varints are decoded into numbers. Length-delimited and
+     * fixed-width values are the raw bytes of the value.
+     */
+    value: number | Buffer
+}
+
+/**
+ * Read a varint from the given buffer. Returns undefined if the buffer ends before
+ * the varint does, which allows callers reading a partially received message to wait
+ * for more data. Values larger than `Number.MAX_SAFE_INTEGER` lose precision.
+ *
+ * @param buffer The encoded bytes.
+ * @param offset The offset of the varint in the buffer.
+ */
+export function readVarint(buffer: Buffer, offset: number): { value: number; offset: number } | undefined {
+    let value = 0
+    let multiplier = 1
+
+    for (let i = offset; i < buffer.length; i++) {
+        const byte = buffer[i]
+        value += (byte & 0x7f) * multiplier
+        multiplier *= 128
+
+        if ((byte & 0x80) === 0) {
+            return { value, offset: i + 1 }
+        }
+    }
+
+    return undefined
+}
+
+/**
+ * Read the header of the field at the given offset. For length-delimited fields, the
+ * returned offset is the start of the value and `length` is its size. Returns undefined
+ * if the buffer ends before the header does. Throws on unsupported wire types.
+ *
+ * @param buffer The encoded bytes.
+ * @param offset The offset of the field in the buffer.
+ */
+export function readFieldHeader(
+    buffer: Buffer,
+    offset: number
+): { field: number; wireType: WireType; length: number; offset: number } | undefined {
+    const tag = readVarint(buffer, offset)
+    if (!tag) {
+        return undefined
+    }
+
+    const field = Math.floor(tag.value / 8)
+    const wireType = tag.value % 8
+
+    switch (wireType) {
+        case WireType.varint: {
+            // The length of a varint is unknown until it is read
+            return { field, wireType, length: 0, offset: tag.offset }
+        }
+
+        case WireType.fixed64:
+            return { field, wireType, length: 8, offset: tag.offset }
+
+        case WireType.fixed32:
+            return { field, wireType, length: 4, offset: tag.offset }
+
+        case WireType.lengthDelimited: {
+            const length = readVarint(buffer, tag.offset)
+            return length && { field, wireType, length: length.value, offset: length.offset }
+        }
+    }
+
+    throw new Error(`Unsupported protobuf wire type ${wireType} for field ${field}`)
+}
+
+/**
+ * Yield each field of the given encoded protobuf message in order. Repeated fields
+ * are yielded once per occurrence. Throws if the message is truncated.
+ *
+ * @param buffer The encoded message.
+ */
+export function* readFields(buffer: Buffer): Iterable<ProtobufField> {
+    let offset = 0
+    while (offset < buffer.length) {
+        const header = readFieldHeader(buffer, offset)
+        if (!header) {
+            throw new Error('Truncated protobuf field header')
+        }
+
+        const { field, wireType } = header
+        if (wireType === WireType.varint) {
+            const varint = readVarint(buffer, header.offset)
+            if (!varint) {
+                throw new Error(`Truncated protobuf varint for field ${field}`)
+            }
+
+            offset = varint.offset
+            yield { field, wireType, value: varint.value }
+            continue
+        }
+
+        const end = header.offset + header.length
+        if (end > buffer.length) {
+            throw new Error(`Truncated protobuf value for field ${field}`)
+        }
+
+        offset = end
+        yield { field, wireType, value: buffer.slice(header.offset, end) }
+    }
+}
+
+/**
+ * Decode a repeated varint field. Proto3 encodes these as a single packed
+ * length-delimited value, but parsers must also accept unpacked occurrences,
+ * so the values of each occurrence of the field should be concatenated.
+ *
+ * @param value The value of one occurrence of the field.
+ */
+export function decodePackedVarints(value: number | Buffer): number[] {
+    if (typeof value === 'number') {
+        return [value]
+    }
+
+    const values: number[] = []
+    let offset = 0
+    while (offset < value.length) {
+        const varint = readVarint(value, offset)
+        if (!varint) {
+            throw new Error('Truncated packed protobuf varint')
+        }
+
+        values.push(varint.value)
+        offset = varint.offset
+    }
+
+    return values
+}
+
+/**
+ * Decode a string field.
+ *
+ * @param value The value of the field.
+ */
+export function decodeString(value: number | Buffer): string {
+    return expectBytes(value).toString('utf8')
+}
+
+/**
+ * Return the raw bytes of a length-delimited field. Throws if the field was encoded
+ * as a varint.
+ *
+ * @param value The value of the field.
+ */
+export function expectBytes(value: number | Buffer): Buffer {
+    if (typeof value === 'number') {
+        throw new Error('Expected a length-delimited protobuf value')
+    }
+
+    return value
+}
+
+/**
+ * Return the value of a varint field. Throws if the field was not encoded as a varint.
+ *
+ * @param value The value of the field.
+ */
+export function expectVarint(value: number | Buffer): number {
+    if (typeof value !== 'number') {
+        throw new Error('Expected a varint protobuf value')
+    }
+
+    return value
+}
@@ -0,0 +1,76 @@
+import { decodeIndex, ScipPositionEncoding } from './scip'
+import { encodeProtobufField as field } from '../test-util'
+
+describe('decodeIndex', () => {
+    it('should decode metadata, documents, and external symbols', () => {
+        const index = Buffer.concat([
+            field(1, [
+                field(2, [field(1, 'scip-typescript'), field(2, '0.1.0'), field(3, '--yarn-workspaces')]),
+                field(3, 'file:///lsif-test'),
+            ]),
+            field(2, [
+                field(4, 'TypeScript'),
+                field(1, 'src/index.ts'),
+                field(2, [
+                    field(1, Buffer.from([1, 4, 8])),
+                    field(2, 'scip-typescript npm pkg 1.0.0 src/`index.ts`/foo().'),
+                    field(3, 1),
+                ]),
+                // An unpacked repeated field
+                field(2, [field(1, 2), field(1, 4), field(1, 3), field(1, 6), field(2, 'local 0')]),
+                field(3, [field(1, 'local 0'), field(3, 'docs'), field(4, [field(1, 'other'), field(3, 1)])]),
+                field(6, ScipPositionEncoding.utf16),
+                // An unknown field
+                field(99, 'ignored'),
+            ]),
+            field(3, [field(1, 'scip-typescript npm dep 2.0.0 `lib.d.ts`/bar().')]),
+        ])
+
+        expect(decodeIndex(index)).toEqual({
+            metadata: {
+                version: 0,
+                toolInfo: { name: 'scip-typescript', version: '0.1.0', arguments: ['--yarn-workspaces'] },
+                projectRoot: 'file:///lsif-test',
+            },
+            documents: [
+                {
+                    relativePath: 'src/index.ts',
+                    language: 'TypeScript',
+                    occurrences: [
+                        {
+                            range: [1, 4, 8],
+                            symbol: 'scip-typescript npm pkg 1.0.0 src/`index.ts`/foo().',
+                            symbolRoles: 1,
+                            overrideDocumentation: [],
+                        },
+                        { range: [2, 4, 3, 6], symbol: 'local 0', symbolRoles: 0, overrideDocumentation: [] },
+                    ],
+                    symbols: [
+                        {
+                            symbol: 'local 0',
+                            documentation: ['docs'],
+                            relationships: [
+                                {
+                                    symbol: 'other',
+                                    isReference: false,
+                                    isImplementation: true,
+                                    isTypeDefinition: false,
+                                    isDefinition: false,
+                                },
+                            ],
+                        },
+                    ],
+                    positionEncoding: ScipPositionEncoding.utf16,
+                },
+            ],
+            externalSymbols: [
+                { symbol: 'scip-typescript npm dep 2.0.0 `lib.d.ts`/bar().', documentation: [], relationships: [] },
+            ],
+        })
+    })
+
+    it('should reject truncated messages', () => {
+        const index = field(2, [field(1, 'src/index.ts')])
+        expect(() => decodeIndex(index.slice(0, -2))).toThrow('Truncated protobuf value for field 2')
+    })
+})
@@ -0,0 +1,249 @@
+import { decodePackedVarints, decodeString, expectBytes, expectVarint, readFields } from './protobuf'
+
+// This file decodes the subset of the SCIP protobuf schema (see
+// https://github.com/sourcegraph/scip/blob/main/scip.proto) that is needed to convert
+// an index into a bundle. LSIF-typed indexes use the same schema and field numbers.
+
+/** The symbol role bit set on occurrences that define their symbol. */
+export const SYMBOL_ROLE_DEFINITION = 0x1
+
+/** The position encodings a SCIP document may declare. */
+export enum ScipPositionEncoding {
+    unspecified = 0,
+    utf8 = 1,
+    utf16 = 2,
+    utf32 = 3,
+}
+
+/** The tool that produced a SCIP index. */
+export interface ScipToolInfo {
+    name: string
+    version: string
+    arguments: string[]
+}
+
+/** The metadata at the start of a SCIP index. */
+export interface ScipMetadata {
+    /** The SCIP protocol version. */
+    version: number
+    /** The tool that produced the index, if given. */
+    toolInfo?: ScipToolInfo
+    /** The URI of the root of the indexed project. */
+    projectRoot: string
+}
+
+/** A relationship from one symbol to another. */
+export interface ScipRelationship {
+    symbol: string
+    isReference: boolean
+    isImplementation: boolean
+    isTypeDefinition: boolean
+    isDefinition: boolean
+}
+
+/** Information about a symbol defined by a document or referenced by the index. */
+export interface ScipSymbolInformation {
+    symbol: string
+    documentation: string[]
+    relationships: ScipRelationship[]
+}
+
+/** A single occurrence of a symbol in a document. */
+export interface ScipOccurrence {
+    /**
+     * The range as `[startLine, startCharacter, endCharacter]` for single-line ranges, or
+     * as `[startLine, startCharacter, endLine, endCharacter]`.
+     */
+    range: number[]
+    symbol: string
+    symbolRoles: number
+    overrideDocumentation: string[]
+}
+
+/** A document of a SCIP index. */
+export interface ScipDocument {
+    relativePath: string
+    language: string
+    occurrences: ScipOccurrence[]
+    symbols: ScipSymbolInformation[]
+    positionEncoding: ScipPositionEncoding
+}
+
+/** A decoded SCIP index. */
+export interface ScipIndex {
+    metadata?: ScipMetadata
+    documents: ScipDocument[]
+    externalSymbols: ScipSymbolInformation[]
+}
+
+/**
+ * Decode an encoded SCIP index. Unknown fields are ignored.
+ *
+ * @param buffer The encoded index.
+ */
+export function decodeIndex(buffer: Buffer): ScipIndex {
+    const index: ScipIndex = { documents: [], externalSymbols: [] }
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                index.metadata = decodeMetadata(expectBytes(value))
+                break
+            case 2:
+                index.documents.push(decodeDocument(expectBytes(value)))
+                break
+            case 3:
+                index.externalSymbols.push(decodeSymbolInformation(expectBytes(value)))
+                break
+        }
+    }
+
+    return index
+}
+
+/**
+ * Decode the metadata message of a SCIP index.
+ *
+ * @param buffer The encoded metadata.
+ */
+export function decodeMetadata(buffer: Buffer): ScipMetadata {
+    const metadata: ScipMetadata = { version: 0, projectRoot: '' }
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                metadata.version = expectVarint(value)
+                break
+            case 2:
+                metadata.toolInfo = decodeToolInfo(expectBytes(value))
+                break
+            case 3:
+                metadata.projectRoot = decodeString(value)
+                break
+        }
+    }
+
+    return metadata
+}
+
+function decodeToolInfo(buffer: Buffer): ScipToolInfo {
+    const toolInfo: ScipToolInfo = { name: '', version: '', arguments: [] }
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                toolInfo.name = decodeString(value)
+                break
+            case 2:
+                toolInfo.version = decodeString(value)
+                break
+            case 3:
+                toolInfo.arguments.push(decodeString(value))
+                break
+        }
+    }
+
+    return toolInfo
+}
+
+function decodeDocument(buffer: Buffer): ScipDocument {
+    const document: ScipDocument = {
+        relativePath: '',
+        language: '',
+        occurrences: [],
+        symbols: [],
+        positionEncoding: ScipPositionEncoding.unspecified,
+    }
+
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                document.relativePath = decodeString(value)
+                break
+            case 2:
+                document.occurrences.push(decodeOccurrence(expectBytes(value)))
+                break
+            case 3:
+                document.symbols.push(decodeSymbolInformation(expectBytes(value)))
+                break
+            case 4:
+                document.language = decodeString(value)
+                break
+            case 6:
+                document.positionEncoding = expectVarint(value)
+                break
+        }
+    }
+
+    return document
+}
+
+function decodeOccurrence(buffer: Buffer): ScipOccurrence {
+    const occurrence: ScipOccurrence = { range: [], symbol: '', symbolRoles: 0, overrideDocumentation: [] }
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                occurrence.range.push(...decodePackedVarints(value))
+                break
+            case 2:
+                occurrence.symbol = decodeString(value)
+                break
+            case 3:
+                occurrence.symbolRoles = expectVarint(value)
+                break
+            case 4:
+                occurrence.overrideDocumentation.push(decodeString(value))
+                break
+        }
+    }
+
+    return occurrence
+}
+
+function decodeSymbolInformation(buffer: Buffer): ScipSymbolInformation {
+    const symbolInformation: ScipSymbolInformation = { symbol: '', documentation: [], relationships: [] }
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                symbolInformation.symbol = decodeString(value)
+                break
+            case 3:
+                symbolInformation.documentation.push(decodeString(value))
+                break
+            case 4:
+                symbolInformation.relationships.push(decodeRelationship(expectBytes(value)))
+                break
+        }
+    }
+
+    return symbolInformation
+}
+
+function decodeRelationship(buffer: Buffer): ScipRelationship {
+    const relationship: ScipRelationship = {
+        symbol: '',
+        isReference: false,
+        isImplementation: false,
+        isTypeDefinition: false,
+        isDefinition: false,
+    }
+
+    for (const { field, value } of readFields(buffer)) {
+        switch (field) {
+            case 1:
+                relationship.symbol = decodeString(value)
+                break
+            case 2:
+                relationship.isReference = expectVarint(value) !== 0
+                break
+            case 3:
+                relationship.isImplementation = expectVarint(value) !== 0
+                break
+            case 4:
+                relationship.isTypeDefinition = expectVarint(value) !== 0
+                break
+            case 5:
+                relationship.isDefinition = expectVarint(value) !== 0
+                break
+        }
+    }
+
+    return relationship
+}
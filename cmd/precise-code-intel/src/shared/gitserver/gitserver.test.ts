@@ -1,5 +1,5 @@
 import nock from 'nock'
-import { flattenCommitParents, getCommitsNear, getDirectoryChildren } from './gitserver'
+import { flattenCommitParents, getCommitsNear, getDirectoryChildren, getRecentlyChangedFiles } from './gitserver'
 
 describe('getDirectoryChildren', () => {
     it('should parse response from gitserver', async () => {
@@ -26,6 +26,29 @@ describe('getDirectoryChildren', () => {
     })
 })
 
+describe('getRecentlyChangedFiles', () => {
+    it('should return each changed file once', async () => {
+        const since = new Date('2020-01-01T00:00:00Z')
+
+        nock('http://frontend')
+            .post('/.internal/git/42/exec', {
+                args: [
+                    'log',
+                    '--name-only',
+                    '--diff-filter=d',
+                    '--pretty=format:',
+                    '--since=2020-01-01T00:00:00.000Z',
+                    'c',
+                ],
+            })
+            .reply(200, 'a.ts\nb/c.ts\n\nb/c.ts\nd.ts\n')
+
+        expect(
+            await getRecentlyChangedFiles({ frontendUrl: 'frontend', repositoryId: 42, commit: 'c', since })
+        ).toEqual(['a.ts', 'b/c.ts', 'd.ts'])
+    })
+})
+
 describe('getCommitsNear', () => {
     it('should parse response from gitserver', async () => {
         nock('http://frontend')
@@ -1,6 +1,7 @@
 import got from 'got'
 import { MAX_COMMITS_PER_UPDATE } from '../constants'
-import { TracingContext, logAndTraceCall } from '../tracing'
+import { TracingContext, logAndTraceCall, tracingHeaders } from '../tracing'
+import { withCancellation } from '../cancellation'
 import { instrument } from '../metrics'
 import * as metrics from './metrics'
 
@@ -58,6 +59,72 @@ export async function getDirectoryChildren({
     return childMap
 }
 
+/**
+ * Get the paths of all files tracked by git recursively within the given directory at a
+ * particular commit. The root directory is denoted by the empty string.
+ *
+ * @param args Parameter bag.
+ */
+export function getTrackedFiles({
+    frontendUrl,
+    repositoryId,
+    commit,
+    dirname,
+    ctx = {},
+}: {
+    /** The url of the frontend internal API. */
+    frontendUrl: string
+    /** The repository identifier. */
+    repositoryId: number
+    /** The commit at which to list files. */
+    commit: string
+    /** A repo-root-relative directory. */
+    dirname: string
+    /** The tracing context. */
+    ctx?: TracingContext
+}): Promise<string[]> {
+    const args = [
+        'ls-tree',
+        '-r',
+        '--name-only',
+        commit,
+        '--',
+        dirname === '' ? '.' : dirname.endsWith('/') ? dirname : dirname + '/',
+    ]
+
+    return gitserverExecLines(frontendUrl, repositoryId, args, ctx)
+}
+
+/**
+ * Get the paths of the files changed by the commits reachable from the given commit that
+ * were made since the given date, ordered from most to least recently changed. Deletions
+ * are not reported, but a file changed before its deletion within the window is.
+ *
+ * @param args Parameter bag.
+ */
+export async function getRecentlyChangedFiles({
+    frontendUrl,
+    repositoryId,
+    commit,
+    since,
+    ctx = {},
+}: {
+    /** The url of the frontend internal API. */
+    frontendUrl: string
+    /** The repository identifier. */
+    repositoryId: number
+    /** The commit from which history is traversed. */
+    commit: string
+    /** The date of the oldest commit to consider. */
+    since: Date
+    /** The tracing context. */
+    ctx?: TracingContext
+}): Promise<string[]> {
+    const args = ['log', '--name-only', '--diff-filter=d', '--pretty=format:', `--since=${since.toISOString()}`, commit]
+
+    return Array.from(new Set(await gitserverExecLines(frontendUrl, repositoryId, args, ctx)))
+}
+
 /**
  * Get a list of commits for the given repository with their parent starting at the
  * given commit and returning at most `MAX_COMMITS_PER_UPDATE` commits. The output
@@ -173,12 +240,15 @@ function gitserverExec(
         throw new Error('Gitserver commands should not be prefixed with `git`')
     }
 
-    return logAndTraceCall(ctx, 'Executing git command', () =>
+    return logAndTraceCall(ctx, 'Executing git command', ctx =>
         instrument(metrics.gitserverDurationHistogram, metrics.gitserverErrorsCounter, async () => {
             // Perform request - this may fail with a 404 or 500
-            const resp = await got.post(new URL(`http://${frontendUrl}/.internal/git/${repositoryId}/exec`).href, {
-                body: JSON.stringify({ args }),
-            })
+            const resp = await withCancellation(ctx.cancellation, () =>
+                got.post(new URL(`http://${frontendUrl}/.internal/git/${repositoryId}/exec`).href, {
+                    headers: tracingHeaders(ctx),
+                    body: JSON.stringify({ args }),
+                })
+            )
 
             // Read trailers on a 200-level response
             const status = resp.trailers['x-exec-exit-status']
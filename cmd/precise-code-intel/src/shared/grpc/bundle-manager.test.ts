@@ -0,0 +1,46 @@
+import * as grpc from '@grpc/grpc-js'
+import * as lsif from 'lsif-protocol'
+import {
+    decodeMoniker,
+    encodeMoniker,
+    ERROR_CODE_METADATA_KEY,
+    fromServiceError,
+    toServiceError,
+} from './bundle-manager'
+
+describe('encodeMoniker', () => {
+    it('should round trip monikers', () => {
+        const monikers = [
+            { kind: lsif.MonikerKind.import, scheme: 'npm', identifier: 'foo:bar', packageInformationId: '12' },
+            { kind: lsif.MonikerKind.local, scheme: 'tsc', identifier: 'baz' },
+        ]
+
+        for (const moniker of monikers) {
+            expect(decodeMoniker(encodeMoniker(moniker))).toEqual(moniker)
+        }
+    })
+
+    it('should wrap package information identifiers', () => {
+        const moniker = { kind: lsif.MonikerKind.export, scheme: 'npm', identifier: 'x', packageInformationId: 7 }
+        expect(encodeMoniker(moniker).packageInformationId).toEqual({ value: '7' })
+    })
+})
+
+describe('toServiceError', () => {
+    it('should round trip statuses and error codes', () => {
+        const error = fromServiceError(
+            toServiceError(Object.assign(new Error('Bundle not found'), { status: 404, code: 'bundle_not_found' }))
+        )
+
+        expect(error.message).toEqual('Bundle not found')
+        expect(error.status).toEqual(404)
+        expect(error.code).toEqual('bundle_not_found')
+    })
+
+    it('should report unknown statuses as internal errors', () => {
+        const serviceError = toServiceError(new Error('oops'))
+        expect(serviceError.code).toEqual(grpc.status.INTERNAL)
+        expect(serviceError.metadata.get(ERROR_CODE_METADATA_KEY)).toEqual([])
+        expect(fromServiceError(serviceError).status).toEqual(500)
+    })
+})
@@ -0,0 +1,235 @@
+import * as grpc from '@grpc/grpc-js'
+import * as lsif from 'lsif-protocol'
+import * as lsp from 'vscode-languageserver-protocol'
+import * as path from 'path'
+import * as protoLoader from '@grpc/proto-loader'
+import * as sqliteModels from '../models/sqlite'
+import { HoverData } from '../encoding/hover'
+
+/**
+ * The definition of the BundleManager gRPC service. Fields are named in camel case, unset
+ * message fields are null, and 64-bit integers are read as numbers.
+ */
+export const bundleManagerService = (protoLoader.loadSync(path.join(__dirname, 'bundle-manager.proto'), {
+    longs: Number,
+    enums: String,
+    defaults: true,
+    oneofs: true,
+})['precisecodeintel.bundlemanager.v1.BundleManager'] as unknown) as grpc.ServiceDefinition
+
+/** A value of a `google.protobuf` wrapper type, which distinguishes an unset value. */
+export interface WrappedValue<T> {
+    value: T
+}
+
+/** The location of a range in a document of a dump. */
+export interface LocationMessage {
+    path: string
+    range: lsp.Range
+}
+
+/** A moniker attached to a range of a dump. */
+export interface MonikerMessage {
+    kind: string
+    scheme: string
+    identifier: string
+    packageInformationId: WrappedValue<string> | null
+}
+
+/** The header of a bundle upload. */
+export interface UploadBundleHeader {
+    dumpId: number
+    force: boolean
+    size: number
+}
+
+/** A request for a position in a document of a dump. */
+export interface PositionRequest {
+    dumpId: number
+    path: string
+    position: lsp.Position
+}
+
+/** A request for a page of the locations of the symbol at a position. */
+export interface LocationsRequest extends PositionRequest {
+    skip: number
+    take: WrappedValue<number> | null
+}
+
+/** A page of locations and the total number of locations. */
+export interface LocationsResponse {
+    locations: LocationMessage[]
+    count: number
+}
+
+/** The request and response types of each method of the BundleManager service. */
+export interface BundleManagerMethods {
+    Exists: [{ dumpId: number; path: string }, { exists: boolean }]
+    Definitions: [LocationsRequest, LocationsResponse]
+    References: [LocationsRequest, LocationsResponse]
+    Hover: [PositionRequest, { hover: HoverData | null }]
+    MonikersByPosition: [PositionRequest, { ranges: { monikers: MonikerMessage[] }[] }]
+    MonikerResults: [
+        {
+            dumpId: number
+            model: 'DEFINITION' | 'REFERENCE'
+            scheme: string
+            identifier: string
+            skip: number
+            take: WrappedValue<number> | null
+        },
+        LocationsResponse
+    ]
+    PackageInformation: [
+        { dumpId: number; path: string; packageInformationId: string },
+        { packageInformation: { name: string; version: WrappedValue<string> | null } | null }
+    ]
+    UploadBundle: [{ header?: UploadBundleHeader; data?: Buffer }, {}]
+}
+
+/** The methods of the BundleManager service that take a single request. */
+export type UnaryMethod = Exclude<keyof BundleManagerMethods, 'UploadBundle'>
+
+/**
+ * Wrap the given value in a `google.protobuf` wrapper message, or return null if it is undefined.
+ *
+ * @param value The value.
+ */
+export function wrap<T>(value: T | undefined | null): WrappedValue<T> | null {
+    return value === undefined || value === null ? null : { value }
+}
+
+/**
+ * Convert a moniker into its message.
+ *
+ * @param moniker The moniker.
+ */
+export function encodeMoniker({
+    kind,
+    scheme,
+    identifier,
+    packageInformationId,
+}: sqliteModels.MonikerData): MonikerMessage {
+    return {
+        kind,
+        scheme,
+        identifier,
+        packageInformationId: wrap(packageInformationId === undefined ? undefined : String(packageInformationId)),
+    }
+}
+
+/**
+ * Convert a moniker message into a moniker.
+ *
+ * @param message The moniker message.
+ */
+export function decodeMoniker({
+    kind,
+    scheme,
+    identifier,
+    packageInformationId,
+}: MonikerMessage): sqliteModels.MonikerData {
+    return {
+        kind: kind as lsif.MonikerKind,
+        scheme,
+        identifier,
+        ...(packageInformationId ? { packageInformationId: packageInformationId.value } : {}),
+    }
+}
+
+/** The name of the trailer that carries the error code of a failed call. */
+export const ERROR_CODE_METADATA_KEY = 'x-error-code'
+
+/** The gRPC status of each HTTP status of an error. Other statuses are reported as internal errors. */
+const grpcStatuses = new Map<number, grpc.status>([
+    [400, grpc.status.INVALID_ARGUMENT],
+    [401, grpc.status.UNAUTHENTICATED],
+    [404, grpc.status.NOT_FOUND],
+    [409, grpc.status.ALREADY_EXISTS],
+    [422, grpc.status.FAILED_PRECONDITION],
+    [429, grpc.status.RESOURCE_EXHAUSTED],
+    [499, grpc.status.CANCELLED],
+    [501, grpc.status.UNIMPLEMENTED],
+    [503, grpc.status.UNAVAILABLE],
+])
+
+/** The HTTP status of each gRPC status. Other statuses are reported as internal errors. */
+const httpStatuses = new Map<grpc.status, number>([
+    ...Array.from(grpcStatuses.entries()).map(([httpStatus, grpcStatus]): [grpc.status, number] => [
+        grpcStatus,
+        httpStatus,
+    ]),
+    [grpc.status.DEADLINE_EXCEEDED, 504],
+])
+
+/**
+ * Convert an error thrown by a handler of the gRPC API into the error sent to the client.
+ * The status of the error is converted into the corresponding gRPC status, and its error
+ * code is sent as a trailer.
+ *
+ * @param error The error thrown by the handler.
+ */
+export function toServiceError(error: {
+    message?: string
+    status?: number
+    code?: string
+}): grpc.ServiceError {
+    const metadata = new grpc.Metadata()
+    if (error.code) {
+        metadata.set(ERROR_CODE_METADATA_KEY, error.code)
+    }
+
+    const details = error.message || 'Unknown error'
+    return Object.assign(new Error(details), {
+        code: (error.status && grpcStatuses.get(error.status)) || grpc.status.INTERNAL,
+        details,
+        metadata,
+    })
+}
+
+/**
+ * Convert an error returned from a call to the gRPC API into an error with the HTTP status
+ * and error code that the HTTP API would have responded with, so that callers handle errors
+ * of both transports alike.
+ *
+ * @param error The error returned from the call.
+ */
+export function fromServiceError(error: grpc.ServiceError): Error & { status: number; code?: string } {
+    const [code] = error.metadata ? error.metadata.get(ERROR_CODE_METADATA_KEY) : []
+
+    return Object.assign(new Error(error.details || error.message), {
+        status: httpStatuses.get(error.code) || 500,
+        code: code === undefined ? undefined : String(code),
+    })
+}
+
+/**
+ * Create call metadata holding the given HTTP headers, e.g. the tracing and authorization
+ * headers of a request.
+ *
+ * @param headers The headers.
+ */
+export function createMetadata(headers: { [name: string]: string }): grpc.Metadata {
+    const metadata = new grpc.Metadata()
+    for (const [name, value] of Object.entries(headers)) {
+        metadata.set(name.toLowerCase(), value)
+    }
+
+    return metadata
+}
+
+/**
+ * Return the textual values of the given call metadata as HTTP headers.
+ *
+ * @param metadata The call metadata.
+ */
+export function metadataHeaders(metadata: grpc.Metadata): { [name: string]: string } {
+    const headers: { [name: string]: string } = {}
+    for (const [name, value] of Object.entries(metadata.getMap())) {
+        if (typeof value === 'string') {
+            headers[name] = value
+        }
+    }
+
+    return headers
+}
@@ -0,0 +1,213 @@
+import * as grpc from '@grpc/grpc-js'
+import * as settings from '../config/settings'
+import { authorizationHeaders } from '../api/middleware/auth'
+import { TracingContext, tracingHeaders } from '../tracing'
+import { withCancellation } from '../cancellation'
+import {
+    bundleManagerService,
+    BundleManagerMethods,
+    createMetadata,
+    fromServiceError,
+    UnaryMethod,
+    UploadBundleHeader,
+} from './bundle-manager'
+
+/** A pool of connections to a single bundle manager. */
+interface ConnectionPool {
+    /** The clients of the pool, each of which holds its own connection. */
+    clients: grpc.Client[]
+
+    /** The index of the client that sends the next call. */
+    next: number
+}
+
+/**
+ * A client of the gRPC API of the bundle managers. Each bundle manager is reached over a
+ * fixed pool of connections, which are opened on first use and kept open. Calls are spread
+ * over the connections of a pool in turn, as the number of concurrent calls over a single
+ * HTTP/2 connection is limited by the server.
+ */
+export class BundleManagerGrpcClient {
+    /** The connection pools, indexed by the address of the bundle manager. */
+    private pools = new Map<string, ConnectionPool>()
+
+    /**
+     * Create a new `BundleManagerGrpcClient`.
+     *
+     * @param port The port of the gRPC API of the bundle managers.
+     * @param connectionsPerHost The number of connections opened to each bundle manager.
+     */
+    constructor(
+        private port: number = settings.BUNDLE_MANAGER_GRPC_PORT,
+        private connectionsPerHost: number = settings.BUNDLE_MANAGER_GRPC_CONNECTIONS_PER_HOST
+    ) {}
+
+    /**
+     * Call a method of the bundle manager with the given URL. Errors are converted into errors
+     * with the HTTP status and error code that the HTTP API would have responded with. The call
+     * is cancelled once the request of the tracing context is cancelled.
+     *
+     * @param shard The URL of the HTTP API of the bundle manager.
+     * @param method The name of the method.
+     * @param request The request message.
+     * @param options The deadline (in milliseconds) of the call and the tracing context.
+     */
+    public call<M extends UnaryMethod>(
+        shard: string,
+        method: M,
+        request: BundleManagerMethods[M][0],
+        { timeoutMs, ctx = {} }: { timeoutMs?: number; ctx?: TracingContext } = {}
+    ): Promise<BundleManagerMethods[M][1]> {
+        const { path, requestSerialize, responseDeserialize } = bundleManagerService[method]
+        const client = this.clientFor(shard)
+
+        return withCancellation(ctx.cancellation, () => {
+            let call: grpc.ClientUnaryCall | undefined
+            const promise = new Promise<BundleManagerMethods[M][1]>((resolve, reject) => {
+                call = client.makeUnaryRequest(
+                    path,
+                    requestSerialize,
+                    responseDeserialize,
+                    request,
+                    this.metadata(ctx),
+                    timeoutMs === undefined ? {} : { deadline: Date.now() + timeoutMs },
+                    (error, response) => (error ? reject(fromServiceError(error)) : resolve(response))
+                )
+            })
+
+            return Object.assign(promise, { cancel: () => call?.cancel() })
+        })
+    }
+
+    /**
+     * Send a bundle to the bundle manager with the given URL.
+     *
+     * @param shard The URL of the HTTP API of the bundle manager.
+     * @param header The header of the upload.
+     * @param source The contents of the bundle.
+     * @param ctx The tracing context.
+     */
+    public uploadBundle(
+        shard: string,
+        header: UploadBundleHeader,
+        source: AsyncIterable<Buffer>,
+        ctx: TracingContext = {}
+    ): Promise<void> {
+        const { path, requestSerialize, responseDeserialize } = bundleManagerService.UploadBundle
+        const client = this.clientFor(shard)
+
+        return withCancellation(ctx.cancellation, () => {
+            let call: grpc.ClientWritableStream<BundleManagerMethods['UploadBundle'][0]> | undefined
+            const promise = new Promise<void>((resolve, reject) => {
+                const stream = client.makeClientStreamRequest(
+                    path,
+                    requestSerialize,
+                    responseDeserialize,
+                    this.metadata(ctx),
+                    {},
+                    error => (error ? reject(fromServiceError(error)) : resolve())
+                )
+                call = stream
+
+                writeBundle(stream, header, source).catch(error => {
+                    stream.cancel()
+                    reject(error)
+                })
+            })
+
+            return Object.assign(promise, { cancel: () => call?.cancel() })
+        })
+    }
+
+    /** Close the connections to all bundle managers. */
+    public close(): void {
+        for (const { clients } of this.pools.values()) {
+            for (const client of clients) {
+                client.close()
+            }
+        }
+
+        this.pools.clear()
+    }
+
+    /**
+     * Return the client that sends the next call to the bundle manager with the given URL.
+     *
+     * @param shard The URL of the HTTP API of the bundle manager.
+     */
+    private clientFor(shard: string): grpc.Client {
+        const address = `${new URL(shard).hostname}:${this.port}`
+
+        let pool = this.pools.get(address)
+        if (!pool) {
+            const clients = Array.from(
+                { length: Math.max(1, this.connectionsPerHost) },
+                () =>
+                    new grpc.Client(address, grpc.credentials.createInsecure(), {
+                        // Clients with the same address and options share a connection by default
+                        'grpc.use_local_subchannel_pool': 1,
+                        // Locations of popular symbols easily exceed the default limit of 4MiB
+                        'grpc.max_receive_message_length': -1,
+                    })
+            )
+
+            pool = { clients, next: 0 }
+            this.pools.set(address, pool)
+        }
+
+        const client = pool.clients[pool.next]
+        pool.next = (pool.next + 1) % pool.clients.length
+        return client
+    }
+
+    /**
+     * Create the metadata of a call that propagates the span of the given tracing context
+     * and authorizes the call.
+     *
+     * @param ctx The tracing context.
+     */
+    private metadata(ctx: TracingContext): grpc.Metadata {
+        return createMetadata({ ...tracingHeaders(ctx), ...authorizationHeaders() })
+    }
+}
+
+/**
+ * Write the header and the contents of a bundle to the stream of an upload, then close the
+ * stream. Writing stops early once the call fails, as the stream no longer drains then.
+ *
+ * @param call The stream of the upload.
+ * @param header The header of the upload.
+ * @param source The contents of the bundle.
+ */
+async function writeBundle(
+    call: grpc.ClientWritableStream<BundleManagerMethods['UploadBundle'][0]>,
+    header: UploadBundleHeader,
+    source: AsyncIterable<Buffer>
+): Promise<void> {
+    let failed = false
+    call.on('status', ({ code }: grpc.StatusObject) => {
+        failed = code !== grpc.status.OK
+    })
+
+    call.write({ header })
+    for await (const data of source) {
+        if (failed) {
+            return
+        }
+
+        if (!call.write({ data })) {
+            await new Promise(resolve => {
+                const done = (): void => {
+                    call.removeListener('drain', done)
+                    call.removeListener('status', done)
+                    resolve()
+                }
+
+                call.on('drain', done)
+                call.on('status', done)
+            })
+        }
+    }
+
+    call.end()
+}
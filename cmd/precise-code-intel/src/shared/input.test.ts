@@ -2,7 +2,7 @@ import * as fs from 'mz/fs'
 import * as path from 'path'
 import * as zlib from 'mz/zlib'
 import rmfr from 'rmfr'
-import { parseJsonLines, readGzippedJsonElementsFromFile, splitLines } from './input'
+import { detectFormat, parseJsonLines, readGzippedJsonElementsFromFile, splitLines } from './input'
 import { Readable } from 'stream'
 
 describe('readGzippedJsonElements', () => {
@@ -131,3 +131,12 @@ async function consume(iterable: AsyncIterable<unknown>): Promise<void> {
         // no-op body, just consume iterable
     }
 }
+
+describe('detectFormat', () => {
+    it('should detect JSON lines and protobuf payloads', () => {
+        expect(detectFormat(Buffer.from('{"id": 1}'))).toEqual('lsif')
+        expect(detectFormat(Buffer.from('\n  {"id": 1}'))).toEqual('lsif')
+        expect(detectFormat(Buffer.from([0x0a, 0x05]))).toEqual('scip')
+        expect(detectFormat(Buffer.from(' \n'))).toBeUndefined()
+    })
+})
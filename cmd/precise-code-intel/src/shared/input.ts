@@ -1,15 +1,56 @@
 import * as fs from 'mz/fs'
 import { createGunzip } from 'zlib'
+import { gunzip } from 'mz/zlib'
+import { decodeIndex, ScipIndex } from './encoding/scip'
+import { LsifUploadFormat } from './models/pg'
+
+/**
+ * Determine the format of a decompressed upload from its first bytes. JSON lines uploads
+ * begin with an object (possibly after whitespace). Anything else is assumed to be an
+ * encoded protobuf SCIP (or LSIF-typed) index, whose first byte is a field tag. Returns
+ * undefined if the given bytes are all whitespace.
+ *
+ * @param head The first bytes of the decompressed upload.
+ */
+export function detectFormat(head: Buffer): LsifUploadFormat | undefined {
+    for (const byte of head) {
+        if (byte === 0x20 || byte === 0x09 || byte === 0x0a || byte === 0x0d) {
+            continue
+        }
+
+        return byte === 0x7b ? 'lsif' : 'scip'
+    }
+
+    return undefined
+}
+
+/**
+ * Read and decode a gzipped SCIP index. The index is a single protobuf message, so the
+ * entire file is decompressed into memory.
+ *
+ * @param path The filepath containing a gzipped SCIP index.
+ */
+export async function readGzippedScipIndexFromFile(path: string): Promise<ScipIndex> {
+    return decodeIndex(await gunzip(await fs.readFile(path)))
+}
 
 /**
  * Yield parsed JSON elements from a file containing the gzipped JSON lines.
  *
  * @param path The filepath containing a gzipped compressed stream of JSON lines composing the LSIF dump.
+ * @param onRead A function invoked with the total number of (compressed) bytes read so far.
  */
-export function readGzippedJsonElementsFromFile(path: string): AsyncIterable<unknown> {
+export function readGzippedJsonElementsFromFile(
+    path: string,
+    onRead?: (bytesRead: number) => void
+): AsyncIterable<unknown> {
     const input = fs.createReadStream(path)
     const piped = input.pipe(createGunzip())
 
+    if (onRead) {
+        input.on('data', () => onRead(input.bytesRead))
+    }
+
     // Ensure we forward errors opening/reading the file to the async
     // iterator opened below.
     input.on('error', error => piped.emit('error', error))
@@ -22,3 +22,19 @@ export async function instrument<T>(
         end()
     }
 }
+
+//
+// Periodic Task Metrics
+
+export const taskDurationHistogram = new promClient.Histogram({
+    name: 'lsif_janitor_phase_duration_seconds',
+    help: 'Total time spent on each phase of the periodic cleanup tasks.',
+    labelNames: ['phase'],
+    buckets: [0.2, 0.5, 1, 2, 5, 10, 30, 60, 300],
+})
+
+export const taskErrorsCounter = new promClient.Counter({
+    name: 'lsif_janitor_phase_errors_total',
+    help: 'The number of errors that occurred during each phase of the periodic cleanup tasks.',
+    labelNames: ['phase'],
+})
@@ -1,4 +1,4 @@
-import { Column, Entity, JoinColumn, OneToOne, PrimaryGeneratedColumn } from 'typeorm'
+import { Column, Entity, JoinColumn, OneToOne, PrimaryColumn, PrimaryGeneratedColumn } from 'typeorm'
 import { EncodedBloomFilter } from '../datastructures/bloom-filter'
 import { MAX_POSTGRES_BATCH_SIZE } from '../constants'
 
@@ -6,7 +6,26 @@ import { MAX_POSTGRES_BATCH_SIZE } from '../constants'
 export type DumpId = number
 
 /** The possible states of an LsifUpload entity. */
-export type LsifUploadState = 'queued' | 'completed' | 'errored' | 'processing'
+export type LsifUploadState = 'queued' | 'completed' | 'errored' | 'processing' | 'failed' | 'deleting'
+
+/** The possible input formats of an LsifUpload entity. */
+export type LsifUploadFormat = 'lsif' | 'scip'
+
+/** The steps of the conversion of an LsifUpload entity, in the order in which they occur. */
+export type LsifUploadStage = 'downloading' | 'correlating' | 'writing' | 'dependencies' | 'uploading'
+
+/** The possible kinds of LsifUploadEvent entities. */
+export type LsifUploadEventType =
+    | 'enqueued'
+    | 'processing'
+    | 'completed'
+    | 'errored'
+    | 'requeued'
+    | 'failed'
+    | 'reset'
+    | 'deleted'
+    | 'restored'
+    | 'purged'
 
 /**
  * An entity within Postgres. This entity carries the data necessary to convert an
@@ -39,7 +58,11 @@ export class LsifUpload {
     @Column('text')
     public indexer!: string
 
-    /** The conversion state of the upload. May be `queued`, `processing`, `completed`, or `errored`. */
+    /**
+     * The conversion state of the upload. May be `queued`, `processing`, `completed`, `errored`,
+     * `failed`, or `deleting`. A failed upload errored after exhausting its retries and is never
+     * requeued. A deleting upload is hidden from queries until it is restored or purged.
+     */
     @Column('text')
     public state!: LsifUploadState
 
@@ -70,6 +93,77 @@ export class LsifUpload {
     /** Whether or not this commit is visible at the tip of the default branch. */
     @Column('boolean', { name: 'visible_at_tip' })
     public visibleAtTip!: boolean
+
+    /**
+     * The identifier of a newer dump for the same root and indexer at a descendant commit
+     * (if any). Superseded dumps are pruned before other dumps that are not visible at tip.
+     */
+    @Column('integer', { name: 'superseded_by', nullable: true })
+    public supersededBy!: number | null
+
+    /** Whether or not an operator has pinned this dump as the preferred provider for its root. */
+    @Column('boolean', { name: 'pinned' })
+    public pinned!: boolean
+
+    /** Whether or not an operator has excluded this dump from visibility. */
+    @Column('boolean', { name: 'excluded' })
+    public excluded!: boolean
+
+    /** The hex-encoded SHA-256 digest of the raw upload, if one was recorded. */
+    @Column('text', { nullable: true })
+    public checksum!: string | null
+
+    /** The number of times this upload has been requeued after an error. */
+    @Column('integer')
+    public attempts!: number
+
+    /** The time this upload was last requeued after an error (if ever). */
+    @Column('timestamp with time zone', { name: 'last_retried_at', nullable: true })
+    public lastRetriedAt!: Date | null
+
+    /** The time this upload was deleted, if it is in the `deleting` state. */
+    @Column('timestamp with time zone', { name: 'deleted_at', nullable: true })
+    public deletedAt!: Date | null
+
+    /** The state of this upload before it was deleted, if it is in the `deleting` state. */
+    @Column('text', { name: 'state_before_delete', nullable: true })
+    public stateBeforeDelete!: LsifUploadState | null
+
+    /** The time a bundle manager last answered a query from this dump, if it has been queried. */
+    @Column('timestamp with time zone', { name: 'last_accessed_at', nullable: true })
+    public lastAccessedAt!: Date | null
+
+    /** The version of LSIF given by the metadata vertex of the upload, if known. */
+    @Column('text', { name: 'lsif_version', nullable: true })
+    public lsifVersion!: string | null
+
+    /** The position encoding given by the metadata vertex of the upload, if known. */
+    @Column('text', { name: 'position_encoding', nullable: true })
+    public positionEncoding!: string | null
+
+    /** The project root URI given by the metadata vertex of the upload, if known. */
+    @Column('text', { name: 'project_root', nullable: true })
+    public projectRoot!: string | null
+
+    /** The input format of the upload. */
+    @Column('text')
+    public format!: LsifUploadFormat
+
+    /** The percentage (0-100) of the conversion that has completed. */
+    @Column('integer')
+    public progress!: number
+
+    /** The conversion step in progress, or the step that failed for an errored upload. */
+    @Column('text', { nullable: true })
+    public stage!: LsifUploadStage | null
+
+    /** The identifier of the worker process that dequeued the upload. */
+    @Column('text', { name: 'worker_id', nullable: true })
+    public workerId!: string | null
+
+    /** The time the worker converting the upload last reported that it is alive. */
+    @Column('timestamp with time zone', { name: 'last_heartbeat_at', nullable: true })
+    public lastHeartbeatAt!: Date | null
 }
 
 /** A view of LsifUpload entities with state = 'completed'. */
@@ -173,5 +267,75 @@ export class ReferenceModel extends Package {
     public filter!: EncodedBloomFilter
 }
 
+/**
+ * An entity within Postgres. This holds statistics about the documents and ranges of a
+ * dump that are computed at conversion time.
+ */
+@Entity({ name: 'lsif_dump_statistics' })
+export class DumpStatistics {
+    /** The foreign key to the dump. */
+    @PrimaryColumn('integer', { name: 'dump_id' })
+    public dumpId!: DumpId
+
+    /** The number of documents in the dump. */
+    @Column('integer', { name: 'num_documents' })
+    public numDocuments!: number
+
+    /** The total number of ranges over all documents in the dump. */
+    @Column('integer', { name: 'num_ranges' })
+    public numRanges!: number
+
+    /**
+     * The number of files tracked by git under the dump root at the dump's commit. This
+     * value is null if the files could not be listed during conversion.
+     */
+    @Column('integer', { name: 'num_files_in_root', nullable: true })
+    public numFilesInRoot!: number | null
+
+    /** A map from a language (identified by file extension) to the number of documents in the dump. */
+    @Column('jsonb', { name: 'documents_by_language' })
+    public documentsByLanguage!: { [language: string]: number }
+}
+
+/**
+ * An entity within Postgres. This records an event in the lifecycle of an upload, such as a
+ * state transition or a deletion, along with what caused it. Events are kept after the upload
+ * is removed so that the removal can be audited.
+ */
+@Entity({ name: 'lsif_upload_events' })
+export class LsifUploadEvent {
+    /** A unique ID required by typeorm entities. */
+    @PrimaryGeneratedColumn('increment', { type: 'int' })
+    public id!: number
+
+    /** The identifier of the upload. This is not a foreign key, as the upload may no longer exist. */
+    @Column('integer', { name: 'upload_id' })
+    public uploadId!: DumpId
+
+    /** The kind of event. */
+    @Column('text')
+    public event!: LsifUploadEventType
+
+    /** The state of the upload after the event, or null if the upload was removed. */
+    @Column('text', { nullable: true })
+    public state!: LsifUploadState | null
+
+    /** The component that caused the event: `api`, `worker`, or `janitor`. */
+    @Column('text')
+    public source!: string
+
+    /** The user on whose behalf the API request that caused the event was made (if known). */
+    @Column('text', { nullable: true })
+    public actor!: string | null
+
+    /** A human-readable description of the reason for the event (if any). */
+    @Column('text', { nullable: true })
+    public message!: string | null
+
+    /** The time the event occurred. */
+    @Column('timestamp with time zone', { name: 'created_at' })
+    public createdAt!: Date
+}
+
 /** The entities composing the Postgres database models. */
-export const entities = [LsifUpload, Commit, LsifDump, PackageModel, ReferenceModel]
+export const entities = [LsifUpload, Commit, LsifDump, PackageModel, ReferenceModel, DumpStatistics, LsifUploadEvent]
@@ -75,7 +75,8 @@ export function visibleDumps(limit: number = MAX_TRAVERSAL_LIMIT): string {
 /**
  * Return a set of CTE definitions assuming the definition of a previous CTE named `lineage`.
  * This creates the CTE `lineage_with_dumps`, which gathers the set of LSIF dump identifiers
- * whose commit occurs in `lineage` (within the given traversal limit).
+ * whose commit occurs in `lineage` (within the given traversal limit) and which have not
+ * been excluded.
  *
  * @param limit The maximum number of dumps that can be extracted from `lineage`.
  */
@@ -86,10 +87,12 @@ function lineageWithDumps(limit: number = MAX_TRAVERSAL_LIMIT): string {
         limited_lineage AS (
             SELECT a.*, row_number() OVER() as n from lineage a LIMIT ${limit}
         ),
-        -- Correlate commits to dumps and filter out commits without LSIF data
+        -- Correlate commits to dumps and filter out commits without LSIF data. Dumps
+        -- that have been excluded by an operator are never visible.
         lineage_with_dumps AS (
             SELECT a.*, d.root, d.indexer, d.id as dump_id FROM limited_lineage a
             JOIN lsif_dumps d ON d.repository_id = a.repository_id AND d."commit" = a."commit"
+            WHERE NOT d.excluded
         )
     `
 }
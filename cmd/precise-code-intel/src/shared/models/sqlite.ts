@@ -1,4 +1,5 @@
 import * as lsif from 'lsif-protocol'
+import * as lsp from 'vscode-languageserver-protocol'
 import { Column, Entity, Index, PrimaryColumn } from 'typeorm'
 import { calcSqliteBatchSize } from './util'
 
@@ -7,12 +8,17 @@ export type DocumentPath = string
 export type RangeId = lsif.Id
 export type DefinitionResultId = lsif.Id
 export type ReferenceResultId = lsif.Id
-export type DefinitionReferenceResultId = DefinitionResultId | ReferenceResultId
+export type ImplementationResultId = lsif.Id
+export type DefinitionReferenceResultId = DefinitionResultId | ReferenceResultId | ImplementationResultId
 export type HoverResultId = lsif.Id
 export type MonikerId = lsif.Id
 export type PackageInformationId = lsif.Id
+export type DiagnosticResultId = lsif.Id
 
-/** A type that describes a gzipped and JSON-encoded value of type `T`. */
+/**
+ * A type that describes an encoded value of type `T`. The encoding is given by the
+ * `payloadEncoding` column of the meta row of the database.
+ */
 export type JSONEncoded<T> = Buffer
 
 /**
@@ -22,6 +28,13 @@ export type JSONEncoded<T> = Buffer
  */
 export type HashMod<T, U> = number
 
+/**
+ * The version of the schema of newly written databases. Databases written before schema
+ * versions were recorded have no `schemaVersion` column and are at version 1. Increasing
+ * this requires a migration in the bundle manager that brings existing databases up to date.
+ */
+export const CURRENT_SCHEMA_VERSION = 3
+
 /**
  * An entity within the database describing LSIF data for a single repository
  * and commit pair. There should be only one metadata entity per database.
@@ -29,7 +42,7 @@ export type HashMod<T, U> = number
 @Entity({ name: 'meta' })
 export class MetaModel {
     /** The number of model instances that can be inserted at once. */
-    public static BatchSize = calcSqliteBatchSize(4)
+    public static BatchSize = calcSqliteBatchSize(6)
 
     /** A unique ID required by typeorm entities: always zero here. */
     @PrimaryColumn('int')
@@ -51,6 +64,21 @@ export class MetaModel {
      */
     @Column('int')
     public numResultChunks!: number
+
+    /**
+     * The version of the schema of this database. This column is not selected by default
+     * so that databases written before it existed can be read until they are migrated.
+     */
+    @Column('int', { select: false })
+    public schemaVersion!: number
+
+    /**
+     * The encoding of the document and result chunk payloads of this database. This column
+     * is not selected by default so that databases written before it existed can be read
+     * until they are migrated.
+     */
+    @Column('text', { select: false, default: 'gzip-json' })
+    public payloadEncoding!: string
 }
 
 /**
@@ -154,6 +182,57 @@ export class DefinitionModel extends Symbols {}
 @Index(['scheme', 'identifier'])
 export class ReferenceModel extends Symbols {}
 
+/**
+ * An entity within the database describing LSIF data for a single repository and commit
+ * pair. This contains a single diagnostic reported by the indexer for a document.
+ */
+@Entity({ name: 'diagnostics' })
+@Index(['documentPath'])
+export class DiagnosticModel {
+    /** The number of model instances that can be inserted at once. */
+    public static BatchSize = calcSqliteBatchSize(10)
+
+    /** A unique ID required by typeorm entities. */
+    @PrimaryColumn('int')
+    public id!: number
+
+    /** The path of the document to which this diagnostic belongs. */
+    @Column('text')
+    public documentPath!: DocumentPath
+
+    /** The severity of the diagnostic (1 = error, 2 = warning, 3 = information, 4 = hint). */
+    @Column('int', { nullable: true })
+    public severity!: number | null
+
+    /** The indexer-specific code of the diagnostic. */
+    @Column('text', { nullable: true })
+    public code!: string | null
+
+    /** The diagnostic message. */
+    @Column('text')
+    public message!: string
+
+    /** The tool that produced the diagnostic (e.g. the compiler name). */
+    @Column('text', { nullable: true })
+    public source!: string | null
+
+    /** The zero-indexed line describing the start of this range. */
+    @Column('int')
+    public startLine!: number
+
+    /** The zero-indexed line describing the end of this range. */
+    @Column('int')
+    public endLine!: number
+
+    /** The zero-indexed line describing the start of this range. */
+    @Column('int')
+    public startCharacter!: number
+
+    /** The zero-indexed line describing the end of this range. */
+    @Column('int')
+    public endCharacter!: number
+}
+
 /**
  * Data for a single document within an LSIF dump. The data here can answer definitions,
  * references, and hover queries if the results are all contained within the same document.
@@ -218,9 +297,9 @@ export interface ResultChunkData {
     documentPaths: Map<DocumentId, DocumentPath>
 
     /**
-     * A map from definition or reference result identifiers to the ranges that
-     * compose the result set. Each range is paired with the identifier of the
-     * document in which it can be found.
+     * A map from definition, reference, or implementation result identifiers to the
+     * ranges that compose the result set. Each range is paired with the identifier of
+     * the document in which it can be found.
      */
     documentIdRangeIds: Map<DefinitionReferenceResultId, DocumentIdRangeId[]>
 }
@@ -258,6 +337,13 @@ export interface RangeData {
      */
     referenceResultId?: ReferenceResultId
 
+    /**
+     * The identifier of the implementation result attached to this range, if one exists.
+     * The implementation result object can be queried by its identifier within the containing
+     * document.
+     */
+    implementationResultId?: ImplementationResultId
+
     /**
      * The identifier of the hover result attached to this range, if one exists. The
      * hover result object can be queried by its identifier within the containing
@@ -271,6 +357,57 @@ export interface RangeData {
      * containing document.
      */
     monikerIds: Set<MonikerId>
+
+    /**
+     * The symbol declared or defined by this range, if the indexer tagged the
+     * range vertex with a declaration or definition tag.
+     */
+    tag?: SymbolTagData
+}
+
+/** Data about the symbol declared or defined at a range. */
+export interface SymbolTagData {
+    /** The name of the symbol. */
+    text: string
+
+    /** The kind of the symbol. */
+    kind: lsp.SymbolKind
+
+    /** The range enclosing the entire symbol declaration or definition. */
+    fullRange: lsp.Range
+}
+
+/** An entry of the symbol outline of a document. */
+export interface DocumentSymbolData {
+    /** The name of the symbol. */
+    name: string
+
+    /** The kind of the symbol, if known. */
+    kind: lsp.SymbolKind | null
+
+    /** The range enclosing the symbol. */
+    range: lsp.Range
+
+    /** The name of the symbol containing this symbol, if one exists. */
+    container: string | null
+}
+
+/** The code intelligence available for a range of a document. */
+export interface RangeSummaryData {
+    /** The range. */
+    range: lsp.Range
+
+    /** Whether the range has a definition result. */
+    hasDefinitions: boolean
+
+    /** Whether the range has a reference result. */
+    hasReferences: boolean
+
+    /** Whether the range has an implementation result. */
+    hasImplementations: boolean
+
+    /** Whether the range has a hover result. */
+    hasHover: boolean
 }
 
 /** Data about a moniker attached to a range. */
@@ -301,5 +438,26 @@ export interface PackageInformationData {
     version: string | null
 }
 
+/** A diagnostic reported by the indexer for a document of an LSIF dump. */
+export interface DiagnosticData {
+    /** The path of the document to which this diagnostic belongs. */
+    path: DocumentPath
+
+    /** The range of the diagnostic. */
+    range: lsp.Range
+
+    /** The severity of the diagnostic (1 = error, 2 = warning, 3 = information, 4 = hint). */
+    severity: number | null
+
+    /** The indexer-specific code of the diagnostic. */
+    code: string | null
+
+    /** The diagnostic message. */
+    message: string
+
+    /** The tool that produced the diagnostic (e.g. the compiler name). */
+    source: string | null
+}
+
 /** The entities composing the SQLite database models. */
-export const entities = [DefinitionModel, DocumentModel, MetaModel, ReferenceModel, ResultChunkModel]
+export const entities = [DefinitionModel, DiagnosticModel, DocumentModel, MetaModel, ReferenceModel, ResultChunkModel]
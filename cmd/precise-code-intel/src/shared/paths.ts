@@ -1,6 +1,7 @@
 import * as constants from './constants'
 import * as fs from 'mz/fs'
 import * as path from 'path'
+import { mapConcurrently } from './util'
 
 /**
  * Construct the path of the SQLite database file for the given dump.
@@ -22,6 +23,34 @@ export function uploadFilename(storageRoot: string, id: number): string {
     return path.join(storageRoot, constants.UPLOADS_DIR, `${id}.lsif.gz`)
 }
 
+/**
+ * Construct the path of a single chunk of the raw upload file for the given identifier.
+ * Chunks are concatenated into the file returned by `uploadFilename` once all have been
+ * received.
+ *
+ * @param storageRoot The path where uploads are stored.
+ * @param id The identifier of the upload.
+ * @param index The index of the chunk.
+ */
+export function uploadChunkFilename(storageRoot: string, id: number, index: number): string {
+    return path.join(storageRoot, constants.UPLOADS_DIR, `${id}.${index}.lsif.gz.part`)
+}
+
+/**
+ * Returns the index of the upload chunk file if it belongs to the given upload.
+ *
+ * @param basename The basename of a file in the uploads directory.
+ * @param id The identifier of the upload.
+ */
+export function uploadChunkIndexFromFilename(basename: string, id: number): number | undefined {
+    const match = basename.match(/^([0-9]+)\.([0-9]+)\.lsif\.gz\.part$/)
+    if (match && parseInt(match[1], 10) === id) {
+        return parseInt(match[2], 10)
+    }
+
+    return undefined
+}
+
 /**
  * Returns the identifier of the database file. Handles both of the
  * following formats:
@@ -54,3 +83,48 @@ export async function ensureDirectory(directoryPath: string): Promise<void> {
         }
     }
 }
+
+/**
+ * Calculate the cumulative size of all plain files in a directory, non-recursively.
+ *
+ * @param directory The directory path.
+ * @param concurrency The maximum number of files to stat at once.
+ */
+export async function dirsize(directory: string, concurrency = 100): Promise<number> {
+    return (
+        await mapConcurrently(await fs.readdir(directory), concurrency, filename =>
+            filesize(path.join(directory, filename))
+        )
+    ).reduce((a, b) => a + b, 0)
+}
+
+/**
+ * Get the file size or zero if it doesn't exist.
+ *
+ * @param filename The filename.
+ */
+export async function filesize(filename: string): Promise<number> {
+    try {
+        return (await fs.stat(filename)).size
+    } catch (error) {
+        if (!(error && error.code === 'ENOENT')) {
+            throw error
+        }
+
+        return 0
+    }
+}
+
+/**
+ * Flush the given file or directory to disk.
+ *
+ * @param filename The path of the file or directory.
+ */
+export async function fsyncPath(filename: string): Promise<void> {
+    const fd = await fs.open(filename, 'r')
+    try {
+        await fs.fsync(fd)
+    } finally {
+        await fs.close(fd)
+    }
+}
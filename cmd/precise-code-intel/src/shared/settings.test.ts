@@ -0,0 +1,76 @@
+import { deprecatedEnvUses, parseDuration, readEnvInt, readEnvIntWithDeprecatedFallback } from './settings'
+
+describe('readEnvInt', () => {
+    const key = 'TEST_READ_ENV_INT'
+
+    afterEach(() => {
+        delete process.env[key]
+    })
+
+    it('should default when unset or empty', () => {
+        expect(readEnvInt(key, 5)).toEqual(5)
+        process.env[key] = ''
+        expect(readEnvInt(key, 5)).toEqual(5)
+    })
+
+    it('should keep zero and negative values', () => {
+        process.env[key] = '0'
+        expect(readEnvInt(key, 5)).toEqual(0)
+        process.env[key] = '-1'
+        expect(readEnvInt(key, 5)).toEqual(-1)
+    })
+
+    it('should reject malformed integers', () => {
+        process.env[key] = 'ten'
+        expect(() => readEnvInt(key, 5)).toThrow()
+        process.env[key] = '1.5'
+        expect(() => readEnvInt(key, 5)).toThrow()
+    })
+})
+
+describe('readEnvIntWithDeprecatedFallback', () => {
+    const key = 'TEST_READ_ENV_INT'
+    const deprecatedKey = 'TEST_READ_ENV_INT_DEPRECATED'
+
+    afterEach(() => {
+        delete process.env[key]
+        delete process.env[deprecatedKey]
+    })
+
+    it('should prefer the current name', () => {
+        process.env[key] = '1'
+        process.env[deprecatedKey] = '2'
+        expect(readEnvIntWithDeprecatedFallback(key, deprecatedKey, 5)).toEqual(1)
+        expect(deprecatedEnvUses).toEqual([])
+    })
+
+    it('should fall back to the deprecated name', () => {
+        process.env[deprecatedKey] = '2'
+        expect(readEnvIntWithDeprecatedFallback(key, deprecatedKey, 5)).toEqual(2)
+        expect(deprecatedEnvUses).toEqual([{ key, deprecatedKey }])
+        delete process.env[deprecatedKey]
+        expect(readEnvIntWithDeprecatedFallback(key, deprecatedKey, 5)).toEqual(5)
+    })
+})
+
+describe('parseDuration', () => {
+    it('should parse a number of seconds', () => {
+        expect(parseDuration('0')).toEqual(0)
+        expect(parseDuration('90')).toEqual(90)
+    })
+
+    it('should parse durations with units', () => {
+        expect(parseDuration('1500ms')).toEqual(1.5)
+        expect(parseDuration('45s')).toEqual(45)
+        expect(parseDuration('2.5m')).toEqual(150)
+        expect(parseDuration('1h30m')).toEqual(5400)
+    })
+
+    it('should reject malformed durations', () => {
+        expect(parseDuration('')).toBeUndefined()
+        expect(parseDuration('-5s')).toBeUndefined()
+        expect(parseDuration('5 s')).toBeUndefined()
+        expect(parseDuration('5d')).toBeUndefined()
+        expect(parseDuration('s')).toBeUndefined()
+    })
+})
@@ -1,9 +1,112 @@
 /**
- * Reads an integer from an environment variable or defaults to the given value.
+ * Reads an integer from an environment variable or defaults to the given value when the
+ * variable is unset or empty. An explicit zero is kept. Throws if the value is not an integer,
+ * so that a misconfigured process fails at startup.
  *
  * @param key The environment variable name.
  * @param defaultValue The default value.
  */
 export function readEnvInt(key: string, defaultValue: number): number {
-    return (process.env[key] && parseInt(process.env[key] || '', 10)) || defaultValue
+    const value = process.env[key]
+    if (value === undefined || isUnset(value)) {
+        return defaultValue
+    }
+
+    if (!/^\s*-?\d+\s*$/.test(value)) {
+        throw new Error(`Invalid integer for ${key}: ${JSON.stringify(value)}`)
+    }
+
+    return parseInt(value, 10)
+}
+
+/**
+ * Returns true if an environment variable is unset or empty.
+ *
+ * @param value The value of the environment variable.
+ */
+function isUnset(value: string | undefined): boolean {
+    return value === undefined || value.trim() === ''
+}
+
+/** The deprecated environment variables that were read in place of the ones that replace them. */
+export const deprecatedEnvUses: { key: string; deprecatedKey: string }[] = []
+
+/**
+ * Reads an integer from an environment variable, falling back to a deprecated environment
+ * variable and then to the given value. A use of the deprecated variable is recorded in
+ * `deprecatedEnvUses` so that it can be reported once a logger exists.
+ *
+ * @param key The environment variable name.
+ * @param deprecatedKey The name of the environment variable replaced by `key`.
+ * @param defaultValue The default value.
+ */
+export function readEnvIntWithDeprecatedFallback(key: string, deprecatedKey: string, defaultValue: number): number {
+    if (isUnset(process.env[key]) && !isUnset(process.env[deprecatedKey])) {
+        deprecatedEnvUses.push({ key, deprecatedKey })
+        return readEnvInt(deprecatedKey, defaultValue)
+    }
+
+    return readEnvInt(key, defaultValue)
+}
+
+/**
+ * Reads a boolean from an environment variable or defaults to the given value. The values
+ * `true` and `1` (case-insensitive) are truthy, and any other non-empty value is falsy.
+ *
+ * @param key The environment variable name.
+ * @param defaultValue The default value.
+ */
+export function readEnvBool(key: string, defaultValue: boolean): boolean {
+    const value = process.env[key]
+    return value ? ['true', '1'].includes(value.toLowerCase()) : defaultValue
+}
+
+/**
+ * Reads a duration (in seconds) from an environment variable or defaults to the given value.
+ * See `parseDuration` for the accepted formats. Throws if the value cannot be parsed, so that
+ * a misconfigured process fails at startup.
+ *
+ * @param key The environment variable name.
+ * @param defaultValue The default value (in seconds).
+ */
+export function readEnvDuration(key: string, defaultValue: number): number {
+    const value = process.env[key]
+    if (!value) {
+        return defaultValue
+    }
+
+    const duration = parseDuration(value)
+    if (duration === undefined) {
+        throw new Error(`Invalid duration for ${key}: ${JSON.stringify(value)}`)
+    }
+
+    return duration
+}
+
+/** The number of milliseconds in each unit accepted by `parseDuration`. */
+const durationUnits: { [unit: string]: number } = { ms: 1, s: 1000, m: 60 * 1000, h: 60 * 60 * 1000 }
+
+/**
+ * Parse a duration into a number of seconds. The duration is either a number of seconds or a
+ * sequence of numbers with a unit suffix (`ms`, `s`, `m`, or `h`) such as `90s` or `1h30m`, as
+ * accepted by Go's `time.ParseDuration`. Returns undefined if the duration cannot be parsed.
+ *
+ * @param value The duration.
+ */
+export function parseDuration(value: string): number | undefined {
+    if (/^\d+$/.test(value)) {
+        return parseInt(value, 10)
+    }
+
+    if (!/^(\d+(\.\d+)?(ms|s|m|h))+$/.test(value)) {
+        return undefined
+    }
+
+    let milliseconds = 0
+    const pattern = /(\d+(?:\.\d+)?)(ms|s|m|h)/g
+    for (let match = pattern.exec(value); match; match = pattern.exec(value)) {
+        milliseconds += parseFloat(match[1]) * durationUnits[match[2]]
+    }
+
+    return milliseconds / 1000
 }
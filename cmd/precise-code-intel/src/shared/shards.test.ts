@@ -0,0 +1,55 @@
+import { parseShardUrls, ShardRing } from './shards'
+import { range } from 'lodash'
+
+describe('parseShardUrls', () => {
+    it('should normalize and deduplicate urls', () => {
+        expect(parseShardUrls(' http://a:3187/, http://b:3187,,http://a:3187 ')).toEqual([
+            'http://a:3187',
+            'http://b:3187',
+        ])
+        expect(parseShardUrls('')).toEqual([])
+    })
+})
+
+describe('ShardRing', () => {
+    const ids = range(1, 5001)
+
+    it('should assign every dump to a single shard', () => {
+        const ring = new ShardRing(['http://a'])
+        expect(ids.every(id => ring.shardFor(id) === 'http://a')).toBeTruthy()
+    })
+
+    it('should not depend on the order of the urls', () => {
+        const ring1 = new ShardRing(['http://a', 'http://b', 'http://c'])
+        const ring2 = new ShardRing(['http://c', 'http://a', 'http://b'])
+        expect(ids.map(id => ring1.shardFor(id))).toEqual(ids.map(id => ring2.shardFor(id)))
+    })
+
+    it('should spread dumps across shards', () => {
+        const ring = new ShardRing(['http://a', 'http://b', 'http://c'])
+
+        const counts = new Map<string, number>()
+        for (const id of ids) {
+            const url = ring.shardFor(id)
+            counts.set(url, (counts.get(url) || 0) + 1)
+        }
+
+        for (const url of ring.urls) {
+            // Each shard should receive roughly a third of the dumps
+            expect(counts.get(url)).toBeGreaterThan(ids.length / 5)
+        }
+    })
+
+    it('should only move dumps onto an added shard', () => {
+        const ring1 = new ShardRing(['http://a', 'http://b', 'http://c'])
+        const ring2 = new ShardRing(['http://a', 'http://b', 'http://c', 'http://d'])
+
+        const moved = ids.filter(id => ring1.shardFor(id) !== ring2.shardFor(id))
+        expect(moved.every(id => ring2.shardFor(id) === 'http://d')).toBeTruthy()
+        expect(moved.length).toBeLessThan(ids.length / 2)
+    })
+
+    it('should require a shard', () => {
+        expect(() => new ShardRing([])).toThrow()
+    })
+})
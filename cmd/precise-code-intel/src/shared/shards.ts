@@ -0,0 +1,90 @@
+import * as crypto from 'crypto'
+
+/**
+ * The number of points each shard occupies on the hash ring. More points spread the dumps
+ * more evenly across shards at the cost of a larger ring. This must match `PointsPerShard`
+ * in the Go worker's `internal/shards` package.
+ */
+const POINTS_PER_SHARD = 128
+
+/**
+ * Parse a comma-separated list of bundle manager URLs. Whitespace around each URL and empty
+ * entries are ignored, and trailing slashes are removed so that each shard has a single
+ * canonical URL.
+ *
+ * @param value The comma-separated list.
+ */
+export function parseShardUrls(value: string): string[] {
+    return Array.from(
+        new Set(
+            value
+                .split(',')
+                .map(url => url.trim().replace(/\/+$/, ''))
+                .filter(url => url !== '')
+        )
+    )
+}
+
+/**
+ * A consistent hash ring that assigns each dump to one of a set of bundle manager shards.
+ * Adding a shard to the ring only moves dumps onto the new shard, and removing a shard only
+ * moves the dumps that were assigned to it, so the number of bundles that must be migrated
+ * when the set of shards changes is proportional to the share of the changed shard.
+ */
+export class ShardRing {
+    /** The points of each shard on the ring, ordered by hash. */
+    private points: { hash: number; url: string }[] = []
+
+    /**
+     * Create a new `ShardRing`.
+     *
+     * @param urls The URLs of the bundle manager shards.
+     */
+    constructor(public readonly urls: string[]) {
+        if (urls.length === 0) {
+            throw new Error('At least one bundle manager URL is required')
+        }
+
+        for (const url of urls) {
+            for (let i = 0; i < POINTS_PER_SHARD; i++) {
+                this.points.push({ hash: hash(`${url}#${i}`), url })
+            }
+        }
+
+        // Break ties by URL so that the ring does not depend on the order of the URLs
+        this.points.sort((a, b) => a.hash - b.hash || (a.url < b.url ? -1 : a.url > b.url ? 1 : 0))
+    }
+
+    /**
+     * Return the URL of the shard that stores the bundle of the given dump. This is the
+     * first point on the ring at or after the hash of the dump identifier.
+     *
+     * @param id The identifier of the dump.
+     */
+    public shardFor(id: number): string {
+        const target = hash(String(id))
+
+        let lo = 0
+        let hi = this.points.length
+        while (lo < hi) {
+            const mid = (lo + hi) >>> 1
+            if (this.points[mid].hash < target) {
+                lo = mid + 1
+            } else {
+                hi = mid
+            }
+        }
+
+        // Wrap around to the first point
+        return this.points[lo % this.points.length].url
+    }
+}
+
+/**
+ * Hash the given value to an unsigned 32-bit integer.
+ *
+ * @param value The value to hash.
+ */
+function hash(value: string): number {
+    return crypto.createHash('md5').update(value).digest().readUInt32BE(0)
+}
@@ -0,0 +1,60 @@
+import * as http from 'http'
+import { Logger } from 'winston'
+
+/**
+ * Register a function to invoke on the first SIGINT or SIGTERM received by the process. The
+ * process exits once the shutdown function completes or the given timeout elapses, whichever
+ * happens first. A second signal received during shutdown exits the process immediately.
+ *
+ * @param logger The logger instance.
+ * @param timeoutMs The maximum time to wait for the shutdown function to complete.
+ * @param shutdown The function that drains in-flight work and releases resources.
+ */
+export function onShutdown(logger: Logger, timeoutMs: number, shutdown: () => Promise<void>): void {
+    let shuttingDown = false
+
+    const exit = (code: number): void => {
+        // Flush the logger before exiting
+        logger.on('finish', () => process.exit(code))
+        logger.end()
+    }
+
+    const handler = (signal: NodeJS.Signals): void => {
+        if (shuttingDown) {
+            logger.warn('Received second signal during shutdown, exiting immediately', { signal })
+            process.exit(1)
+        }
+
+        shuttingDown = true
+        logger.info('Shutting down', { signal })
+
+        const timeout = new Promise<boolean>(resolve => setTimeout(() => resolve(false), timeoutMs).unref())
+
+        Promise.race([shutdown().then(() => true), timeout]).then(
+            completed => {
+                if (!completed) {
+                    logger.warn('Timed out waiting for in-flight work to complete', { timeoutMs })
+                }
+
+                exit(completed ? 0 : 1)
+            },
+            error => {
+                logger.error('Failed to shut down cleanly', { error })
+                exit(1)
+            }
+        )
+    }
+
+    process.on('SIGINT', handler)
+    process.on('SIGTERM', handler)
+}
+
+/**
+ * Stop accepting new connections on the given server. The returned promise resolves once
+ * all in-flight requests have completed and all open connections have closed.
+ *
+ * @param server The HTTP server.
+ */
+export function closeServer(server: http.Server): Promise<void> {
+    return new Promise((resolve, reject) => server.close(error => (error ? reject(error) : resolve())))
+}
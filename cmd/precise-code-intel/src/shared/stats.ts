@@ -0,0 +1,98 @@
+import { sum } from 'lodash'
+
+/** The number of entries and the current and maximum size of an in-memory cache. */
+export interface CacheOccupancy {
+    /** The number of keys in the cache. */
+    entries: number
+
+    /** The additive size of the values in the cache. */
+    size: number
+
+    /** The (soft) maximum size of the cache. */
+    max: number
+}
+
+/**
+ * The progress of the background migration of bundles to the current schema version. Only
+ * bundles that have been checked by the migration are counted.
+ */
+export interface BundleMigrationProgress {
+    /** The schema version that bundles are migrated to. */
+    currentVersion: number
+
+    /** The number of bundles known to be at the current schema version. */
+    numCurrent: number
+
+    /** The number of bundles known to be at an older schema version. */
+    numOutdated: number
+
+    /** The number of bundles migrated since startup. */
+    numMigrated: number
+
+    /** The number of outdated bundles whose last migration attempt failed. */
+    numFailed: number
+}
+
+/** The disk and cache usage of a bundle manager, as served by its `/stats` endpoint. */
+export interface BundleManagerStats {
+    /** The number of processed bundles in the dbs directory. */
+    numBundles: number
+
+    /** The number of bytes used by the uploads and dbs directories. */
+    totalBytes: number
+
+    /** The number of bytes used by raw uploads and upload chunks. */
+    uploadsBytes: number
+
+    /** The number of bytes used by processed bundles. */
+    dbsBytes: number
+
+    /** The percentage of the storage root's filesystem that is available. */
+    freeSpacePercent: number
+
+    /** The occupancy of each of the in-memory caches. */
+    caches: {
+        connections: CacheOccupancy
+        documents: CacheOccupancy
+        resultChunks: CacheOccupancy
+    }
+
+    /** The progress of the background migration of bundles. */
+    migrations: BundleMigrationProgress
+}
+
+/**
+ * Combine the disk and cache usage of several bundle manager shards. Sizes and counts are
+ * summed, and the free space is reported for the shard with the least free space, as that
+ * is the shard that will run out of space first.
+ *
+ * @param stats The stats of each shard.
+ */
+export function combineBundleManagerStats(stats: BundleManagerStats[]): BundleManagerStats {
+    const combineCaches = (caches: CacheOccupancy[]): CacheOccupancy => ({
+        entries: sum(caches.map(cache => cache.entries)),
+        size: sum(caches.map(cache => cache.size)),
+        max: sum(caches.map(cache => cache.max)),
+    })
+
+    return {
+        numBundles: sum(stats.map(s => s.numBundles)),
+        totalBytes: sum(stats.map(s => s.totalBytes)),
+        uploadsBytes: sum(stats.map(s => s.uploadsBytes)),
+        dbsBytes: sum(stats.map(s => s.dbsBytes)),
+        freeSpacePercent: Math.min(...stats.map(s => s.freeSpacePercent)),
+        caches: {
+            connections: combineCaches(stats.map(s => s.caches.connections)),
+            documents: combineCaches(stats.map(s => s.caches.documents)),
+            resultChunks: combineCaches(stats.map(s => s.caches.resultChunks)),
+        },
+        migrations: {
+            // Shards may run different versions during a deployment
+            currentVersion: Math.min(...stats.map(s => s.migrations.currentVersion)),
+            numCurrent: sum(stats.map(s => s.migrations.numCurrent)),
+            numOutdated: sum(stats.map(s => s.migrations.numOutdated)),
+            numMigrated: sum(stats.map(s => s.migrations.numMigrated)),
+            numFailed: sum(stats.map(s => s.migrations.numFailed)),
+        },
+    }
+}
@@ -3,7 +3,7 @@ import * as pgModels from '../models/pg'
 import { Connection } from 'typeorm'
 import { fail } from 'assert'
 import { DumpManager } from './dumps'
-import { DependencyManager } from './dependencies'
+import { DependencyManager, Package, SymbolReferences } from './dependencies'
 
 describe('DependencyManager', () => {
     let connection!: Connection
@@ -13,6 +13,7 @@ describe('DependencyManager', () => {
 
     const repositoryId1 = 100
     const repositoryId2 = 101
+    const repositoryId3 = 102
 
     beforeAll(async () => {
         ;({ connection, cleanup } = await util.createCleanPostgresDatabase())
@@ -169,6 +170,51 @@ describe('DependencyManager', () => {
         expect(packageReferences.map(packageReference => packageReference.dump_id).sort()).toEqual(dumps)
     })
 
+    it('should return total count of all package reference pages', async () => {
+        if (!dependencyManager) {
+            fail('failed beforeAll')
+        }
+
+        for (let i = 0; i < 6; i++) {
+            const dump = await util.insertDump(
+                connection,
+                dumpManager,
+                repositoryId1,
+                util.createCommit(),
+                `r${i}`,
+                'test'
+            )
+            await dependencyManager.addPackagesAndReferences(
+                dump.id,
+                [],
+                [{ package: { scheme: 'npm', name: 'p1', version: '0.1.0' }, identifiers: ['y'] }]
+            )
+
+            // The last dump is not visible at tip and is not counted
+            dump.visibleAtTip = i < 5
+            await connection.getRepository(pgModels.LsifUpload).save(dump)
+        }
+
+        const getPage = (offset: number) =>
+            dependencyManager.getPackageReferences({
+                repositoryId: repositoryId2,
+                scheme: 'npm',
+                name: 'p1',
+                version: '0.1.0',
+                identifier: 'y',
+                limit: 2,
+                offset,
+            })
+
+        const { packageReferences, totalCount } = await getPage(0)
+        expect(packageReferences).toHaveLength(2)
+        expect(totalCount).toEqual(5)
+
+        const { packageReferences: lastPage, totalCount: lastPageTotalCount } = await getPage(4)
+        expect(lastPage).toHaveLength(1)
+        expect(lastPageTotalCount).toEqual(5)
+    })
+
     it('references only returned if dumps visible at tip', async () => {
         if (!dependencyManager) {
             fail('failed beforeAll')
@@ -233,4 +279,331 @@ describe('DependencyManager', () => {
         await updateVisibility(false, false, false)
         expect(await getReferencedDumpIds()).toEqual([])
     })
+
+    it('should interleave references from different repositories', async () => {
+        if (!dependencyManager) {
+            fail('failed beforeAll')
+        }
+
+        const references = [{ package: { scheme: 'npm', name: 'p1', version: '0.1.0' }, identifiers: ['y'] }]
+
+        const insertVisibleDump = async (repositoryId: number, root: string): Promise<number> => {
+            const dump = await util.insertDump(connection, dumpManager, repositoryId, util.createCommit(), root, 'test')
+            dump.visibleAtTip = true
+            await connection.getRepository(pgModels.LsifUpload).save(dump)
+            await dependencyManager.addPackagesAndReferences(dump.id, [], references)
+            return dump.id
+        }
+
+        const dumpa = await insertVisibleDump(repositoryId1, 'a/')
+        const dumpb = await insertVisibleDump(repositoryId1, 'b/')
+        const dumpc = await insertVisibleDump(repositoryId1, 'c/')
+        const dumpd = await insertVisibleDump(repositoryId3, 'b/')
+
+        const getReferencedDumpIds = async (offset: number) =>
+            (
+                await dependencyManager.getPackageReferences({
+                    repositoryId: repositoryId2,
+                    scheme: 'npm',
+                    name: 'p1',
+                    version: '0.1.0',
+                    identifier: 'y',
+                    limit: 2,
+                    offset,
+                })
+            ).packageReferences.map(packageReference => packageReference.dump_id)
+
+        expect(await getReferencedDumpIds(0)).toEqual([dumpa, dumpd])
+        expect(await getReferencedDumpIds(2)).toEqual([dumpb, dumpc])
+    })
+
+    it('should return same-repo references visible from the target commit', async () => {
+        if (!dependencyManager) {
+            fail('failed beforeAll')
+        }
+
+        // This database has the following commit graph:
+        //
+        // a -- b -- c -- d
+        //       \
+        //        +-- e
+
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+        const cc = util.createCommit()
+        const cd = util.createCommit()
+        const ce = util.createCommit()
+
+        await dumpManager.updateCommits(
+            repositoryId1,
+            new Map<string, Set<string>>([
+                [ca, new Set()],
+                [cb, new Set([ca])],
+                [cc, new Set([cb])],
+                [cd, new Set([cc])],
+                [ce, new Set([cb])],
+            ])
+        )
+
+        const addReferences = async (
+            repositoryId: number,
+            commit: string,
+            root: string,
+            identifiers: string[],
+            version: string | null = '0.1.0'
+        ): Promise<pgModels.LsifDump> => {
+            const dump = await util.insertDump(connection, dumpManager, repositoryId, commit, root, 'test')
+            await dependencyManager.addPackagesAndReferences(
+                dump.id,
+                [],
+                [{ package: { scheme: 'npm', name: 'p1', version }, identifiers }]
+            )
+            return dump
+        }
+
+        const dump1 = await addReferences(repositoryId1, ca, 'a/', ['x', 'y'])
+        const dump2 = await addReferences(repositoryId1, cb, 'b/', ['y'])
+        await addReferences(repositoryId1, cb, 'c/', ['z'])
+        const dump3 = await addReferences(repositoryId1, cc, 'd/', ['y', 'z'])
+        const dump4 = await addReferences(repositoryId1, cd, 'e/', ['y'])
+        const dump5 = await addReferences(repositoryId1, cd, 'f/', ['y'], null)
+
+        // Not in the lineage of the target commit
+        await addReferences(repositoryId1, ce, 'g/', ['y'])
+        // Shadowed by the dump with the same root and indexer at a closer commit
+        await addReferences(repositoryId1, ca, 'e/', ['y'])
+        // Another repository
+        await addReferences(repositoryId2, cd, 'h/', ['y'])
+
+        const getReferencedDumpIds = async (version: string | null, limit: number) => {
+            const dumpIds: number[] = []
+            let offset = 0
+            let totalCount = 0
+
+            do {
+                const result = await dependencyManager.getSameRepoRemotePackageReferences({
+                    repositoryId: repositoryId1,
+                    commit: cd,
+                    scheme: 'npm',
+                    name: 'p1',
+                    version,
+                    identifier: 'y',
+                    limit,
+                    offset,
+                })
+
+                dumpIds.push(...result.packageReferences.map(packageReference => packageReference.dump_id))
+                totalCount = result.totalCount
+                if (result.newOffset === offset) {
+                    break
+                }
+                offset = result.newOffset
+            } while (offset < totalCount)
+
+            return { dumpIds, totalCount }
+        }
+
+        // The dump without a use of the identifier is counted but filtered out of the pages
+        for (const limit of [1, 2, 50]) {
+            expect(await getReferencedDumpIds('0.1.0', limit)).toEqual({
+                dumpIds: [dump1.id, dump2.id, dump3.id, dump4.id],
+                totalCount: 5,
+            })
+        }
+
+        expect(await getReferencedDumpIds(null, 50)).toEqual({ dumpIds: [dump5.id], totalCount: 1 })
+    })
+
+    it('should return the dependencies and dependents of a dump', async () => {
+        if (!dependencyManager) {
+            fail('failed beforeAll')
+        }
+
+        const p1 = { scheme: 'npm', name: 'p1', version: '0.1.0' }
+        const p2 = { scheme: 'npm', name: 'p2', version: null }
+        const p3 = { scheme: 'npm', name: 'p3', version: '1.0.0' }
+
+        const provider1 = await util.insertDump(connection, dumpManager, repositoryId1, util.createCommit(), '', 'test')
+        const provider2 = await util.insertDump(connection, dumpManager, repositoryId2, util.createCommit(), '', 'test')
+        const consumer = await util.insertDump(connection, dumpManager, repositoryId2, util.createCommit(), '', 'test')
+
+        await dependencyManager.addPackagesAndReferences(provider1.id, [p1, p2], [])
+        await dependencyManager.addPackagesAndReferences(provider2.id, [p3], [])
+        await dependencyManager.addPackagesAndReferences(
+            consumer.id,
+            [],
+            [p2, p1, p3, { scheme: 'npm', name: 'unknown', version: null }].map(pkg => ({
+                package: pkg,
+                identifiers: ['x'],
+            }))
+        )
+
+        const { dependencies, totalCount } = await dependencyManager.getDependencies(consumer.id, 10, 0)
+        expect(totalCount).toEqual(2)
+        expect(dependencies.map(({ dump, packages }) => ({ id: dump.id, packages }))).toEqual([
+            { id: provider1.id, packages: [p1, p2] },
+            { id: provider2.id, packages: [p3] },
+        ])
+
+        const { dependencies: secondPage } = await dependencyManager.getDependencies(consumer.id, 1, 1)
+        expect(secondPage.map(({ dump }) => dump.id)).toEqual([provider2.id])
+
+        const { dependents } = await dependencyManager.getDependents(provider1.id, 10, 0)
+        expect(dependents.map(({ dump, packages }) => ({ id: dump.id, packages }))).toEqual([
+            { id: consumer.id, packages: [p1, p2] },
+        ])
+
+        // Dependents that have not completed processing are not returned
+        await connection.getRepository(pgModels.LsifUpload).update({ id: consumer.id }, { state: 'deleting' })
+        expect(await dependencyManager.getDependents(provider1.id, 10, 0)).toEqual({ dependents: [], totalCount: 0 })
+    })
+
+    it('should search packages by name prefix and similarity', async () => {
+        if (!dependencyManager) {
+            fail('failed beforeAll')
+        }
+
+        const dump1 = await util.insertDump(connection, dumpManager, repositoryId1, util.createCommit(), '', 'test')
+        const dump2 = await util.insertDump(connection, dumpManager, repositoryId2, util.createCommit(), '', 'test')
+
+        await dependencyManager.addPackagesAndReferences(
+            dump1.id,
+            [
+                { scheme: 'npm', name: 'lodash', version: '4.17.15' },
+                { scheme: 'npm', name: 'lodash.merge', version: '4.6.2' },
+                { scheme: 'npm', name: 'react', version: '16.13.1' },
+            ],
+            []
+        )
+        await dependencyManager.addPackagesAndReferences(
+            dump2.id,
+            [
+                { scheme: 'gomod', name: 'Lodash', version: 'v1.0.0' },
+                { scheme: 'npm', name: 'llodash', version: null },
+            ],
+            []
+        )
+
+        const search = async (scheme: string, query: string, limit = 10, offset = 0) => {
+            const { packages, totalCount } = await dependencyManager.searchPackages({ scheme, query, limit, offset })
+            return { names: packages.map(p => `${p.scheme}:${p.name}@${p.version || ''}`), totalCount }
+        }
+
+        // Prefix matches come before similar names, and matching ignores case
+        expect(await search('npm', 'LODASH')).toEqual({
+            names: ['npm:lodash@4.17.15', 'npm:lodash.merge@4.6.2', 'npm:llodash@'],
+            totalCount: 3,
+        })
+
+        const { names, totalCount } = await search('', 'lodash')
+        expect(names.sort()).toEqual([
+            'gomod:Lodash@v1.0.0',
+            'npm:llodash@',
+            'npm:lodash.merge@4.6.2',
+            'npm:lodash@4.17.15',
+        ])
+        expect(totalCount).toEqual(4)
+
+        // Names with a typo are still found
+        expect((await search('npm', 'reactt')).names).toEqual(['npm:react@16.13.1'])
+
+        // Pages are taken from the same ordering
+        expect(await search('npm', 'lodash', 1, 1)).toEqual({ names: ['npm:lodash.merge@4.6.2'], totalCount: 3 })
+
+        // Each package carries the dump that provides it
+        const { packages } = await dependencyManager.searchPackages({
+            scheme: 'gomod',
+            query: '',
+            limit: 10,
+            offset: 0,
+        })
+        expect(packages.map(p => p.dump.id)).toEqual([dump2.id])
+    })
+
+    it('should return the dumps that may contain a moniker', async () => {
+        if (!dependencyManager) {
+            fail('failed beforeAll')
+        }
+
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+        await dumpManager.updateCommits(repositoryId1, new Map<string, Set<string>>([[ca, new Set()]]))
+        await dumpManager.updateCommits(repositoryId2, new Map<string, Set<string>>([[cb, new Set()]]))
+
+        const addDump = async (
+            repositoryId: number,
+            commit: string,
+            root: string,
+            visibleAtTip: boolean,
+            packages: Package[],
+            symbolReferences: SymbolReferences[]
+        ): Promise<pgModels.LsifDump> => {
+            const dump = await util.insertDump(connection, dumpManager, repositoryId, commit, root, 'test')
+            dump.visibleAtTip = visibleAtTip
+            await connection.getRepository(pgModels.LsifUpload).save(dump)
+            await dependencyManager.addPackagesAndReferences(dump.id, packages, symbolReferences)
+            return dump
+        }
+
+        const p1 = { scheme: 'npm', name: 'p1', version: '0.1.0' }
+        const p2 = { scheme: 'npm', name: 'p2', version: null }
+        const p3 = { scheme: 'gomod', name: 'p3', version: 'v1.0.0' }
+
+        const dump1 = await addDump(repositoryId1, ca, 'a/', true, [p1], [
+            { package: p2, identifiers: ['y'] },
+            { package: p3, identifiers: ['x'] },
+            { package: { ...p2, name: 'p4' }, identifiers: ['x'] },
+        ])
+        const dump2 = await addDump(repositoryId2, cb, 'b/', true, [], [{ package: p2, identifiers: ['z'] }])
+        await addDump(repositoryId2, cb, 'c/', true, [p3], [{ package: p3, identifiers: ['x'] }])
+        // Not visible at tip
+        await addDump(repositoryId2, util.createCommit(), 'd/', false, [p1], [{ package: p2, identifiers: ['x'] }])
+
+        const getMonikerDumpIds = async (
+            kind: 'definition' | 'reference',
+            identifier: string,
+            scope?: { repositoryId: number; commit: string },
+            limit = 10,
+            offset = 0
+        ) => {
+            const { dumps, totalCount, newOffset } = await dependencyManager.getMonikerDumps({
+                scheme: 'npm',
+                identifier,
+                kind,
+                scope,
+                limit,
+                offset,
+            })
+
+            return { dumpIds: dumps.map(dump => dump.id), totalCount, newOffset }
+        }
+
+        // Definitions are not filtered by identifier
+        expect(await getMonikerDumpIds('definition', 'x')).toEqual({ dumpIds: [dump1.id], totalCount: 1, newOffset: 1 })
+
+        // References are filtered by the bloom filters of any package of the scheme
+        expect(await getMonikerDumpIds('reference', 'x')).toEqual({ dumpIds: [dump1.id], totalCount: 2, newOffset: 2 })
+        expect(await getMonikerDumpIds('reference', 'z')).toEqual({ dumpIds: [dump2.id], totalCount: 2, newOffset: 2 })
+
+        // Pages continue from the offset of the previous page
+        expect(await getMonikerDumpIds('reference', 'y', undefined, 1)).toEqual({
+            dumpIds: [dump1.id],
+            totalCount: 2,
+            newOffset: 1,
+        })
+        expect(await getMonikerDumpIds('reference', 'y', undefined, 1, 1)).toEqual({
+            dumpIds: [],
+            totalCount: 2,
+            newOffset: 2,
+        })
+
+        // Scoped searches only consider dumps visible from the commit
+        const scope = { repositoryId: repositoryId2, commit: cb }
+        expect(await getMonikerDumpIds('reference', 'z', scope)).toEqual({
+            dumpIds: [dump2.id],
+            totalCount: 1,
+            newOffset: 1,
+        })
+        expect(await getMonikerDumpIds('definition', 'x', scope)).toEqual({ dumpIds: [], totalCount: 0, newOffset: 0 })
+    })
 })
@@ -2,7 +2,7 @@ import * as metrics from './metrics'
 import * as sharedMetrics from '../database/metrics'
 import * as pgModels from '../models/pg'
 import { Connection, EntityManager } from 'typeorm'
-import { createFilter, testFilter } from '../datastructures/bloom-filter'
+import { createFilter, EncodedBloomFilter, testFilter } from '../datastructures/bloom-filter'
 import { instrumentQuery, withInstrumentedTransaction } from '../database/postgres'
 import { logAndTraceCall, logSpan, TracingContext } from '../tracing'
 import { TableInserter } from '../database/inserter'
@@ -38,6 +38,25 @@ export interface SymbolReferences {
     identifiers: string[]
 }
 
+/** A dump related to another dump by the packages that one provides and the other uses. */
+export interface DumpDependency {
+    /** The related dump. */
+    dump: pgModels.LsifDump
+
+    /** The packages that relate the two dumps, ordered by scheme, name, and version. */
+    packages: Package[]
+}
+
+/**
+ * The package and reference operations used to answer code intelligence queries. This is
+ * the subset of `DependencyManager` on which the api-server backend depends, so that the
+ * backend can be tested against a mock store without a Postgres connection.
+ */
+export type DependencyStore = Pick<
+    DependencyManager,
+    'getPackage' | 'getPackageReferences' | 'getSameRepoRemotePackageReferences' | 'getMonikerDumps'
+>
+
 /**
  * A wrapper around package and references tables that stitch together the references
  * between projects at a specific commit. This is used for cross-repository jump to
@@ -112,46 +131,57 @@ export class DependencyManager {
         /** The tracing context. */
         ctx?: TracingContext
     }): Promise<{ packageReferences: pgModels.ReferenceModel[]; totalCount: number; newOffset: number }> {
-        // We do this inside of a transaction so that we get consistent results from multiple
-        // distinct queries: one count query and one or more select queries, depending on the
-        // sparsity of the use of the given identifier.
-        return withInstrumentedTransaction(this.connection, async entityManager => {
-            // Create a base query that selects all active uses of the target package. This
-            // is used as the common prefix for both the count and the getPage queries.
-            const baseQuery = entityManager
-                .getRepository(pgModels.ReferenceModel)
-                .createQueryBuilder('reference')
-                .leftJoinAndSelect('reference.dump', 'dump')
-                .where({ scheme, name, version })
-                .andWhere('dump.repository_id != :repositoryId', { repositoryId })
-                .andWhere('dump.visible_at_tip = true')
-
-            // Get total number of items in this set of results
-            const totalCount = await baseQuery.getCount()
-
-            // Construct method to select a page of possible package references
-            const getPage = (pageOffset: number): Promise<pgModels.ReferenceModel[]> =>
-                baseQuery
-                    .orderBy('dump.repository_id')
-                    .addOrderBy('dump.root')
-                    .limit(limit)
-                    .offset(pageOffset)
-                    .getMany()
-
-            // Invoke getPage with increasing offsets until we get a page size's worth of
-            // package references that actually use the given identifier as indicated by result
-            // of the bloom filter query.
-            const { packageReferences, newOffset } = await this.gatherPackageReferences({
-                getPage,
-                identifier,
-                offset,
-                limit,
-                totalCount,
-                ctx,
-            })
+        // We do this inside of a repeatable read transaction so that we get consistent results
+        // from multiple distinct queries: one count query and one or more select queries,
+        // depending on the sparsity of the use of the given identifier. Under the default
+        // isolation level, each query sees the rows committed before it began, so the total
+        // count could disagree with the pages.
+        return withInstrumentedTransaction(
+            this.connection,
+            async entityManager => {
+                // Create a base query that selects all active uses of the target package. This
+                // is used as the common prefix for both the count and the getPage queries.
+                const baseQuery = entityManager
+                    .getRepository(pgModels.ReferenceModel)
+                    .createQueryBuilder('reference')
+                    .leftJoinAndSelect('reference.dump', 'dump')
+                    .where({ scheme, name, version })
+                    .andWhere('dump.repository_id != :repositoryId', { repositoryId })
+                    .andWhere('dump.visible_at_tip = true')
+
+                // Get total number of items in this set of results
+                const totalCount = await baseQuery.getCount()
+
+                // Construct method to select a page of possible package references. The dumps of
+                // each repository are interleaved (the first dump of every repository, then the
+                // second, and so on) so that a repository with many dumps does not fill the first
+                // pages of remote references by itself.
+                const getPage = (pageOffset: number): Promise<pgModels.ReferenceModel[]> =>
+                    baseQuery
+                        .orderBy('row_number() OVER (PARTITION BY dump.repository_id ORDER BY dump.root, dump.id)')
+                        .addOrderBy('dump.repository_id')
+                        .addOrderBy('dump.root')
+                        .addOrderBy('dump.id')
+                        .limit(limit)
+                        .offset(pageOffset)
+                        .getMany()
+
+                // Invoke getPage with increasing offsets until we get a page size's worth of
+                // package references that actually use the given identifier as indicated by result
+                // of the bloom filter query.
+                const { packageReferences, newOffset } = await this.gatherPackageReferences({
+                    getPage,
+                    identifier,
+                    offset,
+                    limit,
+                    totalCount,
+                    ctx,
+                })
 
-            return { packageReferences, totalCount, newOffset }
-        })
+                return { packageReferences, totalCount, newOffset }
+            },
+            'REPEATABLE READ'
+        )
     }
 
     /**
@@ -200,77 +230,342 @@ export class DependencyManager {
             SELECT * FROM visible_ids
         `
 
+        // The version of a package may be null, which never compares equal with the = operator.
+        // Pages are ordered by a unique key so that consecutive offsets do not skip or repeat
+        // references of dumps that share a root.
         const countQuery = `
             SELECT count(*) FROM lsif_references r
-            WHERE r.scheme = $1 AND r.name = $2 AND r.version = $3 AND r.dump_id = ANY($4)
+            WHERE r.scheme = $1 AND r.name = $2 AND r.version IS NOT DISTINCT FROM $3 AND r.dump_id = ANY($4)
         `
 
         const referenceIdsQuery = `
             SELECT r.id FROM lsif_references r
             LEFT JOIN lsif_dumps d on r.dump_id = d.id
-            WHERE r.scheme = $1 AND r.name = $2 AND r.version = $3 AND r.dump_id = ANY($4)
-            ORDER BY d.root OFFSET $5 LIMIT $6
+            WHERE r.scheme = $1 AND r.name = $2 AND r.version IS NOT DISTINCT FROM $3 AND r.dump_id = ANY($4)
+            ORDER BY d.root, r.id OFFSET $5 LIMIT $6
         `
 
-        // We do this inside of a transaction so that we get consistent results from multiple
-        // distinct queries: one count query and one or more select queries, depending on the
-        // sparsity of the use of the given identifier.
-        return withInstrumentedTransaction(this.connection, async entityManager => {
-            // Extract numeric ids from query results that return objects
-            const extractIds = (results: { id: number }[]): number[] => results.map(r => r.id)
-
-            // We need the set of identifiers for visible lsif dumps for both the count
-            // and the getPage queries. The results of this query do not change based on
-            // the page size or offset, so we query it separately here and pass the result
-            // as a parameter.
-            const visible_ids = extractIds(await entityManager.query(visibleIdsQuery, [repositoryId, commit]))
-
-            // Get total number of items in this set of results
-            const rawCount: { count: string }[] = await entityManager.query(countQuery, [
-                scheme,
-                name,
-                version,
-                visible_ids,
-            ])
-
-            // Oddly, this comes back as a string value in the result set
-            const totalCount = parseInt(rawCount[0].count, 10)
-
-            // Construct method to select a page of possible package references. We first
-            // perform the query defined above that returns reference identifiers, then
-            // perform a second query to select the models by id so that we load the
-            // relationships.
-            const getPage = async (pageOffset: number): Promise<pgModels.ReferenceModel[]> => {
-                const args = [scheme, name, version, visible_ids, pageOffset, limit]
-                const results = await entityManager.query(referenceIdsQuery, args)
-                const referenceIds = extractIds(results)
-                const packageReferences = await entityManager
-                    .getRepository(pgModels.ReferenceModel)
-                    .findByIds(referenceIds)
+        // We do this inside of a repeatable read transaction so that we get consistent results
+        // from multiple distinct queries: one count query and one or more select queries,
+        // depending on the sparsity of the use of the given identifier. Under the default
+        // isolation level, each query sees the rows committed before it began, so the total
+        // count could disagree with the pages.
+        return withInstrumentedTransaction(
+            this.connection,
+            async entityManager => {
+                // Extract numeric ids from query results that return objects
+                const extractIds = (results: { id: number }[]): number[] => results.map(r => r.id)
+
+                // We need the set of identifiers for visible lsif dumps for both the count
+                // and the getPage queries. The results of this query do not change based on
+                // the page size or offset, so we query it separately here and pass the result
+                // as a parameter.
+                const visible_ids = extractIds(await entityManager.query(visibleIdsQuery, [repositoryId, commit]))
+
+                // Get total number of items in this set of results
+                const rawCount: { count: string }[] = await entityManager.query(countQuery, [
+                    scheme,
+                    name,
+                    version,
+                    visible_ids,
+                ])
+
+                // Oddly, this comes back as a string value in the result set
+                const totalCount = parseInt(rawCount[0].count, 10)
+
+                // Construct method to select a page of possible package references. We first
+                // perform the query defined above that returns reference identifiers, then
+                // perform a second query to select the models by id so that we load the
+                // relationships.
+                const getPage = async (pageOffset: number): Promise<pgModels.ReferenceModel[]> => {
+                    const args = [scheme, name, version, visible_ids, pageOffset, limit]
+                    const results = await entityManager.query(referenceIdsQuery, args)
+                    const referenceIds = extractIds(results)
+                    const packageReferences = await entityManager
+                        .getRepository(pgModels.ReferenceModel)
+                        .findByIds(referenceIds)
+
+                    // findByIds doesn't return models in the same order as they were requested,
+                    // so we need to sort them here before returning.
+
+                    const modelsById = new Map(packageReferences.map(r => [r.id, r]))
+                    return referenceIds
+                        .map(id => modelsById.get(id))
+                        .filter(<T>(x: T | undefined): x is T => x !== undefined)
+                }
+
+                // Invoke getPage with increasing offsets until we get a page size's worth of
+                // package references that actually use the given identifier as indicated by
+                // result of the bloom filter query.
+                const { packageReferences, newOffset } = await this.gatherPackageReferences({
+                    getPage,
+                    identifier,
+                    offset,
+                    limit,
+                    totalCount,
+                    ctx,
+                })
+
+                return { packageReferences, totalCount, newOffset }
+            },
+            'REPEATABLE READ'
+        )
+    }
+
+    /**
+     * Search the packages provided by completed dumps by name. Packages whose name starts
+     * with the query are returned first, followed by packages whose name is similar to the
+     * query, ordered by decreasing similarity. The comparison ignores case. The total count
+     * of matching packages, that ignores limit and offset, is also returned.
+     *
+     * @param args Parameter bag.
+     */
+    public searchPackages({
+        scheme,
+        query,
+        limit,
+        offset,
+    }: {
+        /** The package manager scheme (e.g. npm, pip). If empty, packages of every scheme are searched. */
+        scheme: string
+        /** The name prefix or approximate name. If empty, every package matches. */
+        query: string
+        /** The maximum number of packages to return. */
+        limit: number
+        /** The number of packages to skip. */
+        offset: number
+    }): Promise<{ packages: pgModels.PackageModel[]; totalCount: number }> {
+        // The % operator matches names whose trigram similarity to the query exceeds the
+        // pg_trgm.similarity_threshold setting
+        const matchingPackagesQuery = `
+            FROM lsif_packages p
+            JOIN lsif_dumps d ON d.id = p.dump_id
+            WHERE ($1 = '' OR p.scheme = $1) AND (lower(p.name) LIKE lower($2) || '%' OR lower(p.name) % lower($2))
+        `
+
+        const countQuery = `SELECT COUNT(*) ${matchingPackagesQuery}`
+
+        const packageIdsQuery = `
+            SELECT p.id ${matchingPackagesQuery}
+            ORDER BY
+                lower(p.name) LIKE lower($2) || '%' DESC,
+                similarity(lower(p.name), lower($2)) DESC,
+                p.name, p.version, p.id
+            LIMIT $3 OFFSET $4
+        `
+
+        // Count and select the page in the same snapshot so that the total count agrees
+        // with the page
+        return withInstrumentedTransaction(
+            this.connection,
+            async entityManager => {
+                const rawCount: { count: string }[] = await entityManager.query(countQuery, [scheme, query])
+
+                // Select the models by id so that we load the dump relationship
+                const results: { id: number }[] = await entityManager.query(packageIdsQuery, [
+                    scheme,
+                    query,
+                    limit,
+                    offset,
+                ])
+                const packageIds = results.map(r => r.id)
+                const models = await entityManager.getRepository(pgModels.PackageModel).findByIds(packageIds)
 
                 // findByIds doesn't return models in the same order as they were requested,
                 // so we need to sort them here before returning.
-
-                const modelsById = new Map(packageReferences.map(r => [r.id, r]))
-                return referenceIds
+                const modelsById = new Map(models.map(p => [p.id, p]))
+                const packages = packageIds
                     .map(id => modelsById.get(id))
                     .filter(<T>(x: T | undefined): x is T => x !== undefined)
-            }
 
-            // Invoke getPage with increasing offsets until we get a page size's worth of
-            // package references that actually use the given identifier as indicated by
-            // result of the bloom filter query.
-            const { packageReferences, newOffset } = await this.gatherPackageReferences({
-                getPage,
-                identifier,
-                offset,
-                limit,
-                totalCount,
-                ctx,
-            })
+                // Oddly, this comes back as a string value in the result set
+                return { packages, totalCount: parseInt(rawCount[0].count, 10) }
+            },
+            'REPEATABLE READ'
+        )
+    }
 
-            return { packageReferences, totalCount, newOffset }
-        })
+    /**
+     * Return a page of the completed dumps that may contain a symbol with the given moniker
+     * scheme and identifier, ordered by dump identifier. Dumps providing a package of the
+     * scheme may define the symbol. Dumps using a package of the scheme may reference the
+     * symbol, and those whose bloom filters exclude the identifier are skipped. The total
+     * count of candidate dumps, that ignores limit, offset, and the bloom filters, is also
+     * returned along with the offset at which the next page starts.
+     *
+     * Without a repository scope, only dumps visible at the tip of their repository are
+     * searched.
+     *
+     * @param args Parameter bag.
+     */
+    public getMonikerDumps({
+        scheme,
+        identifier,
+        kind,
+        scope,
+        limit,
+        offset,
+        ctx = {},
+    }: {
+        /** The moniker scheme (e.g. npm, gomod). */
+        scheme: string
+        /** The moniker identifier. */
+        identifier: string
+        /** Whether to search for dumps defining or referencing the symbol. */
+        kind: 'definition' | 'reference'
+        /** The repository and commit whose visible dumps are searched. */
+        scope?: { repositoryId: number; commit: string }
+        /** The maximum number of dumps to return. */
+        limit: number
+        /** The number of candidate dumps to skip. */
+        offset: number
+        /** The tracing context. */
+        ctx?: TracingContext
+    }): Promise<{ dumps: pgModels.LsifDump[]; totalCount: number; newOffset: number }> {
+        const visibleIdsQuery = `
+            WITH
+            ${bidirectionalLineage()},
+            ${visibleDumps()}
+            SELECT * FROM visible_ids
+        `
+
+        const candidatesQuery = `
+            SELECT DISTINCT t.dump_id FROM ${kind === 'definition' ? 'lsif_packages' : 'lsif_references'} t
+            JOIN lsif_dumps d ON d.id = t.dump_id
+            WHERE t.scheme = $1 AND ${scope ? 'd.id = ANY($2)' : 'd.visible_at_tip = true'}
+        `
+
+        // Count and select the pages in the same snapshot so that the total count agrees
+        // with the pages
+        return withInstrumentedTransaction(
+            this.connection,
+            async entityManager => {
+                const params: unknown[] = [scheme]
+                if (scope) {
+                    const results: { id: number }[] = await entityManager.query(visibleIdsQuery, [
+                        scope.repositoryId,
+                        scope.commit,
+                    ])
+                    params.push(results.map(r => r.id))
+                }
+
+                const rawCount: { count: string }[] = await entityManager.query(
+                    `SELECT COUNT(*) FROM (${candidatesQuery}) candidates`,
+                    params
+                )
+
+                // Oddly, this comes back as a string value in the result set
+                const totalCount = parseInt(rawCount[0].count, 10)
+
+                const [limitParam, offsetParam] = [params.length + 1, params.length + 2]
+                const pageQuery = `${candidatesQuery} ORDER BY t.dump_id LIMIT $${limitParam} OFFSET $${offsetParam}`
+
+                let numScanned = 0
+                let numFiltered = 0
+                let newOffset = offset
+                const dumpIds: number[] = []
+
+                while (dumpIds.length < limit && newOffset < totalCount) {
+                    const rows: { dump_id: number }[] = await entityManager.query(pageQuery, [
+                        ...params,
+                        limit - dumpIds.length,
+                        newOffset,
+                    ])
+                    if (rows.length === 0) {
+                        // Shouldn't happen, but just in case of a bug we
+                        // don't want this to throw up into an infinite loop.
+                        break
+                    }
+
+                    const pageIds = rows.map(row => row.dump_id)
+                    const matchingIds =
+                        kind === 'definition'
+                            ? pageIds
+                            : await this.filterReferencingDumps(entityManager, scheme, identifier, pageIds)
+
+                    dumpIds.push(...matchingIds)
+                    newOffset += pageIds.length
+                    numScanned += pageIds.length
+                    numFiltered += pageIds.length - matchingIds.length
+                }
+
+                logSpan(ctx, 'moniker_dumps', { numScanned, numFiltered })
+                if (ctx.debug && kind === 'reference') {
+                    ctx.debug.recordBloomFilter(numScanned, numFiltered)
+                }
+
+                // findByIds doesn't return models in the same order as they were requested,
+                // so we need to sort them here before returning.
+                const models = await entityManager.getRepository(pgModels.LsifDump).findByIds(dumpIds)
+                const dumpsById = new Map(models.map(dump => [dump.id, dump]))
+                const dumps = dumpIds
+                    .map(id => dumpsById.get(id))
+                    .filter(<T>(x: T | undefined): x is T => x !== undefined)
+
+                return { dumps, totalCount, newOffset }
+            },
+            'REPEATABLE READ'
+        )
+    }
+
+    /**
+     * Return a page of the completed dumps that provide a package used by the given dump,
+     * ordered by dump identifier, along with the packages each dump provides. The total
+     * count of such dumps, that ignores limit and offset, is also returned.
+     *
+     * @param dumpId The identifier of the dependent dump.
+     * @param limit The maximum number of dumps to return.
+     * @param offset The number of dumps to skip.
+     */
+    public async getDependencies(
+        dumpId: number,
+        limit: number,
+        offset: number
+    ): Promise<{ dependencies: DumpDependency[]; totalCount: number }> {
+        const { dumps, totalCount } = await this.getRelatedDumps(
+            `
+                SELECT p.dump_id AS related_id, p.scheme, p.name, p.version
+                FROM lsif_references r
+                JOIN lsif_packages p
+                    ON p.scheme = r.scheme AND p.name = r.name AND p.version IS NOT DISTINCT FROM r.version
+                WHERE r.dump_id = $1 AND p.dump_id != $1
+            `,
+            dumpId,
+            limit,
+            offset
+        )
+
+        return { dependencies: dumps, totalCount }
+    }
+
+    /**
+     * Return a page of the completed dumps that use a package provided by the given dump,
+     * ordered by dump identifier, along with the packages each dump uses. The total count
+     * of such dumps, that ignores limit and offset, is also returned.
+     *
+     * @param dumpId The identifier of the dump providing the packages.
+     * @param limit The maximum number of dumps to return.
+     * @param offset The number of dumps to skip.
+     */
+    public async getDependents(
+        dumpId: number,
+        limit: number,
+        offset: number
+    ): Promise<{ dependents: DumpDependency[]; totalCount: number }> {
+        const { dumps, totalCount } = await this.getRelatedDumps(
+            `
+                SELECT r.dump_id AS related_id, p.scheme, p.name, p.version
+                FROM lsif_packages p
+                JOIN lsif_references r
+                    ON r.scheme = p.scheme AND r.name = p.name AND r.version IS NOT DISTINCT FROM p.version
+                WHERE p.dump_id = $1 AND r.dump_id != $1
+            `,
+            dumpId,
+            limit,
+            offset
+        )
+
+        return { dependents: dumps, totalCount }
     }
 
     /**
@@ -327,6 +622,71 @@ export class DependencyManager {
         })
     }
 
+    /**
+     * Group the rows of the given query by related dump and return a page of the related
+     * dumps that have completed processing. The query must select the columns `related_id`,
+     * `scheme`, `name`, and `version` and take the identifier of the source dump as its
+     * only parameter.
+     *
+     * @param relationQuery The query selecting the related dumps and the packages relating them.
+     * @param dumpId The identifier of the source dump.
+     * @param limit The maximum number of dumps to return.
+     * @param offset The number of dumps to skip.
+     */
+    private getRelatedDumps(
+        relationQuery: string,
+        dumpId: number,
+        limit: number,
+        offset: number
+    ): Promise<{ dumps: DumpDependency[]; totalCount: number }> {
+        const relatedQuery = `
+            WITH relations AS (${relationQuery})
+            SELECT
+                related_id,
+                json_agg(json_build_object('scheme', scheme, 'name', name, 'version', version)
+                    ORDER BY scheme, name, version) AS packages
+            FROM relations
+            WHERE related_id IN (SELECT id FROM lsif_dumps)
+            GROUP BY related_id
+        `
+
+        // Count and select the page in the same snapshot so that the total count agrees
+        // with the page
+        return withInstrumentedTransaction(
+            this.connection,
+            async entityManager => {
+                const rawCount: { count: string }[] = await entityManager.query(
+                    `SELECT COUNT(*) FROM (${relatedQuery}) related`,
+                    [dumpId]
+                )
+
+                const rows: { related_id: number; packages: Package[] }[] = await entityManager.query(
+                    `${relatedQuery} ORDER BY related_id LIMIT $2 OFFSET $3`,
+                    [dumpId, limit, offset]
+                )
+
+                // findByIds doesn't return models in the same order as they were requested,
+                // so we need to sort them here before returning.
+                const models = await entityManager
+                    .getRepository(pgModels.LsifDump)
+                    .findByIds(rows.map(row => row.related_id))
+                const dumpsById = new Map(models.map(dump => [dump.id, dump]))
+
+                const dumps: DumpDependency[] = []
+                for (const { related_id: id, packages } of rows) {
+                    const dump = dumpsById.get(id)
+                    if (dump) {
+                        dumps.push({ dump, packages })
+                    }
+                }
+
+                // Oddly, this comes back as a string value in the result set
+                return { dumps, totalCount: parseInt(rawCount[0].count, 10) }
+            },
+            'REPEATABLE READ'
+        )
+    }
+
     /**
      * Select a page of possible results via the `getPage` function and collect the package references that
      * include a use of the given identifier. As the given results may depend on the target package but not
@@ -397,6 +757,10 @@ export class DependencyManager {
             }
 
             logSpan(ctx, 'reference_results', { numScanned, numFetched, numFiltered })
+            if (ctx.debug) {
+                ctx.debug.recordBloomFilter(numScanned, numFiltered)
+            }
+
             return { packageReferences, newOffset }
         })
     }
@@ -439,4 +803,41 @@ export class DependencyManager {
         // We scanned the entire set of package references
         return { packageReferences: filtered, scanned: packageReferences.length }
     }
+
+    /**
+     * Return the given dumps, in order, that use a package of the given scheme whose bloom
+     * filter contains the given identifier. A dump may use several packages of the scheme,
+     * and is kept if any of their filters contains the identifier.
+     *
+     * @param entityManager The EntityManager to use as part of a transaction.
+     * @param scheme The package manager scheme (e.g. npm, pip).
+     * @param identifier The identifier to test.
+     * @param dumpIds The identifiers of the candidate dumps.
+     */
+    private async filterReferencingDumps(
+        entityManager: EntityManager,
+        scheme: string,
+        identifier: string,
+        dumpIds: number[]
+    ): Promise<number[]> {
+        const references: { dump_id: number; filter: EncodedBloomFilter }[] = await entityManager.query(
+            'SELECT dump_id, filter FROM lsif_references WHERE scheme = $1 AND dump_id = ANY($2)',
+            [scheme, dumpIds]
+        )
+
+        const matchingIds = new Set<number>()
+        for (const { dump_id: dumpId, filter } of references) {
+            if (matchingIds.has(dumpId)) {
+                continue
+            }
+
+            const flag = await testFilter(filter, identifier)
+            metrics.bloomFilterEventsCounter.labels(flag ? 'hit' : 'miss').inc()
+            if (flag) {
+                matchingIds.add(dumpId)
+            }
+        }
+
+        return dumpIds.filter(dumpId => matchingIds.has(dumpId))
+    }
 }
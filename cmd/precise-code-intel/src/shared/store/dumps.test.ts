@@ -102,6 +102,11 @@ describe('DumpManager', () => {
         expect(d5[0].commit).toEqual(cg)
         expect(d6[0].commit).toEqual(cg)
 
+        // Test distance from target commit
+        expect(d1[0].distance).toEqual(0)
+        expect(d3[0].distance).toEqual(0)
+        expect(d6[0].distance).toEqual(0)
+
         // Multiple nearest are chosen arbitrarily
         expect([ca, cc, cg]).toContain(d7[0].commit)
         expect([ca, cc]).toContain(d8[0].commit)
@@ -229,6 +234,179 @@ describe('DumpManager', () => {
         expect(d7[0].root).toEqual('')
     })
 
+    it('should find distinct closest dumps ordered by distance', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        // This database has the following commit graph:
+        //
+        // [a] -- [b] --+-- [c] --+-- e
+        //              |         |
+        //              +--- d ---+
+        //
+        // Where each LSIF dump has a distinct indexer. The dumps at a and b are reachable
+        // from e along multiple paths, but must be returned only once.
+
+        const repositoryId = nextId()
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+        const cc = util.createCommit()
+        const cd = util.createCommit()
+        const ce = util.createCommit()
+
+        // Add relations
+        await dumpManager.updateCommits(
+            repositoryId,
+            new Map<string, Set<string>>([
+                [ca, new Set()],
+                [cb, new Set([ca])],
+                [cc, new Set([cb])],
+                [cd, new Set([cb])],
+                [ce, new Set([cc, cd])],
+            ])
+        )
+
+        // Add dumps
+        const dump1 = await util.insertDump(connection, dumpManager, repositoryId, ca, '', 'A')
+        const dump2 = await util.insertDump(connection, dumpManager, repositoryId, cb, '', 'B')
+        const dump3 = await util.insertDump(connection, dumpManager, repositoryId, cc, '', 'C')
+
+        const dumps = await dumpManager.findClosestDumps(repositoryId, ce, 'file.ts')
+        expect(dumps.map(d => d.id)).toEqual([dump3.id, dump2.id, dump1.id])
+        expect(dumps.map(d => d.commit)).toEqual([cc, cb, ca])
+
+        expect(dumps[0].distance).toBeLessThan(dumps[1].distance)
+        expect(dumps[1].distance).toBeLessThan(dumps[2].distance)
+    })
+
+    it('should read closest dumps from refreshed nearest uploads only', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        // This database has the following commit graph:
+        //
+        // [a] -- b -- c
+        //
+        // A dump is later added at c, and another dump at a is excluded.
+
+        const repositoryId = nextId()
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+        const cc = util.createCommit()
+        const lineageDumpManager = new DumpManager(connection, false)
+
+        await dumpManager.updateCommits(
+            repositoryId,
+            new Map<string, Set<string>>([
+                [ca, new Set()],
+                [cb, new Set([ca])],
+                [cc, new Set([cb])],
+            ])
+        )
+
+        const dump1 = await util.insertDump(connection, dumpManager, repositoryId, ca, '', 'A')
+        const dump2 = await util.insertDump(connection, dumpManager, repositoryId, ca, 'sub/', 'B')
+
+        const getClosest = (manager: DumpManager, commit: string) =>
+            manager.findClosestDumps(repositoryId, commit, 'sub/file.ts')
+        const getClosestIds = async (commit: string) => (await getClosest(dumpManager, commit)).map(d => d.id)
+
+        const countNearestUploads = async (): Promise<number> =>
+            (
+                await connection.query('SELECT 1 FROM lsif_nearest_uploads WHERE repository_id = $1', [
+                    repositoryId,
+                ])
+            ).length
+
+        // Replace the rows of the repository so that reads of the table can be told apart
+        const clearNearestUploads = () =>
+            connection.query(
+                "UPDATE lsif_nearest_uploads SET upload_ids = '{}', distances = '{}' WHERE repository_id = $1",
+                [repositoryId]
+            )
+
+        // Queries traverse the commit graph and write nothing until the repository is refreshed
+        expect(await getClosestIds(cc)).toEqual([dump1.id, dump2.id])
+        expect(await countNearestUploads()).toEqual(0)
+
+        // The refreshed rows agree with the lineage query
+        expect(await dumpManager.refreshNearestUploads(1000)).toContain(repositoryId)
+        expect(await countNearestUploads()).toEqual(3)
+        for (const commit of [ca, cb, cc]) {
+            expect(await getClosest(dumpManager, commit)).toEqual(await getClosest(lineageDumpManager, commit))
+        }
+
+        // Up-to-date rows are read instead of the commit graph
+        await clearNearestUploads()
+        expect(await getClosestIds(cc)).toEqual([])
+        expect(await dumpManager.refreshNearestUploads(1000)).not.toContain(repositoryId)
+
+        // Adding a dump leaves the rows of the repository unread until the next refresh
+        const dump3 = await util.insertDump(connection, dumpManager, repositoryId, cc, '', 'A')
+        expect(await getClosestIds(cc)).toEqual([dump3.id, dump2.id])
+        expect(await dumpManager.refreshNearestUploads(1000)).toContain(repositoryId)
+        await clearNearestUploads()
+        expect(await getClosestIds(cc)).toEqual([])
+
+        // Excluding a dump leaves the rows of the repository unread until the next refresh
+        await dumpManager.setExcluded(dump2.id, true)
+        expect(await getClosestIds(cc)).toEqual([dump3.id])
+        expect(await dumpManager.refreshNearestUploads(1000)).toContain(repositoryId)
+        expect(await getClosestIds(cc)).toEqual([dump3.id])
+
+        // Adding a commit adds its row on the next refresh
+        const cd = util.createCommit()
+        await dumpManager.updateCommits(repositoryId, new Map<string, Set<string>>([[cd, new Set([cc])]]))
+        await clearNearestUploads()
+        expect(await getClosestIds(cd)).toEqual([dump3.id])
+        expect(await dumpManager.refreshNearestUploads(1000)).toContain(repositoryId)
+        expect(await countNearestUploads()).toEqual(4)
+    })
+
+    it('should keep a repository dirty when it changes during a refresh', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        const repositoryId = nextId()
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+
+        await dumpManager.updateCommits(repositoryId, new Map<string, Set<string>>([[ca, new Set()]]))
+        await util.insertDump(connection, dumpManager, repositoryId, ca, '', 'A')
+        await dumpManager.refreshNearestUploads(1000)
+        await util.insertDump(connection, dumpManager, repositoryId, ca, 'sub/', 'A')
+
+        // Block the refresh after it reads the dirty token by locking the rows it replaces,
+        // and change the commit graph before releasing them
+        const { pending } = await connection.transaction(async entityManager => {
+            await entityManager.query('SELECT 1 FROM lsif_nearest_uploads WHERE repository_id = $1 FOR UPDATE', [
+                repositoryId,
+            ])
+
+            const pending = dumpManager.refreshNearestUploads(1000)
+            const waiting = `
+                SELECT 1 FROM pg_stat_activity
+                WHERE datname = current_database() AND wait_event_type = 'Lock'
+            `
+            while ((await entityManager.query(waiting)).length === 0) {
+                await new Promise(resolve => setTimeout(resolve, 10))
+            }
+
+            await dumpManager.updateCommits(repositoryId, new Map<string, Set<string>>([[cb, new Set([ca])]]))
+
+            // Wrap the pending refresh so that the transaction does not wait for it
+            return { pending }
+        })
+
+        // The refresh recorded the token it read, which the change has since replaced
+        expect(await pending).toContain(repositoryId)
+        expect(await dumpManager.refreshNearestUploads(1000)).toContain(repositoryId)
+        expect(await dumpManager.refreshNearestUploads(1000)).not.toContain(repositoryId)
+    })
+
     it('should find closest commits with LSIF data (overlapping roots)', async () => {
         if (!dumpManager) {
             fail('failed beforeAll')
@@ -602,6 +780,252 @@ describe('DumpManager', () => {
         visibleDumps = await dumpManager.getVisibleDumps(repositoryId)
         expect(visibleDumps.map((dump: pgModels.LsifDump) => dump.id).sort()).toEqual([dump2.id])
     })
+
+    it('should mark ancestor dumps of the same root and indexer as superseded', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        // This database has the following commit graph:
+        //
+        // [a] -- [b] -- [c] -- [d]
+
+        const repositoryId = nextId()
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+        const cc = util.createCommit()
+        const cd = util.createCommit()
+
+        // Add relations
+        await dumpManager.updateCommits(
+            repositoryId,
+            new Map<string, Set<string>>([
+                [ca, new Set()],
+                [cb, new Set([ca])],
+                [cc, new Set([cb])],
+                [cd, new Set([cc])],
+            ])
+        )
+
+        // Add dumps
+        const dump1 = await util.insertDump(connection, dumpManager, repositoryId, ca, '', 'test')
+        await util.insertDump(connection, dumpManager, repositoryId, cb, 'r1/', 'test') // different root
+        await util.insertDump(connection, dumpManager, repositoryId, cb, '', 'other') // different indexer
+        const dump2 = await util.insertDump(connection, dumpManager, repositoryId, cc, '', 'test')
+        const dump3 = await util.insertDump(connection, dumpManager, repositoryId, cd, '', 'test')
+
+        expect(await dumpManager.markSupersededDumps(repositoryId, cc, '', 'test', dump2.id)).toEqual([dump1.id])
+        expect(await dumpManager.markSupersededDumps(repositoryId, cd, '', 'test', dump3.id)).toEqual([dump2.id])
+
+        // Superseded dumps are pruned first
+        const prunable = await dumpManager.getLeastRecentlyUsedPrunableDump()
+        expect(prunable?.id).toEqual(dump1.id)
+        expect(prunable?.supersededBy).toEqual(dump2.id)
+    })
+
+    it('should mark superseded dumps for deletion', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        const repositoryId = nextId()
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+
+        // Add relations
+        await dumpManager.updateCommits(
+            repositoryId,
+            new Map<string, Set<string>>([
+                [ca, new Set()],
+                [cb, new Set([ca])],
+            ])
+        )
+
+        // Add dumps
+        const dump1 = await util.insertDump(connection, dumpManager, repositoryId, ca, '', 'test')
+        const dump2 = await util.insertDump(connection, dumpManager, repositoryId, cb, '', 'test')
+
+        expect(await dumpManager.markSupersededDumps(repositoryId, cb, '', 'test', dump2.id, true)).toEqual([dump1.id])
+        expect(await dumpManager.getDumpById(dump1.id)).toBeUndefined()
+
+        const rows = await connection.query('SELECT state, state_before_delete FROM lsif_uploads WHERE id = $1', [
+            dump1.id,
+        ])
+        expect(rows).toEqual([{ state: 'deleting', state_before_delete: 'completed' }])
+
+        const events = await connection.query(
+            'SELECT event, source, message FROM lsif_upload_events WHERE upload_id = $1',
+            [dump1.id]
+        )
+        expect(events).toEqual([{ event: 'deleted', source: 'worker', message: `Superseded by upload ${dump2.id}` }])
+    })
+
+    it('should apply the prune policy', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        const repositoryId1 = nextId()
+        const repositoryId2 = nextId()
+
+        // Dumps are uploaded one to four days ago (oldest first)
+        const updateQuery = "UPDATE lsif_uploads SET uploaded_at = now() - ($1 * interval '1 day') WHERE id = $2"
+        const dumps: pgModels.LsifDump[] = []
+        for (let i = 0; i < 4; i++) {
+            const dump = await util.insertDump(connection, dumpManager, repositoryId1, util.createCommit(), '', 'test')
+            await connection.query(updateQuery, [4 - i, dump.id])
+            dumps.push(dump)
+        }
+        const otherDump = await util.insertDump(connection, dumpManager, repositoryId2, util.createCommit(), '', 'test')
+        await connection.query('UPDATE lsif_uploads SET visible_at_tip = true WHERE id = $1', [dumps[0].id])
+
+        const ids = (values: pgModels.LsifDump[]): pgModels.DumpId[] => values.map(dump => dump.id)
+        const protectAll = { protectVisibleAtTip: true, protectNewerThan: 60 * 60 * 24 * 5 }
+        const protectNone = { protectVisibleAtTip: false, protectNewerThan: -1 }
+        const protectRecent = { protectVisibleAtTip: false, protectNewerThan: 60 * 60 * 24 * 2.5 }
+
+        // The visible dump is pruned last even when it is not protected
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump())?.id).toEqual(dumps[1].id)
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump(protectNone))?.id).toEqual(dumps[1].id)
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump(protectNone, [dumps[0].id]))?.id).toEqual(
+            dumps[0].id
+        )
+        expect(await dumpManager.getLeastRecentlyUsedPrunableDump(protectAll)).toBeUndefined()
+
+        // Only the given dumps are candidates
+        const candidates = [dumps[2].id, dumps[3].id]
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump(protectNone, candidates))?.id).toEqual(dumps[2].id)
+        expect(await dumpManager.getLeastRecentlyUsedPrunableDump(protectNone, [])).toBeUndefined()
+
+        // The visible dump counts towards the limit but is not returned
+        expect(ids(await dumpManager.getExcessDumps(1))).toEqual([dumps[1].id, dumps[2].id])
+        expect(ids(await dumpManager.getExcessDumps(1, protectNone))).toEqual([
+            dumps[0].id,
+            dumps[1].id,
+            dumps[2].id,
+        ])
+        expect(ids(await dumpManager.getExcessDumps(1, protectRecent))).toEqual([dumps[0].id, dumps[1].id])
+        expect(ids(await dumpManager.getExcessDumps(1, protectAll))).toEqual([])
+        expect(ids(await dumpManager.getExcessDumps(4, protectNone))).toEqual([])
+        expect(ids(await dumpManager.getExcessDumps(0, protectNone))).toContain(otherDump.id)
+    })
+
+    it('should prune the least recently used dump first', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        // Dumps are uploaded one to three days ago (oldest first)
+        const repositoryId = nextId()
+        const updateQuery = "UPDATE lsif_uploads SET uploaded_at = now() - ($1 * interval '1 day') WHERE id = $2"
+        const dumps: pgModels.LsifDump[] = []
+        for (let i = 0; i < 3; i++) {
+            const dump = await util.insertDump(connection, dumpManager, repositoryId, util.createCommit(), '', 'test')
+            await connection.query(updateQuery, [3 - i, dump.id])
+            dumps.push(dump)
+        }
+
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump())?.id).toEqual(dumps[0].id)
+
+        // A recently queried dump is pruned after dumps that have not been queried since
+        await dumpManager.recordAccess([dumps[0].id])
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump())?.id).toEqual(dumps[1].id)
+
+        // Access times never move backwards
+        const twoDaysAgo = new Date(Date.now() - 1000 * 60 * 60 * 24 * 2)
+        await dumpManager.recordAccess([dumps[1].id])
+        await dumpManager.recordAccess([dumps[0].id, dumps[1].id], twoDaysAgo)
+        expect((await dumpManager.getLeastRecentlyUsedPrunableDump())?.id).toEqual(dumps[2].id)
+
+        const dump = await dumpManager.getDumpById(dumps[1].id)
+        expect(dump?.lastAccessedAt?.getTime()).toBeGreaterThan(twoDaysAgo.getTime())
+    })
+
+    it('should respect pinned and excluded dumps', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        // This database has the following commit graph:
+        //
+        // [a] -- [b] -- c
+
+        const repositoryId = nextId()
+        const ca = util.createCommit()
+        const cb = util.createCommit()
+        const cc = util.createCommit()
+
+        // Add relations
+        await dumpManager.updateCommits(
+            repositoryId,
+            new Map<string, Set<string>>([
+                [ca, new Set()],
+                [cb, new Set([ca])],
+                [cc, new Set([cb])],
+            ])
+        )
+
+        // Add dumps
+        const dump1 = await util.insertDump(connection, dumpManager, repositoryId, ca, '', 'test')
+        const dump2 = await util.insertDump(connection, dumpManager, repositoryId, cb, '', 'test')
+
+        // Closest dump is chosen by default
+        expect((await dumpManager.findClosestDumps(repositoryId, cc, 'file.ts')).map(d => d.id)).toEqual([dump2.id])
+
+        // Excluded dumps are skipped
+        await dumpManager.setExcluded(dump2.id, true)
+        expect((await dumpManager.findClosestDumps(repositoryId, cc, 'file.ts')).map(d => d.id)).toEqual([dump1.id])
+
+        // Pinned dumps override closest dumps
+        await dumpManager.setExcluded(dump2.id, false)
+        await dumpManager.setPinned(dump1.id, true)
+        expect((await dumpManager.findClosestDumps(repositoryId, cc, 'file.ts')).map(d => d.id)).toEqual([dump1.id])
+
+        // Pinning another dump unpins the previous one
+        await dumpManager.setPinned(dump2.id, true)
+        expect((await dumpManager.getDumpById(dump1.id))?.pinned).toBeFalsy()
+        expect((await dumpManager.findClosestDumps(repositoryId, ca, 'file.ts')).map(d => d.id)).toEqual([dump2.id])
+
+        // Unknown dumps are not updated
+        expect(await dumpManager.setPinned(nextId(), true)).toBeUndefined()
+    })
+
+    it('should list distinct repositories with dumps', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        const repositoryId1 = nextId()
+        const repositoryId2 = nextId()
+
+        await util.insertDump(connection, dumpManager, repositoryId2, util.createCommit(), 'a/', 'test')
+        await util.insertDump(connection, dumpManager, repositoryId1, util.createCommit(), 'a/', 'test')
+        await util.insertDump(connection, dumpManager, repositoryId2, util.createCommit(), 'b/', 'test')
+
+        expect(await dumpManager.getRepositoryIds()).toEqual([repositoryId1, repositoryId2])
+    })
+
+    it('should group the dumps of a repository by root and indexer', async () => {
+        if (!dumpManager) {
+            fail('failed beforeAll')
+        }
+
+        const repositoryId = nextId()
+        const d1 = await util.insertDump(connection, dumpManager, repositoryId, util.createCommit(), 'b/', 'tsc')
+        const d2 = await util.insertDump(connection, dumpManager, repositoryId, util.createCommit(), 'a/', 'tsc')
+        const d3 = await util.insertDump(connection, dumpManager, repositoryId, util.createCommit(), 'a/', 'go')
+        const d4 = await util.insertDump(connection, dumpManager, repositoryId, util.createCommit(), 'a/', 'tsc')
+        await util.insertDump(connection, dumpManager, nextId(), util.createCommit(), 'a/', 'tsc')
+
+        const groups = await dumpManager.getDumpGroups(repositoryId)
+        expect(
+            groups.map(({ root, indexer, dumps }) => ({ root, indexer, ids: dumps.map(dump => dump.id) }))
+        ).toEqual([
+            { root: 'a/', indexer: 'go', ids: [d3.id] },
+            { root: 'a/', indexer: 'tsc', ids: [d4.id, d2.id] },
+            { root: 'b/', indexer: 'tsc', ids: [d1.id] },
+        ])
+    })
 })
 
 describe('discoverAndUpdateCommit', () => {
@@ -1,13 +1,16 @@
-import { uniq } from 'lodash'
+import * as metrics from './metrics'
 import * as sharedMetrics from '../database/metrics'
 import * as pgModels from '../models/pg'
 import { getCommitsNear, getHead } from '../gitserver/gitserver'
-import { Brackets, Connection, EntityManager } from 'typeorm'
+import { Brackets, Connection, EntityManager, SelectQueryBuilder } from 'typeorm'
 import { logAndTraceCall, TracingContext } from '../tracing'
 import { instrumentQuery, instrumentQueryOrTransaction, withInstrumentedTransaction } from '../database/postgres'
 import { TableInserter } from '../database/inserter'
 import { visibleDumps, ancestorLineage, bidirectionalLineage } from '../models/queries'
+import { recordUploadEvents, WORKER_ORIGIN } from './uploads'
+import { USE_NEAREST_UPLOADS } from '../config/settings'
 import { isDefined } from '../util'
+import { acquireTransactionLock } from './locks'
 
 /** The insertion metrics for Postgres. */
 const insertionMetrics = {
@@ -15,14 +18,59 @@ const insertionMetrics = {
     errorsCounter: sharedMetrics.postgresQueryErrorsCounter,
 }
 
+/** A dump along with the approximate number of commits between its commit and a target commit. */
+export interface LsifDumpWithDistance extends pgModels.LsifDump {
+    distance: number
+}
+
+/** The dumps of a repository that share a root and indexer. */
+export interface DumpGroup {
+    /** The root of the dumps. */
+    root: string
+    /** The indexer that produced the dumps. */
+    indexer: string
+    /** The dumps, from most to least recently processed. */
+    dumps: pgModels.LsifDump[]
+}
+
+/**
+ * The dump operations used to answer code intelligence queries. This is the subset of
+ * `DumpManager` on which the api-server backend depends, so that the backend can be
+ * tested against a mock store without a Postgres connection.
+ */
+export type DumpStore = Pick<DumpManager, 'findClosestDumps' | 'getDumpById' | 'getDumpsByIds'>
+
+/**
+ * The dump operations used by the dump debug endpoints of the api-server. This is the subset
+ * of `DumpManager` on which those endpoints depend, so that they can be tested against a mock
+ * store without a Postgres connection.
+ */
+export type DumpDebugStore = Pick<
+    DumpManager,
+    'discoverCommits' | 'updateCommits' | 'getDumpGroups' | 'findClosestDumps'
+>
+
+/** Determines which dumps the janitor may prune. */
+export interface PrunePolicy {
+    /** Whether dumps visible from the tip of the default branch are protected. */
+    protectVisibleAtTip: boolean
+
+    /** Dumps uploaded within this many seconds are protected (< 0 means no dump is protected by age). */
+    protectNewerThan: number
+}
+
+/** The policy used when none is supplied, which protects only dumps visible at tip. */
+const defaultPrunePolicy: PrunePolicy = { protectVisibleAtTip: true, protectNewerThan: -1 }
+
 /** A wrapper around the database tables that control dumps and commits. */
 export class DumpManager {
     /**
      * Create a new `DumpManager` backed by the given database connection.
      *
      * @param connection The Postgres connection.
+     * @param useNearestUploads Whether closest dump queries read the `lsif_nearest_uploads` table.
      */
-    constructor(private connection: Connection) {}
+    constructor(private connection: Connection, private useNearestUploads: boolean = USE_NEAREST_UPLOADS) {}
 
     /**
      * Find the dump for the given repository and commit.
@@ -47,19 +95,28 @@ export class DumpManager {
      * Get a dump by identifier.
      *
      * @param id The dump identifier.
+     * @param ctx The tracing context.
      */
-    public getDumpById(id: pgModels.DumpId): Promise<pgModels.LsifDump | undefined> {
-        return instrumentQuery(() => this.connection.getRepository(pgModels.LsifDump).findOne({ id }))
+    public getDumpById(id: pgModels.DumpId, ctx: TracingContext = {}): Promise<pgModels.LsifDump | undefined> {
+        return logAndTraceCall(ctx, 'Getting dump', () =>
+            instrumentQuery(() => this.connection.getRepository(pgModels.LsifDump).findOne({ id }))
+        )
     }
 
     /**
      * Bulk get dumps by identifier.
      *
      * @param ids The dump identifiers.
+     * @param ctx The tracing context.
      */
-    public async getDumpsByIds(ids: pgModels.DumpId[]): Promise<Map<pgModels.DumpId, pgModels.LsifDump>> {
-        const dumps = await instrumentQuery(() =>
-            this.connection.getRepository(pgModels.LsifDump).createQueryBuilder().select().whereInIds(ids).getMany()
+    public async getDumpsByIds(
+        ids: pgModels.DumpId[],
+        ctx: TracingContext = {}
+    ): Promise<Map<pgModels.DumpId, pgModels.LsifDump>> {
+        const dumps = await logAndTraceCall(ctx, 'Getting dumps', () =>
+            instrumentQuery(() =>
+                this.connection.getRepository(pgModels.LsifDump).createQueryBuilder().select().whereInIds(ids).getMany()
+            )
         )
 
         return new Map(dumps.map(d => [d.id, d]))
@@ -88,7 +145,7 @@ export class DumpManager {
     }
 
     /**
-     * Find the visible dumps. This method is used for testing.
+     * Find the dumps visible from the tip of the default branch.
      *
      * @param repositoryId The repository identifier.
      */
@@ -104,21 +161,135 @@ export class DumpManager {
     }
 
     /**
-     * Get the oldest dump that is not visible at the tip of its repository.
+     * Get the dumps of a repository grouped by root and indexer. Groups are ordered by root
+     * and indexer, and the dumps of a group are ordered from most to least recently processed.
      *
+     * @param repositoryId The repository identifier.
+     */
+    public async getDumpGroups(repositoryId: number): Promise<DumpGroup[]> {
+        const dumps = await instrumentQuery(() =>
+            this.connection
+                .getRepository(pgModels.LsifDump)
+                .createQueryBuilder('dump')
+                .where({ repositoryId })
+                .orderBy('dump.root')
+                .addOrderBy('dump.indexer')
+                .addOrderBy('dump.processed_at', 'DESC')
+                .addOrderBy('dump.id', 'DESC')
+                .getMany()
+        )
+
+        const groups: DumpGroup[] = []
+        for (const dump of dumps) {
+            const last = groups[groups.length - 1]
+            if (last && last.root === dump.root && last.indexer === dump.indexer) {
+                last.dumps.push(dump)
+            } else {
+                groups.push({ root: dump.root, indexer: dump.indexer, dumps: [dump] })
+            }
+        }
+
+        return groups
+    }
+
+    /**
+     * Get the identifiers of all repositories with at least one dump, ordered by identifier.
+     */
+    public async getRepositoryIds(): Promise<number[]> {
+        const rows: { repository_id: number }[] = await instrumentQuery(() =>
+            this.connection.query('SELECT DISTINCT repository_id FROM lsif_dumps ORDER BY repository_id')
+        )
+
+        return rows.map(({ repository_id }) => repository_id)
+    }
+
+    /**
+     * Record that the given dumps have been queried. The access time of a dump never moves
+     * backwards, so reports that arrive out of order are harmless.
+     *
+     * @param ids The identifiers of the queried dumps.
+     * @param accessedAt The time the dumps were queried.
+     */
+    public async recordAccess(ids: pgModels.DumpId[], accessedAt: Date = new Date()): Promise<void> {
+        if (ids.length === 0) {
+            return
+        }
+
+        await instrumentQuery(() =>
+            this.connection.query(
+                `
+                    UPDATE lsif_uploads
+                    SET last_accessed_at = GREATEST(last_accessed_at, $2)
+                    WHERE id = ANY($1) AND state = 'completed'
+                `,
+                [ids, accessedAt]
+            )
+        )
+    }
+
+    /**
+     * Get the least recently used dump that the given policy allows to be pruned. Dumps that
+     * have been superseded by a newer dump are returned first, followed by dumps that are not
+     * visible from the tip of the default branch. Within each group, dumps are ordered by the
+     * time they were last queried, or by their upload time if they have never been queried.
+     *
+     * @param policy The policy that determines which dumps are protected from pruning.
+     * @param ids If supplied, only these dumps are candidates for pruning.
      * @param entityManager The EntityManager to use as part of a transaction.
      */
-    public getOldestPrunableDump(
+    public async getLeastRecentlyUsedPrunableDump(
+        policy: PrunePolicy = defaultPrunePolicy,
+        ids?: pgModels.DumpId[],
         entityManager: EntityManager = this.connection.createEntityManager()
     ): Promise<pgModels.LsifDump | undefined> {
-        return instrumentQuery(() =>
-            entityManager
-                .getRepository(pgModels.LsifDump)
-                .createQueryBuilder()
-                .select()
-                .where({ visibleAtTip: false })
-                .orderBy('uploaded_at')
+        if (ids && ids.length === 0) {
+            return undefined
+        }
+
+        return instrumentQuery(() => {
+            let query = applyPrunePolicy(
+                entityManager.getRepository(pgModels.LsifDump).createQueryBuilder().select(),
+                policy
+            )
+            if (ids) {
+                query = query.andWhere('id IN (:...ids)', { ids })
+            }
+
+            return query
+                .orderBy('superseded_by IS NULL')
+                .addOrderBy('visible_at_tip')
+                .addOrderBy('COALESCE(last_accessed_at, uploaded_at)')
+                .addOrderBy('id')
                 .getOne()
+        })
+    }
+
+    /**
+     * Get the dumps of each repository that are older than its `maxDumps` most recent dumps
+     * and that the given policy allows to be pruned. Protected dumps still count towards the
+     * limit of their repository.
+     *
+     * @param maxDumps The maximum number of dumps to retain per repository.
+     * @param policy The policy that determines which dumps are protected from pruning.
+     */
+    public getExcessDumps(maxDumps: number, policy: PrunePolicy = defaultPrunePolicy): Promise<pgModels.LsifDump[]> {
+        return instrumentQuery(() =>
+            applyPrunePolicy(this.connection.getRepository(pgModels.LsifDump).createQueryBuilder().select(), policy)
+                .andWhere(
+                    `id IN (
+                        SELECT ranked.id FROM (
+                            SELECT d.id, ROW_NUMBER() OVER (
+                                PARTITION BY d.repository_id ORDER BY d.uploaded_at DESC, d.id DESC
+                            ) AS rank
+                            FROM lsif_dumps d
+                        ) ranked
+                        WHERE ranked.rank > :maxDumps
+                    )`,
+                    { maxDumps }
+                )
+                .orderBy('repository_id')
+                .addOrderBy('uploaded_at')
+                .getMany()
         )
     }
 
@@ -126,21 +297,25 @@ export class DumpManager {
      * Return the dump 'closest' to the given target commit (a direct descendant or ancestor of
      * the target commit). If no closest commit can be determined, this method returns undefined.
      *
-     * This method returns dumps ordered by commit distance (nearest first).
+     * This method returns dumps ordered by commit distance (nearest first). Dumps at the same
+     * distance are ordered by identifier so that the result is deterministic.
+     *
+     * A pinned dump replaces the closest dumps with the same root and indexer regardless of its
+     * commit. Pinned dumps are reported with a distance of zero.
      *
      * @param repositoryId The repository identifier.
      * @param commit The target commit.
-     * @param file One of the files in the dump.
+     * @param file One of the files in the dump. If undefined, dumps of every root are returned.
      * @param ctx The tracing context.
      * @param frontendUrl The url of the frontend internal API.
      */
     public async findClosestDumps(
         repositoryId: number,
         commit: string,
-        file: string,
+        file: string | undefined,
         ctx: TracingContext = {},
         frontendUrl?: string
-    ): Promise<pgModels.LsifDump[]> {
+    ): Promise<LsifDumpWithDistance[]> {
         // Request updated commit data from gitserver if this commit isn't already
         // tracked. This will pull back ancestors for this commit up to a certain
         // (configurable) depth and insert them into the database. This populates
@@ -154,38 +329,204 @@ export class DumpManager {
         }
 
         return logAndTraceCall(ctx, 'Finding closest dump', async () => {
+            // Each visible dump is reported once, at the distance of the nearest commit in the
+            // lineage at which it occurs. The row number of the target commit in the lineage is
+            // one, so we subtract one to get the distance of a dump from the target commit. The
+            // distances are read from the nearest uploads table when enabled and the row of the
+            // commit is up to date. Otherwise the commit graph is traversed.
+            let closestQuery = `
+                ${bidirectionalLineage()},
+                ${visibleDumps()},
+                closest AS (
+                    SELECT d.dump_id AS id, MIN(d.n) - 1 AS distance FROM lineage_with_dumps d
+                    WHERE d.dump_id IN (SELECT * FROM visible_ids)
+                    AND ($3::text IS NULL OR $3 LIKE (d.root || '%'))
+                    GROUP BY d.dump_id
+                )
+            `
+
+            const nearest = this.useNearestUploads ? await this.getNearestUploads(repositoryId, commit) : undefined
+            if (nearest) {
+                closestQuery = `
+                    closest AS (
+                        SELECT u.id, u.distance
+                        FROM unnest($4::integer[], $5::integer[]) AS u(id, distance)
+                        JOIN lsif_dumps d ON d.id = u.id
+                        WHERE ($3::text IS NULL OR $3 LIKE (d.root || '%'))
+                    )
+                `
+            }
+
             const query = `
                 WITH
-                ${bidirectionalLineage()},
-                ${visibleDumps()}
+                ${closestQuery},
+                pinned AS (
+                    SELECT d.id, d.root, d.indexer FROM lsif_dumps d
+                    WHERE d.repository_id = $1 AND d.pinned AND NOT d.excluded
+                    AND ($3::text IS NULL OR $3 LIKE (d.root || '%'))
+                ),
+                candidates AS (
+                    SELECT p.id, 0 AS distance, true AS is_pinned FROM pinned p
+                    UNION ALL
+                    -- drop dumps overridden by a pinned dump with the same root and indexer
+                    SELECT c.id, c.distance, false FROM closest c JOIN lsif_dumps d ON d.id = c.id
+                    WHERE NOT EXISTS (SELECT 1 FROM pinned p WHERE p.root = d.root AND p.indexer = d.indexer)
+                )
 
-                SELECT d.dump_id FROM lineage_with_dumps d
-                WHERE $3 LIKE (d.root || '%') AND d.dump_id IN (SELECT * FROM visible_ids)
-                ORDER BY d.n
+                SELECT c.id, c.distance FROM candidates c
+                ORDER BY c.is_pinned DESC, c.distance, c.id
             `
 
             return withInstrumentedTransaction(this.connection, async entityManager => {
-                const results: { dump_id: number }[] = await entityManager.query(query, [repositoryId, commit, file])
-                const dumpIds = results.map(({ dump_id }) => dump_id)
-                if (dumpIds.length === 0) {
+                const rows: { id: number; distance: string }[] = await entityManager.query(query, [
+                    repositoryId,
+                    commit,
+                    file,
+                    ...(nearest ? [nearest.uploadIds, nearest.distances] : []),
+                ])
+                if (rows.length === 0) {
                     return []
                 }
 
-                const uniqueDumpIds = uniq(dumpIds)
+                // findByIds doesn't return models in the same order as they were requested,
+                // so we need to sort them here before returning.
+                const models = await entityManager.getRepository(pgModels.LsifDump).findByIds(rows.map(({ id }) => id))
+                const dumpsById = new Map(models.map(dump => [dump.id, dump]))
 
-                const dumps = await entityManager
-                    .getRepository(pgModels.LsifDump)
-                    .createQueryBuilder()
-                    .select()
-                    .where('id IN (:...ids)', { ids: uniqueDumpIds })
-                    .getMany()
-
-                const dumpByID = new Map(dumps.map(dump => [dump.id, dump]))
-                return uniqueDumpIds.map(id => dumpByID.get(id)).filter(isDefined)
+                return rows
+                    .map(({ id, distance }) => {
+                        const dump = dumpsById.get(id)
+                        return dump && { ...dump, distance: parseInt(distance, 10) }
+                    })
+                    .filter(isDefined)
             })
         })
     }
 
+    /**
+     * Return the visible dumps of the given commit from its `lsif_nearest_uploads` row. This
+     * returns undefined if the commit has no row or if the commits or dumps of the repository
+     * changed since its rows were last refreshed.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The target commit.
+     */
+    private async getNearestUploads(
+        repositoryId: number,
+        commit: string
+    ): Promise<{ uploadIds: number[]; distances: number[] } | undefined> {
+        const rows: { upload_ids: number[]; distances: number[] }[] = await instrumentQuery(() =>
+            this.connection.query(
+                `
+                    SELECT n.upload_ids, n.distances FROM lsif_nearest_uploads n
+                    WHERE n.repository_id = $1 AND n."commit" = $2 AND NOT EXISTS (
+                        SELECT 1 FROM lsif_dirty_repositories r
+                        WHERE r.repository_id = $1 AND r.dirty_token <> r.update_token
+                    )
+                `,
+                [repositoryId, commit]
+            )
+        )
+        if (rows.length === 0) {
+            metrics.nearestUploadsCounter.labels('miss').inc()
+            return undefined
+        }
+
+        metrics.nearestUploadsCounter.labels('hit').inc()
+        return { uploadIds: rows[0].upload_ids, distances: rows[0].distances }
+    }
+
+    /**
+     * Rebuild the `lsif_nearest_uploads` rows of repositories whose commits or dumps changed
+     * since their rows were last refreshed, with one row for each commit of the repository.
+     * Returns the identifiers of the refreshed repositories.
+     *
+     * Triggers set the dirty token of a repository to the identifier of each transaction that
+     * changes its commits or dumps. A refresh records the dirty token it read before reading
+     * the commit graph, so a repository changed during the refresh stays dirty and its rows
+     * are not read until the next refresh.
+     *
+     * @param limit The maximum number of repositories to refresh.
+     * @param ctx The tracing context.
+     */
+    public async refreshNearestUploads(limit: number, ctx: TracingContext = {}): Promise<number[]> {
+        const rows: { repository_id: number }[] = await instrumentQuery(() =>
+            this.connection.query(
+                `
+                    SELECT repository_id FROM lsif_dirty_repositories
+                    WHERE dirty_token <> update_token
+                    ORDER BY repository_id
+                    LIMIT $1
+                `,
+                [limit]
+            )
+        )
+
+        const repositoryIds = rows.map(({ repository_id }) => repository_id)
+        for (const repositoryId of repositoryIds) {
+            await logAndTraceCall(ctx, 'Refreshing nearest uploads', () =>
+                this.refreshRepositoryNearestUploads(repositoryId)
+            )
+        }
+
+        return repositoryIds
+    }
+
+    /**
+     * Rebuild the `lsif_nearest_uploads` rows of the given repository. The refresh holds an
+     * advisory lock on the repository so that concurrent refreshes of the same repository
+     * do not interleave.
+     *
+     * @param repositoryId The repository identifier.
+     */
+    private refreshRepositoryNearestUploads(repositoryId: number): Promise<void> {
+        const query = `
+            WITH
+            ${bidirectionalLineage()},
+            ${visibleDumps()},
+            closest AS (
+                SELECT d.dump_id AS id, MIN(d.n) - 1 AS distance FROM lineage_with_dumps d
+                WHERE d.dump_id IN (SELECT * FROM visible_ids)
+                GROUP BY d.dump_id
+            )
+            INSERT INTO lsif_nearest_uploads (repository_id, "commit", upload_ids, distances)
+            SELECT
+                $1,
+                $2,
+                COALESCE(array_agg(c.id ORDER BY c.id), '{}'),
+                COALESCE(array_agg(c.distance ORDER BY c.id), '{}')
+            FROM closest c
+        `
+
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            await acquireTransactionLock(entityManager, `nearest-uploads-${repositoryId}`)
+
+            // Read the token before the commit graph so that the rows are at least as new as it
+            const tokens: { dirty_token: string }[] = await entityManager.query(
+                'SELECT dirty_token FROM lsif_dirty_repositories WHERE repository_id = $1',
+                [repositoryId]
+            )
+            if (tokens.length === 0) {
+                return
+            }
+
+            await entityManager.query('DELETE FROM lsif_nearest_uploads WHERE repository_id = $1', [repositoryId])
+
+            const commits: { commit: string }[] = await entityManager.query(
+                'SELECT DISTINCT "commit" FROM lsif_commits WHERE repository_id = $1',
+                [repositoryId]
+            )
+            for (const { commit } of commits) {
+                await entityManager.query(query, [repositoryId, commit])
+            }
+
+            await entityManager.query(
+                'UPDATE lsif_dirty_repositories SET update_token = $2 WHERE repository_id = $1',
+                [repositoryId, tokens[0].dirty_token]
+            )
+        })
+    }
+
     /**
      * Determine the set of dumps which are 'visible' from the given commit and set the
      * `visible_at_tip` flags. Unset the flag for each invisible dump for this repository.
@@ -347,7 +688,7 @@ export class DumpManager {
     ): Promise<void> {
         return logAndTraceCall(ctx, 'Clearing overlapping dumps', () =>
             instrumentQuery(async () => {
-                await entityManager
+                const { raw } = await entityManager
                     .getRepository(pgModels.LsifUpload)
                     .createQueryBuilder()
                     .delete()
@@ -357,8 +698,218 @@ export class DumpManager {
                             qb.where(":root LIKE (root || '%')", { root }).orWhere("root LIKE (:root || '%')", { root })
                         )
                     )
+                    .returning('id')
                     .execute()
+
+                const ids = ((raw || []) as { id: number }[]).map(({ id }) => id)
+                await recordUploadEvents(
+                    entityManager,
+                    ids,
+                    'purged',
+                    null,
+                    WORKER_ORIGIN,
+                    'Replaced by an overlapping upload'
+                )
             })
         )
     }
+
+    /**
+     * Mark the dumps for the same repository, root, and indexer at an ancestor of the given
+     * commit as superseded by the given dump. Dumps visible at the tip of the default branch
+     * are never marked, as they still provide global reference data. Superseded dumps can also
+     * be marked for deletion, as if they were deleted with `softDeleteUpload`, so that they no
+     * longer answer queries. Returns the identifiers of the newly superseded dumps.
+     *
+     * @param repositoryId The repository identifier.
+     * @param commit The commit of the superseding dump.
+     * @param root The root of all files that are in the dump.
+     * @param indexer The indexer used to produce the dump.
+     * @param dumpId The identifier of the superseding dump.
+     * @param markForDeletion Whether the superseded dumps are also marked for deletion.
+     * @param ctx The tracing context.
+     * @param entityManager The EntityManager to use as part of a transaction.
+     */
+    public async markSupersededDumps(
+        repositoryId: number,
+        commit: string,
+        root: string,
+        indexer: string,
+        dumpId: pgModels.DumpId,
+        markForDeletion = false,
+        ctx: TracingContext = {},
+        entityManager: EntityManager = this.connection.createEntityManager()
+    ): Promise<pgModels.DumpId[]> {
+        return logAndTraceCall(ctx, 'Marking superseded dumps', async () => {
+            // Superseded dumps are never visible at tip, so marking them for deletion does
+            // not change the visibility of the repository's dumps
+            const query = `
+                WITH ${ancestorLineage()}
+                UPDATE lsif_uploads u
+                SET
+                    superseded_by = $5,
+                    state = CASE WHEN $6 THEN 'deleting' ELSE u.state END,
+                    state_before_delete = CASE WHEN $6 THEN u.state ELSE u.state_before_delete END,
+                    deleted_at = CASE WHEN $6 THEN now() ELSE u.deleted_at END
+                WHERE u.id IN (
+                    SELECT d.id FROM lineage l
+                    JOIN lsif_dumps d ON d.repository_id = l.repository_id AND d."commit" = l."commit"
+                    WHERE d.root = $3 AND d.indexer = $4 AND d.id != $5 AND NOT d.visible_at_tip AND d.superseded_by IS NULL
+                )
+                RETURNING u.id
+            `
+
+            const results: [{ id: number }[]] = await instrumentQuery(() =>
+                entityManager.query(query, [repositoryId, commit, root, indexer, dumpId, markForDeletion])
+            )
+
+            const ids = results[0].map(({ id }) => id)
+            if (markForDeletion) {
+                await recordUploadEvents(
+                    entityManager,
+                    ids,
+                    'deleted',
+                    'deleting',
+                    WORKER_ORIGIN,
+                    `Superseded by upload ${dumpId}`
+                )
+            }
+
+            return ids
+        })
+    }
+
+    /**
+     * Pin or unpin a dump. A pinned dump is the preferred provider for its root and indexer and
+     * overrides closest-commit selection. Pinning a dump unpins all other dumps of the same
+     * repository, root, and indexer. Returns the updated dump, or undefined if it does not exist.
+     *
+     * @param id The dump identifier.
+     * @param pinned Whether or not the dump should be pinned.
+     * @param entityManager The EntityManager to use as part of a transaction.
+     */
+    public setPinned(
+        id: pgModels.DumpId,
+        pinned: boolean,
+        entityManager: EntityManager = this.connection.createEntityManager()
+    ): Promise<pgModels.LsifDump | undefined> {
+        return instrumentQuery(async () => {
+            const dump = await entityManager.getRepository(pgModels.LsifDump).findOne({ id })
+            if (!dump) {
+                return undefined
+            }
+
+            if (pinned) {
+                await entityManager
+                    .getRepository(pgModels.LsifUpload)
+                    .createQueryBuilder()
+                    .update()
+                    .set({ pinned: false })
+                    .where({
+                        repositoryId: dump.repositoryId,
+                        root: dump.root,
+                        indexer: dump.indexer,
+                        pinned: true,
+                    })
+                    .execute()
+            }
+
+            await entityManager
+                .getRepository(pgModels.LsifUpload)
+                .createQueryBuilder()
+                .update()
+                .set({ pinned })
+                .where({ id })
+                .execute()
+
+            return { ...dump, pinned }
+        })
+    }
+
+    /**
+     * Exclude or re-include a dump. An excluded dump is never returned from a closest dump
+     * query and is never visible at tip. Returns the updated dump, or undefined if it does
+     * not exist.
+     *
+     * @param id The dump identifier.
+     * @param excluded Whether or not the dump should be excluded.
+     * @param entityManager The EntityManager to use as part of a transaction.
+     */
+    public setExcluded(
+        id: pgModels.DumpId,
+        excluded: boolean,
+        entityManager: EntityManager = this.connection.createEntityManager()
+    ): Promise<pgModels.LsifDump | undefined> {
+        return instrumentQuery(async () => {
+            const dump = await entityManager.getRepository(pgModels.LsifDump).findOne({ id })
+            if (!dump) {
+                return undefined
+            }
+
+            await entityManager
+                .getRepository(pgModels.LsifUpload)
+                .createQueryBuilder()
+                .update()
+                .set({ excluded })
+                .where({ id })
+                .execute()
+
+            return { ...dump, excluded }
+        })
+    }
+
+    /**
+     * Get the statistics computed for a dump at conversion time.
+     *
+     * @param dumpId The dump identifier.
+     */
+    public getStatistics(dumpId: pgModels.DumpId): Promise<pgModels.DumpStatistics | undefined> {
+        return instrumentQuery(() => this.connection.getRepository(pgModels.DumpStatistics).findOne({ dumpId }))
+    }
+
+    /**
+     * Insert the statistics computed for a dump at conversion time.
+     *
+     * @param dumpId The dump identifier.
+     * @param statistics The statistics of the dump.
+     * @param ctx The tracing context.
+     * @param entityManager The EntityManager to use as part of a transaction.
+     */
+    public addStatistics(
+        dumpId: pgModels.DumpId,
+        statistics: Omit<pgModels.DumpStatistics, 'dumpId'>,
+        ctx: TracingContext = {},
+        entityManager: EntityManager = this.connection.createEntityManager()
+    ): Promise<void> {
+        return logAndTraceCall(ctx, 'Inserting dump statistics', async () => {
+            await instrumentQuery(() =>
+                entityManager
+                    .createQueryBuilder()
+                    .insert()
+                    .into(pgModels.DumpStatistics)
+                    .values({ dumpId, ...statistics })
+                    .execute()
+            )
+        })
+    }
+}
+
+/**
+ * Restrict the given dump query to the dumps that the policy allows to be pruned.
+ *
+ * @param query The dump query.
+ * @param policy The policy that determines which dumps are protected from pruning.
+ */
+function applyPrunePolicy(
+    query: SelectQueryBuilder<pgModels.LsifDump>,
+    { protectVisibleAtTip, protectNewerThan }: PrunePolicy
+): SelectQueryBuilder<pgModels.LsifDump> {
+    if (protectVisibleAtTip) {
+        query = query.andWhere({ visibleAtTip: false })
+    }
+    if (protectNewerThan >= 0) {
+        query = query.andWhere("uploaded_at < now() - (:protectNewerThan * interval '1 second')", { protectNewerThan })
+    }
+
+    return query
 }
@@ -1,6 +1,6 @@
 import * as crc32 from 'crc-32'
 import { ADVISORY_LOCK_ID_SALT } from '../constants'
-import { Connection } from 'typeorm'
+import { Connection, EntityManager } from 'typeorm'
 
 /**
  * Hold a Postgres advisory lock while executing the given function. Note that acquiring
@@ -51,6 +51,20 @@ export async function tryWithLock<T>(
     return undefined
 }
 
+/**
+ * Acquire a Postgres advisory lock that is released when the transaction of the given entity
+ * manager ends. Note that acquiring an advisory lock is an (indefinitely) blocking operation.
+ *
+ * For more information, see
+ * https://www.postgresql.org/docs/9.6/static/explicit-locking.html#ADVISORY-LOCKS
+ *
+ * @param entityManager The EntityManager of the transaction.
+ * @param name The name of the lock.
+ */
+export async function acquireTransactionLock(entityManager: EntityManager, name: string): Promise<void> {
+    await entityManager.query('SELECT pg_advisory_xact_lock($1)', [createLockId(name)])
+}
+
 /**
  * Create an integer identifier that will be unique to this app, but will always be the same for this given
  * name within the application.
@@ -8,3 +8,12 @@ export const bloomFilterEventsCounter = new promClient.Counter({
     help: 'The number of bloom filter hits and misses.',
     labelNames: ['type'],
 })
+
+//
+// Nearest Uploads Metrics
+
+export const nearestUploadsCounter = new promClient.Counter({
+    name: 'lsif_nearest_uploads_events_total',
+    help: 'The number of closest dump queries answered from nearest uploads (hit) or from the commit graph (miss).',
+    labelNames: ['type'],
+})
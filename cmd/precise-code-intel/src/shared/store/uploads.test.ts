@@ -0,0 +1,535 @@
+import * as util from '../test-util'
+import * as pgModels from '../models/pg'
+import { Connection } from 'typeorm'
+import { UploadCursor, UploadEventOrigin, UploadManager } from './uploads'
+import { fail } from 'assert'
+import { createSilentLogger } from '../logging'
+
+describe('UploadManager', () => {
+    let connection!: Connection
+    let cleanup!: () => Promise<void>
+    let uploadManager!: UploadManager
+
+    const origin: UploadEventOrigin = { source: 'api', actor: 'user:1' }
+
+    beforeAll(async () => {
+        ;({ connection, cleanup } = await util.createCleanPostgresDatabase())
+        uploadManager = new UploadManager(connection)
+    })
+
+    afterAll(async () => {
+        if (cleanup) {
+            await cleanup()
+        }
+    })
+
+    beforeEach(async () => {
+        if (connection) {
+            await util.truncatePostgresTables(connection)
+        }
+    })
+
+    const insertUpload = async (
+        repositoryId: number,
+        commit: string,
+        indexer: string,
+        state: pgModels.LsifUploadState
+    ): Promise<number> => {
+        const upload = new pgModels.LsifUpload()
+        upload.repositoryId = repositoryId
+        upload.commit = commit
+        upload.root = ''
+        upload.indexer = indexer
+        upload.uploadedAt = new Date()
+        upload.state = state
+        upload.tracingContext = '{}'
+        await connection.createEntityManager().save(upload)
+        return upload.id
+    }
+
+    it('should return total count of all pages', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        for (let i = 0; i < 5; i++) {
+            await insertUpload(50, util.createCommit(), 'lsif-go', i % 2 === 0 ? 'completed' : 'errored')
+        }
+        await insertUpload(51, util.createCommit(), 'lsif-go', 'completed')
+
+        const { uploads, totalCount } = await uploadManager.getUploads(50, undefined, '', false, 2, 0)
+        expect(uploads).toHaveLength(2)
+        expect(totalCount).toEqual(5)
+
+        const { uploads: lastPage, totalCount: lastPageTotalCount } = await uploadManager.getUploads(
+            50,
+            undefined,
+            '',
+            false,
+            2,
+            4
+        )
+        expect(lastPage).toHaveLength(1)
+        expect(lastPageTotalCount).toEqual(5)
+    })
+
+    it('should apply filters to total count', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        for (let i = 0; i < 5; i++) {
+            const indexer = i < 2 ? 'lsif-tsc' : 'lsif-go'
+            await insertUpload(50, util.createCommit(), indexer, i % 2 === 0 ? 'completed' : 'errored')
+        }
+
+        const { uploads, totalCount } = await uploadManager.getUploads(50, 'completed', '', false, 1, 0)
+        expect(uploads).toHaveLength(1)
+        expect(totalCount).toEqual(3)
+
+        const { uploads: tscUploads, totalCount: tscTotalCount } = await uploadManager.getUploads(
+            50,
+            undefined,
+            'tsc',
+            false,
+            10,
+            0
+        )
+        expect(tscUploads).toHaveLength(2)
+        expect(tscTotalCount).toEqual(2)
+    })
+
+    it('should find and supersede duplicate uploads', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const key = { repositoryId: 50, commit: util.createCommit(), root: '', indexer: 'lsif-go' }
+        const completedId = await uploadManager.enqueue({ ...key, checksum: 'a' })
+        await connection.query("UPDATE lsif_uploads SET state = 'completed' WHERE id = $1", [completedId])
+        const queuedId = await uploadManager.enqueue({ ...key, checksum: 'b' })
+        const otherRootId = await uploadManager.enqueue({ ...key, root: 'sub/', checksum: 'b' })
+
+        expect((await uploadManager.findDuplicate(key, 'a'))?.id).toEqual(completedId)
+        expect((await uploadManager.findDuplicate(key, 'b'))?.id).toEqual(queuedId)
+        expect(await uploadManager.findDuplicate(key, 'c')).toBeUndefined()
+
+        const id = await connection.transaction(async entityManager => {
+            const newId = await uploadManager.enqueue({ ...key, checksum: 'c' }, entityManager)
+            expect(await uploadManager.supersedeQueued(newId, key, entityManager, origin)).toEqual([queuedId])
+            return newId
+        })
+
+        // Completed uploads are replaced once the superseding upload is converted
+        const remaining = await connection.query('SELECT id FROM lsif_uploads ORDER BY id')
+        expect(remaining.map(({ id }: { id: number }) => id)).toEqual([completedId, otherRootId, id])
+        expect((await uploadManager.getEvents(queuedId)).map(({ event, message }) => ({ event, message }))).toEqual([
+            { event: 'enqueued', message: null },
+            { event: 'purged', message: `Superseded by upload ${id}` },
+        ])
+    })
+
+    it('should count queued uploads', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'completed')
+        await insertUpload(51, util.createCommit(), 'lsif-go', 'queued')
+
+        expect(await uploadManager.countQueued(50)).toEqual({ repository: 2, total: 3 })
+        expect(await uploadManager.countQueued(52)).toEqual({ repository: 0, total: 3 })
+    })
+
+    it('should summarize the conversion queue by indexer', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'processing')
+        const recentId = await insertUpload(50, util.createCommit(), 'lsif-tsc', 'completed')
+        const staleId = await insertUpload(50, util.createCommit(), 'lsif-tsc', 'errored')
+        await connection.query(
+            "UPDATE lsif_uploads SET uploaded_at = now() - interval '10 minutes' WHERE state = 'queued'"
+        )
+        await connection.query('UPDATE lsif_uploads SET finished_at = now() WHERE id = $1', [recentId])
+        await connection.query("UPDATE lsif_uploads SET finished_at = now() - interval '2 hours' WHERE id = $1", [
+            staleId,
+        ])
+
+        const [goStatus, tscStatus, ...rest] = await uploadManager.getQueueStatus(60 * 60)
+        expect(rest).toHaveLength(0)
+        expect(goStatus).toMatchObject({ indexer: 'lsif-go', queued: 2, processing: 1, recentlyFinished: 0 })
+        expect(goStatus.oldestQueuedAge).toBeGreaterThanOrEqual(10 * 60)
+        expect(tscStatus).toEqual({
+            indexer: 'lsif-tsc',
+            queued: 0,
+            processing: 0,
+            oldestQueuedAge: null,
+            recentlyFinished: 1,
+        })
+    })
+
+    it('should page through uploads of all repositories', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const ids: number[] = []
+        for (let i = 0; i < 5; i++) {
+            ids.push(await insertUpload(50 + (i % 2), util.createCommit(), 'lsif-go', 'completed'))
+        }
+        const tscId = await insertUpload(52, util.createCommit(), 'lsif-tsc', 'errored')
+
+        // Uploads with the same upload time are ordered by identifier
+        await connection.query('UPDATE lsif_uploads SET uploaded_at = now()')
+
+        const seen: number[] = []
+        let cursor: UploadCursor | undefined
+        do {
+            const page = await uploadManager.getAllUploads({ indexer: 'lsif-go' }, 2, cursor)
+            expect(page.uploads.length).toBeLessThanOrEqual(2)
+            seen.push(...page.uploads.map(u => u.id))
+            cursor = page.nextCursor
+        } while (cursor)
+
+        expect(seen).toEqual([...ids].reverse())
+
+        const { uploads: errored } = await uploadManager.getAllUploads({ state: 'errored' }, 10)
+        expect(errored.map(u => u.id)).toEqual([tscId])
+
+        const { uploads: repositoryUploads } = await uploadManager.getAllUploads({ repositoryId: 51 }, 10)
+        expect(repositoryUploads.map(u => u.id)).toEqual([ids[3], ids[1]])
+
+        const { uploads: future } = await uploadManager.getAllUploads(
+            { uploadedAfter: new Date(Date.now() + 60 * 1000) },
+            10
+        )
+        expect(future).toHaveLength(0)
+    })
+
+    it('should requeue errored uploads until they fail', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const id = await insertUpload(50, util.createCommit(), 'lsif-go', 'errored')
+        await insertUpload(50, util.createCommit(), 'lsif-go', 'completed')
+
+        for (let i = 1; i <= 2; i++) {
+            expect(await uploadManager.requeue(id, 2, origin)).toEqual('queued')
+            const upload = await uploadManager.getUpload(id)
+            expect(upload?.state).toEqual('queued')
+            expect(upload?.attempts).toEqual(i)
+            expect(upload?.lastRetriedAt).not.toBeNull()
+
+            // Only errored uploads can be requeued
+            expect(await uploadManager.requeue(id, 2, origin)).toBeUndefined()
+            await connection.query("UPDATE lsif_uploads SET state = 'errored' WHERE id = $1", [id])
+        }
+
+        expect(await uploadManager.requeue(id, 2, origin)).toEqual('failed')
+        expect((await uploadManager.getUpload(id))?.state).toEqual('failed')
+        expect(await uploadManager.requeue(id, 2, origin)).toBeUndefined()
+        expect(await uploadManager.requeue(id + 100, 2, origin)).toBeUndefined()
+
+        // Failed uploads are retained by the janitor unless given a maximum age
+        await connection.query("UPDATE lsif_uploads SET uploaded_at = now() - interval '1 day'")
+        expect(await uploadManager.clean({ queued: 60, processing: 60, errored: 60 })).toEqual([])
+    })
+
+    it('should clean uploads older than the maximum age of their state', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const queuedId = await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        const erroredId = await insertUpload(50, util.createCommit(), 'lsif-go', 'errored')
+        const failedId = await insertUpload(50, util.createCommit(), 'lsif-go', 'failed')
+        const completedId = await insertUpload(50, util.createCommit(), 'lsif-go', 'completed')
+        await connection.query("UPDATE lsif_uploads SET uploaded_at = now() - interval '1 hour'")
+        const recentErroredId = await insertUpload(50, util.createCommit(), 'lsif-go', 'errored')
+
+        const maxAges = { queued: 60 * 60 * 2, errored: 60, failed: -1 }
+        const remainingIds = async (): Promise<number[]> =>
+            (await connection.query('SELECT id FROM lsif_uploads ORDER BY id')).map(({ id }: { id: number }) => id)
+
+        // Dry runs do not remove anything
+        expect(await uploadManager.clean(maxAges, true)).toEqual([erroredId])
+        expect(await remainingIds()).toEqual([queuedId, erroredId, failedId, completedId, recentErroredId])
+
+        expect(await uploadManager.clean(maxAges)).toEqual([erroredId])
+        expect(await remainingIds()).toEqual([queuedId, failedId, completedId, recentErroredId])
+        expect(await uploadManager.clean({})).toEqual([])
+    })
+
+    it('should count uploads by state', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        for (let i = 0; i < 5; i++) {
+            await insertUpload(50, util.createCommit(), 'lsif-go', i % 2 === 0 ? 'completed' : 'errored')
+        }
+        await insertUpload(51, util.createCommit(), 'lsif-go', 'queued')
+
+        expect(await uploadManager.getCountsByState()).toEqual(
+            new Map([
+                ['completed', 3],
+                ['errored', 2],
+                ['queued', 1],
+            ])
+        )
+    })
+
+    it('should hide deleted uploads until they are restored', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const commit = util.createCommit()
+        const id = await insertUpload(50, commit, 'lsif-go', 'completed')
+        const updateVisibility = (): Promise<void> => Promise.resolve()
+
+        expect(await uploadManager.softDeleteUpload(id, updateVisibility, origin)).toBeTruthy()
+        expect(await uploadManager.softDeleteUpload(id, updateVisibility, origin)).toBeFalsy()
+        expect(await uploadManager.getUpload(id)).toBeUndefined()
+        expect((await uploadManager.getUploads(50, undefined, '', false, 10, 0)).totalCount).toEqual(0)
+        expect((await uploadManager.getUploads(50, 'deleting', '', false, 10, 0)).totalCount).toEqual(1)
+
+        expect(await uploadManager.restoreUpload(id, 60, updateVisibility, origin)).toEqual('restored')
+        expect(await uploadManager.restoreUpload(id, 60, updateVisibility, origin)).toBeUndefined()
+        const upload = await uploadManager.getUpload(id)
+        expect(upload?.state).toEqual('completed')
+        expect(upload?.deletedAt).toBeNull()
+        expect(upload?.stateBeforeDelete).toBeNull()
+
+        // Another upload for the same commit supersedes the deleted one
+        await uploadManager.softDeleteUpload(id, updateVisibility, origin)
+        await insertUpload(50, commit, 'lsif-go', 'completed')
+        expect(await uploadManager.restoreUpload(id, 60, updateVisibility, origin)).toEqual('conflict')
+
+        await connection.query("UPDATE lsif_uploads SET deleted_at = now() - interval '1 hour' WHERE id = $1", [id])
+        expect(await uploadManager.restoreUpload(id, 60, updateVisibility, origin)).toEqual('expired')
+    })
+
+    it('should flag corrupted dumps as errored', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const id = await insertUpload(50, util.createCommit(), 'lsif-go', 'completed')
+        const queuedId = await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        const updateVisibility = (): Promise<void> => Promise.resolve()
+
+        const problems = ['Integrity check failed: page 3 is never used', 'Malformed document main.go: missing ranges']
+        expect(await uploadManager.markCorrupted(id, problems, updateVisibility, origin)).toBeTruthy()
+        expect(await uploadManager.markCorrupted(id, problems, updateVisibility, origin)).toBeFalsy()
+        expect(await uploadManager.markCorrupted(queuedId, problems, updateVisibility, origin)).toBeFalsy()
+
+        const upload = await uploadManager.getUpload(id)
+        expect(upload?.state).toEqual('errored')
+        expect(upload?.failureSummary).toEqual('Bundle failed verification')
+        expect(upload?.failureStacktrace).toEqual(problems.join('\n'))
+
+        // Flagged dumps can be retried like any other errored upload
+        expect(await uploadManager.requeue(id, 3, origin)).toEqual('queued')
+    })
+
+    it('should purge uploads deleted before the restore window', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const updateVisibility = (): Promise<void> => Promise.resolve()
+
+        const oldId = await insertUpload(50, util.createCommit(), 'lsif-go', 'completed')
+        const recentId = await insertUpload(50, util.createCommit(), 'lsif-go', 'errored')
+        const liveId = await insertUpload(50, util.createCommit(), 'lsif-go', 'completed')
+        await uploadManager.softDeleteUpload(oldId, updateVisibility, origin)
+        await uploadManager.softDeleteUpload(recentId, updateVisibility, origin)
+        await connection.query("UPDATE lsif_uploads SET deleted_at = now() - interval '1 hour' WHERE id = $1", [oldId])
+
+        const remainingIds = async (): Promise<number[]> =>
+            (await connection.query('SELECT id FROM lsif_uploads ORDER BY id')).map(({ id }: { id: number }) => id)
+
+        // Dry runs do not remove anything
+        expect(await uploadManager.purgeDeleted(60, true)).toEqual([oldId])
+        expect(await remainingIds()).toEqual([oldId, recentId, liveId])
+
+        expect(await uploadManager.purgeDeleted(60)).toEqual([oldId])
+        expect(await remainingIds()).toEqual([recentId, liveId])
+    })
+
+    it('should reset uploads with a stale heartbeat', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const staleId = await insertUpload(50, util.createCommit(), 'lsif-go', 'processing')
+        const liveId = await insertUpload(51, util.createCommit(), 'lsif-go', 'processing')
+        const legacyId = await insertUpload(52, util.createCommit(), 'lsif-go', 'processing')
+        const setAges = (id: number, startedAgo: string, lastHeartbeatAgo: string | null): Promise<void> =>
+            connection.query(
+                `
+                    UPDATE lsif_uploads
+                    SET started_at = now() - $2::interval, last_heartbeat_at = now() - $3::interval
+                    WHERE id = $1
+                `,
+                [id, startedAgo, lastHeartbeatAgo]
+            )
+        await setAges(staleId, '1 hour', '5 minutes')
+        await setAges(liveId, '1 hour', '0 seconds')
+        await setAges(legacyId, '10 minutes', null)
+
+        const uploads = (await uploadManager.resetStalled(60)).sort((a, b) => a.id - b.id)
+        expect(uploads.map(({ id, repositoryId }) => ({ id, repositoryId }))).toEqual([
+            { id: staleId, repositoryId: 50 },
+            { id: legacyId, repositoryId: 52 },
+        ])
+
+        // Stalled uploads are aged by their last heartbeat, falling back to the start of their conversion
+        expect(uploads[0].age).toBeGreaterThanOrEqual(5 * 60)
+        expect(uploads[0].age).toBeLessThan(60 * 60)
+        expect(uploads[1].age).toBeGreaterThanOrEqual(10 * 60)
+
+        const states = await connection.query('SELECT id, state FROM lsif_uploads ORDER BY id')
+        expect(states).toEqual([
+            { id: staleId, state: 'queued' },
+            { id: liveId, state: 'processing' },
+            { id: legacyId, state: 'queued' },
+        ])
+    })
+
+    it('should dequeue the oldest queued upload on behalf of a worker', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const newerId = await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+        const olderId = await insertUpload(51, util.createCommit(), 'lsif-go', 'queued')
+        await insertUpload(52, util.createCommit(), 'lsif-go', 'completed')
+        await connection.query("UPDATE lsif_uploads SET uploaded_at = now() - interval '1 hour' WHERE id = $1", [
+            olderId,
+        ])
+
+        const converted: { id: number; state: unknown; workerId: unknown }[] = []
+        const convert = async (upload: pgModels.LsifUpload): Promise<void> => {
+            // The state transition is visible outside of the converting transaction
+            const [{ state, worker_id: workerId }] = await connection.query(
+                'SELECT state, worker_id FROM lsif_uploads WHERE id = $1',
+                [upload.id]
+            )
+            converted.push({ id: upload.id, state, workerId })
+        }
+
+        expect(await uploadManager.dequeueAndConvert(convert, 'worker-1', createSilentLogger())).toBe(true)
+        expect(await uploadManager.dequeueAndConvert(convert, 'worker-2', createSilentLogger())).toBe(true)
+        expect(await uploadManager.dequeueAndConvert(convert, 'worker-1', createSilentLogger())).toBe(false)
+
+        expect(converted).toEqual([
+            { id: olderId, state: 'processing', workerId: 'worker-1' },
+            { id: newerId, state: 'processing', workerId: 'worker-2' },
+        ])
+    })
+
+    it('should record heartbeats only for the worker processing the upload', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const processingId = await insertUpload(50, util.createCommit(), 'lsif-go', 'processing')
+        const queuedId = await insertUpload(51, util.createCommit(), 'lsif-go', 'queued')
+        await connection.query(
+            `
+                UPDATE lsif_uploads SET worker_id = 'worker-1', last_heartbeat_at = now() - interval '1 hour'
+                WHERE id = $1
+            `,
+            [processingId]
+        )
+
+        expect(await uploadManager.heartbeat(processingId, 'worker-2')).toBe(false)
+        expect(await uploadManager.heartbeat(queuedId, 'worker-1')).toBe(false)
+        expect(await uploadManager.heartbeat(processingId, 'worker-1')).toBe(true)
+
+        const [{ recent }] = await connection.query(
+            "SELECT last_heartbeat_at > now() - interval '1 minute' AS recent FROM lsif_uploads WHERE id = $1",
+            [processingId]
+        )
+        expect(recent).toBe(true)
+    })
+
+    it('should record the lifecycle events of an upload', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const id = await insertUpload(50, util.createCommit(), 'lsif-go', 'errored')
+        const updateVisibility = (): Promise<void> => Promise.resolve()
+
+        await uploadManager.requeue(id, 2, origin)
+        await connection.query("UPDATE lsif_uploads SET state = 'completed' WHERE id = $1", [id])
+        await uploadManager.softDeleteUpload(id, updateVisibility, origin)
+        await connection.query("UPDATE lsif_uploads SET deleted_at = now() - interval '1 hour' WHERE id = $1", [id])
+        await uploadManager.purgeDeleted(60)
+
+        // Events remain after the upload has been removed
+        const events = await uploadManager.getEvents(id)
+        expect(events.map(({ event, state, source, actor }) => ({ event, state, source, actor }))).toEqual([
+            { event: 'requeued', state: 'queued', source: 'api', actor: 'user:1' },
+            { event: 'deleted', state: 'deleting', source: 'api', actor: 'user:1' },
+            { event: 'purged', state: null, source: 'janitor', actor: null },
+        ])
+        expect(events[2].message).toEqual('Restore window expired')
+        expect(await uploadManager.getEvents(id + 100)).toEqual([])
+    })
+
+    it('should purge old lifecycle events', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const id = await insertUpload(50, util.createCommit(), 'lsif-go', 'errored')
+        await uploadManager.requeue(id, 2, origin)
+        await connection.query(
+            "UPDATE lsif_upload_events SET created_at = created_at - interval '1 hour' WHERE upload_id = $1",
+            [id]
+        )
+        await connection.query("UPDATE lsif_uploads SET state = 'errored' WHERE id = $1", [id])
+        await uploadManager.requeue(id, 2, origin)
+
+        // Dry runs do not remove anything
+        expect(await uploadManager.purgeEvents(60, true)).toEqual(1)
+        expect(await uploadManager.getEvents(id)).toHaveLength(2)
+
+        expect(await uploadManager.purgeEvents(60)).toEqual(1)
+        expect(await uploadManager.getEvents(id)).toHaveLength(1)
+    })
+
+    it('should not complete an upload deleted during its conversion', async () => {
+        if (!uploadManager) {
+            fail('failed beforeAll')
+        }
+
+        const id = await insertUpload(50, util.createCommit(), 'lsif-go', 'queued')
+
+        const converted = await uploadManager.dequeueAndConvert(
+            async (upload, entityManager) => {
+                // The key share lock does not block the soft deletion of the upload
+                await connection.query("UPDATE lsif_uploads SET state = 'deleting' WHERE id = $1", [upload.id])
+                await uploadManager.markComplete(upload, entityManager)
+            },
+            'worker-1',
+            createSilentLogger()
+        )
+        expect(converted).toBe(true)
+
+        const upload = await connection.getRepository(pgModels.LsifUpload).findOneOrFail(id)
+        expect(upload.state).toEqual('deleting')
+        expect((await uploadManager.getEvents(id)).map(({ event }) => event)).toEqual(['processing'])
+    })
+})
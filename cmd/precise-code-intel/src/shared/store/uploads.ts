@@ -9,6 +9,157 @@ export interface LsifUploadWithPlaceInQueue extends pgModels.LsifUpload {
     placeInQueue: number | null
 }
 
+/**
+ * The maximum age (in seconds) of uploads in each state other than completed. Deleted uploads
+ * are governed by the restore window instead.
+ */
+export type UploadMaxAges = { [K in Exclude<pgModels.LsifUploadState, 'completed' | 'deleting'>]?: number }
+
+/** The fields that identify the data provided by an upload. */
+export interface UploadKey {
+    /** The repository identifier. */
+    repositoryId: number
+
+    /** The commit. */
+    commit: string
+
+    /** The root. */
+    root: string
+
+    /** The name of the indexer that produced the upload. */
+    indexer: string
+}
+
+/** An upload moved back into the `queued` state after its conversion stalled. */
+export interface StalledUpload {
+    /** The upload identifier. */
+    id: number
+
+    /** The repository identifier. */
+    repositoryId: number
+
+    /** The time (in seconds) since the last heartbeat of the upload, or since its conversion started. */
+    age: number
+}
+
+/** The state of the conversion queue for the uploads of a single indexer. */
+export interface IndexerQueueStatus {
+    /** The name of the indexer. */
+    indexer: string
+
+    /** The number of uploads waiting to be converted. */
+    queued: number
+
+    /** The number of uploads being converted. */
+    processing: number
+
+    /** The time (in seconds) that the oldest queued upload has been waiting, or null if none are queued. */
+    oldestQueuedAge: number | null
+
+    /** The number of conversions that finished (successfully or not) within the throughput window. */
+    recentlyFinished: number
+}
+
+/** Filters applied to a listing of uploads across all repositories. */
+export interface UploadFilter {
+    /** Only return uploads in this state. Deleted uploads are excluded unless explicitly requested. */
+    state?: pgModels.LsifUploadState
+
+    /** Only return uploads created by this indexer. */
+    indexer?: string
+
+    /** Only return uploads of this repository. */
+    repositoryId?: number
+
+    /** Only return uploads uploaded at or after this time. */
+    uploadedAfter?: Date
+
+    /** Only return uploads uploaded before this time. */
+    uploadedBefore?: Date
+
+    /** Only return uploads with a commit, root, indexer, or failure matching this search query. */
+    query?: string
+
+    /** If true, only return dumps visible at tip. */
+    visibleAtTip?: boolean
+}
+
+/** The position of the last upload of a page of uploads ordered from newest to oldest. */
+export interface UploadCursor {
+    /**
+     * The upload time of the last upload, as formatted by Postgres. This is not parsed into
+     * a Date, which would truncate the timestamp to millisecond precision.
+     */
+    uploadedAt: string
+
+    /** The identifier of the last upload, which breaks ties between equal upload times. */
+    id: number
+}
+
+/** The component and user that caused an upload event. */
+export interface UploadEventOrigin {
+    /** The component that caused the event. */
+    source: 'api' | 'worker' | 'janitor'
+
+    /** The user on whose behalf the API request that caused the event was made (if known). */
+    actor?: string
+}
+
+/** The origin of events caused by the conversion of an upload. */
+export const WORKER_ORIGIN: UploadEventOrigin = { source: 'worker' }
+
+/** The origin of events caused by background cleanup tasks. */
+export const JANITOR_ORIGIN: UploadEventOrigin = { source: 'janitor' }
+
+/**
+ * Create a condition matching uploads with a commit, root, indexer, or failure that contains
+ * the given search query.
+ *
+ * @param query The search query.
+ */
+function searchClause(query: string): Brackets {
+    const clauses = ['commit', 'root', 'indexer', 'failure_summary', 'failure_stacktrace'].map(
+        field => `"${field}" LIKE '%' || :query || '%'`
+    )
+
+    return new Brackets(qb =>
+        clauses.slice(1).reduce((ob, c) => ob.orWhere(c, { query }), qb.where(clauses[0], { query }))
+    )
+}
+
+/**
+ * Record an event in the lifecycle of each of the given uploads.
+ *
+ * @param entityManager The EntityManager to use as part of a transaction.
+ * @param ids The upload identifiers.
+ * @param event The kind of event.
+ * @param state The state of the uploads after the event, or null if they were removed.
+ * @param origin The component and user that caused the event.
+ * @param message A description of the reason for the event.
+ */
+export async function recordUploadEvents(
+    entityManager: EntityManager,
+    ids: pgModels.DumpId[],
+    event: pgModels.LsifUploadEventType,
+    state: pgModels.LsifUploadState | null,
+    { source, actor }: UploadEventOrigin,
+    message?: string
+): Promise<void> {
+    if (ids.length === 0) {
+        return
+    }
+
+    await instrumentQuery(() =>
+        entityManager.query(
+            `
+                INSERT INTO lsif_upload_events (upload_id, event, state, source, actor, message)
+                SELECT id, $2, $3, $4, $5, $6 FROM unnest($1::integer[]) AS id
+            `,
+            [ids, event, state, source, actor || null, message || null]
+        )
+    )
+}
+
 /**
  * A wrapper around the database tables that control uploads. This class has
  * behaviors to enqueue uploads and dequeue them for the worker process to
@@ -41,7 +192,91 @@ export class UploadManager {
     }
 
     /**
-     * Get the uploads in the given state.
+     * Get the count of uploads in each state. States without any uploads are omitted.
+     *
+     * @param entityManager An entity manager to use if within a transaction.
+     */
+    public async getCountsByState(
+        entityManager: EntityManager = this.connection.createEntityManager()
+    ): Promise<Map<pgModels.LsifUploadState, number>> {
+        const results: { state: pgModels.LsifUploadState; count: string }[] = await entityManager
+            .getRepository(pgModels.LsifUpload)
+            .createQueryBuilder('upload')
+            .select('upload.state', 'state')
+            .addSelect('COUNT(*)', 'count')
+            .groupBy('upload.state')
+            .getRawMany()
+
+        return new Map(results.map(({ state, count }) => [state, parseInt(count, 10)]))
+    }
+
+    /**
+     * Count the uploads waiting to be converted, both for the given repository and in total.
+     *
+     * @param repositoryId The repository identifier.
+     */
+    public async countQueued(repositoryId: number): Promise<{ repository: number; total: number }> {
+        const results: { repository: string; total: string }[] = await instrumentQuery(() =>
+            this.connection.query(
+                `
+                    SELECT
+                        (SELECT COUNT(*) FROM lsif_uploads WHERE state = 'queued' AND repository_id = $1) AS repository,
+                        (SELECT COUNT(*) FROM lsif_uploads WHERE state = 'queued') AS total
+                `,
+                [repositoryId]
+            )
+        )
+
+        return { repository: parseInt(results[0].repository, 10), total: parseInt(results[0].total, 10) }
+    }
+
+    /**
+     * Get the state of the conversion queue for each indexer with queued, processing, or
+     * recently finished uploads.
+     *
+     * @param throughputWindow The time (in seconds) over which finished conversions are counted.
+     */
+    public async getQueueStatus(throughputWindow: number): Promise<IndexerQueueStatus[]> {
+        const results: {
+            indexer: string
+            queued: string
+            processing: string
+            oldest_queued_age: number | null
+            recently_finished: string
+        }[] = await instrumentQuery(() =>
+            this.connection.query(
+                `
+                    SELECT
+                        indexer,
+                        COUNT(*) FILTER (WHERE state = 'queued') AS queued,
+                        COUNT(*) FILTER (WHERE state = 'processing') AS processing,
+                        EXTRACT(
+                            EPOCH FROM now() - MIN(uploaded_at) FILTER (WHERE state = 'queued')
+                        ) AS oldest_queued_age,
+                        COUNT(*) FILTER (WHERE state NOT IN ('queued', 'processing')) AS recently_finished
+                    FROM lsif_uploads
+                    WHERE
+                        state IN ('queued', 'processing') OR
+                        finished_at >= now() - ($1 * interval '1 second')
+                    GROUP BY indexer
+                    ORDER BY indexer
+                `,
+                [throughputWindow]
+            )
+        )
+
+        return results.map(({ indexer, queued, processing, oldest_queued_age, recently_finished }) => ({
+            indexer,
+            queued: parseInt(queued, 10),
+            processing: parseInt(processing, 10),
+            oldestQueuedAge: oldest_queued_age,
+            recentlyFinished: parseInt(recently_finished, 10),
+        }))
+    }
+
+    /**
+     * Get the uploads in the given state. Deleted uploads are only returned when explicitly
+     * requested via the `deleting` state.
      *
      * @param repositoryId The repository identifier.
      * @param state The state.
@@ -58,15 +293,85 @@ export class UploadManager {
         limit: number,
         offset: number
     ): Promise<{ uploads: LsifUploadWithPlaceInQueue[]; totalCount: number }> {
-        const { uploads, raw, totalCount } = await instrumentQuery<{
+        // Read the page and the total count from the same snapshot so that the count is
+        // consistent with the page even when uploads are added or removed concurrently.
+        const { uploads, raw, totalCount } = await withInstrumentedTransaction<{
             uploads: pgModels.LsifUpload[]
             raw: { upload_id: number; rank: string | undefined }[]
             totalCount: number
-        }>(async () => {
+        }>(
+            this.connection,
+            async entityManager => {
+                let queryBuilder = entityManager
+                    .getRepository(pgModels.LsifUpload)
+                    .createQueryBuilder('upload')
+                    .addSelect('ranked.rank', 'rank')
+                    .leftJoin(
+                        qb =>
+                            qb
+                                .subQuery()
+                                .select('ranked.id, RANK() OVER (ORDER BY ranked.uploaded_at) as rank')
+                                .from(pgModels.LsifUpload, 'ranked')
+                                .where("ranked.state = 'queued'"),
+                        'ranked',
+                        'ranked.id = upload.id'
+                    )
+                    .where({ repositoryId })
+                    .orderBy('uploaded_at', 'DESC')
+                    .limit(limit)
+                    .offset(offset)
+
+                if (state) {
+                    queryBuilder = queryBuilder.andWhere('state = :state', { state })
+                } else {
+                    queryBuilder = queryBuilder.andWhere("state != 'deleting'")
+                }
+
+                if (query) {
+                    queryBuilder = queryBuilder.andWhere(searchClause(query))
+                }
+
+                if (visibleAtTip) {
+                    queryBuilder = queryBuilder.andWhere('visible_at_tip = true')
+                }
+
+                const { entities, raw: rawEntities } = await queryBuilder.getRawAndEntities()
+                const count = await queryBuilder.getCount()
+
+                return { uploads: entities, raw: rawEntities, totalCount: count }
+            },
+            'REPEATABLE READ'
+        )
+
+        const ranks = new Map(raw.map(r => [r.upload_id, parseInt(r.rank || '', 10)]))
+        return { uploads: uploads.map(u => ({ ...u, placeInQueue: ranks.get(u.id) || null })), totalCount }
+    }
+
+    /**
+     * Get a page of uploads across all repositories, ordered from newest to oldest. This uses
+     * keyset pagination so that pages remain stable while new uploads are enqueued.
+     *
+     * @param filter The filters to apply.
+     * @param limit The maximum number of uploads to return.
+     * @param cursor The position of the last upload of the previous page.
+     */
+    public async getAllUploads(
+        { state, indexer, repositoryId, uploadedAfter, uploadedBefore, query, visibleAtTip }: UploadFilter,
+        limit: number,
+        cursor?: UploadCursor
+    ): Promise<{ uploads: LsifUploadWithPlaceInQueue[]; nextCursor?: UploadCursor }> {
+        const {
+            entities,
+            raw,
+        }: {
+            entities: pgModels.LsifUpload[]
+            raw: { rank: string | null; cursor_uploaded_at: string }[]
+        } = await instrumentQuery(() => {
             let queryBuilder = this.connection
                 .getRepository(pgModels.LsifUpload)
                 .createQueryBuilder('upload')
                 .addSelect('ranked.rank', 'rank')
+                .addSelect('upload.uploaded_at::text', 'cursor_uploaded_at')
                 .leftJoin(
                     qb =>
                         qb
@@ -77,45 +382,60 @@ export class UploadManager {
                     'ranked',
                     'ranked.id = upload.id'
                 )
-                .where({ repositoryId })
-                .orderBy('uploaded_at', 'DESC')
-                .limit(limit)
-                .offset(offset)
+                .orderBy('upload.uploaded_at', 'DESC')
+                .addOrderBy('upload.id', 'DESC')
+                // Fetch one extra upload to determine if there is a next page
+                .limit(limit + 1)
 
             if (state) {
-                queryBuilder = queryBuilder.andWhere('state = :state', { state })
+                queryBuilder = queryBuilder.where('upload.state = :state', { state })
+            } else {
+                queryBuilder = queryBuilder.where("upload.state != 'deleting'")
             }
 
+            if (indexer) {
+                queryBuilder = queryBuilder.andWhere('upload.indexer = :indexer', { indexer })
+            }
+            if (repositoryId !== undefined) {
+                queryBuilder = queryBuilder.andWhere('upload.repository_id = :repositoryId', { repositoryId })
+            }
+            if (uploadedAfter) {
+                queryBuilder = queryBuilder.andWhere('upload.uploaded_at >= :uploadedAfter', { uploadedAfter })
+            }
+            if (uploadedBefore) {
+                queryBuilder = queryBuilder.andWhere('upload.uploaded_at < :uploadedBefore', { uploadedBefore })
+            }
             if (query) {
-                const clauses = ['commit', 'root', 'indexerName', 'failure_summary', 'failure_stacktrace'].map(
-                    field => `"${field}" LIKE '%' || :query || '%'`
-                )
+                queryBuilder = queryBuilder.andWhere(searchClause(query))
+            }
+            if (visibleAtTip) {
+                queryBuilder = queryBuilder.andWhere('upload.visible_at_tip = true')
+            }
 
+            if (cursor) {
                 queryBuilder = queryBuilder.andWhere(
-                    new Brackets(qb =>
-                        clauses.slice(1).reduce((ob, c) => ob.orWhere(c, { query }), qb.where(clauses[0], { query }))
-                    )
+                    '(upload.uploaded_at, upload.id) < (CAST(:cursorUploadedAt AS timestamptz), :cursorId)',
+                    { cursorUploadedAt: cursor.uploadedAt, cursorId: cursor.id }
                 )
             }
 
-            if (visibleAtTip) {
-                queryBuilder = queryBuilder.andWhere('visible_at_tip = true')
-            }
+            return queryBuilder.getRawAndEntities()
+        })
 
-            const [{ entities, raw: rawEntities }, count] = await Promise.all([
-                queryBuilder.getRawAndEntities(),
-                queryBuilder.getCount(),
-            ])
+        const uploads = entities
+            .slice(0, limit)
+            .map((u, i) => ({ ...u, placeInQueue: parseInt(raw[i].rank || '', 10) || null }))
 
-            return { uploads: entities, raw: rawEntities, totalCount: count }
-        })
+        if (entities.length <= limit) {
+            return { uploads }
+        }
 
-        const ranks = new Map(raw.map(r => [r.upload_id, parseInt(r.rank || '', 10)]))
-        return { uploads: uploads.map(u => ({ ...u, placeInQueue: ranks.get(u.id) || null })), totalCount }
+        const last = uploads[uploads.length - 1]
+        return { uploads, nextCursor: { uploadedAt: raw[limit - 1].cursor_uploaded_at, id: last.id } }
     }
 
     /**
-     * Get an upload by identifier.
+     * Get an upload by identifier. Deleted uploads are not returned.
      *
      * @param id The upload identifier.
      */
@@ -139,6 +459,7 @@ export class UploadManager {
                     'ranked.id = upload.id'
                 )
                 .where({ id })
+                .andWhere("upload.state != 'deleting'")
                 .limit(1)
                 .getRawAndEntities()
 
@@ -150,6 +471,20 @@ export class UploadManager {
         })
     }
 
+    /**
+     * Get the lifecycle events of an upload, oldest first. Events are returned for uploads
+     * that have since been removed.
+     *
+     * @param id The upload identifier.
+     */
+    public getEvents(id: number): Promise<pgModels.LsifUploadEvent[]> {
+        return instrumentQuery(() =>
+            this.connection
+                .getRepository(pgModels.LsifUploadEvent)
+                .find({ where: { uploadId: id }, order: { createdAt: 'ASC', id: 'ASC' } })
+        )
+    }
+
     /**
      * Delete an upload. This returns true if the upload existed. Also remove referenced
      * package and reference rows if the upload was successfully processed.
@@ -162,10 +497,14 @@ export class UploadManager {
      * @param updateVisibility A function that updates the dumps visible at the tip for
      *     the given repository. This is called if the deleted dump was visible at tip,
      *     as a previously non-visible dump may become visible after deletion.
+     * @param origin The component and user that caused the deletion.
+     * @param reason A description of the reason for the deletion.
      */
     public async deleteUpload(
         id: number,
-        updateVisibility: (entityManager: EntityManager, repositoryId: number) => Promise<void>
+        updateVisibility: (entityManager: EntityManager, repositoryId: number) => Promise<void>,
+        origin: UploadEventOrigin,
+        reason?: string
     ): Promise<boolean> {
         return withInstrumentedTransaction(this.connection, async entityManager => {
             const [affected, numAffected]: [
@@ -181,6 +520,8 @@ export class UploadManager {
                 return false
             }
 
+            await recordUploadEvents(entityManager, [id], 'purged', null, origin, reason)
+
             if (affected[0].visible_at_tip) {
                 await updateVisibility(entityManager, affected[0].repository_id)
             }
@@ -190,48 +531,401 @@ export class UploadManager {
     }
 
     /**
-     * Remove all uploads that are older than `maxAge` seconds. Returns the count of deleted uploads.
+     * Mark an upload as deleted. The upload is hidden from queries but can be restored with
+     * `restoreUpload` until it is purged by `purgeDeleted`. This returns true if the upload
+     * existed and was not already deleted.
      *
-     * @param maxAge The maximum age for an upload.
+     * @param id The upload identifier.
+     * @param updateVisibility A function that updates the dumps visible at the tip for
+     *     the given repository. This is called if the deleted dump was visible at tip,
+     *     as a previously non-visible dump may become visible after deletion.
+     * @param origin The component and user that caused the deletion.
      */
-    public async clean(maxAge: number): Promise<number> {
-        return (
-            (
-                await instrumentQuery(() =>
-                    this.connection
-                        .getRepository(pgModels.LsifUpload)
-                        .createQueryBuilder()
-                        .delete()
-                        .where("state != 'completed'")
-                        .andWhere("uploaded_at < now() - (:maxAge * interval '1 second')", { maxAge })
-                        .execute()
+    public async softDeleteUpload(
+        id: number,
+        updateVisibility: (entityManager: EntityManager, repositoryId: number) => Promise<void>,
+        origin: UploadEventOrigin
+    ): Promise<boolean> {
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const [affected, numAffected]: [
+                { repository_id: number; visible_at_tip: boolean }[],
+                number
+            ] = await instrumentQuery(() =>
+                entityManager.query(
+                    `
+                        UPDATE lsif_uploads u
+                        SET
+                            state = 'deleting',
+                            state_before_delete = old.state,
+                            deleted_at = now(),
+                            visible_at_tip = false
+                        FROM (SELECT id, state, visible_at_tip FROM lsif_uploads WHERE id = $1 FOR UPDATE) old
+                        WHERE u.id = old.id AND old.state != 'deleting'
+                        RETURNING u.repository_id, old.visible_at_tip
+                    `,
+                    [id]
                 )
-            ).affected || 0
-        )
+            )
+
+            if (numAffected === 0) {
+                return false
+            }
+
+            await recordUploadEvents(entityManager, [id], 'deleted', 'deleting', origin)
+
+            if (affected[0].visible_at_tip) {
+                await updateVisibility(entityManager, affected[0].repository_id)
+            }
+
+            return true
+        })
     }
 
     /**
-     * Move all processing uploads started more than `maxAge` seconds ago that are not currently
-     * locked back to the `queued` state.
+     * Move a completed upload whose bundle failed verification into the errored state so
+     * that it is no longer used to answer queries and can be retried with `requeue`. This
+     * returns true if the upload existed and was completed.
      *
-     * @param maxAge The maximum age for an unlocked upload in the `processing` state.
+     * @param id The upload identifier.
+     * @param problems The failed checks reported by the bundle manager.
+     * @param updateVisibility A function that updates the dumps visible at the tip for
+     *     the given repository. This is called if the corrupted dump was visible at tip,
+     *     as a previously non-visible dump may become visible in its place.
+     * @param origin The component and user that requested the verification.
      */
-    public async resetStalled(maxAge: number): Promise<number[]> {
-        const results: [{ id: number }[]] = await instrumentQuery(() =>
-            this.connection.query(
+    public async markCorrupted(
+        id: number,
+        problems: string[],
+        updateVisibility: (entityManager: EntityManager, repositoryId: number) => Promise<void>,
+        origin: UploadEventOrigin
+    ): Promise<boolean> {
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const [affected, numAffected]: [
+                { repository_id: number; visible_at_tip: boolean }[],
+                number
+            ] = await instrumentQuery(() =>
+                entityManager.query(
+                    `
+                        UPDATE lsif_uploads u
+                        SET
+                            state = 'errored',
+                            failure_summary = 'Bundle failed verification',
+                            failure_stacktrace = $2,
+                            finished_at = now(),
+                            visible_at_tip = false
+                        FROM (SELECT id, visible_at_tip FROM lsif_uploads WHERE id = $1 FOR UPDATE) old
+                        WHERE u.id = old.id AND u.state = 'completed'
+                        RETURNING u.repository_id, old.visible_at_tip
+                    `,
+                    [id, problems.join('\n')]
+                )
+            )
+
+            if (numAffected === 0) {
+                return false
+            }
+
+            await recordUploadEvents(entityManager, [id], 'errored', 'errored', origin, 'Bundle failed verification')
+
+            if (affected[0].visible_at_tip) {
+                await updateVisibility(entityManager, affected[0].repository_id)
+            }
+
+            return true
+        })
+    }
+
+    /**
+     * Move a deleted upload back into the state it had before it was deleted. Returns `restored`
+     * on success, `expired` if the upload was deleted more than `restoreWindow` seconds ago, and
+     * `conflict` if the upload was completed and another completed upload for the same commit,
+     * root, and indexer has since been processed. Returns undefined if the upload does not exist
+     * or is not deleted.
+     *
+     * @param id The upload identifier.
+     * @param restoreWindow The number of seconds after deletion that an upload can be restored.
+     * @param updateVisibility A function that updates the dumps visible at the tip for
+     *     the given repository. This is called if the restored upload was completed, as
+     *     it may become visible at tip.
+     * @param origin The component and user that caused the restoration.
+     */
+    public async restoreUpload(
+        id: number,
+        restoreWindow: number,
+        updateVisibility: (entityManager: EntityManager, repositoryId: number) => Promise<void>,
+        origin: UploadEventOrigin
+    ): Promise<'restored' | 'expired' | 'conflict' | undefined> {
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const results: {
+                repository_id: number
+                commit: string
+                root: string
+                indexer: string
+                state_before_delete: pgModels.LsifUploadState
+                expired: boolean
+            }[] = await instrumentQuery(() =>
+                entityManager.query(
+                    `
+                        SELECT
+                            repository_id,
+                            "commit",
+                            root,
+                            indexer,
+                            state_before_delete,
+                            deleted_at < now() - ($2 * interval '1 second') AS expired
+                        FROM lsif_uploads
+                        WHERE id = $1 AND state = 'deleting'
+                        FOR UPDATE
+                    `,
+                    [id, restoreWindow]
+                )
+            )
+
+            if (results.length === 0) {
+                return undefined
+            }
+
+            const { repository_id: repositoryId, commit, root, indexer, state_before_delete: state } = results[0]
+            if (results[0].expired) {
+                return 'expired'
+            }
+
+            if (state === 'completed') {
+                const conflicts: { id: number }[] = await instrumentQuery(() =>
+                    entityManager.query(
+                        `
+                            SELECT id FROM lsif_uploads
+                            WHERE repository_id = $1 AND "commit" = $2 AND root = $3 AND indexer = $4
+                            AND state = 'completed'
+                            LIMIT 1
+                        `,
+                        [repositoryId, commit, root, indexer]
+                    )
+                )
+
+                if (conflicts.length > 0) {
+                    return 'conflict'
+                }
+            }
+
+            await instrumentQuery(() =>
+                entityManager.query(
+                    `
+                        UPDATE lsif_uploads
+                        SET state = state_before_delete, state_before_delete = null, deleted_at = null
+                        WHERE id = $1
+                    `,
+                    [id]
+                )
+            )
+
+            await recordUploadEvents(entityManager, [id], 'restored', state, origin)
+
+            if (state === 'completed') {
+                await updateVisibility(entityManager, repositoryId)
+            }
+
+            return 'restored'
+        })
+    }
+
+    /**
+     * Remove all uploads that were deleted more than `restoreWindow` seconds ago. Returns the
+     * identifiers of the removed uploads. The files of the removed uploads are cleaned up later
+     * by the bundle manager, as is done for `deleteUpload`.
+     *
+     * @param restoreWindow The number of seconds after deletion that an upload can be restored.
+     * @param dryRun If true, return the uploads that would be removed without removing them.
+     */
+    public async purgeDeleted(restoreWindow: number, dryRun = false): Promise<number[]> {
+        const where = "state = 'deleting' AND deleted_at < now() - ($1 * interval '1 second')"
+        if (dryRun) {
+            const rows: { id: number }[] = await instrumentQuery(() =>
+                this.connection.query(`SELECT id FROM lsif_uploads WHERE ${where}`, [restoreWindow])
+            )
+
+            return rows.map(({ id }) => id)
+        }
+
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const results: [{ id: number }[]] = await entityManager.query(
+                `DELETE FROM lsif_uploads WHERE ${where} RETURNING id`,
+                [restoreWindow]
+            )
+
+            const ids = results[0].map(({ id }) => id)
+            await recordUploadEvents(entityManager, ids, 'purged', null, JANITOR_ORIGIN, 'Restore window expired')
+            return ids
+        })
+    }
+
+    /**
+     * Remove all upload lifecycle events that were recorded more than `maxAge` seconds ago.
+     * Returns the number of removed events.
+     *
+     * @param maxAge The maximum age (in seconds) of events.
+     * @param dryRun If true, return the number of events that would be removed without removing them.
+     */
+    public async purgeEvents(maxAge: number, dryRun = false): Promise<number> {
+        const where = "created_at < now() - ($1 * interval '1 second')"
+        const query = dryRun
+            ? `SELECT COUNT(*) AS count FROM lsif_upload_events WHERE ${where}`
+            : `
+                WITH deleted AS (DELETE FROM lsif_upload_events WHERE ${where} RETURNING 1)
+                SELECT COUNT(*) AS count FROM deleted
+            `
+
+        const rows: { count: string }[] = await instrumentQuery(() => this.connection.query(query, [maxAge]))
+        return parseInt(rows[0].count, 10)
+    }
+
+    /**
+     * Remove all uploads that are older than the maximum age of their state. Completed uploads
+     * are never removed, as their lifetime is governed by the dump pruning policy. Returns the
+     * identifiers of the removed uploads.
+     *
+     * @param maxAges The maximum age (in seconds) of uploads in each state. Uploads in a state
+     *     without a maximum age, or with a negative maximum age, are not removed.
+     * @param dryRun If true, return the uploads that would be removed without removing them.
+     */
+    public async clean(maxAges: UploadMaxAges, dryRun = false): Promise<number[]> {
+        const conditions: string[] = []
+        const params: (string | number)[] = []
+        for (const [state, maxAge] of Object.entries(maxAges)) {
+            if (maxAge === undefined || maxAge < 0) {
+                continue
+            }
+
+            params.push(state, maxAge)
+            conditions.push(
+                `(state = $${params.length - 1} AND uploaded_at < now() - ($${params.length} * interval '1 second'))`
+            )
+        }
+
+        if (conditions.length === 0) {
+            return []
+        }
+
+        const where = conditions.join(' OR ')
+        if (dryRun) {
+            const rows: { id: number }[] = await instrumentQuery(() =>
+                this.connection.query(`SELECT id FROM lsif_uploads WHERE ${where}`, params)
+            )
+
+            return rows.map(({ id }) => id)
+        }
+
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const results: [{ id: number }[]] = await entityManager.query(
+                `DELETE FROM lsif_uploads WHERE ${where} RETURNING id`,
+                params
+            )
+
+            const ids = results[0].map(({ id }) => id)
+            await recordUploadEvents(
+                entityManager,
+                ids,
+                'purged',
+                null,
+                JANITOR_ORIGIN,
+                'Exceeded the maximum age of its state'
+            )
+            return ids
+        })
+    }
+
+    /**
+     * Move all processing uploads whose worker has not recorded a heartbeat in the last `maxAge`
+     * seconds and that are not currently locked back to the `queued` state. Uploads dequeued
+     * before heartbeats were recorded are aged by the time their conversion started. Returns
+     * the uploads that were reset.
+     *
+     * @param maxAge The maximum age of the last heartbeat of an unlocked upload in the `processing` state.
+     */
+    public async resetStalled(maxAge: number): Promise<StalledUpload[]> {
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const results: [{ id: number; repository_id: number; age: number }[]] = await entityManager.query(
                 `
-                    UPDATE lsif_uploads u SET state = 'queued', started_at = null WHERE id = ANY(
-                        SELECT id FROM lsif_uploads
-                        WHERE state = 'processing' AND started_at < now() - ($1 * interval '1 second')
+                    WITH stalled AS (
+                        SELECT id, extract(epoch FROM now() - COALESCE(last_heartbeat_at, started_at)) AS age
+                        FROM lsif_uploads
+                        WHERE
+                            state = 'processing' AND
+                            COALESCE(last_heartbeat_at, started_at) < now() - ($1 * interval '1 second')
                         FOR UPDATE SKIP LOCKED
                     )
-                    RETURNING u.id
+                    UPDATE lsif_uploads u
+                    SET state = 'queued', started_at = null, worker_id = null, last_heartbeat_at = null
+                    FROM stalled s
+                    WHERE u.id = s.id
+                    RETURNING u.id, u.repository_id, s.age
                 `,
                 [maxAge]
             )
-        )
 
-        return results[0].map(r => r.id)
+            const uploads = results[0].map(r => ({ id: r.id, repositoryId: r.repository_id, age: Number(r.age) }))
+            await recordUploadEvents(
+                entityManager,
+                uploads.map(u => u.id),
+                'reset',
+                'queued',
+                JANITOR_ORIGIN,
+                'Conversion stalled'
+            )
+            return uploads
+        })
+    }
+
+    /**
+     * Move an errored upload back to the `queued` state so that it is converted again. If the
+     * upload has already been requeued `maxAttempts` times, it is moved into the terminal
+     * `failed` state instead. Returns the new state of the upload, or undefined if the upload
+     * does not exist or is not in the `errored` state.
+     *
+     * @param id The upload identifier.
+     * @param maxAttempts The maximum number of times an upload can be requeued.
+     * @param origin The component and user that caused the retry.
+     */
+    public async requeue(
+        id: number,
+        maxAttempts: number,
+        origin: UploadEventOrigin
+    ): Promise<'queued' | 'failed' | undefined> {
+        return withInstrumentedTransaction(this.connection, async entityManager => {
+            const queued: [{ id: number }[]] = await entityManager.query(
+                `
+                    UPDATE lsif_uploads
+                    SET
+                        state = 'queued',
+                        attempts = attempts + 1,
+                        last_retried_at = now(),
+                        started_at = null,
+                        finished_at = null,
+                        failure_summary = null,
+                        failure_stacktrace = null
+                    WHERE id = $1 AND state = 'errored' AND attempts < $2
+                    RETURNING id
+                `,
+                [id, maxAttempts]
+            )
+
+            if (queued[0].length > 0) {
+                await recordUploadEvents(entityManager, [id], 'requeued', 'queued', origin)
+                return 'queued'
+            }
+
+            const failed: [{ id: number }[]] = await entityManager.query(
+                "UPDATE lsif_uploads SET state = 'failed' WHERE id = $1 AND state = 'errored' RETURNING id",
+                [id]
+            )
+
+            if (failed[0].length > 0) {
+                await recordUploadEvents(entityManager, [id], 'failed', 'failed', origin, 'Exhausted retries')
+                return 'failed'
+            }
+
+            return undefined
+        })
     }
 
     /**
@@ -241,6 +935,7 @@ export class UploadManager {
      * @param entityManager The EntityManager to use as part of a transaction.
      * @param tracer The tracer instance.
      * @param span The parent span.
+     * @param origin The component and user that caused the upload.
      */
     public async enqueue(
         {
@@ -248,6 +943,11 @@ export class UploadManager {
             commit,
             root,
             indexer,
+            checksum,
+            lsifVersion,
+            positionEncoding,
+            projectRoot,
+            format = 'lsif',
         }: {
             /** The repository identifier. */
             repositoryId: number
@@ -257,10 +957,21 @@ export class UploadManager {
             root: string
             /** The indexer binary name that produced this dump as specified by the metadata. */
             indexer: string
+            /** The hex-encoded SHA-256 digest of the raw upload. */
+            checksum?: string
+            /** The version of LSIF given by the metadata vertex of the upload. */
+            lsifVersion?: string
+            /** The position encoding given by the metadata vertex of the upload. */
+            positionEncoding?: string
+            /** The project root given by the metadata vertex of the upload. */
+            projectRoot?: string
+            /** The input format of the upload. */
+            format?: pgModels.LsifUploadFormat
         },
         entityManager: EntityManager = this.connection.createEntityManager(),
         tracer?: Tracer,
-        span?: Span
+        span?: Span,
+        origin: UploadEventOrigin = { source: 'api' }
     ): Promise<number> {
         const tracing = {}
         if (tracer && span) {
@@ -272,17 +983,89 @@ export class UploadManager {
                 .createQueryBuilder()
                 .insert()
                 .into(pgModels.LsifUpload)
-                .values({ repositoryId, commit, root, indexer, tracingContext: JSON.stringify(tracing) })
+                .values({
+                    repositoryId,
+                    commit,
+                    root,
+                    indexer,
+                    checksum: checksum || null,
+                    lsifVersion: lsifVersion || null,
+                    positionEncoding: positionEncoding || null,
+                    projectRoot: projectRoot || null,
+                    format,
+                    tracingContext: JSON.stringify(tracing),
+                })
                 .execute()
         )
 
-        return identifiers[0].id
+        const id: number = identifiers[0].id
+        await recordUploadEvents(entityManager, [id], 'enqueued', 'queued', origin)
+        return id
+    }
+
+    /**
+     * Get the most recent queued, processing, or completed upload with the given key and
+     * checksum. An upload whose payload has no recorded checksum is never a duplicate.
+     *
+     * @param key The repository, commit, root, and indexer of the upload.
+     * @param checksum The hex-encoded SHA-256 digest of the raw upload.
+     * @param entityManager An entity manager to use if within a transaction.
+     */
+    public findDuplicate(
+        { repositoryId, commit, root, indexer }: UploadKey,
+        checksum: string,
+        entityManager: EntityManager = this.connection.createEntityManager()
+    ): Promise<pgModels.LsifUpload | undefined> {
+        return instrumentQuery(() =>
+            entityManager
+                .getRepository(pgModels.LsifUpload)
+                .createQueryBuilder('upload')
+                .where({ repositoryId, commit, root, indexer, checksum })
+                .andWhere("upload.state IN ('queued', 'processing', 'completed')")
+                .orderBy('upload.uploaded_at', 'DESC')
+                .getOne()
+        )
+    }
+
+    /**
+     * Remove the queued uploads with the same key as the given upload, which would otherwise
+     * be converted only to be replaced by the given upload. Uploads that are already being
+     * converted are left alone. Returns the identifiers of the removed uploads.
+     *
+     * @param id The identifier of the superseding upload.
+     * @param key The repository, commit, root, and indexer of the superseding upload.
+     * @param entityManager The EntityManager to use as part of a transaction.
+     * @param origin The component and user that caused the removal.
+     */
+    public async supersedeQueued(
+        id: number,
+        { repositoryId, commit, root, indexer }: UploadKey,
+        entityManager: EntityManager,
+        origin: UploadEventOrigin
+    ): Promise<number[]> {
+        const results: [{ id: number }[]] = await instrumentQuery(() =>
+            entityManager.query(
+                `
+                    DELETE FROM lsif_uploads
+                    WHERE
+                        repository_id = $1 AND "commit" = $2 AND root = $3 AND indexer = $4 AND
+                        state = 'queued' AND id != $5
+                    RETURNING id
+                `,
+                [repositoryId, commit, root, indexer, id]
+            )
+        )
+
+        const ids = results[0].map(row => row.id)
+        await recordUploadEvents(entityManager, ids, 'purged', null, origin, `Superseded by upload ${id}`)
+        return ids
     }
 
     /**
      * Lock and convert a queued upload. If the conversion function throws an error, then
-     * the error summary and stack trace will be written to the upload record and the state
-     * will be set to "errored".
+     * the changes it made are discarded, the error summary and stack trace will be written to
+     * the upload record and the state will be set to "errored". An upload that has left the
+     * processing state in the meantime (e.g. it was soft deleted) is left untouched.
      *
      * The convert callback is invoked with the locked upload record and the entity manager
      * that locked the record. The callback should use it to operate in the same transaction.
@@ -292,38 +1075,61 @@ export class UploadManager {
      * head of the queue by being re-processed ad nauseam.
      *
      * @param convert The function to call with the locked upload.
+     * @param workerId The identifier of this worker process, recorded on the upload.
      * @param logger The logger instance.
      */
     public async dequeueAndConvert(
         convert: (upload: pgModels.LsifUpload, entityManager: EntityManager) => Promise<void>,
+        workerId: string,
         logger: Logger
     ): Promise<boolean> {
         // First, we select the next oldest upload with a state of `queued` and set
         // its state to `processing`. We do this outside of a transaction so that this
         // state transition is visible to the API. We skip any locked rows as they are
         // being handled by another worker process.
-        const lockResult: [{ id: number }[]] = await this.connection.query(`
-            UPDATE lsif_uploads u SET state = 'processing', started_at = now() WHERE id = (
-                SELECT id FROM lsif_uploads
-                WHERE state = 'queued'
-                ORDER BY uploaded_at
-                FOR UPDATE SKIP LOCKED LIMIT 1
-            )
-            RETURNING u.id
-        `)
+        const lockResult: [{ id: number }[]] = await this.connection.query(
+            `
+                UPDATE lsif_uploads u
+                SET
+                    state = 'processing',
+                    started_at = now(),
+                    progress = 0,
+                    stage = NULL,
+                    worker_id = $1,
+                    last_heartbeat_at = now()
+                WHERE id = (
+                    SELECT id FROM lsif_uploads
+                    WHERE state = 'queued'
+                    ORDER BY uploaded_at
+                    FOR UPDATE SKIP LOCKED LIMIT 1
+                )
+                RETURNING u.id
+            `,
+            [workerId]
+        )
         if (lockResult[0].length === 0) {
             return false
         }
         const uploadId = lockResult[0][0].id
+        await recordUploadEvents(
+            this.connection.createEntityManager(),
+            [uploadId],
+            'processing',
+            'processing',
+            WORKER_ORIGIN
+        )
 
         return withInstrumentedTransaction(this.connection, async entityManager => {
+            // The key share lock conflicts with the locks taken by the janitor and by requests
+            // that delete or requeue the upload, but not with updates of non-key columns. This
+            // allows the progress of the conversion to be recorded outside of the transaction.
             const results: object[] = await entityManager.query(
-                'SELECT * FROM lsif_uploads WHERE id = $1 FOR UPDATE LIMIT 1',
+                "SELECT * FROM lsif_uploads WHERE id = $1 AND state = 'processing' FOR KEY SHARE LIMIT 1",
                 [uploadId]
             )
             if (results.length === 0) {
-                // Record was deleted in race, retry
-                return this.dequeueAndConvert(convert, logger)
+                // Record was deleted or soft deleted in race, retry
+                return this.dequeueAndConvert(convert, workerId, logger)
             }
 
             // Transform locked result into upload entity
@@ -332,19 +1138,34 @@ export class UploadManager {
             const transformer = new PlainObjectToDatabaseEntityTransformer(repo.manager)
             const upload = (await transformer.transform(results[0], meta)) as pgModels.LsifUpload
 
+            // Discard the changes of a failed conversion but keep the lock on the upload
+            await entityManager.query('SAVEPOINT conversion')
+
             try {
                 await convert(upload, entityManager)
             } catch (error) {
                 logger.error('Failed to convert upload', { error })
+                await entityManager.query('ROLLBACK TO SAVEPOINT conversion')
 
-                await entityManager.query(
+                // An upload that has left the processing state (e.g. it was soft deleted) is left untouched
+                const [, numAffected]: [unknown[], number] = await entityManager.query(
                     `
                         UPDATE lsif_uploads
                         SET state = 'errored', finished_at = now(), failure_summary = $2, failure_stacktrace = $3
-                        WHERE id = $1
+                        WHERE id = $1 AND state = 'processing'
                     `,
                     [uploadId, error?.message, error?.stack]
                 )
+                if (numAffected > 0) {
+                    await recordUploadEvents(
+                        entityManager,
+                        [uploadId],
+                        'errored',
+                        'errored',
+                        WORKER_ORIGIN,
+                        error?.message
+                    )
+                }
             }
 
             return true
@@ -352,17 +1173,70 @@ export class UploadManager {
     }
 
     /**
-     * Mark an upload as complete and set its finished timestamp.
+     * Record the progress of the conversion of a processing upload. This must not be called
+     * within the transaction that converts the upload so that the progress is visible before
+     * the conversion completes. As the row is updated, this blocks until the converting
+     * transaction commits if that transaction has already updated the upload.
+     *
+     * @param id The upload identifier.
+     * @param stage The conversion step in progress.
+     * @param progress The percentage (0-100) of the conversion that has completed.
+     */
+    public async updateProgress(id: number, stage: pgModels.LsifUploadStage, progress: number): Promise<void> {
+        await instrumentQuery(() =>
+            this.connection.query(
+                "UPDATE lsif_uploads SET stage = $2, progress = $3 WHERE id = $1 AND state = 'processing'",
+                [id, stage, Math.max(0, Math.min(100, Math.round(progress)))]
+            )
+        )
+    }
+
+    /**
+     * Record that the given worker is still converting a processing upload. This must not be
+     * called within the transaction that converts the upload. Returns false if the upload is no
+     * longer being processed by the given worker.
+     *
+     * @param id The upload identifier.
+     * @param workerId The identifier of the worker converting the upload.
+     */
+    public async heartbeat(id: number, workerId: string): Promise<boolean> {
+        const [, numAffected]: [unknown[], number] = await instrumentQuery(() =>
+            this.connection.query(
+                `
+                    UPDATE lsif_uploads SET last_heartbeat_at = now()
+                    WHERE id = $1 AND state = 'processing' AND worker_id = $2
+                `,
+                [id, workerId]
+            )
+        )
+
+        return numAffected > 0
+    }
+
+    /**
+     * Mark a processing upload as complete and set its finished timestamp. Throws if the upload
+     * has left the processing state (e.g. it was soft deleted during its conversion), so that
+     * the conversion is not committed.
      *
      * @param upload The upload.
      * @param entityManager The EntityManager to use as part of a transaction.
      */
-    public markComplete(
+    public async markComplete(
         upload: pgModels.LsifUpload,
         entityManager: EntityManager = this.connection.createEntityManager()
     ): Promise<void> {
-        return entityManager.query("UPDATE lsif_uploads SET state = 'completed', finished_at = now() WHERE id = $1", [
-            upload.id,
-        ])
+        const [, numAffected]: [unknown[], number] = await entityManager.query(
+            `
+                UPDATE lsif_uploads
+                SET state = 'completed', finished_at = now(), progress = 100, stage = NULL
+                WHERE id = $1 AND state = 'processing'
+            `,
+            [upload.id]
+        )
+        if (numAffected === 0) {
+            throw new Error('Upload is no longer processing')
+        }
+
+        await recordUploadEvents(entityManager, [upload.id], 'completed', 'completed', WORKER_ORIGIN)
     }
 }
@@ -3,18 +3,41 @@ import { Connection } from 'typeorm'
 import { logAndTraceCall, TracingContext } from './tracing'
 import { Logger } from 'winston'
 import { tryWithLock } from './store/locks'
+import { taskDurationHistogram, taskErrorsCounter } from './metrics'
 
 interface Task {
+    name: string
     intervalMs: number
     handler: () => Promise<void>
 }
 
+/** The outcome of the invocations of a periodic task by this process. */
+export interface TaskStatus {
+    /** The task name. */
+    name: string
+
+    /** The time the task last started, or null if it has not run yet. */
+    lastRunAt: Date | null
+
+    /** The duration (in seconds) of the last completed invocation, or null if none has completed. */
+    lastDurationSeconds: number | null
+
+    /** The message of the most recent error thrown by the task, or null if it has never failed. */
+    lastError: string | null
+
+    /** The time of the most recent error thrown by the task, or null if it has never failed. */
+    lastErrorAt: Date | null
+}
+
 /**
  * A collection of tasks that are invoked periodically, each holding an
  * exclusive advisory lock on a Postgres database connection.
  */
 export class ExclusivePeriodicTaskRunner {
     private tasks: Task[] = []
+    private statuses: TaskStatus[] = []
+    private pollers: ReturnType<typeof AsyncPolling>[] = []
+    private running = new Set<Promise<void>>()
 
     /**
      * Create a new task runner.
@@ -45,27 +68,96 @@ export class ExclusivePeriodicTaskRunner {
         silent?: boolean
     }): void {
         const taskArgs = { connection: this.connection, ctx: {} }
+        const status: TaskStatus = {
+            name,
+            lastRunAt: null,
+            lastDurationSeconds: null,
+            lastError: null,
+            lastErrorAt: null,
+        }
+        this.statuses.push(status)
 
         this.tasks.push({
+            name,
             intervalMs,
             handler: () =>
                 tryWithLock(this.connection, name, () =>
-                    silent
-                        ? task(taskArgs)
-                        : logAndTraceCall({ logger: this.logger }, name, ctx => task({ ...taskArgs, ctx }))
+                    recordStatus(status, () =>
+                        silent
+                            ? task(taskArgs)
+                            : logAndTraceCall({ logger: this.logger }, name, ctx => task({ ...taskArgs, ctx }))
+                    )
                 ),
         })
     }
 
+    /** Return the status of each registered task in order of registration. */
+    public status(): TaskStatus[] {
+        return this.statuses.map(status => ({ ...status }))
+    }
+
     /** Start running all registered tasks on the specified interval. */
     public run(): void {
-        for (const { intervalMs, handler } of this.tasks) {
+        for (const { name, intervalMs, handler } of this.tasks) {
             const fn = async (end: () => void): Promise<void> => {
-                await handler()
+                const promise = handler()
+                this.running.add(promise)
+
+                try {
+                    await promise
+                } catch (error) {
+                    // Keep polling so that a transient failure does not stop the task for good
+                    this.logger.error('Periodic task failed', { name, error: error && error.message })
+                } finally {
+                    this.running.delete(promise)
+                }
+
                 end()
             }
 
-            AsyncPolling(fn, intervalMs * 1000).run()
+            const poller = AsyncPolling(fn, intervalMs * 1000)
+            poller.run()
+            this.pollers.push(poller)
         }
     }
+
+    /** Stop scheduling tasks and wait for all in-flight task invocations to complete. */
+    public async stop(): Promise<void> {
+        for (const poller of this.pollers) {
+            poller.stop()
+        }
+        this.pollers = []
+
+        await Promise.all(
+            Array.from(this.running).map(promise =>
+                promise.catch(() => {
+                    /* noop */
+                })
+            )
+        )
+    }
+}
+
+/**
+ * Invoke the given function and record its start time, duration, and error (if any) in the
+ * given status and in the periodic task metrics.
+ *
+ * @param status The status of the task.
+ * @param f The function to invoke.
+ */
+async function recordStatus(status: TaskStatus, f: () => Promise<void>): Promise<void> {
+    const start = Date.now()
+    status.lastRunAt = new Date(start)
+
+    try {
+        await f()
+    } catch (error) {
+        status.lastError = error instanceof Error ? error.message : String(error)
+        status.lastErrorAt = new Date()
+        taskErrorsCounter.labels(status.name).inc()
+        throw error
+    } finally {
+        status.lastDurationSeconds = (Date.now() - start) / 1000
+        taskDurationHistogram.labels(status.name).observe(status.lastDurationSeconds)
+    }
 }
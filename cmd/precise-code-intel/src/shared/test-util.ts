@@ -6,7 +6,8 @@ import { child_process } from 'mz'
 import { Connection } from 'typeorm'
 import { connectPostgres } from './database/postgres'
 import { userInfo } from 'os'
-import { DumpManager } from './store/dumps'
+import { DumpDebugStore, DumpManager, DumpStore } from './store/dumps'
+import { DependencyStore } from './store/dependencies'
 import { createSilentLogger } from './logging'
 
 /**
@@ -167,3 +168,73 @@ export function createCommit(base?: number): string {
     // Add 'a' to differentiate between similar numeric bases such as `1a1a...` and `11a11a...`.
     return (base + 'a').repeat(40).substring(0, 40)
 }
+
+/**
+ * Encode a single protobuf field. Numbers are encoded as varints, and strings, buffers,
+ * and arrays of encoded fields (nested messages) as length-delimited values.
+ *
+ * @param field The field number.
+ * @param value The value of the field.
+ */
+export function encodeProtobufField(field: number, value: number | string | Buffer | Buffer[]): Buffer {
+    const varint = (n: number): number[] => {
+        const bytes: number[] = []
+        let remaining = n
+        while (remaining >= 0x80) {
+            bytes.push((remaining % 0x80) | 0x80)
+            remaining = Math.floor(remaining / 0x80)
+        }
+
+        return [...bytes, remaining]
+    }
+
+    if (typeof value === 'number') {
+        return Buffer.from([...varint(field * 8), ...varint(value)])
+    }
+
+    const bytes = Array.isArray(value) ? Buffer.concat(value) : Buffer.from(value)
+    return Buffer.concat([Buffer.from([...varint(field * 8 + 2), ...varint(bytes.length)]), bytes])
+}
+
+/**
+ * Create a mock dump store. Each method rejects when called, so tests should stub the
+ * methods they expect to be invoked.
+ */
+export function createMockDumpStore(): DumpStore {
+    return createMockStore<DumpStore>(['findClosestDumps', 'getDumpById', 'getDumpsByIds'])
+}
+
+/**
+ * Create a mock dump debug store. Each method rejects when called, so tests should stub the
+ * methods they expect to be invoked.
+ */
+export function createMockDumpDebugStore(): DumpDebugStore {
+    return createMockStore<DumpDebugStore>(['discoverCommits', 'updateCommits', 'getDumpGroups', 'findClosestDumps'])
+}
+
+/**
+ * Create a mock dependency store. Each method rejects when called, so tests should stub
+ * the methods they expect to be invoked.
+ */
+export function createMockDependencyStore(): DependencyStore {
+    return createMockStore<DependencyStore>([
+        'getPackage',
+        'getPackageReferences',
+        'getSameRepoRemotePackageReferences',
+        'getMonikerDumps',
+    ])
+}
+
+/**
+ * Create an object with a method for each of the given names that rejects when called.
+ *
+ * @param names The method names.
+ */
+function createMockStore<T>(names: (keyof T & string)[]): T {
+    const store: { [name: string]: () => Promise<never> } = {}
+    for (const name of names) {
+        store[name] = () => Promise.reject(new Error(`Unexpected ${name} invocation`))
+    }
+
+    return (store as unknown) as T
+}
@@ -2,7 +2,9 @@ import { createSilentLogger, logCall } from './logging'
 import { ERROR } from 'opentracing/lib/ext/tags'
 import { initTracerFromEnv } from 'jaeger-client'
 import { Logger } from 'winston'
-import { Span, Tracer } from 'opentracing'
+import { FORMAT_HTTP_HEADERS, Span, Tracer } from 'opentracing'
+import { Cancellation } from './cancellation'
+import { QueryDebugLog } from './debug'
 
 /**
  * A bag of logging and tracing instances passed around a current
@@ -14,6 +16,12 @@ export interface TracingContext {
 
     /** The current opentracing span. Optional for testing. */
     span?: Span
+
+    /** The cancellation of the request that created this context. Optional for testing. */
+    cancellation?: Cancellation
+
+    /** The explanation of the current query, if the client requested debug output. */
+    debug?: QueryDebugLog
 }
 
 /**
@@ -23,10 +31,10 @@ export interface TracingContext {
  * @param tags The tags to add to the logger and span.
  */
 export function addTags(
-    { logger = createSilentLogger(), span = new Span() }: TracingContext,
+    { logger = createSilentLogger(), span = new Span(), cancellation, debug }: TracingContext,
     tags: { [name: string]: unknown }
 ): TracingContext {
-    return { logger: logger.child(tags), span: span.addTags(tags) }
+    return { logger: logger.child(tags), span: span.addTags(tags), cancellation, debug }
 }
 
 /**
@@ -102,16 +110,48 @@ export async function traceCall<T>(name: string, parent: Span, f: (span: Span) =
 }
 
 /**
- * Log and trace the execution of a function.
+ * Log and trace the execution of a function. The duration of the function is recorded
+ * in the debug log of the context, if one is attached.
  *
  * @param ctx The tracing context.
  * @param name The name of the span and text of the log message.
  * @param f The function to invoke.
  */
 export function logAndTraceCall<T>(
-    { logger = createSilentLogger(), span = new Span() }: TracingContext,
+    { logger = createSilentLogger(), span = new Span(), cancellation, debug }: TracingContext,
     name: string,
     f: (ctx: TracingContext) => Promise<T> | T
 ): Promise<T> {
-    return logCall(name, logger, () => traceCall(name, span, childSpan => f({ logger, span: childSpan })))
+    return logCall(name, logger, () =>
+        traceCall(name, span, async childSpan => {
+            // Do not start new work on behalf of a cancelled request
+            if (cancellation) {
+                cancellation.throwIfCancelled()
+            }
+
+            const start = Date.now()
+            try {
+                return await f({ logger, span: childSpan, cancellation, debug })
+            } finally {
+                if (debug) {
+                    debug.recordTiming(name, Date.now() - start)
+                }
+            }
+        })
+    )
+}
+
+/**
+ * Create the headers that propagate the span of the tracing context to a downstream
+ * service, so that spans created by that service are parented to the current span.
+ *
+ * @param ctx The tracing context.
+ */
+export function tracingHeaders({ span }: TracingContext): { [name: string]: string } {
+    const headers: { [name: string]: string } = {}
+    if (span) {
+        span.tracer().inject(span.context(), FORMAT_HTTP_HEADERS, headers)
+    }
+
+    return headers
 }
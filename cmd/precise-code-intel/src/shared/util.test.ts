@@ -0,0 +1,85 @@
+import { findConcurrently, mapConcurrently } from './util'
+
+describe('mapConcurrently', () => {
+    it('should return results aligned with input', async () => {
+        const values = [5, 1, 4, 2, 3]
+        const results = await mapConcurrently(values, 2, async value => {
+            await new Promise(resolve => setTimeout(resolve, value))
+            return value * 2
+        })
+
+        expect(results).toEqual([10, 2, 8, 4, 6])
+    })
+
+    it('should limit concurrent invocations', async () => {
+        let pending = 0
+        let maxPending = 0
+
+        await mapConcurrently([1, 2, 3, 4, 5, 6, 7], 3, async () => {
+            pending++
+            maxPending = Math.max(maxPending, pending)
+            await new Promise(resolve => setTimeout(resolve, 1))
+            pending--
+        })
+
+        expect(maxPending).toEqual(3)
+    })
+})
+
+describe('findConcurrently', () => {
+    it('should return the first match in input order', async () => {
+        const values = [5, 1, 4, 2, 3]
+        const match = await findConcurrently(
+            values,
+            3,
+            async value => {
+                await new Promise(resolve => setTimeout(resolve, value))
+                return value * 2
+            },
+            result => result < 10
+        )
+
+        expect(match).toEqual({ result: 2, index: 1 })
+    })
+
+    it('should ignore failures after the first match', async () => {
+        const match = await findConcurrently(
+            [1, 2, 3],
+            3,
+            value => (value === 1 ? Promise.resolve(value) : Promise.reject(new Error('speculative failure'))),
+            () => true
+        )
+
+        expect(match).toEqual({ result: 1, index: 0 })
+    })
+
+    it('should throw failures before the first match', async () => {
+        await expect(
+            findConcurrently(
+                [1, 2, 3],
+                3,
+                value => (value === 3 ? Promise.resolve(value) : Promise.reject(new Error('failure'))),
+                () => true
+            )
+        ).rejects.toThrow('failure')
+    })
+
+    it('should stop after the window containing a match', async () => {
+        const invoked: number[] = []
+        await findConcurrently(
+            [1, 2, 3, 4, 5, 6, 7],
+            3,
+            value => {
+                invoked.push(value)
+                return Promise.resolve(value)
+            },
+            result => result === 2
+        )
+
+        expect(invoked).toEqual([1, 2, 3])
+    })
+
+    it('should return undefined without a match', async () => {
+        expect(await findConcurrently([1, 2, 3], 2, value => Promise.resolve(value), () => false)).toBeUndefined()
+    })
+})
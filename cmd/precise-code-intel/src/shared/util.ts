@@ -6,3 +6,74 @@
 export function isDefined<T>(value: T | undefined): value is T {
     return value !== undefined
 }
+
+/**
+ * Invoke the given function on each value, with at most `limit` invocations pending at
+ * any one time. The resulting list is aligned with the input values.
+ *
+ * @param values The input values.
+ * @param limit The maximum number of concurrent invocations.
+ * @param fn The function to invoke on each value.
+ */
+export async function mapConcurrently<T, R>(
+    values: T[],
+    limit: number,
+    fn: (value: T, index: number) => Promise<R>
+): Promise<R[]> {
+    const results: R[] = new Array(values.length)
+
+    let next = 0
+    const worker = async (): Promise<void> => {
+        while (next < values.length) {
+            const index = next++
+            results[index] = await fn(values[index], index)
+        }
+    }
+
+    await Promise.all(Array.from({ length: Math.min(limit, values.length) }, worker))
+    return results
+}
+
+/**
+ * Invoke the given function on each value, with at most `limit` invocations pending at
+ * any one time, and return the first result (in input order) that satisfies the given
+ * predicate along with the index of its value. Values are processed in windows of `limit`
+ * values so that no further invocations are made once a window yields a match. The result
+ * is the same as if the values were processed serially: an invocation that fails after an
+ * earlier value has already matched was only made speculatively, so its error is ignored.
+ * Returns undefined if no result satisfies the predicate.
+ *
+ * @param values The input values.
+ * @param limit The maximum number of concurrent invocations.
+ * @param fn The function to invoke on each value.
+ * @param predicate The function that determines if a result is a match.
+ */
+export async function findConcurrently<T, R>(
+    values: T[],
+    limit: number,
+    fn: (value: T, index: number) => Promise<R>,
+    predicate: (result: R) => boolean
+): Promise<{ result: R; index: number } | undefined> {
+    const windowSize = Math.max(1, limit)
+
+    for (let offset = 0; offset < values.length; offset += windowSize) {
+        const results = await Promise.all(
+            values.slice(offset, offset + windowSize).map(
+                (value, i): Promise<{ result: R } | { error: unknown }> =>
+                    fn(value, offset + i).then(result => ({ result }), error => ({ error }))
+            )
+        )
+
+        for (const [i, settled] of results.entries()) {
+            if ('error' in settled) {
+                throw settled.error
+            }
+
+            if (predicate(settled.result)) {
+                return { result: settled.result, index: offset + i }
+            }
+        }
+    }
+
+    return undefined
+}
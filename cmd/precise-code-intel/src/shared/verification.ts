@@ -0,0 +1,14 @@
+/** The result of checking the integrity of a bundle, as served by the bundle manager's `/verify` endpoint. */
+export interface BundleVerification {
+    /** Whether or not the bundle passed every check. */
+    valid: boolean
+
+    /** A description of each failed check. Empty if the bundle is valid. */
+    problems: string[]
+
+    /** The number of documents that were decoded as a sample. */
+    numSampledDocuments: number
+
+    /** The number of result chunks that were decoded as a sample. */
+    numSampledResultChunks: number
+}
@@ -0,0 +1,24 @@
+import { groupParentCommits } from './visibility'
+
+describe('groupParentCommits', () => {
+    it('should group and de-duplicate parents', () => {
+        const commits = groupParentCommits([
+            { commit: 'a', parent: null },
+            { commit: 'b', parent: 'a' },
+            { commit: 'c', parent: 'a' },
+            { commit: 'd', parent: 'b' },
+            { commit: 'd', parent: 'c' },
+            { commit: 'd', parent: 'b' },
+            { commit: 'a', parent: null },
+        ])
+
+        expect(commits).toEqual(
+            new Map([
+                ['a', new Set()],
+                ['b', new Set(['a'])],
+                ['c', new Set(['a'])],
+                ['d', new Set(['b', 'c'])],
+            ])
+        )
+    })
+})
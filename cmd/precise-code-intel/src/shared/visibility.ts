@@ -1,11 +1,13 @@
 import { EntityManager } from 'typeorm'
 import { DumpManager } from './store/dumps'
 import { TracingContext } from './tracing'
+import { acquireTransactionLock } from './store/locks'
 
 /**
  * Update the commits for this repo, and update the visible_at_tip flag on the dumps
  * of this repository. This will query for commits starting from both the current tip
- * of the repo and from given commit.
+ * of the repo and from given commit. Concurrent updates of the same repository are
+ * serialized by a lock held until the end of the given entity manager's transaction.
  *
  * @param args Parameter bag.
  */
@@ -34,6 +36,8 @@ export async function updateCommitsAndDumpsVisibleFromTip({
     /** The tracing context. */
     ctx?: TracingContext
 }): Promise<void> {
+    await acquireTransactionLock(entityManager, `visibility-${repositoryId}`)
+
     const tipCommit = await dumpManager.discoverTip({
         repositoryId,
         frontendUrl,
@@ -43,14 +47,17 @@ export async function updateCommitsAndDumpsVisibleFromTip({
         throw new Error('No tip commit available for repository')
     }
 
-    const commits = commit
+    const uploadCommits = commit
         ? await dumpManager.discoverCommits({
               repositoryId,
               commit,
               frontendUrl,
               ctx,
           })
-        : new Map()
+        : new Map<string, Set<string>>()
+
+    // Merge into a copy so that the map returned by the dump manager is never modified
+    const commits = new Map(uploadCommits)
 
     if (tipCommit !== commit) {
         // If the tip is ahead of this commit, we also want to discover all of
@@ -77,3 +84,27 @@ export async function updateCommitsAndDumpsVisibleFromTip({
     await dumpManager.updateCommits(repositoryId, commits, ctx, entityManager)
     await dumpManager.updateDumpsVisibleFromTip(repositoryId, tipCommit, ctx, entityManager)
 }
+
+/**
+ * Convert a list of (commit, parent) pairs into a map from commits to their set of parent
+ * commits, as expected by `DumpManager#updateCommits`. Duplicate pairs are collapsed, and a
+ * commit without a parent is mapped to an empty set.
+ *
+ * @param pairs The commit and parent pairs. The parent of a root commit is null.
+ */
+export function groupParentCommits(pairs: { commit: string; parent: string | null }[]): Map<string, Set<string>> {
+    const commits = new Map<string, Set<string>>()
+    for (const { commit, parent } of pairs) {
+        let parents = commits.get(commit)
+        if (parents === undefined) {
+            parents = new Set<string>()
+            commits.set(commit, parents)
+        }
+
+        if (parent !== null) {
+            parents.add(parent)
+        }
+    }
+
+    return commits
+}
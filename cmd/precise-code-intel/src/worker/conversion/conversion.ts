@@ -1,31 +1,38 @@
 import * as pgModels from '../../shared/models/pg'
 import { TracingContext } from '../../shared/tracing'
 import { EntityManager } from 'typeorm'
-import { convertLsif } from './importer'
+import { convertLsif, languageFromPath } from './importer'
 import { createSilentLogger } from '../../shared/logging'
 import { DependencyManager } from '../../shared/store/dependencies'
 import { PathExistenceChecker } from './existence'
+import { DumpManager } from '../../shared/store/dumps'
+import { getTrackedFiles } from '../../shared/gitserver/gitserver'
+import { ProgressCallback } from './progress'
 
 /**
  * Convert the LSIF dump input into a SQLite database and populate the dependency tables
- * with packages and reference data.
+ * with packages and reference data and the statistics table with document statistics.
  *
  * @param entityManager The EntityManager to use as part of a transaction.
+ * @param dumpManager The dumps manager instance.
  * @param dependencyManager The dependency manager instance.
  * @param frontendUrl The url of the frontend internal API.
  * @param upload The unprocessed upload record.
  * @param sourcePath The path to the upload file.
  * @param targetPath The target database filename.
  * @param ctx The tracing context.
+ * @param onProgress A function that reports the progress of the conversion.
  */
 export async function convertDatabase(
     entityManager: EntityManager,
+    dumpManager: DumpManager,
     dependencyManager: DependencyManager,
     frontendUrl: string,
     upload: pgModels.LsifUpload,
     sourcePath: string,
     targetPath: string,
-    { logger = createSilentLogger(), span }: TracingContext
+    { logger = createSilentLogger(), span }: TracingContext,
+    onProgress?: ProgressCallback
 ): Promise<void> {
     const ctx = { logger, span }
 
@@ -38,14 +45,65 @@ export async function convertDatabase(
     })
 
     // Create database in a temp path
-    const { packages, references } = await convertLsif({
+    const { packages, references, statistics } = await convertLsif({
         path: sourcePath,
+        format: upload.format,
         root: upload.root,
         database: targetPath,
         pathExistenceChecker,
+        onProgress,
         ctx,
     })
 
     // Insert dump and add packages and references to Postgres
+    if (onProgress) {
+        onProgress('dependencies', 0)
+    }
     await dependencyManager.addPackagesAndReferences(upload.id, packages, references, ctx, entityManager)
+
+    // Add document statistics to Postgres
+    await dumpManager.addStatistics(
+        upload.id,
+        {
+            ...statistics,
+            numFilesInRoot: await countFilesInRoot(frontendUrl, upload, statistics.documentsByLanguage, ctx),
+        },
+        ctx,
+        entityManager
+    )
+}
+
+/**
+ * Count the number of files tracked by git within the root of the given upload that are
+ * written in one of the languages of the dump's documents. This is used to determine the
+ * percentage of relevant files covered by the dump, so files the indexer cannot cover
+ * (e.g. images or files of other languages) are not counted. Returns null if the files
+ * cannot be listed, as this should not cause the conversion to fail.
+ *
+ * @param frontendUrl The url of the frontend internal API.
+ * @param upload The unprocessed upload record.
+ * @param documentsByLanguage The number of documents in the dump by language.
+ * @param ctx The tracing context.
+ */
+async function countFilesInRoot(
+    frontendUrl: string,
+    upload: pgModels.LsifUpload,
+    documentsByLanguage: { [language: string]: number },
+    { logger = createSilentLogger(), span }: TracingContext
+): Promise<number | null> {
+    try {
+        const files = await getTrackedFiles({
+            frontendUrl,
+            repositoryId: upload.repositoryId,
+            commit: upload.commit,
+            dirname: upload.root,
+            ctx: { logger, span },
+        })
+
+        const languages = new Set(Object.keys(documentsByLanguage))
+        return files.filter(file => languages.has(languageFromPath(file))).length
+    } catch (error) {
+        logger.warn('Failed to list files in dump root', { uploadId: upload.id, error: error && error.message })
+        return null
+    }
 }
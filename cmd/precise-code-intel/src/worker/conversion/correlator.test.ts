@@ -1,5 +1,6 @@
 import * as lsif from 'lsif-protocol'
 import { Correlator, normalizeHover } from './correlator'
+import { Diagnostic, DiagnosticSeverity } from 'vscode-languageserver-types'
 
 describe('Correlator', () => {
     it('should stash lsif version and project root from metadata', () => {
@@ -118,6 +119,68 @@ describe('Correlator', () => {
         expect(refs?.get('2')).toEqual(['3'])
     })
 
+    it('should correlate implementation results', () => {
+        const c = new Correlator()
+        c.insert({
+            id: '1',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.metaData,
+            positionEncoding: 'utf-16',
+            version: '0.4.3',
+            projectRoot: 'file:///lsif-test',
+        })
+
+        c.insert({
+            id: '2',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.document,
+            uri: 'file:///lsif-test/sub/path/index.ts',
+            languageId: 'typescript',
+        })
+
+        c.insert({
+            id: '3',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.range,
+            start: { line: 3, character: 16 },
+            end: { line: 3, character: 19 },
+        })
+
+        c.insert({
+            id: '4',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.resultSet,
+        })
+
+        c.insert({
+            id: '5',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.implementationResult,
+        })
+
+        c.insert({
+            id: '6',
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.textDocument_implementation,
+            outV: '4',
+            inV: '5',
+        })
+
+        c.insert({
+            id: '7',
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.item,
+            outV: '5',
+            inVs: ['3'],
+            document: '2',
+        })
+
+        expect(c.resultSetData.get('4')?.implementationResultId).toEqual('5')
+
+        const implementations = c.implementationData.get('5')
+        expect(implementations?.get('2')).toEqual(['3'])
+    })
+
     it('should correlate linked reference results', () => {
         const c = new Correlator()
 
@@ -305,6 +368,68 @@ describe('Correlator', () => {
         expect(c.linkedMonikers.extractSet('3')).toEqual(new Set(['2', '3', '4']))
         expect(c.linkedMonikers.extractSet('4')).toEqual(new Set(['2', '3', '4']))
     })
+
+    it('should attach diagnostics to documents', () => {
+        const c = new Correlator()
+        c.insert({
+            id: '1',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.metaData,
+            positionEncoding: 'utf-16',
+            version: '0.4.3',
+            projectRoot: 'file:///lsif-test',
+        })
+
+        c.insert({
+            id: '2',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.document,
+            uri: 'file:///lsif-test/index.ts',
+            languageId: 'typescript',
+        })
+
+        c.insert({
+            id: '3',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.project,
+            kind: 'typescript',
+        })
+
+        const diagnostic: Diagnostic = {
+            range: { start: { line: 1, character: 2 }, end: { line: 1, character: 5 } },
+            severity: DiagnosticSeverity.Error,
+            code: 2304,
+            message: "Cannot find name 'foo'.",
+            source: 'tsc',
+        }
+
+        c.insert({
+            id: '4',
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.diagnosticResult,
+            result: [diagnostic],
+        })
+
+        c.insert({
+            id: '5',
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.textDocument_diagnostic,
+            outV: '2',
+            inV: '4',
+        })
+
+        c.insert({
+            id: '6',
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.textDocument_diagnostic,
+            outV: '3',
+            inV: '4',
+        })
+
+        expect(c.diagnosticData.get('4')).toEqual([diagnostic])
+        expect(c.documentDiagnostics.get('2')).toEqual(['4'])
+        expect(c.documentDiagnostics.get('3')).toBeUndefined()
+    })
 })
 
 describe('normalizeHover', () => {
@@ -3,7 +3,7 @@ import * as lsif from 'lsif-protocol'
 import { createSilentLogger } from '../../shared/logging'
 import { DefaultMap } from '../../shared/datastructures/default-map'
 import { DisjointSet } from '../../shared/datastructures/disjoint-set'
-import { Hover, MarkupContent } from 'vscode-languageserver-types'
+import { Diagnostic, Hover, MarkupContent } from 'vscode-languageserver-types'
 import { Logger } from 'winston'
 import { mustGet, mustGetFromEither } from '../../shared/maps'
 import { relativePath } from './paths'
@@ -23,6 +23,9 @@ export interface ResultSetData {
     /** The identifier of the reference result attached to this result set. */
     referenceResultId?: sqliteModels.ReferenceResultId
 
+    /** The identifier of the implementation result attached to this result set. */
+    implementationResultId?: sqliteModels.ImplementationResultId
+
     /** The identifier of the hover result attached to this result set. */
     hoverResultId?: sqliteModels.HoverResultId
 
@@ -56,6 +59,7 @@ export class Correlator {
     public hoverData = new Map<sqliteModels.HoverResultId, string>()
     public monikerData = new Map<sqliteModels.MonikerId, sqliteModels.MonikerData>()
     public packageInformationData = new Map<sqliteModels.PackageInformationId, sqliteModels.PackageInformationData>()
+    public diagnosticData = new Map<sqliteModels.DiagnosticResultId, Diagnostic[]>()
     public unsupportedVertexes = new Set<lsif.Id>()
 
     // Edge data
@@ -69,6 +73,11 @@ export class Correlator {
         sqliteModels.ReferenceResultId,
         DefaultMap<sqliteModels.DocumentId, lsif.RangeId[]>
     >()
+    public implementationData = new Map<
+        sqliteModels.ImplementationResultId,
+        DefaultMap<sqliteModels.DocumentId, lsif.RangeId[]>
+    >()
+    public documentDiagnostics = new DefaultMap<sqliteModels.DocumentId, sqliteModels.DiagnosticResultId[]>(() => [])
 
     /** A disjoint set of monikers linked by `nextMoniker` edges. */
     public linkedMonikers = new DisjointSet<sqliteModels.MonikerId>()
@@ -124,6 +133,7 @@ export class Correlator {
                         endLine: element.end.line,
                         endCharacter: element.end.character,
                         monikerIds: new Set<sqliteModels.MonikerId>(),
+                        ...normalizeTag(element.tag),
                     })
                     break
 
@@ -145,6 +155,13 @@ export class Correlator {
                     )
                     break
 
+                case lsif.VertexLabels.implementationResult:
+                    this.implementationData.set(
+                        element.id,
+                        new DefaultMap<sqliteModels.DocumentId, lsif.RangeId[]>(() => [])
+                    )
+                    break
+
                 case lsif.VertexLabels.hoverResult:
                     this.hoverData.set(element.id, normalizeHover(element.result))
                     break
@@ -164,11 +181,14 @@ export class Correlator {
                     })
                     break
 
+                case lsif.VertexLabels.diagnosticResult:
+                    this.diagnosticData.set(element.id, element.result)
+                    break
+
                 default:
                     // Some vertex labels are not yet supported:
                     //
                     // - typeDefinitionResult
-                    // - implementationResult
                     // - ... others in the future
                     //
                     // We keep track of these unsupported vertexes so that we
@@ -201,6 +221,10 @@ export class Correlator {
                     this.handleReferenceEdge(element)
                     break
 
+                case lsif.EdgeLabels.textDocument_implementation:
+                    this.handleImplementationEdge(element)
+                    break
+
                 case lsif.EdgeLabels.textDocument_hover:
                     this.handleHoverEdge(element)
                     break
@@ -216,6 +240,10 @@ export class Correlator {
                 case lsif.EdgeLabels.packageInformation:
                     this.handlePackageInformationEdge(element)
                     break
+
+                case lsif.EdgeLabels.textDocument_diagnostic:
+                    this.handleDiagnosticEdge(element)
+                    break
             }
         }
     }
@@ -271,8 +299,8 @@ export class Correlator {
     }
 
     /**
-     * Update definition and reference fields from an item edge. Ensures all
-     * referenced vertices are defined.
+     * Update definition, reference, and implementation fields from an item edge.
+     * Ensures all referenced vertices are defined.
      *
      * @param edge The item edge.
      */
@@ -303,6 +331,17 @@ export class Correlator {
             return
         }
 
+        if (this.implementationData.has(edge.outV)) {
+            const documentMap = mustGet(this.implementationData, edge.outV, 'implementationResult')
+            const rangeIds = documentMap.getOrDefault(edge.document)
+            for (const inV of edge.inVs) {
+                mustGet(this.rangeData, inV, 'range')
+                rangeIds.push(inV)
+            }
+
+            return
+        }
+
         if (this.unsupportedVertexes.has(edge.outV)) {
             this.logger.debug('Skipping edge from an unsupported vertex', { edge })
             return
@@ -416,6 +455,24 @@ export class Correlator {
         outV.referenceResultId = edge.inV
     }
 
+    /**
+     * Sets the implementation result of the specified range or result set. Ensures all
+     * referenced vertices are defined.
+     *
+     * @param edge The textDocument/implementation edge.
+     */
+    private handleImplementationEdge(edge: lsif.textDocument_implementation): void {
+        const outV = mustGetFromEither<lsif.RangeId, sqliteModels.RangeData, ResultSetId, ResultSetData>(
+            this.rangeData,
+            this.resultSetData,
+            edge.outV,
+            'range/resultSet'
+        )
+
+        mustGet(this.implementationData, edge.inV, 'implementationResult')
+        outV.implementationResultId = edge.inV
+    }
+
     /**
      * Sets the hover result of the specified range or result set. Ensures all referenced
      * vertices are defined.
@@ -433,6 +490,37 @@ export class Correlator {
         mustGet(this.hoverData, edge.inV, 'hoverResult')
         outV.hoverResultId = edge.inV
     }
+
+    /**
+     * Attaches the diagnostic result to the specified document. Diagnostics attached to a
+     * project are not associated with a file and are skipped. Ensures all referenced vertices
+     * are defined.
+     *
+     * @param edge The textDocument/diagnostic edge.
+     */
+    private handleDiagnosticEdge(edge: lsif.textDocument_diagnostic): void {
+        if (!this.documentPaths.has(edge.outV)) {
+            this.logger.debug('Skipping diagnostics not attached to a document', { edge })
+            return
+        }
+
+        mustGet(this.diagnosticData, edge.inV, 'diagnosticResult')
+        this.documentDiagnostics.getOrDefault(edge.outV).push(edge.inV)
+    }
+}
+
+/**
+ * Extract the symbol data from a range tag. Only declaration and definition tags
+ * describe a symbol; other tags are discarded.
+ *
+ * @param tag The range tag.
+ */
+function normalizeTag(tag: lsif.RangeTag | undefined): { tag?: sqliteModels.SymbolTagData } {
+    if (tag === undefined || (tag.type !== 'declaration' && tag.type !== 'definition')) {
+        return {}
+    }
+
+    return { tag: { text: tag.text, kind: tag.kind, fullRange: tag.fullRange } }
 }
 
 /**
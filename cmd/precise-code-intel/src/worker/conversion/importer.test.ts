@@ -0,0 +1,14 @@
+import { languageFromPath } from './importer'
+
+describe('languageFromPath', () => {
+    it('should use the lowercased file extension', () => {
+        expect(languageFromPath('web/src/index.ts')).toEqual('ts')
+        expect(languageFromPath('cmd/main.GO')).toEqual('go')
+        expect(languageFromPath('lib/archive.tar.gz')).toEqual('gz')
+    })
+
+    it('should attribute files without an extension to unknown', () => {
+        expect(languageFromPath('Makefile')).toEqual('unknown')
+        expect(languageFromPath('scripts/.bashrc')).toEqual('unknown')
+    })
+})
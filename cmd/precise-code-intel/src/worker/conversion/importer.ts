@@ -5,17 +5,22 @@ import { createSqliteConnection } from '../../shared/database/sqlite'
 import { databaseInsertionDurationHistogram, databaseInsertionErrorsCounter } from '../metrics'
 import { DefaultMap } from '../../shared/datastructures/default-map'
 import { EntityManager } from 'typeorm'
-import { gzipJSON } from '../../shared/encoding/json'
+import { encodePayload, PayloadEncoding } from '../../shared/encoding/payload'
 import { hashKey } from '../../shared/models/hash'
 import { isEqual, uniqWith } from 'lodash'
 import { logAndTraceCall, TracingContext } from '../../shared/tracing'
 import { mustGet } from '../../shared/maps'
 import { Package, SymbolReferences } from '../../shared/store/dependencies'
-import { readGzippedJsonElementsFromFile } from '../../shared/input'
+import { readGzippedJsonElementsFromFile, readGzippedScipIndexFromFile } from '../../shared/input'
 import { TableInserter } from '../../shared/database/inserter'
 import { createSilentLogger } from '../../shared/logging'
 import { PathExistenceChecker } from './existence'
 import * as settings from '../settings'
+import * as nodepath from 'path'
+import { LsifUploadFormat } from '../../shared/models/pg'
+import { scipElements } from './scip'
+import { ProgressCallback } from './progress'
+import * as fs from 'mz/fs'
 
 /** The insertion metrics for the database. */
 const inserterMetrics = {
@@ -31,30 +36,59 @@ const inserterMetrics = {
  */
 const INTERNAL_LSIF_VERSION = '0.1.0'
 
+/** Statistics about the documents of a converted dump. */
+export interface DocumentStatistics {
+    /** The number of documents in the dump. */
+    numDocuments: number
+    /** The total number of ranges over all documents in the dump. */
+    numRanges: number
+    /** A map from a language (identified by file extension) to the number of documents in the dump. */
+    documentsByLanguage: { [language: string]: number }
+}
+
+/** The data returned from an import required to populate Postgres. */
+export interface ImportResult {
+    /** The packages provided by the dump. */
+    packages: Package[]
+    /** The packages and symbols referenced by the dump. */
+    references: SymbolReferences[]
+    /** Statistics about the documents of the dump. */
+    statistics: DocumentStatistics
+}
+
 /**
  * Populate a SQLite database with the given input stream. Returns the
- * data required to populate the dependency tables in Postgres.
+ * data required to populate the dependency and statistics tables in Postgres.
  *
  * @param args Parameter bag.
  */
 export async function convertLsif({
     path,
+    format = 'lsif',
     root,
     database,
     pathExistenceChecker,
+    payloadEncoding = settings.BUNDLE_PAYLOAD_ENCODING,
+    onProgress,
     ctx: { logger = createSilentLogger(), span } = {},
 }: {
-    /** The filepath containing a gzipped compressed stream of JSON lines composing the LSIF dump. */
+    /** The filepath containing the gzipped upload. */
     path: string
+    /** The format of the file: gzipped JSON lines, or a gzipped protobuf-encoded SCIP index. */
+    format?: LsifUploadFormat
     /** The root of all files that are in the dump. */
     root: string
     /** The filepath of the database to populate. */
     database: string
     /** An object that tracks whether a path is visible within the LSIF dump. */
     pathExistenceChecker: PathExistenceChecker
+    /** The encoding of the document and result chunk payloads. */
+    payloadEncoding?: PayloadEncoding
+    /** A function that reports the progress of the correlation and writing steps. */
+    onProgress?: ProgressCallback
     /** The tracing context. */
     ctx?: TracingContext
-}): Promise<{ packages: Package[]; references: SymbolReferences[] }> {
+}): Promise<ImportResult> {
     const connection = await createSqliteConnection(database, sqliteModels.entities, logger)
 
     try {
@@ -62,7 +96,16 @@ export async function convertLsif({
         await connection.query('PRAGMA journal_mode = OFF')
 
         return await connection.transaction(entityManager =>
-            importLsif(entityManager, path, root, pathExistenceChecker, { logger, span })
+            importLsif(
+                entityManager,
+                path,
+                root,
+                pathExistenceChecker,
+                { logger, span },
+                format,
+                payloadEncoding,
+                onProgress
+            )
         )
     } finally {
         await connection.close()
@@ -72,25 +115,44 @@ export async function convertLsif({
 /**
  * Correlate each vertex and edge together, then populate the provided entity manager
  * with the document, definition, and reference information. Returns the package and
- * external reference data needed to populate the dependency tables in Postgres.
+ * external reference data needed to populate the dependency tables in Postgres, as well
+ * as statistics about the imported documents.
  *
  * @param entityManager A transactional SQLite entity manager.
- * @param path The filepath containing a gzipped compressed stream of JSON lines composing the LSIF dump.
+ * @param path The filepath containing the gzipped upload.
  * @param root The root of all files that are in the dump.
  * @param pathExistenceChecker An object that tracks whether a path is visible within the LSIF dump.
  * @param ctx The tracing context.
+ * @param format The format of the file.
+ * @param payloadEncoding The encoding of the document and result chunk payloads.
+ * @param onProgress A function that reports the progress of the correlation and writing steps.
  */
 export async function importLsif(
     entityManager: EntityManager,
     path: string,
     root: string,
     pathExistenceChecker: PathExistenceChecker,
-    ctx: TracingContext
-): Promise<{ packages: Package[]; references: SymbolReferences[] }> {
-    // Correlate input data into in-memory maps
+    ctx: TracingContext,
+    format: LsifUploadFormat = 'lsif',
+    payloadEncoding: PayloadEncoding = settings.BUNDLE_PAYLOAD_ENCODING,
+    onProgress: ProgressCallback = () => {
+        /* noop */
+    }
+): Promise<ImportResult> {
+    // Correlate input data into in-memory maps. The progress of JSON lines uploads is the
+    // fraction of the compressed file read so far; SCIP indexes are decoded all at once.
     const correlator = new Correlator(root, ctx.logger)
     await logAndTraceCall(ctx, 'Correlating LSIF data', async () => {
-        for await (const element of readGzippedJsonElementsFromFile(path) as AsyncIterable<lsif.Vertex | lsif.Edge>) {
+        onProgress('correlating', 0)
+        const { size } = await fs.stat(path)
+        const elements =
+            format === 'scip'
+                ? scipElements(await readGzippedScipIndexFromFile(path))
+                : (readGzippedJsonElementsFromFile(path, bytesRead =>
+                      onProgress('correlating', size > 0 ? bytesRead / size : 1)
+                  ) as AsyncIterable<lsif.Vertex | lsif.Edge>)
+
+        for await (const element of elements) {
             correlator.insert(element)
         }
     })
@@ -121,7 +183,8 @@ export async function importLsif(
     await pathExistenceChecker.warmCache(Array.from(correlator.documentPaths.values()))
 
     // Calculate the number of result chunks that we'll attempt to populate
-    const numResults = correlator.definitionData.size + correlator.referenceData.size
+    const numResults =
+        correlator.definitionData.size + correlator.referenceData.size + correlator.implementationData.size
     const numResultChunks = Math.min(
         settings.MAX_NUM_RESULT_CHUNKS,
         Math.floor(numResults / settings.RESULTS_PER_RESULT_CHUNK) || 1
@@ -134,22 +197,31 @@ export async function importLsif(
         sqliteModels.MetaModel.BatchSize,
         inserterMetrics
     )
-    await populateMetadataTable(correlator, metaInserter, numResultChunks)
+    await populateMetadataTable(correlator, metaInserter, numResultChunks, payloadEncoding)
     await metaInserter.flush()
 
     // Insert documents
-    await logAndTraceCall(ctx, 'Populating documents', async () => {
+    onProgress('writing', 0)
+    const statistics = await logAndTraceCall(ctx, 'Populating documents', async () => {
         const documentInserter = new TableInserter(
             entityManager,
             sqliteModels.DocumentModel,
             sqliteModels.DocumentModel.BatchSize,
             inserterMetrics
         )
-        await populateDocumentsTable(correlator, documentInserter, canonicalReferenceResultIds, pathExistenceChecker)
+        const statistics = await populateDocumentsTable(
+            correlator,
+            documentInserter,
+            canonicalReferenceResultIds,
+            pathExistenceChecker,
+            payloadEncoding
+        )
         await documentInserter.flush()
+        return statistics
     })
 
     // Insert result chunks
+    onProgress('writing', 0.5)
     await logAndTraceCall(ctx, 'Populating result chunks', async () => {
         const resultChunkInserter = new TableInserter(
             entityManager,
@@ -157,11 +229,18 @@ export async function importLsif(
             sqliteModels.ResultChunkModel.BatchSize,
             inserterMetrics
         )
-        await populateResultChunksTable(correlator, resultChunkInserter, numResultChunks, pathExistenceChecker)
+        await populateResultChunksTable(
+            correlator,
+            resultChunkInserter,
+            numResultChunks,
+            pathExistenceChecker,
+            payloadEncoding
+        )
         await resultChunkInserter.flush()
     })
 
     // Insert definitions and references
+    onProgress('writing', 0.7)
     await logAndTraceCall(ctx, 'Populating definitions and references', async () => {
         const definitionInserter = new TableInserter(
             entityManager,
@@ -185,24 +264,42 @@ export async function importLsif(
         await referenceInserter.flush()
     })
 
-    // Return data to populate dependency tables in Postgres
-    return { packages: getPackages(correlator), references: getReferences(correlator) }
+    // Insert diagnostics
+    onProgress('writing', 0.95)
+    await logAndTraceCall(ctx, 'Populating diagnostics', async () => {
+        const diagnosticInserter = new TableInserter(
+            entityManager,
+            sqliteModels.DiagnosticModel,
+            sqliteModels.DiagnosticModel.BatchSize,
+            inserterMetrics
+        )
+        await populateDiagnosticsTable(correlator, diagnosticInserter, pathExistenceChecker)
+        await diagnosticInserter.flush()
+    })
+
+    // Return data to populate dependency and statistics tables in Postgres
+    return { packages: getPackages(correlator), references: getReferences(correlator), statistics }
 }
 
 /**
- * Correlate, encode, and insert all document entries for this dump.
+ * Correlate, encode, and insert all document entries for this dump. Returns statistics
+ * about the inserted documents.
  *
  * @param correlator The correlator with all vertices and edges inserted.
  * @param documentInserter The inserter for the documents table.
  * @param canonicalReferenceResultIds A map from reference result identifiers to its canonical identifier.
  * @param pathExistenceChecker An object that tracks whether a path is visible within the LSIF dump.
+ * @param payloadEncoding The encoding of the document payloads.
  */
 async function populateDocumentsTable(
     correlator: Correlator,
     documentInserter: TableInserter<sqliteModels.DocumentModel, new () => sqliteModels.DocumentModel>,
     canonicalReferenceResultIds: Map<sqliteModels.ReferenceResultId, sqliteModels.ReferenceResultId>,
-    pathExistenceChecker: PathExistenceChecker
-): Promise<void> {
+    pathExistenceChecker: PathExistenceChecker,
+    payloadEncoding: PayloadEncoding
+): Promise<DocumentStatistics> {
+    const statistics: DocumentStatistics = { numDocuments: 0, numRanges: 0, documentsByLanguage: {} }
+
     // Collapse result sets data into the ranges that can reach them. The
     // remainder of this function assumes that we can completely ignore
     // the "next" edges coming from range data.
@@ -233,14 +330,35 @@ async function populateDocumentsTable(
         // Encode and insert document record
         await documentInserter.insert({
             path: documentPath,
-            data: await gzipJSON({
-                ranges: document.ranges,
-                hoverResults: document.hoverResults,
-                monikers: document.monikers,
-                packageInformation: document.packageInformation,
-            }),
+            data: await encodePayload(
+                {
+                    ranges: document.ranges,
+                    hoverResults: document.hoverResults,
+                    monikers: document.monikers,
+                    packageInformation: document.packageInformation,
+                },
+                payloadEncoding
+            ),
         })
+
+        // Update document statistics
+        const language = languageFromPath(documentPath)
+        statistics.numDocuments++
+        statistics.numRanges += document.ranges.size
+        statistics.documentsByLanguage[language] = (statistics.documentsByLanguage[language] || 0) + 1
     }
+
+    return statistics
+}
+
+/**
+ * Return the language of a document as identified by its (lowercased) file extension.
+ * Documents without an extension are attributed to the language `unknown`.
+ *
+ * @param documentPath The path of the document.
+ */
+export function languageFromPath(documentPath: string): string {
+    return nodepath.extname(documentPath).slice(1).toLowerCase() || 'unknown'
 }
 
 /**
@@ -250,12 +368,14 @@ async function populateDocumentsTable(
  * @param resultChunkInserter The inserter for the result chunks table.
  * @param numResultChunks The number of result chunks used to hash compute the result identifier hash.
  * @param pathExistenceChecker An object that tracks whether a path is visible within the LSIF dump.
+ * @param payloadEncoding The encoding of the result chunk payloads.
  */
 async function populateResultChunksTable(
     correlator: Correlator,
     resultChunkInserter: TableInserter<sqliteModels.ResultChunkModel, new () => sqliteModels.ResultChunkModel>,
     numResultChunks: number,
-    pathExistenceChecker: PathExistenceChecker
+    pathExistenceChecker: PathExistenceChecker,
+    payloadEncoding: PayloadEncoding
 ): Promise<void> {
     // Create all the result chunks we'll be populating and inserting up-front. Data will
     // be inserted into result chunks based on hash values (modulo the number of result chunks),
@@ -300,9 +420,10 @@ async function populateResultChunksTable(
         }
     }
 
-    // Add definitions and references to result chunks
+    // Add definitions, references, and implementations to result chunks
     chunkResults(correlator.definitionData)
     chunkResults(correlator.referenceData)
+    chunkResults(correlator.implementationData)
 
     for (const [id, resultChunk] of resultChunks.entries()) {
         // Empty chunk, no need to serialize as it will never be queried
@@ -310,10 +431,13 @@ async function populateResultChunksTable(
             continue
         }
 
-        const data = await gzipJSON({
-            documentPaths: resultChunk.paths,
-            documentIdRangeIds: resultChunk.documentIdRangeIds,
-        })
+        const data = await encodePayload(
+            {
+                documentPaths: resultChunk.paths,
+                documentIdRangeIds: resultChunk.documentIdRangeIds,
+            },
+            payloadEncoding
+        )
 
         // Encode and insert result chunk record
         await resultChunkInserter.insert({ id, data })
@@ -418,26 +542,70 @@ async function populateDefinitionsAndReferencesTables(
     await insertMonikerRanges(correlator.referenceData, referenceMonikers, referenceInserter)
 }
 
+/**
+ * Insert a row for each diagnostic attached to a document in this dump.
+ *
+ * @param correlator The correlator with all vertices and edges inserted.
+ * @param diagnosticInserter The inserter for the diagnostics table.
+ * @param pathExistenceChecker An object that tracks whether a path is visible within the LSIF dump.
+ */
+async function populateDiagnosticsTable(
+    correlator: Correlator,
+    diagnosticInserter: TableInserter<sqliteModels.DiagnosticModel, new () => sqliteModels.DiagnosticModel>,
+    pathExistenceChecker: PathExistenceChecker
+): Promise<void> {
+    for (const [documentId, diagnosticResultIds] of correlator.documentDiagnostics) {
+        const documentPath = correlator.documentPaths.get(documentId)
+
+        // Skip diagnostics of documents that were merged into another document or that
+        // are not present in the dump, as they would never be queried.
+        if (documentPath === undefined || !pathExistenceChecker.shouldIncludePath(documentPath)) {
+            continue
+        }
+
+        for (const diagnosticResultId of diagnosticResultIds) {
+            for (const diagnostic of mustGet(correlator.diagnosticData, diagnosticResultId, 'diagnosticResult')) {
+                await diagnosticInserter.insert({
+                    documentPath,
+                    severity: diagnostic.severity === undefined ? null : diagnostic.severity,
+                    code: diagnostic.code === undefined ? null : `${diagnostic.code}`,
+                    message: diagnostic.message,
+                    source: diagnostic.source === undefined ? null : diagnostic.source,
+                    startLine: diagnostic.range.start.line,
+                    startCharacter: diagnostic.range.start.character,
+                    endLine: diagnostic.range.end.line,
+                    endCharacter: diagnostic.range.end.character,
+                })
+            }
+        }
+    }
+}
+
 /**
  * Insert metadata row. This gives us a place to store the version of the converter that
  * created a database in case we have backwards-incompatible changes in the future that
  * require historic version flagging. This also stores the number of result chunks
- * determined above so that we can have stable hashes at query time.
+ * determined above so that we can have stable hashes at query time, and the encoding
+ * of the document and result chunk payloads so that they can be decoded.
  *
  * @param correlator The correlator with all vertices and edges inserted.
  * @param metaInserter The inserter for the meta table.
  * @param numResultChunks The number of result chunks used to hash compute the result identifier hash.
+ * @param payloadEncoding The encoding of the document and result chunk payloads.
  */
 async function populateMetadataTable(
     correlator: Correlator,
     metaInserter: TableInserter<sqliteModels.MetaModel, new () => sqliteModels.MetaModel>,
-    numResultChunks: number
+    numResultChunks: number,
+    payloadEncoding: PayloadEncoding
 ): Promise<void> {
     await metaInserter.insert({
         id: 1,
         lsifVersion: correlator.lsifVersion,
         sourcegraphVersion: INTERNAL_LSIF_VERSION,
         numResultChunks,
+        schemaVersion: sqliteModels.CURRENT_SCHEMA_VERSION,
+        payloadEncoding,
     })
 }
 
@@ -518,6 +686,8 @@ function mergeDocuments(correlator: Correlator): void {
         mergeContains(id, canonicalId, correlator.containsData)
         mergeDefinitionReferences(id, canonicalId, correlator.definitionData)
         mergeDefinitionReferences(id, canonicalId, correlator.referenceData)
+        mergeDefinitionReferences(id, canonicalId, correlator.implementationData)
+        mergeDiagnostics(id, canonicalId, correlator.documentDiagnostics)
 
         // Discard the document data as a flag to prevent inserting one
         // of the documents subsumed by the canonical representative.
@@ -548,6 +718,28 @@ function mergeContains(
     containsData.delete(id)
 }
 
+/**
+ * Move the diagnostic results attached to document `id` into the diagnostic results
+ * of document `canonicalId`, then delete the reference to document `id`.
+ *
+ * @param id The id of the document to replace.
+ * @param canonicalId The id of the document to subsume the other.
+ * @param documentDiagnostics The document diagnostics map of the correlator.
+ */
+function mergeDiagnostics(
+    id: sqliteModels.DocumentId,
+    canonicalId: sqliteModels.DocumentId,
+    documentDiagnostics: DefaultMap<sqliteModels.DocumentId, sqliteModels.DiagnosticResultId[]>
+): void {
+    const diagnosticResultIds = documentDiagnostics.get(id)
+    if (diagnosticResultIds === undefined) {
+        return
+    }
+
+    documentDiagnostics.getOrDefault(canonicalId).push(...diagnosticResultIds)
+    documentDiagnostics.delete(id)
+}
+
 /**
  * Move the definition or reference data for document `id` into the definition or
  * reference data of document `canonicalId`, then delete the reference to document
@@ -631,9 +823,9 @@ function canonicalizeReferenceResults(
     return canonicalReferenceResultIds
 }
 /**
- * Flatten the definition result, reference result, hover results, and monikers of range
- * and result set items by following next links in the graph. This needs to be run over
- * each range before committing them to a document.
+ * Flatten the definition result, reference result, implementation result, hover results,
+ * and monikers of range and result set items by following next links in the graph. This
+ * needs to be run over each range before committing them to a document.
  *
  * @param correlator The correlator with all vertices and edges inserted.
  * @param canonicalReferenceResultIds A map from reference result identifiers to its canonical identifier.
@@ -673,8 +865,8 @@ function canonicalizeItem(
             monikers.add(monikerId)
         }
 
-        // If we do not have a definition, reference, or hover result, take the result
-        // value from the next item.
+        // If we do not have a definition, reference, implementation, or hover result,
+        // take the result value from the next item.
 
         if (item.definitionResultId === undefined) {
             item.definitionResultId = nextItem.definitionResultId
@@ -684,6 +876,10 @@ function canonicalizeItem(
             item.referenceResultId = nextItem.referenceResultId
         }
 
+        if (item.implementationResultId === undefined) {
+            item.implementationResultId = nextItem.implementationResultId
+        }
+
         if (item.hoverResultId === undefined) {
             item.hoverResultId = nextItem.hoverResultId
         }
@@ -0,0 +1,49 @@
+import * as pgModels from '../../shared/models/pg'
+import { ConversionProgress } from './progress'
+import { createSilentLogger } from '../../shared/logging'
+
+describe('ConversionProgress', () => {
+    it('should record new steps and throttle records of the same step', async () => {
+        const records: [pgModels.LsifUploadStage, number][] = []
+        let now = 0
+
+        const progress = new ConversionProgress(
+            (stage, value) => {
+                records.push([stage, value])
+                return Promise.resolve()
+            },
+            createSilentLogger(),
+            1000,
+            () => now
+        )
+
+        progress.report('downloading', 0)
+        progress.report('correlating', 0)
+        now = 500
+        progress.report('correlating', 0.5) // within interval
+        now = 1500
+        progress.report('correlating', 0.5)
+        now = 3000
+        progress.report('correlating', 0.5) // did not advance
+        progress.report('writing', 1)
+        await progress.flush()
+
+        expect(records).toEqual([
+            ['downloading', 0],
+            ['correlating', 5],
+            ['correlating', 30],
+            ['writing', 90],
+        ])
+    })
+
+    it('should not fail when progress cannot be recorded', async () => {
+        const progress = new ConversionProgress(
+            () => Promise.reject(new Error('oops')),
+            createSilentLogger(),
+            1000
+        )
+
+        progress.report('downloading', 0)
+        await progress.flush()
+    })
+})
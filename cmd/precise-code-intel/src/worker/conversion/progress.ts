@@ -0,0 +1,82 @@
+import * as pgModels from '../../shared/models/pg'
+import { Logger } from 'winston'
+
+/**
+ * A function that reports the fraction (between 0 and 1) of the given conversion step that
+ * has completed.
+ */
+export type ProgressCallback = (stage: pgModels.LsifUploadStage, fraction: number) => void
+
+/** The range of the overall progress (as a percentage) covered by each conversion step. */
+const STAGE_RANGES: { [K in pgModels.LsifUploadStage]: [number, number] } = {
+    downloading: [0, 5],
+    correlating: [5, 55],
+    writing: [55, 90],
+    dependencies: [90, 95],
+    uploading: [95, 100],
+}
+
+/**
+ * Tracks the progress of the conversion of an upload and records it as it advances. Records
+ * are written one at a time in the order in which they are reported, and are throttled so that
+ * a step that advances quickly does not issue a write for every reported fraction. A failure to
+ * record progress is logged and does not fail the conversion.
+ */
+export class ConversionProgress {
+    /** A promise that resolves once every reported progress has been recorded. */
+    private pending: Promise<void> = Promise.resolve()
+
+    /** The last recorded step. */
+    private lastStage?: pgModels.LsifUploadStage
+
+    /** The last recorded progress. */
+    private lastProgress = -1
+
+    /** The time at which progress was last recorded. */
+    private lastRecordedAt = 0
+
+    /**
+     * Create a new `ConversionProgress`.
+     *
+     * @param record The function that records the overall progress of the conversion.
+     * @param logger The logger instance.
+     * @param interval The minimum interval (in milliseconds) between records of the same step.
+     * @param now The function that returns the current time in milliseconds.
+     */
+    constructor(
+        private record: (stage: pgModels.LsifUploadStage, progress: number) => Promise<void>,
+        private logger: Logger,
+        private interval: number,
+        private now: () => number = Date.now
+    ) {}
+
+    /**
+     * Report that the given fraction of a conversion step has completed. A progress of a step
+     * that has already been recorded is only recorded if it has advanced and the interval has
+     * passed since the last record.
+     *
+     * @param stage The conversion step in progress.
+     * @param fraction The fraction (between 0 and 1) of the step that has completed.
+     */
+    public report: ProgressCallback = (stage, fraction) => {
+        const [lower, upper] = STAGE_RANGES[stage]
+        const progress = Math.floor(lower + (upper - lower) * Math.max(0, Math.min(1, fraction)))
+        const now = this.now()
+
+        if (stage === this.lastStage && (progress <= this.lastProgress || now - this.lastRecordedAt < this.interval)) {
+            return
+        }
+
+        this.lastStage = stage
+        this.lastProgress = progress
+        this.lastRecordedAt = now
+        this.pending = this.pending
+            .then(() => this.record(stage, progress))
+            .catch(error => this.logger.warn('Failed to record conversion progress', { stage, progress, error }))
+    }
+
+    /** Wait until every reported progress has been recorded. */
+    public flush(): Promise<void> {
+        return this.pending
+    }
+}
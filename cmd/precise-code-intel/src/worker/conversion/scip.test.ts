@@ -0,0 +1,112 @@
+import * as lsif from 'lsif-protocol'
+import { Correlator } from './correlator'
+import { parseSymbol, scipElements } from './scip'
+import { ScipIndex, ScipPositionEncoding, SYMBOL_ROLE_DEFINITION } from '../../shared/encoding/scip'
+
+describe('parseSymbol', () => {
+    it('should split global symbols', () => {
+        expect(parseSymbol('scip-go gomod github.com/foo/bar v1.2.3 `pkg/baz`/Qux#')).toEqual({
+            scheme: 'scip-go',
+            manager: 'gomod',
+            name: 'github.com/foo/bar',
+            version: 'v1.2.3',
+            descriptor: '`pkg/baz`/Qux#',
+        })
+    })
+
+    it('should unescape spaces and empty placeholders', () => {
+        expect(parseSymbol('scip-java maven . . a  b/c().')).toEqual({
+            scheme: 'scip-java',
+            manager: 'maven',
+            name: '',
+            version: '',
+            descriptor: 'a  b/c().',
+        })
+        expect(parseSymbol('my  scheme npm pkg 1.0.0 x.')?.scheme).toEqual('my scheme')
+    })
+
+    it('should ignore local and malformed symbols', () => {
+        expect(parseSymbol('local 12')).toBeUndefined()
+        expect(parseSymbol('scip-go gomod')).toBeUndefined()
+    })
+})
+
+describe('scipElements', () => {
+    const exported = 'scip-typescript npm pkg 1.0.0 src/`a.ts`/foo().'
+    const imported = 'scip-typescript npm dep 2.0.0 `lib.ts`/bar().'
+
+    const index: ScipIndex = {
+        metadata: {
+            version: 0,
+            toolInfo: { name: 'scip-typescript', version: '', arguments: [] },
+            projectRoot: 'file:///root',
+        },
+        documents: [
+            {
+                relativePath: 'src/a.ts',
+                language: 'TypeScript',
+                positionEncoding: ScipPositionEncoding.unspecified,
+                symbols: [{ symbol: exported, documentation: ['```ts\nfunction foo()\n```'], relationships: [] }],
+                occurrences: [
+                    {
+                        range: [1, 9, 12],
+                        symbol: exported,
+                        symbolRoles: SYMBOL_ROLE_DEFINITION,
+                        overrideDocumentation: [],
+                    },
+                    { range: [2, 0, 3], symbol: imported, symbolRoles: 0, overrideDocumentation: [] },
+                    {
+                        range: [3, 0, 1],
+                        symbol: 'local 0',
+                        symbolRoles: SYMBOL_ROLE_DEFINITION,
+                        overrideDocumentation: [],
+                    },
+                ],
+            },
+            {
+                relativePath: 'src/b.ts',
+                language: 'TypeScript',
+                positionEncoding: ScipPositionEncoding.utf16,
+                symbols: [],
+                occurrences: [
+                    { range: [4, 0, 5, 3], symbol: exported, symbolRoles: 0, overrideDocumentation: [] },
+                    { range: [5, 0, 1], symbol: 'local 0', symbolRoles: 0, overrideDocumentation: [] },
+                ],
+            },
+        ],
+        externalSymbols: [],
+    }
+
+    it('should produce elements that can be correlated', () => {
+        const correlator = new Correlator()
+        for (const element of scipElements(index)) {
+            correlator.insert(element)
+        }
+
+        expect(correlator.lsifVersion).toEqual('0.4.3')
+        expect([...correlator.documentPaths.values()]).toEqual(['src/a.ts', 'src/b.ts'])
+        expect(correlator.rangeData.size).toEqual(5)
+
+        // One result set for each global symbol and each document's local symbol
+        expect(correlator.resultSetData.size).toEqual(4)
+        expect(correlator.definitionData.size).toEqual(2)
+        expect([...correlator.hoverData.values()]).toEqual(['```ts\nfunction foo()\n```'])
+        expect([...correlator.monikerData.values()]).toEqual([
+            { kind: lsif.MonikerKind.export, scheme: 'scip-typescript', identifier: 'src/`a.ts`/foo().' },
+            { kind: lsif.MonikerKind.import, scheme: 'scip-typescript', identifier: '`lib.ts`/bar().' },
+        ])
+        expect([...correlator.packageInformationData.values()]).toEqual([
+            { name: 'pkg', version: '1.0.0' },
+            { name: 'dep', version: '2.0.0' },
+        ])
+    })
+
+    it('should reject documents with unsupported position encodings', () => {
+        const utf8Index = {
+            ...index,
+            documents: [{ ...index.documents[0], positionEncoding: ScipPositionEncoding.utf8 }],
+        }
+
+        expect(() => [...scipElements(utf8Index)]).toThrow('Unsupported position encoding utf8 in src/a.ts')
+    })
+})
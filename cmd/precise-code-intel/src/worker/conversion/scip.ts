@@ -0,0 +1,425 @@
+import * as lsif from 'lsif-protocol'
+import {
+    ScipDocument,
+    ScipIndex,
+    ScipOccurrence,
+    ScipPositionEncoding,
+    ScipSymbolInformation,
+    SYMBOL_ROLE_DEFINITION,
+} from '../../shared/encoding/scip'
+
+/** The LSIF version reported for the elements synthesized from a SCIP index. */
+export const SCIP_LSIF_VERSION = '0.4.3'
+
+/** The state of a symbol that occurs in the index. */
+interface SymbolState {
+    /** The SCIP symbol. */
+    symbol: string
+
+    /** The identifier of the result set shared by each occurrence of the symbol. */
+    resultSetId: lsif.Id
+
+    /** The identifier of the definition result, created on the first definition occurrence. */
+    definitionResultId?: lsif.Id
+
+    /** The identifier of the reference result. */
+    referenceResultId: lsif.Id
+
+    /** The documents and ranges at which the symbol is defined. */
+    definitions: { documentId: lsif.Id; rangeId: lsif.Id }[]
+}
+
+/** The components of a global SCIP symbol. */
+interface ParsedSymbol {
+    scheme: string
+    manager: string
+    name: string
+    version: string
+    descriptor: string
+}
+
+/**
+ * Translate a decoded SCIP index into the LSIF vertices and edges that describe the same
+ * data, so that SCIP indexes can be fed through the same correlation and import steps as
+ * JSON lines uploads. Each symbol becomes a result set with definition, reference, and
+ * hover results. Global symbols are attached to a moniker (and package information when
+ * the symbol names a package), exported if the index defines the symbol and imported
+ * otherwise. Implementation relationships populate implementation results.
+ *
+ * Documents with UTF-8 or UTF-32 position offsets are rejected. Documents that do not
+ * declare an encoding are assumed to use UTF-16 offsets, as LSIF does.
+ *
+ * @param index The decoded SCIP index.
+ */
+export function* scipElements(index: ScipIndex): Iterable<lsif.Vertex | lsif.Edge> {
+    if (!index.metadata) {
+        throw new Error('No metadata defined.')
+    }
+
+    let nextId = 0
+    const id = (): lsif.Id => ++nextId
+    const { projectRoot, toolInfo } = index.metadata
+    const rootUri = new URL(projectRoot.endsWith('/') ? projectRoot : projectRoot + '/')
+
+    yield {
+        id: id(),
+        type: lsif.ElementTypes.vertex,
+        label: lsif.VertexLabels.metaData,
+        version: SCIP_LSIF_VERSION,
+        positionEncoding: 'utf-16',
+        projectRoot,
+        toolInfo: toolInfo && { name: toolInfo.name, version: toolInfo.version, args: toolInfo.arguments },
+    }
+
+    const symbols = new Map<string, SymbolState>()
+    const documentation = new Map<string, string[]>()
+    const relationships = new Map<string, ScipSymbolInformation['relationships']>()
+
+    const addSymbolInformation = (key: string, information: ScipSymbolInformation): void => {
+        if (information.documentation.length > 0) {
+            documentation.set(key, information.documentation)
+        }
+        if (information.relationships.length > 0) {
+            relationships.set(key, information.relationships)
+        }
+    }
+
+    for (const information of index.externalSymbols) {
+        addSymbolInformation(information.symbol, information)
+    }
+
+    for (const [documentIndex, document] of index.documents.entries()) {
+        checkPositionEncoding(document)
+
+        // Local symbols are only unique within a document
+        const symbolKey = (symbol: string): string => (isLocal(symbol) ? `${documentIndex}:${symbol}` : symbol)
+
+        for (const information of document.symbols) {
+            addSymbolInformation(symbolKey(information.symbol), information)
+        }
+
+        const documentId = id()
+        yield {
+            id: documentId,
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.document,
+            uri: new URL(document.relativePath, rootUri).href,
+            languageId: document.language.toLowerCase(),
+        }
+
+        const rangeIds: lsif.Id[] = []
+        for (const occurrence of document.occurrences) {
+            const range = decodeRange(occurrence)
+            if (!range || occurrence.symbol === '') {
+                continue
+            }
+
+            const key = symbolKey(occurrence.symbol)
+            let state = symbols.get(key)
+            if (!state) {
+                state = { symbol: occurrence.symbol, resultSetId: id(), referenceResultId: id(), definitions: [] }
+                symbols.set(key, state)
+
+                yield { id: state.resultSetId, type: lsif.ElementTypes.vertex, label: lsif.VertexLabels.resultSet }
+                yield {
+                    id: state.referenceResultId,
+                    type: lsif.ElementTypes.vertex,
+                    label: lsif.VertexLabels.referenceResult,
+                }
+                yield {
+                    id: id(),
+                    type: lsif.ElementTypes.edge,
+                    label: lsif.EdgeLabels.textDocument_references,
+                    outV: state.resultSetId,
+                    inV: state.referenceResultId,
+                }
+            }
+
+            const rangeId = id()
+            rangeIds.push(rangeId)
+            yield { id: rangeId, type: lsif.ElementTypes.vertex, label: lsif.VertexLabels.range, ...range }
+            yield {
+                id: id(),
+                type: lsif.ElementTypes.edge,
+                label: lsif.EdgeLabels.next,
+                outV: rangeId,
+                inV: state.resultSetId,
+            }
+
+            if (occurrence.overrideDocumentation.length > 0) {
+                yield* hoverElements(id, rangeId, occurrence.overrideDocumentation)
+            }
+
+            const isDefinition = (occurrence.symbolRoles & SYMBOL_ROLE_DEFINITION) !== 0
+            if (isDefinition) {
+                if (state.definitionResultId === undefined) {
+                    state.definitionResultId = id()
+                    yield {
+                        id: state.definitionResultId,
+                        type: lsif.ElementTypes.vertex,
+                        label: lsif.VertexLabels.definitionResult,
+                    }
+                    yield {
+                        id: id(),
+                        type: lsif.ElementTypes.edge,
+                        label: lsif.EdgeLabels.textDocument_definition,
+                        outV: state.resultSetId,
+                        inV: state.definitionResultId,
+                    }
+                }
+
+                state.definitions.push({ documentId, rangeId })
+                yield {
+                    id: id(),
+                    type: lsif.ElementTypes.edge,
+                    label: lsif.EdgeLabels.item,
+                    outV: state.definitionResultId,
+                    inVs: [rangeId],
+                    document: documentId,
+                }
+            }
+
+            yield {
+                id: id(),
+                type: lsif.ElementTypes.edge,
+                label: lsif.EdgeLabels.item,
+                outV: state.referenceResultId,
+                inVs: [rangeId],
+                document: documentId,
+                property: isDefinition ? lsif.ItemEdgeProperties.definitions : lsif.ItemEdgeProperties.references,
+            }
+        }
+
+        if (rangeIds.length > 0) {
+            yield {
+                id: id(),
+                type: lsif.ElementTypes.edge,
+                label: lsif.EdgeLabels.contains,
+                outV: documentId,
+                inVs: rangeIds,
+            }
+        }
+    }
+
+    const packageInformationIds = new Map<string, lsif.Id>()
+
+    for (const [key, state] of symbols) {
+        const docs = documentation.get(key)
+        if (docs) {
+            yield* hoverElements(id, state.resultSetId, docs)
+        }
+
+        const parsed = parseSymbol(state.symbol)
+        if (!parsed) {
+            continue
+        }
+
+        const monikerId = id()
+        yield {
+            id: monikerId,
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.moniker,
+            scheme: parsed.scheme,
+            identifier: parsed.descriptor,
+            kind: state.definitions.length > 0 ? lsif.MonikerKind.export : lsif.MonikerKind.import,
+        }
+        yield {
+            id: id(),
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.moniker,
+            outV: state.resultSetId,
+            inV: monikerId,
+        }
+
+        if (parsed.name === '') {
+            continue
+        }
+
+        const packageKey = [parsed.manager, parsed.name, parsed.version].join(' ')
+        let packageInformationId = packageInformationIds.get(packageKey)
+        if (packageInformationId === undefined) {
+            packageInformationId = id()
+            packageInformationIds.set(packageKey, packageInformationId)
+            yield {
+                id: packageInformationId,
+                type: lsif.ElementTypes.vertex,
+                label: lsif.VertexLabels.packageInformation,
+                name: parsed.name,
+                manager: parsed.manager,
+                version: parsed.version || undefined,
+            }
+        }
+
+        yield {
+            id: id(),
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.packageInformation,
+            outV: monikerId,
+            inV: packageInformationId,
+        }
+    }
+
+    // The definitions of a symbol that implements another are implementations of the other
+    const implementations = new Map<string, { documentId: lsif.Id; rangeId: lsif.Id }[]>()
+    for (const [key, state] of symbols) {
+        for (const relationship of relationships.get(key) || []) {
+            if (relationship.isImplementation && symbols.has(relationship.symbol)) {
+                implementations.set(relationship.symbol, [
+                    ...(implementations.get(relationship.symbol) || []),
+                    ...state.definitions,
+                ])
+            }
+        }
+    }
+
+    for (const [key, definitions] of implementations) {
+        const state = symbols.get(key)
+        if (!state || definitions.length === 0) {
+            continue
+        }
+
+        const implementationResultId = id()
+        yield {
+            id: implementationResultId,
+            type: lsif.ElementTypes.vertex,
+            label: lsif.VertexLabels.implementationResult,
+        }
+        yield {
+            id: id(),
+            type: lsif.ElementTypes.edge,
+            label: lsif.EdgeLabels.textDocument_implementation,
+            outV: state.resultSetId,
+            inV: implementationResultId,
+        }
+
+        for (const { documentId, rangeId } of definitions) {
+            yield {
+                id: id(),
+                type: lsif.ElementTypes.edge,
+                label: lsif.EdgeLabels.item,
+                outV: implementationResultId,
+                inVs: [rangeId],
+                document: documentId,
+            }
+        }
+    }
+}
+
+/**
+ * Yield a hover result with the given documentation and the edge attaching it to the
+ * given range or result set.
+ *
+ * @param id A function that returns a fresh element identifier.
+ * @param outV The identifier of the range or result set.
+ * @param documentation The markdown documentation of the symbol.
+ */
+function* hoverElements(
+    id: () => lsif.Id,
+    outV: lsif.Id,
+    documentation: string[]
+): Iterable<lsif.Vertex | lsif.Edge> {
+    const hoverResultId = id()
+    yield {
+        id: hoverResultId,
+        type: lsif.ElementTypes.vertex,
+        label: lsif.VertexLabels.hoverResult,
+        result: { contents: documentation },
+    }
+    yield {
+        id: id(),
+        type: lsif.ElementTypes.edge,
+        label: lsif.EdgeLabels.textDocument_hover,
+        outV,
+        inV: hoverResultId,
+    }
+}
+
+/**
+ * Throw if the document declares position offsets in an encoding other than UTF-16.
+ *
+ * @param document The SCIP document.
+ */
+function checkPositionEncoding(document: ScipDocument): void {
+    if (
+        document.positionEncoding !== ScipPositionEncoding.unspecified &&
+        document.positionEncoding !== ScipPositionEncoding.utf16
+    ) {
+        const encoding = ScipPositionEncoding[document.positionEncoding] || document.positionEncoding
+        throw new Error(`Unsupported position encoding ${encoding} in ${document.relativePath} (expected utf16)`)
+    }
+}
+
+/**
+ * Return the start and end positions of the occurrence. Returns undefined if the range
+ * is malformed.
+ *
+ * @param occurrence The SCIP occurrence.
+ */
+export function decodeRange({ range }: ScipOccurrence): Pick<lsif.Range, 'start' | 'end'> | undefined {
+    if (range.length === 3) {
+        const [line, startCharacter, endCharacter] = range
+        return { start: { line, character: startCharacter }, end: { line, character: endCharacter } }
+    }
+
+    if (range.length === 4) {
+        const [startLine, startCharacter, endLine, endCharacter] = range
+        return {
+            start: { line: startLine, character: startCharacter },
+            end: { line: endLine, character: endCharacter },
+        }
+    }
+
+    return undefined
+}
+
+/**
+ * Determine if the given symbol is local to its document.
+ *
+ * @param symbol The SCIP symbol.
+ */
+function isLocal(symbol: string): boolean {
+    return symbol.startsWith('local ')
+}
+
+/**
+ * Split a global SCIP symbol into its scheme, package, and descriptor. Spaces within
+ * a component are escaped as double spaces, and a package component of `.` is empty.
+ * Returns undefined for local and malformed symbols.
+ *
+ * @param symbol The SCIP symbol.
+ */
+export function parseSymbol(symbol: string): ParsedSymbol | undefined {
+    if (isLocal(symbol)) {
+        return undefined
+    }
+
+    const components: string[] = []
+    let current = ''
+    let i = 0
+
+    while (i < symbol.length && components.length < 4) {
+        if (symbol[i] === ' ') {
+            if (symbol[i + 1] === ' ') {
+                current += ' '
+                i += 2
+                continue
+            }
+
+            components.push(current)
+            current = ''
+            i++
+            continue
+        }
+
+        current += symbol[i]
+        i++
+    }
+
+    const descriptor = symbol.slice(i)
+    if (components.length < 4 || descriptor === '') {
+        return undefined
+    }
+
+    const [scheme, manager, name, version] = components.map(component => (component === '.' ? '' : component))
+    return { scheme, manager, name, version, descriptor }
+}
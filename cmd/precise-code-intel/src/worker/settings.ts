@@ -1,4 +1,6 @@
-import { readEnvInt } from '../shared/settings'
+import { readEnvBool, readEnvInt } from '../shared/settings'
+import { parseShardUrls } from '../shared/shards'
+import { parsePayloadEncoding } from '../shared/encoding/payload'
 
 /** Which port to run the metrics server on. Defaults to 3188. */
 export const METRICS_PORT = readEnvInt('METRICS_PORT', 3188)
@@ -7,12 +9,24 @@ export const METRICS_PORT = readEnvInt('METRICS_PORT', 3188)
 export const PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL =
     process.env.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL || 'http://localhost:3187'
 
+/**
+ * HTTP addresses for internal precise code intel bundle manager servers, as a comma-separated
+ * list. Bundles are sharded across these servers by dump identifier. Defaults to the single
+ * server given by PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL.
+ */
+export const PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS = parseShardUrls(
+    process.env.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS || PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL
+)
+
 /** Where on the file system to temporarily store LSIF uploads and SQLite files. This is NOT a persistent volume. */
 export const STORAGE_ROOT = process.env.LSIF_STORAGE_ROOT || 'lsif-storage'
 
 /** The interval (in seconds) to poll the database for unconverted uploads. */
 export const POLLING_INTERVAL = readEnvInt('POLLING_INTERVAL', 1)
 
+/** The interval (in seconds) between heartbeats recorded for the upload being converted. */
+export const HEARTBEAT_INTERVAL = readEnvInt('HEARTBEAT_INTERVAL', 5)
+
 /**
  * The target results per result chunk. This is used to determine the number of chunks
  * created during conversion, but does not guarantee that the distribution of hash keys
@@ -22,3 +36,25 @@ export const RESULTS_PER_RESULT_CHUNK = readEnvInt('RESULTS_PER_RESULT_CHUNK', 5
 
 /** The maximum number of result chunks that will be created during conversion. */
 export const MAX_NUM_RESULT_CHUNKS = readEnvInt('MAX_NUM_RESULT_CHUNKS', 1000)
+
+/**
+ * The encoding of the document and result chunk payloads of converted bundles, either
+ * `gzip-json` or `gzip-binary`. The binary encoding is cheaper to decode, but can only be
+ * read by bundle managers that support it, so it should only be enabled once every bundle
+ * manager has been upgraded.
+ */
+export const BUNDLE_PAYLOAD_ENCODING = parsePayloadEncoding(process.env.BUNDLE_PAYLOAD_ENCODING || 'gzip-json')
+
+/**
+ * Whether dumps superseded by a newly converted dump are also marked for deletion, so that they
+ * no longer answer queries. Marked dumps can be restored until they are purged by the janitor
+ * once the restore window has passed. When disabled, superseded dumps are only pruned first
+ * once the disk is under pressure.
+ */
+export const DELETE_SUPERSEDED_DUMPS = readEnvBool('DELETE_SUPERSEDED_DUMPS', true)
+
+/**
+ * The minimum interval (in milliseconds) between two updates of the progress of the same
+ * conversion step. Progress is always recorded when a new step begins.
+ */
+export const PROGRESS_UPDATE_INTERVAL_MS = readEnvInt('PROGRESS_UPDATE_INTERVAL_MS', 1000)
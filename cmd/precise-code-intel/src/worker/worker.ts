@@ -1,8 +1,9 @@
 import * as metrics from './metrics'
+import * as os from 'os'
 import * as path from 'path'
 import * as settings from './settings'
 import promClient from 'prom-client'
-import { addTags, createTracer, logAndTraceCall, TracingContext } from '../shared/tracing'
+import { addTags, createTracer, logAndTraceCall, TracingContext, tracingHeaders } from '../shared/tracing'
 import { createLogger } from '../shared/logging'
 import { createPostgresConnection } from '../shared/database/postgres'
 import { ensureDirectory } from '../shared/paths'
@@ -13,22 +14,33 @@ import { waitForConfiguration } from '../shared/config/config'
 import { UploadManager } from '../shared/store/uploads'
 import * as pgModels from '../shared/models/pg'
 import { convertDatabase } from './conversion/conversion'
+import { ConversionProgress } from './conversion/progress'
 import { pick } from 'lodash'
 import AsyncPolling from 'async-polling'
 import { DumpManager } from '../shared/store/dumps'
 import { DependencyManager } from '../shared/store/dependencies'
 import { EntityManager } from 'typeorm'
-import { SRC_FRONTEND_INTERNAL } from '../shared/config/settings'
+import { BUNDLE_MANAGER_TRANSPORT, SRC_FRONTEND_INTERNAL } from '../shared/config/settings'
 import { updateCommitsAndDumpsVisibleFromTip } from '../shared/visibility'
 import { startExpressApp } from '../shared/api/init'
 import * as uuid from 'uuid'
-import got from 'got'
+import { createBundleManagerClient } from '../shared/api/client'
+import { authorizationHeaders } from '../shared/api/middleware/auth'
+import { checksumMismatchError, ChecksumStream } from '../shared/checksum'
 import { pipeline as _pipeline } from 'stream'
 import { promisify } from 'util'
 import * as fs from 'mz/fs'
+import { ShardRing } from '../shared/shards'
+import { BundleManagerGrpcClient } from '../shared/grpc/client'
 
 const pipeline = promisify(_pipeline)
 
+/** The HTTP client used for all requests to the bundle managers. */
+const bundleManagerClient = createBundleManagerClient()
+
+/** The gRPC client used to upload bundles to the bundle managers, if the gRPC transport is enabled. */
+const bundleManagerGrpcClient = BUNDLE_MANAGER_TRANSPORT === 'grpc' ? new BundleManagerGrpcClient() : undefined
+
 /**
  * Runs the worker process that converts LSIF uploads.
  *
@@ -53,6 +65,12 @@ async function main(logger: Logger): Promise<void> {
     const uploadManager = new UploadManager(connection)
     const dependencyManager = new DependencyManager(connection)
 
+    // Identifies this process in the uploads it dequeues
+    const workerId = `${os.hostname()}:${process.pid}`
+
+    // Bundles are sharded across bundle managers by upload identifier
+    const shards = new ShardRing(settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URLS)
+
     // Start metrics server
     startExpressApp({ port: settings.METRICS_PORT, logger })
 
@@ -72,6 +90,14 @@ async function main(logger: Logger): Promise<void> {
         // Tag tracing context with uploadId and arguments
         const ctx = addTags({ logger, span }, { uploadId: upload.id, ...pick(upload, 'repository', 'commit', 'root') })
 
+        // Progress is recorded outside of the conversion transaction so that it is visible
+        // while the upload is being converted
+        const progress = new ConversionProgress(
+            (stage, value) => uploadManager.updateProgress(upload.id, stage, value),
+            logger,
+            settings.PROGRESS_UPDATE_INTERVAL_MS
+        )
+
         await instrument(
             metrics.uploadConversionDurationHistogram,
             metrics.uploadConversionDurationErrorsCounter,
@@ -79,34 +105,81 @@ async function main(logger: Logger): Promise<void> {
                 logAndTraceCall(ctx, 'Converting upload', async (ctx: TracingContext) => {
                     const sourcePath = path.join(settings.STORAGE_ROOT, uuid.v4())
                     const targetPath = path.join(settings.STORAGE_ROOT, uuid.v4())
-                    const url = new URL(`/uploads/${upload.id}`, settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL).href
+                    const bundleManagerUrl = shards.shardFor(upload.id)
+                    const url = new URL(`/uploads/${upload.id}`, bundleManagerUrl).href
+
+                    // Heartbeats are recorded outside of the conversion transaction so that the janitor
+                    // can distinguish a conversion in progress from one whose worker has died
+                    const heartbeats = setInterval(() => {
+                        uploadManager.heartbeat(upload.id, workerId).catch(error => {
+                            logger.warn('Failed to record heartbeat', { uploadId: upload.id, error })
+                        })
+                    }, settings.HEARTBEAT_INTERVAL * 1000)
 
                     try {
-                        await logAndTraceCall(ctx, 'Downloading raw dump from bundle manager', () =>
-                            pipeline(got.stream.get(url), fs.createWriteStream(sourcePath))
+                        const checksum = new ChecksumStream()
+                        progress.report('downloading', 0)
+                        await logAndTraceCall(ctx, 'Downloading raw dump from bundle manager', ctx =>
+                            pipeline(
+                                bundleManagerClient.stream.get(url, {
+                                    headers: { ...tracingHeaders(ctx), ...authorizationHeaders() },
+                                }),
+                                checksum,
+                                fs.createWriteStream(sourcePath)
+                            )
                         )
 
+                        // Do not convert an upload that was corrupted in transit or on disk
+                        const actualChecksum = checksum.digest()
+                        if (upload.checksum && upload.checksum !== actualChecksum) {
+                            throw checksumMismatchError(upload.checksum, actualChecksum)
+                        }
+
                         // Convert the database and populate the cross-dump package data
                         await convertDatabase(
                             entityManager,
+                            dumpManager,
                             dependencyManager,
                             SRC_FRONTEND_INTERNAL,
                             upload,
                             sourcePath,
                             targetPath,
-                            ctx
+                            ctx,
+                            progress.report
                         )
 
-                        // Upload the database where it cna be found by the server
-                        await logAndTraceCall(ctx, 'Uploading converted dump to bundle manager', () =>
-                            pipeline(
-                                fs.createReadStream(targetPath),
-                                got.stream.post(
-                                    new URL(`/dbs/${upload.id}`, settings.PRECISE_CODE_INTEL_BUNDLE_MANAGER_URL).href
-                                )
-                            )
+                        // Upload the database where it cna be found by the server. A previous attempt to
+                        // convert this upload may have already written a database, which is replaced.
+                        const dbUrl = new URL(`/dbs/${upload.id}`, bundleManagerUrl)
+                        dbUrl.searchParams.set('force', 'true')
+                        const { size } = await fs.stat(targetPath)
+
+                        progress.report('uploading', 0)
+                        await logAndTraceCall(ctx, 'Uploading converted dump to bundle manager', ctx =>
+                            bundleManagerGrpcClient
+                                ? bundleManagerGrpcClient.uploadBundle(
+                                      bundleManagerUrl,
+                                      { dumpId: upload.id, force: true, size },
+                                      fs.createReadStream(targetPath),
+                                      ctx
+                                  )
+                                : pipeline(
+                                      fs.createReadStream(targetPath),
+                                      bundleManagerClient.stream.post(dbUrl.href, {
+                                          // Allows the bundle manager to detect a truncated payload
+                                          headers: {
+                                              ...tracingHeaders(ctx),
+                                              ...authorizationHeaders(),
+                                              'Content-Length': String(size),
+                                          },
+                                      })
+                                  )
                         )
 
+                        // The upload is updated within the transaction below, after which recording its
+                        // progress would block until the transaction commits
+                        await progress.flush()
+
                         // Remove overlapping dumps that would cause a unique index error once this upload has
                         // transitioned into the completed state. As this is done in a transaction, we do not
                         // delete the files on disk right away. These files will be cleaned up by a worker in
@@ -136,12 +209,38 @@ async function main(logger: Logger): Promise<void> {
                             ctx,
                         })
 
+                        // Mark older dumps for the same root and indexer as superseded by this dump. These
+                        // dumps will be pruned before any other dump once the disk is under pressure, unless
+                        // they are marked for deletion right away.
+                        const supersededIds = await dumpManager.markSupersededDumps(
+                            upload.repositoryId,
+                            upload.commit,
+                            upload.root,
+                            upload.indexer,
+                            upload.id,
+                            settings.DELETE_SUPERSEDED_DUMPS,
+                            ctx,
+                            entityManager
+                        )
+                        if (supersededIds.length > 0) {
+                            logger.info('Marked superseded dumps', {
+                                uploadId: upload.id,
+                                supersededIds,
+                                deleted: settings.DELETE_SUPERSEDED_DUMPS,
+                            })
+                        }
+
                         logger.info('Converted upload', {
                             repositoryId: upload.repositoryId,
                             commit: upload.commit,
                             root: upload.root,
                         })
                     } finally {
+                        clearInterval(heartbeats)
+
+                        // Do not record progress after a failed upload is marked as errored
+                        await progress.flush()
+
                         // Remove local files
                         await unlinkQuiet(sourcePath)
                         await unlinkQuiet(targetPath)
@@ -153,7 +252,7 @@ async function main(logger: Logger): Promise<void> {
     logger.debug('Polling database for unconverted uploads')
 
     AsyncPolling(async end => {
-        while (await uploadManager.dequeueAndConvert(convert, logger)) {
+        while (await uploadManager.dequeueAndConvert(convert, workerId, logger)) {
             // Immediately poll again if we converted an upload
         }
 
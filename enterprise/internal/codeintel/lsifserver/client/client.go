@@ -12,13 +12,15 @@ import (
 )
 
 var (
-	preciseCodeIntelAPIServerURL = env.Get("PRECISE_CODE_INTEL_API_SERVER_URL", "k8s+http://precise-code-intel:3186", "precise-code-intel-api-server URL (or space separated list of precise-code-intel-api-server URLs)")
+	preciseCodeIntelAPIServerURL     = env.Get("PRECISE_CODE_INTEL_API_SERVER_URL", "k8s+http://precise-code-intel:3186", "precise-code-intel-api-server URL (or space separated list of precise-code-intel-api-server URLs)")
+	preciseCodeIntelInternalAPIToken = env.Get("PRECISE_CODE_INTEL_INTERNAL_API_TOKEN", "", "shared secret sent as a bearer token to the precise-code-intel-api-server (authentication is disabled if empty)")
 
 	preciseCodeIntelAPIServerURLsOnce sync.Once
 	preciseCodeIntelAPIServerURLs     *endpoint.Map
 
 	DefaultClient = &Client{
 		endpoint: LSIFURLs(),
+		token:    preciseCodeIntelInternalAPIToken,
 		HTTPClient: &http.Client{
 			// ot.Transport will propagate opentracing spans
 			Transport: &ot.Transport{},
@@ -26,8 +28,12 @@ var (
 	}
 )
 
+// ChecksumHeader is the header containing the hex-encoded SHA-256 digest of an upload.
+const ChecksumHeader = "X-Checksum-Sha256"
+
 type Client struct {
 	endpoint   *endpoint.Map
+	token      string
 	HTTPClient *http.Client
 }
 
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/pkg/errors"
@@ -8,7 +9,26 @@ import (
 
 type lsifError struct {
 	StatusCode int
+	Code       string
 	Message    string
+
+	// RetryAfter is the value of the Retry-After header of the response, if any.
+	RetryAfter string
+}
+
+// newLSIFError creates an error from the status code and body of an error response.
+// The body is expected to be a JSON object of the form {"error": "...", "code": "..."};
+// any other body is used as the message verbatim.
+func newLSIFError(statusCode int, body []byte) *lsifError {
+	var payload struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error == "" {
+		return &lsifError{StatusCode: statusCode, Message: string(body)}
+	}
+
+	return &lsifError{StatusCode: statusCode, Code: payload.Code, Message: payload.Error}
 }
 
 func (e *lsifError) Error() string {
@@ -22,3 +42,14 @@ func IsNotFound(err error) bool {
 
 	return false
 }
+
+// IsTooManyRequests returns true if the request was rejected because a limit on queued
+// uploads was reached. The returned string is the time in seconds the client should wait
+// before retrying, or empty if the server did not specify one.
+func IsTooManyRequests(err error) (string, bool) {
+	if e, ok := errors.Cause(err).(*lsifError); ok && e.StatusCode == http.StatusTooManyRequests {
+		return e.RetryAfter, true
+	}
+
+	return "", false
+}
@@ -1,22 +1,25 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
 	"github.com/sourcegraph/sourcegraph/internal/api"
-	"github.com/sourcegraph/sourcegraph/internal/lsif"
+	codeinteltypes "github.com/sourcegraph/sourcegraph/internal/codeintel/types"
 )
 
 func (c *Client) Exists(ctx context.Context, args *struct {
 	RepoID api.RepoID
 	Commit string
 	Path   string
-}) ([]*lsif.LSIFUpload, error) {
+}) ([]*codeinteltypes.Upload, error) {
 	query := queryValues{}
 	query.SetInt("repositoryId", int64(args.RepoID))
 	query.Set("commit", args.Commit)
@@ -29,7 +32,7 @@ func (c *Client) Exists(ctx context.Context, args *struct {
 	}
 
 	payload := struct {
-		Uploads []*lsif.LSIFUpload `json:"uploads"`
+		Uploads []*codeinteltypes.Upload `json:"uploads"`
 	}{}
 
 	_, err := c.do(ctx, req, &payload)
@@ -45,6 +48,8 @@ func (c *Client) Upload(ctx context.Context, args *struct {
 	Commit      graphqlbackend.GitObjectID
 	Root        string
 	IndexerName string
+	OnDuplicate string
+	Checksum    string
 	Body        io.ReadCloser
 }) (int64, bool, error) {
 	query := queryValues{}
@@ -52,12 +57,21 @@ func (c *Client) Upload(ctx context.Context, args *struct {
 	query.Set("commit", string(args.Commit))
 	query.Set("root", args.Root)
 	query.Set("indexerName", args.IndexerName)
+	if args.OnDuplicate != "" {
+		query.Set("onDuplicate", args.OnDuplicate)
+	}
+
+	header := http.Header{}
+	if args.Checksum != "" {
+		header.Set(ChecksumHeader, args.Checksum)
+	}
 
 	req := &lsifRequest{
 		path:       "/upload",
 		method:     "POST",
 		query:      query,
 		body:       args.Body,
+		header:     header,
 		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
 	}
 
@@ -81,17 +95,18 @@ func (c *Client) Definitions(ctx context.Context, args *struct {
 	Line      int32
 	Character int32
 	UploadID  int64
-}) ([]*lsif.LSIFLocation, string, error) {
+}) ([]*codeinteltypes.Location, string, error) {
 	return c.locationQuery(ctx, &struct {
-		Operation string
-		RepoID    api.RepoID
-		Commit    graphqlbackend.GitObjectID
-		Path      string
-		Line      int32
-		Character int32
-		UploadID  int64
-		Limit     *int32
-		Cursor    *string
+		Operation            string
+		RepoID               api.RepoID
+		Commit               graphqlbackend.GitObjectID
+		Path                 string
+		Line                 int32
+		Character            int32
+		UploadID             int64
+		Limit                *int32
+		Cursor               *string
+		AllowedRepositoryIDs []api.RepoID
 	}{
 		Operation: "definitions",
 		RepoID:    args.RepoID,
@@ -103,50 +118,87 @@ func (c *Client) Definitions(ctx context.Context, args *struct {
 	})
 }
 
-func (c *Client) References(ctx context.Context, args *struct {
+func (c *Client) Implementations(ctx context.Context, args *struct {
 	RepoID    api.RepoID
 	Commit    graphqlbackend.GitObjectID
 	Path      string
 	Line      int32
 	Character int32
 	UploadID  int64
-	Limit     *int32
-	Cursor    *string
-}) ([]*lsif.LSIFLocation, string, error) {
+}) ([]*codeinteltypes.Location, string, error) {
 	return c.locationQuery(ctx, &struct {
-		Operation string
-		RepoID    api.RepoID
-		Commit    graphqlbackend.GitObjectID
-		Path      string
-		Line      int32
-		Character int32
-		UploadID  int64
-		Limit     *int32
-		Cursor    *string
+		Operation            string
+		RepoID               api.RepoID
+		Commit               graphqlbackend.GitObjectID
+		Path                 string
+		Line                 int32
+		Character            int32
+		UploadID             int64
+		Limit                *int32
+		Cursor               *string
+		AllowedRepositoryIDs []api.RepoID
 	}{
-		Operation: "references",
+		Operation: "implementations",
 		RepoID:    args.RepoID,
 		Commit:    args.Commit,
 		Path:      args.Path,
 		Line:      args.Line,
 		Character: args.Character,
 		UploadID:  args.UploadID,
-		Limit:     args.Limit,
-		Cursor:    args.Cursor,
+	})
+}
+
+// References returns a page of references of the symbol at the given position. If
+// AllowedRepositoryIDs is non-nil, references in repositories other than the given
+// repository and the allowed repositories are omitted.
+func (c *Client) References(ctx context.Context, args *struct {
+	RepoID               api.RepoID
+	Commit               graphqlbackend.GitObjectID
+	Path                 string
+	Line                 int32
+	Character            int32
+	UploadID             int64
+	Limit                *int32
+	Cursor               *string
+	AllowedRepositoryIDs []api.RepoID
+}) ([]*codeinteltypes.Location, string, error) {
+	return c.locationQuery(ctx, &struct {
+		Operation            string
+		RepoID               api.RepoID
+		Commit               graphqlbackend.GitObjectID
+		Path                 string
+		Line                 int32
+		Character            int32
+		UploadID             int64
+		Limit                *int32
+		Cursor               *string
+		AllowedRepositoryIDs []api.RepoID
+	}{
+		Operation:            "references",
+		RepoID:               args.RepoID,
+		Commit:               args.Commit,
+		Path:                 args.Path,
+		Line:                 args.Line,
+		Character:            args.Character,
+		UploadID:             args.UploadID,
+		Limit:                args.Limit,
+		Cursor:               args.Cursor,
+		AllowedRepositoryIDs: args.AllowedRepositoryIDs,
 	})
 }
 
 func (c *Client) locationQuery(ctx context.Context, args *struct {
-	Operation string
-	RepoID    api.RepoID
-	Commit    graphqlbackend.GitObjectID
-	Path      string
-	Line      int32
-	Character int32
-	UploadID  int64
-	Limit     *int32
-	Cursor    *string
-}) ([]*lsif.LSIFLocation, string, error) {
+	Operation            string
+	RepoID               api.RepoID
+	Commit               graphqlbackend.GitObjectID
+	Path                 string
+	Line                 int32
+	Character            int32
+	UploadID             int64
+	Limit                *int32
+	Cursor               *string
+	AllowedRepositoryIDs []api.RepoID
+}) ([]*codeinteltypes.Location, string, error) {
 	query := queryValues{}
 	query.SetInt("repositoryId", int64(args.RepoID))
 	query.Set("commit", string(args.Commit))
@@ -163,8 +215,21 @@ func (c *Client) locationQuery(ctx context.Context, args *struct {
 		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
 	}
 
+	// The allowed repositories are sent in the body, as there may be too many of them to
+	// fit in a query string. The body is sent again with each page, as the next page URL
+	// does not carry them.
+	if args.AllowedRepositoryIDs != nil {
+		body, err := json.Marshal(map[string]interface{}{"allowedRepositoryIds": args.AllowedRepositoryIDs})
+		if err != nil {
+			return nil, "", err
+		}
+
+		req.method = "POST"
+		req.body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
 	payload := struct {
-		Locations []*lsif.LSIFLocation
+		Locations []*codeinteltypes.Location
 	}{}
 
 	meta, err := c.do(ctx, req, &payload)
@@ -182,7 +247,7 @@ func (c *Client) Hover(ctx context.Context, args *struct {
 	Line      int32
 	Character int32
 	UploadID  int64
-}) (string, lsp.Range, error) {
+}) (*codeinteltypes.Hover, error) {
 	query := queryValues{}
 	query.SetInt("repositoryId", int64(args.RepoID))
 	query.Set("commit", string(args.Commit))
@@ -197,15 +262,177 @@ func (c *Client) Hover(ctx context.Context, args *struct {
 		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
 	}
 
+	// Hover is nil if there is no hover text at the given position
+	payload := struct {
+		Hover *codeinteltypes.Hover `json:"hover"`
+	}{}
+
+	_, err := c.do(ctx, req, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload.Hover, nil
+}
+
+func (c *Client) Hovers(ctx context.Context, args *struct {
+	RepoID    api.RepoID
+	Commit    graphqlbackend.GitObjectID
+	Path      string
+	Positions []lsp.Position
+	UploadID  int64
+}) ([]*codeinteltypes.Hover, error) {
+	query := queryValues{}
+	query.SetInt("repositoryId", int64(args.RepoID))
+	query.Set("commit", string(args.Commit))
+	query.Set("path", args.Path)
+	query.SetInt("uploadId", int64(args.UploadID))
+
+	body, err := json.Marshal(map[string]interface{}{"positions": args.Positions})
+	if err != nil {
+		return nil, err
+	}
+
+	req := &lsifRequest{
+		method:     "POST",
+		path:       "/hovers",
+		query:      query,
+		body:       ioutil.NopCloser(bytes.NewReader(body)),
+		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
+	}
+
+	payload := struct {
+		Hovers []*codeinteltypes.Hover `json:"hovers"`
+	}{}
+
+	_, err = c.do(ctx, req, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload.Hovers, nil
+}
+
+// PositionResult is the hover text, the definitions, and the first page of references of
+// the symbol at a position.
+type PositionResult struct {
+	// Hover is nil if there is no hover text at the given position
+	Hover            *codeinteltypes.Hover
+	Definitions      []*codeinteltypes.Location
+	References       []*codeinteltypes.Location
+	ReferencesCursor string
+}
+
+// Position returns the hover text, the definitions, and the first page of references of the
+// symbol at the given position in a single request. If AllowedRepositoryIDs is non-nil,
+// results in repositories other than the given repository and the allowed repositories are
+// omitted. The next page of references is requested from References with ReferencesCursor.
+func (c *Client) Position(ctx context.Context, args *struct {
+	RepoID               api.RepoID
+	Commit               graphqlbackend.GitObjectID
+	Path                 string
+	Line                 int32
+	Character            int32
+	UploadID             int64
+	Limit                *int32
+	AllowedRepositoryIDs []api.RepoID
+}) (*PositionResult, error) {
+	query := queryValues{}
+	query.SetInt("repositoryId", int64(args.RepoID))
+	query.Set("commit", string(args.Commit))
+	query.Set("path", args.Path)
+	query.SetInt("line", int64(args.Line))
+	query.SetInt("character", int64(args.Character))
+	query.SetInt("uploadId", int64(args.UploadID))
+	query.SetOptionalInt32("limit", args.Limit)
+
+	req := &lsifRequest{
+		path:       "/position",
+		query:      query,
+		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
+	}
+
+	// The allowed repositories are sent in the body, as there may be too many of them to
+	// fit in a query string.
+	if args.AllowedRepositoryIDs != nil {
+		body, err := json.Marshal(map[string]interface{}{"allowedRepositoryIds": args.AllowedRepositoryIDs})
+		if err != nil {
+			return nil, err
+		}
+
+		req.method = "POST"
+		req.body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	payload := &PositionResult{}
+	if _, err := c.do(ctx, req, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func (c *Client) DocumentSymbols(ctx context.Context, args *struct {
+	RepoID   api.RepoID
+	Commit   graphqlbackend.GitObjectID
+	Path     string
+	UploadID int64
+}) ([]*codeinteltypes.Symbol, error) {
+	query := queryValues{}
+	query.SetInt("repositoryId", int64(args.RepoID))
+	query.Set("commit", string(args.Commit))
+	query.Set("path", args.Path)
+	query.SetInt("uploadId", int64(args.UploadID))
+
+	req := &lsifRequest{
+		path:       "/symbols",
+		query:      query,
+		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
+	}
+
 	payload := struct {
-		Text  string    `json:"text"`
-		Range lsp.Range `json:"range"`
+		Symbols []*codeinteltypes.Symbol `json:"symbols"`
 	}{}
 
 	_, err := c.do(ctx, req, &payload)
 	if err != nil {
-		return "", lsp.Range{}, err
+		return nil, err
+	}
+
+	return payload.Symbols, nil
+}
+
+func (c *Client) Diagnostics(ctx context.Context, args *struct {
+	RepoID api.RepoID
+	Commit graphqlbackend.GitObjectID
+	Path   string
+	Limit  *int32
+	Cursor *string
+}) ([]*codeinteltypes.Diagnostic, string, *int, error) {
+	query := queryValues{}
+	query.SetInt("repositoryId", int64(args.RepoID))
+	query.Set("commit", string(args.Commit))
+	query.Set("path", args.Path)
+	query.SetOptionalInt32("limit", args.Limit)
+
+	req := &lsifRequest{
+		path:       "/diagnostics",
+		cursor:     args.Cursor,
+		query:      query,
+		routingKey: fmt.Sprintf("%d:%s", args.RepoID, args.Commit),
+	}
+
+	payload := struct {
+		Diagnostics []*codeinteltypes.Diagnostic `json:"diagnostics"`
+		TotalCount  *int                         `json:"totalCount"`
+	}{
+		Diagnostics: []*codeinteltypes.Diagnostic{},
+	}
+
+	meta, err := c.do(ctx, req, &payload)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	return payload.Text, payload.Range, nil
+	return payload.Diagnostics, meta.nextURL, payload.TotalCount, nil
 }
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/endpoint"
+)
+
+func TestPosition(t *testing.T) {
+	var method, allowedRepositoryIDs, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		method, allowedRepositoryIDs, body = r.Method, r.URL.Query().Get("allowedRepositoryIds"), string(raw)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"hover": {"text": "doc", "range": {"start": {"line": 1, "character": 2}, "end": {"line": 1, "character": 5}}},
+			"definitions": [{"repositoryId": 42, "commit": "deadbeef", "path": "foo.go"}],
+			"references": [],
+			"referencesCursor": "next"
+		}`))
+	}))
+	defer ts.Close()
+
+	c := &Client{endpoint: endpoint.Static(ts.URL), HTTPClient: http.DefaultClient}
+
+	type args = struct {
+		RepoID               api.RepoID
+		Commit               graphqlbackend.GitObjectID
+		Path                 string
+		Line                 int32
+		Character            int32
+		UploadID             int64
+		Limit                *int32
+		AllowedRepositoryIDs []api.RepoID
+	}
+
+	result, err := c.Position(context.Background(), &args{RepoID: 42, Commit: "deadbeef", Path: "foo.go", AllowedRepositoryIDs: []api.RepoID{50, 51}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if method != "POST" || allowedRepositoryIDs != "" || body != `{"allowedRepositoryIds":[50,51]}` {
+		t.Errorf("expected allowed repositories in the body of a POST request. method=%s query=%q body=%q", method, allowedRepositoryIDs, body)
+	}
+	if result.Hover == nil || result.Hover.Text != "doc" || len(result.Definitions) != 1 || result.Definitions[0].Path != "foo.go" || result.ReferencesCursor != "next" {
+		t.Errorf("unexpected result. have=%+v", result)
+	}
+
+	if _, err := c.Position(context.Background(), &args{RepoID: 42, Commit: "deadbeef", Path: "foo.go"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if method != "GET" || body != "" {
+		t.Errorf("expected a GET request without body when all repositories are allowed. method=%s body=%q", method, body)
+	}
+}
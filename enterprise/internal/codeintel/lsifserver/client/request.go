@@ -13,6 +13,7 @@ import (
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
 	"github.com/sourcegraph/sourcegraph/internal/linkheader"
 	"github.com/sourcegraph/sourcegraph/internal/trace/ot"
 )
@@ -23,6 +24,7 @@ type lsifRequest struct {
 	cursor *string
 	query  queryValues
 	body   io.ReadCloser
+	header http.Header
 
 	// (Optional) used in routing to select the "hot" precise-code-intel-api-server that
 	// was used in recent requests for similar data. Requests that are likely to open the
@@ -69,6 +71,16 @@ func (c *Client) do(ctx context.Context, lsifRequest *lsifRequest, payload inter
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if a := actor.FromContext(ctx); a.IsAuthenticated() {
+		// Attributes uploads, deletions, and retries to the user in the upload audit log
+		req.Header.Set("X-Sourcegraph-Actor", "user:"+a.UIDString())
+	}
+	for key, values := range lsifRequest.header {
+		req.Header[key] = values
+	}
 	req = req.WithContext(ctx)
 
 	req, ht := nethttp.TraceRequest(
@@ -98,10 +110,9 @@ func (c *Client) do(ctx context.Context, lsifRequest *lsifRequest, payload inter
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, errors.WithStack(&lsifError{
-			StatusCode: resp.StatusCode,
-			Message:    string(content),
-		})
+		lsifErr := newLSIFError(resp.StatusCode, content)
+		lsifErr.RetryAfter = resp.Header.Get("Retry-After")
+		return nil, errors.WithStack(lsifErr)
 	}
 
 	if payload != nil {
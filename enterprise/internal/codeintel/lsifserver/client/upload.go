@@ -6,7 +6,7 @@ import (
 	"strings"
 
 	"github.com/sourcegraph/sourcegraph/internal/api"
-	"github.com/sourcegraph/sourcegraph/internal/lsif"
+	codeinteltypes "github.com/sourcegraph/sourcegraph/internal/codeintel/types"
 )
 
 func (c *Client) GetUploads(ctx context.Context, args *struct {
@@ -16,7 +16,7 @@ func (c *Client) GetUploads(ctx context.Context, args *struct {
 	IsLatestForRepo *bool
 	Limit           *int32
 	Cursor          *string
-}) ([]*lsif.LSIFUpload, string, *int, error) {
+}) ([]*codeinteltypes.Upload, string, *int, error) {
 	query := queryValues{}
 	query.SetOptionalString("query", args.Query)
 	query.SetOptionalBool("visibleAtTip", args.IsLatestForRepo)
@@ -33,10 +33,10 @@ func (c *Client) GetUploads(ctx context.Context, args *struct {
 	}
 
 	payload := struct {
-		Uploads    []*lsif.LSIFUpload `json:"uploads"`
-		TotalCount *int               `json:"totalCount"`
+		Uploads    []*codeinteltypes.Upload `json:"uploads"`
+		TotalCount *int                     `json:"totalCount"`
 	}{
-		Uploads: []*lsif.LSIFUpload{},
+		Uploads: []*codeinteltypes.Upload{},
 	}
 
 	meta, err := c.do(ctx, req, &payload)
@@ -49,12 +49,12 @@ func (c *Client) GetUploads(ctx context.Context, args *struct {
 
 func (c *Client) GetUpload(ctx context.Context, args *struct {
 	UploadID int64
-}) (*lsif.LSIFUpload, error) {
+}) (*codeinteltypes.Upload, error) {
 	req := &lsifRequest{
 		path: fmt.Sprintf("/uploads/%d", args.UploadID),
 	}
 
-	payload := &lsif.LSIFUpload{}
+	payload := &codeinteltypes.Upload{}
 	_, err := c.do(ctx, req, &payload)
 	return payload, err
 }
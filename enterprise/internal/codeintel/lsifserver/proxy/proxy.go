@@ -35,6 +35,7 @@ func uploadProxyHandler() func(http.ResponseWriter, *http.Request) {
 		commit := q.Get("commit")
 		root := q.Get("root")
 		indexerName := q.Get("indexerName")
+		onDuplicate := q.Get("onDuplicate")
 		ctx := r.Context()
 
 		repo, ok := ensureRepoAndCommitExist(ctx, w, repoName, commit)
@@ -58,15 +59,26 @@ func uploadProxyHandler() func(http.ResponseWriter, *http.Request) {
 			Commit      graphqlbackend.GitObjectID
 			Root        string
 			IndexerName string
+			OnDuplicate string
+			Checksum    string
 			Body        io.ReadCloser
 		}{
 			RepoID:      repo.ID,
 			Commit:      graphqlbackend.GitObjectID(commit),
 			Root:        root,
 			IndexerName: indexerName,
+			OnDuplicate: onDuplicate,
+			Checksum:    r.Header.Get(client.ChecksumHeader),
 			Body:        r.Body,
 		})
 
+		if retryAfter, ok := client.IsTooManyRequests(err); ok {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
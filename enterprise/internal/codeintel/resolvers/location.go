@@ -8,13 +8,13 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/graphqlutil"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/api"
-	"github.com/sourcegraph/sourcegraph/internal/lsif"
+	codeinteltypes "github.com/sourcegraph/sourcegraph/internal/codeintel/types"
 )
 
 type locationConnectionResolver struct {
 	repo      *types.Repo
 	commit    graphqlbackend.GitObjectID
-	locations []*lsif.LSIFLocation
+	locations []*codeinteltypes.Location
 	endCursor string
 }
 
@@ -63,7 +63,7 @@ func (r *locationConnectionResolver) PageInfo(ctx context.Context) (*graphqlutil
 //
 // A non-nil error means the connection resolver was unable to load the diff between
 // the requested commit and location's commit.
-func (r *locationConnectionResolver) adjustLocation(ctx context.Context, location *lsif.LSIFLocation) (string, lsp.Range, error) {
+func (r *locationConnectionResolver) adjustLocation(ctx context.Context, location *codeinteltypes.Location) (string, lsp.Range, error) {
 	if location.RepositoryID != r.repo.ID {
 		return location.Commit, location.Range, nil
 	}
@@ -6,10 +6,14 @@ import (
 	"encoding/json"
 
 	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/globals"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
 	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/lsifserver/client"
 	"github.com/sourcegraph/sourcegraph/internal/api"
-	"github.com/sourcegraph/sourcegraph/internal/lsif"
+	codeinteltypes "github.com/sourcegraph/sourcegraph/internal/codeintel/types"
 )
 
 type lsifQueryResolver struct {
@@ -18,7 +22,7 @@ type lsifQueryResolver struct {
 	commit graphqlbackend.GitObjectID
 	path   string
 	// uploads are ordered by their commit distance from the target commit
-	uploads []*lsif.LSIFUpload
+	uploads []*codeinteltypes.Upload
 }
 
 var _ graphqlbackend.LSIFQueryResolver = &lsifQueryResolver{}
@@ -77,12 +81,19 @@ func (r *lsifQueryResolver) References(ctx context.Context, args *graphqlbackend
 		return nil, err
 	}
 
+	// 🚨 SECURITY: References in other repositories are only returned if the
+	// current user may read them
+	allowedRepositoryIDs, err := allowedRepositoryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// We need to maintain a symmetric map for the next page
 	// of results that we can encode into the endCursor of
 	// this request.
 	newCursors := map[int64]string{}
 
-	var allLocations []*lsif.LSIFLocation
+	var allLocations []*codeinteltypes.Location
 	for _, upload := range r.uploads {
 		adjustedPosition, ok, err := r.adjustPosition(ctx, upload.Commit, args.Line, args.Character)
 		if err != nil {
@@ -93,21 +104,23 @@ func (r *lsifQueryResolver) References(ctx context.Context, args *graphqlbackend
 		}
 
 		opts := &struct {
-			RepoID    api.RepoID
-			Commit    graphqlbackend.GitObjectID
-			Path      string
-			Line      int32
-			Character int32
-			UploadID  int64
-			Limit     *int32
-			Cursor    *string
+			RepoID               api.RepoID
+			Commit               graphqlbackend.GitObjectID
+			Path                 string
+			Line                 int32
+			Character            int32
+			UploadID             int64
+			Limit                *int32
+			Cursor               *string
+			AllowedRepositoryIDs []api.RepoID
 		}{
-			RepoID:    r.repositoryResolver.Type().ID,
-			Commit:    r.commit,
-			Path:      r.path,
-			Line:      int32(adjustedPosition.Line),
-			Character: int32(adjustedPosition.Character),
-			UploadID:  upload.ID,
+			RepoID:               r.repositoryResolver.Type().ID,
+			Commit:               r.commit,
+			Path:                 r.path,
+			Line:                 int32(adjustedPosition.Line),
+			Character:            int32(adjustedPosition.Character),
+			UploadID:             upload.ID,
+			AllowedRepositoryIDs: allowedRepositoryIDs,
 		}
 		if args.First != nil {
 			opts.Limit = args.First
@@ -155,7 +168,7 @@ func (r *lsifQueryResolver) Hover(ctx context.Context, args *graphqlbackend.LSIF
 			continue
 		}
 
-		text, lspRange, err := client.DefaultClient.Hover(ctx, &struct {
+		hover, err := client.DefaultClient.Hover(ctx, &struct {
 			RepoID    api.RepoID
 			Commit    graphqlbackend.GitObjectID
 			Path      string
@@ -174,8 +187,8 @@ func (r *lsifQueryResolver) Hover(ctx context.Context, args *graphqlbackend.LSIF
 			return nil, err
 		}
 
-		if text != "" {
-			adjustedRange, ok, err := r.adjustRange(ctx, upload.Commit, lspRange)
+		if hover != nil {
+			adjustedRange, ok, err := r.adjustRange(ctx, upload.Commit, hover.Range)
 			if err != nil {
 				return nil, err
 			}
@@ -188,7 +201,7 @@ func (r *lsifQueryResolver) Hover(ctx context.Context, args *graphqlbackend.LSIF
 				continue
 			}
 
-			return &hoverResolver{text: text, lspRange: adjustedRange}, nil
+			return &hoverResolver{text: hover.Text, lspRange: adjustedRange}, nil
 		}
 	}
 
@@ -252,3 +265,30 @@ func makeCursor(cursors map[int64]string) (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(encoded), nil
 }
+
+// allowedRepositoryIDs returns the identifiers of the repositories that the current user
+// may read. Returns nil if the user may read every repository, in which case references
+// do not need to be filtered.
+func allowedRepositoryIDs(ctx context.Context) ([]api.RepoID, error) {
+	if backend.CheckCurrentUserIsSiteAdmin(ctx) == nil {
+		return nil, nil
+	}
+
+	// Permissions are not enforced by authz providers and everyone can see all repositories
+	if authzAllowByDefault, authzProviders := authz.GetProviders(); authzAllowByDefault && len(authzProviders) == 0 && !globals.PermissionsUserMapping().Enabled {
+		return nil, nil
+	}
+
+	// The listed repositories are filtered by the permissions of the current user
+	repos, err := db.Repos.List(ctx, db.ReposListOptions{OnlyRepoIDs: true})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]api.RepoID, 0, len(repos))
+	for _, repo := range repos {
+		ids = append(ids, repo.ID)
+	}
+
+	return ids, nil
+}
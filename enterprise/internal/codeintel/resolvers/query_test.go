@@ -0,0 +1,48 @@
+package resolvers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+)
+
+func TestAllowedRepositoryIDs(t *testing.T) {
+	db.Mocks.Repos.List = func(ctx context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{{ID: 50}, {ID: 51}}, nil
+	}
+	defer func() { db.Mocks.Repos.List = nil }()
+
+	authz.SetProviders(false, nil)
+	defer authz.SetProviders(true, nil)
+
+	ids, err := allowedRepositoryIDs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error listing allowed repositories: %s", err)
+	}
+	if expected := []api.RepoID{50, 51}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("unexpected allowed repositories. want=%v have=%v", expected, ids)
+	}
+}
+
+func TestAllowedRepositoryIDsWithoutPermissions(t *testing.T) {
+	db.Mocks.Repos.List = func(ctx context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		t.Fatalf("unexpected call to list repositories")
+		return nil, nil
+	}
+	defer func() { db.Mocks.Repos.List = nil }()
+
+	authz.SetProviders(true, nil)
+
+	ids, err := allowedRepositoryIDs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error listing allowed repositories: %s", err)
+	}
+	if ids != nil {
+		t.Errorf("unexpected allowed repositories. want=%v have=%v", nil, ids)
+	}
+}
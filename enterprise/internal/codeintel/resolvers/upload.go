@@ -13,12 +13,12 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/graphqlutil"
 	"github.com/sourcegraph/sourcegraph/enterprise/internal/codeintel/lsifserver/client"
 	"github.com/sourcegraph/sourcegraph/internal/api"
-	"github.com/sourcegraph/sourcegraph/internal/lsif"
+	codeinteltypes "github.com/sourcegraph/sourcegraph/internal/codeintel/types"
 )
 
 type lsifUploadResolver struct {
 	repositoryResolver *graphqlbackend.RepositoryResolver
-	lsifUpload         *lsif.LSIFUpload
+	lsifUpload         *codeinteltypes.Upload
 }
 
 var _ graphqlbackend.LSIFUploadResolver = &lsifUploadResolver{}
@@ -76,7 +76,7 @@ func (r *lsifUploadResolver) PlaceInQueue() *int32 {
 }
 
 type lsifUploadFailureReasonResolver struct {
-	lsifUpload *lsif.LSIFUpload
+	lsifUpload *codeinteltypes.Upload
 }
 
 var _ graphqlbackend.LSIFUploadFailureReasonResolver = &lsifUploadFailureReasonResolver{}
@@ -111,7 +111,7 @@ type lsifUploadConnectionResolver struct {
 
 	// cache results because they are used by multiple fields
 	once               sync.Once
-	uploads            []*lsif.LSIFUpload
+	uploads            []*codeinteltypes.Upload
 	repositoryResolver *graphqlbackend.RepositoryResolver
 	totalCount         *int
 	nextURL            string
@@ -159,7 +159,7 @@ func (r *lsifUploadConnectionResolver) PageInfo(ctx context.Context) (*graphqlut
 	return graphqlutil.HasNextPage(false), nil
 }
 
-func (r *lsifUploadConnectionResolver) compute(ctx context.Context) ([]*lsif.LSIFUpload, *graphqlbackend.RepositoryResolver, *int, string, error) {
+func (r *lsifUploadConnectionResolver) compute(ctx context.Context) ([]*codeinteltypes.Upload, *graphqlbackend.RepositoryResolver, *int, string, error) {
 	r.once.Do(func() {
 		r.repositoryResolver, r.err = graphqlbackend.RepositoryByID(ctx, r.opt.RepositoryID)
 		if r.err != nil {
@@ -0,0 +1,143 @@
+// Package types contains the canonical forms of the code intelligence data exchanged
+// between the precise-code-intel services and their clients. The JSON encoding of these
+// types matches the encoding used by the precise-code-intel-api-server and the
+// precise-code-intel-bundle-manager.
+package types
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+)
+
+// ID is the identifier of a vertex or edge in an LSIF dump. Identifiers may be
+// either JSON numbers or JSON strings. The raw JSON encoding of the identifier is
+// stored so that it can be written back out with its original type.
+type ID string
+
+// UnmarshalJSON stores the raw JSON encoding of the identifier.
+func (id *ID) UnmarshalJSON(raw []byte) error {
+	*id = ID(raw)
+	return nil
+}
+
+// MarshalJSON writes the identifier with its original type.
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id == "" {
+		return []byte("null"), nil
+	}
+	return []byte(id), nil
+}
+
+// String returns the value of the identifier as it would be formatted by a
+// JavaScript template string. String identifiers are unquoted and numeric
+// identifiers are returned as-is.
+func (id ID) String() string {
+	if s, err := strconv.Unquote(string(id)); err == nil {
+		return s
+	}
+	return string(id)
+}
+
+// Position is a zero-indexed line and character offset in a document. Positions are
+// encoded as LSP positions, so the LSP type is used directly.
+type Position = lsp.Position
+
+// Range is a pair of start and end positions. Ranges are encoded as LSP ranges, so
+// the LSP type is used directly.
+type Range = lsp.Range
+
+// Location is a range within a document of a repository at a particular commit.
+type Location struct {
+	RepositoryID api.RepoID `json:"repositoryId"`
+	Commit       string     `json:"commit"`
+	Path         string     `json:"path"`
+	Range        Range      `json:"range"`
+}
+
+// MonikerData holds the data of a moniker vertex.
+type MonikerData struct {
+	Kind                 string `json:"kind"`
+	Scheme               string `json:"scheme"`
+	Identifier           string `json:"identifier"`
+	PackageInformationID ID     `json:"packageInformationId,omitempty"`
+}
+
+// PackageInformationData holds the data of a package information vertex.
+type PackageInformationData struct {
+	Name    string  `json:"name"`
+	Version *string `json:"version"`
+}
+
+// Dump is an upload that has been successfully converted into a bundle.
+type Dump struct {
+	ID           int64      `json:"id"`
+	RepositoryID api.RepoID `json:"repositoryId"`
+	Commit       string     `json:"commit"`
+	Root         string     `json:"root"`
+	Indexer      string     `json:"indexer"`
+	VisibleAtTip bool       `json:"visibleAtTip"`
+	UploadedAt   time.Time  `json:"uploadedAt"`
+	ProcessedAt  time.Time  `json:"processedAt"`
+}
+
+// Upload is an LSIF upload as reported by the precise-code-intel-api-server.
+type Upload struct {
+	ID                int64      `json:"id"`
+	RepositoryID      api.RepoID `json:"repositoryId"`
+	Commit            string     `json:"commit"`
+	Root              string     `json:"root"`
+	Indexer           string     `json:"indexer"`
+	Filename          string     `json:"filename"`
+	State             string     `json:"state"`
+	UploadedAt        time.Time  `json:"uploadedAt"`
+	StartedAt         *time.Time `json:"startedAt"`
+	FinishedAt        *time.Time `json:"finishedAt"`
+	FailureSummary    *string    `json:"failureSummary"`
+	FailureStacktrace *string    `json:"failureStacktrace"`
+	VisibleAtTip      bool       `json:"visibleAtTip"`
+	Checksum          *string    `json:"checksum"`
+	Attempts          int32      `json:"attempts"`
+	LastRetriedAt     *time.Time `json:"lastRetriedAt"`
+	LastAccessedAt    *time.Time `json:"lastAccessedAt"`
+	LSIFVersion       *string    `json:"lsifVersion"`
+	PositionEncoding  *string    `json:"positionEncoding"`
+	ProjectRoot       *string    `json:"projectRoot"`
+	Format            string     `json:"format"`
+	Progress          int32      `json:"progress"`
+	Stage             *string    `json:"stage"`
+	WorkerID          *string    `json:"workerId"`
+	LastHeartbeatAt   *time.Time `json:"lastHeartbeatAt"`
+	PlaceInQueue      *int32     `json:"placeInQueue"`
+	Distance          *int32     `json:"distance"`
+}
+
+// Hover is the hover text of a range within a document.
+type Hover struct {
+	Text  string `json:"text"`
+	Range Range  `json:"range"`
+}
+
+// Diagnostic is a diagnostic reported by an indexer for a range within a document of a
+// repository at a particular commit.
+type Diagnostic struct {
+	RepositoryID api.RepoID `json:"repositoryId"`
+	Commit       string     `json:"commit"`
+	Path         string     `json:"path"`
+	Range        Range      `json:"range"`
+	Severity     *int32     `json:"severity"`
+	Code         *string    `json:"code"`
+	Message      string     `json:"message"`
+	Source       *string    `json:"source"`
+	UploadID     int64      `json:"uploadId"`
+}
+
+// Symbol is a symbol defined within a document.
+type Symbol struct {
+	Name      string  `json:"name"`
+	Kind      *int32  `json:"kind"`
+	Range     Range   `json:"range"`
+	Container *string `json:"container"`
+}
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	version := "1.2.3"
+	kind := int32(12)
+	uploadedAt := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	rng := Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 3, Character: 4}}
+
+	testCases := []struct {
+		value    interface{}
+		decoded  interface{}
+		expected string
+	}{
+		{
+			value:    rng,
+			decoded:  &Range{},
+			expected: `{"start":{"line":1,"character":2},"end":{"line":3,"character":4}}`,
+		},
+		{
+			value:    Location{RepositoryID: 50, Commit: "deadbeef", Path: "foo.go", Range: rng},
+			decoded:  &Location{},
+			expected: `{"repositoryId":50,"commit":"deadbeef","path":"foo.go","range":{"start":{"line":1,"character":2},"end":{"line":3,"character":4}}}`,
+		},
+		{
+			value:    MonikerData{Kind: "import", Scheme: "gomod", Identifier: "pkg:Foo", PackageInformationID: `"p1"`},
+			decoded:  &MonikerData{},
+			expected: `{"kind":"import","scheme":"gomod","identifier":"pkg:Foo","packageInformationId":"p1"}`,
+		},
+		{
+			value:    MonikerData{Kind: "export", Scheme: "gomod", Identifier: "pkg:Bar", PackageInformationID: `42`},
+			decoded:  &MonikerData{},
+			expected: `{"kind":"export","scheme":"gomod","identifier":"pkg:Bar","packageInformationId":42}`,
+		},
+		{
+			value:    MonikerData{Kind: "local", Scheme: "gomod", Identifier: "pkg:Baz"},
+			decoded:  &MonikerData{},
+			expected: `{"kind":"local","scheme":"gomod","identifier":"pkg:Baz"}`,
+		},
+		{
+			value:    PackageInformationData{Name: "github.com/foo/bar", Version: &version},
+			decoded:  &PackageInformationData{},
+			expected: `{"name":"github.com/foo/bar","version":"1.2.3"}`,
+		},
+		{
+			value:    PackageInformationData{Name: "github.com/foo/bar"},
+			decoded:  &PackageInformationData{},
+			expected: `{"name":"github.com/foo/bar","version":null}`,
+		},
+		{
+			value: Dump{
+				ID:           7,
+				RepositoryID: 50,
+				Commit:       "deadbeef",
+				Root:         "cmd/",
+				Indexer:      "lsif-go",
+				VisibleAtTip: true,
+				UploadedAt:   uploadedAt,
+				ProcessedAt:  uploadedAt.Add(time.Minute),
+			},
+			decoded:  &Dump{},
+			expected: `{"id":7,"repositoryId":50,"commit":"deadbeef","root":"cmd/","indexer":"lsif-go","visibleAtTip":true,"uploadedAt":"2020-04-01T12:00:00Z","processedAt":"2020-04-01T12:01:00Z"}`,
+		},
+		{
+			value:    Hover{Text: "func Foo()", Range: rng},
+			decoded:  &Hover{},
+			expected: `{"text":"func Foo()","range":{"start":{"line":1,"character":2},"end":{"line":3,"character":4}}}`,
+		},
+		{
+			value:    Symbol{Name: "Foo", Kind: &kind, Range: rng},
+			decoded:  &Symbol{},
+			expected: `{"name":"Foo","kind":12,"range":{"start":{"line":1,"character":2},"end":{"line":3,"character":4}},"container":null}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		serialized, err := json.Marshal(testCase.value)
+		if err != nil {
+			t.Fatalf("unexpected error marshalling %T: %s", testCase.value, err)
+		}
+		if string(serialized) != testCase.expected {
+			t.Errorf("unexpected encoding of %T. want=%s have=%s", testCase.value, testCase.expected, serialized)
+		}
+
+		if err := json.Unmarshal(serialized, testCase.decoded); err != nil {
+			t.Fatalf("unexpected error unmarshalling %T: %s", testCase.value, err)
+		}
+		if decoded := reflect.ValueOf(testCase.decoded).Elem().Interface(); !reflect.DeepEqual(decoded, testCase.value) {
+			t.Errorf("unexpected decoded value. want=%+v have=%+v", testCase.value, decoded)
+		}
+	}
+}
+
+func TestIDString(t *testing.T) {
+	testCases := map[ID]string{
+		`"foo"`: "foo",
+		`123`:   "123",
+		`"12"`:  "12",
+	}
+
+	for id, expected := range testCases {
+		if actual := id.String(); actual != expected {
+			t.Errorf("unexpected string for %s. want=%s have=%s", id, expected, actual)
+		}
+	}
+}
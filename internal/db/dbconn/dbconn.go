@@ -155,6 +155,14 @@ func Open(dataSource string) (*sql.DB, error) {
 	return db, nil
 }
 
+// NewListener creates a listener of Postgres notifications with a dedicated connection to the
+// database identified by dataSource. As with ConnectToDB, the value of PGDATASOURCE is used if
+// dataSource is the empty string. The listener reconnects automatically after a connection
+// loss, reporting each connection event to eventCallback (which may be nil).
+func NewListener(dataSource string, eventCallback pq.EventCallbackType) *pq.Listener {
+	return pq.NewListener(buildConnectionString(dataSource), time.Second, time.Minute, eventCallback)
+}
+
 // Ping attempts to contact the database and returns a non-nil error upon failure. It is intended to
 // be used by health checks.
 func Ping(ctx context.Context) error { return Global.PingContext(ctx) }
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Add column referencing the dump that supersedes this one
+ALTER TABLE lsif_uploads ADD COLUMN superseded_by integer;
+
+-- Recreate view with new column
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Add operator overrides for dump visibility
+ALTER TABLE lsif_uploads ADD COLUMN pinned boolean NOT NULL DEFAULT false;
+ALTER TABLE lsif_uploads ADD COLUMN excluded boolean NOT NULL DEFAULT false;
+
+-- Recreate view with new columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
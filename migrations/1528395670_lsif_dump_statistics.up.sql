@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS lsif_dump_statistics (
+    dump_id integer PRIMARY KEY REFERENCES lsif_uploads(id) ON DELETE CASCADE,
+    num_documents integer NOT NULL,
+    num_ranges integer NOT NULL,
+    num_files_in_root integer,
+    documents_by_language jsonb NOT NULL DEFAULT '{}'::jsonb
+);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Add the SHA-256 digest of the raw upload
+ALTER TABLE lsif_uploads ADD COLUMN checksum text;
+
+-- Recreate view with new column
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+
+-- Drop view and index dependent on the state type
+DROP VIEW lsif_dumps;
+DROP INDEX lsif_uploads_repository_id_commit_root_indexer;
+
+-- Drop columns
+ALTER TABLE lsif_uploads DROP COLUMN attempts;
+ALTER TABLE lsif_uploads DROP COLUMN last_retried_at;
+
+-- Recreate the state type without the failed state. Failed uploads are
+-- indistinguishable from errored uploads without an attempt counter.
+UPDATE lsif_uploads SET state = 'errored' WHERE state = 'failed';
+ALTER TYPE lsif_upload_state RENAME TO lsif_upload_state_old;
+CREATE TYPE lsif_upload_state AS ENUM (
+    'queued',
+    'processing',
+    'completed',
+    'errored'
+);
+ALTER TABLE lsif_uploads ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE lsif_uploads ALTER COLUMN state TYPE lsif_upload_state USING state::text::lsif_upload_state;
+ALTER TABLE lsif_uploads ALTER COLUMN state SET DEFAULT 'queued';
+DROP TYPE lsif_upload_state_old;
+
+-- Recreate index and view without new columns
+CREATE UNIQUE INDEX lsif_uploads_repository_id_commit_root_indexer ON lsif_uploads(repository_id, "commit", root, indexer) WHERE state = 'completed'::lsif_upload_state;
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
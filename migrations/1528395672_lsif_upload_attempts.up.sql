@@ -0,0 +1,30 @@
+BEGIN;
+
+-- Drop view and index dependent on the state type
+DROP VIEW lsif_dumps;
+DROP INDEX lsif_uploads_repository_id_commit_root_indexer;
+
+-- Recreate the state type with the terminal failed state. Values cannot be added
+-- to an enum type within a transaction, so the column is converted to a new type.
+ALTER TYPE lsif_upload_state RENAME TO lsif_upload_state_old;
+CREATE TYPE lsif_upload_state AS ENUM (
+    'queued',
+    'processing',
+    'completed',
+    'errored',
+    'failed'
+);
+ALTER TABLE lsif_uploads ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE lsif_uploads ALTER COLUMN state TYPE lsif_upload_state USING state::text::lsif_upload_state;
+ALTER TABLE lsif_uploads ALTER COLUMN state SET DEFAULT 'queued';
+DROP TYPE lsif_upload_state_old;
+
+-- Track the retries of errored uploads
+ALTER TABLE lsif_uploads ADD COLUMN attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE lsif_uploads ADD COLUMN last_retried_at timestamp with time zone;
+
+-- Recreate index and view with new columns
+CREATE UNIQUE INDEX lsif_uploads_repository_id_commit_root_indexer ON lsif_uploads(repository_id, "commit", root, indexer) WHERE state = 'completed'::lsif_upload_state;
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,32 @@
+BEGIN;
+
+-- Drop view and index dependent on the state type
+DROP VIEW lsif_dumps;
+DROP INDEX lsif_uploads_repository_id_commit_root_indexer;
+
+-- Deleted uploads cannot be represented without the deleting state
+DELETE FROM lsif_uploads WHERE state = 'deleting';
+
+-- Drop columns
+ALTER TABLE lsif_uploads DROP COLUMN deleted_at;
+ALTER TABLE lsif_uploads DROP COLUMN state_before_delete;
+
+-- Recreate the state type without the deleting state
+ALTER TYPE lsif_upload_state RENAME TO lsif_upload_state_old;
+CREATE TYPE lsif_upload_state AS ENUM (
+    'queued',
+    'processing',
+    'completed',
+    'errored',
+    'failed'
+);
+ALTER TABLE lsif_uploads ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE lsif_uploads ALTER COLUMN state TYPE lsif_upload_state USING state::text::lsif_upload_state;
+ALTER TABLE lsif_uploads ALTER COLUMN state SET DEFAULT 'queued';
+DROP TYPE lsif_upload_state_old;
+
+-- Recreate index and view without new columns
+CREATE UNIQUE INDEX lsif_uploads_repository_id_commit_root_indexer ON lsif_uploads(repository_id, "commit", root, indexer) WHERE state = 'completed'::lsif_upload_state;
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
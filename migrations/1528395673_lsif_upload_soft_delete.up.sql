@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Drop view and index dependent on the state type
+DROP VIEW lsif_dumps;
+DROP INDEX lsif_uploads_repository_id_commit_root_indexer;
+
+-- Recreate the state type with the deleting state. Values cannot be added
+-- to an enum type within a transaction, so the column is converted to a new type.
+ALTER TYPE lsif_upload_state RENAME TO lsif_upload_state_old;
+CREATE TYPE lsif_upload_state AS ENUM (
+    'queued',
+    'processing',
+    'completed',
+    'errored',
+    'failed',
+    'deleting'
+);
+ALTER TABLE lsif_uploads ALTER COLUMN state DROP DEFAULT;
+ALTER TABLE lsif_uploads ALTER COLUMN state TYPE lsif_upload_state USING state::text::lsif_upload_state;
+ALTER TABLE lsif_uploads ALTER COLUMN state SET DEFAULT 'queued';
+DROP TYPE lsif_upload_state_old;
+
+-- Track the time and prior state of deleted uploads so they can be restored
+ALTER TABLE lsif_uploads ADD COLUMN deleted_at timestamp with time zone;
+ALTER TABLE lsif_uploads ADD COLUMN state_before_delete lsif_upload_state;
+
+-- Recreate index and view with new columns
+CREATE UNIQUE INDEX lsif_uploads_repository_id_commit_root_indexer ON lsif_uploads(repository_id, "commit", root, indexer) WHERE state = 'completed'::lsif_upload_state;
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
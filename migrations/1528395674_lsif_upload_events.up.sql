@@ -0,0 +1,18 @@
+BEGIN;
+
+-- Events are kept after the upload they describe is removed, so that the removal
+-- itself can be audited. Therefore, upload_id does not reference lsif_uploads.
+CREATE TABLE IF NOT EXISTS lsif_upload_events (
+    id serial PRIMARY KEY,
+    upload_id integer NOT NULL,
+    event text NOT NULL,
+    state text,
+    source text NOT NULL,
+    actor text,
+    message text,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS lsif_upload_events_upload_id ON lsif_upload_events(upload_id);
+
+COMMIT;
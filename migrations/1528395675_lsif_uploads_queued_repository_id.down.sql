@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS lsif_uploads_queued_repository_id;
+
+COMMIT;
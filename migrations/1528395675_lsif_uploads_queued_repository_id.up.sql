@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Supports counting the queued uploads of a repository when enforcing enqueue quotas.
+CREATE INDEX IF NOT EXISTS lsif_uploads_queued_repository_id ON lsif_uploads(repository_id) WHERE state = 'queued';
+
+COMMIT;
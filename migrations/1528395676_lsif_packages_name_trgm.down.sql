@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS lsif_packages_name_trgm;
+
+COMMIT;
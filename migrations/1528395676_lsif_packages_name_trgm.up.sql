@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Supports searching packages by name prefix and similarity.
+CREATE INDEX IF NOT EXISTS lsif_packages_name_trgm ON lsif_packages USING gin (lower(name) gin_trgm_ops);
+
+COMMIT;
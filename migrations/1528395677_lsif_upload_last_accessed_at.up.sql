@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Track the last time a bundle manager answered a query from the dump
+ALTER TABLE lsif_uploads ADD COLUMN last_accessed_at timestamp with time zone;
+
+-- Recreate view with new column
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
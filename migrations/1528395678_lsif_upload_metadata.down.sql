@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Drop view dependent on columns
+DROP VIEW lsif_dumps;
+
+-- Drop columns
+ALTER TABLE lsif_uploads DROP COLUMN lsif_version;
+ALTER TABLE lsif_uploads DROP COLUMN position_encoding;
+ALTER TABLE lsif_uploads DROP COLUMN project_root;
+
+-- Recreate view without columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
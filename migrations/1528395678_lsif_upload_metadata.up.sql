@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Record the metadata vertex of each upload
+ALTER TABLE lsif_uploads ADD COLUMN lsif_version text;
+ALTER TABLE lsif_uploads ADD COLUMN position_encoding text;
+ALTER TABLE lsif_uploads ADD COLUMN project_root text;
+
+-- Recreate view with new columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Record the input format of each upload
+ALTER TABLE lsif_uploads ADD COLUMN format text NOT NULL DEFAULT 'lsif';
+
+-- Recreate view with new columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS lsif_uploads_delete_invalidate_nearest_uploads ON lsif_uploads;
+DROP TRIGGER IF EXISTS lsif_uploads_update_invalidate_nearest_uploads ON lsif_uploads;
+DROP TRIGGER IF EXISTS lsif_uploads_insert_invalidate_nearest_uploads ON lsif_uploads;
+DROP TRIGGER IF EXISTS lsif_commits_invalidate_nearest_uploads ON lsif_commits;
+DROP FUNCTION IF EXISTS lsif_invalidate_nearest_uploads();
+DROP TABLE IF EXISTS lsif_nearest_uploads;
+
+COMMIT;
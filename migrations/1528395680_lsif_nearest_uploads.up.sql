@@ -0,0 +1,39 @@
+BEGIN;
+
+-- Cache the dumps visible from each queried commit along with their commit distance
+CREATE TABLE lsif_nearest_uploads (
+    repository_id integer NOT NULL,
+    "commit" text NOT NULL,
+    upload_ids integer[] NOT NULL,
+    distances integer[] NOT NULL,
+    PRIMARY KEY (repository_id, "commit")
+);
+
+-- Drop the cached commits of a repository when its commit graph or its dumps change
+CREATE FUNCTION lsif_invalidate_nearest_uploads() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM lsif_nearest_uploads WHERE repository_id = OLD.repository_id;
+        RETURN OLD;
+    END IF;
+
+    DELETE FROM lsif_nearest_uploads WHERE repository_id = NEW.repository_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER lsif_commits_invalidate_nearest_uploads AFTER INSERT OR DELETE ON lsif_commits
+    FOR EACH ROW EXECUTE PROCEDURE lsif_invalidate_nearest_uploads();
+
+CREATE TRIGGER lsif_uploads_insert_invalidate_nearest_uploads AFTER INSERT ON lsif_uploads
+    FOR EACH ROW WHEN (NEW.state = 'completed') EXECUTE PROCEDURE lsif_invalidate_nearest_uploads();
+
+CREATE TRIGGER lsif_uploads_update_invalidate_nearest_uploads
+    AFTER UPDATE OF state, excluded, repository_id, "commit", root, indexer ON lsif_uploads
+    FOR EACH ROW WHEN (OLD.state = 'completed' OR NEW.state = 'completed')
+    EXECUTE PROCEDURE lsif_invalidate_nearest_uploads();
+
+CREATE TRIGGER lsif_uploads_delete_invalidate_nearest_uploads AFTER DELETE ON lsif_uploads
+    FOR EACH ROW WHEN (OLD.state = 'completed') EXECUTE PROCEDURE lsif_invalidate_nearest_uploads();
+
+COMMIT;
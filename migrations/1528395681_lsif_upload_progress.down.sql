@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Drop view dependent on columns
+DROP VIEW lsif_dumps;
+
+-- Drop columns
+ALTER TABLE lsif_uploads DROP COLUMN progress;
+ALTER TABLE lsif_uploads DROP COLUMN stage;
+
+-- Recreate view without columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Record how far the conversion of each upload has advanced
+ALTER TABLE lsif_uploads ADD COLUMN progress integer NOT NULL DEFAULT 0 CHECK (progress >= 0 AND progress <= 100);
+ALTER TABLE lsif_uploads ADD COLUMN stage text;
+
+-- Recreate view with new columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+-- Send the identifier of an upload on the lsif_upload_state channel when its state changes or it is removed
+CREATE FUNCTION lsif_uploads_notify_state() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('lsif_upload_state', OLD.id::text);
+        RETURN OLD;
+    END IF;
+
+    PERFORM pg_notify('lsif_upload_state', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER lsif_uploads_update_notify_state AFTER UPDATE OF state ON lsif_uploads
+    FOR EACH ROW WHEN (OLD.state IS DISTINCT FROM NEW.state) EXECUTE PROCEDURE lsif_uploads_notify_state();
+
+CREATE TRIGGER lsif_uploads_delete_notify_state AFTER DELETE ON lsif_uploads
+    FOR EACH ROW EXECUTE PROCEDURE lsif_uploads_notify_state();
+
+COMMIT;
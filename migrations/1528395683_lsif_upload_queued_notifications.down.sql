@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS lsif_uploads_update_notify_queued ON lsif_uploads;
+DROP TRIGGER IF EXISTS lsif_uploads_insert_notify_queued ON lsif_uploads;
+DROP FUNCTION IF EXISTS lsif_uploads_notify_queued();
+
+COMMIT;
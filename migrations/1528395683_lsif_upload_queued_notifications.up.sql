@@ -0,0 +1,17 @@
+BEGIN;
+
+-- Send the identifier of an upload on the lsif_upload_queued channel when it is queued or requeued
+CREATE FUNCTION lsif_uploads_notify_queued() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('lsif_upload_queued', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER lsif_uploads_insert_notify_queued AFTER INSERT ON lsif_uploads
+    FOR EACH ROW WHEN (NEW.state = 'queued') EXECUTE PROCEDURE lsif_uploads_notify_queued();
+
+CREATE TRIGGER lsif_uploads_update_notify_queued AFTER UPDATE OF state ON lsif_uploads
+    FOR EACH ROW WHEN (OLD.state != 'queued' AND NEW.state = 'queued') EXECUTE PROCEDURE lsif_uploads_notify_queued();
+
+COMMIT;
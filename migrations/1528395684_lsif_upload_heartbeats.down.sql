@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Drop view dependent on columns
+DROP VIEW lsif_dumps;
+
+-- Drop columns
+ALTER TABLE lsif_uploads DROP COLUMN worker_id;
+ALTER TABLE lsif_uploads DROP COLUMN last_heartbeat_at;
+
+-- Recreate view without columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
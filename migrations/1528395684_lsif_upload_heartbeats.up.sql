@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Drop view dependent on table
+DROP VIEW lsif_dumps;
+
+-- Record which worker is converting each upload and when it last reported that it is alive
+ALTER TABLE lsif_uploads ADD COLUMN worker_id text;
+ALTER TABLE lsif_uploads ADD COLUMN last_heartbeat_at timestamp with time zone;
+
+-- Recreate view with new columns
+CREATE VIEW lsif_dumps AS SELECT u.*, u.finished_at as processed_at FROM lsif_uploads u WHERE state = 'completed';
+
+COMMIT;
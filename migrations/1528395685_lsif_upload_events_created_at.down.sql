@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS lsif_upload_events_created_at;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Support the removal of events older than the retention period
+CREATE INDEX IF NOT EXISTS lsif_upload_events_created_at ON lsif_upload_events(created_at);
+
+COMMIT;
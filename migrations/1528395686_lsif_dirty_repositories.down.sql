@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION lsif_invalidate_nearest_uploads() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM lsif_nearest_uploads WHERE repository_id = OLD.repository_id;
+        RETURN OLD;
+    END IF;
+
+    DELETE FROM lsif_nearest_uploads WHERE repository_id = NEW.repository_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS lsif_mark_repository_dirty(integer);
+DROP TABLE IF EXISTS lsif_dirty_repositories;
+TRUNCATE lsif_nearest_uploads;
+
+COMMIT;
@@ -0,0 +1,46 @@
+BEGIN;
+
+-- Track the repositories whose nearest uploads are out of date. The dirty token is the identifier
+-- of the last transaction that changed the commits or dumps of the repository, and the update token
+-- is the dirty token read by the last refresh of its nearest uploads. The rows of a repository are
+-- up to date when both tokens match.
+CREATE TABLE lsif_dirty_repositories (
+    repository_id integer PRIMARY KEY,
+    dirty_token bigint NOT NULL,
+    update_token bigint NOT NULL DEFAULT 0
+);
+
+CREATE FUNCTION lsif_mark_repository_dirty(repository integer) RETURNS void AS $$
+BEGIN
+    -- Later rows changed by the same transaction find the token already set and write nothing
+    INSERT INTO lsif_dirty_repositories (repository_id, dirty_token) VALUES (repository, txid_current())
+    ON CONFLICT (repository_id) DO UPDATE SET dirty_token = EXCLUDED.dirty_token
+    WHERE lsif_dirty_repositories.dirty_token <> EXCLUDED.dirty_token;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Mark a repository dirty instead of dropping its rows when its commit graph or its dumps change.
+-- The triggers stay row-level as statement-level triggers cannot see the changed rows in Postgres
+-- 9.6, but each transaction now writes once per repository rather than once per row.
+CREATE OR REPLACE FUNCTION lsif_invalidate_nearest_uploads() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM lsif_mark_repository_dirty(OLD.repository_id);
+        RETURN OLD;
+    END IF;
+
+    IF TG_OP = 'UPDATE' AND OLD.repository_id <> NEW.repository_id THEN
+        PERFORM lsif_mark_repository_dirty(OLD.repository_id);
+    END IF;
+
+    PERFORM lsif_mark_repository_dirty(NEW.repository_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Rebuild the rows of every repository in the background
+TRUNCATE lsif_nearest_uploads;
+INSERT INTO lsif_dirty_repositories (repository_id, dirty_token)
+SELECT DISTINCT repository_id, txid_current() FROM lsif_commits;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS lsif_uploads_update_notify_state ON lsif_uploads;
+
+CREATE TRIGGER lsif_uploads_update_notify_state AFTER UPDATE OF state ON lsif_uploads
+    FOR EACH ROW WHEN (OLD.state IS DISTINCT FROM NEW.state) EXECUTE PROCEDURE lsif_uploads_notify_state();
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- Also send the identifier of an upload on the lsif_upload_state channel when its stage or progress changes
+DROP TRIGGER lsif_uploads_update_notify_state ON lsif_uploads;
+
+CREATE TRIGGER lsif_uploads_update_notify_state AFTER UPDATE OF state, stage, progress ON lsif_uploads
+    FOR EACH ROW WHEN (
+        OLD.state IS DISTINCT FROM NEW.state OR
+        OLD.stage IS DISTINCT FROM NEW.stage OR
+        OLD.progress IS DISTINCT FROM NEW.progress
+    ) EXECUTE PROCEDURE lsif_uploads_notify_state();
+
+COMMIT;
@@ -102,6 +102,46 @@
 // 1528395666_lsif_filename.up.sql (289B)
 // 1528395667_index_boolean_fields_on_repo.down.sql (120B)
 // 1528395667_index_boolean_fields_on_repo.up.sql (187B)
+// 1528395668_lsif_superseded_dumps.down.sql (288B)
+// 1528395668_lsif_superseded_dumps.up.sql (340B)
+// 1528395669_lsif_visibility_overrides.down.sql (331B)
+// 1528395669_lsif_visibility_overrides.up.sql (420B)
+// 1528395670_lsif_dump_statistics.down.sql (60B)
+// 1528395670_lsif_dump_statistics.up.sql (310B)
+// 1528395671_lsif_upload_checksums.down.sql (283B)
+// 1528395671_lsif_upload_checksums.up.sql (316B)
+// 1528395672_lsif_upload_attempts.down.sql (1.238kB)
+// 1528395672_lsif_upload_attempts.up.sql (1.28kB)
+// 1528395673_lsif_upload_soft_delete.down.sql (1.224kB)
+// 1528395673_lsif_upload_soft_delete.up.sql (1.323kB)
+// 1528395674_lsif_upload_events.down.sql (58B)
+// 1528395674_lsif_upload_events.up.sql (544B)
+// 1528395675_lsif_uploads_queued_repository_id.down.sql (73B)
+// 1528395675_lsif_uploads_queued_repository_id.up.sql (220B)
+// 1528395676_lsif_packages_name_trgm.down.sql (63B)
+// 1528395676_lsif_packages_name_trgm.up.sql (185B)
+// 1528395677_lsif_upload_last_accessed_at.down.sql (291B)
+// 1528395677_lsif_upload_last_accessed_at.up.sql (371B)
+// 1528395678_lsif_upload_metadata.down.sql (397B)
+// 1528395678_lsif_upload_metadata.up.sql (437B)
+// 1528395679_lsif_upload_format.down.sql (284B)
+// 1528395679_lsif_upload_format.up.sql (337B)
+// 1528395680_lsif_nearest_uploads.down.sql (460B)
+// 1528395680_lsif_nearest_uploads.up.sql (1.582kB)
+// 1528395681_lsif_upload_progress.down.sql (330B)
+// 1528395681_lsif_upload_progress.up.sql (446B)
+// 1528395682_lsif_upload_state_notifications.down.sql (216B)
+// 1528395682_lsif_upload_state_notifications.up.sql (759B)
+// 1528395683_lsif_upload_queued_notifications.down.sql (219B)
+// 1528395683_lsif_upload_queued_notifications.up.sql (670B)
+// 1528395684_lsif_upload_heartbeats.down.sql (343B)
+// 1528395684_lsif_upload_heartbeats.up.sql (446B)
+// 1528395685_lsif_upload_events_created_at.down.sql (69B)
+// 1528395685_lsif_upload_events_created_at.up.sql (174B)
+// 1528395686_lsif_dirty_repositories.down.sql (509B)
+// 1528395686_lsif_dirty_repositories.up.sql (1.947kB)
+// 1528395687_lsif_upload_progress_notifications.down.sql (285B)
+// 1528395687_lsif_upload_progress_notifications.up.sql (517B)
 
 package migrations
 
@@ -2210,6 +2250,806 @@ func _1528395667_index_boolean_fields_on_repoUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __1528395668_lsif_superseded_dumpsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\xce\x51\x4b\xc3\x30\x14\x05\xe0\xf7\xfc\x8a\xf3\x36\x10\xb7\x3f\x30\x7c\xe8\xba\xab\x16\xda\x55\xb2\xe8\x1e\x4b\x6d\xee\x58\xa0\x4d\x42\x6f\xe2\xf0\xdf\x8b\x56\x41\x7d\xbc\x70\xce\xfd\xce\x8e\x1e\xaa\xc3\x56\xa9\xf5\x1a\xfb\x39\x44\xbc\x39\xbe\xc2\x72\x64\x6f\xd9\x27\x04\x8f\x21\x8c\x79\xf2\x6a\xaf\xdb\x27\xbc\x54\x74\xc2\x28\xee\xdc\xd9\x3c\x45\xf9\xd5\xfb\x4e\x15\xb5\x21\x0d\x53\xec\x6a\x5a\x72\x39\x8e\xa1\xb7\x82\xaf\x7a\xd9\xd6\xcf\xcd\x01\x92\x23\xcf\xc2\x96\x6d\xf7\xfa\xbe\xfc\xd0\x3c\xcc\xdc\x27\x5e\xfc\xab\x4b\x97\x90\xd3\x0f\x5d\x6a\x2a\x0c\xfd\xc7\x51\x1c\x71\xa4\x9a\x4a\x83\xbc\xb9\xb9\x45\xde\x9c\x9d\x77\x72\x61\xdb\xf5\x09\xbd\x20\xce\x61\x60\x91\xe5\xbe\xd7\x6d\xf3\x77\x51\xc6\xe9\x91\x34\x41\xd2\xa7\x7b\x87\xd5\x10\xa6\x38\x72\x62\xbb\xda\x2a\x55\xb6\x4d\x53\x99\xad\xfa\x18\x00\xe2\x24\x85\xda\x20\x01\x00\x00")
+
+func _1528395668_lsif_superseded_dumpsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395668_lsif_superseded_dumpsDownSql,
+		"1528395668_lsif_superseded_dumps.down.sql",
+	)
+}
+
+func _1528395668_lsif_superseded_dumpsDownSql() (*asset, error) {
+	bytes, err := _1528395668_lsif_superseded_dumpsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395668_lsif_superseded_dumps.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa8, 0x4, 0x10, 0x8b, 0x17, 0xf3, 0x64, 0xbf, 0x65, 0x4b, 0x65, 0x3c, 0x98, 0x6b, 0x3f, 0x21, 0xe4, 0x5b, 0xb0, 0x9f, 0xa0, 0x70, 0xfa, 0x49, 0xd3, 0xb6, 0xf1, 0x72, 0x24, 0x35, 0xc6, 0x7d}}
+	return a, nil
+}
+
+var __1528395668_lsif_superseded_dumpsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\xce\xc1\x6a\xc3\x30\x10\x04\xd0\xbb\xbe\x62\x6e\x81\xd2\xe4\x07\x42\x0f\x8e\xad\xb6\x01\x3b\x2e\x8e\xdb\x1c\x83\x62\x6d\x62\x81\x23\x09\xed\xaa\xa1\x7f\x5f\x82\x0b\xa5\x3d\x2e\xec\xcc\xbc\x8d\x7e\xd9\xee\xd6\x4a\x2d\x97\xa8\x52\x88\xf8\x74\x74\x83\xa5\x48\xde\x92\x17\x04\x0f\x31\xa7\x89\x54\xd5\xb5\x6f\xf8\xd8\xea\x03\x26\x76\xe7\xa3\xcd\xd7\xc8\x73\xac\xb0\x16\x43\x98\xf2\xd5\x23\xd1\x99\x12\xf9\xc1\xf9\x0b\x64\x24\xdc\xbf\x20\xa3\x11\x70\x8e\x94\x98\x2c\x31\x64\x74\x8c\xe0\x49\x15\x75\xaf\x3b\xf4\xc5\xa6\xd6\x73\x69\x8e\x53\x30\x96\x51\x54\x15\xca\xb6\x7e\x6f\x76\xbf\x39\x7b\x3c\x7d\xc1\x79\xa1\x0b\xa5\x79\xb7\xa3\x21\x91\x11\x9a\xc9\x37\x27\x23\x3c\xdd\x7e\x28\xaa\xec\x74\xd1\xeb\xff\x62\x14\x7b\xec\x75\xad\xcb\x1e\x79\xf5\xf0\x88\xbc\x3a\x3b\xef\x78\x24\x7b\x34\x02\xc3\x88\x29\x0c\xc4\x3c\xdf\xcf\x5d\xdb\xfc\x95\x65\x1c\x5e\x75\xa7\xc1\x72\x1f\x7e\xc2\x62\x08\xd7\x38\x91\x90\x5d\xac\x95\x2a\xdb\xa6\xd9\xf6\x6b\xf5\x3d\x00\x51\x74\xf7\x68\x54\x01\x00\x00")
+
+func _1528395668_lsif_superseded_dumpsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395668_lsif_superseded_dumpsUpSql,
+		"1528395668_lsif_superseded_dumps.up.sql",
+	)
+}
+
+func _1528395668_lsif_superseded_dumpsUpSql() (*asset, error) {
+	bytes, err := _1528395668_lsif_superseded_dumpsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395668_lsif_superseded_dumps.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x90, 0xcc, 0xd8, 0x96, 0xbc, 0xcd, 0xb, 0x6a, 0x91, 0xc2, 0x91, 0xd4, 0xbc, 0xfc, 0xcc, 0xf6, 0x5e, 0xb2, 0x1b, 0xf8, 0x3a, 0xf6, 0x8b, 0xf9, 0x25, 0x6c, 0x7e, 0x8d, 0x17, 0xcb, 0x44, 0x96}}
+	return a, nil
+}
+
+var __1528395669_lsif_visibility_overridesDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x8e\x41\x4b\xc3\x30\x18\x86\xef\xf9\x15\xef\x6d\x20\x6e\x7f\xa0\x78\xe8\xba\x4f\x2d\xb4\xab\x64\xd5\x1d\x47\x49\xbe\xb1\x40\x9a\x84\x26\x71\xfe\x7c\xa9\x45\x51\x4f\x3b\xbe\xf0\xbc\x3c\xcf\x96\x9e\xea\x7d\x21\xc4\x7a\x8d\xdd\xe4\x03\xde\x0d\x5f\xa1\x39\xb0\xd3\xec\x12\xbc\x83\xf2\x36\x8f\x2e\x8a\x9d\xec\x5e\xf0\x56\xd3\x11\x36\x9a\xf3\x49\xe7\x31\xc4\x5f\xc7\x6f\xac\x6c\x7a\x92\xe8\xcb\x6d\x43\x0b\x98\x83\xf5\x83\x8e\xf8\xfa\x57\x5d\xf3\xda\xee\x11\x8c\x73\xac\x8b\xdb\x60\xfe\x50\x36\xeb\x19\x9f\x65\x92\xd5\xc4\x43\xe2\xa5\xf4\x6a\xd2\xc5\xe7\xf4\x13\x59\x49\x2a\x7b\xfa\x9f\x89\xf2\x80\x03\x35\x54\xf5\xc8\x9b\xbb\x7b\xe4\xcd\xd9\x38\x13\x2f\xac\x4f\x43\xc2\x10\x11\x26\xaf\x38\xc6\x65\x3f\xca\xae\xfd\x5b\x93\x71\x7c\x26\x49\x88\x69\x16\x3f\x60\xa5\xfc\x18\x2c\x27\xd6\xab\x42\x88\xaa\x6b\xdb\xba\x2f\xc4\xe7\x00\x47\x6d\xb8\xa4\x4b\x01\x00\x00")
+
+func _1528395669_lsif_visibility_overridesDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395669_lsif_visibility_overridesDownSql,
+		"1528395669_lsif_visibility_overrides.down.sql",
+	)
+}
+
+func _1528395669_lsif_visibility_overridesDownSql() (*asset, error) {
+	bytes, err := _1528395669_lsif_visibility_overridesDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395669_lsif_visibility_overrides.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x49, 0x36, 0x66, 0x48, 0xb9, 0xd8, 0x78, 0x7, 0xb9, 0x36, 0xcf, 0xff, 0xe6, 0x22, 0x11, 0x2, 0x95, 0x8, 0xf6, 0x53, 0xd0, 0x48, 0xb4, 0x6f, 0x26, 0x52, 0x87, 0xee, 0x89, 0xe5, 0xd, 0xd7}}
+	return a, nil
+}
+
+var __1528395669_lsif_visibility_overridesUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x90\xcd\x6e\xea\x30\x10\x85\xf7\x7e\x8a\xb3\x43\xba\xba\xf0\x02\xd1\x5d\x84\xc4\xdc\x22\x39\x49\x15\x4c\x59\x22\x13\x4f\x84\x25\x63\x5b\xfe\x81\xf6\xed\x2b\x94\x55\xbb\x62\x39\xd2\xcc\x37\xe7\x7c\x5b\xfe\x7f\xdf\x57\x8c\xad\xd7\x68\xa3\x0f\xb8\x1b\x7a\x40\x53\x20\xa7\xc9\x65\x78\x87\xac\x2e\x96\x58\x3b\x0e\xef\xf8\xd8\xf3\x13\x6c\x32\xf3\x59\x97\x5b\x48\xcb\x59\xad\x35\x7c\xa0\xa8\xb2\x8f\xf0\x77\x8a\xd1\x68\x4a\x98\x7d\xc4\x73\x0b\x77\x93\xcc\xc5\x58\x93\xbf\x58\x2d\x24\x1f\x21\xeb\xad\xe0\x0b\xa6\x04\xeb\x95\x4e\xa8\xdb\x16\xcd\x20\x8e\x5d\x8f\x60\x9c\x23\x8d\x8b\xf7\x96\x94\x43\x3f\x48\xf4\x47\x21\xd0\xf2\x5d\x7d\x14\x12\xb3\xb2\x89\xaa\x97\x50\xf4\x39\xd9\xa2\x5f\x80\x3d\x6b\x8c\x34\x45\x52\x99\x16\x03\x0f\x93\xaf\x70\xf4\xc0\xe4\x6d\xb9\xb9\xc4\x9a\x91\xd7\x92\xff\x36\x80\xfa\x80\x03\x17\xbc\x91\x28\x9b\x3f\x7f\x51\x36\xb3\x71\x26\x5d\x49\x9f\x55\x86\x4a\x08\xd1\x4f\x94\xd2\x32\xef\xc6\xa1\xfb\x19\xb6\xe0\xf4\xc6\x47\x8e\x94\x9f\x9f\xff\x61\x35\xf9\x5b\xb0\x94\x49\xaf\x2a\xc6\x9a\xa1\xeb\xf6\xb2\x62\xdf\x03\x00\x2e\x5a\x19\xdc\xa4\x01\x00\x00")
+
+func _1528395669_lsif_visibility_overridesUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395669_lsif_visibility_overridesUpSql,
+		"1528395669_lsif_visibility_overrides.up.sql",
+	)
+}
+
+func _1528395669_lsif_visibility_overridesUpSql() (*asset, error) {
+	bytes, err := _1528395669_lsif_visibility_overridesUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395669_lsif_visibility_overrides.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa7, 0xc1, 0xbb, 0x57, 0x52, 0xf, 0xda, 0x9, 0x61, 0xe2, 0xf3, 0xe7, 0xbf, 0x96, 0xf4, 0xac, 0x13, 0xea, 0x93, 0xfd, 0x90, 0xc8, 0x5c, 0x50, 0xb8, 0xa7, 0x6, 0xe2, 0xb, 0xa5, 0xc9, 0x96}}
+	return a, nil
+}
+
+var __1528395670_lsif_dump_statisticsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x3c\x00\xc3\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x6c\x73\x69\x66\x5f\x64\x75\x6d\x70\x5f\x73\x74\x61\x74\x69\x73\x74\x69\x63\x73\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\xd2\x7b\x9c\xd4\x3c\x00\x00\x00")
+
+func _1528395670_lsif_dump_statisticsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395670_lsif_dump_statisticsDownSql,
+		"1528395670_lsif_dump_statistics.down.sql",
+	)
+}
+
+func _1528395670_lsif_dump_statisticsDownSql() (*asset, error) {
+	bytes, err := _1528395670_lsif_dump_statisticsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395670_lsif_dump_statistics.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xed, 0x7a, 0x65, 0xd8, 0x20, 0x34, 0xec, 0x42, 0x5f, 0xe7, 0x23, 0xe1, 0x7b, 0xd9, 0x1a, 0x7a, 0xba, 0x31, 0x94, 0xfc, 0xba, 0x9b, 0xe2, 0xbf, 0x0, 0x88, 0x90, 0xef, 0xf, 0x55, 0xa5, 0xfc}}
+	return a, nil
+}
+
+var __1528395670_lsif_dump_statisticsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\xce\xbd\x4e\xc3\x30\x14\xc5\xf1\xdd\x4f\x71\xb6\xb6\x12\x4f\xd0\x4e\x6e\x72\x83\x22\xf2\x81\x12\x57\xa2\x93\xe5\xd6\x6e\x64\x94\xd8\x55\x6c\x0f\x08\xf1\xee\x88\x00\x61\x63\xbd\xe7\xaf\x9f\xee\x91\x1e\xcb\xe6\xc0\x58\xd6\x11\x17\x04\xc1\x8f\x15\xa1\x2c\xd0\xb4\x02\xf4\x52\xf6\xa2\xc7\x18\xec\x4d\xea\x34\xdd\x65\x88\x2a\xda\x10\xed\x35\x60\xcb\x00\x60\xb9\x5a\x0d\xeb\xa2\x19\xcc\x8c\xe7\xae\xac\x79\x77\xc6\x13\x9d\xd1\x51\x41\x1d\x35\x19\xfd\x08\xe9\x3e\x7a\xa5\xc3\xd6\xea\x1d\xda\x06\x39\x55\x24\x08\x19\xef\x33\x9e\xd3\xc3\xc2\xb9\x34\x49\xed\xaf\x69\x32\x2e\x86\x15\xfd\x7a\xa5\x39\x55\xd5\x5f\x33\x2b\x37\x98\xff\x82\x9b\x1d\x4d\x90\xd6\xc9\xd9\xfb\xf8\xdb\x7d\xcf\xab\x2f\x2f\x6f\x72\x54\x6e\x48\x6a\x30\x78\x0d\xde\x5d\x56\x08\x39\x15\xfc\x54\x09\x6c\xde\x3f\x36\xfb\xfd\x32\xb2\xdd\x81\xb1\xac\xad\xeb\x52\x1c\xd8\xe7\x00\x63\xd0\x2b\x8f\x36\x01\x00\x00")
+
+func _1528395670_lsif_dump_statisticsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395670_lsif_dump_statisticsUpSql,
+		"1528395670_lsif_dump_statistics.up.sql",
+	)
+}
+
+func _1528395670_lsif_dump_statisticsUpSql() (*asset, error) {
+	bytes, err := _1528395670_lsif_dump_statisticsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395670_lsif_dump_statistics.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x59, 0x3c, 0x5f, 0xef, 0xa4, 0xa5, 0xab, 0x2c, 0xc5, 0xd4, 0xa7, 0xf6, 0x96, 0xf, 0x92, 0xd4, 0x3b, 0x9e, 0xb4, 0x67, 0xa, 0x30, 0x4b, 0x66, 0x5d, 0xe3, 0x4c, 0x38, 0xf6, 0x3d, 0x9b, 0xc}}
+	return a, nil
+}
+
+var __1528395671_lsif_upload_checksumsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\xce\xd1\x4a\xc3\x30\x14\xc6\xf1\xfb\x3c\xc5\x77\x37\x10\xb7\x17\x28\x5e\x74\xdd\x51\x0b\xed\x2a\x59\x74\x97\xa3\x24\x67\x34\xd8\x26\xa1\x49\xec\xeb\x8b\x56\x41\x77\x79\xe0\xfb\xf3\x3b\x7b\x7a\xaa\x8f\x85\x10\xdb\x2d\x0e\xb3\x0f\xf8\xb0\xbc\xc0\x70\x60\x67\xd8\x25\x78\x07\xed\xc7\x3c\x39\x71\x90\xdd\x0b\xde\x6a\x3a\x63\x8c\xf6\x7a\x31\x79\x0a\xf1\x4f\xf7\xb3\x2a\x1b\x45\x12\xaa\xdc\x37\xb4\xee\x72\x18\x7d\x6f\x22\xbe\xf3\xaa\x6b\x5e\xdb\x23\xf4\xc0\xfa\x3d\xe6\x69\xcd\x25\xeb\x99\xfb\xc4\x2b\xbd\xd8\x34\xf8\x9c\x7e\xd5\x4a\x52\xa9\xe8\xd6\x45\x79\xc2\x89\x1a\xaa\x14\xf2\xee\xee\x1e\x79\x77\xb5\xce\xc6\x81\xcd\xa5\x4f\xe8\x23\xc2\xec\x35\xc7\xb8\xde\x8f\xb2\x6b\xff\x3f\x93\x71\x7e\x26\x49\x88\xe9\xcb\x7d\xc0\x46\xfb\x29\x8c\x9c\xd8\x6c\x0a\x21\xaa\xae\x6d\x6b\x55\x88\xcf\x01\x00\x3d\xd4\x03\x11\x1b\x01\x00\x00")
+
+func _1528395671_lsif_upload_checksumsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395671_lsif_upload_checksumsDownSql,
+		"1528395671_lsif_upload_checksums.down.sql",
+	)
+}
+
+func _1528395671_lsif_upload_checksumsDownSql() (*asset, error) {
+	bytes, err := _1528395671_lsif_upload_checksumsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395671_lsif_upload_checksums.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x49, 0xcf, 0x34, 0x9b, 0xc, 0xde, 0x72, 0x30, 0xe4, 0x5b, 0xfb, 0x9d, 0x48, 0x3e, 0x80, 0xd0, 0xa9, 0x9f, 0x56, 0x68, 0x86, 0x2d, 0x1, 0xe, 0xa8, 0x96, 0xa7, 0x3c, 0x4a, 0xfe, 0x7, 0x1a}}
+	return a, nil
+}
+
+var __1528395671_lsif_upload_checksumsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\x8e\xb1\x4e\xf3\x30\x14\x46\x77\x3f\xc5\xb7\x55\xfa\xf5\xb7\x03\x12\x2c\x15\x83\x9b\x18\x5a\x29\x69\x90\x1b\xe8\x58\x19\xfb\x96\x58\x24\xb6\x15\xdb\x84\xc7\x47\xe0\x09\xc6\x7b\xa4\x7b\xbe\xb3\x13\x8f\x87\xe3\x96\xb1\xf5\x1a\xf5\xec\x03\x3e\x2c\x2d\x30\x14\xc8\x19\x72\x09\xde\x21\xa9\xd7\x91\x58\x2d\xbb\x27\xbc\x1c\xc4\x19\x63\xb4\xd7\x8b\xc9\x53\x88\xe5\x8d\x1b\x83\x34\x10\x4e\x7b\xbe\xbe\xb9\xbd\x83\xb1\x6f\x14\x13\xfc\xf5\x87\xce\x6a\x41\x0e\xa3\x57\x86\xf1\xa6\x17\x12\x3d\xdf\x35\xa2\x48\x0a\x8f\xe0\x75\x8d\xaa\x6b\x9e\xdb\x23\xf4\x40\xfa\x3d\xe6\x09\x89\x3e\x53\xf1\x4b\xd2\x33\xa9\x44\x25\x6d\xb1\x69\x80\xa3\x05\xda\x8f\x79\x72\xac\x92\x82\xf7\xe2\x6f\x19\xf8\x09\x27\xd1\x88\xaa\x47\xde\xfc\xfb\x8f\xbc\xb9\x5a\x67\xe3\x40\xe6\xa2\x12\x54\x44\x98\xbd\xa6\x18\xcb\xfd\x20\xbb\xf6\x77\x51\xc6\x79\x2f\xa4\x40\x4c\xdf\xc3\xf7\x58\x69\x3f\x85\x91\x12\x99\xd5\x96\xb1\xaa\x6b\xdb\x43\xbf\x65\x5f\x03\x00\xc0\x16\xaf\xee\x3c\x01\x00\x00")
+
+func _1528395671_lsif_upload_checksumsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395671_lsif_upload_checksumsUpSql,
+		"1528395671_lsif_upload_checksums.up.sql",
+	)
+}
+
+func _1528395671_lsif_upload_checksumsUpSql() (*asset, error) {
+	bytes, err := _1528395671_lsif_upload_checksumsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395671_lsif_upload_checksums.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xac, 0x39, 0x40, 0x5e, 0x2a, 0xa8, 0xa3, 0x30, 0xf0, 0xf6, 0xe0, 0xa6, 0x18, 0xe8, 0xec, 0xee, 0x31, 0x21, 0x2d, 0x85, 0x6a, 0x71, 0xd6, 0x6, 0xed, 0xa0, 0x4, 0xf3, 0xe3, 0xdb, 0xd7, 0xe8}}
+	return a, nil
+}
+
+var __1528395672_lsif_upload_attemptsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x54\xcb\x72\x9b\x30\x14\xdd\xeb\x2b\xee\x64\xe3\xa4\xe3\xf8\x03\xcc\x74\x41\x6c\x25\x65\xc6\x80\x8b\xa1\x69\x57\x8c\x8a\xae\x63\xcd\x80\x44\xf5\xa8\x93\xbf\xef\x18\x81\x1d\x9c\xb8\xd3\x74\xa9\xfb\x3a\xe7\x3e\x8e\xee\xe8\x43\x94\x04\x84\xdc\xde\xc2\x52\xab\x16\x7e\x0b\xdc\x03\x93\x1c\x84\xe4\xf8\x0c\x1c\x5b\x94\x1c\xa5\x05\x25\xc1\xee\x10\x8c\x65\x16\xc1\xbe\xb4\x48\x96\x59\xba\x86\x6f\x11\x7d\x84\xda\x88\x6d\xc9\x5d\xd3\x9a\xc0\x5b\xa3\x64\x49\xbf\x7b\xb3\x6b\x6b\xc5\xb8\x29\x35\xb6\xca\x08\xab\xf4\x4b\x29\x78\x59\xa9\xa6\x11\xb6\xd4\x4a\xd9\xb2\x43\x42\xfd\x8a\x44\xa5\x6a\xd7\x48\x43\xc2\x55\x4e\x33\xc8\xc3\xbb\x15\x1d\x15\x83\x0e\x64\x91\xae\x8a\x38\x01\x66\x2d\x36\xad\x35\xc1\xbf\x85\xd7\xcc\xd8\x52\xa3\xd5\x02\x79\xc9\xac\x47\xcd\xb0\xd2\xd8\x35\x36\x6a\x11\xf6\xc2\xee\x94\xb3\x5d\xe7\x5b\x26\x6a\xe4\xde\x3b\x83\x7b\xff\x1a\x10\x98\xc6\x43\x1d\x21\xb9\x30\x56\xc8\x27\x27\xcc\x8e\xfd\xac\x11\xb6\x5a\x35\x80\x5a\x2b\xfd\x2a\x7a\x28\xcb\xe4\xc0\x1e\x2a\xe5\xa4\x45\x3d\x23\xc5\x7a\x19\xe6\x67\xfc\x37\x34\xef\x59\x7d\x86\x49\x5f\x6c\x02\x8f\x5f\x68\x46\x4f\x76\xcf\x6f\x72\x1c\xc3\x8f\xf5\xa8\x4a\xe9\x03\x33\x9a\x84\x31\x85\x3c\x7d\xeb\x2b\x55\xcd\x03\xb2\xc8\xe8\x01\xff\x42\x7a\xb8\x01\x9a\x14\x31\x5c\x13\x00\x80\xc9\x2f\x87\x0e\xf9\x64\xea\x5f\xad\x56\x15\x1a\x23\xe4\xd3\x60\xa9\x54\xd3\xd6\x68\x4f\x21\x03\x79\x72\xf3\x97\x75\x79\x47\xbf\x2f\x8f\xdb\x6d\x70\x49\xef\xc3\x62\x95\x7f\x2c\xf3\x42\x23\xc5\x26\x4a\x1e\xfc\xf0\xe6\x73\x8b\xcf\x76\x3e\x7f\x13\xf4\x31\xa0\xc3\x92\x7a\x86\xc7\xc1\xf4\x72\x78\x9f\x83\x9f\xf7\xe8\xfc\x3a\x29\x74\xf2\xeb\x74\x38\xdc\x89\xc4\xfd\x51\x13\xfd\x7e\x8a\x24\xfa\x5a\xd0\xff\x12\x1a\xa4\xc9\x28\xe3\x7a\x94\x31\x85\x2b\x9f\x73\x35\x85\x83\x3c\xa7\xd0\xa7\xdd\x9c\x1f\xdc\x69\xb9\xef\xce\xae\x27\x7a\xf6\x41\x40\xb8\x81\x0d\x5d\xd1\x45\x0e\x6e\xf6\x69\x0a\x6e\xb6\x15\x52\x98\x5d\x27\x46\x60\x06\xfa\x23\xf2\xef\xfb\x2c\x8d\x47\x5c\xc1\x5d\x66\x11\x10\xb2\x48\xe3\x38\xca\x03\xf2\x67\x00\x6b\x30\x0b\x42\xd6\x04\x00\x00")
+
+func _1528395672_lsif_upload_attemptsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395672_lsif_upload_attemptsDownSql,
+		"1528395672_lsif_upload_attempts.down.sql",
+	)
+}
+
+func _1528395672_lsif_upload_attemptsDownSql() (*asset, error) {
+	bytes, err := _1528395672_lsif_upload_attemptsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395672_lsif_upload_attempts.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x8d, 0xfb, 0x54, 0xcc, 0x5d, 0x49, 0xf0, 0x17, 0xfd, 0xf8, 0xc3, 0x28, 0xb2, 0x92, 0xad, 0xe9, 0xf, 0x1c, 0x38, 0xd2, 0x91, 0x4, 0x23, 0xd0, 0xab, 0x4b, 0xc, 0xf3, 0xc5, 0xe5, 0xee, 0x48}}
+	return a, nil
+}
+
+var __1528395672_lsif_upload_attemptsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x94\xcb\x6e\xdb\x3c\x10\x85\xf7\x7a\x8a\x83\x6c\x92\xfc\x70\x8c\x7f\x6d\xa3\x0b\xc7\x66\x52\x03\xb2\x94\xca\x52\xd2\xae\x04\x56\x1c\x27\x44\x25\x52\x25\x47\xb9\xf4\xe9\x0b\x8b\x72\x12\xe7\x52\x34\x5d\x92\x9a\xcb\xc7\x99\x73\x74\x2a\xce\x97\xc9\x34\x8a\x4e\x4e\xb0\x70\xb6\xc5\xad\xa6\x3b\x48\xa3\xa0\x8d\xa2\x7b\x28\x6a\xc9\x28\x32\x0c\x6b\xc0\x37\x04\xcf\x92\x09\xfc\xd0\x52\xb4\xc8\xd2\x0b\x5c\x2e\xc5\x15\x6a\xaf\x37\xa5\xea\x9a\xd6\x4f\xc3\xed\x32\x59\x88\xaf\xe1\xba\x6b\x6b\x2b\x95\x2f\x1d\xb5\xd6\x6b\xb6\xee\xa1\xd4\xaa\xac\x6c\xd3\x68\x2e\x9d\xb5\x5c\xf6\x9d\xc8\x05\x88\x8c\x2a\x47\x7d\x8b\xbd\x66\xb8\xd3\x7c\xd3\x03\x30\xb9\x46\x1b\x59\x63\x23\x75\x4d\x2a\xc4\x8c\x71\x29\xeb\x8e\x3c\x2a\x69\x8c\x65\x7c\x27\x48\xa5\x48\x6d\x2b\xb2\x85\x34\x20\xd3\x35\x4f\x95\xb4\x81\x04\x3b\x69\xbc\xac\x58\x5b\x33\x82\xb7\x7d\xf5\xca\xd6\x5d\x63\xa0\x3d\x2a\x6b\x6e\xc9\x31\xa9\xbe\x00\x0c\xdd\xf5\xe9\xe3\x68\x16\xe7\x22\x43\xfe\xed\x42\x3c\x7f\x60\xd9\x73\x20\x13\xc9\x6c\x25\x90\xa7\xaf\xbf\x95\xb6\x56\xd3\x68\x9e\x89\x59\x2e\xde\x4b\x9f\xad\x21\x92\x62\x85\xa3\x08\x00\x0e\x7f\x76\xd4\x91\x3a\x1c\x85\x53\xeb\x6c\x45\xde\x6b\x73\xbd\xbb\xa9\x6c\xd3\xd6\xc4\x4f\x21\xe4\x9c\x75\x4f\xc7\x30\xa2\xc3\xe8\x78\xba\xa3\x9e\x9d\xc6\x7b\x7d\x3d\xc2\x87\x79\x1a\x17\xab\x64\x18\x78\xbf\xc2\x85\x38\x9b\x15\x71\xfe\xb1\xcc\x77\x9e\x55\xac\x97\xc9\x79\x28\x3e\x99\x30\xdd\xf3\x64\xf2\x2a\xe8\x63\x8d\xd6\x22\xdf\x11\x3e\x8e\x69\xd0\xde\xdb\x0c\x61\xfa\x5b\x3d\xe4\x4e\x56\x3f\xfa\x65\x3b\x62\xa7\xc9\xc3\x6e\x30\x0c\x0e\x43\xcb\x3f\xb0\x2c\x16\x3b\x12\xc9\x4c\x4d\xcb\x1e\xda\x30\x5d\x93\x43\x92\xe6\x48\x8a\x38\x7e\x24\xfb\x7f\xfa\x57\x85\x6a\xe9\xb9\x0c\x30\xaa\x94\x0c\xd6\x0d\x79\x96\x4d\x3b\xa8\x5e\x37\x84\x5f\xd6\xd0\x0b\x87\xf4\xbe\xe9\xbd\xda\x9b\xb6\x8f\xdd\xca\x34\x68\xd8\xef\xa4\x56\x24\xcb\x2f\x85\xf8\x27\x4b\x22\x4d\xf6\x32\x8e\xf6\x32\x46\x38\x08\x39\x07\x23\x6c\x8d\x3c\xc2\x90\x76\x8c\xab\xcf\x22\x13\xc3\xaa\x3e\x3d\xd7\xe9\x9b\x8b\x1f\x40\x5f\xfc\x4a\x30\x5b\x63\x2d\x62\x31\xcf\xd1\x8d\xff\x1b\xa1\x1b\x6f\xb4\xd1\xfe\x26\xcc\x48\x7a\x0c\x7e\x08\xe7\xb3\x2c\x5d\xed\xb1\xa2\x7b\x9f\x62\x1a\x45\xf3\x74\xb5\x5a\xe6\xd3\xe8\xf7\x00\xf8\xe8\x2f\x55\x00\x05\x00\x00")
+
+func _1528395672_lsif_upload_attemptsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395672_lsif_upload_attemptsUpSql,
+		"1528395672_lsif_upload_attempts.up.sql",
+	)
+}
+
+func _1528395672_lsif_upload_attemptsUpSql() (*asset, error) {
+	bytes, err := _1528395672_lsif_upload_attemptsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395672_lsif_upload_attempts.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc4, 0x24, 0x37, 0x3b, 0xe1, 0xce, 0x8d, 0xf1, 0xa1, 0xa0, 0x7a, 0x33, 0x17, 0x7b, 0xee, 0x77, 0x23, 0x65, 0xe3, 0xeb, 0xf3, 0xc8, 0x31, 0x7b, 0x71, 0xf2, 0xe1, 0xfb, 0xde, 0x4c, 0xf9, 0x82}}
+	return a, nil
+}
+
+var __1528395673_lsif_upload_soft_deleteDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x54\x4d\x53\xdb\x30\x10\xbd\xeb\x57\xec\x70\x09\x74\x02\x3f\x20\x9e\x1e\x4c\x22\xa8\x67\xfc\x41\xfd\x51\xda\x93\xc6\x58\x1b\xa2\x19\x5b\x72\x25\xb9\x81\x7f\xdf\x89\xe4\x04\x4c\x08\x53\x7a\x94\xd6\x4f\xef\xed\xdb\x7d\xbe\xa6\xb7\x51\x1a\x10\x72\x79\x09\x2b\xad\x7a\xf8\x23\x70\x0b\xb5\xe4\x20\x24\xc7\x27\xe0\xd8\xa3\xe4\x28\x2d\x28\x09\x76\x83\x60\x6c\x6d\x11\xec\x73\x8f\x64\x95\x67\x77\xf0\x23\xa2\xf7\xd0\x1a\xb1\x66\x7c\xe8\x7a\x13\xf8\xdb\x28\x5d\xd1\x9f\xfe\x7a\xe8\x5b\x55\x73\xc3\x34\xf6\xca\x08\xab\xf4\x33\x13\x9c\x35\xaa\xeb\x84\x65\x5a\x29\xcb\x1c\x13\xea\x51\x04\xb6\x68\x91\xc3\x08\x83\xa6\x96\x52\x59\x78\x40\xd0\xd8\x6b\x34\x28\x77\xd5\xad\xb0\x1b\x35\x58\xa7\x88\xef\x10\x42\x3e\x7a\x69\x64\x45\x63\x5a\x52\xb8\xc9\xb3\x64\x22\x00\xee\xbf\xd1\x9c\x8e\xfa\xbf\xc2\x6c\x0f\x9b\xbd\x6a\xbe\x51\xed\xd0\x49\x43\xc2\xb8\xa4\x39\x94\xe1\x75\x4c\xa7\x6f\xb8\xe6\x96\x59\x5c\x25\x29\xb8\x07\x90\xb3\xda\x06\xff\x06\x70\xd4\xec\x01\xd7\x4a\x23\xf3\x68\xcf\x9d\x63\xa3\xd1\xd9\x3a\x31\xf8\xa3\x2e\x47\xc2\x5f\x77\x13\x3e\xe6\x8a\x90\xd3\x34\x4c\x28\x94\xd9\x71\x8d\xa9\x96\x07\x64\x99\xd3\xb0\xa4\xa7\xe0\x61\x01\x34\xad\x12\x38\x27\x00\x00\xb3\xdf\x03\x0e\xc8\x67\x73\x7f\xea\xb5\x6a\xd0\x18\x21\x1f\xf7\x37\x8d\xea\xfa\x5d\x2f\x87\x4f\x50\x6b\xa5\x5f\x8e\xeb\x5a\xb4\xc8\x67\xe4\xe2\x03\x9b\x7c\xe1\xb5\x4f\xde\xb9\x15\xbd\x09\xab\xb8\xfc\x1c\xf2\x44\x5b\x55\x11\xa5\xb7\xfe\xf1\xc5\xc2\xe2\x93\x5d\x2c\x8e\x3e\xfa\x1c\x51\x41\xcb\xbd\xc2\x83\x4d\x63\x00\xde\xd7\xe0\xdd\x9f\x8c\xdc\x2d\xbf\x0b\x9c\x4b\xde\x7e\xe4\x12\xb7\x87\x6d\x1c\xa7\x55\xa5\xd1\xf7\x8a\xfe\x57\xb4\x20\x4b\x27\x88\xf3\x09\x62\x0e\x67\x1e\x73\x36\x87\x5d\x20\xe7\x30\xc2\x2e\xde\x66\xe6\x65\xd4\xef\x7a\x37\x0a\x7d\xf3\x4b\x80\xb0\x80\x82\xc6\x74\x59\xc2\x70\xf5\x65\x0e\xc3\xd5\x5a\x48\x61\x36\x2e\x3a\x50\x1b\x18\x57\xca\x9f\x8f\x73\x3b\x9c\x56\x11\x10\xb2\xcc\x92\x24\x2a\x03\xf2\x77\x00\xf4\x25\x76\x84\xc8\x04\x00\x00")
+
+func _1528395673_lsif_upload_soft_deleteDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395673_lsif_upload_soft_deleteDownSql,
+		"1528395673_lsif_upload_soft_delete.down.sql",
+	)
+}
+
+func _1528395673_lsif_upload_soft_deleteDownSql() (*asset, error) {
+	bytes, err := _1528395673_lsif_upload_soft_deleteDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395673_lsif_upload_soft_delete.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb1, 0xa1, 0x8, 0x1, 0xe4, 0x41, 0x1c, 0x69, 0x1f, 0x68, 0x56, 0xc5, 0x12, 0x1e, 0x5, 0xe9, 0xda, 0x28, 0xe5, 0x4c, 0x17, 0xc, 0x7b, 0x84, 0xcc, 0x64, 0x36, 0x2b, 0xbe, 0x6f, 0x69, 0x73}}
+	return a, nil
+}
+
+var __1528395673_lsif_upload_soft_deleteUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x54\xdd\x52\xdb\x3c\x14\xbc\xf7\x53\xec\x70\x03\x7c\x13\xf2\x00\xf1\x7c\x17\x26\x11\x34\x33\x89\x43\x1d\x07\xda\x2b\x8f\xb0\x4e\x40\x53\x5b\x72\x25\x99\x9f\x3e\x7d\xc7\x92\xdd\xe0\x02\x9d\xd2\xcb\x23\xf9\xec\xee\x59\xed\xf1\x39\xbb\x5c\xa6\x71\x14\x9d\x9d\x61\x61\x74\x83\x07\x49\x8f\xe0\x4a\x40\x2a\x41\x4f\x10\xd4\x90\x12\xa4\x1c\xb4\x82\xbb\x27\x58\xc7\x1d\xc1\x3d\x37\x14\x2d\xb2\xcd\x15\xae\x97\xec\x06\x95\x95\xfb\x42\xb4\x75\x63\xe3\x70\xba\x4c\x17\xec\x4b\x38\x6e\x9b\x4a\x73\x61\x0b\x43\x8d\xb6\xd2\x69\xf3\x5c\x48\x51\x94\xba\xae\xa5\x2b\x8c\xd6\xae\xf0\x4c\x64\x82\x88\x8c\x4a\x43\x9e\x62\x44\x86\x47\xe9\xee\xbd\x00\x41\x15\x39\xa9\xee\xc2\xe5\x14\xd7\xbc\x6a\xc9\xa2\xe4\x4a\x69\x87\x5b\x02\x17\x82\x44\x07\xe5\x34\xb8\x02\xa9\xb6\x3e\x40\x48\x05\x0e\x67\xb8\xb2\xbc\x74\x52\xab\x09\xac\xf6\xb0\xa5\xae\xda\x5a\x41\x5a\x94\x5a\x3d\x90\x71\x24\x3c\x00\x14\x3d\xfa\xf6\x69\x94\xac\x72\x96\x21\xff\x7a\xc5\x5e\x4e\x56\x78\x1d\xc8\x58\x9a\xac\x19\xf2\xcd\xeb\xbb\x42\x57\x22\x8e\xe6\x19\x4b\x72\xf6\x5e\x7b\xb2\x05\x4b\x77\x6b\x9c\x44\x00\x70\xfc\xbd\xa5\x96\xc4\xf1\x24\x54\x8d\xd1\x25\x59\x2b\xd5\xdd\x70\x52\xea\xba\xa9\xc8\x1d\x3e\x21\x63\xb4\x39\x94\x7b\x2e\xab\x43\x35\x58\x76\x1c\x9d\xc6\xc3\x14\xc9\xf9\x6a\xa4\xc3\x22\x5c\xcc\x37\xab\xdd\x3a\xed\x9d\xf7\x6f\xb9\x60\x17\xc9\x6e\x95\x7f\xac\xf3\x9d\x31\x77\xdb\x65\x7a\x19\xc0\x67\x33\x47\x4f\x6e\x36\x7b\xf5\xd1\xc7\x88\xb6\x2c\x1f\x14\xfe\xb2\xad\x0f\xe1\xdb\x1a\xc2\x6b\x74\xf9\xc8\x0d\x2f\xbf\xf9\xc7\x77\xb2\x26\x1f\xfa\xc6\x48\x6d\x7a\x64\xbd\x87\x37\x8e\x04\x06\xfe\x90\x95\xe7\x2e\x6c\xb8\x25\x18\xb2\xae\x73\xfd\x0f\x82\x17\x8b\x41\x6e\x8f\x55\x70\xe7\xe9\xac\xe3\x75\xd3\xa7\xba\x63\xff\xa1\x15\xc5\x7f\x05\xe4\xd5\x15\xb7\xb4\xd7\x86\x8a\x80\x8a\x37\x4c\x1c\x2d\x93\x5f\x31\x3f\xa1\xdf\x6f\x4f\xdb\x05\x3b\xa4\xde\x0e\xe1\xdc\xa5\xcb\xcf\x3b\xf6\x4f\xdb\x8b\x4d\x3a\xea\x38\x19\x75\x4c\x70\x14\x7a\x8e\x26\xe8\x76\x7e\x82\xbe\xed\x14\x37\x9f\x58\xc6\x7a\xcb\xff\x7f\x99\xec\x37\xa3\xd1\x0b\xfd\xed\xaf\x83\x64\x8b\x2d\x5b\xb1\x79\x8e\x76\xfa\xdf\x04\xed\x74\x2f\x95\xb4\xf7\xc1\x6e\x6e\xd1\x6f\x50\xa8\x2f\xb2\xcd\x7a\xa4\x15\xed\xfb\x2a\xe2\x28\x9a\x6f\xd6\xeb\x65\x1e\x47\x3f\x07\x00\xb2\x28\xe2\xaa\x2b\x05\x00\x00")
+
+func _1528395673_lsif_upload_soft_deleteUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395673_lsif_upload_soft_deleteUpSql,
+		"1528395673_lsif_upload_soft_delete.up.sql",
+	)
+}
+
+func _1528395673_lsif_upload_soft_deleteUpSql() (*asset, error) {
+	bytes, err := _1528395673_lsif_upload_soft_deleteUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395673_lsif_upload_soft_delete.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa9, 0xd2, 0xe3, 0xda, 0x74, 0xba, 0x29, 0x46, 0xb6, 0x84, 0x65, 0xaf, 0x99, 0x9f, 0xd7, 0xe2, 0x8a, 0x30, 0xde, 0xca, 0xb0, 0x39, 0xdd, 0x27, 0x8a, 0xf8, 0xc1, 0x8a, 0x4e, 0x20, 0x6b, 0x33}}
+	return a, nil
+}
+
+var __1528395674_lsif_upload_eventsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x3a\x00\xc5\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x6c\x73\x69\x66\x5f\x75\x70\x6c\x6f\x61\x64\x5f\x65\x76\x65\x6e\x74\x73\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x4b\xbf\x30\x59\x3a\x00\x00\x00")
+
+func _1528395674_lsif_upload_eventsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395674_lsif_upload_eventsDownSql,
+		"1528395674_lsif_upload_events.down.sql",
+	)
+}
+
+func _1528395674_lsif_upload_eventsDownSql() (*asset, error) {
+	bytes, err := _1528395674_lsif_upload_eventsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395674_lsif_upload_events.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x61, 0xe, 0xb3, 0x44, 0xe4, 0x2, 0xbd, 0xbd, 0xda, 0xe7, 0xc4, 0x17, 0xfe, 0x4a, 0xb8, 0x6f, 0x9d, 0x29, 0xb6, 0x2a, 0x65, 0x93, 0xa4, 0xe1, 0x79, 0xc1, 0xd7, 0xb4, 0x33, 0x57, 0xe, 0xaa}}
+	return a, nil
+}
+
+var __1528395674_lsif_upload_eventsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x90\xc1\x8e\xda\x30\x18\x84\xef\x79\x8a\x39\xb2\x12\xbb\x2f\xc0\x29\x5b\x4c\x15\x35\x84\x0a\x82\x04\xa7\xc8\xc4\x13\x62\x35\x89\x91\xfd\x03\x6d\x9f\xbe\x4a\x02\x14\x55\x48\xbd\x65\xf2\x8d\x3f\xcb\xf3\xa9\xbe\x26\xd9\x2c\x8a\xde\xdf\xa1\x2e\xec\x24\x40\x7b\xe2\x07\x4f\x02\x5d\x09\x3d\xa4\x26\xce\xa7\xc6\x69\xd3\x7f\xfe\x82\x61\x28\xbd\x3d\x10\x36\xc0\xb3\x75\x17\x9a\x29\x82\x83\xd4\x5a\xfa\xc6\xf8\x53\x37\xbd\xd1\x4a\x60\x53\xa1\xd4\x1d\x0e\x84\x3e\x1b\x2b\x34\x1f\xc8\x6b\x7a\x56\xce\x73\x7a\x33\x17\xd6\xc0\x38\x06\x74\x4e\xe0\x59\xd1\xb3\x2b\x89\x26\xd8\xaa\x18\x1b\xe1\x23\xfa\xb2\x56\x71\xae\x90\xc7\x9f\xa9\x42\xb2\x40\xb6\xca\xa1\x76\xc9\x26\xdf\x3c\x17\x0b\x8e\xaf\x98\x44\x00\x60\x0d\x02\xbd\xd5\x0d\xbe\xaf\x93\x65\xbc\xde\xe3\x9b\xda\x4f\x07\xf4\xf7\x66\xdb\x09\x8f\xf4\x83\x30\xdb\xa6\xe9\xc8\x07\x0f\x84\x3f\xe5\x1f\x10\x44\x0b\x07\x70\xcb\xee\xec\x4b\xbe\x6a\xea\x52\x9c\x7f\x6a\xb6\x0c\x41\x1f\x9f\xcf\x96\x9e\x5a\x68\x8a\x7e\x3a\xdb\x32\x88\x6e\x4f\xb8\x5a\xa9\x87\x88\xdf\xae\xe3\xc3\x89\xb9\x5a\xc4\xdb\x34\x47\xe7\xae\x93\xb7\xe8\x6d\x16\xdd\x27\x49\xb2\xb9\xda\xfd\x77\x92\x7b\xb2\x06\xab\xec\x05\x9f\x3c\xf8\xa0\x5e\x2d\x97\x49\x3e\x8b\xfe\x0c\x00\x7f\x19\xbc\x50\x20\x02\x00\x00")
+
+func _1528395674_lsif_upload_eventsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395674_lsif_upload_eventsUpSql,
+		"1528395674_lsif_upload_events.up.sql",
+	)
+}
+
+func _1528395674_lsif_upload_eventsUpSql() (*asset, error) {
+	bytes, err := _1528395674_lsif_upload_eventsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395674_lsif_upload_events.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x53, 0x7d, 0xcb, 0xf, 0xa, 0xfe, 0xe4, 0xa7, 0x1f, 0x63, 0xf, 0xa5, 0xfe, 0x39, 0x46, 0xeb, 0xb3, 0xff, 0xcc, 0x3a, 0x7, 0xe7, 0x6f, 0x15, 0xa7, 0x24, 0x68, 0x32, 0x9d, 0x33, 0xe2, 0xb1}}
+	return a, nil
+}
+
+var __1528395675_lsif_uploads_queued_repository_idDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x49\x00\xb6\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x49\x4e\x44\x45\x58\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x6c\x73\x69\x66\x5f\x75\x70\x6c\x6f\x61\x64\x73\x5f\x71\x75\x65\x75\x65\x64\x5f\x72\x65\x70\x6f\x73\x69\x74\x6f\x72\x79\x5f\x69\x64\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\xd6\xcf\xd0\x1a\x49\x00\x00\x00")
+
+func _1528395675_lsif_uploads_queued_repository_idDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395675_lsif_uploads_queued_repository_idDownSql,
+		"1528395675_lsif_uploads_queued_repository_id.down.sql",
+	)
+}
+
+func _1528395675_lsif_uploads_queued_repository_idDownSql() (*asset, error) {
+	bytes, err := _1528395675_lsif_uploads_queued_repository_idDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395675_lsif_uploads_queued_repository_id.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xda, 0x5, 0x44, 0xe4, 0x46, 0x73, 0x18, 0xec, 0xc8, 0x78, 0xc0, 0x9a, 0xb6, 0x7b, 0xc6, 0x68, 0x14, 0x71, 0x25, 0xff, 0x29, 0x9e, 0xf, 0xf4, 0x69, 0x10, 0x5f, 0x21, 0x6e, 0xd0, 0x7d, 0x23}}
+	return a, nil
+}
+
+var __1528395675_lsif_uploads_queued_repository_idUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x54\xcc\xcd\x4a\xc4\x30\x14\xc5\xf1\x7d\x9e\xe2\xec\x46\x17\xe3\x0b\x14\x17\x3a\x46\xcd\x62\x52\x98\x06\x9c\x5d\x08\xcd\xad\x0d\x94\xdc\x34\x1f\x88\x6f\x2f\x56\x61\xe8\xfa\x9c\xff\xef\x59\xbe\x29\xdd\x09\x71\x3c\x62\x68\x29\x71\xae\x05\x23\xb7\x58\x43\xfc\x44\x9d\x09\x6b\xa3\x46\x1e\x2d\x2d\xec\x7c\x01\x4f\x70\xc8\x94\xb8\x84\xca\xf9\x1b\x5f\x33\x45\x50\x9c\x38\x8f\xbf\x05\xc5\xed\x8f\xb5\x71\x75\xe5\x41\x9c\x2e\xf2\xc9\x48\x28\xfd\x22\xaf\x50\xaf\xd0\xbd\x81\xbc\xaa\xc1\x0c\x58\x4a\x98\xec\x3f\x6b\xb7\xca\xdb\x1b\x6c\x83\x47\xaf\x77\xa7\xbb\xdd\x7a\x8f\x8f\x77\x79\x91\x28\xd5\x55\xc2\x23\x0e\x7f\xc4\xa1\x13\xe2\xd4\x9f\xcf\xca\x74\xe2\x67\x00\xdc\x79\xbe\xa1\xdc\x00\x00\x00")
+
+func _1528395675_lsif_uploads_queued_repository_idUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395675_lsif_uploads_queued_repository_idUpSql,
+		"1528395675_lsif_uploads_queued_repository_id.up.sql",
+	)
+}
+
+func _1528395675_lsif_uploads_queued_repository_idUpSql() (*asset, error) {
+	bytes, err := _1528395675_lsif_uploads_queued_repository_idUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395675_lsif_uploads_queued_repository_id.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xdc, 0x67, 0x7, 0x62, 0xa0, 0x51, 0x6b, 0xf1, 0xdd, 0xd, 0xef, 0x3, 0xfc, 0xde, 0xb1, 0x33, 0xd0, 0x35, 0xdd, 0xfb, 0xd7, 0x41, 0x0, 0x4e, 0xab, 0x67, 0x1a, 0x53, 0xfc, 0xf3, 0xac, 0x96}}
+	return a, nil
+}
+
+var __1528395676_lsif_packages_name_trgmDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x3f\x00\xc0\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x49\x4e\x44\x45\x58\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x6c\x73\x69\x66\x5f\x70\x61\x63\x6b\x61\x67\x65\x73\x5f\x6e\x61\x6d\x65\x5f\x74\x72\x67\x6d\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x33\xb0\x3a\xf5\x3f\x00\x00\x00")
+
+func _1528395676_lsif_packages_name_trgmDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395676_lsif_packages_name_trgmDownSql,
+		"1528395676_lsif_packages_name_trgm.down.sql",
+	)
+}
+
+func _1528395676_lsif_packages_name_trgmDownSql() (*asset, error) {
+	bytes, err := _1528395676_lsif_packages_name_trgmDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395676_lsif_packages_name_trgm.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x89, 0x4f, 0xd7, 0x27, 0xc0, 0xe4, 0x5d, 0x27, 0x7a, 0x76, 0xcd, 0x61, 0xb8, 0x87, 0xc2, 0x48, 0x8e, 0x3b, 0x86, 0x8e, 0xa4, 0x8a, 0x3d, 0xd1, 0xce, 0x8a, 0x8d, 0x78, 0x7a, 0x9d, 0x7d, 0xe7}}
+	return a, nil
+}
+
+var __1528395676_lsif_packages_name_trgmUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x54\xcc\xcf\x0e\x82\x20\x00\x06\xf0\x3b\x4f\xf1\x1d\xf5\x60\x2f\xe0\xa9\x8c\x1c\x07\x71\x0b\xda\xbc\x31\x32\x24\x96\x22\x03\x5b\xf9\xf6\xcd\xb6\x0e\x5d\xbf\x3f\xbf\x03\xad\x19\x2f\x09\x29\x0a\x88\x67\x08\x73\x5c\x12\x92\xd1\xb1\xbf\x3b\x6f\x11\x74\xff\xd0\xd6\x24\x5c\x57\x78\x3d\x19\x84\x68\x06\xf7\x86\xf6\x37\x24\x37\xb9\x51\x47\xb7\xac\x3b\x52\x9d\xe9\x5e\x52\x30\x7e\xa4\x1d\xd8\x09\xbc\x95\xa0\x1d\x13\x52\x60\x4c\x6e\x50\x3f\x47\x6d\x88\x5a\xa2\x9d\xd0\xf2\xff\x0a\x17\xc1\x78\x0d\xeb\x3c\xb2\x71\x7e\x99\x98\x6d\xdb\x7c\x0b\xbe\x07\x35\x87\x94\x97\x84\x54\x6d\xd3\x30\x59\x92\xcf\x00\x02\xc6\xaa\x8a\xb9\x00\x00\x00")
+
+func _1528395676_lsif_packages_name_trgmUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395676_lsif_packages_name_trgmUpSql,
+		"1528395676_lsif_packages_name_trgm.up.sql",
+	)
+}
+
+func _1528395676_lsif_packages_name_trgmUpSql() (*asset, error) {
+	bytes, err := _1528395676_lsif_packages_name_trgmUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395676_lsif_packages_name_trgm.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xf5, 0xa1, 0xa1, 0x71, 0x1d, 0xe3, 0x88, 0x5f, 0x80, 0xb6, 0xcc, 0xba, 0x1b, 0x24, 0x7a, 0x7e, 0xc8, 0xb2, 0xaf, 0x69, 0x77, 0x66, 0x61, 0x48, 0xa9, 0x11, 0xc2, 0x74, 0x50, 0xaf, 0x4c, 0xcf}}
+	return a, nil
+}
+
+var __1528395677_lsif_upload_last_accessed_atDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\xce\x41\x4e\xc3\x30\x14\x04\xd0\xbd\x4f\x31\xbb\x4a\x88\xf6\x02\x15\x8b\x34\xfd\x40\xa4\xa4\x41\x6e\xa0\xcb\xc8\xb2\x7f\x55\x4b\x8e\x6d\xc5\x36\xbd\x3e\xa2\x01\x04\xec\x67\xe6\xcd\x8e\x9e\x9a\xc3\x56\x88\xf5\x1a\xfb\x39\x44\xbc\x5b\xbe\xc2\x70\x64\x6f\xd8\x67\x04\x0f\x1d\x5c\x99\xbc\xd8\xcb\xfe\x05\x6f\x0d\x9d\xe0\x92\x3d\x8f\xa6\x4c\x31\xfd\xea\x7d\xa5\xaa\x76\x20\x89\xa1\xda\xb5\xb4\xe4\x4a\x74\x41\x99\x84\x5b\xbd\xee\xdb\xd7\xee\x00\xa7\x52\x1e\x95\xd6\x9c\x12\x9b\x51\xe5\x65\x46\xb2\x9e\x59\x65\x5e\x2e\x5c\x6d\xbe\x84\x92\xbf\xf5\x5a\x52\x35\xd0\x7f\x1f\xd5\x11\x47\x6a\xa9\x1e\x50\x36\x77\xf7\x28\x9b\xb3\xf5\x36\x5d\x6e\xab\x50\x09\x71\x0e\x3f\x0a\x1e\x65\xdf\xfd\x3d\x55\x70\x7a\x26\x49\x48\xf9\xd3\x7d\xc0\x4a\x87\x29\x3a\xce\x6c\x56\x5b\x21\xea\xbe\xeb\x9a\x61\x2b\x3e\x06\x00\xbd\x89\x3f\xba\x23\x01\x00\x00")
+
+func _1528395677_lsif_upload_last_accessed_atDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395677_lsif_upload_last_accessed_atDownSql,
+		"1528395677_lsif_upload_last_accessed_at.down.sql",
+	)
+}
+
+func _1528395677_lsif_upload_last_accessed_atDownSql() (*asset, error) {
+	bytes, err := _1528395677_lsif_upload_last_accessed_atDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395677_lsif_upload_last_accessed_at.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x92, 0xb2, 0x66, 0xff, 0x22, 0xd0, 0x2a, 0xd6, 0xe9, 0x71, 0xfa, 0x8c, 0xc3, 0x40, 0x97, 0x70, 0xb2, 0xe3, 0x8a, 0x8e, 0x4, 0x1c, 0x32, 0x11, 0x5d, 0x26, 0xe2, 0x50, 0xa7, 0x36, 0x5, 0x79}}
+	return a, nil
+}
+
+var __1528395677_lsif_upload_last_accessed_atUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\x8f\xcd\x6e\xe2\x40\x10\x84\xef\xf3\x14\x75\x43\x5a\x2d\xbc\x00\xda\x83\xb1\x67\x77\x91\x6c\x1c\x19\x27\x1c\x51\xe3\x69\x82\x95\xf9\xcb\xfc\xc4\x4a\x9e\x3e\xc2\x3e\x44\xc9\xb1\xa5\xae\xef\xab\xda\xc9\x7f\xfb\xc3\x56\x88\xf5\x1a\x55\x70\x1e\x6f\x23\x4f\x50\xec\xd9\x2a\xb6\x09\xce\x22\xd1\x45\xb3\xa8\xba\xf6\x01\x4f\x7b\x79\x82\x8e\xe3\xf5\xac\xb2\xf1\x71\x89\xf5\x81\x86\x17\xa4\x1b\x43\x53\x4c\x48\xa3\x61\x10\x2e\xd9\x2a\xcd\x30\x64\xe9\x99\x03\xc8\xc6\x89\x03\x2b\x10\x5e\x33\x87\x77\x5c\x83\x33\x73\xe8\x4e\x12\x45\xdd\xcb\x0e\x7d\xb1\xab\xe5\xc2\xcf\x5e\x3b\x52\x11\x45\x55\xa1\x6c\xeb\xc7\xe6\x30\xd3\xcf\x34\x0c\x1c\x23\xab\x33\x2d\xa6\x98\xc8\x78\x4c\x63\xba\xcd\x27\x3e\x9c\xe5\xa5\x56\xc7\x43\x60\x4a\xbc\x2c\x9a\x3f\x2c\x4f\x18\x9c\xce\xc6\x8a\xb2\x93\x45\x2f\x7f\x0e\x42\x71\xc4\x51\xd6\xb2\xec\x91\x37\xbf\x7e\x23\x6f\xae\xa3\x1d\xe3\x6d\xf1\x51\x84\x0f\xee\xcb\xff\xb7\x6b\x9b\xef\x6d\x33\x4e\xff\x65\x27\x11\xd3\x5d\xfc\x07\xab\xc1\x19\xaf\x39\xb1\x5a\x6d\x85\x28\xdb\xa6\xd9\xf7\x5b\xf1\x39\x00\x54\x9d\xf0\x1e\x73\x01\x00\x00")
+
+func _1528395677_lsif_upload_last_accessed_atUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395677_lsif_upload_last_accessed_atUpSql,
+		"1528395677_lsif_upload_last_accessed_at.up.sql",
+	)
+}
+
+func _1528395677_lsif_upload_last_accessed_atUpSql() (*asset, error) {
+	bytes, err := _1528395677_lsif_upload_last_accessed_atUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395677_lsif_upload_last_accessed_at.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x5f, 0x76, 0xf7, 0xe, 0xb1, 0xce, 0xb, 0x61, 0x73, 0x95, 0x4, 0x55, 0x99, 0x5, 0x7f, 0xbd, 0x73, 0x7c, 0xaf, 0x50, 0x42, 0xcd, 0xb, 0x3c, 0x24, 0xd8, 0x50, 0x9a, 0xae, 0xed, 0x8d, 0x78}}
+	return a, nil
+}
+
+var __1528395678_lsif_upload_metadataDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x8f\x41\x4e\xc3\x30\x10\x45\xf7\x3e\xc5\xec\x2a\x21\xda\x0b\x44\x2c\xd2\xd4\x40\xa5\xa4\x41\x6e\xa0\xcb\x28\xb2\xa7\xd4\x28\xf1\x58\x99\x71\x7b\x7d\x54\x2c\x10\xb0\xea\x72\x34\xff\xe9\xff\xb7\xd6\x4f\xdb\x5d\xa1\xd4\x72\x09\x9b\x99\x22\x9c\x3d\x5e\xc0\x61\xc4\xe0\x30\x08\x50\x00\x4b\x63\x9a\x02\xab\x8d\x69\x5f\xe0\x6d\xab\x0f\x30\xb2\x3f\xf6\x2e\x4d\x91\x7f\x81\xdf\xb1\xb2\xee\xb4\x81\xae\x5c\xd7\x3a\x07\x53\x1c\x69\x70\x0c\x5f\x7c\xd5\xd6\xaf\xcd\x2e\x3f\xce\x38\xb3\xa7\x50\xdc\x86\x44\x62\x2f\x9e\x42\x8f\xc1\x92\xf3\xe1\xfd\x56\x6e\xa6\x0f\xb4\xd2\xcf\x44\x92\xe7\x1a\xb4\x33\x0e\x82\xd9\xf5\xe2\xe5\x44\x49\x7e\x34\x2b\xa3\xcb\x4e\xff\x17\x85\x72\x0f\x7b\x5d\xeb\xaa\x83\xb4\xba\xbb\x87\xb4\x3a\xfa\xe0\xf9\x84\xae\x1f\x04\x06\xbe\xd6\x58\x64\xce\xf7\xa3\x69\x9b\xbf\x8b\x12\x1c\x9e\xb5\xd1\xc0\x72\x2d\x7e\x80\x85\xa5\x29\x8e\x28\xe8\x16\x85\x52\x55\xdb\x34\xdb\xae\x50\x9f\x03\x00\x0b\x2a\x45\x3b\x8d\x01\x00\x00")
+
+func _1528395678_lsif_upload_metadataDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395678_lsif_upload_metadataDownSql,
+		"1528395678_lsif_upload_metadata.down.sql",
+	)
+}
+
+func _1528395678_lsif_upload_metadataDownSql() (*asset, error) {
+	bytes, err := _1528395678_lsif_upload_metadataDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395678_lsif_upload_metadata.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xe3, 0x13, 0xf3, 0x62, 0x0, 0xa7, 0xaa, 0xbf, 0x40, 0xa5, 0xe1, 0x3b, 0x24, 0x6f, 0xd2, 0xab, 0x28, 0xca, 0x5e, 0xf6, 0x56, 0xe3, 0xf7, 0x48, 0x96, 0x6a, 0x42, 0x43, 0xe3, 0x56, 0xa8, 0x9a}}
+	return a, nil
+}
+
+var __1528395678_lsif_upload_metadataUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x90\xc1\x6e\xab\x30\x10\x45\xf7\xfe\x8a\xbb\x8b\xf4\xf4\x92\x1f\x40\x5d\x10\x70\xdb\x48\x10\x2a\x42\x9b\x25\x72\xf1\xa4\xb8\x02\x0f\xb2\x87\x24\x9f\x5f\x45\x64\xd3\xae\xda\xe5\x5c\xe9\xcc\x99\xb9\x5b\xfd\xb4\xdb\x27\x4a\xad\xd7\xc8\x03\x4f\x38\x3b\xba\xc0\xd2\x44\xde\x92\x17\xb0\x87\x98\xf7\x81\x54\x5e\x57\x2f\x78\xdb\xe9\x23\x86\xe8\x4e\xad\x9d\xc7\x29\x2e\x58\x4d\x1d\x07\x0b\xe9\x09\x23\x89\xb1\x46\x0c\xce\x14\x84\xae\xe0\x13\xc8\x74\x3d\xe6\x69\x60\x63\x55\x5a\x34\xba\x46\x93\x6e\x0b\xbd\x6c\x59\xf2\x88\x34\xcf\x91\x55\xc5\x6b\xb9\x5f\xf2\x33\x85\xe8\x6e\x6a\xba\x4a\xf2\x2b\x6c\xe2\xe8\xc4\xb1\x6f\xc9\x77\x6c\x9d\xff\xf8\x0b\x1b\xf8\x93\x3a\x69\x03\xb3\xdc\xb1\xfb\x5f\x81\x8c\xd0\x52\xc9\xc5\x49\x0f\x4f\x17\x74\x3c\xcc\xa3\x8f\x2a\xab\x75\xda\xe8\x9f\x95\x20\x3d\xe0\xa0\x0b\x9d\x35\x98\x37\xff\xfe\x63\xde\x9c\x9c\x77\xb1\x27\xdb\x1a\x81\x89\x37\x59\x47\x31\x2e\xf3\x63\x5d\x95\xdf\xcf\x9a\x71\x7c\xd6\xb5\x46\x94\x9b\xf9\x01\xab\x8e\xc7\x69\x20\x21\xbb\x4a\x94\xca\xaa\xb2\xdc\x35\x89\xfa\x1a\x00\xf5\x5a\x46\x4b\xb5\x01\x00\x00")
+
+func _1528395678_lsif_upload_metadataUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395678_lsif_upload_metadataUpSql,
+		"1528395678_lsif_upload_metadata.up.sql",
+	)
+}
+
+func _1528395678_lsif_upload_metadataUpSql() (*asset, error) {
+	bytes, err := _1528395678_lsif_upload_metadataUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395678_lsif_upload_metadata.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x8d, 0x52, 0x29, 0x33, 0xc9, 0x9c, 0x13, 0xdf, 0xb9, 0xff, 0x2f, 0x6c, 0xc6, 0x7a, 0xce, 0x29, 0x37, 0x8, 0x38, 0x6c, 0xf7, 0x4c, 0xb5, 0xd1, 0x45, 0x91, 0x62, 0xa4, 0x43, 0x82, 0xfd, 0x73}}
+	return a, nil
+}
+
+var __1528395679_lsif_upload_formatDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\xce\xd1\x4a\xc3\x30\x14\xc6\xf1\xfb\x3c\xc5\x77\x37\x10\xb7\x17\x28\x5e\x74\xdd\x51\x0b\xed\x2a\x59\x75\x97\x23\x34\xa7\x2c\xd0\x26\xa1\x27\x71\xaf\x2f\x5a\x14\xf5\xf2\xc0\xf7\xe7\x77\xf6\xf4\x54\x1f\x0b\xa5\xb6\x5b\x1c\x96\x10\xf1\xee\xf8\x06\xcb\x91\xbd\x65\x9f\x10\x3c\x86\x30\xe5\xd9\x8b\x3a\xe8\xee\x05\x6f\x35\x9d\x31\x89\x1b\x2f\x36\xcf\x51\x7e\x85\xdf\xb3\xb2\xe9\x49\xa3\x2f\xf7\x0d\xad\xc3\x1c\xa7\x60\xac\xe0\xab\xaf\xba\xe6\xb5\x3d\x62\x0c\xcb\x6c\xd2\x5a\x6b\x1e\x16\x36\x89\x57\xfa\xe6\xd2\x35\xe4\xf4\xa3\x56\x9a\xca\x9e\xfe\xbb\x28\x4f\x38\x51\x43\x55\x8f\xbc\xbb\xbb\x47\xde\x8d\xce\x3b\xb9\xb2\xbd\x98\x04\x23\x88\x4b\x18\x58\x64\xbd\x1f\x75\xd7\xfe\xfd\x25\xe3\xfc\x4c\x9a\x20\xe9\x13\x7e\xc0\x66\x08\x73\x9c\x38\xb1\xdd\x14\x4a\x55\x5d\xdb\xd6\x7d\xa1\x3e\x06\x00\x76\xb8\x75\x20\x1c\x01\x00\x00")
+
+func _1528395679_lsif_upload_formatDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395679_lsif_upload_formatDownSql,
+		"1528395679_lsif_upload_format.down.sql",
+	)
+}
+
+func _1528395679_lsif_upload_formatDownSql() (*asset, error) {
+	bytes, err := _1528395679_lsif_upload_formatDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395679_lsif_upload_format.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xf7, 0xef, 0x88, 0x2, 0x32, 0x81, 0x81, 0x74, 0xc2, 0xd3, 0x59, 0x1a, 0x24, 0x81, 0xe4, 0x2b, 0xe5, 0xbb, 0xb0, 0xaa, 0x67, 0x97, 0x42, 0xa6, 0xaf, 0x76, 0x70, 0xf2, 0xad, 0xcc, 0xdb, 0xcc}}
+	return a, nil
+}
+
+var __1528395679_lsif_upload_formatUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\x8e\xc1\x6a\xeb\x30\x10\x45\xf7\xfe\x8a\xbb\x0b\x3c\x5e\xf2\x03\xa1\x0b\xc7\x56\xda\x80\x6c\x17\x45\x69\x96\x41\xb5\xc6\x58\x60\x4b\xc2\x1a\x35\xfd\xfc\x12\x4c\x0b\xed\x72\x06\xce\x3d\xe7\x20\x9e\x4f\xed\xbe\x28\xb6\x5b\xd4\x4b\x88\xf8\x70\x74\x87\xa5\x48\xde\x92\x67\x04\x0f\x36\xef\x13\x15\xb5\xea\x5e\xf1\x76\x12\x57\x4c\xc9\x0d\x37\x9b\xe7\x98\x56\x4c\x51\x1f\x16\x0b\x1e\x09\xce\xc7\xcc\x18\xc2\x32\x1b\x46\x18\x40\xa6\x1f\x91\xe3\x14\x8c\x2d\x4a\xa9\x85\x82\x2e\x0f\x52\xac\x13\xeb\x3f\xa1\xac\x6b\x54\x9d\xbc\x34\xed\x37\xc9\xf4\xc9\x68\x3b\x8d\xf6\x22\x25\x6a\x71\x2c\x2f\x52\x63\xf3\xa0\x36\x3f\xce\x85\x0c\xd3\x9a\x7b\x77\x3c\xc2\xd3\x1d\x7d\x98\xf2\xec\x53\x51\x29\x51\x6a\xf1\x37\x17\xe5\x19\x67\x21\x45\xa5\x91\x77\xff\xfe\x23\xef\x06\xe7\x5d\x1a\xc9\xde\x0c\xc3\x24\xc4\x25\xf4\x94\xd2\x7a\x1f\x55\xd7\xfc\x0e\xcd\xb8\xbe\x08\x25\x90\xf8\x61\x7e\xc2\xa6\x0f\x73\x9c\x88\xc9\x3e\xaa\xaa\xae\x69\x4e\x7a\x5f\x7c\x0d\x00\x3e\xb9\xb7\x8f\x51\x01\x00\x00")
+
+func _1528395679_lsif_upload_formatUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395679_lsif_upload_formatUpSql,
+		"1528395679_lsif_upload_format.up.sql",
+	)
+}
+
+func _1528395679_lsif_upload_formatUpSql() (*asset, error) {
+	bytes, err := _1528395679_lsif_upload_formatUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395679_lsif_upload_format.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x7f, 0x9e, 0x20, 0xe, 0x2e, 0xc9, 0x2f, 0x69, 0xe, 0x6a, 0xbf, 0x20, 0xc7, 0x6c, 0x8d, 0x97, 0x63, 0xf4, 0x9c, 0x67, 0xe0, 0x57, 0x86, 0x52, 0x93, 0x5e, 0x2c, 0x9, 0x33, 0xcf, 0xbc, 0x80}}
+	return a, nil
+}
+
+var __1528395680_lsif_nearest_uploadsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\xce\xb1\x0a\xc3\x20\x14\x85\xe1\xdd\xa7\x70\x6c\x9f\xc1\xa9\x49\x4d\xb8\xd0\x68\x31\x16\xba\x89\xd4\x5b\x10\x8c\x09\xd1\xf4\xf9\xbb\x24\x43\x85\x42\x86\x76\xff\xcf\xc7\xa9\x78\x0b\x82\x11\x72\x56\xf2\x4a\xb5\x82\xb6\xe5\x8a\x42\x43\xf9\x1d\x7a\xdd\xd3\x90\xfc\xd3\x2c\x53\x18\xad\x4b\xc6\x61\xc0\x8c\xc6\xc7\x97\x0d\xde\xd9\x8c\x26\xa2\x9d\x31\xe5\xad\xa0\x52\x7c\x2c\xd8\x2e\x76\x99\x9c\xfd\x03\xeb\x63\xc2\x39\xff\x8c\x7d\x8c\xc3\xe0\x73\xda\xe3\xad\xe9\xea\x35\x37\x51\x6b\x90\xa2\x04\xbf\x43\x87\xe3\x76\xe5\x54\x5d\x78\xb9\x2b\x62\x46\x48\x2d\xbb\x0e\x34\x23\xef\x01\x00\x0e\xba\x69\xb7\xcc\x01\x00\x00")
+
+func _1528395680_lsif_nearest_uploadsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395680_lsif_nearest_uploadsDownSql,
+		"1528395680_lsif_nearest_uploads.down.sql",
+	)
+}
+
+func _1528395680_lsif_nearest_uploadsDownSql() (*asset, error) {
+	bytes, err := _1528395680_lsif_nearest_uploadsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395680_lsif_nearest_uploads.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb2, 0x7d, 0x51, 0x36, 0xb9, 0xd7, 0x4c, 0x19, 0x30, 0x55, 0xb2, 0x74, 0x69, 0xb1, 0xe3, 0x56, 0xa6, 0x5f, 0x17, 0x39, 0x94, 0x64, 0x4f, 0x5e, 0x35, 0x1e, 0xac, 0x70, 0xf0, 0x3d, 0x15, 0x67}}
+	return a, nil
+}
+
+var __1528395680_lsif_nearest_uploadsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x53\xdb\x6e\x9b\x40\x10\x7d\xe7\x2b\x8e\x22\x4b\xb1\x25\xa7\x3f\x80\xf2\x40\xcc\x60\xa3\xda\x8b\xb5\x01\xb9\x51\x55\x59\x14\x36\xb0\x12\x06\xb2\xbb\x4e\xd2\xbf\xaf\x16\x6c\xab\x76\xed\x26\x4a\x5b\x89\xa7\xd9\x99\x33\xe7\x32\xdc\xd1\x34\x64\xae\xe3\xdc\xdc\x60\x92\x66\xa5\x80\x29\x05\xf2\xed\xa6\xd5\x78\x96\x5a\x7e\xaf\x04\x1e\x55\xb3\x81\x48\xb3\x12\x4f\x5b\xa1\xa4\xc8\x91\x35\x9b\x8d\x34\x48\xab\xa6\x2e\xf0\x22\x4d\x69\xa7\xa4\xda\xd7\x73\xa9\x4d\x5a\x67\xc2\x99\x70\xf2\x62\x42\xec\xdd\xcd\x09\x95\x96\x8f\xeb\x5a\xa4\x4a\x68\xb3\xde\xb6\x55\x93\xe6\x1a\x43\x07\x00\x94\x68\x1b\x2d\x4d\xa3\x7e\xac\x65\x0e\x59\x1b\x51\x08\x05\x16\xc5\x60\xc9\x7c\x3e\xee\x7a\xae\x7a\xf0\x2b\x18\xf1\x6a\x4e\xde\x7a\xb4\xb5\xcc\xf5\x7e\xf8\xeb\xb7\x93\x96\x3d\xa7\xcb\x1d\x4b\x1e\x2e\x3c\xfe\x80\xcf\xf4\x80\xe1\x11\xa3\xf1\x61\xf9\xc8\x19\xf5\x5e\xf9\xaa\x69\xad\x68\x64\xd6\xb4\xbd\x23\x1a\xcd\x23\xd2\x5f\xe4\xe0\xa5\x14\x35\xec\xc3\xce\x9a\x42\xa5\x6d\x89\x46\x75\xb5\xde\xe6\xac\x4c\xeb\xe2\xe0\x55\x90\xb0\x49\x1c\x46\xac\xb7\x4b\xd6\xcf\x69\x25\xf3\xd4\x88\x53\xe7\x86\x23\x70\x8a\x13\xce\xee\x61\x94\x2c\xac\x5f\xde\x3d\x06\x03\xa7\x0b\xb4\x93\x1c\x06\x88\xa7\xeb\x68\x89\x5b\x5c\xfb\x34\xa7\x98\xae\x11\xcf\xa8\x7f\xb4\x5f\x5f\x44\xc0\xa3\xc5\xf9\x74\x56\x33\xe2\x74\x92\xce\x2d\xa2\xb9\xff\xe9\xa8\xe6\x1e\x10\x7b\x4a\xb6\xa3\xaf\x11\xf3\x11\x06\xae\xe3\xfc\xc5\x3a\x46\xab\x73\xeb\x76\xab\x18\xad\x5c\x87\x98\xef\x3a\x83\x01\xe6\x1e\x9b\x26\xde\x94\xd0\x56\x6d\xa1\x9f\x2a\xd7\x39\x9c\x20\x0f\xa7\x53\xe2\xfd\xde\x5d\x58\x7f\x70\x17\x5e\x10\x13\x47\xc8\xee\x89\xc7\x88\xf8\x9e\x7b\xc4\x8e\x10\x3a\x59\x41\xc4\x41\xde\x64\x06\x1e\xad\x40\x5f\x68\x92\xc4\x84\x25\x8f\x26\xe4\x27\x9c\xde\xce\xf1\x02\xc9\xdd\xfb\x5a\xd6\x5a\x28\xf3\x7e\xae\xec\x68\xfc\x77\x86\xab\x19\x31\x0c\xad\xa9\xda\xa4\x46\xd8\xe3\xc8\x9a\x4d\x5b\x09\x23\xf2\xeb\xd1\x7f\x10\xb0\x6d\xbb\xb1\xcb\x08\x1d\xc7\x5e\x44\xb2\xf4\x2d\x4e\x14\xa0\x23\x37\x86\x78\xcd\xaa\x6d\x2e\xf2\x31\x2e\xfc\x91\x63\xa8\xa6\x31\x63\xc8\x3a\x17\xaf\x42\xbd\x57\xbf\xbd\xe1\x33\xfa\x6d\xd4\x97\xac\xe9\xa0\xfe\xbd\x3d\xb9\xb0\xd6\xbf\x9d\xef\xc9\x05\x7e\x4c\xdf\x87\xf3\x8d\x16\x8b\x30\x76\x9d\x9f\x03\x00\x87\xbf\x65\x2f\x2e\x06\x00\x00")
+
+func _1528395680_lsif_nearest_uploadsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395680_lsif_nearest_uploadsUpSql,
+		"1528395680_lsif_nearest_uploads.up.sql",
+	)
+}
+
+func _1528395680_lsif_nearest_uploadsUpSql() (*asset, error) {
+	bytes, err := _1528395680_lsif_nearest_uploadsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395680_lsif_nearest_uploads.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x89, 0x74, 0xce, 0xba, 0x39, 0x85, 0x84, 0xa9, 0x46, 0x3, 0x4d, 0x75, 0x1d, 0x90, 0x76, 0x77, 0x48, 0x75, 0x64, 0x7, 0x18, 0x62, 0x96, 0xd6, 0x72, 0xa2, 0xf2, 0xfd, 0xd7, 0x2e, 0x15, 0x10}}
+	return a, nil
+}
+
+var __1528395681_lsif_upload_progressDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8d\xce\xc1\xaa\xc2\x30\x10\x05\xd0\x7d\xbf\x62\x76\x82\xa8\x3f\x20\x2e\x6a\x1d\xb5\xd0\x5a\x89\x51\x97\x12\x9a\x51\x03\x6d\x12\x9a\x44\x7f\xff\xc5\x57\x14\x75\xe5\x66\x60\xe0\x5e\xee\x99\xe3\x2a\xdf\x4c\x93\x64\x3c\x86\x45\x67\x2c\xdc\x14\xdd\x41\x92\x25\x2d\x49\x7b\x30\x1a\x6a\xd3\x84\x56\xbb\x64\xc1\xaa\x2d\x1c\x72\x3c\x42\xe3\xd4\xf9\x24\x43\x6b\xdd\x5b\xf1\x19\x4b\x0b\x8e\x0c\x78\x3a\x2f\xb0\x0f\x06\xdb\x18\x21\x1d\xfc\xf7\xb3\xaa\xd8\x97\x1b\xb0\x9d\xb9\x74\xe4\x62\xff\xa7\xb8\xf3\xe2\x42\xfd\x16\xa3\xba\x23\xe1\xa9\x87\xde\x95\xbf\x9a\xe0\x5f\xe3\x19\xc3\x94\xe3\xb7\x12\xd2\x1d\xec\xb0\xc0\x8c\x43\x98\x0c\x47\xf1\x9c\x95\x56\xee\x4a\xf2\x24\x3c\x08\xf7\xe0\xd4\x51\xd3\xff\x4b\x56\x95\x9f\x94\x00\xc7\x35\x32\x7c\x30\xe2\xf0\x0c\x06\xb5\x69\x6d\x43\x9e\xe4\x20\xa2\xb2\xaa\x2c\x73\x3e\x4d\xfe\x00\x0d\xaa\x38\x0c\x4a\x01\x00\x00")
+
+func _1528395681_lsif_upload_progressDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395681_lsif_upload_progressDownSql,
+		"1528395681_lsif_upload_progress.down.sql",
+	)
+}
+
+func _1528395681_lsif_upload_progressDownSql() (*asset, error) {
+	bytes, err := _1528395681_lsif_upload_progressDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395681_lsif_upload_progress.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x5, 0xa8, 0x8e, 0x9a, 0x38, 0xd5, 0x3c, 0xc1, 0x2b, 0x43, 0x89, 0x24, 0xad, 0x5, 0xc0, 0x7e, 0x39, 0x8c, 0x8a, 0xf, 0x1e, 0xe1, 0x89, 0xe9, 0x86, 0x63, 0x19, 0xd8, 0xf6, 0xb3, 0x53, 0x3d}}
+	return a, nil
+}
+
+var __1528395681_lsif_upload_progressUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8d\x90\x51\x4f\xc2\x30\x14\x85\xdf\xf7\x2b\xce\x1b\x6a\xc4\xe0\x33\x62\x32\xb6\x22\xc4\x6e\x33\xa3\xc8\x23\xa9\xeb\x1d\x5b\x32\xda\xa5\xed\xc0\x9f\x6f\xcd\x12\x8c\x3e\xf9\x72\xd3\xdb\x9c\xd3\xf3\xf5\x2c\xd9\xcb\x26\x9f\x47\xd1\x74\x8a\xd4\x9a\x1e\xe7\x96\x2e\x50\xd4\x93\x56\xa4\x3d\x8c\x86\x97\x1f\x1d\x45\x69\x59\xbc\xe1\x7d\xc3\xf6\xe8\x5c\x5b\x1f\xd4\x70\xea\xdd\x68\x2b\xa9\x32\x56\xa1\x31\x17\xd4\xd2\xc2\x37\x84\xca\xe8\x33\x59\xd7\x06\xb7\xa9\x41\xb2\x6a\x30\xf4\x9d\x91\x41\x25\x1d\xa4\x3a\x4b\x5d\x91\x8a\x62\x2e\x58\x09\x11\x2f\x39\x1b\x5f\x1d\x45\x0e\x71\x9a\x22\x29\xf8\x2e\xcb\xd1\x5b\x73\xb4\xe4\x1c\x5a\xed\xe9\x48\x16\x79\x21\x90\xef\x38\x47\xca\x56\xf1\x8e\x0b\xcc\x90\xac\x59\xf2\x8a\x9b\xab\xf4\x79\x11\x2e\xe3\x3c\xfd\x31\x3f\x2d\xf0\x38\x9b\xdd\xce\xff\x15\xe9\xbc\x3c\x12\x3c\x7d\xfa\xeb\x07\x2d\x49\x4f\x63\x37\x97\xd6\x37\xd0\xe1\x50\x99\x6e\x38\x69\x17\x25\x25\x8b\x05\xfb\xdb\x0d\xe2\x2d\xb6\x8c\xb3\x44\x60\x78\xb8\xbb\x0f\xa3\x6e\x75\xeb\x1a\x52\x07\xe9\x11\x5a\x08\x6c\x55\x40\x1b\xf7\x55\x59\x64\xbf\x79\x06\xec\xd7\xac\x64\xdf\x30\x21\x79\x81\x49\x65\x4e\x7d\x47\x9e\xd4\x24\x50\x25\x45\x96\x6d\xc4\x3c\xfa\x02\x01\xc9\xc8\x4c\xbe\x01\x00\x00")
+
+func _1528395681_lsif_upload_progressUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395681_lsif_upload_progressUpSql,
+		"1528395681_lsif_upload_progress.up.sql",
+	)
+}
+
+func _1528395681_lsif_upload_progressUpSql() (*asset, error) {
+	bytes, err := _1528395681_lsif_upload_progressUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395681_lsif_upload_progress.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x74, 0xe2, 0x8a, 0xd4, 0xf5, 0xfa, 0xd8, 0x49, 0x27, 0x93, 0x8e, 0xb, 0xb6, 0xb4, 0x34, 0xd5, 0xad, 0x7b, 0xd7, 0x4f, 0x95, 0xb6, 0x1d, 0x1b, 0xb9, 0xb, 0xb1, 0xe5, 0xfa, 0xcb, 0xa4, 0xb9}}
+	return a, nil
+}
+
+var __1528395682_lsif_upload_state_notificationsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x09\xf2\x74\x77\x77\x0d\x52\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\x29\xce\x4c\x8b\x2f\x2d\xc8\xc9\x4f\x4c\x29\x8e\x4f\x49\xcd\x49\x2d\x49\x8d\xcf\xcb\x2f\xc9\x4c\xab\x8c\x2f\x2e\x49\x2c\x49\x55\xf0\xf7\x43\x51\x63\x4d\x94\x41\xa5\x05\x29\x89\xc4\x19\xe4\x16\xea\xe7\x1c\xe2\x09\x94\xc3\x61\x12\xb2\x11\x1a\x9a\x40\x7f\x38\xfb\xfb\xfa\x7a\x86\x58\x73\x01\x00\x9c\xa0\x24\x79\xd8\x00\x00\x00")
+
+func _1528395682_lsif_upload_state_notificationsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395682_lsif_upload_state_notificationsDownSql,
+		"1528395682_lsif_upload_state_notifications.down.sql",
+	)
+}
+
+func _1528395682_lsif_upload_state_notificationsDownSql() (*asset, error) {
+	bytes, err := _1528395682_lsif_upload_state_notificationsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395682_lsif_upload_state_notifications.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x2c, 0xb5, 0xcb, 0x7c, 0x8a, 0xa6, 0xe3, 0x13, 0x90, 0x80, 0xa, 0x1e, 0x38, 0x9, 0x79, 0xca, 0x5b, 0x65, 0x2b, 0x45, 0x60, 0x25, 0xe7, 0xb6, 0x9d, 0x5e, 0x62, 0x99, 0xf7, 0xdb, 0xd2, 0xd5}}
+	return a, nil
+}
+
+var __1528395682_lsif_upload_state_notificationsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x95\x91\xdd\x6e\x82\x40\x10\x85\xef\x79\x8a\x73\x61\x82\x26\xb5\x0f\x20\xe9\x05\xc2\x80\x9b\x08\x6b\x96\x25\xf6\x8e\x90\xb2\x22\x09\x05\x0a\xd8\x9f\xb7\xef\x22\xa6\xad\xb6\xa9\x71\x2f\xe7\xec\x7c\x73\xe6\xcc\x92\x7c\x16\x5a\x86\x31\x9f\x23\x52\x55\x86\x7e\xaf\x50\x64\xaa\xea\x8b\x5d\xa1\x5a\xd4\x3b\xa4\x15\x0e\x4d\x59\xa7\x19\xea\xea\x28\x97\x5d\xb1\x4b\xc6\x52\xd2\xf5\x69\xaf\xf0\xb4\x4f\xab\x4a\x95\x78\xdb\xab\x0a\x45\xdf\xe1\xbb\x9c\xab\x0e\x75\xab\x8b\x28\x3a\xb4\xea\xb9\x7e\x55\x99\xe1\x08\xb2\x25\xc1\x8b\x43\x47\x32\x1e\xfe\x24\x76\x49\x55\xeb\xd9\x1f\x23\x79\x3a\x83\x20\x19\x8b\x30\x42\xdf\x16\x79\xae\x1d\xd9\x11\x26\x13\x63\x39\xd8\x36\xa0\x1f\xf3\x20\xfd\x84\x6f\xf0\x00\xd3\xa5\x35\x49\x32\x21\x57\x34\x8a\xc3\xdb\x90\xf0\xb8\x08\xd0\xe4\x27\xf4\xd4\xfc\xb5\x81\x79\x07\xbe\x76\xef\x8b\x6c\xb1\xe8\xd5\x7b\x3f\xb3\xbe\xba\xc7\xf1\x83\x3a\xd6\x28\x74\xf5\x48\x1d\xd8\x0d\xe8\x90\xb6\x97\xe8\x13\x56\x2b\x96\xa1\x91\x96\x31\x99\x60\x6d\x87\x7e\x6c\xfb\x84\xa6\x6c\xf2\xee\xa5\xd4\x43\x4e\x41\x49\xc1\x7c\x9f\xc4\x79\x4e\x87\x26\xd3\xf8\xb3\xb8\x60\x7b\x52\x7f\x8b\x37\xee\xd0\xc5\xbd\xd3\x1d\x2e\x12\x3e\x1a\xd0\xbe\x41\xb6\xb3\x82\xe0\x5b\x6c\x75\x5e\x98\x0e\x09\x8c\x0d\x2c\x82\xcb\x22\xc9\xf4\x79\xe0\x09\x1e\x1c\x37\x38\x4a\x33\xd0\x23\x39\xb1\xa6\x6f\x04\x77\xc8\x8d\x05\xfd\x77\xbd\x2b\x2b\x64\xaa\x54\x7f\xaf\x30\x5e\xf2\xba\xf3\x9b\xdd\xf0\x20\x60\xd2\x32\x3e\x01\x11\x00\x7e\x9d\xf7\x02\x00\x00")
+
+func _1528395682_lsif_upload_state_notificationsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395682_lsif_upload_state_notificationsUpSql,
+		"1528395682_lsif_upload_state_notifications.up.sql",
+	)
+}
+
+func _1528395682_lsif_upload_state_notificationsUpSql() (*asset, error) {
+	bytes, err := _1528395682_lsif_upload_state_notificationsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395682_lsif_upload_state_notifications.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xdf, 0x97, 0x17, 0xf4, 0x7f, 0x43, 0x8a, 0xdf, 0x2f, 0x21, 0xec, 0x45, 0xed, 0x31, 0x22, 0xc5, 0x47, 0xd6, 0xcc, 0x87, 0x2, 0x87, 0xac, 0x0, 0x8e, 0x63, 0x27, 0x54, 0xaa, 0x40, 0xc9, 0x84}}
+	return a, nil
+}
+
+var __1528395683_lsif_upload_queued_notificationsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\x09\xf2\x0f\x50\x08\x09\xf2\x74\x77\x77\x0d\x52\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\xc8\x29\xce\x4c\x8b\x2f\x2d\xc8\xc9\x4f\x4c\x29\x06\xd2\x29\x89\x25\xa9\xf1\x79\xf9\x25\x99\x69\x95\xf1\x85\xa5\xa9\xa5\xa9\x29\x0a\xfe\x7e\x28\x8a\xac\x89\x32\x29\x33\xaf\x38\xb5\xa8\x84\x28\x93\xdc\x42\xfd\x9c\x43\x3c\x81\x72\x38\x8c\x42\x31\x43\x43\x13\xe8\x15\x67\x7f\x5f\x5f\xcf\x10\x6b\x2e\x00\xf0\xc6\x5e\xbc\xdb\x00\x00\x00")
+
+func _1528395683_lsif_upload_queued_notificationsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395683_lsif_upload_queued_notificationsDownSql,
+		"1528395683_lsif_upload_queued_notifications.down.sql",
+	)
+}
+
+func _1528395683_lsif_upload_queued_notificationsDownSql() (*asset, error) {
+	bytes, err := _1528395683_lsif_upload_queued_notificationsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395683_lsif_upload_queued_notifications.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x6b, 0xd4, 0x4e, 0x35, 0x17, 0x7c, 0x95, 0xde, 0x4d, 0x8e, 0xd7, 0xda, 0x84, 0xd, 0x61, 0x99, 0xe2, 0x16, 0xc, 0xf5, 0xc, 0x3d, 0x30, 0xe7, 0xf2, 0x5e, 0x3b, 0xb0, 0xf5, 0xb3, 0x95, 0xe}}
+	return a, nil
+}
+
+var __1528395683_lsif_upload_queued_notificationsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xad\xd0\x51\x6b\x83\x30\x10\x07\xf0\xf7\x7c\x8a\xff\x40\x68\x0b\xeb\x3e\x40\x65\x0f\x4e\x4f\x2b\xb4\xb1\x44\xa5\x7b\x2b\x32\x53\x1b\x90\x68\x35\xb2\xed\xdb\x4f\xab\xb0\x75\x1b\xdb\x1e\x96\xa7\x90\xcb\xdd\xfd\xee\x1e\x28\x08\xb9\xcd\xd8\x72\x89\x58\xea\x1c\xe6\x24\xa1\x72\xa9\x8d\x3a\x2a\xd9\xa0\x3a\x22\xd3\xe8\xea\xb2\xca\x72\x54\xfa\x12\x2e\x5b\x75\x3c\x8c\x4f\x87\x73\x27\x3b\x99\xe3\xe9\x94\x69\x2d\x4b\x3c\x9f\xa4\x86\x32\x50\x2d\xa6\x48\xd5\xa0\x91\xe3\x9d\xb9\x82\x9c\x84\xe0\xa7\xdc\x4d\xc2\x88\x7f\x2c\xd4\x1e\x74\xd5\xb7\x7c\x9d\x0a\xce\x17\x10\x94\xa4\x82\xc7\x30\x8d\x2a\x8a\x5e\xe2\xc4\xb0\x2c\xf6\x30\x70\x19\xfa\xb3\x23\xe1\x47\x62\x8b\xba\x98\x52\xe7\xb3\xaf\xb0\xd9\x2d\x38\xed\xef\x54\xbe\x5a\x19\xf9\x62\x16\xf6\x25\x75\x2c\x3d\x44\x6c\x46\xdc\xb3\x99\x65\x61\xe3\xf0\x20\x75\x02\x42\x5d\xd6\x45\x7b\x2e\xfb\x95\x4c\xdc\x44\x84\x41\x40\xe2\x5a\xab\x74\x2b\x1b\x73\x8d\x86\xe3\x27\xfd\xbf\x90\xc7\x24\x12\x7c\x9a\xef\xd2\xb8\x07\x83\x1c\x77\x0d\x11\xed\xb1\x5f\x13\xc7\x7c\xe0\xb5\x26\x33\x12\xf7\x98\x4d\xe8\x05\xe8\x91\xdc\xb4\xef\xbd\x13\x91\x4b\x5e\x2a\xe8\xc7\x5d\xfd\x62\xed\xea\xbc\xaf\xff\xad\x35\xdd\x79\x43\x5a\xe4\x63\x34\xfc\x11\x1d\x6d\xbc\x09\x7d\xf3\xae\x86\xc3\x3d\xfc\xd7\x38\xd1\x76\x1b\x26\x36\x7b\x03\xd1\xa2\x61\x54\x9e\x02\x00\x00")
+
+func _1528395683_lsif_upload_queued_notificationsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395683_lsif_upload_queued_notificationsUpSql,
+		"1528395683_lsif_upload_queued_notifications.up.sql",
+	)
+}
+
+func _1528395683_lsif_upload_queued_notificationsUpSql() (*asset, error) {
+	bytes, err := _1528395683_lsif_upload_queued_notificationsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395683_lsif_upload_queued_notifications.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xdf, 0x5c, 0xce, 0x19, 0x7a, 0x2a, 0x46, 0x34, 0x88, 0xf0, 0x42, 0x7e, 0x49, 0xe0, 0xa, 0x33, 0xef, 0x9, 0x50, 0xaa, 0x2b, 0xc, 0xc, 0x17, 0x63, 0xe0, 0xbf, 0xfa, 0x5f, 0x4b, 0x2, 0x35}}
+	return a, nil
+}
+
+var __1528395684_lsif_upload_heartbeatsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8d\x8e\x41\x4e\xc3\x30\x10\x45\xf7\x39\xc5\xec\x2a\x55\x94\x0b\x44\x5d\xa4\xe9\x00\x91\x92\x06\xb9\x86\x2e\x23\x13\x4f\x15\x0b\x27\xb6\x3c\x36\xb9\x3e\x81\x08\x04\xac\xd8\x8c\xf4\xa5\xf7\xe7\xbf\x03\xde\x57\xa7\x3c\xcb\x76\x3b\x38\x06\xe7\xe1\xcd\xd0\x0c\x9a\x3c\x4d\x9a\xa6\x08\x6e\x82\xde\xd9\x34\x4e\x9c\x1d\x45\xfb\x08\xcf\x15\x5e\xc0\xb2\xb9\x76\x3a\x8d\x9e\x7f\x14\xbf\xb0\xa2\x96\x28\x40\x16\x87\x1a\x57\x30\x79\xeb\x94\x66\xf8\xec\x97\x6d\xfd\xd4\x9c\x60\x76\xe1\x95\x42\x67\x74\xfe\x3f\xde\x2a\x8e\xdd\x40\x2a\xc4\x17\x52\xb1\x53\x71\x1d\x16\xd4\x87\x25\xd3\x6a\x3d\x9b\x38\xb8\x14\xbf\x4d\x4a\x81\x85\xc4\xbf\xca\x50\x9c\xe1\x8c\x35\x96\x12\xd2\xed\xf6\x66\x39\x57\x33\x19\x1e\x48\x2f\x6f\x41\x31\xf8\xe0\x7a\x62\x5e\xf3\x9d\x68\x9b\xdf\x5a\x09\x2e\x0f\x28\x10\x38\x7e\x0c\xef\x61\xd3\xbb\xd1\x5b\x8a\xa4\x37\x8b\x54\xd9\x36\x4d\x25\xf3\xec\x1d\xcc\x46\x22\xd2\x57\x01\x00\x00")
+
+func _1528395684_lsif_upload_heartbeatsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395684_lsif_upload_heartbeatsDownSql,
+		"1528395684_lsif_upload_heartbeats.down.sql",
+	)
+}
+
+func _1528395684_lsif_upload_heartbeatsDownSql() (*asset, error) {
+	bytes, err := _1528395684_lsif_upload_heartbeatsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395684_lsif_upload_heartbeats.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x3d, 0x94, 0x2b, 0x43, 0x56, 0xe9, 0x40, 0xa, 0x6d, 0x3c, 0x54, 0xb3, 0xd1, 0x81, 0xd8, 0x6a, 0xbf, 0x67, 0xc0, 0xcd, 0x2c, 0x28, 0x5f, 0xb5, 0xfb, 0xfc, 0x83, 0x81, 0xdd, 0xf5, 0x39, 0xfd}}
+	return a, nil
+}
+
+var __1528395684_lsif_upload_heartbeatsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8d\x90\xd1\x4e\xc3\x20\x18\x85\xef\xfb\x14\xe7\x6e\x89\x71\xbe\xc0\xe2\x45\xd7\xa2\x2e\x69\x57\xd3\x55\x77\xd9\xb0\xf2\xcf\x12\x29\x10\xa0\xab\xf1\xe9\xa5\xab\x31\xd1\x2b\x6f\x08\x3f\x9c\xc3\x77\x38\x5b\xf6\xb8\xdb\x6f\x92\x64\xbd\x46\xee\x8c\xc5\x45\xd2\x04\x41\x96\xb4\x20\x1d\x60\x34\x02\x3f\x29\x4a\xf2\xba\x7a\xc6\xeb\x8e\x1d\xa1\xbc\x3c\xb7\x62\x1c\xac\x5f\x6c\x35\x75\xc6\x09\x4c\xbd\xec\x7a\x4c\xc6\xbd\x93\x83\xf4\xe8\x8c\xbe\x90\x0b\x52\xbf\x81\x78\xbc\x19\xad\x32\x5c\x80\xeb\x59\x4a\x1a\x32\x40\x71\x1f\xe0\xc8\x1a\x17\x48\x20\xf4\x3c\xcc\xa7\xd1\xcb\x95\xbc\x50\x92\x16\x0d\xab\xd1\xa4\xdb\x82\x2d\xd0\xe5\x09\x8f\x34\xcf\x91\x55\xc5\x4b\xb9\xff\xe6\xb5\x32\xda\xe9\x23\x6c\xfe\xe5\x99\xb1\x6d\x4f\xdc\x85\x13\xf1\xd0\x46\x6a\x90\x03\xf9\xc0\x07\x8b\x49\x86\xfe\x3a\xe2\xd3\x68\xfa\xf9\xa0\x8b\x42\x5a\xba\xb9\x2a\x74\xdc\x74\x46\x8d\x83\xf6\x49\x56\xb3\xb4\x61\x7f\xbb\x41\x7a\xc0\x81\x15\x2c\x6b\x30\xde\xdd\xdc\xc6\xe5\x2c\xb5\xf4\x3d\x89\x19\xc8\x3d\xac\x33\x1d\x79\xbf\xcc\x0f\x75\x55\xfe\xce\x3b\xe2\xf8\xc4\x6a\x86\x18\x2b\x92\xef\xb1\xea\xcc\x60\x15\xc5\xa2\x56\x31\x55\x56\x95\xe5\xae\xd9\x24\x5f\xec\x32\x7d\x27\xbe\x01\x00\x00")
+
+func _1528395684_lsif_upload_heartbeatsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395684_lsif_upload_heartbeatsUpSql,
+		"1528395684_lsif_upload_heartbeats.up.sql",
+	)
+}
+
+func _1528395684_lsif_upload_heartbeatsUpSql() (*asset, error) {
+	bytes, err := _1528395684_lsif_upload_heartbeatsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395684_lsif_upload_heartbeats.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x33, 0xbb, 0xd9, 0xb7, 0x39, 0x24, 0x1f, 0xfd, 0x17, 0x9c, 0xcd, 0xf2, 0x9b, 0x4e, 0x34, 0x1f, 0xc1, 0xf2, 0xff, 0x74, 0xf0, 0x2f, 0x87, 0xbd, 0x31, 0xa, 0x7a, 0x93, 0x78, 0x24, 0x86, 0x3}}
+	return a, nil
+}
+
+var __1528395685_lsif_upload_events_created_atDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x45\x00\xba\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x49\x4e\x44\x45\x58\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x6c\x73\x69\x66\x5f\x75\x70\x6c\x6f\x61\x64\x5f\x65\x76\x65\x6e\x74\x73\x5f\x63\x72\x65\x61\x74\x65\x64\x5f\x61\x74\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x7a\x7a\x24\x94\x45\x00\x00\x00")
+
+func _1528395685_lsif_upload_events_created_atDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395685_lsif_upload_events_created_atDownSql,
+		"1528395685_lsif_upload_events_created_at.down.sql",
+	)
+}
+
+func _1528395685_lsif_upload_events_created_atDownSql() (*asset, error) {
+	bytes, err := _1528395685_lsif_upload_events_created_atDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395685_lsif_upload_events_created_at.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x6d, 0x6a, 0xff, 0xba, 0xa4, 0x13, 0x19, 0xb8, 0xd8, 0xc7, 0xac, 0x8a, 0xf3, 0x6c, 0xd0, 0x8b, 0xcc, 0x25, 0x67, 0xb1, 0x52, 0x59, 0x11, 0x46, 0x90, 0xf8, 0x82, 0xe9, 0xd9, 0xb5, 0x1d, 0xf}}
+	return a, nil
+}
+
+var __1528395685_lsif_upload_events_created_atUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\xcc\xb1\xae\x82\x30\x14\x06\xe0\xbd\x4f\xf1\x8f\xf7\x0e\x3c\x01\x93\x62\x35\x1d\x28\x89\x74\x60\x6b\x1a\x7b\x08\x24\xb5\xa7\x29\x07\x9e\xdf\x41\x13\x17\xf7\x2f\xdf\x59\xdf\x8c\x6d\x95\x6a\x1a\x8c\x7b\x29\x5c\x05\xb2\x10\x2a\x3d\xf9\x08\x09\x3c\x83\x0e\xca\xb2\x81\x53\xa4\x0a\x59\x42\xfe\x00\xa1\x2c\x2b\x67\x14\xaa\x2b\x47\xd5\xdd\xf5\xc9\x69\x18\x7b\xd1\x13\xcc\x15\x76\x70\xd0\x93\x19\xdd\x88\xb4\xad\xb3\xdf\x4b\xe2\x10\xfd\x7b\xf3\x8f\x4a\x41\x28\xfa\x20\x18\xec\x0f\xf0\xf7\x05\xff\xad\x52\xdd\xd0\xf7\xc6\xb5\xea\x35\x00\x7b\xd4\x72\x71\xae\x00\x00\x00")
+
+func _1528395685_lsif_upload_events_created_atUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395685_lsif_upload_events_created_atUpSql,
+		"1528395685_lsif_upload_events_created_at.up.sql",
+	)
+}
+
+func _1528395685_lsif_upload_events_created_atUpSql() (*asset, error) {
+	bytes, err := _1528395685_lsif_upload_events_created_atUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395685_lsif_upload_events_created_at.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xfb, 0x3b, 0xa, 0x6f, 0xe2, 0x11, 0xc8, 0x92, 0xc6, 0x6d, 0xc9, 0x38, 0xe, 0x98, 0xda, 0x73, 0x96, 0xb1, 0xc4, 0x80, 0xf1, 0x70, 0xa5, 0x37, 0xec, 0x8, 0x8c, 0x1b, 0xd9, 0x5, 0xcb, 0x33}}
+	return a, nil
+}
+
+var __1528395686_lsif_dirty_repositoriesDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x91\xc1\x6a\xc3\x30\x10\x44\xef\xfb\x15\x73\x30\x24\xb9\xf4\x07\x44\x0e\x8e\xbd\x76\x0c\x8e\x14\x64\x99\xf4\x66\x0c\x56\x8d\xa8\x9b\xb8\xb2\x5b\xc8\xdf\x97\x44\xa5\x69\x43\x6e\x05\x9d\x46\x3b\xf3\x46\xda\x0d\xe7\x85\x14\x44\x89\xe6\xd8\x30\x94\x86\xe6\x7d\x19\x27\x8c\xac\x96\x89\x29\x94\xc4\x30\xb9\x97\xc6\x1d\x3f\xdb\xc1\x75\xed\x6c\x9b\xa3\x6d\xbd\x9d\xe6\xe6\x63\x1c\x4e\x6d\x37\x2d\x57\xd0\x6c\x6a\x2d\x2b\xcc\xde\xf5\xbd\xf5\x88\x2b\x44\x11\x5d\xa3\x09\x00\x8a\x0c\x26\x6f\xd4\x1e\x6b\x2c\x52\x2e\xd9\xf0\x02\x66\xcb\xe1\xf2\x72\x82\x88\x4c\xab\x5d\xc0\xdd\x31\x70\xd8\xb2\x66\x78\x3b\x9e\x26\x37\x9f\xfc\xb9\x71\x1d\xd6\x50\x65\xfa\xf4\x47\x13\x3f\x89\xa1\xd2\x65\x22\x68\x2c\x53\x14\x99\x20\xfa\x07\x4e\xf2\xe1\x11\xee\x1b\x25\xf9\x20\x88\x65\x2a\x28\x8a\x50\xc6\x32\xaf\xe3\x9c\x31\x0e\x63\x3f\xbd\x0f\x82\x28\xd5\x6a\x7f\xfb\xd4\x22\x03\x3f\x17\x95\xa9\x42\x81\xb7\xd6\xbf\x36\xbf\xb2\x3b\xe7\xe7\xf3\xd2\x1d\x67\xdb\x5b\xbf\x12\xc1\x6c\xe2\x4d\xc9\xf7\xce\xeb\xe4\xcd\xea\xec\x24\xc8\xe8\x5a\x26\x97\x75\x3e\x7a\x9c\x20\x4a\xd4\x6e\x57\x18\x41\x5f\x03\x00\xaf\xf9\xfd\x1e\xfd\x01\x00\x00")
+
+func _1528395686_lsif_dirty_repositoriesDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395686_lsif_dirty_repositoriesDownSql,
+		"1528395686_lsif_dirty_repositories.down.sql",
+	)
+}
+
+func _1528395686_lsif_dirty_repositoriesDownSql() (*asset, error) {
+	bytes, err := _1528395686_lsif_dirty_repositoriesDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395686_lsif_dirty_repositories.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x44, 0x22, 0x9c, 0x52, 0x98, 0x22, 0x1a, 0xbb, 0x2a, 0x4e, 0xcb, 0x4c, 0xe9, 0x21, 0xef, 0x5e, 0x45, 0x74, 0xfa, 0xba, 0x39, 0xe, 0x1e, 0x78, 0xde, 0x77, 0xbe, 0x37, 0x61, 0x52, 0x21, 0xc0}}
+	return a, nil
+}
+
+var __1528395686_lsif_dirty_repositoriesUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x55\x51\x8f\xe2\x36\x10\x7e\xcf\xaf\x98\x87\x95\x76\x91\x00\xf5\xa9\x52\x45\xaf\x52\x2e\x31\x7b\x51\x43\x82\x82\xe9\xf6\x9e\x90\x49\x86\xc4\x22\xd8\xa9\x6d\x96\xf2\xef\x2b\xdb\x01\x02\x7b\xd7\x56\x6a\xa5\x7d\xd9\x61\xfc\x7d\xdf\xcc\x7c\x33\xf9\x4c\x5e\x93\x6c\x16\x04\x93\x09\x50\xc5\xca\x3d\x98\x06\x41\x61\x27\x35\x37\x52\x71\xd4\x70\x6a\xa4\x46\x10\xc8\x14\x6a\x03\xc7\xae\x95\xac\xd2\xc0\x14\x82\x3c\x1a\x90\x3b\xa8\x98\xc1\x29\xd0\x06\xa1\xe2\xca\x9c\xc1\xc8\x3d\x0a\xe0\xda\x41\xf1\x0a\x85\xe1\x3b\x8e\xca\x52\xc8\x9d\x0b\xb6\x4c\x1b\x30\x8a\x09\xcd\x4a\xc3\xa5\x00\xd3\x30\x03\x65\xc3\x44\x8d\x95\xcb\x28\xe5\xe1\xc0\x8d\x06\xa9\xa0\x3a\x1e\x3a\x7d\x79\x79\x55\x76\x1e\x03\x13\x3e\xf7\xd8\x59\x05\x9e\xd6\x92\xf4\xcc\x43\x31\x0a\x59\x05\xdb\xf3\x8d\x5c\xe1\x4e\xa1\x6e\x2c\xac\xa5\x79\xa8\xce\x57\xa3\xe4\xc9\xf1\xb2\x01\xab\xad\xdb\x72\x1c\x3b\x30\xd2\x55\x0e\xa7\x06\x05\x6c\xa5\x69\xbc\x02\x0d\x07\x66\xca\x66\x1a\x44\x05\x09\x29\x01\x1a\x7e\x4e\x09\xb4\x9a\xef\x36\x4e\xd1\xe6\x0a\x66\x9b\xfb\x12\x00\xc0\x00\x7f\xc3\x2b\xe0\xc2\x60\x8d\x0a\x96\x45\xb2\x08\x8b\xaf\xf0\x2b\xf9\x3a\x76\x69\xfe\xbd\x63\x81\x2d\xaf\xb9\x30\x90\xe5\x14\xb2\x75\x9a\xfa\x04\xdf\x89\x6f\x67\x40\x4c\xe6\xe1\x3a\xa5\xf0\x43\x30\x9a\x05\x17\x75\xf3\x75\x16\xd1\x24\xcf\xbc\xc0\x03\x53\xfb\x9b\xbe\xb3\x17\xfc\x72\x0b\x5c\xa4\x8d\xa0\x20\x74\x5d\x64\x2b\x78\x97\xbc\x82\x70\x05\x4f\x4f\x81\x73\x92\x93\x31\x99\x40\xca\x0c\x2a\xdf\xc1\xcb\x58\xfb\xf6\x6b\x76\xc0\xbb\xd9\xef\x78\x3f\x47\x2f\x9b\xb5\x76\x58\x67\xd0\x68\xdc\x84\x4f\x8a\x1b\x04\x21\x4d\xc3\x45\xed\xe0\x93\x6c\x45\x0a\x0a\x49\x46\xf3\xef\xf7\xf5\x26\x7a\xc3\xab\xf1\xb0\x75\x23\xf8\x2d\x4c\xd7\x64\x35\xcc\x19\x83\xf9\x93\x57\x9b\xf2\xa8\x14\x0a\xf3\x32\x1a\x39\xa2\x3c\x83\x28\xcf\xe6\x69\x12\xd1\x07\xc0\x11\xc4\x39\xac\x97\xb1\x6d\xe1\x8a\xd0\x21\x3c\x7c\x02\xf2\x7b\x94\xae\x63\x12\x4f\x07\x61\x07\xf8\xf6\x85\x14\xdf\xf5\xc2\x30\x1b\x7e\xfe\xe5\x9b\x28\xb3\x80\x64\xf1\x2c\x78\x7a\x82\x34\xcc\x5e\xd7\xe1\x2b\x81\xae\xed\x6a\xfd\x47\xeb\x57\x78\xc1\xd4\xfe\xde\xaf\xee\x31\x70\xa1\x8d\x5d\x01\xbb\xad\x4a\x76\x1d\x17\xb5\xf3\xbd\x1b\x90\x33\xb0\xfd\xcf\x2f\x1d\xd4\x8a\x75\x8d\xdd\x3c\x1b\xf3\xdb\xe7\x67\x38\xb5\x14\x76\x33\x8c\xe2\x75\x8d\x4a\x83\x36\xec\x6c\xa7\x3c\x69\xf1\x1d\x5b\x60\x2e\x62\xf0\x80\xc2\xf4\xa1\x6b\x6a\xc9\x84\x90\x06\x34\xa2\x1b\xf6\xc5\x15\x4e\x01\x17\xb0\x94\xda\xd4\x0a\xb5\xa5\xf8\x69\xfa\xe3\x18\xb6\x47\x03\xc8\xca\xe6\xce\x2b\x42\x9e\xbc\x21\x34\x48\x51\x22\x74\xa8\x86\xc5\x2a\x66\x1a\x54\xf6\x9a\x88\xc1\xef\xf2\x74\x5d\xc6\xbc\x80\x82\x2c\xd3\x30\x7a\x74\x3e\x17\xef\xac\xe5\x6e\x7b\xfa\x5b\xb0\xe9\x6f\xc1\xcb\xcd\xec\x7d\x2d\x1f\xfc\x9e\xcc\x81\xbe\x6e\xf2\x25\x7c\x82\xe7\x98\xa4\x84\x92\x67\xa0\x5f\x88\xff\xd1\xfe\x2d\x49\x31\xcf\x8b\xc5\xdf\x2d\x59\x9e\xc6\xd3\x41\x90\x57\xa3\xd9\xf5\xb9\xe7\x87\x3c\x8d\x7d\x8c\x64\x31\x24\xf3\x59\xf0\x81\xdc\x3b\xf2\x19\xc2\x2c\x86\x0f\x80\xd6\x53\x19\x79\x7b\x08\xfe\x5f\x3a\xef\x34\xfd\x0b\x9c\x0f\x4a\x7a\x9c\xbe\xd6\x8c\xbc\xfd\x93\xd7\x0b\xdc\x1e\x79\xeb\x4f\xc7\xe5\x54\xe3\x3b\xaa\xf3\xd0\x11\xdc\x7e\x5b\x10\xb6\xac\xdc\xd7\x4a\x1e\x45\x15\xd0\x62\x9d\x45\x76\x6f\x9d\xb8\x87\x61\xcf\x82\xff\x7a\x5c\x82\x15\x49\x49\x44\x21\x4e\x56\x34\xc9\x22\x3a\x10\xe3\x0e\xd1\xfd\x9d\x81\x79\x91\xf7\x6d\xea\xbf\x78\xb3\x20\x88\xf2\xc5\x22\xa1\xb3\xe0\xaf\x01\x00\xe2\x82\x40\xd4\x9b\x07\x00\x00")
+
+func _1528395686_lsif_dirty_repositoriesUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395686_lsif_dirty_repositoriesUpSql,
+		"1528395686_lsif_dirty_repositories.up.sql",
+	)
+}
+
+func _1528395686_lsif_dirty_repositoriesUpSql() (*asset, error) {
+	bytes, err := _1528395686_lsif_dirty_repositoriesUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395686_lsif_dirty_repositories.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x14, 0xc3, 0x44, 0x25, 0xb3, 0xd9, 0x9c, 0x1a, 0x9a, 0xed, 0xbd, 0xd0, 0x68, 0xbb, 0xe2, 0x71, 0xec, 0x6c, 0xe1, 0x85, 0xd7, 0xf3, 0xda, 0x7, 0x85, 0xba, 0xad, 0x5e, 0x43, 0xd4, 0xb4, 0x63}}
+	return a, nil
+}
+
+var __1528395687_lsif_upload_progress_notificationsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\xce\xb1\x6a\xc3\x40\x0c\xc6\xf1\x5d\x4f\xf1\x8d\xc9\xd2\x17\xf0\xe4\xde\xe9\x1c\x41\x7d\x32\xb2\x8c\xb3\x1d\x86\x24\x10\x08\x4d\xc0\x97\xa1\x6f\x5f\x4a\xa0\x60\x28\x74\x96\xfe\x3f\xbe\x77\xee\x24\x37\x44\xd1\x74\x80\x9b\x74\x1d\x1b\x24\x81\x8f\x32\xfa\x88\xdb\x7a\xbd\x94\xe7\xe3\x76\x5f\x4e\x6b\x79\x3e\x4e\x4b\x3d\x97\xcf\x7b\xbd\x5e\xbe\xca\x5a\x97\x7a\x86\xe6\xcd\x4f\x43\x14\x8c\x5b\xe7\x5f\xeb\x5f\xa1\x4d\xce\x86\x69\x88\x3f\x95\x26\xfc\xe9\x12\x00\x24\x35\x70\x1b\x0e\x30\x9d\x31\x1f\x38\x63\xa7\x1f\xf1\xed\x15\xc8\x88\x28\xa3\x4b\x0e\x8e\x64\xda\x23\xf3\xfc\x3a\xed\xc1\x47\x0e\x93\x33\x06\xd3\xc0\x71\x32\xde\xd8\x9b\x39\xbb\x7d\x43\x14\xb4\xef\xc5\x1b\xfa\x1e\x00\x86\x18\x55\xb7\x1d\x01\x00\x00")
+
+func _1528395687_lsif_upload_progress_notificationsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395687_lsif_upload_progress_notificationsDownSql,
+		"1528395687_lsif_upload_progress_notifications.down.sql",
+	)
+}
+
+func _1528395687_lsif_upload_progress_notificationsDownSql() (*asset, error) {
+	bytes, err := _1528395687_lsif_upload_progress_notificationsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395687_lsif_upload_progress_notifications.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x4c, 0x95, 0xeb, 0x73, 0x81, 0x75, 0x82, 0xf7, 0xaf, 0x0, 0xed, 0x7d, 0x7d, 0x1b, 0xa, 0x6e, 0x5d, 0x62, 0x3f, 0x8e, 0x83, 0xbe, 0xf7, 0xc4, 0x5b, 0xfd, 0xea, 0x5e, 0x89, 0x44, 0x2c, 0x88}}
+	return a, nil
+}
+
+var __1528395687_lsif_upload_progress_notificationsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x90\x51\x6b\xc2\x30\x14\x85\xdf\xf3\x2b\xce\xa3\x82\xee\x0f\xf4\xa9\x6b\x6f\xb5\x30\x1b\x89\x11\xf7\x56\xca\x7a\x1b\x03\x25\x91\x26\x32\xf6\xef\x47\x3b\xb7\xa9\xdb\x60\x10\x08\xdc\xf3\x9d\x73\x0f\xf7\x91\x56\x65\x95\x08\xb1\x5c\x22\xed\x83\x47\x60\xd7\x22\x1e\x19\xb6\x65\x17\x6d\x67\x79\x80\xef\xd0\x38\x9c\x4f\xbd\x6f\x5a\x78\x37\xc9\x7d\xb0\x5d\xfd\x31\xaa\x43\x6c\x22\xe3\xe5\xd8\x38\xc7\x3d\x5e\x8f\xec\x60\x63\x40\x88\x8d\x61\xf8\x01\xa7\xc1\x9b\x81\x43\x98\x10\xc3\x41\xe4\x4a\x6e\xa1\x55\xb9\x5a\x91\xba\x4e\x0a\xf5\xf9\xd4\x36\x91\x6b\xe7\xa3\xed\xde\x2e\xc1\xb2\xba\x61\x12\x21\x32\x45\xa9\xa6\xff\x27\xa4\x85\x26\x85\xfd\x36\x1f\x5d\xb2\x18\x9b\x45\x5e\x8c\x9f\xe1\xc5\x77\xbd\xbb\x45\x02\x00\x0a\xa9\x40\x69\xb6\x86\x92\x07\x1c\xd6\x54\x61\x36\xcd\xc7\x27\x9f\xf2\x87\x29\x0a\xe5\x0e\x79\xb9\xd3\x65\x95\x69\x14\x4a\x6e\x50\xd1\xe1\x22\x49\x75\xcf\x9b\xbf\x79\xf3\x83\xff\x2a\xf7\xab\xe5\x53\x9d\x2c\x73\xd0\x33\x65\x7b\x4d\xd8\x2a\x99\x51\xbe\x57\x74\x7b\x9a\xeb\x9b\xcc\xe6\x89\x10\x99\xdc\x6c\x4a\x9d\x88\xf7\x01\x00\xc9\xfb\xa5\x60\x05\x02\x00\x00")
+
+func _1528395687_lsif_upload_progress_notificationsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395687_lsif_upload_progress_notificationsUpSql,
+		"1528395687_lsif_upload_progress_notifications.up.sql",
+	)
+}
+
+func _1528395687_lsif_upload_progress_notificationsUpSql() (*asset, error) {
+	bytes, err := _1528395687_lsif_upload_progress_notificationsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395687_lsif_upload_progress_notifications.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x6b, 0xf4, 0xa, 0x5a, 0x0, 0x22, 0xa3, 0x49, 0x2d, 0x92, 0x9f, 0xb8, 0x33, 0x46, 0x84, 0xf9, 0x60, 0x77, 0xf7, 0x3b, 0xf1, 0xbb, 0x5e, 0x2, 0x5b, 0x82, 0x89, 0xfe, 0x6c, 0x76, 0x10, 0x2b}}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -2403,6 +3243,46 @@ var _bindata = map[string]func() (*asset, error){
 	"1528395666_lsif_filename.up.sql":                                         _1528395666_lsif_filenameUpSql,
 	"1528395667_index_boolean_fields_on_repo.down.sql":                        _1528395667_index_boolean_fields_on_repoDownSql,
 	"1528395667_index_boolean_fields_on_repo.up.sql":                          _1528395667_index_boolean_fields_on_repoUpSql,
+	"1528395668_lsif_superseded_dumps.down.sql":                               _1528395668_lsif_superseded_dumpsDownSql,
+	"1528395668_lsif_superseded_dumps.up.sql":                                 _1528395668_lsif_superseded_dumpsUpSql,
+	"1528395669_lsif_visibility_overrides.down.sql":                           _1528395669_lsif_visibility_overridesDownSql,
+	"1528395669_lsif_visibility_overrides.up.sql":                             _1528395669_lsif_visibility_overridesUpSql,
+	"1528395670_lsif_dump_statistics.down.sql":                                _1528395670_lsif_dump_statisticsDownSql,
+	"1528395670_lsif_dump_statistics.up.sql":                                  _1528395670_lsif_dump_statisticsUpSql,
+	"1528395671_lsif_upload_checksums.down.sql":                               _1528395671_lsif_upload_checksumsDownSql,
+	"1528395671_lsif_upload_checksums.up.sql":                                 _1528395671_lsif_upload_checksumsUpSql,
+	"1528395672_lsif_upload_attempts.down.sql":                                _1528395672_lsif_upload_attemptsDownSql,
+	"1528395672_lsif_upload_attempts.up.sql":                                  _1528395672_lsif_upload_attemptsUpSql,
+	"1528395673_lsif_upload_soft_delete.down.sql":                             _1528395673_lsif_upload_soft_deleteDownSql,
+	"1528395673_lsif_upload_soft_delete.up.sql":                               _1528395673_lsif_upload_soft_deleteUpSql,
+	"1528395674_lsif_upload_events.down.sql":                                  _1528395674_lsif_upload_eventsDownSql,
+	"1528395674_lsif_upload_events.up.sql":                                    _1528395674_lsif_upload_eventsUpSql,
+	"1528395675_lsif_uploads_queued_repository_id.down.sql":                   _1528395675_lsif_uploads_queued_repository_idDownSql,
+	"1528395675_lsif_uploads_queued_repository_id.up.sql":                     _1528395675_lsif_uploads_queued_repository_idUpSql,
+	"1528395676_lsif_packages_name_trgm.down.sql":                             _1528395676_lsif_packages_name_trgmDownSql,
+	"1528395676_lsif_packages_name_trgm.up.sql":                               _1528395676_lsif_packages_name_trgmUpSql,
+	"1528395677_lsif_upload_last_accessed_at.down.sql":                        _1528395677_lsif_upload_last_accessed_atDownSql,
+	"1528395677_lsif_upload_last_accessed_at.up.sql":                          _1528395677_lsif_upload_last_accessed_atUpSql,
+	"1528395678_lsif_upload_metadata.down.sql":                                _1528395678_lsif_upload_metadataDownSql,
+	"1528395678_lsif_upload_metadata.up.sql":                                  _1528395678_lsif_upload_metadataUpSql,
+	"1528395679_lsif_upload_format.down.sql":                                  _1528395679_lsif_upload_formatDownSql,
+	"1528395679_lsif_upload_format.up.sql":                                    _1528395679_lsif_upload_formatUpSql,
+	"1528395680_lsif_nearest_uploads.down.sql":                                _1528395680_lsif_nearest_uploadsDownSql,
+	"1528395680_lsif_nearest_uploads.up.sql":                                  _1528395680_lsif_nearest_uploadsUpSql,
+	"1528395681_lsif_upload_progress.down.sql":                                _1528395681_lsif_upload_progressDownSql,
+	"1528395681_lsif_upload_progress.up.sql":                                  _1528395681_lsif_upload_progressUpSql,
+	"1528395682_lsif_upload_state_notifications.down.sql":                     _1528395682_lsif_upload_state_notificationsDownSql,
+	"1528395682_lsif_upload_state_notifications.up.sql":                       _1528395682_lsif_upload_state_notificationsUpSql,
+	"1528395683_lsif_upload_queued_notifications.down.sql":                    _1528395683_lsif_upload_queued_notificationsDownSql,
+	"1528395683_lsif_upload_queued_notifications.up.sql":                      _1528395683_lsif_upload_queued_notificationsUpSql,
+	"1528395684_lsif_upload_heartbeats.down.sql":                              _1528395684_lsif_upload_heartbeatsDownSql,
+	"1528395684_lsif_upload_heartbeats.up.sql":                                _1528395684_lsif_upload_heartbeatsUpSql,
+	"1528395685_lsif_upload_events_created_at.down.sql":                       _1528395685_lsif_upload_events_created_atDownSql,
+	"1528395685_lsif_upload_events_created_at.up.sql":                         _1528395685_lsif_upload_events_created_atUpSql,
+	"1528395686_lsif_dirty_repositories.down.sql":                             _1528395686_lsif_dirty_repositoriesDownSql,
+	"1528395686_lsif_dirty_repositories.up.sql":                               _1528395686_lsif_dirty_repositoriesUpSql,
+	"1528395687_lsif_upload_progress_notifications.down.sql":                  _1528395687_lsif_upload_progress_notificationsDownSql,
+	"1528395687_lsif_upload_progress_notifications.up.sql":                    _1528395687_lsif_upload_progress_notificationsUpSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -2548,6 +3428,46 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"1528395666_lsif_filename.up.sql":                                         {_1528395666_lsif_filenameUpSql, map[string]*bintree{}},
 	"1528395667_index_boolean_fields_on_repo.down.sql":                        {_1528395667_index_boolean_fields_on_repoDownSql, map[string]*bintree{}},
 	"1528395667_index_boolean_fields_on_repo.up.sql":                          {_1528395667_index_boolean_fields_on_repoUpSql, map[string]*bintree{}},
+	"1528395668_lsif_superseded_dumps.down.sql":                               {_1528395668_lsif_superseded_dumpsDownSql, map[string]*bintree{}},
+	"1528395668_lsif_superseded_dumps.up.sql":                                 {_1528395668_lsif_superseded_dumpsUpSql, map[string]*bintree{}},
+	"1528395669_lsif_visibility_overrides.down.sql":                           {_1528395669_lsif_visibility_overridesDownSql, map[string]*bintree{}},
+	"1528395669_lsif_visibility_overrides.up.sql":                             {_1528395669_lsif_visibility_overridesUpSql, map[string]*bintree{}},
+	"1528395670_lsif_dump_statistics.down.sql":                                {_1528395670_lsif_dump_statisticsDownSql, map[string]*bintree{}},
+	"1528395670_lsif_dump_statistics.up.sql":                                  {_1528395670_lsif_dump_statisticsUpSql, map[string]*bintree{}},
+	"1528395671_lsif_upload_checksums.down.sql":                               {_1528395671_lsif_upload_checksumsDownSql, map[string]*bintree{}},
+	"1528395671_lsif_upload_checksums.up.sql":                                 {_1528395671_lsif_upload_checksumsUpSql, map[string]*bintree{}},
+	"1528395672_lsif_upload_attempts.down.sql":                                {_1528395672_lsif_upload_attemptsDownSql, map[string]*bintree{}},
+	"1528395672_lsif_upload_attempts.up.sql":                                  {_1528395672_lsif_upload_attemptsUpSql, map[string]*bintree{}},
+	"1528395673_lsif_upload_soft_delete.down.sql":                             {_1528395673_lsif_upload_soft_deleteDownSql, map[string]*bintree{}},
+	"1528395673_lsif_upload_soft_delete.up.sql":                               {_1528395673_lsif_upload_soft_deleteUpSql, map[string]*bintree{}},
+	"1528395674_lsif_upload_events.down.sql":                                  {_1528395674_lsif_upload_eventsDownSql, map[string]*bintree{}},
+	"1528395674_lsif_upload_events.up.sql":                                    {_1528395674_lsif_upload_eventsUpSql, map[string]*bintree{}},
+	"1528395675_lsif_uploads_queued_repository_id.down.sql":                   {_1528395675_lsif_uploads_queued_repository_idDownSql, map[string]*bintree{}},
+	"1528395675_lsif_uploads_queued_repository_id.up.sql":                     {_1528395675_lsif_uploads_queued_repository_idUpSql, map[string]*bintree{}},
+	"1528395676_lsif_packages_name_trgm.down.sql":                             {_1528395676_lsif_packages_name_trgmDownSql, map[string]*bintree{}},
+	"1528395676_lsif_packages_name_trgm.up.sql":                               {_1528395676_lsif_packages_name_trgmUpSql, map[string]*bintree{}},
+	"1528395677_lsif_upload_last_accessed_at.down.sql":                        {_1528395677_lsif_upload_last_accessed_atDownSql, map[string]*bintree{}},
+	"1528395677_lsif_upload_last_accessed_at.up.sql":                          {_1528395677_lsif_upload_last_accessed_atUpSql, map[string]*bintree{}},
+	"1528395678_lsif_upload_metadata.down.sql":                                {_1528395678_lsif_upload_metadataDownSql, map[string]*bintree{}},
+	"1528395678_lsif_upload_metadata.up.sql":                                  {_1528395678_lsif_upload_metadataUpSql, map[string]*bintree{}},
+	"1528395679_lsif_upload_format.down.sql":                                  {_1528395679_lsif_upload_formatDownSql, map[string]*bintree{}},
+	"1528395679_lsif_upload_format.up.sql":                                    {_1528395679_lsif_upload_formatUpSql, map[string]*bintree{}},
+	"1528395680_lsif_nearest_uploads.down.sql":                                {_1528395680_lsif_nearest_uploadsDownSql, map[string]*bintree{}},
+	"1528395680_lsif_nearest_uploads.up.sql":                                  {_1528395680_lsif_nearest_uploadsUpSql, map[string]*bintree{}},
+	"1528395681_lsif_upload_progress.down.sql":                                {_1528395681_lsif_upload_progressDownSql, map[string]*bintree{}},
+	"1528395681_lsif_upload_progress.up.sql":                                  {_1528395681_lsif_upload_progressUpSql, map[string]*bintree{}},
+	"1528395682_lsif_upload_state_notifications.down.sql":                     {_1528395682_lsif_upload_state_notificationsDownSql, map[string]*bintree{}},
+	"1528395682_lsif_upload_state_notifications.up.sql":                       {_1528395682_lsif_upload_state_notificationsUpSql, map[string]*bintree{}},
+	"1528395683_lsif_upload_queued_notifications.down.sql":                    {_1528395683_lsif_upload_queued_notificationsDownSql, map[string]*bintree{}},
+	"1528395683_lsif_upload_queued_notifications.up.sql":                      {_1528395683_lsif_upload_queued_notificationsUpSql, map[string]*bintree{}},
+	"1528395684_lsif_upload_heartbeats.down.sql":                              {_1528395684_lsif_upload_heartbeatsDownSql, map[string]*bintree{}},
+	"1528395684_lsif_upload_heartbeats.up.sql":                                {_1528395684_lsif_upload_heartbeatsUpSql, map[string]*bintree{}},
+	"1528395685_lsif_upload_events_created_at.down.sql":                       {_1528395685_lsif_upload_events_created_atDownSql, map[string]*bintree{}},
+	"1528395685_lsif_upload_events_created_at.up.sql":                         {_1528395685_lsif_upload_events_created_atUpSql, map[string]*bintree{}},
+	"1528395686_lsif_dirty_repositories.down.sql":                             {_1528395686_lsif_dirty_repositoriesDownSql, map[string]*bintree{}},
+	"1528395686_lsif_dirty_repositories.up.sql":                               {_1528395686_lsif_dirty_repositoriesUpSql, map[string]*bintree{}},
+	"1528395687_lsif_upload_progress_notifications.down.sql":                  {_1528395687_lsif_upload_progress_notificationsDownSql, map[string]*bintree{}},
+	"1528395687_lsif_upload_progress_notifications.up.sql":                    {_1528395687_lsif_upload_progress_notificationsUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory.
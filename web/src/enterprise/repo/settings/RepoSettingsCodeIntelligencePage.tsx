@@ -75,8 +75,11 @@ const LsifUploadNode: FunctionComponent<{ node: GQL.ILSIFUpload; onDelete: () =>
                                     <span>Processing</span>
                                 ) : node.state === GQL.LSIFUploadState.COMPLETED ? (
                                     <span className="text-success">Processed</span>
-                                ) : node.state === GQL.LSIFUploadState.ERRORED ? (
+                                ) : node.state === GQL.LSIFUploadState.ERRORED ||
+                                  node.state === GQL.LSIFUploadState.FAILED ? (
                                     <span className="text-danger">Failed to process</span>
+                                ) : node.state === GQL.LSIFUploadState.DELETING ? (
+                                    <span className="text-muted">Deleted</span>
                                 ) : (
                                     <span>Waiting to process (#{node.placeInQueue} in line)</span>
                                 )}
@@ -26,7 +26,12 @@ interface Props extends RouteComponentProps<{ id: string }> {
     scheduler?: SchedulerLike
 }
 
-const terminalStates = [GQL.LSIFUploadState.COMPLETED, GQL.LSIFUploadState.ERRORED]
+const terminalStates = [
+    GQL.LSIFUploadState.COMPLETED,
+    GQL.LSIFUploadState.ERRORED,
+    GQL.LSIFUploadState.FAILED,
+    GQL.LSIFUploadState.DELETING,
+]
 
 function shouldReload(v: GQL.ILSIFUpload | ErrorLike | null | undefined): boolean {
     return !isErrorLike(v) && !(v && terminalStates.includes(v.state))
@@ -115,12 +120,17 @@ export const RepoSettingsLsifUploadPage: FunctionComponent<Props> = ({
                             <CheckIcon className="icon-inline" />{' '}
                             <span className="e2e-upload-state">Upload processed successfully.</span>
                         </div>
-                    ) : uploadOrError.state === GQL.LSIFUploadState.ERRORED ? (
+                    ) : uploadOrError.state === GQL.LSIFUploadState.ERRORED ||
+                      uploadOrError.state === GQL.LSIFUploadState.FAILED ? (
                         <div className="alert alert-danger mb-4 mt-3">
                             <AlertCircleIcon className="icon-inline" />{' '}
                             <span className="e2e-upload-state">Upload failed to complete:</span>{' '}
                             <code>{uploadOrError.failure && uploadOrError.failure.summary}</code>
                         </div>
+                    ) : uploadOrError.state === GQL.LSIFUploadState.DELETING ? (
+                        <div className="alert alert-warning mb-4 mt-3">
+                            <span className="e2e-upload-state">Upload has been deleted.</span>
+                        </div>
                     ) : (
                         <div className="alert alert-primary mb-4 mt-3">
                             <ClockOutlineIcon className="icon-inline" />{' '}
@@ -209,7 +219,9 @@ export const RepoSettingsLsifUploadPage: FunctionComponent<Props> = ({
 
                             <tr>
                                 <td>
-                                    {uploadOrError.state === GQL.LSIFUploadState.ERRORED && uploadOrError.finishedAt
+                                    {(uploadOrError.state === GQL.LSIFUploadState.ERRORED ||
+                                        uploadOrError.state === GQL.LSIFUploadState.FAILED) &&
+                                    uploadOrError.finishedAt
                                         ? 'Failed'
                                         : 'Finished'}{' '}
                                     processing